@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/VictoriaMetrics/vmgather/internal/application/services"
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+)
+
+func withStdin(t *testing.T, input string) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %v", err)
+	}
+	if _, err := w.WriteString(input); err != nil {
+		t.Fatalf("failed to write stdin: %v", err)
+	}
+	_ = w.Close()
+	prev := os.Stdin
+	os.Stdin = r
+	t.Cleanup(func() {
+		os.Stdin = prev
+		_ = r.Close()
+	})
+}
+
+func TestRunConfigExport_ReadsConfigFromStdin(t *testing.T) {
+	vmServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/export" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		_, _ = w.Write([]byte(`{"metric":{"__name__":"up","job":"vmstorage"},"values":[1],"timestamps":[1700000000000]}` + "\n"))
+	}))
+	defer vmServer.Close()
+
+	withStdin(t, `{
+  "connection": {"url": "`+vmServer.URL+`", "auth": {"type": "none"}},
+  "time_range": {"start": "2026-01-23T12:00:00Z", "end": "2026-01-23T12:30:00Z"},
+  "staging_dir": "`+t.TempDir()+`"
+}`)
+
+	var out bytes.Buffer
+	var progress bytes.Buffer
+	service := services.NewExportServiceWithProgress(t.TempDir(), "test", &progress)
+	if err := runOneshot(context.Background(), oneshotOptions{configPath: "-", resultJSON: true}, service, &out); err != nil {
+		t.Fatalf("runOneshot failed: %v", err)
+	}
+	if !strings.Contains(progress.String(), "Processing batch") {
+		t.Fatalf("expected export progress on the progress writer, got %q", progress.String())
+	}
+
+	var result domain.ExportResult
+	if err := json.Unmarshal(out.Bytes(), &result); err != nil {
+		t.Fatalf("stdout is not an export result JSON: %v\n%s", err, out.String())
+	}
+	if result.MetricsExported == 0 || result.ArchivePath == "" {
+		t.Fatalf("unexpected export result: %+v", result)
+	}
+	if _, err := os.Stat(result.ArchivePath); err != nil {
+		t.Fatalf("archive not written: %v", err)
+	}
+}
+
+func TestLoadExportConfig_RejectsMalformedInput(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: `{"connection": `, want: "malformed export config JSON"},
+		{input: `{"time_range": {"start": "2026-01-23T12:00:00Z", "end": "2026-01-23T13:00:00Z"}}`, want: "connection.url is required"},
+		{input: `{"connection": {"url": "http://vm:8428"}}`, want: "time_range.start and time_range.end are required"},
+		{input: `{"connection": {"url": "http://vm:8428"}, "time_range": {"start": "2026-01-23T13:00:00Z", "end": "2026-01-23T12:00:00Z"}}`, want: "must be after"},
+		{input: `{"connection": {"url": "http://vm:8428"}, "time_range": {"start": "2026-01-23T12:00:00Z", "end": "2026-01-23T13:00:00Z"}, "archive_collision": "rename"}`, want: "unknown archive collision strategy"},
+	}
+	for _, tt := range tests {
+		withStdin(t, tt.input)
+		_, err := loadExportConfig("-")
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Fatalf("input %s: expected error containing %q, got %v", tt.input, tt.want, err)
+		}
+	}
+}
@@ -14,11 +14,15 @@ import (
 	"os/signal"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/VictoriaMetrics/vmgather/internal/application/services"
+	"github.com/VictoriaMetrics/vmgather/internal/bindaddr"
 	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/archive"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/audit"
 	"github.com/VictoriaMetrics/vmgather/internal/server"
 )
 
@@ -26,14 +30,34 @@ import (
 var version = "dev"
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "selftest" {
+		// Export progress goes to stderr; stdout carries the step results only
+		if err := runSelfTest(context.Background(), os.Stdout, os.Stderr); err != nil {
+			fmt.Fprintf(os.Stdout, "[FAIL] selftest: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintln(os.Stdout, "[PASS] selftest: export -> import round trip verified")
+		return
+	}
+
 	// Parse flags
-	addr := flag.String("addr", "localhost:8080", "HTTP server address")
+	addr := flag.String("addr", defaultAddr, "HTTP server address")
+	allowAllInterfaces := flag.Bool("allow-all-interfaces", false, "Allow -addr to listen on all network interfaces (e.g. 0.0.0.0:8080), exposing vmgather to the network")
 	outputDirFlag := flag.String("output", "", "Export output directory")
 	noBrowser := flag.Bool("no-browser", false, "Don't open browser automatically")
 	debug := flag.Bool("debug", false, "Enable debug logging")
 	oneshot := flag.Bool("oneshot", false, "Run a single export and exit (experimental)")
 	oneshotConfig := flag.String("oneshot-config", "", "Path to export config JSON for oneshot (use '-' for stdin)")
 	exportStdout := flag.Bool("export-stdout", false, "Stream exported metrics to stdout (oneshot only)")
+	summaryJSON := flag.Bool("json", false, "Print the -oneshot export summary as a JSON line instead of key=value text")
+	configPath := flag.String("config", "", "Like -oneshot -oneshot-config, but prints the full export result JSON to stdout (use '-' for stdin)")
+	archiveCollision := flag.String("archive-collision", "", "With -oneshot or -config, what to do when vmexport_<export_id>.zip exists: unique (default, timestamped name), overwrite, skip or version; overrides archive_collision in the config")
+	safeMode := flag.Bool("safe-mode", false, "Disable filesystem browsing endpoints and confine export paths to the output directory")
+	auditLogPath := flag.String("audit-log", "", "Append a JSON line per completed export (who/what/when, no credentials) to this file")
+	schedulePath := flag.String("schedule", "", "Path to a schedule JSON that runs an export periodically while the server is up (see docs/user-guide.md)")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 5*time.Second, "Time to wait for in-flight exports and requests to stop on shutdown")
+	heavyComponentSeries := flag.Int("heavy-component-series", server.DefaultHeavyComponentSeries, "Discovery estimate in series above which a component must be listed in confirmed_heavy_components to be exported (0 disables)")
+	progressInterval := flag.Duration("progress-interval", 500*time.Millisecond, "Shortest time between two progress updates of an export job (0 publishes every batch)")
 	flag.Parse()
 
 	log.Printf("vmgather v%s starting...", version)
@@ -43,39 +67,58 @@ func main() {
 		outputDir = defaultOutputDir()
 	}
 
+	if *configPath != "" {
+		if *oneshotConfig != "" {
+			log.Fatal("-config and -oneshot-config are alternatives; pass only one")
+		}
+		if *exportStdout {
+			log.Fatal("-config prints the export result; use -oneshot -oneshot-config with -export-stdout")
+		}
+		*oneshot, *oneshotConfig = true, *configPath
+	}
 	if *exportStdout && !*oneshot {
 		log.Fatal("export-stdout is only supported with -oneshot")
 	}
+	if _, err := archive.ParseCollisionStrategy(*archiveCollision); err != nil {
+		log.Fatalf("invalid -archive-collision: %v", err)
+	}
 
+	var auditLogger *audit.Logger
+	if *auditLogPath != "" {
+		logger, err := audit.NewLogger(*auditLogPath)
+		if err != nil {
+			log.Fatalf("Failed to open audit log: %v", err)
+		}
+		auditLogger = logger
+		log.Printf("Audit log: %s", logger.Path())
+	}
 	if *oneshot {
 		if *oneshotConfig == "" {
 			log.Fatal("oneshot requires -oneshot-config")
 		}
-		cfg, err := loadExportConfig(*oneshotConfig)
-		if err != nil {
-			log.Fatalf("failed to load export config: %v", err)
+		// Export progress goes to stderr; stdout carries the metrics, the result JSON or the summary only
+		service := services.NewAuditedExportService(services.NewExportServiceWithProgress(outputDir, version, os.Stderr), auditLogger)
+		opts := oneshotOptions{
+			configPath:       *oneshotConfig,
+			archiveCollision: *archiveCollision,
+			exportStdout:     *exportStdout,
+			resultJSON:       *configPath != "",
+			summaryJSON:      *summaryJSON,
 		}
-		services.ApplyExportDefaults(&cfg)
-
-		ctx := context.Background()
-		if *exportStdout {
-			count, err := services.ExportToWriter(ctx, cfg, os.Stdout)
-			if err != nil {
-				log.Fatalf("oneshot export failed: %v", err)
-			}
-			log.Printf("[OK] Exported %d metrics to stdout", count)
-			return
-		}
-
-		result, err := services.NewExportService(outputDir, version).ExecuteExport(ctx, cfg)
-		if err != nil {
+		if err := runOneshot(context.Background(), opts, service, os.Stdout); err != nil {
 			log.Fatalf("oneshot export failed: %v", err)
 		}
-		log.Printf("[OK] Export complete: id=%s metrics=%d archive=%s",
-			result.ExportID, result.MetricsExported, result.ArchivePath)
 		return
 	}
 
+	allInterfaces, err := bindaddr.Check(*addr, *allowAllInterfaces)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if allInterfaces {
+		log.Printf("[WARN] Listening on all network interfaces: anyone who can reach %s can use the %s", *addr, exposedEndpoints)
+	}
+
 	// Try to find available port if default is busy
 	finalAddr, err := ensureAvailablePort(*addr)
 	if err != nil {
@@ -87,6 +130,27 @@ func main() {
 
 	// Create HTTP server
 	srv := server.NewServer(outputDir, version, *debug)
+	srv.SetSafeMode(*safeMode)
+	srv.SetHeavyComponentThreshold(*heavyComponentSeries)
+	srv.SetProgressInterval(*progressInterval)
+	if auditLogger != nil {
+		srv.SetAuditLogger(auditLogger)
+	}
+	if *schedulePath != "" {
+		spec, err := server.LoadScheduleSpec(*schedulePath)
+		if err != nil {
+			log.Fatalf("failed to load schedule: %v", err)
+		}
+		if err := srv.StartSchedule(spec); err != nil {
+			log.Fatalf("failed to start schedule: %v", err)
+		}
+		log.Printf("Scheduled export every %ds (keeping %d archives); status at /api/schedule/status", spec.IntervalSeconds, spec.KeepArchives)
+	}
+	if restored, err := srv.RestoreExportJobs(); err != nil {
+		log.Printf("[WARN] Failed to restore interrupted export jobs: %v", err)
+	} else if restored > 0 {
+		log.Printf("Restored %d interrupted export job(s); resume them via /api/export/resume", restored)
+	}
 	httpServer := &http.Server{
 		Addr:              finalAddr,
 		Handler:           srv.Router(),
@@ -118,9 +182,12 @@ func main() {
 	log.Println("Shutting down server...")
 
 	// Graceful shutdown
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
 	defer cancel()
 
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("Export jobs shutdown error: %v", err)
+	}
 	if err := httpServer.Shutdown(ctx); err != nil {
 		log.Printf("Server shutdown error: %v", err)
 	}
@@ -166,11 +233,90 @@ func loadExportConfig(path string) (domain.ExportConfig, error) {
 	var cfg domain.ExportConfig
 	dec := json.NewDecoder(reader)
 	if err := dec.Decode(&cfg); err != nil {
-		return domain.ExportConfig{}, err
+		return domain.ExportConfig{}, fmt.Errorf("malformed export config JSON: %w", err)
+	}
+	if err := validateExportConfig(cfg); err != nil {
+		return domain.ExportConfig{}, fmt.Errorf("invalid export config: %w", err)
 	}
 	return cfg, nil
 }
 
+// validateExportConfig checks the fields a headless export cannot run without
+func validateExportConfig(cfg domain.ExportConfig) error {
+	if cfg.Connection.URL == "" {
+		return fmt.Errorf("connection.url is required")
+	}
+	if cfg.TimeRange.Start.IsZero() || cfg.TimeRange.End.IsZero() {
+		return fmt.Errorf("time_range.start and time_range.end are required")
+	}
+	if !cfg.TimeRange.End.After(cfg.TimeRange.Start) {
+		return fmt.Errorf("time_range.end must be after time_range.start")
+	}
+	if cfg.Mode == domain.ExportModeCustom && cfg.Query == "" {
+		return fmt.Errorf("query is required in custom mode")
+	}
+	if id := strings.TrimSpace(cfg.ExportID); id != "" {
+		if err := services.ValidateExportID(id); err != nil {
+			return err
+		}
+	}
+	if err := services.ValidateInstances(cfg.Instances); err != nil {
+		return err
+	}
+	if _, err := archive.ParseCollisionStrategy(cfg.ArchiveCollision); err != nil {
+		return err
+	}
+	return nil
+}
+
+// oneshotOptions are the flags of a -oneshot or -config run
+type oneshotOptions struct {
+	configPath       string // export config JSON, '-' for stdin
+	archiveCollision string // replaces the config's archive_collision when set
+	exportStdout     bool   // stream the metrics to out instead of writing an archive
+	resultJSON       bool   // print the full ExportResult (-config) instead of the summary
+	summaryJSON      bool   // print the summary as a JSON line
+}
+
+// runOneshot loads the export config, runs a single export and writes its output to out:
+// the metrics with exportStdout, otherwise the ExportResult JSON or the summary line.
+func runOneshot(ctx context.Context, opts oneshotOptions, service services.ExportService, out io.Writer) error {
+	cfg, err := loadExportConfig(opts.configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load export config: %w", err)
+	}
+	if opts.archiveCollision != "" {
+		cfg.ArchiveCollision = opts.archiveCollision
+	}
+	services.ApplyExportDefaults(&cfg)
+
+	if opts.exportStdout {
+		count, err := services.ExportToWriter(ctx, cfg, out)
+		if err != nil {
+			return err
+		}
+		log.Printf("[OK] Exported %d metrics to stdout", count)
+		return nil
+	}
+
+	started := time.Now()
+	result, err := service.ExecuteExport(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	log.Printf("[OK] Export complete: id=%s metrics=%d archive=%s",
+		result.ExportID, result.MetricsExported, result.ArchivePath)
+	if opts.resultJSON {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+	}
+	if err := writeExportSummary(out, newExportSummary(result, time.Since(started)), opts.summaryJSON); err != nil {
+		return fmt.Errorf("failed to print export summary: %w", err)
+	}
+	return nil
+}
+
 // ensureAvailablePort checks if the given address is available
 // If not, tries to find an ephemeral port automatically
 func ensureAvailablePort(addr string) (string, error) {
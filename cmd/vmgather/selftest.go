@@ -0,0 +1,337 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/application/services"
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	importer "github.com/VictoriaMetrics/vmgather/internal/importer/server"
+)
+
+const (
+	selfTestDuration = 10 * time.Minute
+	selfTestStep     = 15 * time.Second
+)
+
+// selfTestSeries is one series of the synthetic dataset, also used to collect imported samples
+type selfTestSeries struct {
+	Metric     map[string]string `json:"metric"`
+	Values     []float64         `json:"values"`
+	Timestamps []int64           `json:"timestamps"`
+}
+
+// selfTestVM mocks the VictoriaMetrics endpoints used by an export→import round trip:
+// /api/v1/export serves the synthetic dataset and /api/v1/import records what is pushed back.
+type selfTestVM struct {
+	dataset []selfTestSeries
+
+	mu       sync.Mutex
+	imported map[string]*selfTestSeries
+}
+
+func (m *selfTestVM) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/api/v1/export":
+		m.serveExport(w, r)
+	case "/api/v1/import":
+		m.serveImport(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serveExport returns the samples within [start, end) so consecutive batch windows never overlap
+func (m *selfTestVM) serveExport(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	start, err := time.Parse(time.RFC3339, r.FormValue("start"))
+	if err != nil {
+		http.Error(w, "invalid start: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	end, err := time.Parse(time.RFC3339, r.FormValue("end"))
+	if err != nil {
+		http.Error(w, "invalid end: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	encoder := json.NewEncoder(w)
+	for _, series := range m.dataset {
+		line := selfTestSeries{Metric: series.Metric}
+		for i, ts := range series.Timestamps {
+			if ts >= start.UnixMilli() && ts < end.UnixMilli() {
+				line.Values = append(line.Values, series.Values[i])
+				line.Timestamps = append(line.Timestamps, ts)
+			}
+		}
+		if len(line.Timestamps) == 0 {
+			continue
+		}
+		if err := encoder.Encode(line); err != nil {
+			return
+		}
+	}
+}
+
+func (m *selfTestVM) serveImport(w http.ResponseWriter, r *http.Request) {
+	decoder := json.NewDecoder(r.Body)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for {
+		var line selfTestSeries
+		if err := decoder.Decode(&line); err == io.EOF {
+			break
+		} else if err != nil {
+			http.Error(w, "invalid import line: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		key := selfTestSeriesKey(line.Metric)
+		series := m.imported[key]
+		if series == nil {
+			series = &selfTestSeries{Metric: line.Metric}
+			m.imported[key] = series
+		}
+		series.Values = append(series.Values, line.Values...)
+		series.Timestamps = append(series.Timestamps, line.Timestamps...)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// runSelfTest exports a synthetic dataset from an in-process VM mock, imports the archive back
+// into it and checks that every sample survived the round trip. Step results are written to out,
+// the export's progress lines to progress.
+func runSelfTest(ctx context.Context, out, progress io.Writer) error {
+	workDir, err := os.MkdirTemp("", "vmgather-selftest-")
+	if err != nil {
+		return fmt.Errorf("failed to create work directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(workDir) }()
+
+	end := time.Now().UTC().Truncate(time.Minute)
+	tr := domain.TimeRange{Start: end.Add(-selfTestDuration), End: end}
+	vm := &selfTestVM{dataset: selfTestDataset(tr), imported: make(map[string]*selfTestSeries)}
+	srv := httptest.NewServer(vm)
+	defer srv.Close()
+	fmt.Fprintf(out, "[OK] VictoriaMetrics mock listening on %s (%d synthetic series)\n", srv.URL, len(vm.dataset))
+
+	cfg := domain.ExportConfig{
+		Connection: domain.VMConnection{URL: srv.URL, Auth: domain.AuthConfig{Type: domain.AuthTypeNone}},
+		TimeRange:  tr,
+		Jobs:       []string{"selftest"},
+		StagingDir: workDir,
+	}
+	services.ApplyExportDefaults(&cfg)
+	result, err := services.NewExportServiceWithProgress(workDir, version, progress).ExecuteExport(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("export failed: %w", err)
+	}
+	fmt.Fprintf(out, "[OK] Exported %d series records into %s\n", result.MetricsExported, result.ArchiveName)
+
+	if err := verifyArchiveChecksum(result.ArchivePath, result.SHA256); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "[OK] Archive checksum matches (sha256 %s)\n", result.SHA256)
+
+	if err := importArchive(ctx, srv.URL, result.ArchivePath); err != nil {
+		return fmt.Errorf("import failed: %w", err)
+	}
+	fmt.Fprintf(out, "[OK] Imported the archive back into the mock through VMImporter\n")
+
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	if err := compareSelfTestSeries(vm.dataset, vm.imported); err != nil {
+		return fmt.Errorf("round trip mismatch: %w", err)
+	}
+	fmt.Fprintf(out, "[OK] All %d series and their samples survived the round trip\n", len(vm.dataset))
+	return nil
+}
+
+// selfTestDataset builds a gauge and a counter for two instances with one sample per selfTestStep
+func selfTestDataset(tr domain.TimeRange) []selfTestSeries {
+	var dataset []selfTestSeries
+	for _, instance := range []string{"10.0.0.1:8482", "10.0.0.2:8482"} {
+		gauge := selfTestSeries{Metric: map[string]string{"__name__": "vm_selftest_gauge", "job": "selftest", "instance": instance}}
+		counter := selfTestSeries{Metric: map[string]string{"__name__": "vm_selftest_requests_total", "job": "selftest", "instance": instance}}
+		i := 0
+		for ts := tr.Start; ts.Before(tr.End); ts = ts.Add(selfTestStep) {
+			gauge.Values = append(gauge.Values, float64(i%7)+0.5)
+			gauge.Timestamps = append(gauge.Timestamps, ts.UnixMilli())
+			counter.Values = append(counter.Values, float64(i*3))
+			counter.Timestamps = append(counter.Timestamps, ts.UnixMilli())
+			i++
+		}
+		dataset = append(dataset, gauge, counter)
+	}
+	return dataset
+}
+
+func verifyArchiveChecksum(path, want string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return fmt.Errorf("failed to read archive: %w", err)
+	}
+	if got := hex.EncodeToString(hash.Sum(nil)); got != want {
+		return fmt.Errorf("archive checksum mismatch: got %s, export reported %s", got, want)
+	}
+	return nil
+}
+
+// importArchive uploads the archive to an in-process VMImporter that imports into baseURL and
+// waits for the import job, so the bundle is parsed and pushed exactly as a real import does
+func importArchive(ctx context.Context, baseURL, archivePath string) error {
+	importerSrv := httptest.NewServer(importer.NewServerWithProfilesPath(version, "").Router())
+	defer importerSrv.Close()
+
+	body, contentType, err := selfTestUploadBody(baseURL, archivePath)
+	if err != nil {
+		return err
+	}
+	var started struct {
+		JobID string `json:"job_id"`
+	}
+	if err := selfTestRequest(ctx, http.MethodPost, importerSrv.URL+"/api/upload", contentType, body, &started); err != nil {
+		return err
+	}
+
+	statusURL := importerSrv.URL + "/api/import/status?id=" + url.QueryEscape(started.JobID)
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		var job struct {
+			State string `json:"state"`
+			Error string `json:"error"`
+		}
+		if err := selfTestRequest(ctx, http.MethodGet, statusURL, "", nil, &job); err != nil {
+			return err
+		}
+		switch job.State {
+		case "completed":
+			return nil
+		case "failed":
+			return fmt.Errorf("import job %s failed: %s", started.JobID, job.Error)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// selfTestUploadBody builds the multipart /api/upload request carrying the archive and a config
+// that points the importer at baseURL
+func selfTestUploadBody(baseURL, archivePath string) (*bytes.Buffer, string, error) {
+	archiveFile, err := os.Open(archivePath)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer func() { _ = archiveFile.Close() }()
+
+	cfg, err := json.Marshal(map[string]string{"endpoint": baseURL, "auth_type": "none"})
+	if err != nil {
+		return nil, "", err
+	}
+	body := &bytes.Buffer{}
+	form := multipart.NewWriter(body)
+	if err := form.WriteField("config", string(cfg)); err != nil {
+		return nil, "", err
+	}
+	part, err := form.CreateFormFile("bundle", filepath.Base(archivePath))
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := io.Copy(part, archiveFile); err != nil {
+		return nil, "", fmt.Errorf("failed to read archive: %w", err)
+	}
+	if err := form.Close(); err != nil {
+		return nil, "", err
+	}
+	return body, form.FormDataContentType(), nil
+}
+
+// selfTestRequest sends one request to the importer and decodes its JSON response into dst
+func selfTestRequest(ctx context.Context, method, target, contentType string, body io.Reader, dst any) error {
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s %s returned %s: %s", method, target, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return json.NewDecoder(resp.Body).Decode(dst)
+}
+
+// compareSelfTestSeries checks that imported holds exactly the dataset's series and samples.
+// Batches may deliver a series in several chunks, so samples are compared in timestamp order.
+func compareSelfTestSeries(dataset []selfTestSeries, imported map[string]*selfTestSeries) error {
+	if len(imported) != len(dataset) {
+		return fmt.Errorf("expected %d series, imported %d", len(dataset), len(imported))
+	}
+	for _, want := range dataset {
+		key := selfTestSeriesKey(want.Metric)
+		got := imported[key]
+		if got == nil {
+			return fmt.Errorf("series %s is missing", key)
+		}
+		if len(got.Timestamps) != len(want.Timestamps) || len(got.Values) != len(want.Values) {
+			return fmt.Errorf("series %s: expected %d samples, got %d", key, len(want.Timestamps), len(got.Timestamps))
+		}
+		order := make([]int, len(got.Timestamps))
+		for i := range order {
+			order[i] = i
+		}
+		sort.Slice(order, func(i, j int) bool { return got.Timestamps[order[i]] < got.Timestamps[order[j]] })
+		for i, idx := range order {
+			if got.Timestamps[idx] != want.Timestamps[i] || got.Values[idx] != want.Values[i] {
+				return fmt.Errorf("series %s: sample %d is %v@%d, expected %v@%d",
+					key, i, got.Values[idx], got.Timestamps[idx], want.Values[i], want.Timestamps[i])
+			}
+		}
+	}
+	return nil
+}
+
+func selfTestSeriesKey(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s=%q", name, labels[name]))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+)
+
+func TestRunSelfTest_RoundTripPasses(t *testing.T) {
+	var out bytes.Buffer
+	if err := runSelfTest(context.Background(), &out, io.Discard); err != nil {
+		t.Fatalf("selftest failed: %v\n%s", err, out.String())
+	}
+	if !strings.Contains(out.String(), "survived the round trip") {
+		t.Fatalf("expected round trip confirmation, got:\n%s", out.String())
+	}
+}
+
+func TestCompareSelfTestSeries_DetectsMismatch(t *testing.T) {
+	end := time.Date(2026, 1, 23, 12, 0, 0, 0, time.UTC)
+	dataset := selfTestDataset(domain.TimeRange{Start: end.Add(-time.Minute), End: end})
+
+	imported := make(map[string]*selfTestSeries)
+	for _, series := range dataset {
+		copied := series
+		copied.Values = append([]float64(nil), series.Values...)
+		imported[selfTestSeriesKey(series.Metric)] = &copied
+	}
+	if err := compareSelfTestSeries(dataset, imported); err != nil {
+		t.Fatalf("identical data must match: %v", err)
+	}
+
+	imported[selfTestSeriesKey(dataset[0].Metric)].Values[1]++
+	if err := compareSelfTestSeries(dataset, imported); err == nil || !strings.Contains(err.Error(), "sample 1") {
+		t.Fatalf("expected changed sample to be reported, got %v", err)
+	}
+	delete(imported, selfTestSeriesKey(dataset[1].Metric))
+	if err := compareSelfTestSeries(dataset, imported); err == nil {
+		t.Fatal("expected missing series to be reported")
+	}
+}
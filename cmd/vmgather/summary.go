@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+)
+
+// exportSummary is the compact result printed to stdout after a -oneshot export
+type exportSummary struct {
+	ExportID        string  `json:"export_id"`
+	Archive         string  `json:"archive"`
+	SizeBytes       int64   `json:"size_bytes"`
+	Series          int     `json:"series"`
+	Points          int64   `json:"points"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	SHA256          string  `json:"sha256"`
+	Obfuscated      bool    `json:"obfuscated"`
+}
+
+func newExportSummary(result *domain.ExportResult, elapsed time.Duration) exportSummary {
+	return exportSummary{
+		ExportID:        result.ExportID,
+		Archive:         result.ArchivePath,
+		SizeBytes:       result.ArchiveSizeBytes,
+		Series:          result.MetricsExported,
+		Points:          result.PointsExported,
+		DurationSeconds: elapsed.Round(time.Millisecond).Seconds(),
+		SHA256:          result.SHA256,
+		Obfuscated:      result.ObfuscationApplied,
+	}
+}
+
+// writeExportSummary prints the summary as one JSON line, or as one greppable key=value line
+// prefixed with "[SUMMARY]"; values containing spaces or quotes are quoted
+func writeExportSummary(out io.Writer, summary exportSummary, asJSON bool) error {
+	if asJSON {
+		return json.NewEncoder(out).Encode(summary)
+	}
+	_, err := fmt.Fprintf(out, "[SUMMARY] export_id=%s archive=%s size_bytes=%d series=%d points=%d duration=%s sha256=%s obfuscated=%t\n",
+		summaryValue(summary.ExportID), summaryValue(summary.Archive), summary.SizeBytes, summary.Series, summary.Points,
+		time.Duration(summary.DurationSeconds*float64(time.Second)), summary.SHA256, summary.Obfuscated)
+	return err
+}
+
+func summaryValue(value string) string {
+	if value == "" || strings.ContainsAny(value, " \t\"=") {
+		return strconv.Quote(value)
+	}
+	return value
+}
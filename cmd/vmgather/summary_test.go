@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/application/services"
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+)
+
+func TestWriteExportSummary(t *testing.T) {
+	vmServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"metric":{"__name__":"up","job":"vmstorage"},"values":[1,1],"timestamps":[1769169600000,1769169630000]}` + "\n" +
+			`{"metric":{"__name__":"up","job":"vmselect"},"values":[1],"timestamps":[1769169600000]}` + "\n"))
+	}))
+	defer vmServer.Close()
+
+	start := time.Date(2026, 1, 23, 12, 0, 0, 0, time.UTC)
+	cfg := domain.ExportConfig{
+		ExportID:   "TICKET-42",
+		Connection: domain.VMConnection{URL: vmServer.URL, Auth: domain.AuthConfig{Type: domain.AuthTypeNone}},
+		TimeRange:  domain.TimeRange{Start: start, End: start.Add(5 * time.Minute)},
+		StagingDir: t.TempDir(),
+	}
+	services.ApplyExportDefaults(&cfg)
+	result, err := services.NewExportService(t.TempDir(), "test").ExecuteExport(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	summary := newExportSummary(result, 1500*time.Millisecond)
+	if summary.Points == 0 || summary.Points%3 != 0 || summary.Series == 0 {
+		t.Fatalf("expected series and a multiple of 3 points per batch, got %+v", summary)
+	}
+
+	var text bytes.Buffer
+	if err := writeExportSummary(&text, summary, false); err != nil {
+		t.Fatalf("text summary failed: %v", err)
+	}
+	line := text.String()
+	if strings.Count(line, "\n") != 1 || !strings.HasPrefix(line, "[SUMMARY] export_id=TICKET-42 archive="+result.ArchivePath+" ") {
+		t.Fatalf("expected a single [SUMMARY] line, got %q", line)
+	}
+	for _, want := range []string{"size_bytes=", "series=", "points=", "duration=1.5s", "sha256=" + result.SHA256, "obfuscated=false"} {
+		if !strings.Contains(line, want) {
+			t.Fatalf("summary %q lacks %q", line, want)
+		}
+	}
+
+	var machine bytes.Buffer
+	if err := writeExportSummary(&machine, summary, true); err != nil {
+		t.Fatalf("JSON summary failed: %v", err)
+	}
+	var decoded exportSummary
+	if err := json.Unmarshal(machine.Bytes(), &decoded); err != nil {
+		t.Fatalf("JSON summary is not valid JSON: %v\n%s", err, machine.String())
+	}
+	if decoded != summary || strings.Count(machine.String(), "\n") != 1 {
+		t.Fatalf("expected one JSON line matching %+v, got %s", summary, machine.String())
+	}
+
+	if got := summaryValue("/tmp/my exports/a.zip"); got != `"/tmp/my exports/a.zip"` {
+		t.Fatalf("expected paths with spaces to be quoted, got %s", got)
+	}
+}
@@ -0,0 +1,7 @@
+package main
+
+// defaultAddr listens on loopback only; other hosts can reach the UI only with -allow-all-interfaces
+const defaultAddr = "localhost:8081"
+
+// exposedEndpoints names what a server bound to all interfaces makes reachable from the network
+const exposedEndpoints = "bundle upload and import endpoints"
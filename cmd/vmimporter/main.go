@@ -11,9 +11,11 @@ import (
 	"os/exec"
 	"os/signal"
 	"runtime"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/VictoriaMetrics/vmgather/internal/bindaddr"
 	importer "github.com/VictoriaMetrics/vmgather/internal/importer/server"
 )
 
@@ -21,10 +23,26 @@ import (
 var version = "dev"
 
 func main() {
-	addr := flag.String("addr", "0.0.0.0:8081", "HTTP server address")
+	addr := flag.String("addr", defaultAddr, "HTTP server address")
+	allowAllInterfaces := flag.Bool("allow-all-interfaces", false, "Allow -addr to listen on all network interfaces (e.g. 0.0.0.0:8081), exposing the importer to the network")
 	noBrowser := flag.Bool("no-browser", false, "Do not open browser on start")
+	dialTimeout := flag.Duration("dial-timeout", 30*time.Second, "TCP connect timeout for requests to VictoriaMetrics")
+	tcpKeepAlive := flag.Duration("tcp-keepalive", 30*time.Second, "TCP keepalive period for connections to VictoriaMetrics (negative disables)")
+	verifyTimeout := flag.Duration("verify-timeout", time.Minute, "Maximum time for post-import verification before it is skipped")
+	maxUploadMB := flag.Int64("max-upload-mb", 512, "Maximum size of an uploaded bundle in MiB")
+	maxMultiUploadMB := flag.Int64("max-multi-upload-mb", 4096, "Maximum combined size of the bundles of one /api/upload-multi request in MiB")
+	maxLineMB := flag.Int("max-line-mb", 16, "Maximum size of a single JSONL line (one series) in MiB")
+	importURLHosts := flag.String("import-url-allow-hosts", "", "Comma-separated hosts (host or host:port) /api/import-from-url may fetch bundles from; empty disables the endpoint")
 	flag.Parse()
 
+	allInterfaces, err := bindaddr.Check(*addr, *allowAllInterfaces)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if allInterfaces {
+		log.Printf("[WARN] Listening on all network interfaces: anyone who can reach %s can use the %s", *addr, exposedEndpoints)
+	}
+
 	finalAddr, err := ensureAvailablePort(*addr)
 	if err != nil {
 		log.Fatalf("Failed to find available port: %v", err)
@@ -34,6 +52,14 @@ func main() {
 	}
 
 	srv := importer.NewServer(version)
+	srv.SetDialSettings(*dialTimeout, *tcpKeepAlive)
+	srv.SetVerifyTimeout(*verifyTimeout)
+	srv.SetMaxUploadSize(*maxUploadMB << 20)
+	srv.SetMaxMultiUploadSize(*maxMultiUploadMB << 20)
+	srv.SetMaxLineSize(*maxLineMB << 20)
+	if *importURLHosts != "" {
+		srv.SetImportURLAllowlist(strings.Split(*importURLHosts, ","))
+	}
 	httpServer := &http.Server{
 		Addr:              finalAddr,
 		Handler:           srv.Router(),
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/audit"
+)
+
+// auditedExportService records every completed export in an audit log
+type auditedExportService struct {
+	inner  ExportService
+	logger *audit.Logger
+}
+
+// NewAuditedExportService wraps service so each successful export appends an audit record.
+// Audit write failures are logged but do not fail the export, which has already produced its archive.
+func NewAuditedExportService(service ExportService, logger *audit.Logger) ExportService {
+	if logger == nil {
+		return service
+	}
+	return &auditedExportService{inner: service, logger: logger}
+}
+
+// ExecuteExport runs the wrapped export and records its result
+func (s *auditedExportService) ExecuteExport(ctx context.Context, config domain.ExportConfig) (*domain.ExportResult, error) {
+	result, err := s.inner.ExecuteExport(ctx, config)
+	if err != nil {
+		return result, err
+	}
+	if err := s.logger.Append(buildAuditRecord(config, result, time.Now())); err != nil {
+		log.Printf("[WARN] Failed to append audit record for export %s: %v", result.ExportID, err)
+	}
+	return result, nil
+}
+
+// buildAuditRecord describes an export without any credentials
+func buildAuditRecord(config domain.ExportConfig, result *domain.ExportResult, now time.Time) audit.Record {
+	selector, _ := (&exportServiceImpl{}).buildExportQuery(config)
+	return audit.Record{
+		Timestamp:      now.UTC(),
+		ExportID:       result.ExportID,
+		ConnectionHost: connectionHost(config.Connection),
+		Tenant:         config.Connection.TenantId,
+		Selectors:      []string{selector},
+		TimeRangeStart: result.TimeRange.Start.UTC(),
+		TimeRangeEnd:   result.TimeRange.End.UTC(),
+		Obfuscation: audit.Obfuscation{
+			Enabled:      config.Obfuscation.Enabled,
+			Instance:     config.Obfuscation.Enabled && config.Obfuscation.ObfuscateInstance,
+			Job:          config.Obfuscation.Enabled && config.Obfuscation.ObfuscateJob,
+			CustomLabels: config.Obfuscation.CustomLabels,
+			DropLabels:   config.Obfuscation.DropLabels,
+		},
+		MetricsExported:  result.MetricsExported,
+		ArchiveName:      result.ArchiveName,
+		ArchiveSizeBytes: result.ArchiveSizeBytes,
+		SHA256:           result.SHA256,
+		SourceSHA256:     result.SourceSHA256,
+		Partial:          result.Partial != nil,
+	}
+}
+
+// connectionHost returns only the host[:port] of the connection URL, never userinfo, path or query
+func connectionHost(conn domain.VMConnection) string {
+	raw := conn.URL
+	if raw == "" {
+		raw = conn.FullApiUrl
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Host == "" {
+		return ""
+	}
+	return parsed.Host
+}
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/archive"
+)
+
+// seriesSet holds series keys (see seriesKey)
+type seriesSet map[string]struct{}
+
+func (s seriesSet) contains(labels map[string]string) bool {
+	if s == nil {
+		return false
+	}
+	_, exists := s[seriesKey(labels)]
+	return exists
+}
+
+// loadBaselineSeries reads the series set of a prior archive for diff exports.
+// Returns a nil set when no baseline is configured.
+func loadBaselineSeries(path string) (seriesSet, *domain.BaselineReference, error) {
+	if path == "" {
+		return nil, nil, nil
+	}
+	baseline, err := archive.ReadArchiveSeries(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read baseline archive: %w", err)
+	}
+	// Obfuscated values are not stable between runs, so they cannot be matched against live series.
+	if baseline.Obfuscated {
+		return nil, nil, fmt.Errorf("baseline archive %s is obfuscated; diff export requires a non-obfuscated baseline", baseline.ArchiveName)
+	}
+
+	set := make(seriesSet, len(baseline.Series))
+	for _, labels := range baseline.Series {
+		set[seriesKey(labels)] = struct{}{}
+	}
+	return set, &domain.BaselineReference{
+		ArchiveName: baseline.ArchiveName,
+		ExportID:    baseline.ExportID,
+		SeriesCount: len(set),
+	}, nil
+}
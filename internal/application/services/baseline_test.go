@@ -0,0 +1,100 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/archive"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+func TestExecuteExport_BaselineArchiveExportsOnlyNewSeries(t *testing.T) {
+	oldSeries := map[string]string{"__name__": "vm_rows", "job": "vmstorage", "instance": "host-1:8482"}
+	newSeries := map[string]string{"__name__": "vm_rows", "job": "vmstorage", "instance": "host-2:8482"}
+	line := func(labels map[string]string) string {
+		data, _ := json.Marshal(vm.ExportedMetric{Metric: labels, Values: vm.SampleValues{float64(1)}, Timestamps: []int64{1000}})
+		return string(data)
+	}
+
+	srv := newIPv4Server(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/export" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+			return
+		}
+		_, _ = fmt.Fprintln(w, line(oldSeries))
+		_, _ = fmt.Fprintln(w, line(newSeries))
+	}))
+	defer srv.Close()
+
+	writer := archive.NewWriter(t.TempDir())
+	baselinePath, _, err := writer.CreateArchive("baseline", strings.NewReader(line(oldSeries)+"\n"), archive.ArchiveMetadata{ExportID: "baseline"})
+	if err != nil {
+		t.Fatalf("failed to create baseline archive: %v", err)
+	}
+
+	service := &exportServiceImpl{
+		clientFactory:   vm.NewClient,
+		archiveWriter:   writer,
+		vmGatherVersion: "test",
+	}
+	result, err := service.ExecuteExport(context.Background(), domain.ExportConfig{
+		Connection:      domain.VMConnection{URL: srv.URL},
+		TimeRange:       domain.TimeRange{Start: time.Now().Add(-time.Minute), End: time.Now()},
+		StagingDir:      t.TempDir(),
+		BaselineArchive: baselinePath,
+	})
+	if err != nil {
+		t.Fatalf("ExecuteExport failed: %v", err)
+	}
+	if result.MetricsExported != 1 {
+		t.Fatalf("expected 1 exported series, got %d", result.MetricsExported)
+	}
+
+	zr, err := zip.OpenReader(result.ArchivePath)
+	if err != nil {
+		t.Fatalf("failed to open archive: %v", err)
+	}
+	defer zr.Close()
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("failed to open %s: %v", f.Name, err)
+		}
+		data, _ := io.ReadAll(rc)
+		_ = rc.Close()
+		files[f.Name] = string(data)
+	}
+
+	if got := strings.TrimSpace(files["metrics.jsonl"]); got != line(newSeries) {
+		t.Fatalf("expected only the new series, got:\n%s", got)
+	}
+	var meta struct {
+		Baseline *domain.BaselineReference `json:"baseline"`
+	}
+	if err := json.Unmarshal([]byte(files["metadata.json"]), &meta); err != nil {
+		t.Fatalf("invalid metadata.json: %v", err)
+	}
+	if meta.Baseline == nil || meta.Baseline.ExportID != "baseline" || meta.Baseline.SeriesCount != 1 {
+		t.Fatalf("unexpected baseline reference: %+v", meta.Baseline)
+	}
+}
+
+func TestLoadBaselineSeries_RejectsObfuscatedArchive(t *testing.T) {
+	writer := archive.NewWriter(t.TempDir())
+	path, _, err := writer.CreateArchive("obf", strings.NewReader(`{"metric":{"__name__":"up"},"values":[1],"timestamps":[1]}`+"\n"), archive.ArchiveMetadata{ExportID: "obf", Obfuscated: true})
+	if err != nil {
+		t.Fatalf("failed to create archive: %v", err)
+	}
+	if _, _, err := loadBaselineSeries(path); err == nil || !strings.Contains(err.Error(), "obfuscated") {
+		t.Fatalf("expected obfuscated baseline error, got %v", err)
+	}
+}
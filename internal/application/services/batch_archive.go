@@ -0,0 +1,122 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/archive"
+)
+
+// batchArchiveTimeFormat keeps window bounds in archive names free of characters Windows forbids
+const batchArchiveTimeFormat = "20060102T150405Z"
+
+// batchArchiveID names the archive of one archive_per_batch window, e.g. <id>_20260101T000000Z-20260101T010000Z
+func batchArchiveID(exportID string, window domain.TimeRange) string {
+	return fmt.Sprintf("%s_%s-%s", exportID, window.Start.UTC().Format(batchArchiveTimeFormat), window.End.UTC().Format(batchArchiveTimeFormat))
+}
+
+// sealArchive writes the staging file into an archive and returns its path, checksum and size
+func (s *exportServiceImpl) sealArchive(config domain.ExportConfig, metadata archive.ArchiveMetadata) (string, string, int64, error) {
+	s.printf("Creating archive...\n")
+	if config.SeriesStats {
+		stats, err := measureArchiveStats(config, metadata)
+		if err != nil {
+			return "", "", 0, err
+		}
+		metadata.SeriesStatsJSON = stats
+	}
+	processedReader, err := openArchiveReader(config, metadata)
+	if err != nil {
+		return "", "", 0, fmt.Errorf("failed to open staging file for archive: %w", err)
+	}
+	defer func() {
+		_ = processedReader.Close()
+	}()
+
+	writer, err := s.archiveWriterFor(config)
+	if err != nil {
+		return "", "", 0, err
+	}
+	archiveStartTime := time.Now()
+	archivePath, sha256sum, err := writer.CreateArchive(metadata.ExportID, processedReader, metadata)
+	if err != nil {
+		s.printf("[ERROR] Archive creation failed: %v\n", err)
+		return "", "", 0, fmt.Errorf("archive creation failed: %w", err)
+	}
+	s.printf("[OK] Archive created in %v\n", time.Since(archiveStartTime))
+
+	archiveSize, err := writer.GetArchiveSize(archivePath)
+	if err != nil {
+		s.printf("[ERROR] Failed to get archive size: %v\n", err)
+		return "", "", 0, fmt.Errorf("failed to get archive size: %w", err)
+	}
+	s.printf("Archive size: %.2f MB\n", float64(archiveSize)/(1024*1024))
+	s.printf("SHA256: %s\n", sha256sum)
+	return archivePath, sha256sum, archiveSize, nil
+}
+
+// archiveWriterFor returns the writer honoring config's archive_collision; the service's writer when unset
+func (s *exportServiceImpl) archiveWriterFor(config domain.ExportConfig) (*archive.Writer, error) {
+	if config.ArchiveCollision == "" {
+		return s.archiveWriter, nil
+	}
+	return archive.NewWriterWithCollisionStrategy(s.archiveWriter.OutputDir(), archive.CollisionStrategy(config.ArchiveCollision))
+}
+
+// openArchiveReader streams the staging file as it goes into metrics.jsonl
+func openArchiveReader(config domain.ExportConfig, metadata archive.ArchiveMetadata) (io.ReadCloser, error) {
+	reader, err := openStagingReader(config.StagingFile, config.StagingGzip)
+	if err != nil {
+		return nil, err
+	}
+	if metadata.LabelBudget != nil {
+		reader = dropBudgetLabels(reader, metadata.LabelBudget.DroppedLabels, config.MaxLineBytes)
+	}
+	return reader, nil
+}
+
+// measureArchiveStats reads the archive's series once before sealing to render series_stats.json
+func measureArchiveStats(config domain.ExportConfig, metadata archive.ArchiveMetadata) ([]byte, error) {
+	reader, err := openArchiveReader(config, metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open staging file for series_stats: %w", err)
+	}
+	defer func() { _ = reader.Close() }()
+	return measureSeriesStats(reader, config.MaxLineBytes)
+}
+
+// sealBatchArchive archives what one batch window staged and empties the staging files for the
+// next window. It returns the CSV sink to use from now on (nil without the csv format).
+func (s *exportServiceImpl) sealBatchArchive(config domain.ExportConfig, metadata archive.ArchiveMetadata, staging *stagingSink, csvOut *csvSink) (domain.BatchArchive, *csvSink, error) {
+	if csvOut != nil {
+		if err := csvOut.close(); err != nil {
+			return domain.BatchArchive{}, nil, fmt.Errorf("failed to finish CSV staging file: %w", err)
+		}
+		metadata.CSVPath = csvOut.file.Name()
+	}
+	archivePath, sha256sum, archiveSize, err := s.sealArchive(config, metadata)
+	if err != nil {
+		return domain.BatchArchive{}, nil, err
+	}
+	if err := staging.reset(); err != nil {
+		return domain.BatchArchive{}, nil, fmt.Errorf("failed to reset staging file: %w", err)
+	}
+	if csvOut != nil {
+		// Reopening truncates the CSV staging file and writes a fresh header
+		if csvOut, err = openCSVSink(metadata.CSVPath, false, config.FormatQueueSize); err != nil {
+			return domain.BatchArchive{}, nil, err
+		}
+	}
+	return domain.BatchArchive{
+		Index:            metadata.Batch.Index,
+		TimeRange:        metadata.TimeRange,
+		ArchivePath:      archivePath,
+		ArchiveName:      filepath.Base(archivePath),
+		ArchiveSizeBytes: archiveSize,
+		SHA256:           sha256sum,
+		MetricsExported:  metadata.MetricsCount,
+	}, csvOut, nil
+}
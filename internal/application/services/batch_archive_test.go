@@ -0,0 +1,134 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/archive"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+func TestExecuteExport_ArchivePerBatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Tag each series with the window it was fetched for
+		_, _ = fmt.Fprintf(w, `{"metric":{"__name__":"up","start":"%s"},"values":[1],"timestamps":[1000]}`+"\n", r.FormValue("start"))
+	}))
+	defer srv.Close()
+
+	service := &exportServiceImpl{
+		clientFactory:   vm.NewClient,
+		archiveWriter:   archive.NewWriter(t.TempDir()),
+		vmGatherVersion: "test",
+	}
+	end := time.Date(2026, 1, 1, 3, 0, 0, 0, time.UTC)
+	config := domain.ExportConfig{
+		ExportID:          "per-batch",
+		Connection:        domain.VMConnection{URL: srv.URL},
+		TimeRange:         domain.TimeRange{Start: end.Add(-3 * time.Hour), End: end},
+		Batching:          domain.BatchSettings{Enabled: true, Strategy: "custom", CustomIntervalSecs: 3600},
+		StagingDir:        t.TempDir(),
+		MetricStepSeconds: 30,
+		Formats:           []string{"jsonl", "csv"},
+		ArchivePerBatch:   true,
+	}
+	reporter := &batchArchiveReporter{}
+	ctx := WithProgressReporter(context.Background(), reporter)
+
+	result, err := service.ExecuteExport(ctx, config)
+	if err != nil {
+		t.Fatalf("ExecuteExport failed: %v", err)
+	}
+	if len(result.BatchArchives) != 3 {
+		t.Fatalf("expected 3 batch archives, got %d", len(result.BatchArchives))
+	}
+	reported := reporter.archives
+	if len(reported) != 3 {
+		t.Fatalf("expected 3 progress events, got %d", len(reported))
+	}
+	last := result.BatchArchives[2]
+	if result.ArchivePath != last.ArchivePath || result.SHA256 != last.SHA256 {
+		t.Fatalf("expected the result to describe the last archive, got %s", result.ArchivePath)
+	}
+
+	for i, batch := range result.BatchArchives {
+		wantStart := config.TimeRange.Start.Add(time.Duration(i) * time.Hour)
+		wantID := fmt.Sprintf("per-batch_%s-%s", wantStart.Format("20060102T150405Z"), wantStart.Add(time.Hour).Format("20060102T150405Z"))
+		if !strings.Contains(batch.ArchiveName, wantID) {
+			t.Fatalf("batch %d: expected archive name to contain %s, got %s", i, wantID, batch.ArchiveName)
+		}
+		if reported[i] == nil || reported[i].ArchivePath != batch.ArchivePath {
+			t.Fatalf("batch %d: progress event did not carry the archive: %+v", i, reported[i])
+		}
+		if batch.Index != i || batch.MetricsExported != 1 {
+			t.Fatalf("batch %d: unexpected archive entry %+v", i, batch)
+		}
+
+		files := readZipFiles(t, batch.ArchivePath)
+		var metadata struct {
+			ExportID  string             `json:"export_id"`
+			TimeRange domain.TimeRange   `json:"time_range"`
+			Batch     domain.BatchWindow `json:"batch"`
+		}
+		if err := json.Unmarshal([]byte(files["metadata.json"]), &metadata); err != nil {
+			t.Fatalf("batch %d: invalid metadata: %v", i, err)
+		}
+		if metadata.ExportID != wantID || !metadata.TimeRange.Start.Equal(wantStart) || !metadata.TimeRange.End.Equal(wantStart.Add(time.Hour)) {
+			t.Fatalf("batch %d: unexpected metadata %+v", i, metadata)
+		}
+		if metadata.Batch.Index != i || metadata.Batch.TotalBatches != 3 || !metadata.Batch.ExportTimeRange.End.Equal(end) {
+			t.Fatalf("batch %d: unexpected batch window %+v", i, metadata.Batch)
+		}
+		// Each archive holds only its own window's series, in both formats
+		wantLabel := fmt.Sprintf(`"start":"%s"`, wantStart.Format(time.RFC3339))
+		if strings.Count(files["metrics.jsonl"], "\n") != 1 || !strings.Contains(files["metrics.jsonl"], wantLabel) {
+			t.Fatalf("batch %d: unexpected metrics.jsonl %q", i, files["metrics.jsonl"])
+		}
+		if strings.Count(files["metrics.csv"], "\n") != 2 {
+			t.Fatalf("batch %d: expected a header and one row in metrics.csv, got %q", i, files["metrics.csv"])
+		}
+		if !strings.Contains(files["README.txt"], fmt.Sprintf("Batch: %d of 3", i+1)) {
+			t.Fatalf("batch %d: README.txt does not name the batch", i)
+		}
+		// Export-wide summaries are attached to the last archive only
+		if hasTypes := strings.Contains(files["README.txt"], "Top metrics with types"); hasTypes != (i == 2) {
+			t.Fatalf("batch %d: metric type summary present = %v, want %v", i, hasTypes, i == 2)
+		}
+	}
+}
+
+type batchArchiveReporter struct {
+	archives []*domain.BatchArchive
+}
+
+func (r *batchArchiveReporter) OnBatchComplete(progress BatchProgress) {
+	r.archives = append(r.archives, progress.Archive)
+}
+
+func readZipFiles(t *testing.T, path string) map[string]string {
+	t.Helper()
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatalf("failed to open archive: %v", err)
+	}
+	defer func() { _ = zr.Close() }()
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("failed to open %s: %v", f.Name, err)
+		}
+		data, _ := io.ReadAll(rc)
+		_ = rc.Close()
+		files[f.Name] = string(data)
+	}
+	return files
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+)
+
+// batchReadError is a failure reading a batch from VictoriaMetrics, as opposed to writing it out
+type batchReadError struct {
+	err error
+}
+
+func (e *batchReadError) Error() string { return e.err.Error() }
+
+func (e *batchReadError) Unwrap() error { return e.err }
+
+// batchFailures collects the batch windows skipped with skip_failed_batches; a nil value
+// tolerates no failure
+type batchFailures struct {
+	list []domain.FailedBatch
+}
+
+func newBatchFailures(config domain.ExportConfig) *batchFailures {
+	if !config.SkipFailedBatches {
+		return nil
+	}
+	return &batchFailures{}
+}
+
+// tolerates reports whether the export may go on after err. Fetching a batch and reading its
+// data can fail and be skipped; writing the archive, cancellation and the deadline cannot.
+func (f *batchFailures) tolerates(ctx context.Context, err error, fetching bool) bool {
+	if f == nil || ctx.Err() != nil {
+		return false
+	}
+	var readErr *batchReadError
+	return fetching || errors.As(err, &readErr) || errors.Is(err, errExportStalled)
+}
+
+// record adds a failed window and returns its entry
+func (f *batchFailures) record(index, span int, window domain.TimeRange, err error, seriesKept int) domain.FailedBatch {
+	failed := domain.FailedBatch{Index: index, Batches: span, TimeRange: window, Error: err.Error(), SeriesKept: seriesKept}
+	f.list = append(f.list, failed)
+	return failed
+}
+
+// windows is the number of base batch windows that failed
+func (f *batchFailures) windows() int {
+	if f == nil {
+		return 0
+	}
+	n := 0
+	for _, failed := range f.list {
+		n += failed.Batches
+	}
+	return n
+}
+
+// allFailed returns an error when every one of total windows failed, since the archive would be empty
+func (f *batchFailures) allFailed(total int) error {
+	if f == nil || len(f.list) == 0 || f.windows() < total {
+		return nil
+	}
+	return fmt.Errorf("all %d batch window(s) failed (skip_failed_batches); first error: %s", total, f.list[0].Error)
+}
+
+// apply marks the export partial and attaches errors.json when any window failed. An export
+// that also stopped early keeps its reason and just counts the failed windows.
+func (f *batchFailures) apply(partial *domain.PartialExport, config domain.ExportConfig, windows []domain.TimeRange, bytesWritten int64) *domain.PartialExport {
+	if f == nil || len(f.list) == 0 {
+		return partial
+	}
+	if partial == nil {
+		partial = &domain.PartialExport{
+			Reason:           "failed_batches",
+			BytesWritten:     bytesWritten,
+			CoveredRange:     coveredRange(config, windows, f.list[0].Index),
+			CompletedBatches: len(windows) - f.windows(),
+			TotalBatches:     len(windows),
+		}
+	}
+	partial.FailedBatches = f.windows()
+	return partial
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/archive"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+func TestExecuteExport_SkipFailedBatchesRecordsErrors(t *testing.T) {
+	const vmError = `{"status":"error","errorType":"422","error":"cannot find tag filter matching more than 1000000 time series"}`
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 2 {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			_, _ = io.WriteString(w, vmError)
+			return
+		}
+		_, _ = io.WriteString(w, `{"metric":{"__name__":"up","job":"api"},"values":[1],"timestamps":[1000]}`+"\n")
+	}))
+	defer srv.Close()
+
+	service := &exportServiceImpl{
+		clientFactory:   vm.NewClient,
+		archiveWriter:   archive.NewWriter(t.TempDir()),
+		vmGatherVersion: "test",
+	}
+	end := time.Now().Truncate(time.Hour)
+	config := domain.ExportConfig{
+		Connection:        domain.VMConnection{URL: srv.URL},
+		TimeRange:         domain.TimeRange{Start: end.Add(-3 * time.Hour), End: end},
+		Batching:          domain.BatchSettings{Enabled: true, Strategy: "custom", CustomIntervalSecs: 3600},
+		StagingDir:        t.TempDir(),
+		SkipFailedBatches: true,
+	}
+	result, err := service.ExecuteExport(context.Background(), config)
+	if err != nil {
+		t.Fatalf("expected the failed batch to be skipped, got %v", err)
+	}
+	if result.MetricsExported != 2 {
+		t.Fatalf("expected the 2 healthy batches to be exported, got %d series", result.MetricsExported)
+	}
+	partial := result.Partial
+	if partial == nil || partial.Reason != "failed_batches" || partial.FailedBatches != 1 || partial.CompletedBatches != 2 || partial.TotalBatches != 3 {
+		t.Fatalf("expected the export to be marked partial with 1 failed batch, got %+v", partial)
+	}
+	if len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], "1 of 3 batch window(s) failed") {
+		t.Fatalf("expected a warning about the failed window, got %v", result.Warnings)
+	}
+
+	files := readZipFiles(t, result.ArchivePath)
+	var errorsFile struct {
+		FailedBatches []domain.FailedBatch `json:"failed_batches"`
+	}
+	if err := json.Unmarshal([]byte(files["errors.json"]), &errorsFile); err != nil {
+		t.Fatalf("invalid errors.json: %v\n%s", err, files["errors.json"])
+	}
+	if len(errorsFile.FailedBatches) != 1 {
+		t.Fatalf("expected one failed window in errors.json, got %+v", errorsFile.FailedBatches)
+	}
+	failed := errorsFile.FailedBatches[0]
+	if failed.Index != 1 || !failed.TimeRange.Start.Equal(end.Add(-2*time.Hour)) || !failed.TimeRange.End.Equal(end.Add(-time.Hour)) {
+		t.Fatalf("expected the second window to be recorded, got %+v", failed)
+	}
+	if !strings.Contains(failed.Error, "cannot find tag filter matching more than 1000000 time series") {
+		t.Fatalf("expected the VictoriaMetrics error response to be recorded, got %q", failed.Error)
+	}
+	if !strings.Contains(files["metadata.json"], `"failed_batches": 1`) || !strings.Contains(files["README.txt"], "errors.json") {
+		t.Fatal("expected metadata.json and README.txt to point at the failed window")
+	}
+	readme := files["README.txt"]
+	window := end.Add(-2*time.Hour).UTC().Format(time.RFC3339) + " to " + end.Add(-time.Hour).UTC().Format(time.RFC3339)
+	if strings.Contains(readme, "stopped early") || !strings.Contains(readme, window) {
+		t.Fatalf("expected README.txt to list the skipped window without claiming an early stop, got:\n%s", readme)
+	}
+
+	requests.Store(0)
+	config.SkipFailedBatches = false
+	if _, err := service.ExecuteExport(context.Background(), config); err == nil {
+		t.Fatal("expected a failed batch to fail the export without skip_failed_batches")
+	}
+}
+
+func TestExecuteExport_SkipFailedBatchesFailsWhenEveryWindowFails(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		_, _ = io.WriteString(w, `{"status":"error","error":"too many series"}`)
+	}))
+	defer srv.Close()
+
+	service := &exportServiceImpl{
+		clientFactory:   vm.NewClient,
+		archiveWriter:   archive.NewWriter(t.TempDir()),
+		vmGatherVersion: "test",
+	}
+	end := time.Now().Truncate(time.Hour)
+	_, err := service.ExecuteExport(context.Background(), domain.ExportConfig{
+		Connection:        domain.VMConnection{URL: srv.URL},
+		TimeRange:         domain.TimeRange{Start: end.Add(-2 * time.Hour), End: end},
+		Batching:          domain.BatchSettings{Enabled: true, Strategy: "custom", CustomIntervalSecs: 3600},
+		StagingDir:        t.TempDir(),
+		SkipFailedBatches: true,
+	})
+	if err == nil || !strings.Contains(err.Error(), "all 2 batch window(s) failed") || !strings.Contains(err.Error(), "too many series") {
+		t.Fatalf("expected the export to fail when no window succeeded, got %v", err)
+	}
+}
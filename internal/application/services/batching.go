@@ -1,6 +1,9 @@
 package services
 
 import (
+	"fmt"
+	"io"
+	"strconv"
 	"time"
 
 	"github.com/VictoriaMetrics/vmgather/internal/domain"
@@ -16,6 +19,13 @@ const (
 	MaxBatchIntervalSeconds = 24 * 60 * 60
 )
 
+// Adaptive batching thresholds: a request that wrote less than adaptiveGrowBelowBytes doubles the
+// number of merged windows for the next one, a request above adaptiveShrinkAboveBytes halves it
+const (
+	adaptiveGrowBelowBytes   = 1 << 20
+	adaptiveShrinkAboveBytes = 32 << 20
+)
+
 // CalculateBatchWindows splits the requested time range into windows suitable for batched exports.
 // When batching is disabled the original range is returned as a single window.
 func CalculateBatchWindows(tr domain.TimeRange, settings domain.BatchSettings) []domain.TimeRange {
@@ -49,6 +59,66 @@ func CalculateBatchWindows(tr domain.TimeRange, settings domain.BatchSettings) [
 	return windows
 }
 
+// batchPlanner decides how many consecutive base windows the next export request covers.
+// Base windows remain the unit of progress, resume and partial exports; with the adaptive
+// strategy only the request size changes, so sparse data needs far fewer HTTP requests.
+type batchPlanner struct {
+	adaptive bool
+	span     int
+	maxSpan  int
+}
+
+func newBatchPlanner(windows []domain.TimeRange, settings domain.BatchSettings) *batchPlanner {
+	planner := &batchPlanner{span: 1, maxSpan: 1}
+	if settings.Strategy != domain.BatchStrategyAdaptive || len(windows) < 2 {
+		return planner
+	}
+	planner.adaptive = true
+	if base := windows[0].End.Sub(windows[0].Start); base > 0 {
+		planner.maxSpan = max(int(maxBatchInterval/base), 1)
+	}
+	return planner
+}
+
+// next returns the range of the request starting at base window index and how many windows it covers
+func (p *batchPlanner) next(windows []domain.TimeRange, index int) (domain.TimeRange, int) {
+	span := min(p.span, len(windows)-index)
+	return domain.TimeRange{Start: windows[index].Start, End: windows[index+span-1].End}, span
+}
+
+// observe adapts the merge factor to the number of bytes the last request produced
+func (p *batchPlanner) observe(bytes int64) {
+	if !p.adaptive {
+		return
+	}
+	switch {
+	case bytes < adaptiveGrowBelowBytes:
+		p.span = min(p.span*2, p.maxSpan)
+	case bytes > adaptiveShrinkAboveBytes:
+		p.span = max(p.span/2, 1)
+	}
+}
+
+// batchLabel formats the 1-based base window number(s) covered by a request for progress output
+func batchLabel(index, span int) string {
+	if span <= 1 {
+		return strconv.Itoa(index + 1)
+	}
+	return fmt.Sprintf("%d-%d", index+1, index+span)
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
 func selectBatchInterval(tr domain.TimeRange, settings domain.BatchSettings) time.Duration {
 	if settings.CustomIntervalSecs > 0 {
 		custom := time.Duration(settings.CustomIntervalSecs) * time.Second
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+// maxCarryInSeconds bounds how far before the range the first window may reach
+const maxCarryInSeconds = 24 * 60 * 60
+
+// validateCarryIn rejects negative or day-plus carry_in_seconds values
+func validateCarryIn(seconds int) error {
+	if seconds < 0 || seconds > maxCarryInSeconds {
+		return fmt.Errorf("carry_in_seconds must be between 0 and %d, got %d", maxCarryInSeconds, seconds)
+	}
+	return nil
+}
+
+// carryInStart is where the first batch window starts fetching: carry_in_seconds before the range
+func carryInStart(config domain.ExportConfig) time.Time {
+	return config.TimeRange.Start.Add(-time.Duration(config.CarryInSeconds) * time.Second)
+}
+
+// carryInGuard keeps at most one sample from before the range per series: the most recent one,
+// so infrequently scraped gauges still show their last value at the range start
+type carryInGuard struct {
+	seconds int
+	startMs int64
+	series  int64
+}
+
+// newCarryInGuard returns nil when carry_in_seconds is not positive, which disables carry-in
+func newCarryInGuard(config domain.ExportConfig) *carryInGuard {
+	if config.CarryInSeconds <= 0 {
+		return nil
+	}
+	return &carryInGuard{seconds: config.CarryInSeconds, startMs: config.TimeRange.Start.UnixMilli()}
+}
+
+// apply drops every pre-range sample except the latest. A latest sample that is a staleness
+// marker means the series had ended before the range, so nothing is carried in then.
+func (g *carryInGuard) apply(metric *vm.ExportedMetric) {
+	if g == nil || len(metric.Values) != len(metric.Timestamps) {
+		return
+	}
+	latest := -1
+	before := 0
+	for i, ts := range metric.Timestamps {
+		if ts >= g.startMs {
+			continue
+		}
+		before++
+		if latest < 0 || ts >= metric.Timestamps[latest] {
+			latest = i
+		}
+	}
+	if before == 0 {
+		return
+	}
+	carry := latest >= 0 && !vm.IsStaleNaN(metric.Values[latest])
+	kept := 0
+	for i, ts := range metric.Timestamps {
+		if ts < g.startMs && (!carry || i != latest) {
+			continue
+		}
+		metric.Values[kept] = metric.Values[i]
+		metric.Timestamps[kept] = ts
+		kept++
+	}
+	metric.Values = metric.Values[:kept]
+	metric.Timestamps = metric.Timestamps[:kept]
+	if carry {
+		g.series++
+	}
+}
+
+// summary records the carry-in for archive metadata; set whenever carry_in_seconds is
+func (g *carryInGuard) summary() *domain.CarryInSummary {
+	if g == nil {
+		return nil
+	}
+	return &domain.CarryInSummary{
+		CarryInSeconds: g.seconds,
+		Since:          time.UnixMilli(g.startMs).Add(-time.Duration(g.seconds) * time.Second).UTC(),
+		Series:         g.series,
+	}
+}
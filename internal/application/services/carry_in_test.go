@@ -0,0 +1,63 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+func TestExportCarriesInLatestPreRangeSample(t *testing.T) {
+	start := time.Date(2026, 1, 23, 12, 0, 0, 0, time.UTC)
+	startMs := start.UnixMilli()
+	var requestedStart string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		requestedStart = r.FormValue("start")
+		// A gauge scraped every 5 minutes has nothing inside the first minute of the range
+		from, _ := time.Parse(time.RFC3339, requestedStart)
+		if from.UnixMilli() <= startMs-120_000 {
+			_, _ = fmt.Fprintf(w, `{"metric":{"__name__":"disk_free","job":"a"},"values":[1,2],"timestamps":[%d,%d]}`+"\n", startMs-600_000, startMs-120_000)
+		}
+	}))
+	defer srv.Close()
+
+	cfg := baseOneshotConfig(srv.URL)
+	cfg.TimeRange = domain.TimeRange{Start: start, End: start.Add(time.Minute)}
+	cfg.CarryInSeconds = 300
+	out, count, err := runExportToWriter(t, cfg)
+	if err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	if count != 1 || !strings.Contains(out, fmt.Sprintf(`"values":[2],"timestamps":[%d]`, startMs-120_000)) {
+		t.Fatalf("expected only the latest pre-range sample to be carried in, got %d series: %s", count, out)
+	}
+	if want := start.Add(-5 * time.Minute); requestedStart != want.Format(time.RFC3339) {
+		t.Fatalf("expected the first window to start at %s, got start=%s", want, requestedStart)
+	}
+
+	cfg.CarryInSeconds = 0
+	if _, count, err := runExportToWriter(t, cfg); err != nil || count != 0 {
+		t.Fatalf("expected no pre-range samples without carry_in_seconds, got %d (%v)", count, err)
+	}
+}
+
+func TestCarryInGuardSkipsStaleSeries(t *testing.T) {
+	guard := newCarryInGuard(domain.ExportConfig{CarryInSeconds: 60, TimeRange: domain.TimeRange{Start: time.UnixMilli(100_000)}})
+	ended := &vm.ExportedMetric{Values: vm.SampleValues{1.0, vm.StaleNaN, 3.0}, Timestamps: []int64{50_000, 90_000, 110_000}}
+	guard.apply(ended)
+	if len(ended.Timestamps) != 1 || ended.Timestamps[0] != 110_000 {
+		t.Fatalf("a series that went stale before the range must not carry a value in, got %v", ended.Timestamps)
+	}
+	if summary := guard.summary(); summary.Series != 0 || summary.CarryInSeconds != 60 {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+	if err := validateCarryIn(-1); err == nil {
+		t.Fatal("expected negative carry_in_seconds to be rejected")
+	}
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/archive"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+func TestExecuteExport_CategoryLabelSurvivesObfuscation(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `{"metric":{"__name__":"up","job":"vmstorage","instance":"10.1.4.2:8482"},"values":[1],"timestamps":[1000]}`+"\n")
+		_, _ = io.WriteString(w, `{"metric":{"__name__":"up","job":"vmstorage","instance":"192.168.0.9:8482"},"values":[1],"timestamps":[1000]}`+"\n")
+	}))
+	defer srv.Close()
+
+	service := &exportServiceImpl{
+		clientFactory:   vm.NewClient,
+		archiveWriter:   archive.NewWriter(t.TempDir()),
+		vmGatherVersion: "test",
+	}
+	end := time.Now().Truncate(time.Minute)
+	result, err := service.ExecuteExport(context.Background(), domain.ExportConfig{
+		Connection: domain.VMConnection{URL: srv.URL},
+		TimeRange:  domain.TimeRange{Start: end.Add(-time.Hour), End: end},
+		StagingDir: t.TempDir(),
+		Obfuscation: domain.ObfuscationConfig{
+			Enabled:           true,
+			ObfuscateInstance: true,
+			CategoryLabels: []domain.CategoryRule{{
+				Source:  "instance",
+				Target:  "region",
+				Match:   []domain.CategoryMatch{{CIDR: "10.1.0.0/16", Category: "eu-west"}},
+				Default: "other",
+			}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("ExecuteExport failed: %v", err)
+	}
+	files := readZipFiles(t, result.ArchivePath)
+	data := files["metrics.jsonl"]
+	if strings.Contains(data, "10.1.4.2") || strings.Contains(data, "192.168.0.9") {
+		t.Fatalf("expected instances to be obfuscated, got %s", data)
+	}
+	if !strings.Contains(data, `"region":"eu-west"`) || !strings.Contains(data, `"region":"other"`) {
+		t.Fatalf("expected the region category to be kept, got %s", data)
+	}
+	if !strings.Contains(files["metadata.json"], `"category_labels"`) {
+		t.Fatal("expected metadata.json to list the category labels")
+	}
+
+	_, err = service.ExecuteExport(context.Background(), domain.ExportConfig{
+		Connection: domain.VMConnection{URL: srv.URL},
+		TimeRange:  domain.TimeRange{Start: end.Add(-time.Hour), End: end},
+		StagingDir: t.TempDir(),
+		Obfuscation: domain.ObfuscationConfig{
+			Enabled:           true,
+			ObfuscateInstance: true,
+			CategoryLabels:    []domain.CategoryRule{{Source: "job", Target: "instance"}},
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), "category_labels[0]") {
+		t.Fatalf("expected an obfuscated target label to be rejected, got %v", err)
+	}
+}
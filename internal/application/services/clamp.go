@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+// clampToData narrows the requested range to the first and last sample the selector has in it,
+// rounded out to whole seconds, so batches before retention or in the future are not fetched.
+// It returns nil when the range is left as is. A failing lookup only skips clamping and is
+// returned as a warning; the export itself may still succeed.
+func (s *exportServiceImpl) clampToData(ctx context.Context, client *vm.Client, config domain.ExportConfig, selector string, useQueryRange bool) (*domain.RangeAdjustment, string, error) {
+	if !config.ClampToData {
+		return nil, "", nil
+	}
+	if useQueryRange {
+		return nil, "", fmt.Errorf("clamp_to_data requires a plain series selector export")
+	}
+	requested := config.TimeRange
+	window := fmt.Sprintf("%ds", int64(math.Ceil(requested.End.Sub(requested.Start).Seconds())))
+	first, found, err := querySampleTime(ctx, client, fmt.Sprintf("min(tfirst_over_time(%s[%s]))", selector, window), requested.End)
+	if err == nil && found {
+		var last time.Time
+		last, found, err = querySampleTime(ctx, client, fmt.Sprintf("max(tlast_over_time(%s[%s]))", selector, window), requested.End)
+		if err == nil && found {
+			return clampRange(requested, first, last), "", nil
+		}
+	}
+	if ctx.Err() != nil {
+		return nil, "", ctx.Err()
+	}
+	if err != nil {
+		return nil, fmt.Sprintf("clamp_to_data skipped, the requested range is exported as is: %v", err), nil
+	}
+	return nil, "clamp_to_data found no samples for the selector; the requested range is exported as is", nil
+}
+
+// clampRange fits requested to [first, last]; nil when it already lies within the data
+func clampRange(requested domain.TimeRange, first, last time.Time) *domain.RangeAdjustment {
+	clamped := requested
+	if start := first.Truncate(time.Second); start.After(clamped.Start) {
+		clamped.Start = start
+	}
+	// Rounding the end up keeps the last sample inside the range
+	if end := last.Truncate(time.Second).Add(time.Second); end.Before(clamped.End) {
+		clamped.End = end
+	}
+	if clamped.Start.Equal(requested.Start) && clamped.End.Equal(requested.End) {
+		return nil
+	}
+	return &domain.RangeAdjustment{
+		RequestedRange: requested,
+		ClampedRange:   clamped,
+		FirstSample:    first,
+		LastSample:     last,
+	}
+}
+
+// querySampleTime runs an instant query returning a Unix timestamp in seconds
+func querySampleTime(ctx context.Context, client *vm.Client, query string, at time.Time) (time.Time, bool, error) {
+	result, err := client.Query(ctx, query, at)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	if len(result.Data.Result) == 0 || len(result.Data.Result[0].Value) < 2 {
+		return time.Time{}, false, nil
+	}
+	raw, _ := result.Data.Result[0].Value[1].(string)
+	seconds, err := vm.ParseSampleValue(raw)
+	if err != nil || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+		return time.Time{}, false, nil
+	}
+	return time.UnixMilli(int64(math.Round(seconds * 1000))).UTC(), true, nil
+}
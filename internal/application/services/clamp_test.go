@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/archive"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+func TestExecuteExport_ClampToData(t *testing.T) {
+	end := time.Now().Truncate(time.Hour)
+	first := end.Add(-2 * time.Hour).Add(17 * time.Second)
+	last := end.Add(-30 * time.Minute).Add(5500 * time.Millisecond)
+	var exports atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		if r.URL.Path == "/api/v1/query" {
+			ts := first
+			if strings.HasPrefix(r.Form.Get("query"), "max(tlast_over_time(") {
+				ts = last
+			}
+			_, _ = fmt.Fprintf(w, `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[%d,"%.3f"]}]}}`, end.Unix(), float64(ts.UnixMilli())/1000)
+			return
+		}
+		exports.Add(1)
+		_, _ = io.WriteString(w, `{"metric":{"__name__":"up","job":"api"},"values":[1],"timestamps":[1000]}`+"\n")
+	}))
+	defer srv.Close()
+
+	service := &exportServiceImpl{
+		clientFactory:   vm.NewClient,
+		archiveWriter:   archive.NewWriter(t.TempDir()),
+		vmGatherVersion: "test",
+	}
+	requested := domain.TimeRange{Start: end.Add(-6 * time.Hour), End: end.Add(time.Hour)}
+	result, err := service.ExecuteExport(context.Background(), domain.ExportConfig{
+		Connection:  domain.VMConnection{URL: srv.URL},
+		TimeRange:   requested,
+		Batching:    domain.BatchSettings{Enabled: true, Strategy: "custom", CustomIntervalSecs: 3600},
+		StagingDir:  t.TempDir(),
+		ClampToData: true,
+	})
+	if err != nil {
+		t.Fatalf("ExecuteExport failed: %v", err)
+	}
+	want := domain.TimeRange{Start: first, End: end.Add(-30 * time.Minute).Add(6 * time.Second)}
+	if !result.TimeRange.Start.Equal(want.Start) || !result.TimeRange.End.Equal(want.End) {
+		t.Fatalf("expected the range to be clamped to %s - %s, got %s - %s", want.Start, want.End, result.TimeRange.Start, result.TimeRange.End)
+	}
+	if got := exports.Load(); got != 2 {
+		t.Fatalf("expected only the 2 batches with data to be fetched instead of 7, got %d", got)
+	}
+	clamp := result.RangeClamp
+	if clamp == nil || !clamp.RequestedRange.Start.Equal(requested.Start) || !clamp.RequestedRange.End.Equal(requested.End) || !clamp.LastSample.Equal(last) {
+		t.Fatalf("expected the adjustment to be reported, got %+v", clamp)
+	}
+	files := readZipFiles(t, result.ArchivePath)
+	if !strings.Contains(files["metadata.json"], `"range_clamp"`) || !strings.Contains(files["README.txt"], "clamp_to_data") {
+		t.Fatal("expected the adjustment to be recorded in metadata.json and README.txt")
+	}
+
+	if clampRange(want, first, last) != nil {
+		t.Fatal("expected a range within the data to be left as is")
+	}
+	if _, _, err := service.clampToData(context.Background(), nil, domain.ExportConfig{ClampToData: true}, "up", true); err == nil {
+		t.Fatal("expected clamp_to_data to be rejected for query_range exports")
+	}
+}
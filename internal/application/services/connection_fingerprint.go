@@ -0,0 +1,46 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+)
+
+// connectionFingerprintPrefix versions the fingerprint input, so a later change of the
+// normalization cannot collide with fingerprints already stored in archives
+const connectionFingerprintPrefix = "vmgather-connection-v1"
+
+// connectionFingerprint returns a stable 16-hex-digit hash of the connection's host, tenant
+// and API base path, so archives exported from the same source can be linked without storing
+// the URL. Scheme, credentials, query parameters, headers and default ports are left out, and
+// host and path are normalized, so the same endpoint reached with different auth or
+// http/https matches. The hash is one-way but unsalted: whoever already knows a URL can
+// confirm it. Returns "" when the URL has no host.
+func connectionFingerprint(conn domain.VMConnection) string {
+	target := conn.FullApiUrl
+	if target == "" {
+		target = strings.TrimRight(conn.URL, "/") + conn.ApiBasePath
+	}
+	u, err := url.Parse(strings.TrimSpace(target))
+	if err != nil || u.Hostname() == "" {
+		return ""
+	}
+	host := strings.ToLower(u.Hostname())
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		host = net.JoinHostPort(host, port)
+	}
+	path := u.Path
+	for strings.Contains(path, "//") {
+		path = strings.ReplaceAll(path, "//", "/")
+	}
+	path = strings.TrimRight(path, "/")
+
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		connectionFingerprintPrefix, host, strings.TrimSpace(conn.TenantId), path,
+	}, "\x00")))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+// maxExactCounter keeps counter values and their deltas exactly representable as float64,
+// so decoding restores the original samples bit for bit
+const maxExactCounter = 1 << 52
+
+// validateCounterEncoding rejects unknown counter_encoding values
+func validateCounterEncoding(encoding domain.CounterEncoding) error {
+	switch encoding {
+	case "", domain.CounterEncodingAbsolute, domain.CounterEncodingDelta:
+		return nil
+	default:
+		return fmt.Errorf("unsupported counter_encoding %q (use %q or %q)", encoding, domain.CounterEncodingAbsolute, domain.CounterEncodingDelta)
+	}
+}
+
+// encodeCounterDeltas stores a `_total` series as its first value followed by per-sample deltas
+// and marks it with CounterEncodingLabel. Series with non-integer, negative or very large values
+// (or NaN staleness markers) are left as-is, since their deltas would not round-trip exactly.
+func encodeCounterDeltas(metric *vm.ExportedMetric) bool {
+	if !strings.HasSuffix(metric.Metric["__name__"], "_total") || len(metric.Values) < 2 {
+		return false
+	}
+	for _, v := range metric.Values {
+		if v < 0 || v > maxExactCounter || v != math.Trunc(v) {
+			return false
+		}
+	}
+
+	prev := 0.0
+	for i, v := range metric.Values {
+		metric.Values[i] = v - prev
+		prev = v
+	}
+	metric.Metric[domain.CounterEncodingLabel] = string(domain.CounterEncodingDelta)
+	return true
+}
+
+// decodeCounterDeltas reverses encodeCounterDeltas on a line marked with CounterEncodingLabel,
+// restoring the absolute values and removing the marker. Unmarked lines are left as-is.
+func decodeCounterDeltas(metric *vm.ExportedMetric) {
+	if metric.Metric[domain.CounterEncodingLabel] != string(domain.CounterEncodingDelta) {
+		return
+	}
+	delete(metric.Metric, domain.CounterEncodingLabel)
+	for i := 1; i < len(metric.Values); i++ {
+		metric.Values[i] += metric.Values[i-1]
+	}
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+func TestEncodeCounterDeltas(t *testing.T) {
+	tests := []struct {
+		name        string
+		metricName  string
+		values      vm.SampleValues
+		wantEncoded bool
+		wantValues  vm.SampleValues
+	}{
+		{
+			name:        "counter with reset",
+			metricName:  "http_requests_total",
+			values:      vm.SampleValues{100.0, 130.0, 170.0, 5.0, 25.0},
+			wantEncoded: true,
+			wantValues:  vm.SampleValues{100.0, 30.0, 40.0, -165.0, 20.0},
+		},
+		{
+			name:       "not a counter name",
+			metricName: "process_resident_memory_bytes",
+			values:     vm.SampleValues{1.0, 2.0},
+			wantValues: vm.SampleValues{1.0, 2.0},
+		},
+		{
+			name:       "fractional counter",
+			metricName: "process_cpu_seconds_total",
+			values:     vm.SampleValues{1.5, 2.25},
+			wantValues: vm.SampleValues{1.5, 2.25},
+		},
+		{
+			name:       "infinite value",
+			metricName: "errors_total",
+			values:     vm.SampleValues{1, math.Inf(1)},
+			wantValues: vm.SampleValues{1, math.Inf(1)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metric := &vm.ExportedMetric{
+				Metric: map[string]string{"__name__": tt.metricName},
+				Values: append(vm.SampleValues(nil), tt.values...),
+			}
+			if got := encodeCounterDeltas(metric); got != tt.wantEncoded {
+				t.Fatalf("encoded = %v, want %v", got, tt.wantEncoded)
+			}
+			if !reflect.DeepEqual(metric.Values, tt.wantValues) {
+				t.Fatalf("values = %v, want %v", metric.Values, tt.wantValues)
+			}
+			_, marked := metric.Metric[domain.CounterEncodingLabel]
+			if marked != tt.wantEncoded {
+				t.Fatalf("marker label present = %v, want %v", marked, tt.wantEncoded)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+)
+
+// errExportDeadline is the cause of cancelling an export that ran longer than deadline_seconds
+var errExportDeadline = errors.New("overall export deadline exceeded")
+
+// maxDeadlineSeconds caps deadline_seconds at one week
+const maxDeadlineSeconds = 7 * 24 * 60 * 60
+
+// validateDeadline rejects negative deadlines and ones beyond maxDeadlineSeconds
+func validateDeadline(seconds int) error {
+	if seconds < 0 || seconds > maxDeadlineSeconds {
+		return fmt.Errorf("deadline_seconds must be between 0 and %d, got %d", maxDeadlineSeconds, seconds)
+	}
+	return nil
+}
+
+// withExportDeadline bounds ctx by deadline_seconds. Selector resolution, every batch and every
+// request inside it inherit the context, so the limit holds for the export as a whole instead
+// of per batch. Without a deadline ctx is returned unchanged.
+func withExportDeadline(ctx context.Context, seconds int) (context.Context, context.CancelFunc) {
+	if seconds <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeoutCause(ctx, time.Duration(seconds)*time.Second, errExportDeadline)
+}
+
+// deadlineExceeded reports whether ctx was cancelled by the export deadline
+func deadlineExceeded(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), errExportDeadline)
+}
+
+// deadlineError explains an export cut short by its deadline with the batches completed before it
+func deadlineError(seconds, completed, total int) error {
+	return fmt.Errorf("%w (%ds) after %d of %d batches", errExportDeadline, seconds, completed, total)
+}
+
+// deadlinePartial describes the export sealed after its deadline; completed is the number of
+// leading batch windows that were exported completely
+func deadlinePartial(config domain.ExportConfig, windows []domain.TimeRange, completed int, bytesWritten int64) *domain.PartialExport {
+	return &domain.PartialExport{
+		Reason:           "deadline_seconds",
+		DeadlineSeconds:  config.DeadlineSeconds,
+		BytesWritten:     bytesWritten,
+		CoveredRange:     coveredRange(config, windows, completed),
+		CompletedBatches: completed,
+		TotalBatches:     len(windows),
+	}
+}
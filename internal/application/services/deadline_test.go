@@ -0,0 +1,89 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/archive"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+func TestExecuteExport_DeadlineSealsPartialArchive(t *testing.T) {
+	var requests atomic.Int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		line := `{"metric":{"__name__":"up","job":"api"},"values":[1],"timestamps":[1000]}` + "\n"
+		if requests.Add(1) == 1 {
+			_, _ = io.WriteString(w, line)
+			return
+		}
+		// Later batches send a few series, then hang past the deadline
+		_, _ = io.WriteString(w, strings.Repeat(line, 10))
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	service := &exportServiceImpl{
+		clientFactory:   vm.NewClient,
+		archiveWriter:   archive.NewWriter(t.TempDir()),
+		vmGatherVersion: "test",
+	}
+	end := time.Now().Truncate(time.Hour)
+	config := domain.ExportConfig{
+		Connection:      domain.VMConnection{URL: srv.URL},
+		TimeRange:       domain.TimeRange{Start: end.Add(-3 * time.Hour), End: end},
+		Batching:        domain.BatchSettings{Enabled: true, Strategy: "custom", CustomIntervalSecs: 3600},
+		StagingDir:      t.TempDir(),
+		DeadlineSeconds: 1,
+	}
+
+	started := time.Now()
+	result, err := service.ExecuteExport(context.Background(), config)
+	if err != nil {
+		t.Fatalf("expected a partial archive instead of an error, got %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > 10*time.Second {
+		t.Fatalf("expected the deadline to stop the export after about 1s, took %v", elapsed)
+	}
+	partial := result.Partial
+	if partial == nil || partial.Reason != "deadline_seconds" || partial.DeadlineSeconds != 1 || partial.CompletedBatches != 1 || partial.TotalBatches != 3 {
+		t.Fatalf("expected a deadline partial after 1 of 3 batches, got %+v", partial)
+	}
+	if want := end.Add(-2 * time.Hour); !partial.CoveredRange.End.Equal(want) {
+		t.Fatalf("covered range end = %s, want %s", partial.CoveredRange.End, want)
+	}
+	if len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], "overall export deadline exceeded (1s) after 1 of 3 batches") {
+		t.Fatalf("expected a clear deadline warning, got %v", result.Warnings)
+	}
+	files := readZipFiles(t, result.ArchivePath)
+	if got := strings.Count(files["metrics.jsonl"], "\n"); got != 11 {
+		t.Fatalf("expected the first batch and the series received before the deadline, got %d lines", got)
+	}
+	if !strings.Contains(files["metadata.json"], `"reason": "deadline_seconds"`) {
+		t.Fatalf("expected the deadline to be recorded in metadata.json, got %s", files["metadata.json"])
+	}
+
+	requests.Store(0)
+	var out bytes.Buffer
+	_, err = service.exportToWriter(context.Background(), config, &out)
+	if !errors.Is(err, errExportDeadline) || !strings.Contains(err.Error(), "after 1 of 3 batches") {
+		t.Fatalf("expected streaming to fail with the deadline error, got %v", err)
+	}
+	if err := validateDeadline(-1); err == nil {
+		t.Fatal("expected a negative deadline_seconds to be rejected")
+	}
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+// decimator caps the number of points kept per series across the whole export.
+// Every batch returns its own slice of a series, so the cap is split between
+// batch windows in proportion to their duration.
+type decimator struct {
+	limit       int
+	total       time.Duration
+	windowLimit int
+	kept        int64
+	dropped     int64
+}
+
+// newDecimator returns nil when limit is not positive, which disables decimation
+func newDecimator(limit int, tr domain.TimeRange) *decimator {
+	if limit <= 0 {
+		return nil
+	}
+	return &decimator{limit: limit, total: tr.End.Sub(tr.Start), windowLimit: limit}
+}
+
+// validateMaxPointsPerSeries rejects negative max_points_per_series values
+func validateMaxPointsPerSeries(limit int) error {
+	if limit < 0 {
+		return fmt.Errorf("max_points_per_series must not be negative, got %d", limit)
+	}
+	return nil
+}
+
+// startWindow sets the share of the cap available to the next request; every window keeps at least one point
+func (d *decimator) startWindow(window domain.TimeRange) {
+	if d == nil {
+		return
+	}
+	d.windowLimit = d.limit
+	if span := window.End.Sub(window.Start); d.total > 0 && span < d.total {
+		d.windowLimit = max(int(int64(d.limit)*int64(span)/int64(d.total)), 1)
+	}
+}
+
+// apply thins the series to evenly spaced points, always keeping the first and last sample
+func (d *decimator) apply(metric *vm.ExportedMetric) {
+	if d == nil {
+		return
+	}
+	n := len(metric.Timestamps)
+	if n <= d.windowLimit || len(metric.Values) != n {
+		d.kept += int64(n)
+		return
+	}
+	limit := d.windowLimit
+	values := make(vm.SampleValues, limit)
+	timestamps := make([]int64, limit)
+	for i := 0; i < limit; i++ {
+		idx := 0
+		if limit > 1 {
+			idx = i * (n - 1) / (limit - 1)
+		}
+		values[i] = metric.Values[idx]
+		timestamps[i] = metric.Timestamps[idx]
+	}
+	metric.Values = values
+	metric.Timestamps = timestamps
+	d.kept += int64(limit)
+	d.dropped += int64(n - limit)
+}
+
+// summary describes the decimation for archive metadata
+func (d *decimator) summary() *domain.DecimationSummary {
+	if d == nil {
+		return nil
+	}
+	return &domain.DecimationSummary{
+		MaxPointsPerSeries: d.limit,
+		PointsKept:         d.kept,
+		PointsDropped:      d.dropped,
+	}
+}
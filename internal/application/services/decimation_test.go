@@ -0,0 +1,132 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/archive"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+func TestDecimatorKeepsEvenlySpacedPoints(t *testing.T) {
+	now := time.Now()
+	d := newDecimator(5, domain.TimeRange{Start: now.Add(-time.Hour), End: now})
+	metric := &vm.ExportedMetric{Metric: map[string]string{"__name__": "gauge"}}
+	for i := 0; i < 101; i++ {
+		metric.Values = append(metric.Values, float64(i))
+		metric.Timestamps = append(metric.Timestamps, int64(i)*1000)
+	}
+
+	d.apply(metric)
+	wantTimestamps := []int64{0, 25000, 50000, 75000, 100000}
+	if len(metric.Timestamps) != len(wantTimestamps) || len(metric.Values) != len(wantTimestamps) {
+		t.Fatalf("expected %d points, got %d timestamps / %d values", len(wantTimestamps), len(metric.Timestamps), len(metric.Values))
+	}
+	for i, ts := range wantTimestamps {
+		if metric.Timestamps[i] != ts || metric.Values[i] != float64(ts/1000) {
+			t.Fatalf("point %d: got %v@%d, want %v@%d", i, metric.Values[i], metric.Timestamps[i], float64(ts/1000), ts)
+		}
+	}
+
+	short := &vm.ExportedMetric{Values: vm.SampleValues{1.0, 2.0}, Timestamps: []int64{0, 1000}}
+	d.apply(short)
+	if len(short.Timestamps) != 2 {
+		t.Fatalf("series below the cap must be untouched, got %d points", len(short.Timestamps))
+	}
+	if got := d.summary(); got.PointsKept != 7 || got.PointsDropped != 96 {
+		t.Fatalf("unexpected summary %+v", got)
+	}
+
+	// A 15 minute window of an hour-long export gets a quarter of the cap, but never less than one point
+	d.startWindow(domain.TimeRange{Start: now.Add(-15 * time.Minute), End: now})
+	if d.windowLimit != 1 {
+		t.Fatalf("expected window limit 1, got %d", d.windowLimit)
+	}
+	if newDecimator(0, domain.TimeRange{}) != nil {
+		t.Fatal("expected decimation to be disabled without a limit")
+	}
+}
+
+func TestExecuteExport_MaxPointsPerSeries(t *testing.T) {
+	values := make([]string, 0, 1000)
+	timestamps := make([]string, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		values = append(values, fmt.Sprint(i))
+		timestamps = append(timestamps, fmt.Sprint(i*1000))
+	}
+	body := fmt.Sprintf(`{"metric":{"__name__":"hf_gauge","job":"test"},"values":[%s],"timestamps":[%s]}`+"\n",
+		strings.Join(values, ","), strings.Join(timestamps, ","))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, body)
+	}))
+	defer srv.Close()
+
+	service := &exportServiceImpl{
+		clientFactory:   vm.NewClient,
+		archiveWriter:   archive.NewWriter(t.TempDir()),
+		vmGatherVersion: "test",
+	}
+	config := domain.ExportConfig{
+		Connection:         domain.VMConnection{URL: srv.URL},
+		TimeRange:          domain.TimeRange{Start: time.Now().Add(-5 * time.Minute), End: time.Now()},
+		StagingDir:         t.TempDir(),
+		MetricStepSeconds:  30,
+		MaxPointsPerSeries: 50,
+	}
+
+	result, err := service.ExecuteExport(context.Background(), config)
+	if err != nil {
+		t.Fatalf("ExecuteExport failed: %v", err)
+	}
+	zr, err := zip.OpenReader(result.ArchivePath)
+	if err != nil {
+		t.Fatalf("failed to open archive: %v", err)
+	}
+	defer func() { _ = zr.Close() }()
+
+	var metadata struct {
+		Decimation *domain.DecimationSummary `json:"decimation"`
+	}
+	var exported vm.ExportedMetric
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("failed to open %s: %v", f.Name, err)
+		}
+		switch f.Name {
+		case "metadata.json":
+			err = json.NewDecoder(rc).Decode(&metadata)
+		case "metrics.jsonl":
+			err = json.NewDecoder(rc).Decode(&exported)
+		}
+		_ = rc.Close()
+		if err != nil {
+			t.Fatalf("failed to decode %s: %v", f.Name, err)
+		}
+	}
+
+	if len(exported.Timestamps) != 50 || len(exported.Values) != 50 {
+		t.Fatalf("expected series decimated to 50 points, got %d", len(exported.Timestamps))
+	}
+	if exported.Timestamps[0] != 0 || exported.Timestamps[49] != 999000 {
+		t.Fatalf("expected first and last sample to be kept, got %d..%d", exported.Timestamps[0], exported.Timestamps[49])
+	}
+	want := domain.DecimationSummary{MaxPointsPerSeries: 50, PointsKept: 50, PointsDropped: 950}
+	if metadata.Decimation == nil || *metadata.Decimation != want {
+		t.Fatalf("expected decimation %+v in metadata, got %+v", want, metadata.Decimation)
+	}
+
+	config.MaxPointsPerSeries = -1
+	if _, err := service.ExecuteExport(context.Background(), config); err == nil {
+		t.Fatal("expected negative max_points_per_series to be rejected")
+	}
+}
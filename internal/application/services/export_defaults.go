@@ -1,6 +1,10 @@
 package services
 
-import "github.com/VictoriaMetrics/vmgather/internal/domain"
+import (
+	"strings"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+)
 
 // ApplyExportDefaults normalizes export configuration for CLI and server usage.
 func ApplyExportDefaults(config *domain.ExportConfig) {
@@ -9,7 +13,10 @@ func ApplyExportDefaults(config *domain.ExportConfig) {
 		settings.Enabled = true
 	}
 	if settings.Strategy == "" {
-		settings.Strategy = "auto"
+		settings.Strategy = domain.BatchStrategyAuto
+	}
+	if settings.Strategy == domain.BatchStrategyAdaptive {
+		settings.Enabled = true
 	}
 	if settings.CustomIntervalSecs < 0 {
 		settings.CustomIntervalSecs = 0
@@ -25,6 +32,19 @@ func ApplyExportDefaults(config *domain.ExportConfig) {
 	if config.MetricStepSeconds <= 0 {
 		config.MetricStepSeconds = RecommendedMetricStepSeconds(config.TimeRange)
 	}
+	config.ExportID = strings.TrimSpace(config.ExportID)
+	if config.StagingBufferSize < 0 {
+		config.StagingBufferSize = 0
+	}
+	if config.MaxBytes < 0 {
+		config.MaxBytes = 0
+	}
+	if config.SeriesLimit < 0 {
+		config.SeriesLimit = 0
+	}
+	if config.SeriesOffset < 0 {
+		config.SeriesOffset = 0
+	}
 	if !config.Obfuscation.Enabled {
 		config.Obfuscation = domain.ObfuscationConfig{DropLabels: config.Obfuscation.DropLabels}
 	}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxExportIDLength keeps archive and staging file names well below filesystem limits
+const maxExportIDLength = 128
+
+// windowsReservedNames cannot be used as a file name (with any extension) on Windows
+var windowsReservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// ValidateExportID checks a caller-supplied export ID.
+// The ID names the staging file as-is (`<id>.partial.jsonl`), so it must be a safe bare file name on every platform.
+func ValidateExportID(id string) error {
+	if id == "" {
+		return fmt.Errorf("export_id cannot be empty")
+	}
+	if len(id) > maxExportIDLength {
+		return fmt.Errorf("export_id must be at most %d characters", maxExportIDLength)
+	}
+	if strings.ContainsAny(id, `/\`) {
+		return fmt.Errorf("export_id must not contain path separators")
+	}
+	if strings.ContainsAny(id, `<>:"|?*`) {
+		return fmt.Errorf("export_id contains invalid filename characters")
+	}
+	for _, r := range id {
+		if r < 32 || r == 127 {
+			return fmt.Errorf("export_id contains control characters")
+		}
+	}
+	if id == "." || id == ".." || strings.HasSuffix(id, ".") {
+		return fmt.Errorf("export_id must not be a relative path or end with a dot")
+	}
+	base := strings.ToUpper(strings.SplitN(id, ".", 2)[0])
+	if windowsReservedNames[base] {
+		return fmt.Errorf("export_id %q is a reserved file name on Windows", id)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/archive"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+func TestValidateExportID(t *testing.T) {
+	tests := []struct {
+		id      string
+		wantErr bool
+	}{
+		{id: "TICKET-1234", wantErr: false},
+		{id: "incident_2026.01.02", wantErr: false},
+		{id: "CONSOLE", wantErr: false},
+		{id: "", wantErr: true},
+		{id: "../escape", wantErr: true},
+		{id: `dir\name`, wantErr: true},
+		{id: "..", wantErr: true},
+		{id: "trailing.", wantErr: true},
+		{id: "CON", wantErr: true},
+		{id: "nul.backup", wantErr: true},
+		{id: "com1", wantErr: true},
+		{id: "ticket:1", wantErr: true},
+		{id: "tab\tid", wantErr: true},
+	}
+	for _, tt := range tests {
+		err := ValidateExportID(tt.id)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ValidateExportID(%q) error = %v, wantErr %v", tt.id, err, tt.wantErr)
+		}
+	}
+}
+
+func TestExecuteExport_UsesSuppliedExportID(t *testing.T) {
+	exportBody := `{"metric":{"__name__":"up","job":"test"},"values":[1],"timestamps":[1]}` + "\n"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, exportBody)
+	}))
+	defer srv.Close()
+
+	outputDir := t.TempDir()
+	service := &exportServiceImpl{
+		clientFactory:   vm.NewClient,
+		archiveWriter:   archive.NewWriter(outputDir),
+		vmGatherVersion: "test",
+	}
+	config := domain.ExportConfig{
+		ExportID:   "TICKET-1234",
+		Connection: domain.VMConnection{URL: srv.URL},
+		TimeRange: domain.TimeRange{
+			Start: time.Now().Add(-time.Minute),
+			End:   time.Now(),
+		},
+		StagingDir:        t.TempDir(),
+		MetricStepSeconds: 30,
+	}
+
+	result, err := service.ExecuteExport(context.Background(), config)
+	if err != nil {
+		t.Fatalf("ExecuteExport failed: %v", err)
+	}
+	if result.ExportID != "TICKET-1234" {
+		t.Fatalf("expected supplied export ID in result, got %q", result.ExportID)
+	}
+	matched, _ := filepath.Match("vmexport_TICKET-1234_*.zip", result.ArchiveName)
+	if !matched {
+		t.Fatalf("expected archive name to carry the export ID, got %q", result.ArchiveName)
+	}
+	contents, err := archive.ReadArchiveSeries(result.ArchivePath)
+	if err != nil {
+		t.Fatalf("failed to read archive: %v", err)
+	}
+	if contents.ExportID != "TICKET-1234" {
+		t.Fatalf("expected metadata export_id TICKET-1234, got %q", contents.ExportID)
+	}
+
+	config.ExportID = "../outside"
+	if _, err := service.ExecuteExport(context.Background(), config); err == nil {
+		t.Fatal("expected export ID with path separators to be rejected")
+	}
+}
+
+func TestExecuteExport_ArchiveCollision(t *testing.T) {
+	exportBody := `{"metric":{"__name__":"up","job":"test"},"values":[1],"timestamps":[1]}` + "\n"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, exportBody)
+	}))
+	defer srv.Close()
+
+	outputDir := t.TempDir()
+	service := &exportServiceImpl{
+		clientFactory:   vm.NewClient,
+		archiveWriter:   archive.NewWriter(outputDir),
+		vmGatherVersion: "test",
+	}
+	config := domain.ExportConfig{
+		ExportID:   "nightly",
+		Connection: domain.VMConnection{URL: srv.URL},
+		TimeRange: domain.TimeRange{
+			Start: time.Now().Add(-time.Minute),
+			End:   time.Now(),
+		},
+		StagingDir:        t.TempDir(),
+		MetricStepSeconds: 30,
+	}
+
+	for _, tt := range []struct {
+		strategy string
+		wantName string
+	}{
+		{strategy: "overwrite", wantName: "vmexport_nightly.zip"},
+		{strategy: "overwrite", wantName: "vmexport_nightly.zip"},
+		{strategy: "version", wantName: "vmexport_nightly-v2.zip"},
+		{strategy: "skip", wantName: "vmexport_nightly.zip"},
+	} {
+		config.ArchiveCollision = tt.strategy
+		result, err := service.ExecuteExport(context.Background(), config)
+		if err != nil {
+			t.Fatalf("%s: ExecuteExport failed: %v", tt.strategy, err)
+		}
+		if name := filepath.Base(result.ArchivePath); name != tt.wantName {
+			t.Fatalf("%s: expected archive %s, got %s", tt.strategy, tt.wantName, name)
+		}
+	}
+
+	config.ArchiveCollision = "rename"
+	if _, err := service.ExecuteExport(context.Background(), config); err == nil {
+		t.Fatal("expected an unknown archive_collision to be rejected")
+	}
+}
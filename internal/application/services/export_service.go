@@ -5,12 +5,13 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
-	"strconv"
+	"regexp"
 	"strings"
 	"time"
 
@@ -20,7 +21,13 @@ import (
 	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
 )
 
-const defaultBatchTimeout = 2 * time.Minute
+const (
+	defaultBatchTimeout      = 2 * time.Minute
+	defaultStagingBufferSize = 4096
+)
+
+// syncStagingFile commits staging data to stable storage; replaced in tests.
+var syncStagingFile = (*os.File).Sync
 
 // ExportService interface for full export operations
 type ExportService interface {
@@ -30,38 +37,97 @@ type ExportService interface {
 
 // exportServiceImpl implements ExportService
 type exportServiceImpl struct {
-	clientFactory   func(domain.VMConnection) *vm.Client
-	archiveWriter   *archive.Writer
-	vmGatherVersion string
+	clientFactory     func(domain.VMConnection) *vm.Client
+	archiveWriter     *archive.Writer
+	vmGatherVersion   string
+	obfuscatorFactory obfuscation.Factory
+	progress          io.Writer // progress and warning lines; os.Stdout when nil
+}
+
+// progressOut returns where progress lines are written
+func (s *exportServiceImpl) progressOut() io.Writer {
+	if s.progress == nil {
+		return os.Stdout
+	}
+	return s.progress
+}
+
+// printf writes a progress line
+func (s *exportServiceImpl) printf(format string, args ...interface{}) {
+	_, _ = fmt.Fprintf(s.progressOut(), format, args...)
 }
 
 // NewExportService creates a new export service
 func NewExportService(outputDir, version string) ExportService {
+	return NewExportServiceWithObfuscator(outputDir, version, obfuscation.DefaultFactory)
+}
+
+// NewExportServiceWithProgress creates an export service that writes its progress lines to
+// progress instead of stdout, e.g. when stdout carries the export result.
+func NewExportServiceWithProgress(outputDir, version string, progress io.Writer) ExportService {
+	service := NewExportServiceWithObfuscator(outputDir, version, obfuscation.DefaultFactory).(*exportServiceImpl)
+	service.progress = progress
+	return service
+}
+
+// NewExportServiceWithObfuscator creates an export service that pseudonymizes labels with
+// obfuscators from factory (one per export) instead of the built-in implementation.
+func NewExportServiceWithObfuscator(outputDir, version string, factory obfuscation.Factory) ExportService {
 	if version == "" {
 		version = "dev"
 	}
+	if factory == nil {
+		factory = obfuscation.DefaultFactory
+	}
 	return &exportServiceImpl{
-		clientFactory:   vm.NewClient,
-		archiveWriter:   archive.NewWriter(outputDir),
-		vmGatherVersion: version,
+		clientFactory:     vm.NewClient,
+		archiveWriter:     archive.NewWriter(outputDir),
+		vmGatherVersion:   version,
+		obfuscatorFactory: factory,
+	}
+}
+
+// newObfuscator returns a fresh obfuscator for one export
+func (s *exportServiceImpl) newObfuscator() obfuscation.Obfuscator {
+	if s.obfuscatorFactory == nil {
+		return obfuscation.NewObfuscator()
 	}
+	return s.obfuscatorFactory()
 }
 
 // ExportToWriter streams exported metrics into the provided writer.
 // Intended for CLI oneshot mode; writes JSONL metrics without creating an archive.
 func ExportToWriter(ctx context.Context, config domain.ExportConfig, writer io.Writer) (int, error) {
+	// writer usually is stdout, so progress goes to stderr to keep the stream clean
 	service := &exportServiceImpl{
 		clientFactory:   vm.NewClient,
 		archiveWriter:   archive.NewWriter(os.TempDir()),
 		vmGatherVersion: "dev",
+		progress:        os.Stderr,
 	}
 	return service.exportToWriter(ctx, config, writer)
 }
 
 // ExecuteExport performs full metrics export with optional obfuscation
 func (s *exportServiceImpl) ExecuteExport(ctx context.Context, config domain.ExportConfig) (*domain.ExportResult, error) {
-	// Generate export ID
-	exportID := s.generateExportID()
+	formats, err := s.validateExportConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	categories, err := obfuscation.NewCategorizer(config.Obfuscation)
+	if err != nil {
+		return nil, err
+	}
+
+	// Use the caller's export ID for correlation, otherwise generate one
+	exportID := strings.TrimSpace(config.ExportID)
+	if exportID != "" {
+		if err := ValidateExportID(exportID); err != nil {
+			return nil, err
+		}
+	} else {
+		exportID = s.generateExportID()
+	}
 
 	// Step 1: Prepare staging file for incremental writes
 	stagingDir := config.StagingDir
@@ -71,34 +137,89 @@ func (s *exportServiceImpl) ExecuteExport(ctx context.Context, config domain.Exp
 	if err := os.MkdirAll(stagingDir, 0o755); err != nil {
 		return nil, fmt.Errorf("failed to prepare staging directory: %w", err)
 	}
-	if config.StagingFile == "" {
-		config.StagingFile = filepath.Join(stagingDir, fmt.Sprintf("%s.partial.jsonl", exportID))
+	if exportFormat(config) == domain.ExportFormatNative {
+		return s.executeNativeExport(ctx, config, exportID, stagingDir)
 	}
-	flags := os.O_CREATE | os.O_WRONLY
-	if config.ResumeFromBatch > 0 {
-		flags |= os.O_APPEND
-	} else {
-		flags |= os.O_TRUNC
+	if config.StagingFile == "" {
+		config.StagingFile = filepath.Join(stagingDir, StagingFileName(exportID, config.StagingGzip))
 	}
-	stagingHandle, err := os.OpenFile(config.StagingFile, flags, 0o640)
+	// On resume the staging file already holds earlier batches, which count against the budget.
+	stagingWriter, stagedBytes, err := openStagingSink(config.StagingFile, config.ResumeFromBatch > 0, config.StagingGzip, config.StagingBufferSize)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create staging file: %w", err)
+		return nil, err
+	}
+	defer func() { _ = stagingWriter.close() }()
+	// Extra formats are written from the same processed stream, so VictoriaMetrics is queried once
+	var csvOut *csvSink
+	if containsString(formats, domain.OutputFormatCSV) {
+		csvOut, err = openCSVSink(csvStagingPath(config.StagingFile), config.ResumeFromBatch > 0, config.FormatQueueSize)
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = csvOut.close() }()
 	}
-	defer func() { _ = stagingHandle.Close() }()
-	stagingWriter := bufio.NewWriter(stagingHandle)
-	defer func() {
-		_ = stagingWriter.Flush()
-		_ = stagingHandle.Close()
-	}()
 
 	// Step 2: Export metrics from VictoriaMetrics in batches
+	// The deadline bounds fetching only; the archive is still sealed and uploaded once it passes
+	parentCtx := ctx
+	ctx, cancelDeadline := withExportDeadline(ctx, config.DeadlineSeconds)
+	defer cancelDeadline()
+	batchWindows := CalculateBatchWindows(config.TimeRange, config.Batching)
 	client := s.clientFactory(config.Connection)
 	selector, useQueryRange := s.buildExportQuery(config)
-	batchWindows := CalculateBatchWindows(config.TimeRange, config.Batching)
+	rangeClamp, clampWarning, err := s.clampToData(ctx, client, config, selector, useQueryRange)
+	if err != nil {
+		if deadlineExceeded(ctx) {
+			return nil, deadlineError(config.DeadlineSeconds, 0, len(batchWindows))
+		}
+		return nil, err
+	}
+	if rangeClamp != nil {
+		s.printf("[INFO] clamp_to_data: range narrowed to %s - %s, where the selector has data\n",
+			rangeClamp.ClampedRange.Start.Format(time.RFC3339), rangeClamp.ClampedRange.End.Format(time.RFC3339))
+		config.TimeRange = rangeClamp.ClampedRange
+		batchWindows = CalculateBatchWindows(config.TimeRange, config.Batching)
+	}
+	if err := checkOutputFiles(config, formats, len(batchWindows)); err != nil {
+		return nil, err
+	}
+	selection, err := s.resolveExportSelectors(ctx, client, config, selector, useQueryRange)
+	if err != nil {
+		if deadlineExceeded(ctx) {
+			return nil, deadlineError(config.DeadlineSeconds, 0, len(batchWindows))
+		}
+		return nil, err
+	}
+	selectors, pagination := withUpSelector(config, selection.selectors), selection.pagination
+	// Captured before the batches run, as close as possible to the moment the export was requested
+	alerting := s.captureVMAlert(ctx, config)
+	baseline, baselineRef, err := loadBaselineSeries(config.BaselineArchive)
+	if err != nil {
+		return nil, err
+	}
+	opts := newProcessOptions(config, categories, baseline, selection.series)
+	opts.budget = newByteBudget(config.MaxBytes, stagedBytes)
+	opts.metricTypes = newMetricTypeStats()
+	opts.csv = csvOut
+	// query_range samples are spaced by the step, not by the scrape interval, so there is nothing to infer
+	if config.InferScrapeInterval && !useQueryRange {
+		opts.intervals = newScrapeIntervalStats()
+	}
+	// A resumed export does not see the batches staged before the restart
+	if config.ResumeFromBatch == 0 {
+		opts.source = newSourceDigest()
+	}
+	var pointsCount int64
+	opts.points = &pointsCount
+	seriesWithSamples := 0
+	opts.sampled = &seriesWithSamples
 	metricsCount := 0
-	var obfuscator *obfuscation.Obfuscator
+	bytesWritten := stagedBytes
+	failures := newBatchFailures(config)
+	var partial *domain.PartialExport
+	var obfuscator obfuscation.Obfuscator
 	if config.Obfuscation.Enabled {
-		obfuscator = obfuscation.NewObfuscator()
+		obfuscator = s.newObfuscator()
 	}
 
 	startIdx := config.ResumeFromBatch
@@ -106,88 +227,246 @@ func (s *exportServiceImpl) ExecuteExport(ctx context.Context, config domain.Exp
 		startIdx = 0
 	}
 
-	for batchIndex := startIdx; batchIndex < len(batchWindows); batchIndex++ {
-		window := batchWindows[batchIndex]
+	var fidelity fidelityLog
+	// describe builds archive metadata from the state of the export so far; summaries that
+	// accumulate over the whole export are added by snapshot
+	describe := func(archiveID string, count int, runs []domain.BatchFidelity) archive.ArchiveMetadata {
+		obfuscationMaps := make(map[string]map[string]string)
+		if obfuscator != nil {
+			instanceMap, jobMap := obfuscator.GetMappings()
+			obfuscationMaps["instance"] = instanceMap
+			obfuscationMaps["job"] = jobMap
+		}
+		metadata := s.buildArchiveMetadata(archiveID, config, count, obfuscationMaps)
+		metadata.Pagination = pagination
+		metadata.SeriesCap = selection.componentCap
+		metadata.Baseline = baselineRef
+		metadata.Fidelity = runs
+		metadata.Formats = formats
+		metadata.QuerySet = querySetMetadata(config.QuerySet, config.Obfuscation.Enabled)
+		metadata.AlwaysIncludeUp = config.AlwaysIncludeUp
+		metadata.RangeClamp = rangeClamp
+		metadata.CategoryLabels = categories.Targets()
+		if opts.counterDeltas {
+			metadata.CounterEncoding = domain.CounterEncodingDelta
+		}
+		if config.IncludeReproduce {
+			metadata.ReproduceScript = buildReproduceScript(exportID, config, selector, useQueryRange, s.vmGatherVersion)
+		}
+		return metadata
+	}
+	// snapshot adds what the whole export has seen so far: per-series figures and the vmalert
+	// state. With archive_per_batch only the last archive carries it, since earlier windows
+	// would repeat or overstate data that is not their own.
+	snapshot := func(metadata *archive.ArchiveMetadata) {
+		metadata.ScrapeIntervals = opts.intervals.summaries()
+		metadata.MetricTypes = opts.metricTypes.hints()
+		metadata.Decimation = opts.decimation.summary()
+		metadata.Sampling = opts.sampling.summary()
+		metadata.FutureSamples = opts.future.summary()
+		metadata.LabelValues = opts.labels.summary()
+		metadata.CarryIn = opts.carryIn.summary()
+		metadata.Normalization = opts.normalize.summary()
+		metadata.VMAlert = alerting.summary
+		metadata.AlertsJSON, metadata.RulesJSON = alerting.alerts, alerting.rules
+	}
+	var batchArchives []domain.BatchArchive
+	planner := newBatchPlanner(batchWindows, config.Batching)
+	coarsener := newStepCoarsener(config, s.progressOut())
+	for batchIndex := startIdx; batchIndex < len(batchWindows); {
+		window, span := planner.next(batchWindows, batchIndex)
+		batchConfig := coarsener.apply(config)
+		if deadlineExceeded(ctx) {
+			partial = deadlinePartial(config, batchWindows, batchIndex, bytesWritten)
+			break
+		}
 		select {
 		case <-ctx.Done():
 			return nil, ctx.Err()
 		default:
 		}
 
-		fmt.Printf("Processing batch %d/%d (%s - %s)\n",
-			batchIndex+1, len(batchWindows), window.Start.Format(time.RFC3339), window.End.Format(time.RFC3339))
+		s.printf("Processing batch %s/%d (%s - %s)\n",
+			batchLabel(batchIndex, span), len(batchWindows), window.Start.Format(time.RFC3339), window.End.Format(time.RFC3339))
 		batchStart := time.Now()
 
+		opts.decimation.startWindow(window)
 		batchCtx, cancelBatch := context.WithTimeout(ctx, defaultBatchTimeout)
-		exportReader, err := s.fetchBatch(batchCtx, client, selector, window, config.MetricStepSeconds, useQueryRange)
+		watchdog := newStallWatchdog(config.StallTimeoutSeconds, cancelBatch)
+		exportReader, source, err := s.fetchWindow(batchCtx, client, batchConfig, selectors, window, useQueryRange)
+		var failed *domain.FailedBatch
 		if err != nil {
+			watchdog.stop()
 			cancelBatch()
-			return nil, err
+			if deadlineExceeded(ctx) {
+				partial = deadlinePartial(config, batchWindows, batchIndex, bytesWritten)
+				break
+			}
+			err = watchdog.explain(err)
+			if !failures.tolerates(ctx, err, true) {
+				return nil, err
+			}
+			// The window goes through the usual steps with no data, so progress and archive_per_batch still see it
+			entry := failures.record(batchIndex, span, window, err, 0)
+			failed = &entry
+			exportReader = emptyExportReader()
+		} else {
+			fidelity.record(window, span, source, batchConfig)
 		}
+		exportReader = watchdog.watch(exportReader)
 
-		batchCount, err := s.processMetricsIntoWriter(exportReader, config.Obfuscation, obfuscator, stagingWriter)
+		written := &countingWriter{w: stagingWriter}
+		sampledBefore := seriesWithSamples
+		pointsBefore := pointsCount
+		batchCount, err := s.processMetricsIntoWriter(exportReader, config.Obfuscation, obfuscator, opts, written)
 		_ = exportReader.Close()
 		cancelBatch()
-		if err != nil {
-			fmt.Printf("[ERROR] Metrics processing failed for batch %d: %v\n", batchIndex+1, err)
-			return nil, fmt.Errorf("metrics processing failed: %w", err)
+		err = watchdog.explain(err)
+		budgetReached := errors.Is(err, errByteBudgetReached)
+		// Series written before the deadline are whole lines and are kept, like those within max_bytes
+		deadlineHit := err != nil && !budgetReached && deadlineExceeded(ctx)
+		if err != nil && !budgetReached && !deadlineHit {
+			s.printf("[ERROR] Metrics processing failed for batch %s: %v\n", batchLabel(batchIndex, span), err)
+			if !failures.tolerates(ctx, err, false) {
+				return nil, fmt.Errorf("metrics processing failed: %w", err)
+			}
+			// Whole series received before the error stay in the archive and are counted in errors.json
+			entry := failures.record(batchIndex, span, window, err, batchCount)
+			failed = &entry
+		}
+		if failed != nil {
+			s.printf("[WARN] Batch %s failed and is skipped: %s\n", batchLabel(batchIndex, span), failed.Error)
 		}
-		if err := stagingWriter.Flush(); err != nil {
+		if err := stagingWriter.endBatch(); err != nil {
 			return nil, fmt.Errorf("failed to flush staging file: %w", err)
 		}
+		if err := csvOut.flush(); err != nil {
+			return nil, fmt.Errorf("failed to flush CSV staging file: %w", err)
+		}
+		// Flush only hands data to the OS; fsync makes the batch survive a hard crash so resume can rely on it.
+		if config.StagingFsync {
+			if err := syncStagingFile(stagingWriter.file); err != nil {
+				return nil, fmt.Errorf("failed to fsync staging file: %w", err)
+			}
+		}
 
 		metricsCount += batchCount
+		bytesWritten += written.n
+		if budgetReached {
+			partial = opts.budget.partial(config, batchWindows, batchIndex)
+			s.printf("[WARN] Byte budget of %d bytes reached in batch %s; sealing partial archive\n", config.MaxBytes, batchLabel(batchIndex, span))
+		}
+		if deadlineHit {
+			partial = deadlinePartial(config, batchWindows, batchIndex, bytesWritten)
+		}
+		var sealed *domain.BatchArchive
+		if config.ArchivePerBatch {
+			var windowFidelity fidelityLog
+			if source != "" {
+				windowFidelity.record(window, span, source, batchConfig)
+			}
+			metadata := describe(batchArchiveID(exportID, window), batchCount, windowFidelity.runs)
+			metadata.TimeRange = window
+			if budgetReached || deadlineHit || batchIndex+span >= len(batchWindows) {
+				snapshot(&metadata)
+			}
+			metadata.Partial = partial
+			if failed != nil {
+				// Each window's archive documents its own failure
+				metadata.Partial = &domain.PartialExport{
+					Reason:        "failed_batches",
+					BytesWritten:  written.n,
+					CoveredRange:  domain.TimeRange{Start: window.Start, End: window.Start},
+					TotalBatches:  span,
+					FailedBatches: span,
+				}
+				metadata.FailedBatches = []domain.FailedBatch{*failed}
+			}
+			metadata.Batch = &domain.BatchWindow{Index: batchIndex, Batches: span, TotalBatches: len(batchWindows), ExportTimeRange: config.TimeRange}
+			batchArchive, nextCSV, err := s.sealBatchArchive(config, metadata, stagingWriter, csvOut)
+			if err != nil {
+				return nil, err
+			}
+			csvOut, opts.csv = nextCSV, nextCSV
+			batchArchives = append(batchArchives, batchArchive)
+			sealed = &batchArchives[len(batchArchives)-1]
+		}
+		if budgetReached || deadlineHit {
+			break
+		}
 		batchDuration := time.Since(batchStart)
-		fmt.Printf("[OK] Batch %d processed in %v (%d metrics)\n", batchIndex+1, batchDuration, batchCount)
+		if failed == nil {
+			s.printf("[OK] Batch %s processed in %v (%d metrics)\n", batchLabel(batchIndex, span), batchDuration, batchCount)
+		}
+		batchSampled := seriesWithSamples - sampledBefore
 
+		batchIndex += span
+		if failed == nil {
+			planner.observe(written.n)
+			coarsener.observe(pointsCount-pointsBefore, source, window)
+		}
 		ReportBatchProgress(ctx, BatchProgress{
-			BatchIndex:   batchIndex + 1,
-			TotalBatches: len(batchWindows),
-			TimeRange:    window,
-			Metrics:      batchCount,
-			Duration:     batchDuration,
+			BatchIndex:        batchIndex,
+			Batches:           span,
+			TotalBatches:      len(batchWindows),
+			TimeRange:         window,
+			Metrics:           batchCount,
+			Duration:          batchDuration,
+			SeriesWithSamples: batchSampled,
+			Archive:           sealed,
 		})
 	}
 
-	obfuscationMaps := make(map[string]map[string]string)
-	if obfuscator != nil {
-		instanceMap, jobMap := obfuscator.GetMappings()
-		obfuscationMaps["instance"] = instanceMap
-		obfuscationMaps["job"] = jobMap
-	}
-
 	// Step 3: Create archive
-	fmt.Printf("Creating archive...\n")
-	metadata := s.buildArchiveMetadata(exportID, config, metricsCount, obfuscationMaps)
-	processedReader, err := os.Open(config.StagingFile)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open staging file for archive: %w", err)
+	if err := failures.allFailed(len(batchWindows)); err != nil {
+		return nil, err
 	}
-	defer func() {
-		_ = processedReader.Close()
-	}()
-
-	archiveStartTime := time.Now()
-	archivePath, sha256sum, err := s.archiveWriter.CreateArchive(exportID, processedReader, metadata)
-	if err != nil {
-		fmt.Printf("[ERROR] Archive creation failed: %v\n", err)
-		return nil, fmt.Errorf("archive creation failed: %w", err)
+	partial = failures.apply(partial, config, batchWindows, bytesWritten)
+	metadata := describe(exportID, metricsCount, fidelity.runs)
+	snapshot(&metadata)
+	metadata.Partial = partial
+	if failures.windows() > 0 {
+		metadata.FailedBatches = failures.list
 	}
-	fmt.Printf("[OK] Archive created in %v\n", time.Since(archiveStartTime))
-
-	// Step 4: Get archive size
-	archiveSize, err := s.archiveWriter.GetArchiveSize(archivePath)
-	if err != nil {
-		fmt.Printf("[ERROR] Failed to get archive size: %v\n", err)
-		return nil, fmt.Errorf("failed to get archive size: %w", err)
+	if config.CardinalityBudget > 0 {
+		// Every batch has already been handed to the staging file by endBatch
+		if metadata.LabelBudget, err = measureLabelBudget(config); err != nil {
+			return nil, err
+		}
+	}
+	if csvOut != nil {
+		if err := csvOut.close(); err != nil {
+			return nil, fmt.Errorf("failed to finish CSV staging file: %w", err)
+		}
+		metadata.CSVPath = csvOut.file.Name()
+	}
+	var archivePath, sha256sum string
+	var archiveSize int64
+	if config.ArchivePerBatch {
+		// Every window is already sealed; the result points at the last archive
+		if n := len(batchArchives); n > 0 {
+			archivePath, sha256sum, archiveSize = batchArchives[n-1].ArchivePath, batchArchives[n-1].SHA256, batchArchives[n-1].ArchiveSizeBytes
+		}
+	} else {
+		archivePath, sha256sum, archiveSize, err = s.sealArchive(config, metadata)
+		if err != nil {
+			return nil, err
+		}
 	}
-	fmt.Printf("Archive size: %.2f MB\n", float64(archiveSize)/(1024*1024))
-	fmt.Printf("SHA256: %s\n", sha256sum)
 
-	if config.ResumeFromBatch == 0 {
+	keptStaging := ""
+	if config.KeepStaging {
+		keptStaging = config.StagingFile
+		s.printf("Staging file kept: %s\n", keptStaging)
+	} else if config.ResumeFromBatch == 0 {
 		if err := os.Remove(config.StagingFile); err != nil {
 			log.Printf("[WARN] Failed to remove staging file %s: %v", config.StagingFile, err)
 		}
+		if metadata.CSVPath != "" {
+			if err := os.Remove(metadata.CSVPath); err != nil {
+				log.Printf("[WARN] Failed to remove staging file %s: %v", metadata.CSVPath, err)
+			}
+		}
 	}
 
 	// Build result
@@ -197,42 +476,170 @@ func (s *exportServiceImpl) ExecuteExport(ctx context.Context, config domain.Exp
 		ArchiveName:        filepath.Base(archivePath),
 		ArchiveSizeBytes:   archiveSize,
 		MetricsExported:    metricsCount,
+		SeriesWithSamples:  seriesWithSamples,
+		PointsExported:     pointsCount,
 		TimeRange:          config.TimeRange,
 		ObfuscationApplied: config.Obfuscation.Enabled,
 		SHA256:             sha256sum,
+		Pagination:         pagination,
+		Partial:            partial,
+		RangeClamp:         rangeClamp,
+		StagingPath:        keptStaging,
+		BatchArchives:      batchArchives,
+	}
+	// Only a complete export describes the whole range
+	if partial == nil {
+		result.SourceSHA256 = opts.source.hex()
+	}
+	if clampWarning != "" {
+		s.printf("[WARN] %s\n", clampWarning)
+		result.Warnings = append(result.Warnings, clampWarning)
+	}
+	if metricsCount == 0 {
+		warning := fmt.Sprintf("selector %s matched no series in the requested time range", selector)
+		if config.QuerySet != nil {
+			warning = fmt.Sprintf("query_set %q matched no series in the requested time range", config.QuerySet.Name)
+		}
+		s.printf("[WARN] %s\n", warning)
+		result.Warnings = append(result.Warnings, warning)
+	}
+	if future := metadata.FutureSamples; future != nil {
+		warning := fmt.Sprintf("%d sample(s) in %d series were beyond now+%ds and were %s", future.Points, future.Series, future.MaxFutureSkewSeconds, futureSampleVerb(future.Policy))
+		s.printf("[WARN] %s\n", warning)
+		result.Warnings = append(result.Warnings, warning)
+	}
+	if labels := metadata.LabelValues; labels != nil {
+		warning := fmt.Sprintf("%d series had label values longer than %d bytes (%s) and were %s", labels.Series, labels.MaxLabelValueLength, strings.Join(labels.Labels, ", "), labelValueVerb(labels.Policy))
+		s.printf("[WARN] %s\n", warning)
+		result.Warnings = append(result.Warnings, warning)
 	}
+	result.Warnings = append(result.Warnings, alerting.warnings...)
+	if budget := metadata.LabelBudget; budget != nil && len(budget.DroppedLabels) > 0 {
+		warning := fmt.Sprintf("labels with more than %d distinct values were dropped (label_cardinality_budget): %s", budget.Budget, strings.Join(budget.DroppedLabels, ", "))
+		s.printf("[WARN] %s\n", warning)
+		result.Warnings = append(result.Warnings, warning)
+	}
+	if norm := metadata.Normalization; norm != nil && norm.Collisions > 0 {
+		warning := fmt.Sprintf("%d label(s) were dropped because lowercasing gave them the name of another label", norm.Collisions)
+		s.printf("[WARN] %s\n", warning)
+		result.Warnings = append(result.Warnings, warning)
+	}
+	if partial != nil && partial.Reason == "deadline_seconds" {
+		warning := fmt.Sprintf("%v; sealed a partial archive covering %s to %s", deadlineError(config.DeadlineSeconds, partial.CompletedBatches, partial.TotalBatches),
+			partial.CoveredRange.Start.Format(time.RFC3339), partial.CoveredRange.End.Format(time.RFC3339))
+		s.printf("[WARN] %s\n", warning)
+		result.Warnings = append(result.Warnings, warning)
+	}
+	if failures.windows() > 0 {
+		first := failures.list[0]
+		warning := fmt.Sprintf("%d of %d batch window(s) failed and were skipped (see errors.json); first failure %s - %s: %s", failures.windows(), len(batchWindows),
+			first.TimeRange.Start.Format(time.RFC3339), first.TimeRange.End.Format(time.RFC3339), first.Error)
+		s.printf("[WARN] %s\n", warning)
+		result.Warnings = append(result.Warnings, warning)
+	}
+	uploadArchives(parentCtx, config.Upload, result, s.progressOut())
 
 	return result, nil
 }
 
 func (s *exportServiceImpl) exportToWriter(ctx context.Context, config domain.ExportConfig, writer io.Writer) (int, error) {
+	if err := validateStreamingConfig(config); err != nil {
+		return 0, err
+	}
+	if _, err := s.validateExportConfig(config); err != nil {
+		return 0, err
+	}
+	categories, err := obfuscation.NewCategorizer(config.Obfuscation)
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancelDeadline := withExportDeadline(ctx, config.DeadlineSeconds)
+	defer cancelDeadline()
+	batchWindows := CalculateBatchWindows(config.TimeRange, config.Batching)
 	client := s.clientFactory(config.Connection)
 	selector, useQueryRange := s.buildExportQuery(config)
-	batchWindows := CalculateBatchWindows(config.TimeRange, config.Batching)
+	rangeClamp, clampWarning, err := s.clampToData(ctx, client, config, selector, useQueryRange)
+	if err != nil {
+		if deadlineExceeded(ctx) {
+			return 0, deadlineError(config.DeadlineSeconds, 0, len(batchWindows))
+		}
+		return 0, err
+	}
+	if clampWarning != "" {
+		s.printf("[WARN] %s\n", clampWarning)
+	}
+	if rangeClamp != nil {
+		config.TimeRange = rangeClamp.ClampedRange
+		batchWindows = CalculateBatchWindows(config.TimeRange, config.Batching)
+	}
+	selection, err := s.resolveExportSelectors(ctx, client, config, selector, useQueryRange)
+	if err != nil {
+		if deadlineExceeded(ctx) {
+			return 0, deadlineError(config.DeadlineSeconds, 0, len(batchWindows))
+		}
+		return 0, err
+	}
+	selectors := withUpSelector(config, selection.selectors)
+	baseline, _, err := loadBaselineSeries(config.BaselineArchive)
+	if err != nil {
+		return 0, err
+	}
+	opts := newProcessOptions(config, categories, baseline, selection.series)
+	opts.budget = newByteBudget(config.MaxBytes, 0)
 	metricsCount := 0
-	var obfuscator *obfuscation.Obfuscator
+	var obfuscator obfuscation.Obfuscator
 	if config.Obfuscation.Enabled {
-		obfuscator = obfuscation.NewObfuscator()
+		obfuscator = s.newObfuscator()
 	}
 
+	var pointsCount int64
+	opts.points = &pointsCount
+
 	buffered := bufio.NewWriter(writer)
-	for _, window := range batchWindows {
+	planner := newBatchPlanner(batchWindows, config.Batching)
+	coarsener := newStepCoarsener(config, s.progressOut())
+	for batchIndex := 0; batchIndex < len(batchWindows); {
+		window, span := planner.next(batchWindows, batchIndex)
+		completed := batchIndex
+		batchIndex += span
+		opts.decimation.startWindow(window)
 		batchCtx, cancelBatch := context.WithTimeout(ctx, defaultBatchTimeout)
-		exportReader, err := s.fetchBatch(batchCtx, client, selector, window, config.MetricStepSeconds, useQueryRange)
+		watchdog := newStallWatchdog(config.StallTimeoutSeconds, cancelBatch)
+		exportReader, source, err := s.fetchWindow(batchCtx, client, coarsener.apply(config), selectors, window, useQueryRange)
 		if err != nil {
+			watchdog.stop()
 			cancelBatch()
-			return 0, err
+			if deadlineExceeded(ctx) {
+				_ = buffered.Flush()
+				return 0, deadlineError(config.DeadlineSeconds, completed, len(batchWindows))
+			}
+			return 0, watchdog.explain(err)
 		}
+		exportReader = watchdog.watch(exportReader)
 
-		count, err := s.processMetricsIntoWriter(exportReader, config.Obfuscation, obfuscator, buffered)
+		written := &countingWriter{w: buffered}
+		pointsBefore := pointsCount
+		count, err := s.processMetricsIntoWriter(exportReader, config.Obfuscation, obfuscator, opts, written)
 		cancelBatch()
 		if closeErr := exportReader.Close(); closeErr != nil && err == nil {
 			err = closeErr
 		}
+		err = watchdog.explain(err)
+		if errors.Is(err, errByteBudgetReached) {
+			metricsCount += count
+			break
+		}
+		if err != nil && deadlineExceeded(ctx) {
+			// Lines written so far are whole series, so the stream stays valid JSONL
+			_ = buffered.Flush()
+			return 0, deadlineError(config.DeadlineSeconds, completed, len(batchWindows))
+		}
 		if err != nil {
 			return 0, err
 		}
 		metricsCount += count
+		planner.observe(written.n)
+		coarsener.observe(pointsCount-pointsBefore, source, window)
 	}
 
 	if err := buffered.Flush(); err != nil {
@@ -249,12 +656,12 @@ func (s *exportServiceImpl) processMetrics(
 	obfConfig domain.ObfuscationConfig,
 ) (io.Reader, int, map[string]map[string]string, error) {
 	var processedMetrics bytes.Buffer
-	var obfuscator *obfuscation.Obfuscator
+	var obfuscator obfuscation.Obfuscator
 	if obfConfig.Enabled {
-		obfuscator = obfuscation.NewObfuscator()
+		obfuscator = s.newObfuscator()
 	}
 
-	metricsCount, err := s.processMetricsIntoWriter(reader, obfConfig, obfuscator, &processedMetrics)
+	metricsCount, err := s.processMetricsIntoWriter(reader, obfConfig, obfuscator, processOptions{}, &processedMetrics)
 	if err != nil {
 		return nil, 0, nil, err
 	}
@@ -269,14 +676,89 @@ func (s *exportServiceImpl) processMetrics(
 	return &processedMetrics, metricsCount, obfuscationMaps, nil
 }
 
+// processOptions carries per-export series filters applied while processing metrics
+type processOptions struct {
+	baseline       seriesSet
+	selected       seriesSet // nil unless series pagination or per_component_series_cap listed the series
+	keepUp         bool      // always_include_up series pass the selected filter
+	histogramMode  domain.HistogramMode
+	namelessSeries domain.NamelessSeriesPolicy
+	categories     *obfuscation.Categorizer
+	budget         *byteBudget
+	intervals      *scrapeIntervalStats // nil unless scrape interval inference is enabled
+	metricTypes    *metricTypeStats     // nil when no archive lists them
+	future         *futureGuard         // nil unless max_future_skew_seconds is set
+	labels         *labelValueGuard     // nil unless max_label_value_length is set
+	carryIn        *carryInGuard        // nil unless carry_in_seconds is set
+	normalize      *labelNormalizer     // nil unless lowercase_label_names or trim_label_values is set
+	source         *sourceDigest        // nil for resumed exports, whose earlier batches are not seen
+	counterDeltas  bool
+	decimation     *decimator // nil unless max_points_per_series is set
+	sampling       *sampler   // nil unless sample_every_n is above 1
+	stripStale     bool
+	csv            *csvSink // nil unless the csv format is requested
+	points         *int64   // samples written, summed across batches; nil when not counted
+	sampled        *int     // written series that carry real samples, see hasRealSamples; nil when not counted
+	lineLimit      int      // longest series line accepted from VictoriaMetrics; 0 uses vm.DefaultMaxLineBytes
+}
+
+// errByteBudgetReached stops processing once the export byte budget is used up
+var errByteBudgetReached = errors.New("export byte budget reached")
+
+// byteBudget tracks bytes written against the MaxBytes limit; a nil budget is unlimited
+type byteBudget struct {
+	limit int64
+	used  int64
+}
+
+func newByteBudget(limit, used int64) *byteBudget {
+	if limit <= 0 {
+		return nil
+	}
+	return &byteBudget{limit: limit, used: used}
+}
+
+func (b *byteBudget) allow(n int) bool {
+	if b == nil {
+		return true
+	}
+	if b.used+int64(n) > b.limit {
+		return false
+	}
+	b.used += int64(n)
+	return true
+}
+
+func (b *byteBudget) partial(config domain.ExportConfig, windows []domain.TimeRange, completed int) *domain.PartialExport {
+	return &domain.PartialExport{
+		Reason:           "max_bytes",
+		MaxBytes:         b.limit,
+		BytesWritten:     b.used,
+		CoveredRange:     coveredRange(config, windows, completed),
+		CompletedBatches: completed,
+		TotalBatches:     len(windows),
+	}
+}
+
+// coveredRange is the span of the first completed batch windows, starting at the export start
+func coveredRange(config domain.ExportConfig, windows []domain.TimeRange, completed int) domain.TimeRange {
+	covered := domain.TimeRange{Start: config.TimeRange.Start, End: config.TimeRange.Start}
+	if completed > 0 {
+		covered.End = windows[completed-1].End
+	}
+	return covered
+}
+
 // processMetricsIntoWriter decodes metrics stream, applies obfuscation (if enabled) and appends JSONL lines into the provided writer.
+// Series filtered out by opts (baseline, histogram compaction) are skipped; long series are decimated when opts.decimation is set.
 func (s *exportServiceImpl) processMetricsIntoWriter(
 	reader io.Reader,
 	obfConfig domain.ObfuscationConfig,
-	obfuscator *obfuscation.Obfuscator,
+	obfuscator obfuscation.Obfuscator,
+	opts processOptions,
 	writer io.Writer,
 ) (int, error) {
-	decoder := vm.NewExportDecoder(reader)
+	decoder := vm.NewExportDecoderSize(reader, opts.lineLimit)
 	metricsCount := 0
 
 	for {
@@ -284,27 +766,86 @@ func (s *exportServiceImpl) processMetricsIntoWriter(
 		if err == io.EOF {
 			break
 		}
+		if errors.Is(err, vm.ErrLineTooLong) {
+			return metricsCount, &batchReadError{fmt.Errorf("%w; raise max_line_bytes or export with a shorter batch window so each series line is smaller", err)}
+		}
 		if err != nil {
-			return 0, fmt.Errorf("decode error: %w", err)
+			return metricsCount, &batchReadError{fmt.Errorf("decode error: %w", err)}
+		}
+		// Exact-match selectors can also match series with labels never seen in the listing
+		if opts.selected != nil && !opts.selected.contains(metric.Metric) && !(opts.keepUp && metric.Metric["__name__"] == "up") {
+			continue
+		}
+		if err := opts.source.observe(metric); err != nil {
+			return 0, fmt.Errorf("marshal error: %w", err)
 		}
 
+		opts.normalize.apply(metric)
 		if len(obfConfig.DropLabels) > 0 {
 			for _, label := range obfConfig.DropLabels {
 				delete(metric.Metric, label)
 			}
 		}
 
+		var keep bool
+		if metric.Metric, keep = applyNamelessSeriesPolicy(metric.Metric, opts.namelessSeries); !keep {
+			continue
+		}
+		if opts.baseline.contains(metric.Metric) {
+			continue
+		}
+		if opts.histogramMode == domain.HistogramModeCompact && isVMRangeBucket(metric.Metric) && allZeroValues(metric.Values) {
+			continue
+		}
+		if opts.carryIn.apply(metric); len(metric.Timestamps) == 0 {
+			continue
+		}
+		if opts.stripStale {
+			if stripStalenessMarkers(metric); len(metric.Timestamps) == 0 {
+				continue
+			}
+		}
+		if opts.future.apply(metric); len(metric.Timestamps) == 0 {
+			continue
+		}
+		keep, err = opts.labels.apply(metric)
+		if err != nil {
+			return 0, err
+		}
+		if !keep {
+			continue
+		}
+
 		if obfConfig.Enabled {
 			if obfuscator == nil {
-				obfuscator = obfuscation.NewObfuscator()
+				obfuscator = s.newObfuscator()
 			}
+			// Categories are derived from the original values, so they come first
+			opts.categories.Apply(metric.Metric)
 			s.applyObfuscation(metric, obfuscator, obfConfig)
 		}
+		// Scrape intervals are inferred from the original spacing, not the sampled or decimated one
+		timestamps := metric.Timestamps
+		opts.sampling.apply(metric)
+		opts.decimation.apply(metric)
+		csvValues := metric.Values
+		if opts.counterDeltas {
+			if opts.csv != nil {
+				csvValues = append(vm.SampleValues(nil), metric.Values...)
+			}
+			encodeCounterDeltas(metric)
+		}
 
 		data, err := json.Marshal(metric)
 		if err != nil {
 			return 0, fmt.Errorf("marshal error: %w", err)
 		}
+		if !opts.budget.allow(len(data) + 1) {
+			return metricsCount, errByteBudgetReached
+		}
+		// Grouped after obfuscation so component keys never reveal original job names
+		opts.intervals.observe(s.guessComponent(metric.Metric), timestamps)
+		opts.metricTypes.observe(metric.Metric["__name__"])
 
 		if _, err := writer.Write(data); err != nil {
 			return 0, fmt.Errorf("write error: %w", err)
@@ -312,6 +853,15 @@ func (s *exportServiceImpl) processMetricsIntoWriter(
 		if _, err := writer.Write([]byte{'\n'}); err != nil {
 			return 0, fmt.Errorf("write error: %w", err)
 		}
+		if err := opts.csv.writeSeries(metric.Metric, csvValues, metric.Timestamps); err != nil {
+			return 0, err
+		}
+		if opts.points != nil {
+			*opts.points += int64(len(metric.Timestamps))
+		}
+		if opts.sampled != nil && hasRealSamples(metric) {
+			*opts.sampled++
+		}
 		metricsCount++
 	}
 
@@ -321,7 +871,7 @@ func (s *exportServiceImpl) processMetricsIntoWriter(
 // applyObfuscation applies obfuscation to a metric
 func (s *exportServiceImpl) applyObfuscation(
 	metric *vm.ExportedMetric,
-	obfuscator *obfuscation.Obfuscator,
+	obfuscator obfuscation.Obfuscator,
 	config domain.ObfuscationConfig,
 ) {
 	if metric.Metric == nil {
@@ -330,14 +880,14 @@ func (s *exportServiceImpl) applyObfuscation(
 
 	// Obfuscate instance label
 	if config.ObfuscateInstance {
-		if instance, exists := metric.Metric["instance"]; exists {
+		if instance, exists := metric.Metric["instance"]; exists && !obfuscation.Allowlisted(config, instance) {
 			metric.Metric["instance"] = obfuscator.ObfuscateInstance(instance)
 		}
 	}
 
 	// Obfuscate job label
 	if config.ObfuscateJob {
-		if job, exists := metric.Metric["job"]; exists {
+		if job, exists := metric.Metric["job"]; exists && !obfuscation.Allowlisted(config, job) {
 			// Try to determine component from metric name or other labels
 			component := s.guessComponent(metric.Metric)
 			metric.Metric["job"] = obfuscator.ObfuscateJob(job, component)
@@ -346,12 +896,15 @@ func (s *exportServiceImpl) applyObfuscation(
 
 	// Obfuscate custom labels (pod, namespace, etc.)
 	for _, labelName := range config.CustomLabels {
-		if value, exists := metric.Metric[labelName]; exists {
+		if value, exists := metric.Metric[labelName]; exists && !obfuscation.Allowlisted(config, value) {
 			metric.Metric[labelName] = obfuscator.ObfuscateCustomLabel(labelName, value)
 		}
 	}
 }
 
+// componentMetricPrefixes are the components recognized by their metric name prefix
+var componentMetricPrefixes = []string{"vmstorage", "vmselect", "vminsert", "vmagent", "vmalert"}
+
 // guessComponent attempts to determine component type from metric labels
 // Falls back to "unknown" if cannot be determined
 func (s *exportServiceImpl) guessComponent(labels map[string]string) string {
@@ -372,21 +925,10 @@ func (s *exportServiceImpl) guessComponent(labels map[string]string) string {
 	}
 
 	// Common VictoriaMetrics metric prefixes
-	// Check specific components first (longer prefixes)
-	if len(metricName) >= 10 && metricName[0:10] == "vmstorage_" {
-		return "vmstorage"
-	}
-	if len(metricName) >= 9 && metricName[0:9] == "vmselect_" {
-		return "vmselect"
-	}
-	if len(metricName) >= 9 && metricName[0:9] == "vminsert_" {
-		return "vminsert"
-	}
-	if len(metricName) >= 8 && metricName[0:8] == "vmagent_" {
-		return "vmagent"
-	}
-	if len(metricName) >= 8 && metricName[0:8] == "vmalert_" {
-		return "vmalert"
+	for _, component := range componentMetricPrefixes {
+		if strings.HasPrefix(metricName, component+"_") {
+			return component
+		}
 	}
 
 	// Fallback: use job name as component
@@ -406,14 +948,59 @@ func (s *exportServiceImpl) buildSelector(jobs []string) string {
 	return buildJobFilterSelector(jobs)
 }
 
+// buildTargetFilterSelector builds a selector matching the given jobs and/or instances
+func buildTargetFilterSelector(jobs, instances []string) string {
+	if len(instances) == 0 {
+		return buildJobFilterSelector(jobs)
+	}
+	matcher := buildInstanceMatcher(instances)
+	if len(jobs) == 0 {
+		return fmt.Sprintf("{%s}", matcher)
+	}
+	jobSelector := buildJobFilterSelector(jobs)
+	return fmt.Sprintf("{%s, %s}", strings.TrimSuffix(strings.TrimPrefix(jobSelector, "{"), "}"), matcher)
+}
+
+// buildInstanceMatcher matches instances exactly: regex metacharacters (dots in IPs) are
+// escaped and the result is escaped again for the double-quoted PromQL string.
+func buildInstanceMatcher(instances []string) string {
+	escaped := make([]string, 0, len(instances))
+	for _, instance := range instances {
+		escaped = append(escaped, regexp.QuoteMeta(instance))
+	}
+	pattern := strings.Join(escaped, "|")
+	pattern = strings.ReplaceAll(pattern, `\`, `\\`)
+	pattern = strings.ReplaceAll(pattern, `"`, `\"`)
+	return fmt.Sprintf(`instance=~"%s"`, pattern)
+}
+
+// ValidateInstances rejects instance filters that cannot be expressed as exact matches
+func ValidateInstances(instances []string) error {
+	for _, instance := range instances {
+		if strings.TrimSpace(instance) == "" {
+			return fmt.Errorf("instances must not contain empty values")
+		}
+		for _, r := range instance {
+			if r < 32 || r == 127 {
+				return fmt.Errorf("instance %q contains control characters", instance)
+			}
+		}
+	}
+	return nil
+}
+
 func (s *exportServiceImpl) buildExportQuery(config domain.ExportConfig) (string, bool) {
+	if config.QuerySet != nil {
+		// Every query of the set runs on its own via query_range; the joined form is only for logs
+		return querySetExpression(config.QuerySet), true
+	}
 	if config.Mode == domain.ExportModeCustom && config.Query != "" {
 		switch config.QueryType {
 		case domain.QueryModeSelector:
 			selector := config.Query
-			if len(config.Jobs) > 0 {
-				filter := buildJobFilterSelector(config.Jobs)
-				selector = fmt.Sprintf("(%s) and on(job) %s", selector, filter)
+			if len(config.Jobs) > 0 || len(config.Instances) > 0 {
+				filter := buildTargetFilterSelector(config.Jobs, config.Instances)
+				selector = fmt.Sprintf("(%s) and on(%s) %s", selector, targetFilterLabels(config), filter)
 				return selector, true
 			}
 			return selector, false
@@ -424,9 +1011,24 @@ func (s *exportServiceImpl) buildExportQuery(config domain.ExportConfig) (string
 		}
 	}
 
+	if len(config.Instances) > 0 {
+		return buildTargetFilterSelector(config.Jobs, config.Instances), false
+	}
 	return s.buildSelector(config.Jobs), false
 }
 
+// targetFilterLabels lists the labels used to join a custom selector with the job/instance filter
+func targetFilterLabels(config domain.ExportConfig) string {
+	labels := make([]string, 0, 2)
+	if len(config.Jobs) > 0 {
+		labels = append(labels, "job")
+	}
+	if len(config.Instances) > 0 {
+		labels = append(labels, "instance")
+	}
+	return strings.Join(labels, ", ")
+}
+
 // buildArchiveMetadata builds archive metadata from export config
 func (s *exportServiceImpl) buildArchiveMetadata(
 	exportID string,
@@ -443,6 +1045,8 @@ func (s *exportServiceImpl) buildArchiveMetadata(
 		MetricsCount:    metricsCount,
 		Obfuscated:      config.Obfuscation.Enabled,
 		VMGatherVersion: s.vmGatherVersion,
+		Format:          exportFormat(config),
+		Fingerprint:     connectionFingerprint(config.Connection),
 	}
 
 	// Add obfuscation maps if present
@@ -522,9 +1126,12 @@ func determineQueryRangeStep(tr domain.TimeRange, overrideSeconds int) time.Dura
 
 // exportViaQueryRange exports metrics using query_range as fallback when /api/v1/export is not available
 // This method queries all series matching the selector and reconstructs export format
-// It uses streaming and time chunking to avoid OOM on large time ranges
-func (s *exportServiceImpl) exportViaQueryRange(ctx context.Context, client *vm.Client, selector string, timeRange domain.TimeRange, overrideSeconds int) (io.ReadCloser, error) {
+// It uses streaming and time chunking to avoid OOM on large time ranges.
+// Points are evaluated at every step, so a raw sample repeats until lookbehindSeconds (or the
+// server's default lookback) expires; the archive marks such batches as not raw.
+func (s *exportServiceImpl) exportViaQueryRange(ctx context.Context, client *vm.Client, selector string, timeRange domain.TimeRange, overrideSeconds, lookbehindSeconds int) (io.ReadCloser, error) {
 	step := determineQueryRangeStep(timeRange, overrideSeconds)
+	lookbehind := time.Duration(lookbehindSeconds) * time.Second
 
 	// Create a pipe to stream results
 	pr, pw := io.Pipe()
@@ -538,7 +1145,7 @@ func (s *exportServiceImpl) exportViaQueryRange(ctx context.Context, client *vm.
 		currentStart := timeRange.Start
 		totalPoints := 0
 
-		fmt.Printf("Starting streaming query_range fallback (chunk size: %v)\n", chunkSize)
+		s.printf("Starting streaming query_range fallback (chunk size: %v)\n", chunkSize)
 
 		for currentStart.Before(timeRange.End) {
 			// Check context cancellation
@@ -551,15 +1158,17 @@ func (s *exportServiceImpl) exportViaQueryRange(ctx context.Context, client *vm.
 			if currentEnd.After(timeRange.End) {
 				currentEnd = timeRange.End
 			}
+			// query_range includes both ends: a point on a chunk boundary belongs to the next chunk
+			requestEnd := currentEnd
+			if currentEnd.Before(timeRange.End) {
+				requestEnd = currentEnd.Add(-time.Millisecond)
+			}
 
-			// Execute query_range for this chunk
-			// We use a separate context for the request to ensure we can cancel it
-			reqCtx, cancel := context.WithTimeout(ctx, 2*time.Minute)
-			result, err := client.QueryRange(reqCtx, selector, currentStart, currentEnd, step)
-			cancel()
+			// Execute query_range for this chunk; the client bounds it by request_timeout_seconds
+			result, err := client.QueryRangeWithLookbehind(ctx, selector, currentStart, requestEnd, step, lookbehind)
 
 			if err != nil {
-				fmt.Printf("[FAIL] Query_range failed for chunk %s-%s: %v\n",
+				s.printf("[FAIL] Query_range failed for chunk %s-%s: %v\n",
 					currentStart.Format(time.RFC3339), currentEnd.Format(time.RFC3339), err)
 				_ = pw.CloseWithError(fmt.Errorf("query_range chunk failed: %w", err))
 				return
@@ -581,16 +1190,16 @@ func (s *exportServiceImpl) exportViaQueryRange(ctx context.Context, client *vm.
 					if !ok {
 						continue
 					}
-					valueNum, err := strconv.ParseFloat(valueStr, 64)
+					valueNum, err := vm.ParseSampleValue(valueStr)
 					if err != nil {
 						continue
 					}
 
 					// Build export line
-					exportLine := map[string]interface{}{
-						"metric":     series.Metric,
-						"values":     []interface{}{valueNum},
-						"timestamps": []interface{}{int64(timestamp * 1000)},
+					exportLine := vm.ExportedMetric{
+						Metric:     series.Metric,
+						Values:     vm.SampleValues{valueNum},
+						Timestamps: []int64{int64(timestamp * 1000)},
 					}
 
 					if err := encoder.Encode(exportLine); err != nil {
@@ -601,47 +1210,62 @@ func (s *exportServiceImpl) exportViaQueryRange(ctx context.Context, client *vm.
 				}
 			}
 
-			// Move to next chunk
-			// Add a small overlap or just next step?
-			// QueryRange is inclusive of start and end?
-			// Prometheus /query_range is usually inclusive.
-			// If we do [0, 60] and [60, 120], we might get duplicate point at 60.
-			// But step aligns points.
-			// Safest is to start next chunk at currentEnd + step?
-			// Or just rely on deduplication downstream?
-			// Actually, standard practice is [start, end).
-			// But Prom API takes start/end.
-			// Let's just use currentEnd as next start, duplicates are better than gaps,
-			// and usually downstream can handle it or we accept minor overlap.
 			currentStart = currentEnd
 		}
 
-		fmt.Printf("[OK] Streaming completed. Total points: %d\n", totalPoints)
+		s.printf("[OK] Streaming completed. Total points: %d\n", totalPoints)
 		_ = pw.Close()
 	}()
 
 	return pr, nil
 }
 
-func (s *exportServiceImpl) fetchBatch(ctx context.Context, client *vm.Client, selector string, tr domain.TimeRange, metricStepSeconds int, forceQueryRange bool) (io.ReadCloser, error) {
-	fmt.Printf("Attempting export for batch: %s -> %s\n", tr.Start.Format(time.RFC3339), tr.End.Format(time.RFC3339))
+// fetchWindow fetches one batch window of the export, running a query set query by query
+func (s *exportServiceImpl) fetchWindow(ctx context.Context, client *vm.Client, config domain.ExportConfig, selectors []string, window domain.TimeRange, useQueryRange bool) (io.ReadCloser, domain.DataSource, error) {
+	window.End = windowRequestEnd(config, window)
+	if config.QuerySet != nil {
+		return s.fetchQuerySet(ctx, client, config.QuerySet, window, config.MetricStepSeconds, config.LookbehindSeconds), domain.DataSourceQueryRange, nil
+	}
+	// Only the first window reaches back; later windows would carry in samples already exported
+	if config.CarryInSeconds > 0 && window.Start.Equal(config.TimeRange.Start) {
+		window.Start = carryInStart(config)
+	}
+	if config.SelectorConcurrency > 1 && len(selectors) > 1 {
+		return s.fetchSelectorsConcurrently(ctx, client, config, selectors, window, useQueryRange)
+	}
+	return s.fetchBatch(ctx, client, selectors, window, config.MetricStepSeconds, config.LookbehindSeconds, useQueryRange)
+}
+
+// fetchBatch returns the batch data and whether it came from /api/v1/export or from query_range
+func (s *exportServiceImpl) fetchBatch(ctx context.Context, client *vm.Client, selectors []string, tr domain.TimeRange, metricStepSeconds, lookbehindSeconds int, forceQueryRange bool) (io.ReadCloser, domain.DataSource, error) {
+	s.printf("Attempting export for batch: %s -> %s\n", tr.Start.Format(time.RFC3339), tr.End.Format(time.RFC3339))
+	if len(selectors) == 0 {
+		// An empty series page has nothing to fetch.
+		return emptyExportReader(), domain.DataSourceExport, nil
+	}
+	querySelector := strings.Join(selectors, " or ")
 	if forceQueryRange {
-		fmt.Printf("[INFO] Using query_range export for custom query\n")
-		return s.exportViaQueryRange(ctx, client, selector, tr, metricStepSeconds)
+		s.printf("[INFO] Using query_range export for custom query\n")
+		reader, err := s.exportViaQueryRange(ctx, client, querySelector, tr, metricStepSeconds, lookbehindSeconds)
+		return reader, domain.DataSourceQueryRange, err
+	}
+	reader, err := client.ExportMatches(ctx, selectors, tr.Start, tr.End)
+	if errors.Is(err, vm.ErrNoData) {
+		s.printf("[INFO] No series matched for batch %s -> %s\n", tr.Start.Format(time.RFC3339), tr.End.Format(time.RFC3339))
+		return emptyExportReader(), domain.DataSourceExport, nil
 	}
-	reader, err := client.Export(ctx, selector, tr.Start, tr.End)
 	if err != nil && s.isMissingRouteError(err) {
-		fmt.Printf("[WARN] Export API not available for current batch, falling back to query_range\n")
-		return s.exportViaQueryRange(ctx, client, selector, tr, metricStepSeconds)
+		s.printf("[WARN] Export API not available for current batch, falling back to query_range (points are evaluated per step, not raw samples)\n")
+		reader, err := s.exportViaQueryRange(ctx, client, querySelector, tr, metricStepSeconds, lookbehindSeconds)
+		return reader, domain.DataSourceQueryRange, err
 	}
 	if err != nil {
-		return nil, fmt.Errorf("export failed: %w", err)
+		return nil, "", fmt.Errorf("export failed: %w", err)
 	}
-	return reader, nil
+	return reader, domain.DataSourceExport, nil
 }
 
 // generateExportID generates a unique export ID
 func (s *exportServiceImpl) generateExportID() string {
-	timestamp := time.Now().Unix()
-	return fmt.Sprintf("export-%d", timestamp)
+	return fmt.Sprintf("export-%d", time.Now().UnixNano())
 }
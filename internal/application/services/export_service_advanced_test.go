@@ -453,7 +453,7 @@ func TestExportService_ApplyObfuscation_SelectiveLabels(t *testing.T) {
 			"datacenter": "dc1",
 			"version":    "v1.95.1",
 		},
-		Values:     []interface{}{1.0},
+		Values:     vm.SampleValues{1.0},
 		Timestamps: []int64{1699728000000},
 	}
 
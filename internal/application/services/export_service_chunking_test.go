@@ -70,7 +70,7 @@ func TestExportViaQueryRange_Chunking(t *testing.T) {
 	ctx := context.Background()
 	tr := domain.TimeRange{Start: startTime, End: endTime}
 
-	reader, err := svc.exportViaQueryRange(ctx, client, "{__name__!=\"\"}", tr, 0)
+	reader, err := svc.exportViaQueryRange(ctx, client, "{__name__!=\"\"}", tr, 0, 0)
 	if err != nil {
 		t.Fatalf("exportViaQueryRange failed: %v", err)
 	}
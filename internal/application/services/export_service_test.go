@@ -1,6 +1,7 @@
 package services
 
 import (
+	"archive/zip"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -15,6 +16,7 @@ import (
 
 	"github.com/VictoriaMetrics/vmgather/internal/domain"
 	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/archive"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/obfuscation"
 	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
 )
 
@@ -143,6 +145,37 @@ func TestExportService_BuildExportQuery(t *testing.T) {
 			expected:    `({__name__=~"vm_.*"}) and on(job) {job=~"vmstorage-prod|vmselect-prod"}`,
 			useQueryRng: true,
 		},
+		{
+			name: "jobs combined with instances",
+			config: domain.ExportConfig{
+				Mode:      domain.ExportModeCluster,
+				Jobs:      []string{"vmstorage-prod"},
+				Instances: []string{"10.0.1.5:8482", "10.0.1.6:8482"},
+			},
+			expected:    `{job=~"vmstorage-prod", instance=~"10\\.0\\.1\\.5:8482|10\\.0\\.1\\.6:8482"}`,
+			useQueryRng: false,
+		},
+		{
+			name: "instances without jobs",
+			config: domain.ExportConfig{
+				Mode:      domain.ExportModeCluster,
+				Instances: []string{`host"1`},
+			},
+			expected:    `{instance=~"host\"1"}`,
+			useQueryRng: false,
+		},
+		{
+			name: "custom selector with job and instance filter",
+			config: domain.ExportConfig{
+				Mode:      domain.ExportModeCustom,
+				QueryType: domain.QueryModeSelector,
+				Query:     `{__name__=~"vm_.*"}`,
+				Jobs:      []string{"vmstorage-prod"},
+				Instances: []string{"node-1"},
+			},
+			expected:    `({__name__=~"vm_.*"}) and on(job, instance) {job=~"vmstorage-prod", instance=~"node-1"}`,
+			useQueryRng: true,
+		},
 		{
 			name: "custom metricsql forces query_range",
 			config: domain.ExportConfig{
@@ -517,6 +550,70 @@ func TestExportService_ProcessMetrics_WithObfuscation(t *testing.T) {
 	}
 }
 
+func TestExportService_ProcessMetrics_ObfuscatesInstanceWithoutPort(t *testing.T) {
+	service := &exportServiceImpl{}
+	metricsData := `{"metric":{"__name__":"up","instance":"vmstorage-1","job":"vmstorage"},"values":[1],"timestamps":[1699728000000]}
+{"metric":{"__name__":"up","instance":"10.0.1.5","job":"vmstorage"},"values":[1],"timestamps":[1699728000000]}
+{"metric":{"__name__":"go_goroutines","instance":"vmstorage-1","job":"vmstorage"},"values":[42],"timestamps":[1699728000000]}`
+
+	obfConfig := domain.ObfuscationConfig{Enabled: true, ObfuscateInstance: true}
+	processedReader, count, obfMaps, err := service.processMetrics(strings.NewReader(metricsData), obfConfig)
+	if err != nil {
+		t.Fatalf("processMetrics failed: %v", err)
+	}
+	if count != 3 {
+		t.Fatalf("metrics count = %d, want 3", count)
+	}
+	instances := obfMaps["instance"]
+	if instances["vmstorage-1"] != "777.777.1.1" || instances["10.0.1.5"] != "777.777.1.2" {
+		t.Fatalf("expected port-less instances to get pool IPs without port, got %v", instances)
+	}
+	data, err := io.ReadAll(processedReader)
+	if err != nil {
+		t.Fatalf("failed to read processed metrics: %v", err)
+	}
+	if strings.Contains(string(data), "vmstorage-1") || strings.Contains(string(data), "10.0.1.5") {
+		t.Fatalf("original instance leaked into output: %s", data)
+	}
+	if strings.Count(string(data), `"instance":"777.777.1.1"`) != 2 {
+		t.Fatalf("expected both series of vmstorage-1 to share one pseudonym, got %s", data)
+	}
+}
+
+func TestApplyObfuscation_AllowlistPassesValuesThrough(t *testing.T) {
+	service := &exportServiceImpl{}
+	obfuscator := obfuscation.NewObfuscator()
+	config := domain.ObfuscationConfig{
+		Enabled:           true,
+		ObfuscateInstance: true,
+		ObfuscateJob:      true,
+		CustomLabels:      []string{"pod"},
+		Allowlist:         []string{"demo.victoriametrics.com:8428", "demo", "vmagent-demo-0"},
+	}
+
+	demo := &vm.ExportedMetric{Metric: map[string]string{"__name__": "up", "instance": "demo.victoriametrics.com:8428", "job": "demo", "pod": "vmagent-demo-0"}}
+	private := &vm.ExportedMetric{Metric: map[string]string{"__name__": "up", "instance": "10.0.1.5:8482", "job": "vmstorage-prod", "pod": "vmstorage-0"}}
+	service.applyObfuscation(demo, obfuscator, config)
+	service.applyObfuscation(private, obfuscator, config)
+
+	if demo.Metric["instance"] != "demo.victoriametrics.com:8428" || demo.Metric["job"] != "demo" || demo.Metric["pod"] != "vmagent-demo-0" {
+		t.Fatalf("expected allowlisted values to be untouched, got %v", demo.Metric)
+	}
+	for _, label := range []string{"instance", "job", "pod"} {
+		original := map[string]string{"instance": "10.0.1.5:8482", "job": "vmstorage-prod", "pod": "vmstorage-0"}[label]
+		if got := private.Metric[label]; got == original || got == "" {
+			t.Errorf("expected %s to be obfuscated, got %q", label, got)
+		}
+	}
+	instances, jobs := obfuscator.GetMappings()
+	if _, ok := instances["demo.victoriametrics.com:8428"]; ok {
+		t.Error("allowlisted instance must not appear in the obfuscation mapping")
+	}
+	if _, ok := jobs["demo"]; ok {
+		t.Error("allowlisted job must not appear in the obfuscation mapping")
+	}
+}
+
 func TestProcessMetricsIntoWriterFile(t *testing.T) {
 	service := &exportServiceImpl{}
 	tmpDir := t.TempDir()
@@ -527,7 +624,7 @@ func TestProcessMetricsIntoWriterFile(t *testing.T) {
 	}
 
 	metricsData := `{"metric":{"__name__":"up","instance":"a","job":"j"},"values":[1],"timestamps":[1000]}`
-	count, err := service.processMetricsIntoWriter(strings.NewReader(metricsData), domain.ObfuscationConfig{}, nil, handle)
+	count, err := service.processMetricsIntoWriter(strings.NewReader(metricsData), domain.ObfuscationConfig{}, nil, processOptions{}, handle)
 	if err != nil {
 		t.Fatalf("processMetricsIntoWriter failed: %v", err)
 	}
@@ -578,7 +675,7 @@ func TestExportService_ApplyObfuscation(t *testing.T) {
 			"instance": "10.0.1.5:8482",
 			"job":      "vmstorage-prod",
 		},
-		Values:     []interface{}{1.0},
+		Values:     vm.SampleValues{1.0},
 		Timestamps: []int64{1699728000000},
 	}
 
@@ -769,3 +866,435 @@ func TestExportService_Integration_NoObfuscation(t *testing.T) {
 	// This is better suited for E2E tests
 	t.Log("Integration test stub - full E2E requires VM instance")
 }
+
+type stagingCheckReporter struct {
+	t          *testing.T
+	path       string
+	syncs      *int
+	checkedOne bool
+}
+
+func (r *stagingCheckReporter) OnBatchComplete(progress BatchProgress) {
+	if *r.syncs != progress.BatchIndex {
+		r.t.Errorf("batch %d reported after %d fsyncs", progress.BatchIndex, *r.syncs)
+	}
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		r.t.Errorf("failed to read staging file: %v", err)
+		return
+	}
+	if got := strings.Count(string(data), "\n"); got != progress.BatchIndex {
+		r.t.Errorf("expected %d staged lines after batch %d, got %d", progress.BatchIndex, progress.BatchIndex, got)
+	}
+	r.checkedOne = true
+}
+
+// TestExecuteExport_StagingFsyncAfterBatch tests that each batch is fsynced to disk before progress is reported
+func TestExecuteExport_StagingFsyncAfterBatch(t *testing.T) {
+	server := newIPv4Server(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"metric":{"__name__":"up","job":"vmstorage"},"values":[1],"timestamps":[1000]}` + "\n"))
+	}))
+	defer server.Close()
+
+	syncs := 0
+	originalSync := syncStagingFile
+	syncStagingFile = func(f *os.File) error {
+		syncs++
+		return originalSync(f)
+	}
+	defer func() { syncStagingFile = originalSync }()
+
+	stagingFile := filepath.Join(t.TempDir(), "staging.partial.jsonl")
+	reporter := &stagingCheckReporter{t: t, path: stagingFile, syncs: &syncs}
+	ctx := WithProgressReporter(context.Background(), reporter)
+
+	service := &exportServiceImpl{
+		clientFactory:   vm.NewClient,
+		archiveWriter:   archive.NewWriter(t.TempDir()),
+		vmGatherVersion: "test",
+	}
+	end := time.Now()
+	_, err := service.ExecuteExport(ctx, domain.ExportConfig{
+		Connection:        domain.VMConnection{URL: server.URL},
+		TimeRange:         domain.TimeRange{Start: end.Add(-2 * time.Hour), End: end},
+		Batching:          domain.BatchSettings{Enabled: true, Strategy: "custom", CustomIntervalSecs: 3600},
+		StagingFile:       stagingFile,
+		StagingBufferSize: 1 << 20,
+		StagingFsync:      true,
+	})
+	if err != nil {
+		t.Fatalf("ExecuteExport failed: %v", err)
+	}
+	if !reporter.checkedOne {
+		t.Fatal("expected at least one batch progress report")
+	}
+	if syncs < 2 {
+		t.Fatalf("expected an fsync per batch, got %d", syncs)
+	}
+}
+
+// TestExecuteExport_MaxBytesSealsPartialArchive tests that a byte budget stops the export with a valid partial archive
+func TestExecuteExport_MaxBytesSealsPartialArchive(t *testing.T) {
+	line := `{"metric":{"__name__":"up","job":"vmstorage"},"values":[1],"timestamps":[1000]}`
+	server := newIPv4Server(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 10; i++ {
+			_, _ = w.Write([]byte(line + "\n"))
+		}
+	}))
+	defer server.Close()
+
+	service := &exportServiceImpl{
+		clientFactory:   vm.NewClient,
+		archiveWriter:   archive.NewWriter(t.TempDir()),
+		vmGatherVersion: "test",
+	}
+	end := time.Now().Truncate(time.Hour)
+	lineBytes := int64(len(line) + 1)
+	result, err := service.ExecuteExport(context.Background(), domain.ExportConfig{
+		Connection: domain.VMConnection{URL: server.URL},
+		TimeRange:  domain.TimeRange{Start: end.Add(-3 * time.Hour), End: end},
+		Batching:   domain.BatchSettings{Enabled: true, Strategy: "custom", CustomIntervalSecs: 3600},
+		StagingDir: t.TempDir(),
+		// One full batch (10 lines) plus a fraction of the second one.
+		MaxBytes: lineBytes*12 + lineBytes/2,
+	})
+	if err != nil {
+		t.Fatalf("ExecuteExport failed: %v", err)
+	}
+	if result.MetricsExported != 12 {
+		t.Fatalf("expected 12 exported series within budget, got %d", result.MetricsExported)
+	}
+	if result.Partial == nil || result.Partial.Reason != "max_bytes" || result.Partial.CompletedBatches != 1 || result.Partial.TotalBatches != 3 {
+		t.Fatalf("expected partial result after 1 of 3 batches, got %+v", result.Partial)
+	}
+	if want := end.Add(-2 * time.Hour); !result.Partial.CoveredRange.End.Equal(want) {
+		t.Fatalf("covered range end = %s, want %s", result.Partial.CoveredRange.End, want)
+	}
+	if result.Partial.BytesWritten > result.Partial.MaxBytes {
+		t.Fatalf("bytes written %d exceed budget %d", result.Partial.BytesWritten, result.Partial.MaxBytes)
+	}
+
+	zr, err := zip.OpenReader(result.ArchivePath)
+	if err != nil {
+		t.Fatalf("partial archive is not a valid zip: %v", err)
+	}
+	defer zr.Close()
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("failed to open %s: %v", f.Name, err)
+		}
+		data, _ := io.ReadAll(rc)
+		_ = rc.Close()
+		switch f.Name {
+		case "metrics.jsonl":
+			if got := strings.Count(string(data), "\n"); got != 12 {
+				t.Fatalf("expected 12 lines in archive, got %d", got)
+			}
+		case "metadata.json":
+			var meta struct {
+				Partial *domain.PartialExport `json:"partial"`
+			}
+			if err := json.Unmarshal(data, &meta); err != nil || meta.Partial == nil {
+				t.Fatalf("metadata must mark the archive partial: %v %s", err, data)
+			}
+		}
+	}
+}
+
+func TestExecuteExport_KeepStagingPreservesFile(t *testing.T) {
+	exportBody := `{"metric":{"__name__":"up","job":"test"},"values":[1],"timestamps":[1]}` + "\n"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, exportBody)
+	}))
+	defer srv.Close()
+
+	service := &exportServiceImpl{
+		clientFactory:   vm.NewClient,
+		archiveWriter:   archive.NewWriter(t.TempDir()),
+		vmGatherVersion: "test",
+	}
+	config := domain.ExportConfig{
+		Connection: domain.VMConnection{URL: srv.URL},
+		TimeRange: domain.TimeRange{
+			Start: time.Now().Add(-time.Minute),
+			End:   time.Now(),
+		},
+		StagingDir:        t.TempDir(),
+		MetricStepSeconds: 30,
+	}
+
+	result, err := service.ExecuteExport(context.Background(), config)
+	if err != nil {
+		t.Fatalf("ExecuteExport failed: %v", err)
+	}
+	if result.StagingPath != "" {
+		t.Fatalf("expected no staging path by default, got %q", result.StagingPath)
+	}
+	entries, _ := os.ReadDir(config.StagingDir)
+	if len(entries) != 0 {
+		t.Fatalf("expected staging file to be removed by default, found %d entries", len(entries))
+	}
+
+	config.KeepStaging = true
+	result, err = service.ExecuteExport(context.Background(), config)
+	if err != nil {
+		t.Fatalf("ExecuteExport failed: %v", err)
+	}
+	if result.StagingPath == "" {
+		t.Fatal("expected staging path in result when keep_staging is set")
+	}
+	data, err := os.ReadFile(result.StagingPath)
+	if err != nil {
+		t.Fatalf("expected staging file to survive: %v", err)
+	}
+	if !strings.Contains(string(data), `"__name__":"up"`) {
+		t.Fatalf("unexpected staging contents: %s", data)
+	}
+}
+
+func TestValidateInstances(t *testing.T) {
+	if err := ValidateInstances([]string{"10.0.1.5:8482", "node-1"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := ValidateInstances([]string{" "}); err == nil {
+		t.Fatal("expected empty instance to be rejected")
+	}
+	if err := ValidateInstances([]string{"node\n1"}); err == nil {
+		t.Fatal("expected control characters to be rejected")
+	}
+}
+
+func TestExecuteExport_NoDataReportsWarning(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	service := &exportServiceImpl{
+		clientFactory:   vm.NewClient,
+		archiveWriter:   archive.NewWriter(t.TempDir()),
+		vmGatherVersion: "test",
+	}
+	config := domain.ExportConfig{
+		Connection: domain.VMConnection{URL: srv.URL},
+		TimeRange: domain.TimeRange{
+			Start: time.Now().Add(-time.Minute),
+			End:   time.Now(),
+		},
+		Jobs:              []string{"missing"},
+		StagingDir:        t.TempDir(),
+		MetricStepSeconds: 30,
+	}
+
+	result, err := service.ExecuteExport(context.Background(), config)
+	if err != nil {
+		t.Fatalf("ExecuteExport failed: %v", err)
+	}
+	if result.MetricsExported != 0 {
+		t.Fatalf("expected no metrics, got %d", result.MetricsExported)
+	}
+	if len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], "matched no series") {
+		t.Fatalf("expected no-series warning, got %v", result.Warnings)
+	}
+}
+
+// prefixObfuscator is a custom pseudonymization backend used to verify injection
+type prefixObfuscator struct {
+	instances map[string]string
+}
+
+func (p *prefixObfuscator) ObfuscateInstance(instance string) string {
+	p.instances[instance] = "custom-" + instance
+	return p.instances[instance]
+}
+
+func (p *prefixObfuscator) ObfuscateJob(job string, component string) string {
+	return "custom-job"
+}
+
+func (p *prefixObfuscator) ObfuscateCustomLabel(labelName, value string) string {
+	return "custom-" + labelName
+}
+
+func (p *prefixObfuscator) GetMappings() (instanceMap, jobMap map[string]string) {
+	return p.instances, map[string]string{}
+}
+
+func TestExecuteExport_UsesInjectedObfuscator(t *testing.T) {
+	exportBody := `{"metric":{"__name__":"up","job":"vmstorage","instance":"10.0.0.1:8482","pod":"pod-a"},"values":[1],"timestamps":[1]}` + "\n"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, exportBody)
+	}))
+	defer srv.Close()
+
+	created := 0
+	service := NewExportServiceWithObfuscator(t.TempDir(), "test", func() obfuscation.Obfuscator {
+		created++
+		return &prefixObfuscator{instances: map[string]string{}}
+	})
+	config := domain.ExportConfig{
+		Connection: domain.VMConnection{URL: srv.URL},
+		TimeRange: domain.TimeRange{
+			Start: time.Now().Add(-time.Minute),
+			End:   time.Now(),
+		},
+		StagingDir:        t.TempDir(),
+		MetricStepSeconds: 30,
+		KeepStaging:       true,
+		Obfuscation: domain.ObfuscationConfig{
+			Enabled:           true,
+			ObfuscateInstance: true,
+			ObfuscateJob:      true,
+			CustomLabels:      []string{"pod"},
+		},
+	}
+
+	result, err := service.ExecuteExport(context.Background(), config)
+	if err != nil {
+		t.Fatalf("ExecuteExport failed: %v", err)
+	}
+	if created != 1 {
+		t.Fatalf("expected one obfuscator per export, got %d", created)
+	}
+	data, err := os.ReadFile(result.StagingPath)
+	if err != nil {
+		t.Fatalf("failed to read staging file: %v", err)
+	}
+	for _, want := range []string{`"instance":"custom-10.0.0.1:8482"`, `"job":"custom-job"`, `"pod":"custom-pod"`} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("expected %s in exported data, got %s", want, data)
+		}
+	}
+}
+
+func TestExecuteExport_IncludeReproduceScript(t *testing.T) {
+	exportBody := `{"metric":{"__name__":"up","job":"vmstorage"},"values":[1],"timestamps":[1]}` + "\n"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, exportBody)
+	}))
+	defer srv.Close()
+
+	service := &exportServiceImpl{
+		clientFactory:   vm.NewClient,
+		archiveWriter:   archive.NewWriter(t.TempDir()),
+		vmGatherVersion: "test",
+	}
+	start := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	config := domain.ExportConfig{
+		Connection: domain.VMConnection{
+			URL:  srv.URL,
+			Auth: domain.AuthConfig{Type: domain.AuthTypeBearer, Token: "secret-token"},
+		},
+		TimeRange:         domain.TimeRange{Start: start, End: start.Add(5 * time.Minute)},
+		Jobs:              []string{"vmstorage"},
+		StagingDir:        t.TempDir(),
+		MetricStepSeconds: 30,
+		IncludeReproduce:  true,
+		Webhook: &domain.WebhookTarget{
+			URL:  "https://hooks.example.com/vmgather",
+			Auth: domain.AuthConfig{Type: domain.AuthTypeBasic, Username: "hook-user", Password: "hook-password"},
+		},
+	}
+
+	result, err := service.ExecuteExport(context.Background(), config)
+	if err != nil {
+		t.Fatalf("ExecuteExport failed: %v", err)
+	}
+	zr, err := zip.OpenReader(result.ArchivePath)
+	if err != nil {
+		t.Fatalf("failed to open archive: %v", err)
+	}
+	defer func() { _ = zr.Close() }()
+
+	var script string
+	for _, f := range zr.File {
+		if f.Name != "reproduce.sh" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("failed to open reproduce.sh: %v", err)
+		}
+		data, _ := io.ReadAll(rc)
+		_ = rc.Close()
+		script = string(data)
+	}
+	if script == "" {
+		t.Fatal("expected reproduce.sh in archive")
+	}
+	for _, want := range []string{
+		`match[]={job=~"vmstorage"}`,
+		"start=2026-03-01T10:00:00Z",
+		"end=2026-03-01T10:05:00Z",
+		`"metric_step_seconds": 30`,
+	} {
+		if !strings.Contains(script, want) {
+			t.Fatalf("expected %q in reproduce.sh:\n%s", want, script)
+		}
+	}
+	if strings.Contains(script, "secret-token") || strings.Contains(script, srv.URL) {
+		t.Fatalf("reproduce.sh must not contain credentials or the source URL:\n%s", script)
+	}
+	for _, leaked := range []string{"hook-user", "hook-password", "hooks.example.com", `"webhook"`} {
+		if strings.Contains(script, leaked) {
+			t.Fatalf("reproduce.sh must not contain the webhook or its credentials (%q):\n%s", leaked, script)
+		}
+	}
+
+	// vmalert is another endpoint of the source environment
+	if got := reproduceConfigJSON(domain.ExportConfig{VMAlertURL: "http://vmalert.internal:8880"}); strings.Contains(got, "vmalert.internal") {
+		t.Fatalf("expected vmalert_url to be removed, got:\n%s", got)
+	}
+}
+
+func TestExecuteExport_AdaptiveBatchingMergesSparseWindows(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = io.WriteString(w, `{"metric":{"__name__":"up","job":"test"},"values":[1],"timestamps":[1]}`+"\n")
+	}))
+	defer srv.Close()
+
+	service := &exportServiceImpl{
+		clientFactory:   vm.NewClient,
+		archiveWriter:   archive.NewWriter(t.TempDir()),
+		vmGatherVersion: "test",
+	}
+	end := time.Now()
+	config := domain.ExportConfig{
+		Connection: domain.VMConnection{URL: srv.URL},
+		TimeRange:  domain.TimeRange{Start: end.Add(-time.Hour), End: end},
+		Batching: domain.BatchSettings{
+			Enabled:            true,
+			Strategy:           domain.BatchStrategyAdaptive,
+			CustomIntervalSecs: 60,
+		},
+		StagingDir:        t.TempDir(),
+		MetricStepSeconds: 30,
+	}
+
+	reporter := &lastProgressReporter{}
+	result, err := service.ExecuteExport(WithProgressReporter(context.Background(), reporter), config)
+	if err != nil {
+		t.Fatalf("ExecuteExport failed: %v", err)
+	}
+	// 60 one-minute windows merged as 1+2+4+8+16+29
+	if requests != 6 {
+		t.Fatalf("expected 6 merged requests for sparse data, got %d", requests)
+	}
+	if reporter.last.BatchIndex != 60 || reporter.last.TotalBatches != 60 {
+		t.Fatalf("progress must still count base windows, got %d/%d", reporter.last.BatchIndex, reporter.last.TotalBatches)
+	}
+	if result.MetricsExported != 6 {
+		t.Fatalf("expected one series per request, got %d", result.MetricsExported)
+	}
+}
+
+type lastProgressReporter struct {
+	last BatchProgress
+}
+
+func (r *lastProgressReporter) OnBatchComplete(progress BatchProgress) {
+	r.last = progress
+}
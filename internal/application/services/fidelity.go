@@ -0,0 +1,45 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+)
+
+// validateLookbehind rejects negative lookbehind_seconds values
+func validateLookbehind(seconds int) error {
+	if seconds < 0 {
+		return fmt.Errorf("lookbehind_seconds must not be negative, got %d", seconds)
+	}
+	return nil
+}
+
+// fidelityLog records the data source of every fetched batch for archive metadata.
+// Consecutive batches fetched the same way are merged into one run to keep metadata small.
+type fidelityLog struct {
+	runs []domain.BatchFidelity
+}
+
+func (f *fidelityLog) record(window domain.TimeRange, batches int, source domain.DataSource, config domain.ExportConfig) {
+	entry := domain.BatchFidelity{
+		Start:   window.Start,
+		End:     window.End,
+		Batches: batches,
+		Source:  source,
+		Raw:     source == domain.DataSourceExport,
+	}
+	if source == domain.DataSourceQueryRange {
+		entry.StepSeconds = int(determineQueryRangeStep(window, config.MetricStepSeconds).Seconds())
+		entry.LookbehindSeconds = config.LookbehindSeconds
+	}
+	if n := len(f.runs); n > 0 {
+		last := &f.runs[n-1]
+		if last.End.Equal(entry.Start) && last.Source == entry.Source &&
+			last.StepSeconds == entry.StepSeconds && last.LookbehindSeconds == entry.LookbehindSeconds {
+			last.End = entry.End
+			last.Batches += entry.Batches
+			return
+		}
+	}
+	f.runs = append(f.runs, entry)
+}
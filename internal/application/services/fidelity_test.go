@@ -0,0 +1,125 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/archive"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+func TestExecuteExport_RecordsFidelityPerBatch(t *testing.T) {
+	end := time.Now().UTC().Truncate(time.Minute)
+	start := end.Add(-4 * time.Minute)
+	// /api/v1/export disappears halfway through, e.g. a vmauth route change during a long export
+	cutover := start.Add(2 * time.Minute)
+
+	var mu sync.Mutex
+	var lookbehinds []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		switch r.URL.Path {
+		case "/api/v1/export":
+			batchStart, _ := time.Parse(time.RFC3339, r.FormValue("start"))
+			if !batchStart.Before(cutover) {
+				http.Error(w, "missing route", http.StatusNotFound)
+				return
+			}
+			_, _ = fmt.Fprintf(w, `{"metric":{"__name__":"up","job":"test"},"values":[1],"timestamps":[%d]}`+"\n", batchStart.UnixMilli())
+		case "/api/v1/query_range":
+			mu.Lock()
+			lookbehinds = append(lookbehinds, r.FormValue("max_lookback"))
+			mu.Unlock()
+			_, _ = fmt.Fprintf(w, `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"__name__":"up","job":"test"},"values":[[%s,"1"]]}]}}`, r.FormValue("start"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	service := &exportServiceImpl{
+		clientFactory:   vm.NewClient,
+		archiveWriter:   archive.NewWriter(t.TempDir()),
+		vmGatherVersion: "test",
+	}
+	config := domain.ExportConfig{
+		Connection:        domain.VMConnection{URL: srv.URL},
+		TimeRange:         domain.TimeRange{Start: start, End: end},
+		Jobs:              []string{"test"},
+		Batching:          domain.BatchSettings{Enabled: true, Strategy: "custom", CustomIntervalSecs: 60},
+		StagingDir:        t.TempDir(),
+		MetricStepSeconds: 30,
+		LookbehindSeconds: 30,
+	}
+
+	result, err := service.ExecuteExport(context.Background(), config)
+	if err != nil {
+		t.Fatalf("ExecuteExport failed: %v", err)
+	}
+	zr, err := zip.OpenReader(result.ArchivePath)
+	if err != nil {
+		t.Fatalf("failed to open archive: %v", err)
+	}
+	defer func() { _ = zr.Close() }()
+
+	var metadata struct {
+		Fidelity []domain.BatchFidelity `json:"fidelity"`
+	}
+	var readme string
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("failed to open %s: %v", f.Name, err)
+		}
+		switch f.Name {
+		case "metadata.json":
+			err = json.NewDecoder(rc).Decode(&metadata)
+		case "README.txt":
+			var data []byte
+			data, err = io.ReadAll(rc)
+			readme = string(data)
+		}
+		_ = rc.Close()
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", f.Name, err)
+		}
+	}
+
+	want := []domain.BatchFidelity{
+		{Start: start, End: cutover, Batches: 2, Source: domain.DataSourceExport, Raw: true},
+		{Start: cutover, End: end, Batches: 2, Source: domain.DataSourceQueryRange, StepSeconds: 30, LookbehindSeconds: 30},
+	}
+	if len(metadata.Fidelity) != len(want) {
+		t.Fatalf("expected %d fidelity runs, got %+v", len(want), metadata.Fidelity)
+	}
+	for i, run := range metadata.Fidelity {
+		if !run.Start.Equal(want[i].Start) || !run.End.Equal(want[i].End) {
+			t.Fatalf("run %d: expected %s - %s, got %s - %s", i, want[i].Start, want[i].End, run.Start, run.End)
+		}
+		run.Start, run.End = want[i].Start, want[i].End
+		if run != want[i] {
+			t.Fatalf("run %d: expected %+v, got %+v", i, want[i], run)
+		}
+	}
+	if len(lookbehinds) != 2 || lookbehinds[0] != "30s" || lookbehinds[1] != "30s" {
+		t.Fatalf("expected max_lookback=30s on both query_range batches, got %v", lookbehinds)
+	}
+	if !strings.Contains(readme, "QUERY_RANGE-DERIVED DATA") || !strings.Contains(readme, "2 batch window(s)") {
+		t.Fatalf("expected README to warn about query_range batches, got:\n%s", readme)
+	}
+
+	config.LookbehindSeconds = -1
+	if _, err := service.ExecuteExport(context.Background(), config); err == nil {
+		t.Fatal("expected negative lookbehind_seconds to be rejected")
+	}
+}
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+// csvHeader is the first row of metrics.csv: one row per sample
+var csvHeader = []string{"name", "labels", "timestamp_ms", "value"}
+
+// defaultFormatQueueSize is how many series an extra format's encoder may lag behind the JSONL writer
+const defaultFormatQueueSize = 256
+
+// normalizeFormats validates the requested output formats and returns them deduplicated,
+// with JSONL always first: metrics.jsonl is the canonical format used for staging and import.
+func normalizeFormats(formats []string) ([]string, error) {
+	result := []string{domain.OutputFormatJSONL}
+	for _, format := range formats {
+		format = strings.ToLower(strings.TrimSpace(format))
+		switch format {
+		case domain.OutputFormatJSONL:
+		case domain.OutputFormatCSV:
+			if !containsString(result, format) {
+				result = append(result, format)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported format %q (use %q or %q)", format, domain.OutputFormatJSONL, domain.OutputFormatCSV)
+		}
+	}
+	return result, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// csvStagingPath returns the CSV staging file that sits next to the JSONL staging file
+func csvStagingPath(stagingFile string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(stagingFile, ".gz"), ".jsonl") + ".csv"
+}
+
+// csvSink writes processed series as CSV rows into a staging file alongside the JSONL staging file.
+// Rows are encoded on the sink's own goroutine, so the JSONL writer does not wait for CSV formatting.
+// The queue is bounded, which applies backpressure, and has a single consumer, so every series
+// reaches metrics.csv exactly once and in the same order as metrics.jsonl.
+type csvSink struct {
+	file   *os.File
+	buffer *bufio.Writer
+	writer *csv.Writer
+	queue  chan csvSeries
+	done   chan struct{}
+	err    error // first encoding error, owned by the encoder goroutine until done is closed
+	closed bool
+}
+
+// csvSeries is one queued series, or a flush request when flushed is set
+type csvSeries struct {
+	labels     map[string]string
+	values     []float64
+	timestamps []int64
+	flushed    chan error
+}
+
+// openCSVSink opens the CSV staging file and starts its encoder; on resume rows are appended
+// and the header is not repeated. queueSize <= 0 uses defaultFormatQueueSize.
+func openCSVSink(path string, resume bool, queueSize int) (*csvSink, error) {
+	flags := os.O_CREATE | os.O_WRONLY
+	if resume {
+		flags |= os.O_APPEND
+	} else {
+		flags |= os.O_TRUNC
+	}
+	file, err := os.OpenFile(path, flags, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CSV staging file: %w", err)
+	}
+	buffer := bufio.NewWriter(file)
+	sink := &csvSink{file: file, buffer: buffer, writer: csv.NewWriter(buffer)}
+	if info, err := file.Stat(); err == nil && info.Size() == 0 {
+		if err := sink.writer.Write(csvHeader); err != nil {
+			_ = file.Close()
+			return nil, fmt.Errorf("failed to write CSV header: %w", err)
+		}
+	}
+	if queueSize <= 0 {
+		queueSize = defaultFormatQueueSize
+	}
+	sink.queue = make(chan csvSeries, queueSize)
+	sink.done = make(chan struct{})
+	go sink.run()
+	return sink, nil
+}
+
+// writeSeries queues a series for encoding, blocking while the queue is full. The caller must not
+// modify labels, values or timestamps afterwards. Encoding errors are returned by flush and close.
+func (c *csvSink) writeSeries(labels map[string]string, values []float64, timestamps []int64) error {
+	if c == nil {
+		return nil
+	}
+	c.queue <- csvSeries{labels: labels, values: values, timestamps: timestamps}
+	return nil
+}
+
+// run encodes queued series in order until the queue is closed
+func (c *csvSink) run() {
+	defer close(c.done)
+	for series := range c.queue {
+		if series.flushed != nil {
+			series.flushed <- c.flushWriter()
+			continue
+		}
+		if c.err == nil {
+			c.err = c.encode(series.labels, series.values, series.timestamps)
+		}
+	}
+}
+
+// encode writes one row per sample; staleness markers (null values) become empty cells
+func (c *csvSink) encode(labels map[string]string, values []float64, timestamps []int64) error {
+	name := labels["__name__"]
+	formatted := formatCSVLabels(labels)
+	for i, ts := range timestamps {
+		value := ""
+		if i < len(values) {
+			value = formatCSVValue(values[i])
+		}
+		if err := c.writer.Write([]string{name, formatted, strconv.FormatInt(ts, 10), value}); err != nil {
+			return fmt.Errorf("CSV write error: %w", err)
+		}
+	}
+	return nil
+}
+
+// flush waits until every queued series is encoded and written to the staging file
+func (c *csvSink) flush() error {
+	if c == nil {
+		return nil
+	}
+	flushed := make(chan error, 1)
+	c.queue <- csvSeries{flushed: flushed}
+	return <-flushed
+}
+
+func (c *csvSink) flushWriter() error {
+	if c.err != nil {
+		return c.err
+	}
+	c.writer.Flush()
+	if err := c.writer.Error(); err != nil {
+		return err
+	}
+	return c.buffer.Flush()
+}
+
+// close drains the queue, stops the encoder and closes the staging file; later calls are no-ops
+func (c *csvSink) close() error {
+	if c == nil || c.closed {
+		return nil
+	}
+	c.closed = true
+	close(c.queue)
+	<-c.done
+	flushErr := c.flushWriter()
+	closeErr := c.file.Close()
+	if flushErr != nil {
+		return flushErr
+	}
+	return closeErr
+}
+
+// formatCSVLabels renders the labels except __name__ as a sorted {k="v",...} selector.
+// CSV always holds absolute values, so the counter encoding marker is left out.
+func formatCSVLabels(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		if name != "__name__" && name != domain.CounterEncodingLabel {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s=%q", name, labels[name]))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func formatCSVValue(value float64) string {
+	if vm.IsStaleNaN(value) {
+		return ""
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
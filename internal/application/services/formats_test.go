@@ -0,0 +1,208 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/archive"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+func TestNormalizeFormats(t *testing.T) {
+	formats, err := normalizeFormats([]string{"CSV", "jsonl", "csv"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(formats) != 2 || formats[0] != "jsonl" || formats[1] != "csv" {
+		t.Fatalf("expected [jsonl csv], got %v", formats)
+	}
+	if formats, _ := normalizeFormats(nil); len(formats) != 1 || formats[0] != "jsonl" {
+		t.Fatalf("expected jsonl by default, got %v", formats)
+	}
+	if _, err := normalizeFormats([]string{"parquet"}); err == nil {
+		t.Fatal("expected unknown format to be rejected")
+	}
+}
+
+func TestExecuteExport_JSONLAndCSVFromOneQuery(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = io.WriteString(w, `{"metric":{"__name__":"http_requests_total","job":"api","path":"/a,b"},"values":[10,15,21],"timestamps":[1000,2000,3000]}`+"\n"+
+			`{"metric":{"__name__":"temperature","job":"api"},"values":[0.5,null],"timestamps":[1000,2000]}`+"\n")
+	}))
+	defer srv.Close()
+
+	service := &exportServiceImpl{
+		clientFactory:   vm.NewClient,
+		archiveWriter:   archive.NewWriter(t.TempDir()),
+		vmGatherVersion: "test",
+	}
+	config := domain.ExportConfig{
+		Connection:        domain.VMConnection{URL: srv.URL},
+		TimeRange:         domain.TimeRange{Start: time.Now().Add(-5 * time.Minute), End: time.Now()},
+		StagingDir:        t.TempDir(),
+		MetricStepSeconds: 30,
+		Formats:           []string{"jsonl", "csv"},
+		CounterEncoding:   domain.CounterEncodingDelta,
+	}
+
+	result, err := service.ExecuteExport(context.Background(), config)
+	if err != nil {
+		t.Fatalf("ExecuteExport failed: %v", err)
+	}
+	if requests != 1 {
+		t.Fatalf("expected a single export request, got %d", requests)
+	}
+	zr, err := zip.OpenReader(result.ArchivePath)
+	if err != nil {
+		t.Fatalf("failed to open archive: %v", err)
+	}
+	defer func() { _ = zr.Close() }()
+
+	var jsonlSamples int
+	var rows [][]string
+	var metadata struct {
+		Formats []string `json:"formats"`
+	}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("failed to open %s: %v", f.Name, err)
+		}
+		switch f.Name {
+		case "metrics.jsonl":
+			decoder := vm.NewExportDecoder(rc)
+			for {
+				metric, err := decoder.Decode()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("failed to decode metrics.jsonl: %v", err)
+				}
+				jsonlSamples += len(metric.Timestamps)
+			}
+		case "metrics.csv":
+			rows, err = csv.NewReader(rc).ReadAll()
+		case "metadata.json":
+			err = json.NewDecoder(rc).Decode(&metadata)
+		}
+		_ = rc.Close()
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", f.Name, err)
+		}
+	}
+
+	if len(metadata.Formats) != 2 || metadata.Formats[1] != "csv" {
+		t.Fatalf("expected both formats in metadata, got %v", metadata.Formats)
+	}
+	if len(rows) == 0 || len(rows)-1 != jsonlSamples {
+		t.Fatalf("expected %d CSV rows after the header, got %d", jsonlSamples, len(rows)-1)
+	}
+	want := [][]string{
+		{"name", "labels", "timestamp_ms", "value"},
+		{"http_requests_total", `{job="api",path="/a,b"}`, "1000", "10"},
+		{"http_requests_total", `{job="api",path="/a,b"}`, "2000", "15"},
+		{"http_requests_total", `{job="api",path="/a,b"}`, "3000", "21"},
+		{"temperature", `{job="api"}`, "1000", "0.5"},
+		{"temperature", `{job="api"}`, "2000", ""},
+	}
+	// Counters are delta-encoded in JSONL but stay absolute in CSV
+	for i, row := range want {
+		for j := range row {
+			if rows[i][j] != row[j] {
+				t.Fatalf("row %d: expected %v, got %v", i, row, rows[i])
+			}
+		}
+	}
+	entries, _ := os.ReadDir(config.StagingDir)
+	if len(entries) != 0 {
+		t.Fatalf("expected staging files to be removed, found %d", len(entries))
+	}
+}
+
+func TestExecuteExport_FormatsReceiveEverySeriesOnceInOrder(t *testing.T) {
+	const seriesCount = 500
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < seriesCount; i++ {
+			_, _ = fmt.Fprintf(w, `{"metric":{"__name__":"load","job":"api","shard":"%d"},"values":[%d],"timestamps":[1000]}`+"\n", i, i)
+		}
+	}))
+	defer srv.Close()
+
+	service := &exportServiceImpl{
+		clientFactory:   vm.NewClient,
+		archiveWriter:   archive.NewWriter(t.TempDir()),
+		vmGatherVersion: "test",
+	}
+	config := domain.ExportConfig{
+		Connection:        domain.VMConnection{URL: srv.URL},
+		TimeRange:         domain.TimeRange{Start: time.Now().Add(-5 * time.Minute), End: time.Now()},
+		StagingDir:        t.TempDir(),
+		MetricStepSeconds: 30,
+		Formats:           []string{"csv"},
+		// A one-series queue makes the JSONL writer wait for the CSV encoder on almost every series
+		FormatQueueSize: 1,
+	}
+	result, err := service.ExecuteExport(context.Background(), config)
+	if err != nil {
+		t.Fatalf("ExecuteExport failed: %v", err)
+	}
+
+	zr, err := zip.OpenReader(result.ArchivePath)
+	if err != nil {
+		t.Fatalf("failed to open archive: %v", err)
+	}
+	defer func() { _ = zr.Close() }()
+	var jsonlShards, csvShards []string
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("failed to open %s: %v", f.Name, err)
+		}
+		switch f.Name {
+		case "metrics.jsonl":
+			decoder := vm.NewExportDecoder(rc)
+			for {
+				metric, err := decoder.Decode()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("decode: %v", err)
+				}
+				jsonlShards = append(jsonlShards, metric.Metric["shard"])
+			}
+		case "metrics.csv":
+			rows, err := csv.NewReader(rc).ReadAll()
+			if err != nil {
+				t.Fatalf("read csv: %v", err)
+			}
+			for _, row := range rows[1:] {
+				csvShards = append(csvShards, row[1])
+			}
+		}
+		_ = rc.Close()
+	}
+
+	if len(jsonlShards) != seriesCount || len(csvShards) != seriesCount {
+		t.Fatalf("expected %d series in both formats, got jsonl=%d csv=%d", seriesCount, len(jsonlShards), len(csvShards))
+	}
+	for i := range jsonlShards {
+		if want := fmt.Sprintf(`{job="api",shard="%s"}`, jsonlShards[i]); csvShards[i] != want || jsonlShards[i] != strconv.Itoa(i) {
+			t.Fatalf("series %d differs or is out of order: jsonl shard %s, csv %s", i, jsonlShards[i], csvShards[i])
+		}
+	}
+}
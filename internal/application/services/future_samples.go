@@ -0,0 +1,102 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+// futureGuard drops or clamps samples timestamped after now+max_future_skew_seconds,
+// which clock-skewed sources produce and which would pollute analysis clusters
+type futureGuard struct {
+	skewSeconds int
+	policy      domain.FutureSamplePolicy
+	limitMs     int64
+	points      int64
+	series      int64
+}
+
+// newFutureGuard returns nil when max_future_skew_seconds is not positive, which disables the guard
+func newFutureGuard(config domain.ExportConfig, now time.Time) *futureGuard {
+	if config.MaxFutureSkewSeconds <= 0 {
+		return nil
+	}
+	policy := config.FutureSamples
+	if policy == "" {
+		policy = domain.FutureSamplesDrop
+	}
+	limit := now.Add(time.Duration(config.MaxFutureSkewSeconds) * time.Second)
+	return &futureGuard{skewSeconds: config.MaxFutureSkewSeconds, policy: policy, limitMs: limit.UnixMilli()}
+}
+
+// validateFutureSamples rejects negative skews and unknown future_samples values
+func validateFutureSamples(config domain.ExportConfig) error {
+	if config.MaxFutureSkewSeconds < 0 {
+		return fmt.Errorf("max_future_skew_seconds must not be negative, got %d", config.MaxFutureSkewSeconds)
+	}
+	switch config.FutureSamples {
+	case "", domain.FutureSamplesDrop, domain.FutureSamplesClamp:
+		return nil
+	default:
+		return fmt.Errorf("unsupported future_samples %q (use %q or %q)", config.FutureSamples, domain.FutureSamplesDrop, domain.FutureSamplesClamp)
+	}
+}
+
+// apply removes the series' future samples; with clamp the latest of them is kept at the limit,
+// so the series still ends with its most recent value without reaching into the future
+func (g *futureGuard) apply(metric *vm.ExportedMetric) {
+	if g == nil || len(metric.Values) != len(metric.Timestamps) {
+		return
+	}
+	kept := 0
+	future := 0
+	var latest float64
+	var latestTs int64
+	for i, ts := range metric.Timestamps {
+		if ts > g.limitMs {
+			future++
+			if ts >= latestTs {
+				latest, latestTs = metric.Values[i], ts
+			}
+			continue
+		}
+		metric.Values[kept] = metric.Values[i]
+		metric.Timestamps[kept] = ts
+		kept++
+	}
+	if future == 0 {
+		return
+	}
+	g.points += int64(future)
+	g.series++
+	metric.Values = metric.Values[:kept]
+	metric.Timestamps = metric.Timestamps[:kept]
+	if g.policy == domain.FutureSamplesClamp && (kept == 0 || metric.Timestamps[kept-1] < g.limitMs) {
+		metric.Values = append(metric.Values, latest)
+		metric.Timestamps = append(metric.Timestamps, g.limitMs)
+	}
+}
+
+// futureSampleVerb describes what the policy did to future samples
+func futureSampleVerb(policy domain.FutureSamplePolicy) string {
+	if policy == domain.FutureSamplesClamp {
+		return "clamped"
+	}
+	return "dropped"
+}
+
+// summary describes the guarded samples for archive metadata; nil when nothing was affected
+func (g *futureGuard) summary() *domain.FutureSampleSummary {
+	if g == nil || g.points == 0 {
+		return nil
+	}
+	return &domain.FutureSampleSummary{
+		MaxFutureSkewSeconds: g.skewSeconds,
+		Policy:               g.policy,
+		Limit:                time.UnixMilli(g.limitMs).UTC(),
+		Points:               g.points,
+		Series:               g.series,
+	}
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+)
+
+func TestProcessMetricsFutureSamples(t *testing.T) {
+	now := time.UnixMilli(10_000)
+	// 75s and 90s are beyond now+60s; series b only has future samples
+	input := `{"metric":{"__name__":"up","job":"a"},"values":[1,2,3],"timestamps":[5000,75000,90000]}` + "\n" +
+		`{"metric":{"__name__":"up","job":"b"},"values":[7],"timestamps":[80000]}` + "\n"
+	tests := []struct {
+		policy    domain.FutureSamplePolicy
+		wantCount int
+		want      []string
+	}{
+		{policy: domain.FutureSamplesDrop, wantCount: 1, want: []string{`"values":[1],"timestamps":[5000]`}},
+		{policy: domain.FutureSamplesClamp, wantCount: 2, want: []string{
+			`"values":[1,3],"timestamps":[5000,70000]`,
+			`"values":[7],"timestamps":[70000]`,
+		}},
+	}
+	service := &exportServiceImpl{}
+	for _, tt := range tests {
+		guard := newFutureGuard(domain.ExportConfig{MaxFutureSkewSeconds: 60, FutureSamples: tt.policy}, now)
+		var out bytes.Buffer
+		count, err := service.processMetricsIntoWriter(strings.NewReader(input), domain.ObfuscationConfig{}, nil, processOptions{future: guard}, &out)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.policy, err)
+		}
+		if count != tt.wantCount {
+			t.Fatalf("%s: expected %d series, got %d", tt.policy, tt.wantCount, count)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(out.String(), want) {
+				t.Fatalf("%s: expected output to contain %s, got %s", tt.policy, want, out.String())
+			}
+		}
+		summary := guard.summary()
+		if summary == nil || summary.Points != 3 || summary.Series != 2 || summary.Policy != tt.policy {
+			t.Fatalf("%s: unexpected summary %+v", tt.policy, summary)
+		}
+	}
+
+	if newFutureGuard(domain.ExportConfig{}, now) != nil {
+		t.Fatal("expected the guard to be disabled without max_future_skew_seconds")
+	}
+	if err := validateFutureSamples(domain.ExportConfig{FutureSamples: "shift"}); err == nil {
+		t.Fatal("expected unknown future_samples value to be rejected")
+	}
+	if err := validateFutureSamples(domain.ExportConfig{MaxFutureSkewSeconds: -1}); err == nil {
+		t.Fatal("expected negative max_future_skew_seconds to be rejected")
+	}
+}
@@ -0,0 +1,39 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+)
+
+// validateHistogramMode rejects unknown histogram_mode values
+func validateHistogramMode(mode domain.HistogramMode) error {
+	switch mode {
+	case "", domain.HistogramModePreserve, domain.HistogramModeCompact:
+		return nil
+	default:
+		return fmt.Errorf("unsupported histogram_mode %q (use %q or %q)", mode, domain.HistogramModePreserve, domain.HistogramModeCompact)
+	}
+}
+
+// isVMRangeBucket reports whether labels describe a VictoriaMetrics histogram bucket.
+// Unlike Prometheus `le` buckets, vmrange buckets are not cumulative: each one counts
+// only its own range, so a bucket without observations carries no information and
+// histogram_quantile results do not depend on it being present.
+func isVMRangeBucket(labels map[string]string) bool {
+	if _, exists := labels["vmrange"]; !exists {
+		return false
+	}
+	return strings.HasSuffix(labels["__name__"], "_bucket")
+}
+
+// allZeroValues reports whether every sample value is zero
+func allZeroValues(values []float64) bool {
+	for _, v := range values {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+)
+
+func TestProcessMetricsIntoWriter_HistogramModes(t *testing.T) {
+	input := strings.Join([]string{
+		`{"metric":{"__name__":"req_duration_bucket","vmrange":"1.000e-01...1.136e-01"},"values":[3,5],"timestamps":[1000,2000]}`,
+		`{"metric":{"__name__":"req_duration_bucket","vmrange":"1.136e-01...1.292e-01"},"values":[0,0],"timestamps":[1000,2000]}`,
+		`{"metric":{"__name__":"prom_duration_bucket","le":"0.1"},"values":[0,0],"timestamps":[1000,2000]}`,
+		`{"metric":{"__name__":"prom_duration_bucket","le":"+Inf"},"values":[4,7],"timestamps":[1000,2000]}`,
+		`{"metric":{"__name__":"req_duration_count"},"values":[0,0],"timestamps":[1000,2000]}`,
+	}, "\n") + "\n"
+
+	tests := []struct {
+		mode     domain.HistogramMode
+		expected int
+		dropped  string
+	}{
+		{mode: "", expected: 5},
+		{mode: domain.HistogramModePreserve, expected: 5},
+		// Only the empty vmrange bucket goes away; cumulative le buckets are always kept.
+		{mode: domain.HistogramModeCompact, expected: 4, dropped: `"vmrange":"1.136e-01...1.292e-01"`},
+	}
+
+	service := &exportServiceImpl{}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		count, err := service.processMetricsIntoWriter(strings.NewReader(input), domain.ObfuscationConfig{}, nil, processOptions{histogramMode: tt.mode}, &out)
+		if err != nil {
+			t.Fatalf("mode %q: processMetricsIntoWriter failed: %v", tt.mode, err)
+		}
+		if count != tt.expected {
+			t.Fatalf("mode %q: expected %d series, got %d", tt.mode, tt.expected, count)
+		}
+		if tt.dropped != "" && strings.Contains(out.String(), tt.dropped) {
+			t.Fatalf("mode %q: expected %s to be dropped, got:\n%s", tt.mode, tt.dropped, out.String())
+		}
+		if !strings.Contains(out.String(), `"le":"0.1"`) {
+			t.Fatalf("mode %q: le buckets must be preserved", tt.mode)
+		}
+	}
+}
+
+func TestValidateHistogramMode(t *testing.T) {
+	for _, mode := range []domain.HistogramMode{"", domain.HistogramModePreserve, domain.HistogramModeCompact} {
+		if err := validateHistogramMode(mode); err != nil {
+			t.Fatalf("expected histogram_mode %q to be accepted, got %v", mode, err)
+		}
+	}
+	if err := validateHistogramMode("compress"); err == nil || !strings.Contains(err.Error(), "histogram_mode") {
+		t.Fatalf("expected an unknown histogram_mode to be rejected, got %v", err)
+	}
+}
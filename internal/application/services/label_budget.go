@@ -0,0 +1,112 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+// validateCardinalityBudget rejects negative budgets and modes that write series before the whole
+// export is measured, which would leak the labels the budget is meant to drop
+func validateCardinalityBudget(config domain.ExportConfig, formats []string) error {
+	if config.CardinalityBudget < 0 {
+		return fmt.Errorf("label_cardinality_budget must not be negative, got %d", config.CardinalityBudget)
+	}
+	if config.CardinalityBudget == 0 {
+		return nil
+	}
+	if config.ArchivePerBatch {
+		return fmt.Errorf("label_cardinality_budget cannot be combined with archive_per_batch")
+	}
+	if containsString(formats, domain.OutputFormatCSV) {
+		return fmt.Errorf("label_cardinality_budget cannot be combined with the csv format")
+	}
+	return nil
+}
+
+// measureLabelBudget is the first pass over the staging file: it counts distinct values per label
+// and returns the labels above the budget. Counting stops at budget+1 values, so memory stays
+// bounded by the budget whatever the cardinality. The metric name is never dropped.
+func measureLabelBudget(config domain.ExportConfig) (*domain.LabelBudgetSummary, error) {
+	reader, err := openStagingReader(config.StagingFile, config.StagingGzip)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open staging file for label_cardinality_budget: %w", err)
+	}
+	defer func() { _ = reader.Close() }()
+
+	values := make(map[string]map[string]struct{})
+	over := make(map[string]bool)
+	decoder := vm.NewExportDecoderSize(reader, config.MaxLineBytes)
+	for {
+		metric, err := decoder.Decode()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to measure label cardinality: %w", err)
+		}
+		for label, value := range metric.Metric {
+			if label == "__name__" || over[label] {
+				continue
+			}
+			seen := values[label]
+			if seen == nil {
+				seen = make(map[string]struct{})
+				values[label] = seen
+			}
+			seen[value] = struct{}{}
+			if len(seen) > config.CardinalityBudget {
+				over[label] = true
+				delete(values, label)
+			}
+		}
+	}
+
+	summary := &domain.LabelBudgetSummary{Budget: config.CardinalityBudget, DroppedLabels: []string{}}
+	for label := range over {
+		summary.DroppedLabels = append(summary.DroppedLabels, label)
+	}
+	sort.Strings(summary.DroppedLabels)
+	return summary, nil
+}
+
+// dropBudgetLabels is the second pass: it streams the staging data with the dropped labels removed.
+// Series that differed only in those labels keep separate lines with equal label sets.
+func dropBudgetLabels(reader io.ReadCloser, labels []string, lineLimit int) io.ReadCloser {
+	if len(labels) == 0 {
+		return reader
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		defer func() { _ = reader.Close() }()
+		decoder := vm.NewExportDecoderSize(reader, lineLimit)
+		for {
+			metric, err := decoder.Decode()
+			if errors.Is(err, io.EOF) {
+				_ = pw.Close()
+				return
+			}
+			if err != nil {
+				_ = pw.CloseWithError(fmt.Errorf("failed to drop labels over label_cardinality_budget: %w", err))
+				return
+			}
+			for _, label := range labels {
+				delete(metric.Metric, label)
+			}
+			data, err := json.Marshal(metric)
+			if err == nil {
+				_, err = pw.Write(append(data, '\n'))
+			}
+			if err != nil {
+				_ = pw.CloseWithError(err)
+				return
+			}
+		}
+	}()
+	return pr
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/archive"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+func TestExecuteExport_LabelCardinalityBudgetDropsHighCardinalityLabels(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 10; i++ {
+			_, _ = fmt.Fprintf(w, `{"metric":{"__name__":"http_requests_%d","job":"vmselect","instance":"host-%d:8481","request_id":"req-%d"},"values":[1],"timestamps":[1000]}`+"\n", i, i%2, i)
+		}
+	}))
+	defer srv.Close()
+
+	service := &exportServiceImpl{
+		clientFactory:   vm.NewClient,
+		archiveWriter:   archive.NewWriter(t.TempDir()),
+		vmGatherVersion: "test",
+	}
+	end := time.Now().Truncate(time.Minute)
+	config := domain.ExportConfig{
+		Connection:        domain.VMConnection{URL: srv.URL},
+		TimeRange:         domain.TimeRange{Start: end.Add(-time.Hour), End: end},
+		StagingDir:        t.TempDir(),
+		CardinalityBudget: 5,
+	}
+	result, err := service.ExecuteExport(context.Background(), config)
+	if err != nil {
+		t.Fatalf("ExecuteExport failed: %v", err)
+	}
+	files := readZipFiles(t, result.ArchivePath)
+	data := files["metrics.jsonl"]
+	if strings.Contains(data, "request_id") {
+		t.Fatalf("expected the high-cardinality request_id label to be dropped, got %s", data)
+	}
+	for _, want := range []string{`"job":"vmselect"`, `"instance":"host-1:8481"`, `"__name__":"http_requests_9"`} {
+		if !strings.Contains(data, want) {
+			t.Fatalf("expected %s to be kept, got %s", want, data)
+		}
+	}
+	if !strings.Contains(files["metadata.json"], `"dropped_labels": [`+"\n"+`      "request_id"`) {
+		t.Fatalf("expected metadata.json to record the dropped label, got %s", files["metadata.json"])
+	}
+	if !strings.Contains(files["README.txt"], "request_id") || len(result.Warnings) == 0 {
+		t.Fatalf("expected README.txt and a warning to name the dropped label, got warnings %v", result.Warnings)
+	}
+
+	config.Formats = []string{domain.OutputFormatCSV}
+	if _, err := service.ExecuteExport(context.Background(), config); err == nil || !strings.Contains(err.Error(), "label_cardinality_budget") {
+		t.Fatalf("expected label_cardinality_budget with csv to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+// labelNormalizer applies lowercase_label_names and trim_label_values before any other processing,
+// so drop_labels, obfuscation and the baseline all see the normalized labels
+type labelNormalizer struct {
+	lowercaseNames bool
+	trimValues     bool
+	renamed        int64
+	trimmed        int64
+	collisions     int64
+	series         int64
+}
+
+// newLabelNormalizer returns nil when neither option is set, which disables normalization
+func newLabelNormalizer(config domain.ExportConfig) *labelNormalizer {
+	if !config.LowercaseLabelNames && !config.TrimLabelValues {
+		return nil
+	}
+	return &labelNormalizer{lowercaseNames: config.LowercaseLabelNames, trimValues: config.TrimLabelValues}
+}
+
+// apply normalizes the labels of metric in place. The metric name is a value, so it is trimmed but
+// never lowercased. When lowercasing makes two names equal, a name that was already lowercase wins,
+// otherwise the first in byte order, so every series is normalized the same way.
+func (n *labelNormalizer) apply(metric *vm.ExportedMetric) {
+	if n == nil {
+		return
+	}
+	changed := false
+	if n.trimValues {
+		for name, value := range metric.Metric {
+			if trimmed := strings.TrimSpace(value); trimmed != value {
+				metric.Metric[name] = trimmed
+				n.trimmed++
+				changed = true
+			}
+		}
+	}
+	if n.lowercaseNames {
+		var mixedCase []string
+		for name := range metric.Metric {
+			if strings.ToLower(name) != name {
+				mixedCase = append(mixedCase, name)
+			}
+		}
+		sort.Strings(mixedCase)
+		for _, name := range mixedCase {
+			value := metric.Metric[name]
+			delete(metric.Metric, name)
+			lower := strings.ToLower(name)
+			if _, exists := metric.Metric[lower]; exists {
+				n.collisions++
+			} else {
+				metric.Metric[lower] = value
+				n.renamed++
+			}
+			changed = true
+		}
+	}
+	if changed {
+		n.series++
+	}
+}
+
+// summary flags the normalization in archive metadata whenever it is enabled, even if nothing changed,
+// because the archive can no longer be assumed to carry the source labels verbatim
+func (n *labelNormalizer) summary() *domain.NormalizationSummary {
+	if n == nil {
+		return nil
+	}
+	return &domain.NormalizationSummary{
+		LowercaseNames: n.lowercaseNames,
+		TrimValues:     n.trimValues,
+		NamesRenamed:   n.renamed,
+		ValuesTrimmed:  n.trimmed,
+		Collisions:     n.collisions,
+		Series:         n.series,
+	}
+}
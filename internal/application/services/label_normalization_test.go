@@ -0,0 +1,72 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+func TestProcessMetricsNormalizesLabels(t *testing.T) {
+	input := `{"metric":{"__name__":" HTTP_Requests_Total ","Job":"api ","Instance":" host:9100"},"values":[1],"timestamps":[1000]}` + "\n" +
+		`{"metric":{"__name__":"HTTP_Requests_Total","job":"api","JOB":"other","Job":"third"},"values":[2],"timestamps":[1000]}` + "\n" +
+		`{"metric":{"__name__":"up","job":"api"},"values":[1],"timestamps":[1000]}` + "\n"
+	normalizer := newLabelNormalizer(domain.ExportConfig{LowercaseLabelNames: true, TrimLabelValues: true})
+	var out bytes.Buffer
+	service := &exportServiceImpl{}
+	count, err := service.processMetricsIntoWriter(strings.NewReader(input), domain.ObfuscationConfig{DropLabels: []string{"instance"}}, nil, processOptions{normalize: normalizer}, &out)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count != 3 {
+		t.Fatalf("expected 3 series, got %d", count)
+	}
+
+	var series []vm.ExportedMetric
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var metric vm.ExportedMetric
+		if err := json.Unmarshal([]byte(line), &metric); err != nil {
+			t.Fatalf("invalid output line %q: %v", line, err)
+		}
+		series = append(series, metric)
+	}
+	// The metric name keeps its case, and drop_labels sees the lowercased name
+	if got := series[0].Metric; len(got) != 2 || got["__name__"] != "HTTP_Requests_Total" || got["job"] != "api" {
+		t.Fatalf("unexpected labels for the first series: %v", got)
+	}
+	// The existing lowercase label wins over the mixed-case ones
+	if got := series[1].Metric; len(got) != 2 || got["job"] != "api" {
+		t.Fatalf("unexpected labels for the second series: %v", got)
+	}
+	if got := series[2].Metric; len(got) != 2 || got["job"] != "api" {
+		t.Fatalf("expected the already normalized series to be unchanged, got %v", got)
+	}
+
+	summary := normalizer.summary()
+	want := domain.NormalizationSummary{LowercaseNames: true, TrimValues: true, NamesRenamed: 2, ValuesTrimmed: 3, Collisions: 2, Series: 2}
+	if summary == nil || *summary != want {
+		t.Fatalf("expected summary %+v, got %+v", want, summary)
+	}
+
+	if newLabelNormalizer(domain.ExportConfig{}) != nil {
+		t.Fatal("expected normalization to be disabled by default")
+	}
+	if summary := newLabelNormalizer(domain.ExportConfig{TrimLabelValues: true}).summary(); summary == nil || summary.Series != 0 {
+		t.Fatalf("expected an enabled normalizer to be recorded even without changes, got %+v", summary)
+	}
+}
+
+func TestLabelNormalizerIsOrderIndependent(t *testing.T) {
+	// Map iteration order is random, so repeat to catch a collision winner that depends on it
+	for i := 0; i < 50; i++ {
+		normalizer := newLabelNormalizer(domain.ExportConfig{LowercaseLabelNames: true})
+		metric := &vm.ExportedMetric{Metric: map[string]string{"__name__": "up", "Zone": "b", "ZONE": "a", "zOne": "c"}}
+		normalizer.apply(metric)
+		if metric.Metric["zone"] != "a" || len(metric.Metric) != 2 {
+			t.Fatalf("expected the first name in byte order (ZONE) to win, got %v", metric.Metric)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+// labelValueGuard enforces max_label_value_length on every label of a series, __name__ included,
+// so pathological values cannot blow up memory or break the system the archive is replayed into
+type labelValueGuard struct {
+	maxLength int
+	policy    domain.LabelValuePolicy
+	labels    map[string]struct{}
+	values    int64
+	series    int64
+}
+
+// newLabelValueGuard returns nil when max_label_value_length is not positive, which disables the guard
+func newLabelValueGuard(config domain.ExportConfig) *labelValueGuard {
+	if config.MaxLabelValueLength <= 0 {
+		return nil
+	}
+	policy := config.LongLabelValues
+	if policy == "" {
+		policy = domain.LabelValuesTruncate
+	}
+	return &labelValueGuard{maxLength: config.MaxLabelValueLength, policy: policy, labels: make(map[string]struct{})}
+}
+
+// validateLabelValueLimit rejects negative limits and unknown long_label_values values
+func validateLabelValueLimit(config domain.ExportConfig) error {
+	if config.MaxLabelValueLength < 0 {
+		return fmt.Errorf("max_label_value_length must not be negative, got %d", config.MaxLabelValueLength)
+	}
+	switch config.LongLabelValues {
+	case "", domain.LabelValuesTruncate, domain.LabelValuesDropSeries, domain.LabelValuesError:
+		return nil
+	default:
+		return fmt.Errorf("unsupported long_label_values %q (use %q, %q or %q)",
+			config.LongLabelValues, domain.LabelValuesTruncate, domain.LabelValuesDropSeries, domain.LabelValuesError)
+	}
+}
+
+// apply truncates oversized values in place and reports whether the series should be kept.
+// The error policy fails with the label name and length only; the value itself may be sensitive.
+func (g *labelValueGuard) apply(metric *vm.ExportedMetric) (bool, error) {
+	if g == nil {
+		return true, nil
+	}
+	oversized := 0
+	for name, value := range metric.Metric {
+		if len(value) <= g.maxLength {
+			continue
+		}
+		if g.policy == domain.LabelValuesError {
+			return false, fmt.Errorf("label %q has a %d-byte value, longer than max_label_value_length %d", name, len(value), g.maxLength)
+		}
+		g.labels[name] = struct{}{}
+		oversized++
+		if g.policy == domain.LabelValuesTruncate {
+			metric.Metric[name] = truncateLabelValue(value, g.maxLength)
+		}
+	}
+	if oversized == 0 {
+		return true, nil
+	}
+	g.series++
+	if g.policy == domain.LabelValuesDropSeries {
+		return false, nil
+	}
+	g.values += int64(oversized)
+	return true, nil
+}
+
+// labelValueVerb describes what the policy did to series with oversized label values
+func labelValueVerb(policy domain.LabelValuePolicy) string {
+	if policy == domain.LabelValuesDropSeries {
+		return "dropped"
+	}
+	return "truncated"
+}
+
+// truncateLabelValue cuts value to at most maxLength bytes without splitting a UTF-8 sequence
+func truncateLabelValue(value string, maxLength int) string {
+	cut := maxLength
+	for cut > 0 && !utf8.RuneStart(value[cut]) {
+		cut--
+	}
+	return value[:cut]
+}
+
+// summary describes the affected series for archive metadata; nil when nothing exceeded the limit
+func (g *labelValueGuard) summary() *domain.LabelValueSummary {
+	if g == nil || g.series == 0 {
+		return nil
+	}
+	labels := make([]string, 0, len(g.labels))
+	for name := range g.labels {
+		labels = append(labels, name)
+	}
+	sort.Strings(labels)
+	return &domain.LabelValueSummary{
+		MaxLabelValueLength: g.maxLength,
+		Policy:              g.policy,
+		Labels:              labels,
+		ValuesTruncated:     g.values,
+		Series:              g.series,
+	}
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+)
+
+func TestProcessMetricsLabelValueLimit(t *testing.T) {
+	long := strings.Repeat("x", 1000)
+	input := `{"metric":{"__name__":"up","job":"a","path":"` + long + `"},"values":[1],"timestamps":[1000]}` + "\n" +
+		`{"metric":{"__name__":"up","job":"b"},"values":[1],"timestamps":[1000]}` + "\n"
+	tests := []struct {
+		policy    domain.LabelValuePolicy
+		wantCount int
+		want      string
+		wantErr   bool
+	}{
+		{policy: domain.LabelValuesTruncate, wantCount: 2, want: `"path":"` + long[:16] + `"`},
+		{policy: domain.LabelValuesDropSeries, wantCount: 1, want: `"job":"b"`},
+		{policy: domain.LabelValuesError, wantErr: true},
+	}
+	service := &exportServiceImpl{}
+	for _, tt := range tests {
+		guard := newLabelValueGuard(domain.ExportConfig{MaxLabelValueLength: 16, LongLabelValues: tt.policy})
+		var out bytes.Buffer
+		count, err := service.processMetricsIntoWriter(strings.NewReader(input), domain.ObfuscationConfig{}, nil, processOptions{labels: guard}, &out)
+		if tt.wantErr {
+			if err == nil || !strings.Contains(err.Error(), `label "path"`) || strings.Contains(err.Error(), long) {
+				t.Fatalf("%s: expected an error naming the label without its value, got %v", tt.policy, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.policy, err)
+		}
+		if count != tt.wantCount {
+			t.Fatalf("%s: expected %d series, got %d", tt.policy, tt.wantCount, count)
+		}
+		if !strings.Contains(out.String(), tt.want) || strings.Contains(out.String(), long[:17]) {
+			t.Fatalf("%s: unexpected output %s", tt.policy, out.String())
+		}
+		summary := guard.summary()
+		if summary == nil || summary.Series != 1 || summary.Policy != tt.policy || len(summary.Labels) != 1 || summary.Labels[0] != "path" {
+			t.Fatalf("%s: unexpected summary %+v", tt.policy, summary)
+		}
+	}
+
+	if newLabelValueGuard(domain.ExportConfig{}) != nil {
+		t.Fatal("expected the guard to be disabled without max_label_value_length")
+	}
+	if err := validateLabelValueLimit(domain.ExportConfig{LongLabelValues: "hash"}); err == nil {
+		t.Fatal("expected unknown long_label_values value to be rejected")
+	}
+}
+
+func TestTruncateLabelValueKeepsUTF8(t *testing.T) {
+	if got := truncateLabelValue("añb", 2); got != "a" {
+		t.Fatalf("expected the multi-byte rune to be dropped whole, got %q", got)
+	}
+}
@@ -0,0 +1,14 @@
+package services
+
+import "fmt"
+
+// maxLineBytesLimit bounds max_line_bytes: one series line is held in memory while it is decoded
+const maxLineBytesLimit = 1 << 30
+
+// validateMaxLineBytes rejects negative and unreasonably large max_line_bytes values
+func validateMaxLineBytes(limit int) error {
+	if limit < 0 || limit > maxLineBytesLimit {
+		return fmt.Errorf("max_line_bytes must be between 0 and %d, got %d", maxLineBytesLimit, limit)
+	}
+	return nil
+}
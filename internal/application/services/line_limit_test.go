@@ -0,0 +1,50 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+func TestProcessMetricsLongLines(t *testing.T) {
+	// One series of ~2 MiB, above the former fixed 1 MiB decoder limit
+	var line strings.Builder
+	line.WriteString(`{"metric":{"__name__":"up","job":"a"},"values":[`)
+	const points = 150_000
+	for i := 0; i < points; i++ {
+		if i > 0 {
+			line.WriteByte(',')
+		}
+		line.WriteString("1")
+	}
+	line.WriteString(`],"timestamps":[`)
+	for i := 0; i < points; i++ {
+		if i > 0 {
+			line.WriteByte(',')
+		}
+		fmt.Fprintf(&line, "%d", 1_700_000_000_000+int64(i))
+	}
+	line.WriteString("]}\n")
+	input := `{"metric":{"__name__":"up","job":"b"},"values":[1],"timestamps":[1000]}` + "\n" + line.String()
+
+	service := &exportServiceImpl{}
+	var out bytes.Buffer
+	count, err := service.processMetricsIntoWriter(strings.NewReader(input), domain.ObfuscationConfig{}, nil, processOptions{}, &out)
+	if err != nil || count != 2 {
+		t.Fatalf("expected the long series to be exported with the default limit, got %d series, err %v", count, err)
+	}
+
+	_, err = service.processMetricsIntoWriter(strings.NewReader(input), domain.ObfuscationConfig{}, nil, processOptions{lineLimit: 1 << 20}, &out)
+	if !errors.Is(err, vm.ErrLineTooLong) || !strings.Contains(err.Error(), "line 2 is longer than 1048576 bytes") || !strings.Contains(err.Error(), "max_line_bytes") {
+		t.Fatalf("expected a clear error for the oversized line, got %v", err)
+	}
+
+	if err := validateMaxLineBytes(-1); err == nil {
+		t.Fatal("expected negative max_line_bytes to be rejected")
+	}
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+)
+
+// maxMetricTypeHints bounds the README table to the most frequent metric names
+const maxMetricTypeHints = 20
+
+// metricTypeStats counts exported series lines per metric name so README.txt can list the
+// most frequent metrics with a type inferred from their name
+type metricTypeStats struct {
+	series map[string]int
+}
+
+func newMetricTypeStats() *metricTypeStats {
+	return &metricTypeStats{series: make(map[string]int)}
+}
+
+func (s *metricTypeStats) observe(name string) {
+	if s == nil || name == "" {
+		return
+	}
+	s.series[name]++
+}
+
+// hints returns the most frequent metrics with their inferred type, ties sorted by name
+func (s *metricTypeStats) hints() []domain.MetricTypeHint {
+	if s == nil || len(s.series) == 0 {
+		return nil
+	}
+	result := make([]domain.MetricTypeHint, 0, len(s.series))
+	for name, series := range s.series {
+		result = append(result, domain.MetricTypeHint{Metric: name, Type: inferMetricType(name), Series: series})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Series != result[j].Series {
+			return result[i].Series > result[j].Series
+		}
+		return result[i].Metric < result[j].Metric
+	})
+	if len(result) > maxMetricTypeHints {
+		result = result[:maxMetricTypeHints]
+	}
+	return result
+}
+
+// inferMetricType guesses the type from Prometheus naming conventions. It is a heuristic:
+// _count also ends summary series and gauges may use any name.
+func inferMetricType(name string) string {
+	switch {
+	case strings.HasSuffix(name, "_bucket"):
+		return "histogram"
+	case strings.HasSuffix(name, "_total"), strings.HasSuffix(name, "_count"):
+		return "counter"
+	default:
+		return "gauge"
+	}
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/archive"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+func TestInferMetricType(t *testing.T) {
+	cases := map[string]string{
+		"vm_http_requests_total":            "counter",
+		"vm_request_duration_seconds_count": "counter",
+		"vm_request_duration_bucket":        "histogram",
+		"vm_free_disk_space_bytes":          "gauge",
+		"up":                                "gauge",
+	}
+	for name, want := range cases {
+		if got := inferMetricType(name); got != want {
+			t.Errorf("inferMetricType(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestExecuteExport_ReadmeListsInferredMetricTypes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `{"metric":{"__name__":"vm_http_requests_total","instance":"a"},"values":[1],"timestamps":[1000]}`+"\n")
+		_, _ = io.WriteString(w, `{"metric":{"__name__":"vm_http_requests_total","instance":"b"},"values":[1],"timestamps":[1000]}`+"\n")
+		_, _ = io.WriteString(w, `{"metric":{"__name__":"vm_request_duration_bucket","vmrange":"1e0...2e0"},"values":[1],"timestamps":[1000]}`+"\n")
+		_, _ = io.WriteString(w, `{"metric":{"__name__":"vm_request_duration_count"},"values":[1],"timestamps":[1000]}`+"\n")
+		_, _ = io.WriteString(w, `{"metric":{"__name__":"vm_free_disk_space_bytes"},"values":[1],"timestamps":[1000]}`+"\n")
+	}))
+	defer srv.Close()
+
+	service := &exportServiceImpl{
+		clientFactory:   vm.NewClient,
+		archiveWriter:   archive.NewWriter(t.TempDir()),
+		vmGatherVersion: "test",
+	}
+	end := time.Now().Truncate(time.Minute)
+	result, err := service.ExecuteExport(context.Background(), domain.ExportConfig{
+		Connection: domain.VMConnection{URL: srv.URL},
+		TimeRange:  domain.TimeRange{Start: end.Add(-time.Hour), End: end},
+		StagingDir: t.TempDir(),
+	})
+	if err != nil {
+		t.Fatalf("ExecuteExport failed: %v", err)
+	}
+	readme := readZipFiles(t, result.ArchivePath)["README.txt"]
+	for name, want := range map[string]string{
+		"vm_http_requests_total":     "counter",
+		"vm_request_duration_bucket": "histogram",
+		"vm_request_duration_count":  "counter",
+		"vm_free_disk_space_bytes":   "gauge",
+	} {
+		if !regexp.MustCompile(`(?m)^  ` + name + ` +` + want + ` +\d+$`).MatchString(readme) {
+			t.Errorf("expected README to list %s as %s, got:\n%s", name, want, readme)
+		}
+	}
+	if strings.Index(readme, "vm_http_requests_total") > strings.Index(readme, "vm_free_disk_space_bytes") {
+		t.Errorf("expected the metric with most series first, got:\n%s", readme)
+	}
+}
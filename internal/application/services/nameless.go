@@ -0,0 +1,21 @@
+package services
+
+import "github.com/VictoriaMetrics/vmgather/internal/domain"
+
+// applyNamelessSeriesPolicy applies the policy to a series without __name__.
+// Returns the (possibly updated) labels and false when the series must be dropped.
+func applyNamelessSeriesPolicy(labels map[string]string, policy domain.NamelessSeriesPolicy) (map[string]string, bool) {
+	if labels["__name__"] != "" {
+		return labels, true
+	}
+	switch policy {
+	case domain.NamelessSeriesDrop:
+		return labels, false
+	case domain.NamelessSeriesSynthesize:
+		if labels == nil {
+			labels = make(map[string]string, 1)
+		}
+		labels["__name__"] = domain.SynthesizeMetricName(labels)
+	}
+	return labels, true
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+)
+
+func TestProcessMetricsIntoWriter_NamelessSeriesPolicies(t *testing.T) {
+	input := `{"metric":{"__name__":"up","job":"vmstorage"},"values":[1],"timestamps":[1000]}
+{"metric":{"job":"vmstorage","instance":"host:8482"},"values":[1],"timestamps":[1000]}
+`
+	tests := []struct {
+		policy   domain.NamelessSeriesPolicy
+		expected int
+		wantName string
+	}{
+		{policy: "", expected: 2},
+		{policy: domain.NamelessSeriesKeep, expected: 2},
+		{policy: domain.NamelessSeriesDrop, expected: 1},
+		{policy: domain.NamelessSeriesSynthesize, expected: 2, wantName: "unnamed_instance_job"},
+	}
+
+	service := &exportServiceImpl{}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		count, err := service.processMetricsIntoWriter(strings.NewReader(input), domain.ObfuscationConfig{}, nil, processOptions{namelessSeries: tt.policy}, &out)
+		if err != nil {
+			t.Fatalf("policy %q: processMetricsIntoWriter failed: %v", tt.policy, err)
+		}
+		if count != tt.expected {
+			t.Fatalf("policy %q: expected %d series, got %d", tt.policy, tt.expected, count)
+		}
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		var last struct {
+			Metric map[string]string `json:"metric"`
+		}
+		if err := json.Unmarshal([]byte(lines[len(lines)-1]), &last); err != nil {
+			t.Fatalf("policy %q: invalid output: %v", tt.policy, err)
+		}
+		if tt.wantName != "" && last.Metric["__name__"] != tt.wantName {
+			t.Fatalf("policy %q: expected synthesized name %q, got %q", tt.policy, tt.wantName, last.Metric["__name__"])
+		}
+		if tt.policy == domain.NamelessSeriesKeep && last.Metric["__name__"] != "" {
+			t.Fatalf("keep policy must not invent a name, got %q", last.Metric["__name__"])
+		}
+	}
+}
+
+func TestVMService_GetSample_NamelessSeriesPolicies(t *testing.T) {
+	server := newIPv4Server(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[
+			{"metric":{"__name__":"up","job":"vmstorage"},"value":[1700000000,"1"]},
+			{"metric":{"job":"vmstorage"},"value":[1700000000,"2"]}
+		]}}`))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		policy    domain.NamelessSeriesPolicy
+		wantCount int
+		wantName  string
+	}{
+		{policy: domain.NamelessSeriesKeep, wantCount: 2, wantName: ""},
+		{policy: domain.NamelessSeriesDrop, wantCount: 1},
+		{policy: domain.NamelessSeriesSynthesize, wantCount: 2, wantName: "unnamed_job"},
+	}
+
+	service := NewVMService()
+	for _, tt := range tests {
+		samples, err := service.GetSample(context.Background(), domain.ExportConfig{
+			Connection:     domain.VMConnection{URL: server.URL},
+			TimeRange:      domain.TimeRange{Start: time.Now().Add(-time.Hour), End: time.Now()},
+			Jobs:           []string{"vmstorage"},
+			NamelessSeries: tt.policy,
+		}, 10)
+		if err != nil {
+			t.Fatalf("policy %q: GetSample failed: %v", tt.policy, err)
+		}
+		if len(samples) != tt.wantCount {
+			t.Fatalf("policy %q: expected %d samples, got %d", tt.policy, tt.wantCount, len(samples))
+		}
+		if tt.wantCount == 2 && samples[1].MetricName != tt.wantName {
+			t.Fatalf("policy %q: expected metric name %q, got %q", tt.policy, tt.wantName, samples[1].MetricName)
+		}
+	}
+}
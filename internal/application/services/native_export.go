@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+// nativeStagingFileName returns the staging file name of a native export
+func nativeStagingFileName(id string) string {
+	return id + ".partial.native"
+}
+
+// exportFormat returns the normalized data format of config; empty means jsonl
+func exportFormat(config domain.ExportConfig) string {
+	format := strings.ToLower(strings.TrimSpace(config.Format))
+	if format == "" {
+		return domain.ExportFormatJSONL
+	}
+	return format
+}
+
+// validateExportFormat rejects unknown formats and, for native, every option that needs the
+// series decoded: native data is stored as VictoriaMetrics sent it, so labels and samples can
+// be neither rewritten nor counted.
+func (s *exportServiceImpl) validateExportFormat(config domain.ExportConfig, formats []string) error {
+	switch exportFormat(config) {
+	case domain.ExportFormatJSONL:
+		return nil
+	case domain.ExportFormatNative:
+	default:
+		return fmt.Errorf("unsupported format %q (use %q or %q)", config.Format, domain.ExportFormatJSONL, domain.ExportFormatNative)
+	}
+	if config.Obfuscation.Enabled {
+		return fmt.Errorf("obfuscation cannot be combined with format %q: native data is not parsed, so labels cannot be obfuscated; use format %q", domain.ExportFormatNative, domain.ExportFormatJSONL)
+	}
+	if _, useQueryRange := s.buildExportQuery(config); useQueryRange {
+		return fmt.Errorf("format %q exports raw series by selector; MetricsQL queries and query_set need format %q", domain.ExportFormatNative, domain.ExportFormatJSONL)
+	}
+	var options []string
+	add := func(set bool, name string) {
+		if set {
+			options = append(options, name)
+		}
+	}
+	add(len(config.Obfuscation.DropLabels) > 0, "obfuscation.drop_labels")
+	add(containsString(formats, domain.OutputFormatCSV), "formats csv")
+	add(config.ArchivePerBatch, "archive_per_batch")
+	add(config.SeriesStats, "series_stats")
+	add(config.IncludeReproduce, "include_reproduce")
+	add(config.InferScrapeInterval, "infer_scrape_interval")
+	add(config.ClampToData, "clamp_to_data")
+	add(config.StagingGzip, "staging_gzip")
+	add(config.ResumeFromBatch > 0, "resume_from_batch")
+	add(config.CarryInSeconds > 0, "carry_in_seconds")
+	add(config.StallTimeoutSeconds > 0, "stall_timeout_seconds")
+	add(config.DeadlineSeconds > 0, "deadline_seconds")
+	add(config.SkipFailedBatches, "skip_failed_batches")
+	add(config.SeriesLimit > 0 || config.SeriesOffset > 0, "series_limit")
+	add(config.PerComponentSeriesCap > 0, "per_component_series_cap")
+	add(config.CardinalityBudget > 0, "label_cardinality_budget")
+	add(config.SelectorConcurrency > 1, "selector_concurrency")
+	add(config.BaselineArchive != "", "baseline_archive")
+	add(config.HistogramMode == domain.HistogramModeCompact, "histogram_mode")
+	add(config.CounterEncoding == domain.CounterEncodingDelta, "counter_encoding")
+	add(config.NamelessSeries != "" && config.NamelessSeries != domain.NamelessSeriesKeep, "nameless_series")
+	add(config.StalenessMarkers == domain.StalenessMarkersStrip, "staleness_markers")
+	add(config.MaxFutureSkewSeconds > 0, "max_future_skew_seconds")
+	add(config.MaxLabelValueLength > 0, "max_label_value_length")
+	add(config.LowercaseLabelNames, "lowercase_label_names")
+	add(config.TrimLabelValues, "trim_label_values")
+	add(config.MaxBytes > 0, "max_bytes")
+	add(config.MaxPointsPerSeries > 0, "max_points_per_series")
+	add(config.MaxPointsPerBatch > 0, "max_points_per_batch")
+	add(config.SampleEveryN > 1, "sample_every_n")
+	if len(options) > 0 {
+		return fmt.Errorf("format %q stores data unparsed and cannot be combined with: %s", domain.ExportFormatNative, strings.Join(options, ", "))
+	}
+	return nil
+}
+
+// executeNativeExport fetches the whole range from /api/v1/export/native in one request and
+// streams it into the staging file unmodified; a native stream starts with its own time range
+// header, so per-window responses could not be concatenated. Series and points are not counted.
+func (s *exportServiceImpl) executeNativeExport(ctx context.Context, config domain.ExportConfig, exportID, stagingDir string) (*domain.ExportResult, error) {
+	if config.StagingFile == "" {
+		config.StagingFile = filepath.Join(stagingDir, nativeStagingFileName(exportID))
+	}
+	client := s.clientFactory(config.Connection)
+	selector, _ := s.buildExportQuery(config)
+	selectors := withUpSelector(config, []string{selector})
+	alerting := s.captureVMAlert(ctx, config)
+
+	s.printf("Exporting %s - %s in native format\n", config.TimeRange.Start.Format(time.RFC3339), config.TimeRange.End.Format(time.RFC3339))
+	start := time.Now()
+	written, err := stageNativeExport(ctx, client, config, selectors)
+	if err != nil {
+		return nil, err
+	}
+	s.printf("[OK] Native export received in %v (%d bytes)\n", time.Since(start), written)
+	ReportBatchProgress(ctx, BatchProgress{
+		BatchIndex:   1,
+		Batches:      1,
+		TotalBatches: 1,
+		TimeRange:    config.TimeRange,
+		Duration:     time.Since(start),
+	})
+
+	metadata := s.buildArchiveMetadata(exportID, config, 0, nil)
+	metadata.VMAlert = alerting.summary
+	metadata.AlertsJSON, metadata.RulesJSON = alerting.alerts, alerting.rules
+	metadata.AlwaysIncludeUp = config.AlwaysIncludeUp
+	archivePath, sha256sum, archiveSize, err := s.sealArchive(config, metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	keptStaging := ""
+	if config.KeepStaging {
+		keptStaging = config.StagingFile
+		s.printf("Staging file kept: %s\n", keptStaging)
+	} else if err := os.Remove(config.StagingFile); err != nil {
+		log.Printf("[WARN] Failed to remove staging file %s: %v", config.StagingFile, err)
+	}
+
+	result := &domain.ExportResult{
+		ExportID:         exportID,
+		ArchivePath:      archivePath,
+		ArchiveName:      filepath.Base(archivePath),
+		ArchiveSizeBytes: archiveSize,
+		TimeRange:        config.TimeRange,
+		SHA256:           sha256sum,
+		StagingPath:      keptStaging,
+	}
+	if written == 0 {
+		warning := fmt.Sprintf("selector %s matched no series in the requested time range", selector)
+		s.printf("[WARN] %s\n", warning)
+		result.Warnings = append(result.Warnings, warning)
+	}
+	result.Warnings = append(result.Warnings, alerting.warnings...)
+	uploadArchives(ctx, config.Upload, result, s.progressOut())
+	return result, nil
+}
+
+// stageNativeExport copies the native export response into the staging file and returns its size
+func stageNativeExport(ctx context.Context, client *vm.Client, config domain.ExportConfig, selectors []string) (int64, error) {
+	file, err := createStagingFile(config.StagingFile)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = file.Close() }()
+
+	body, err := client.ExportNative(ctx, selectors, config.TimeRange.Start, windowRequestEnd(config, config.TimeRange))
+	if errors.Is(err, vm.ErrNoData) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("native export failed: %w", err)
+	}
+	defer func() { _ = body.Close() }()
+
+	written, err := io.Copy(file, body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to stage native export: %w", err)
+	}
+	if config.StagingFsync {
+		if err := file.Sync(); err != nil {
+			return 0, fmt.Errorf("failed to sync staging file: %w", err)
+		}
+	}
+	if err := file.Close(); err != nil {
+		return 0, fmt.Errorf("failed to close staging file: %w", err)
+	}
+	return written, nil
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/archive"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+func TestExecuteExport_NativeFormat(t *testing.T) {
+	payload := "\x00\x01native-block\xff"
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path)
+		if r.URL.Path == "/api/v1/export/native" {
+			_, _ = w.Write([]byte(payload))
+			return
+		}
+		_, _ = w.Write([]byte(`{"metric":{"__name__":"up","job":"a"},"values":[1],"timestamps":[1000]}` + "\n"))
+	}))
+	defer srv.Close()
+
+	service := &exportServiceImpl{
+		clientFactory:   vm.NewClient,
+		archiveWriter:   archive.NewWriter(t.TempDir()),
+		vmGatherVersion: "test",
+	}
+	end := time.Now().Truncate(time.Minute)
+	config := domain.ExportConfig{
+		Connection: domain.VMConnection{URL: srv.URL},
+		TimeRange:  domain.TimeRange{Start: end.Add(-3 * time.Hour), End: end},
+		Batching:   domain.BatchSettings{Enabled: true, Strategy: "custom", CustomIntervalSecs: 3600},
+		StagingDir: t.TempDir(),
+		Format:     domain.ExportFormatNative,
+	}
+	result, err := service.ExecuteExport(context.Background(), config)
+	if err != nil {
+		t.Fatalf("ExecuteExport failed: %v", err)
+	}
+
+	if len(requests) != 1 || requests[0] != "/api/v1/export/native" {
+		t.Fatalf("expected the whole range in one native request, got %v", requests)
+	}
+	files := readZipFiles(t, result.ArchivePath)
+	if files["metrics.native"] != payload {
+		t.Fatalf("expected metrics.native to hold the payload unmodified, got %q", files["metrics.native"])
+	}
+	if _, ok := files["metrics.jsonl"]; ok {
+		t.Fatal("expected no metrics.jsonl in a native archive")
+	}
+	if !strings.Contains(files["metadata.json"], `"format": "native"`) {
+		t.Fatalf("expected metadata.json to record the native format, got %s", files["metadata.json"])
+	}
+
+	config.Format = ""
+	result, err = service.ExecuteExport(context.Background(), config)
+	if err != nil {
+		t.Fatalf("jsonl ExecuteExport failed: %v", err)
+	}
+	if metadata := readZipFiles(t, result.ArchivePath)["metadata.json"]; !strings.Contains(metadata, `"format": "jsonl"`) {
+		t.Fatalf("expected metadata.json to record the jsonl format, got %s", metadata)
+	}
+}
+
+func TestExecuteExport_NativeFormatRejectsParsingOptions(t *testing.T) {
+	service := &exportServiceImpl{
+		clientFactory:   vm.NewClient,
+		archiveWriter:   archive.NewWriter(t.TempDir()),
+		vmGatherVersion: "test",
+	}
+	end := time.Now().Truncate(time.Minute)
+	base := domain.ExportConfig{
+		Connection: domain.VMConnection{URL: "http://127.0.0.1:1"},
+		TimeRange:  domain.TimeRange{Start: end.Add(-time.Hour), End: end},
+		StagingDir: t.TempDir(),
+		Format:     domain.ExportFormatNative,
+	}
+	tests := []struct {
+		name   string
+		modify func(*domain.ExportConfig)
+		want   string
+	}{
+		{name: "obfuscation", modify: func(c *domain.ExportConfig) { c.Obfuscation.Enabled = true }, want: "obfuscation cannot be combined with format \"native\""},
+		{name: "metricsql", modify: func(c *domain.ExportConfig) {
+			c.Mode, c.QueryType, c.Query = domain.ExportModeCustom, domain.QueryModeMetricsQL, "rate(up[5m])"
+		}, want: "MetricsQL queries"},
+		{name: "csv and series stats", modify: func(c *domain.ExportConfig) {
+			c.Formats, c.SeriesStats = []string{domain.OutputFormatCSV}, true
+		}, want: "cannot be combined with: formats csv, series_stats"},
+		{name: "drop labels", modify: func(c *domain.ExportConfig) { c.Obfuscation.DropLabels = []string{"pod"} }, want: "cannot be combined with: obfuscation.drop_labels"},
+		{name: "unknown format", modify: func(c *domain.ExportConfig) { c.Format = "parquet" }, want: `unsupported format "parquet"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := base
+			tt.modify(&config)
+			if _, err := service.ExecuteExport(context.Background(), config); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestExecuteExport_NativeFormatHonorsRangeEnd(t *testing.T) {
+	var ends []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		ends = append(ends, r.FormValue("end"))
+		_, _ = w.Write([]byte("native"))
+	}))
+	defer srv.Close()
+
+	service := &exportServiceImpl{
+		clientFactory:   vm.NewClient,
+		archiveWriter:   archive.NewWriter(t.TempDir()),
+		vmGatherVersion: "test",
+	}
+	end := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	for _, rangeEnd := range []domain.RangeEnd{domain.RangeEndExclusive, ""} {
+		_, err := service.ExecuteExport(context.Background(), domain.ExportConfig{
+			Connection: domain.VMConnection{URL: srv.URL},
+			TimeRange:  domain.TimeRange{Start: end.Add(-time.Hour), End: end},
+			StagingDir: t.TempDir(),
+			Format:     domain.ExportFormatNative,
+			RangeEnd:   rangeEnd,
+		})
+		if err != nil {
+			t.Fatalf("ExecuteExport with range_end %q failed: %v", rangeEnd, err)
+		}
+	}
+	want := []string{end.Add(-time.Millisecond).Format(time.RFC3339Nano), end.Format(time.RFC3339Nano)}
+	if len(ends) != 2 || ends[0] != want[0] || ends[1] != want[1] {
+		t.Fatalf("expected native request ends %v, got %v", want, ends)
+	}
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+)
+
+// defaultMaxOutputFiles is the entry limit used when max_output_files is not set. A single archive
+// holds at most a handful of entries, so only archive_per_batch over many windows comes near it.
+const defaultMaxOutputFiles = 10_000
+
+// validateMaxOutputFiles rejects a negative max_output_files
+func validateMaxOutputFiles(limit int) error {
+	if limit < 0 {
+		return fmt.Errorf("max_output_files must not be negative, got %d", limit)
+	}
+	return nil
+}
+
+// archiveEntries is the most entries one archive of the export can hold
+func archiveEntries(config domain.ExportConfig, formats []string) int {
+	// metrics.jsonl, metadata.json and README.txt
+	entries := 3
+	if containsString(formats, domain.OutputFormatCSV) {
+		entries++
+	}
+	if config.VMAlertURL != "" {
+		entries += 2 // alerts.json and rules.json
+	}
+	if config.SkipFailedBatches {
+		entries++ // errors.json
+	}
+	if config.IncludeReproduce {
+		entries++
+	}
+	if config.SeriesStats {
+		entries++
+	}
+	return entries
+}
+
+// checkOutputFiles fails the export before anything is fetched when its archives would hold more
+// entries than max_output_files. With archive_per_batch every window may become an archive;
+// adaptive batching can merge windows, so this is an upper bound.
+func checkOutputFiles(config domain.ExportConfig, formats []string, windows int) error {
+	limit := config.MaxOutputFiles
+	if limit == 0 {
+		limit = defaultMaxOutputFiles
+	}
+	archives := 1
+	if config.ArchivePerBatch && windows > 1 {
+		archives = windows
+	}
+	if entries := archives * archiveEntries(config, formats); entries > limit {
+		return fmt.Errorf("export would write up to %d archive entries in %d archives, above max_output_files of %d; use larger batch windows, a shorter range or disable archive_per_batch", entries, archives, limit)
+	}
+	return nil
+}
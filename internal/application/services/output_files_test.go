@@ -0,0 +1,67 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/archive"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+func TestExecuteExport_MaxOutputFilesRejectsPerBatchArchivesUpFront(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		_, _ = w.Write([]byte(`{"metric":{"__name__":"up","job":"vmagent"},"values":[1],"timestamps":[1000]}` + "\n"))
+	}))
+	defer srv.Close()
+
+	outputDir := t.TempDir()
+	service := &exportServiceImpl{
+		clientFactory:   vm.NewClient,
+		archiveWriter:   archive.NewWriter(outputDir),
+		vmGatherVersion: "test",
+	}
+	end := time.Now().Truncate(time.Minute)
+	config := domain.ExportConfig{
+		Connection:      domain.VMConnection{URL: srv.URL},
+		TimeRange:       domain.TimeRange{Start: end.Add(-2 * time.Hour), End: end},
+		Batching:        domain.BatchSettings{Enabled: true, Strategy: "custom", CustomIntervalSecs: 60},
+		StagingDir:      t.TempDir(),
+		ArchivePerBatch: true,
+		Formats:         []string{"jsonl", "csv"},
+		MaxOutputFiles:  400,
+	}
+
+	// 120 windows with metrics.jsonl, metrics.csv, metadata.json and README.txt each
+	_, err := service.ExecuteExport(context.Background(), config)
+	if err == nil || !strings.Contains(err.Error(), "480 archive entries in 120 archives, above max_output_files of 400") {
+		t.Fatalf("expected the entry guard to reject the export, got %v", err)
+	}
+	if n := requests.Load(); n != 0 {
+		t.Fatalf("expected no data to be fetched, got %d requests", n)
+	}
+	entries, _ := os.ReadDir(outputDir)
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), ".zip") {
+			t.Fatalf("expected no archive to be written, found %s", entry.Name())
+		}
+	}
+
+	config.MaxOutputFiles = -1
+	if _, err := service.ExecuteExport(context.Background(), config); err == nil || !strings.Contains(err.Error(), "must not be negative") {
+		t.Fatalf("expected a negative max_output_files to be rejected, got %v", err)
+	}
+
+	config.MaxOutputFiles = 480
+	if _, err := service.ExecuteExport(context.Background(), config); err != nil {
+		t.Fatalf("expected the export to fit max_output_files, got %v", err)
+	}
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+// resolveSeriesPage lists the series matched by selector over the export range, orders them
+// deterministically and returns exact-match selectors for the requested page, with the set
+// of series on it.
+func resolveSeriesPage(ctx context.Context, client *vm.Client, selector string, tr domain.TimeRange, offset, limit int) ([]string, seriesSet, *domain.SeriesPagination, error) {
+	series, err := client.Series(ctx, selector, tr.Start, tr.End)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("series listing failed: %w", err)
+	}
+
+	byKey := make(map[string]map[string]string, len(series))
+	ordered := make([]string, 0, len(series))
+	for _, labels := range series {
+		key := seriesKey(labels)
+		if _, exists := byKey[key]; exists {
+			continue
+		}
+		byKey[key] = labels
+		ordered = append(ordered, key)
+	}
+	sort.Strings(ordered)
+
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > len(ordered) {
+		offset = len(ordered)
+	}
+	end := offset + limit
+	if end > len(ordered) {
+		end = len(ordered)
+	}
+
+	names := labelNames(series)
+	page := make(seriesSet, end-offset)
+	selectors := make([]string, 0, end-offset)
+	for _, key := range ordered[offset:end] {
+		page[key] = struct{}{}
+		selectors = append(selectors, exactSeriesSelector(byKey[key], names))
+	}
+	return selectors, page, &domain.SeriesPagination{
+		Offset:      offset,
+		Limit:       limit,
+		TotalSeries: len(ordered),
+		NextOffset:  end,
+		HasMore:     end < len(ordered),
+	}, nil
+}
+
+// seriesKey identifies a series by its full label set: all labels in name order, formatted
+// like a selector
+func seriesKey(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, name+"="+quotePromQL(labels[name]))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// exactSeriesSelector narrows seriesKey(labels) with name="" for every name in names the series
+// does not have. Equality matchers alone also match series with extra labels; ruling out the
+// labels seen on the other listed series keeps those apart. Series with labels that were never
+// listed can still match, so callers also filter the result by seriesKey.
+func exactSeriesSelector(labels map[string]string, names []string) string {
+	key := seriesKey(labels)
+	var absent []string
+	for _, name := range names {
+		if _, ok := labels[name]; !ok {
+			absent = append(absent, name+`=""`)
+		}
+	}
+	if len(absent) == 0 {
+		return key
+	}
+	if len(labels) == 0 {
+		return "{" + strings.Join(absent, ",") + "}"
+	}
+	return strings.TrimSuffix(key, "}") + "," + strings.Join(absent, ",") + "}"
+}
+
+// labelNames returns the sorted union of label names of series
+func labelNames(series []map[string]string) []string {
+	seen := make(map[string]struct{})
+	for _, labels := range series {
+		for name := range labels {
+			seen[name] = struct{}{}
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// quotePromQL quotes s as a PromQL/MetricsQL double-quoted string. Only the backslash, the quote
+// and line breaks are escaped; Go's %q would also produce \x and \u escapes for bytes that are
+// valid as they are.
+func quotePromQL(s string) string {
+	var b strings.Builder
+	b.Grow(len(s) + 2)
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\', '"':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// seriesSelection is the set of selectors an export fetches, with the metadata of how it was narrowed
+type seriesSelection struct {
+	selectors    []string
+	series       seriesSet // Listed series the selectors stand for; nil when not narrowed
+	pagination   *domain.SeriesPagination
+	componentCap *domain.ComponentSeriesCap
+}
+
+// resolveExportSelectors returns the selectors to export, narrowing them to a single page
+// of series when series pagination is requested, or to at most N series per component.
+func (s *exportServiceImpl) resolveExportSelectors(ctx context.Context, client *vm.Client, config domain.ExportConfig, selector string, useQueryRange bool) (seriesSelection, error) {
+	if err := validatePerComponentSeriesCap(config); err != nil {
+		return seriesSelection{}, err
+	}
+	if config.SeriesLimit <= 0 && config.PerComponentSeriesCap <= 0 {
+		return seriesSelection{selectors: []string{selector}}, nil
+	}
+	if useQueryRange {
+		return seriesSelection{}, fmt.Errorf("series pagination and per_component_series_cap require a plain series selector export")
+	}
+	if config.PerComponentSeriesCap > 0 {
+		selectors, kept, componentCap, err := s.resolveComponentSeriesCap(ctx, client, selector, config.TimeRange, config.PerComponentSeriesCap)
+		return seriesSelection{selectors: selectors, series: kept, componentCap: componentCap}, err
+	}
+	selectors, page, pagination, err := resolveSeriesPage(ctx, client, selector, config.TimeRange, config.SeriesOffset, config.SeriesLimit)
+	return seriesSelection{selectors: selectors, series: page, pagination: pagination}, err
+}
+
+func emptyExportReader() io.ReadCloser {
+	return io.NopCloser(strings.NewReader(""))
+}
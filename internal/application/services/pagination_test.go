@@ -0,0 +1,240 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/archive"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+func TestExportToWriter_SeriesPagesDoNotOverlap(t *testing.T) {
+	series := make([]map[string]string, 0, 5)
+	exportLines := make(map[string]string)
+	for i := 0; i < 5; i++ {
+		labels := map[string]string{"__name__": "vm_rows", "job": "vmstorage", "instance": fmt.Sprintf("host-%d:8482", i)}
+		series = append(series, labels)
+		line, _ := json.Marshal(vm.ExportedMetric{Metric: labels, Values: vm.SampleValues{float64(i)}, Timestamps: []int64{1000}})
+		exportLines[seriesKey(labels)] = string(line)
+	}
+
+	srv := newIPv4Server(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/series":
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"status": "success", "data": series})
+		case "/api/v1/export":
+			if err := r.ParseForm(); err != nil {
+				t.Errorf("failed to parse export form: %v", err)
+			}
+			for _, match := range r.Form["match[]"] {
+				line, ok := exportLines[match]
+				if !ok {
+					t.Errorf("unexpected match[] %q", match)
+					continue
+				}
+				_, _ = fmt.Fprintln(w, line)
+			}
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+
+	service := &exportServiceImpl{
+		clientFactory:   vm.NewClient,
+		archiveWriter:   archive.NewWriter(t.TempDir()),
+		vmGatherVersion: "test",
+	}
+	exportPage := func(offset int) (int, []string) {
+		config := domain.ExportConfig{
+			Connection:   domain.VMConnection{URL: srv.URL},
+			TimeRange:    domain.TimeRange{Start: time.Now().Add(-time.Minute), End: time.Now()},
+			SeriesLimit:  3,
+			SeriesOffset: offset,
+		}
+		var buf bytes.Buffer
+		count, err := service.exportToWriter(context.Background(), config, &buf)
+		if err != nil {
+			t.Fatalf("exportToWriter(offset=%d) failed: %v", offset, err)
+		}
+		var instances []string
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			var metric vm.ExportedMetric
+			if err := json.Unmarshal([]byte(line), &metric); err != nil {
+				t.Fatalf("invalid exported line %q: %v", line, err)
+			}
+			instances = append(instances, metric.Metric["instance"])
+		}
+		return count, instances
+	}
+
+	firstCount, first := exportPage(0)
+	secondCount, second := exportPage(3)
+	if firstCount != 3 || secondCount != 2 {
+		t.Fatalf("unexpected page sizes: first=%d second=%d", firstCount, secondCount)
+	}
+
+	seen := make(map[string]bool)
+	for _, instance := range append(first, second...) {
+		if seen[instance] {
+			t.Fatalf("series %s exported in both pages", instance)
+		}
+		seen[instance] = true
+	}
+	if len(seen) != len(series) {
+		t.Fatalf("expected all %d series across pages, got %d", len(series), len(seen))
+	}
+}
+
+func TestResolveSeriesPage_ReportsPaginationState(t *testing.T) {
+	srv := newIPv4Server(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"success","data":[{"__name__":"b"},{"__name__":"a"},{"__name__":"c"},{"__name__":"a"}]}`))
+	}))
+	defer srv.Close()
+
+	client := vm.NewClient(domain.VMConnection{URL: srv.URL})
+	tr := domain.TimeRange{Start: time.Now().Add(-time.Minute), End: time.Now()}
+
+	page, set, state, err := resolveSeriesPage(context.Background(), client, `{__name__!=""}`, tr, 1, 1)
+	if err != nil {
+		t.Fatalf("resolveSeriesPage failed: %v", err)
+	}
+	if len(page) != 1 || page[0] != `{__name__="b"}` || len(set) != 1 || !set.contains(map[string]string{"__name__": "b"}) {
+		t.Fatalf("unexpected page: %v %v", page, set)
+	}
+	want := domain.SeriesPagination{Offset: 1, Limit: 1, TotalSeries: 3, NextOffset: 2, HasMore: true}
+	if *state != want {
+		t.Fatalf("pagination = %+v, want %+v", *state, want)
+	}
+
+	page, _, state, err = resolveSeriesPage(context.Background(), client, `{__name__!=""}`, tr, 10, 5)
+	if err != nil {
+		t.Fatalf("resolveSeriesPage failed: %v", err)
+	}
+	if len(page) != 0 || state.HasMore || state.NextOffset != 3 {
+		t.Fatalf("expected empty final page, got %v %+v", page, *state)
+	}
+}
+
+// matcherRe parses the name<op>"value" matchers of a selector
+var matcherRe = regexp.MustCompile(`([a-zA-Z_][a-zA-Z0-9_]*)(=~|!~|!=|=)"((?:[^"\\]|\\.)*)"`)
+
+// matchesSelector evaluates a selector like VictoriaMetrics does: every matcher must hold, an
+// absent label has the empty value, regexps are anchored, and other labels are ignored
+func matchesSelector(t *testing.T, selector string, labels map[string]string) bool {
+	t.Helper()
+	for _, m := range matcherRe.FindAllStringSubmatch(selector, -1) {
+		value, err := strconv.Unquote(`"` + m[3] + `"`)
+		if err != nil {
+			t.Fatalf("selector %s has an invalid value: %v", selector, err)
+		}
+		actual := labels[m[1]]
+		var ok bool
+		switch m[2] {
+		case "=":
+			ok = actual == value
+		case "!=":
+			ok = actual != value
+		case "=~":
+			ok = regexp.MustCompile("^(?:" + value + ")$").MatchString(actual)
+		case "!~":
+			ok = !regexp.MustCompile("^(?:" + value + ")$").MatchString(actual)
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func TestExportToWriter_SeriesPagesExcludeSeriesWithExtraLabels(t *testing.T) {
+	listed := []map[string]string{
+		{"__name__": "vm_rows", "job": "vmstorage"},
+		{"__name__": "vm_rows", "job": "vmstorage", "shard": "1"},
+		{"__name__": "vm_rows", "job": "vmstorage", "path": `C:\data "main"`},
+	}
+	// Stored but not returned by the listing, e.g. it appeared after it; it matches every page's selector
+	unlisted := map[string]string{"__name__": "vm_rows", "job": "vmstorage", "zone": "b"}
+
+	srv := newIPv4Server(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/series":
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"status": "success", "data": listed})
+		case "/api/v1/export":
+			_ = r.ParseForm()
+			for _, labels := range append(listed[:len(listed):len(listed)], unlisted) {
+				for _, match := range r.Form["match[]"] {
+					if matchesSelector(t, match, labels) {
+						line, _ := json.Marshal(vm.ExportedMetric{Metric: labels, Values: vm.SampleValues{1}, Timestamps: []int64{1000}})
+						_, _ = fmt.Fprintln(w, string(line))
+						break
+					}
+				}
+			}
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+
+	service := &exportServiceImpl{
+		clientFactory:   vm.NewClient,
+		archiveWriter:   archive.NewWriter(t.TempDir()),
+		vmGatherVersion: "test",
+	}
+	exported := make(map[string]int)
+	for offset := 0; offset < len(listed); offset++ {
+		config := domain.ExportConfig{
+			Connection:   domain.VMConnection{URL: srv.URL},
+			TimeRange:    domain.TimeRange{Start: time.Now().Add(-time.Minute), End: time.Now()},
+			SeriesLimit:  1,
+			SeriesOffset: offset,
+		}
+		var buf bytes.Buffer
+		if _, err := service.exportToWriter(context.Background(), config, &buf); err != nil {
+			t.Fatalf("exportToWriter(offset=%d) failed: %v", offset, err)
+		}
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			var metric vm.ExportedMetric
+			if err := json.Unmarshal([]byte(line), &metric); err != nil {
+				t.Fatalf("invalid exported line %q: %v", line, err)
+			}
+			exported[seriesKey(metric.Metric)]++
+		}
+	}
+	if len(exported) != len(listed) {
+		t.Fatalf("expected the %d listed series, got %v", len(listed), exported)
+	}
+	for _, labels := range listed {
+		if n := exported[seriesKey(labels)]; n != 1 {
+			t.Fatalf("expected %v once across pages, got %d", labels, n)
+		}
+	}
+}
+
+func TestQuotePromQL(t *testing.T) {
+	for value, want := range map[string]string{
+		"plain":      `"plain"`,
+		`say "hi"`:   `"say \"hi\""`,
+		`C:\dir`:     `"C:\\dir"`,
+		"two\nlines": `"two\nlines"`,
+		"ünïcode ✓":  `"ünïcode ✓"`,
+		"":           `""`,
+	} {
+		if got := quotePromQL(value); got != want {
+			t.Errorf("quotePromQL(%q) = %s, want %s", value, got, want)
+		}
+	}
+}
@@ -11,13 +11,21 @@ type progressKeyType struct{}
 
 var progressKey = progressKeyType{}
 
-// BatchProgress describes the completion of a single batch window.
+// BatchProgress describes the completion of a batch request.
+// BatchIndex is the 1-based index of the last base window the request covered and Batches the
+// number of consecutive windows it covered (0 means 1). Events may arrive out of order when
+// batches are fetched concurrently; reporters must aggregate them rather than assume a sequence.
 type BatchProgress struct {
 	BatchIndex   int
+	Batches      int
 	TotalBatches int
 	TimeRange    domain.TimeRange
 	Metrics      int
 	Duration     time.Duration
+	// SeriesWithSamples counts the batch's series that carry real samples (see Metrics for all lines)
+	SeriesWithSamples int
+	// Archive is the window's own archive with archive_per_batch, ready to hand off
+	Archive *domain.BatchArchive
 }
 
 // ProgressReporter receives progress events for long-running exports.
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+// QuerySetLabel tags every series of a query set export with the name of the query that produced it
+const QuerySetLabel = "vmgather_query"
+
+// validateQuerySet checks a query set before anything is fetched; nil means no query set
+func validateQuerySet(set *domain.QuerySet) error {
+	if set == nil {
+		return nil
+	}
+	if strings.TrimSpace(set.Name) == "" {
+		return fmt.Errorf("query_set.name is required")
+	}
+	if len(set.Queries) == 0 {
+		return fmt.Errorf("query_set %q has no queries", set.Name)
+	}
+	seen := make(map[string]struct{}, len(set.Queries))
+	for i, query := range set.Queries {
+		if strings.TrimSpace(query.Name) == "" {
+			return fmt.Errorf("query_set %q: query #%d has no name", set.Name, i+1)
+		}
+		if strings.TrimSpace(query.Query) == "" {
+			return fmt.Errorf("query_set %q: query %q is empty", set.Name, query.Name)
+		}
+		if _, exists := seen[query.Name]; exists {
+			return fmt.Errorf("query_set %q: duplicate query name %q", set.Name, query.Name)
+		}
+		seen[query.Name] = struct{}{}
+	}
+	return nil
+}
+
+// querySetExpression joins the set's queries for logs; fetchQuerySet runs them one by one
+func querySetExpression(set *domain.QuerySet) string {
+	queries := make([]string, len(set.Queries))
+	for i, query := range set.Queries {
+		queries[i] = query.Query
+	}
+	return strings.Join(queries, " ; ")
+}
+
+// querySetMetadata is the query set recorded in metadata.json; expressions are dropped from
+// obfuscated exports because they usually name the original jobs and instances
+func querySetMetadata(set *domain.QuerySet, obfuscated bool) *domain.QuerySet {
+	if set == nil {
+		return nil
+	}
+	recorded := &domain.QuerySet{Name: set.Name, Queries: make([]domain.NamedQuery, len(set.Queries))}
+	for i, query := range set.Queries {
+		recorded.Queries[i] = domain.NamedQuery{Name: query.Name}
+		if !obfuscated {
+			recorded.Queries[i].Query = query.Query
+		}
+	}
+	return recorded
+}
+
+// fetchQuerySet runs every query of the set over tr via query_range and streams the results
+// as one export stream, with each series tagged by its query name
+func (s *exportServiceImpl) fetchQuerySet(ctx context.Context, client *vm.Client, set *domain.QuerySet, tr domain.TimeRange, metricStepSeconds, lookbehindSeconds int) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		encoder := json.NewEncoder(pw)
+		for _, query := range set.Queries {
+			s.printf("[INFO] Query set %s: running %s\n", set.Name, query.Name)
+			reader, err := s.exportViaQueryRange(ctx, client, query.Query, tr, metricStepSeconds, lookbehindSeconds)
+			if err != nil {
+				_ = pw.CloseWithError(fmt.Errorf("query %q: %w", query.Name, err))
+				return
+			}
+			err = tagQuerySeries(reader, query.Name, encoder)
+			_ = reader.Close()
+			if err != nil {
+				_ = pw.CloseWithError(fmt.Errorf("query %q: %w", query.Name, err))
+				return
+			}
+		}
+		_ = pw.Close()
+	}()
+	return pr
+}
+
+// tagQuerySeries re-encodes an export stream with QuerySetLabel set to name on every series
+func tagQuerySeries(reader io.Reader, name string, encoder *json.Encoder) error {
+	decoder := vm.NewExportDecoder(reader)
+	for {
+		metric, err := decoder.Decode()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if metric.Metric == nil {
+			metric.Metric = make(map[string]string, 1)
+		}
+		metric.Metric[QuerySetLabel] = name
+		if err := encoder.Encode(metric); err != nil {
+			return err
+		}
+	}
+}
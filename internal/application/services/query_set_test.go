@@ -0,0 +1,141 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/archive"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+func TestExecuteExport_NamedQuerySet(t *testing.T) {
+	end := time.Now().UTC().Truncate(time.Minute)
+	start := end.Add(-2 * time.Minute)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		if r.URL.Path != "/api/v1/query_range" {
+			http.NotFound(w, r)
+			return
+		}
+		// Both queries return the same label set, so only the query tag tells them apart
+		value := "1"
+		if strings.Contains(r.FormValue("query"), "rows_inserted") {
+			value = "42"
+		}
+		_, _ = fmt.Fprintf(w, `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"job":"vmstorage"},"values":[[%s,"%s"]]}]}}`, r.FormValue("start"), value)
+	}))
+	defer srv.Close()
+
+	service := &exportServiceImpl{
+		clientFactory:   vm.NewClient,
+		archiveWriter:   archive.NewWriter(t.TempDir()),
+		vmGatherVersion: "test",
+	}
+	querySet := &domain.QuerySet{
+		Name: "health",
+		Queries: []domain.NamedQuery{
+			{Name: "up", Query: `up{job="vmstorage"}`},
+			{Name: "ingest_rate", Query: `sum(rate(vm_rows_inserted_total[5m])) by (job)`},
+		},
+	}
+	config := domain.ExportConfig{
+		Connection:        domain.VMConnection{URL: srv.URL},
+		TimeRange:         domain.TimeRange{Start: start, End: end},
+		QuerySet:          querySet,
+		Batching:          domain.BatchSettings{Enabled: false},
+		StagingDir:        t.TempDir(),
+		MetricStepSeconds: 60,
+	}
+
+	result, err := service.ExecuteExport(context.Background(), config)
+	if err != nil {
+		t.Fatalf("ExecuteExport failed: %v", err)
+	}
+	if result.MetricsExported != 2 {
+		t.Fatalf("expected one series per query, got %d", result.MetricsExported)
+	}
+
+	zr, err := zip.OpenReader(result.ArchivePath)
+	if err != nil {
+		t.Fatalf("failed to open archive: %v", err)
+	}
+	defer func() { _ = zr.Close() }()
+
+	var metadata struct {
+		QuerySet *domain.QuerySet `json:"query_set"`
+	}
+	values := make(map[string]interface{})
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("failed to open %s: %v", f.Name, err)
+		}
+		switch f.Name {
+		case "metadata.json":
+			err = json.NewDecoder(rc).Decode(&metadata)
+		case "metrics.jsonl":
+			decoder := vm.NewExportDecoder(rc)
+			for {
+				metric, decodeErr := decoder.Decode()
+				if decodeErr == io.EOF {
+					break
+				}
+				if decodeErr != nil {
+					err = decodeErr
+					break
+				}
+				values[metric.Metric[QuerySetLabel]] = metric.Values[0]
+			}
+		}
+		_ = rc.Close()
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", f.Name, err)
+		}
+	}
+
+	if len(values) != 2 || values["up"] != float64(1) || values["ingest_rate"] != float64(42) {
+		t.Fatalf("expected both queries tagged by name, got %v", values)
+	}
+	if metadata.QuerySet == nil || metadata.QuerySet.Name != "health" || len(metadata.QuerySet.Queries) != 2 {
+		t.Fatalf("unexpected query set metadata: %+v", metadata.QuerySet)
+	}
+	if metadata.QuerySet.Queries[1] != querySet.Queries[1] {
+		t.Fatalf("expected query definitions in metadata, got %+v", metadata.QuerySet.Queries)
+	}
+}
+
+func TestValidateQuerySet(t *testing.T) {
+	cases := []*domain.QuerySet{
+		{Queries: []domain.NamedQuery{{Name: "a", Query: "up"}}},
+		{Name: "empty"},
+		{Name: "unnamed", Queries: []domain.NamedQuery{{Query: "up"}}},
+		{Name: "blank", Queries: []domain.NamedQuery{{Name: "a"}}},
+		{Name: "dup", Queries: []domain.NamedQuery{{Name: "a", Query: "up"}, {Name: "a", Query: "down"}}},
+	}
+	for _, set := range cases {
+		if err := validateQuerySet(set); err == nil {
+			t.Errorf("expected error for %+v", set)
+		}
+	}
+	if err := validateQuerySet(nil); err != nil {
+		t.Fatalf("nil query set must be valid: %v", err)
+	}
+}
+
+func TestQuerySetMetadataOmitsExpressionsWhenObfuscated(t *testing.T) {
+	set := &domain.QuerySet{Name: "health", Queries: []domain.NamedQuery{{Name: "up", Query: `up{job="secret"}`}}}
+	recorded := querySetMetadata(set, true)
+	if recorded.Queries[0].Name != "up" || recorded.Queries[0].Query != "" {
+		t.Fatalf("expected only query names for obfuscated exports, got %+v", recorded.Queries)
+	}
+}
@@ -0,0 +1,28 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+)
+
+// validateRangeEnd rejects unknown range_end values
+func validateRangeEnd(rangeEnd domain.RangeEnd) error {
+	switch rangeEnd {
+	case "", domain.RangeEndExclusive, domain.RangeEndInclusive:
+		return nil
+	default:
+		return fmt.Errorf("unsupported range_end %q (use %q or %q)", rangeEnd, domain.RangeEndExclusive, domain.RangeEndInclusive)
+	}
+}
+
+// windowRequestEnd is the end a batch window is fetched up to. /api/v1/export and query_range
+// both include samples at start and end, so a window that must not include its end asks for
+// samples up to 1ms before it; that is every window except the last unless range_end is exclusive.
+func windowRequestEnd(config domain.ExportConfig, window domain.TimeRange) time.Time {
+	if config.RangeEnd != domain.RangeEndExclusive && !window.End.Before(config.TimeRange.End) {
+		return window.End
+	}
+	return window.End.Add(-time.Millisecond)
+}
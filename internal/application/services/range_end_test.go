@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/archive"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+// boundaryVMServer serves a series scraped every 30s from origin. Both APIs include samples at
+// start and end like VictoriaMetrics does; with exportAPI false /api/v1/export is missing.
+func boundaryVMServer(t *testing.T, origin time.Time, exportAPI bool) *httptest.Server {
+	t.Helper()
+	const interval = 30 * time.Second
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		switch r.URL.Path {
+		case "/api/v1/export":
+			if !exportAPI {
+				http.Error(w, "missing route", http.StatusNotFound)
+				return
+			}
+			start, _ := time.Parse(time.RFC3339Nano, r.FormValue("start"))
+			end, _ := time.Parse(time.RFC3339Nano, r.FormValue("end"))
+			var timestamps, values []string
+			for ts := origin; !ts.After(end); ts = ts.Add(interval) {
+				if !ts.Before(start) {
+					timestamps = append(timestamps, strconv.FormatInt(ts.UnixMilli(), 10))
+					values = append(values, "1")
+				}
+			}
+			if len(timestamps) == 0 {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			_, _ = fmt.Fprintf(w, `{"metric":{"__name__":"up","job":"test"},"values":[%s],"timestamps":[%s]}`+"\n",
+				strings.Join(values, ","), strings.Join(timestamps, ","))
+		case "/api/v1/query_range":
+			start, _ := strconv.ParseFloat(r.FormValue("start"), 64)
+			end, _ := strconv.ParseFloat(r.FormValue("end"), 64)
+			step, _ := time.ParseDuration(r.FormValue("step"))
+			var points []string
+			for ts := start; ts <= end; ts += step.Seconds() {
+				points = append(points, fmt.Sprintf(`[%g,"1"]`, ts))
+			}
+			_, _ = fmt.Fprintf(w, `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"__name__":"up","job":"test"},"values":[%s]}]}}`,
+				strings.Join(points, ","))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func exportedTimestamps(t *testing.T, archivePath string) []int64 {
+	t.Helper()
+	var timestamps []int64
+	for _, line := range strings.Split(strings.TrimSpace(readZipFiles(t, archivePath)["metrics.jsonl"]), "\n") {
+		if line == "" {
+			continue
+		}
+		var metric vm.ExportedMetric
+		if err := json.Unmarshal([]byte(line), &metric); err != nil {
+			t.Fatalf("failed to decode %q: %v", line, err)
+		}
+		timestamps = append(timestamps, metric.Timestamps...)
+	}
+	return timestamps
+}
+
+func TestExecuteExport_RangeEndSameForExportAndQueryRange(t *testing.T) {
+	start := time.Now().UTC().Truncate(time.Hour).Add(-3 * time.Hour)
+	end := start.Add(4 * time.Minute)
+
+	for _, rangeEnd := range []domain.RangeEnd{"", domain.RangeEndExclusive, domain.RangeEndInclusive} {
+		paths := map[string][]int64{}
+		for _, exportAPI := range []bool{true, false} {
+			srv := boundaryVMServer(t, start, exportAPI)
+			service := &exportServiceImpl{
+				clientFactory:   vm.NewClient,
+				archiveWriter:   archive.NewWriter(t.TempDir()),
+				vmGatherVersion: "test",
+			}
+			result, err := service.ExecuteExport(context.Background(), domain.ExportConfig{
+				Connection:        domain.VMConnection{URL: srv.URL},
+				TimeRange:         domain.TimeRange{Start: start, End: end},
+				Jobs:              []string{"test"},
+				Batching:          domain.BatchSettings{Enabled: true, Strategy: "custom", CustomIntervalSecs: 60},
+				StagingDir:        t.TempDir(),
+				MetricStepSeconds: 30,
+				RangeEnd:          rangeEnd,
+			})
+			srv.Close()
+			if err != nil {
+				t.Fatalf("range_end %q, export API %v: ExecuteExport failed: %v", rangeEnd, exportAPI, err)
+			}
+			timestamps := exportedTimestamps(t, result.ArchivePath)
+			seen := map[int64]bool{}
+			for _, ts := range timestamps {
+				if seen[ts] {
+					t.Fatalf("range_end %q, export API %v: sample at %d exported twice across batch windows", rangeEnd, exportAPI, ts)
+				}
+				seen[ts] = true
+			}
+			if !seen[start.UnixMilli()] {
+				t.Fatalf("range_end %q, export API %v: expected the sample at the range start", rangeEnd, exportAPI)
+			}
+			if seen[end.UnixMilli()] != (rangeEnd != domain.RangeEndExclusive) {
+				t.Fatalf("range_end %q, export API %v: sample at the range end exported = %v", rangeEnd, exportAPI, seen[end.UnixMilli()])
+			}
+			paths[fmt.Sprint(exportAPI)] = timestamps
+		}
+		direct, fallback := paths["true"], paths["false"]
+		if fmt.Sprint(direct) != fmt.Sprint(fallback) {
+			t.Fatalf("range_end %q: export and query_range disagree at the boundaries:\n export:      %v\n query_range: %v", rangeEnd, direct, fallback)
+		}
+	}
+}
+
+func TestWindowRequestEndExcludesInnerWindowEnds(t *testing.T) {
+	start := time.Unix(0, 0)
+	config := domain.ExportConfig{TimeRange: domain.TimeRange{Start: start, End: start.Add(2 * time.Minute)}}
+	inner := domain.TimeRange{Start: start, End: start.Add(time.Minute)}
+	last := domain.TimeRange{Start: start.Add(time.Minute), End: start.Add(2 * time.Minute)}
+
+	for _, tc := range []struct {
+		rangeEnd domain.RangeEnd
+		window   domain.TimeRange
+		want     time.Time
+	}{
+		{rangeEnd: "", window: inner, want: inner.End.Add(-time.Millisecond)},
+		{rangeEnd: "", window: last, want: last.End},
+		{rangeEnd: domain.RangeEndExclusive, window: inner, want: inner.End.Add(-time.Millisecond)},
+		{rangeEnd: domain.RangeEndExclusive, window: last, want: last.End.Add(-time.Millisecond)},
+		{rangeEnd: domain.RangeEndInclusive, window: inner, want: inner.End.Add(-time.Millisecond)},
+		{rangeEnd: domain.RangeEndInclusive, window: last, want: last.End},
+	} {
+		config.RangeEnd = tc.rangeEnd
+		if got := windowRequestEnd(config, tc.window); !got.Equal(tc.want) {
+			t.Errorf("range_end %q, window ending %s: expected request end %s, got %s", tc.rangeEnd, tc.window.End, tc.want, got)
+		}
+	}
+
+	if err := validateRangeEnd("closed"); err == nil || !strings.Contains(err.Error(), "range_end") {
+		t.Fatalf("expected an unknown range_end to be rejected, got %v", err)
+	}
+}
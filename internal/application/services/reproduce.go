@@ -0,0 +1,105 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+)
+
+// buildReproduceScript renders reproduce.sh: the curl command and vmgather config that regenerate
+// this export. The target URL and credentials come from VM_URL / VM_AUTH at run time, so the
+// script never carries the sender's endpoint or secrets. With obfuscation enabled the selector and
+// config are omitted because they would reveal the original job and instance values.
+func buildReproduceScript(exportID string, config domain.ExportConfig, selector string, useQueryRange bool, version string) string {
+	var b strings.Builder
+	start := config.TimeRange.Start.UTC().Format(time.RFC3339)
+	end := config.TimeRange.End.UTC().Format(time.RFC3339)
+
+	b.WriteString("#!/bin/sh\n")
+	fmt.Fprintf(&b, "# Reproduce vmgather export %s (vmgather %s)\n", exportID, version)
+	fmt.Fprintf(&b, "# Time range: %s - %s\n", start, end)
+	fmt.Fprintf(&b, "# Step: %ds\n", config.MetricStepSeconds)
+	b.WriteString("#\n")
+	b.WriteString("# Credentials are not included. Set VM_URL to your VictoriaMetrics API base\n")
+	b.WriteString("# (e.g. http://vmselect:8481/select/0/prometheus) and optionally VM_AUTH to an\n")
+	b.WriteString("# Authorization header value (e.g. \"Bearer <token>\").\n")
+
+	if config.Obfuscation.Enabled {
+		b.WriteString("#\n")
+		b.WriteString("# This export was obfuscated, so the original selector is not included here.\n")
+		b.WriteString("# Ask the sender for the selector and re-run vmgather with the time range above.\n")
+		return b.String()
+	}
+
+	b.WriteString("\nset -eu\n")
+	b.WriteString(": \"${VM_URL:?set VM_URL to the VictoriaMetrics API base URL}\"\n\n")
+
+	b.WriteString("# 1. Raw data via the HTTP API\n")
+	if config.QuerySet != nil {
+		for i, query := range config.QuerySet.Queries {
+			fmt.Fprintf(&b, "# %q / %q\n", config.QuerySet.Name, query.Name)
+			writeQueryRangeCurl(&b, config, query.Query, fmt.Sprintf("query_range_%d.json", i+1))
+		}
+	} else if useQueryRange {
+		if config.AlwaysIncludeUp {
+			selector += " or " + upSelector(config)
+		}
+		writeQueryRangeCurl(&b, config, selector, "query_range.json")
+	} else {
+		b.WriteString("curl -sS ${VM_AUTH:+-H \"Authorization: $VM_AUTH\"} \"$VM_URL/api/v1/export\" \\\n")
+		fmt.Fprintf(&b, "  --data-urlencode %s \\\n", shellQuote("match[]="+selector))
+		if config.AlwaysIncludeUp {
+			fmt.Fprintf(&b, "  --data-urlencode %s \\\n", shellQuote("match[]="+upSelector(config)))
+		}
+		fmt.Fprintf(&b, "  --data-urlencode %s \\\n", shellQuote("start="+start))
+		fmt.Fprintf(&b, "  --data-urlencode %s > metrics.jsonl\n\n", shellQuote("end="+end))
+	}
+
+	b.WriteString("# 2. The same export with vmgather (add auth to the config if required)\n")
+	b.WriteString("cat > vmgather-config.json <<'EOF'\n")
+	b.WriteString(reproduceConfigJSON(config))
+	b.WriteString("EOF\n")
+	b.WriteString("sed -i.bak \"s#__VM_URL__#$VM_URL#\" vmgather-config.json\n")
+	b.WriteString("vmgather -config vmgather-config.json\n")
+	return b.String()
+}
+
+// writeQueryRangeCurl writes the query_range curl command for query over the export range
+func writeQueryRangeCurl(b *strings.Builder, config domain.ExportConfig, query, outFile string) {
+	b.WriteString("curl -sS ${VM_AUTH:+-H \"Authorization: $VM_AUTH\"} \"$VM_URL/api/v1/query_range\" \\\n")
+	fmt.Fprintf(b, "  --data-urlencode %s \\\n", shellQuote("query="+query))
+	fmt.Fprintf(b, "  --data-urlencode %s \\\n", shellQuote("start="+config.TimeRange.Start.UTC().Format(time.RFC3339)))
+	fmt.Fprintf(b, "  --data-urlencode %s \\\n", shellQuote("end="+config.TimeRange.End.UTC().Format(time.RFC3339)))
+	if config.LookbehindSeconds > 0 {
+		fmt.Fprintf(b, "  --data-urlencode %s \\\n", shellQuote(fmt.Sprintf("max_lookback=%ds", config.LookbehindSeconds)))
+	}
+	fmt.Fprintf(b, "  --data-urlencode %s > %s\n\n", shellQuote(fmt.Sprintf("step=%ds", config.MetricStepSeconds)), outFile)
+}
+
+// reproduceConfigJSON returns the export config with connection details, credentials, other
+// endpoints (upload, webhook, vmalert) and local paths removed
+func reproduceConfigJSON(config domain.ExportConfig) string {
+	config.Connection = domain.VMConnection{URL: "__VM_URL__", Auth: domain.AuthConfig{Type: domain.AuthTypeNone}}
+	config.StagingDir = ""
+	config.StagingFile = ""
+	config.ResumeFromBatch = 0
+	config.BaselineArchive = ""
+	config.KeepStaging = false
+	config.IncludeReproduce = false
+	config.Upload = nil
+	config.Webhook = nil
+	config.VMAlertURL = ""
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return "{}\n"
+	}
+	return string(data) + "\n"
+}
+
+// shellQuote wraps s in single quotes for POSIX shells
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
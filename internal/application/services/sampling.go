@@ -0,0 +1,67 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+// maxSampleEveryN keeps sample_every_n a thinning factor rather than a way to drop whole series
+const maxSampleEveryN = 10000
+
+// sampler keeps every Nth raw point of a series. Unlike downsampling, kept points carry their original
+// timestamps and values. Counting restarts with each batch window, as every batch returns its own
+// slice of a series, so the first point of every window is kept.
+type sampler struct {
+	every   int
+	kept    int64
+	dropped int64
+}
+
+// newSampler returns nil when every is 1 or less, which keeps every point
+func newSampler(every int) *sampler {
+	if every <= 1 {
+		return nil
+	}
+	return &sampler{every: every}
+}
+
+// validateSampleEveryN rejects negative and unreasonably large sample_every_n values
+func validateSampleEveryN(every int) error {
+	if every < 0 || every > maxSampleEveryN {
+		return fmt.Errorf("sample_every_n must be between 0 and %d, got %d", maxSampleEveryN, every)
+	}
+	return nil
+}
+
+// apply keeps points 0, N, 2N, ... of the series
+func (s *sampler) apply(metric *vm.ExportedMetric) {
+	if s == nil {
+		return
+	}
+	n := len(metric.Timestamps)
+	if len(metric.Values) != n {
+		s.kept += int64(n)
+		return
+	}
+	keep := (n + s.every - 1) / s.every
+	values := make(vm.SampleValues, 0, keep)
+	timestamps := make([]int64, 0, keep)
+	for i := 0; i < n; i += s.every {
+		values = append(values, metric.Values[i])
+		timestamps = append(timestamps, metric.Timestamps[i])
+	}
+	metric.Values = values
+	metric.Timestamps = timestamps
+	s.kept += int64(keep)
+	s.dropped += int64(n - keep)
+}
+
+// summary records the factor for archive metadata
+func (s *sampler) summary() *domain.SamplingSummary {
+	if s == nil {
+		return nil
+	}
+	return &domain.SamplingSummary{SampleEveryN: s.every, PointsKept: s.kept, PointsDropped: s.dropped}
+}
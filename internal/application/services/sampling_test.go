@@ -0,0 +1,61 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+func TestProcessMetricsSampleEveryN(t *testing.T) {
+	// 7 points keep indices 0, 3, 6; 2 points keep index 0; values are kept as-is
+	input := `{"metric":{"__name__":"a"},"values":[10,11,12,13,14,15,16],"timestamps":[1000,2000,3000,4000,5000,6000,7000]}` + "\n" +
+		`{"metric":{"__name__":"b"},"values":[0.5,0.7],"timestamps":[1000,2000]}` + "\n"
+	s := newSampler(3)
+	var out bytes.Buffer
+	service := &exportServiceImpl{}
+	count, err := service.processMetricsIntoWriter(strings.NewReader(input), domain.ObfuscationConfig{}, nil, processOptions{sampling: s}, &out)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count != 2 {
+		t.Fatalf("expected 2 series, got %d", count)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	want := []struct {
+		values     []float64
+		timestamps []int64
+	}{
+		{values: []float64{10, 13, 16}, timestamps: []int64{1000, 4000, 7000}},
+		{values: []float64{0.5}, timestamps: []int64{1000}},
+	}
+	for i, line := range lines {
+		var metric vm.ExportedMetric
+		if err := json.Unmarshal([]byte(line), &metric); err != nil {
+			t.Fatalf("invalid output line %q: %v", line, err)
+		}
+		if len(metric.Timestamps) != len(want[i].timestamps) {
+			t.Fatalf("series %d: expected %d points, got %v", i, len(want[i].timestamps), metric.Timestamps)
+		}
+		for j := range metric.Timestamps {
+			if metric.Timestamps[j] != want[i].timestamps[j] || metric.Values[j] != want[i].values[j] {
+				t.Fatalf("series %d: expected %v/%v, got %v/%v", i, want[i].values, want[i].timestamps, metric.Values, metric.Timestamps)
+			}
+		}
+	}
+
+	summary := s.summary()
+	if summary == nil || summary.SampleEveryN != 3 || summary.PointsKept != 4 || summary.PointsDropped != 5 {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+	if newSampler(1) != nil || newSampler(0) != nil {
+		t.Fatal("expected sample_every_n 0 and 1 to keep every point")
+	}
+	if err := validateSampleEveryN(-2); err == nil {
+		t.Fatal("expected a negative sample_every_n to be rejected")
+	}
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"sort"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+)
+
+// scrapeIntervalStats collects per-series sample spacing grouped by component.
+// Intervals are kept as millisecond histograms since most series of a component share one interval.
+type scrapeIntervalStats struct {
+	byComponent map[string]map[int64]int
+}
+
+func newScrapeIntervalStats() *scrapeIntervalStats {
+	return &scrapeIntervalStats{byComponent: make(map[string]map[int64]int)}
+}
+
+// observe records the median spacing of one series; series with fewer than two samples are ignored
+func (s *scrapeIntervalStats) observe(component string, timestamps []int64) {
+	if s == nil {
+		return
+	}
+	interval, ok := seriesScrapeInterval(timestamps)
+	if !ok {
+		return
+	}
+	counts := s.byComponent[component]
+	if counts == nil {
+		counts = make(map[int64]int)
+		s.byComponent[component] = counts
+	}
+	counts[interval]++
+}
+
+// summaries returns the median interval of every component, sorted by component name
+func (s *scrapeIntervalStats) summaries() []domain.ScrapeIntervalSummary {
+	if s == nil || len(s.byComponent) == 0 {
+		return nil
+	}
+	result := make([]domain.ScrapeIntervalSummary, 0, len(s.byComponent))
+	for component, counts := range s.byComponent {
+		intervals := make([]int64, 0, len(counts))
+		total := 0
+		for interval, n := range counts {
+			intervals = append(intervals, interval)
+			total += n
+		}
+		sort.Slice(intervals, func(i, j int) bool { return intervals[i] < intervals[j] })
+		var median int64
+		seen := 0
+		for _, interval := range intervals {
+			seen += counts[interval]
+			if seen*2 >= total {
+				median = interval
+				break
+			}
+		}
+		result = append(result, domain.ScrapeIntervalSummary{
+			Component:             component,
+			MedianIntervalSeconds: float64(median) / 1000,
+			Series:                total,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Component < result[j].Component })
+	return result
+}
+
+// seriesScrapeInterval returns the median gap in milliseconds between consecutive timestamps.
+// The median ignores occasional missed scrapes that would skew a mean.
+func seriesScrapeInterval(timestamps []int64) (int64, bool) {
+	if len(timestamps) < 2 {
+		return 0, false
+	}
+	sorted := append([]int64(nil), timestamps...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	deltas := make([]int64, 0, len(sorted)-1)
+	for i := 1; i < len(sorted); i++ {
+		if d := sorted[i] - sorted[i-1]; d > 0 {
+			deltas = append(deltas, d)
+		}
+	}
+	if len(deltas) == 0 {
+		return 0, false
+	}
+	sort.Slice(deltas, func(i, j int) bool { return deltas[i] < deltas[j] })
+	return deltas[len(deltas)/2], true
+}
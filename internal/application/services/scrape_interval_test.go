@@ -0,0 +1,95 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/archive"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+func TestSeriesScrapeInterval(t *testing.T) {
+	// 15s spacing with one missed scrape (30s gap) and out-of-order input
+	timestamps := []int64{45000, 0, 15000, 60000, 90000, 105000, 30000}
+	interval, ok := seriesScrapeInterval(timestamps)
+	if !ok || interval != 15000 {
+		t.Fatalf("expected 15000ms interval, got %d (ok=%v)", interval, ok)
+	}
+	if _, ok := seriesScrapeInterval([]int64{1000}); ok {
+		t.Fatal("expected single-sample series to be ignored")
+	}
+}
+
+func TestExecuteExport_InferScrapeInterval(t *testing.T) {
+	var body string
+	for i := 0; i < 3; i++ {
+		body += fmt.Sprintf(`{"metric":{"__name__":"vmstorage_rows","job":"storage","instance":"node-%d"},"values":[1,2,3,4],"timestamps":[0,30000,60000,90000]}`+"\n", i)
+	}
+	body += `{"metric":{"__name__":"vmagent_rows","job":"agent"},"values":[1,2,3],"timestamps":[0,10000,20000]}` + "\n"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, body)
+	}))
+	defer srv.Close()
+
+	service := &exportServiceImpl{
+		clientFactory:   vm.NewClient,
+		archiveWriter:   archive.NewWriter(t.TempDir()),
+		vmGatherVersion: "test",
+	}
+	config := domain.ExportConfig{
+		Connection:          domain.VMConnection{URL: srv.URL},
+		TimeRange:           domain.TimeRange{Start: time.Now().Add(-5 * time.Minute), End: time.Now()},
+		StagingDir:          t.TempDir(),
+		MetricStepSeconds:   30,
+		InferScrapeInterval: true,
+	}
+
+	result, err := service.ExecuteExport(context.Background(), config)
+	if err != nil {
+		t.Fatalf("ExecuteExport failed: %v", err)
+	}
+	zr, err := zip.OpenReader(result.ArchivePath)
+	if err != nil {
+		t.Fatalf("failed to open archive: %v", err)
+	}
+	defer func() { _ = zr.Close() }()
+
+	var metadata struct {
+		ScrapeIntervals []domain.ScrapeIntervalSummary `json:"scrape_intervals"`
+	}
+	for _, f := range zr.File {
+		if f.Name != "metadata.json" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("failed to open metadata.json: %v", err)
+		}
+		err = json.NewDecoder(rc).Decode(&metadata)
+		_ = rc.Close()
+		if err != nil {
+			t.Fatalf("failed to decode metadata.json: %v", err)
+		}
+	}
+
+	want := []domain.ScrapeIntervalSummary{
+		{Component: "vmagent", MedianIntervalSeconds: 10, Series: 1},
+		{Component: "vmstorage", MedianIntervalSeconds: 30, Series: 3},
+	}
+	if len(metadata.ScrapeIntervals) != len(want) {
+		t.Fatalf("expected %d scrape interval summaries, got %+v", len(want), metadata.ScrapeIntervals)
+	}
+	for i := range want {
+		if metadata.ScrapeIntervals[i] != want[i] {
+			t.Fatalf("summary %d: expected %+v, got %+v", i, want[i], metadata.ScrapeIntervals[i])
+		}
+	}
+}
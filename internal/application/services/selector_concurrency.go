@@ -0,0 +1,132 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+// maxSelectorConcurrency bounds selector_concurrency, so one window cannot open an unbounded
+// number of requests against VictoriaMetrics
+const maxSelectorConcurrency = 16
+
+// validateSelectorConcurrency rejects values outside 0..maxSelectorConcurrency
+func validateSelectorConcurrency(n int) error {
+	if n < 0 || n > maxSelectorConcurrency {
+		return fmt.Errorf("selector_concurrency must be between 0 and %d, got %d", maxSelectorConcurrency, n)
+	}
+	return nil
+}
+
+// selectorGroups deals selectors round-robin into at most n groups
+func selectorGroups(selectors []string, n int) [][]string {
+	if n > len(selectors) {
+		n = len(selectors)
+	}
+	groups := make([][]string, n)
+	for i, selector := range selectors {
+		groups[i%n] = append(groups[i%n], selector)
+	}
+	return groups
+}
+
+// fetchSelectorsConcurrently fetches the selectors of one window in selector_concurrency groups,
+// one request per group, and merges the streams into one. A series matched by selectors of
+// several groups is kept only from the group that returned it first, as a single multi-selector
+// request returns it once; the keys of the window's series are held until the window is read.
+// The data source is the one of the first request to answer; a later request failing fails the
+// stream like a broken connection would.
+func (s *exportServiceImpl) fetchSelectorsConcurrently(ctx context.Context, client *vm.Client, config domain.ExportConfig, selectors []string, window domain.TimeRange, useQueryRange bool) (io.ReadCloser, domain.DataSource, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	pr, pw := io.Pipe()
+	groups := selectorGroups(selectors, config.SelectorConcurrency)
+
+	type opened struct {
+		source domain.DataSource
+		err    error
+	}
+	first := make(chan opened, 1)
+	var firstOnce, failOnce sync.Once
+	fail := func(err error) {
+		failOnce.Do(func() {
+			cancel()
+			_ = pw.CloseWithError(err)
+		})
+	}
+
+	var mu sync.Mutex // guards owners and writes to pw
+	owners := make(map[string]int)
+	var wg sync.WaitGroup
+	for i, group := range groups {
+		wg.Add(1)
+		go func(index int, group []string) {
+			defer wg.Done()
+			reader, source, err := s.fetchBatch(ctx, client, group, window, config.MetricStepSeconds, config.LookbehindSeconds, useQueryRange)
+			firstOnce.Do(func() { first <- opened{source: source, err: err} })
+			if err != nil {
+				fail(err)
+				return
+			}
+			defer func() { _ = reader.Close() }()
+			decoder := vm.NewExportDecoderSize(reader, config.MaxLineBytes)
+			for {
+				metric, err := decoder.Decode()
+				if errors.Is(err, io.EOF) {
+					return
+				}
+				if err != nil {
+					fail(fmt.Errorf("selector group %d: %w", index+1, err))
+					return
+				}
+				data, err := json.Marshal(metric)
+				if err != nil {
+					fail(err)
+					return
+				}
+				key := seriesKey(metric.Metric)
+				mu.Lock()
+				owner, seen := owners[key]
+				if !seen {
+					owners[key] = index
+					owner = index
+				}
+				if owner == index {
+					_, err = pw.Write(append(data, '\n'))
+				}
+				mu.Unlock()
+				if err != nil {
+					fail(err)
+					return
+				}
+			}
+		}(i, group)
+	}
+	go func() {
+		wg.Wait()
+		cancel()
+		_ = pw.Close()
+	}()
+
+	result := <-first
+	if result.err != nil {
+		return nil, "", result.err
+	}
+	return &selectorStream{PipeReader: pr, cancel: cancel}, result.source, nil
+}
+
+// selectorStream stops the remaining selector requests when the merged stream is closed early
+type selectorStream struct {
+	*io.PipeReader
+	cancel context.CancelFunc
+}
+
+func (s *selectorStream) Close() error {
+	s.cancel()
+	return s.PipeReader.Close()
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/archive"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+func TestExecuteExport_SelectorConcurrencyCapturesEverySeriesOnce(t *testing.T) {
+	var series []map[string]string
+	exportLines := make(map[string]string)
+	var upLines []string
+	for i := 0; i < 6; i++ {
+		instance := fmt.Sprintf("host-%d:8482", i)
+		for _, name := range []string{"vm_rows", "up"} {
+			labels := map[string]string{"__name__": name, "job": "vmstorage", "instance": instance}
+			series = append(series, labels)
+			line, _ := json.Marshal(vm.ExportedMetric{Metric: labels, Values: vm.SampleValues{float64(i)}, Timestamps: []int64{1000}})
+			exportLines[seriesKey(labels)] = string(line)
+			if name == "up" {
+				upLines = append(upLines, string(line))
+			}
+		}
+	}
+
+	var exportRequests atomic.Int32
+	srv := newIPv4Server(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/series":
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"status": "success", "data": series})
+		case "/api/v1/export":
+			exportRequests.Add(1)
+			_ = r.ParseForm()
+			// Like VictoriaMetrics, a series matched by several selectors of one request is returned once
+			written := make(map[string]bool)
+			for _, match := range r.Form["match[]"] {
+				lines := []string{exportLines[match]}
+				if match == "up" {
+					lines = upLines
+				}
+				for _, line := range lines {
+					if line != "" && !written[line] {
+						written[line] = true
+						_, _ = fmt.Fprintln(w, line)
+					}
+				}
+			}
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+
+	service := &exportServiceImpl{
+		clientFactory:   vm.NewClient,
+		archiveWriter:   archive.NewWriter(t.TempDir()),
+		vmGatherVersion: "test",
+	}
+	end := time.Now().Truncate(time.Minute)
+	export := func(concurrency int) (*domain.ExportResult, map[string]int) {
+		t.Helper()
+		exportRequests.Store(0)
+		// 12 exact series selectors plus up, which overlaps the up series among them
+		result, err := service.ExecuteExport(context.Background(), domain.ExportConfig{
+			Connection:          domain.VMConnection{URL: srv.URL},
+			TimeRange:           domain.TimeRange{Start: end.Add(-time.Minute), End: end},
+			StagingDir:          t.TempDir(),
+			SeriesLimit:         12,
+			AlwaysIncludeUp:     true,
+			SelectorConcurrency: concurrency,
+		})
+		if err != nil {
+			t.Fatalf("ExecuteExport(selector_concurrency=%d) failed: %v", concurrency, err)
+		}
+		counts := make(map[string]int)
+		data := readZipFiles(t, result.ArchivePath)["metrics.jsonl"]
+		for _, line := range strings.Split(strings.TrimSpace(data), "\n") {
+			var metric vm.ExportedMetric
+			if err := json.Unmarshal([]byte(line), &metric); err != nil {
+				t.Fatalf("invalid exported line %q: %v", line, err)
+			}
+			counts[seriesKey(metric.Metric)]++
+		}
+		return result, counts
+	}
+
+	sequential, want := export(0)
+	if n := exportRequests.Load(); n != 1 {
+		t.Fatalf("expected one export request without selector_concurrency, got %d", n)
+	}
+	result, got := export(4)
+	if n := exportRequests.Load(); n != 4 {
+		t.Fatalf("expected 4 concurrent export requests, got %d", n)
+	}
+	if len(got) != len(series) || len(want) != len(series) {
+		t.Fatalf("expected %d series, got %d concurrently and %d sequentially", len(series), len(got), len(want))
+	}
+	for key, count := range got {
+		if count != 1 {
+			t.Errorf("series %s exported %d times", key, count)
+		}
+	}
+	if result.MetricsExported != sequential.MetricsExported || result.MetricsExported != len(series) {
+		t.Fatalf("expected %d metrics in both runs, got %d and %d", len(series), result.MetricsExported, sequential.MetricsExported)
+	}
+
+	if _, err := service.ExecuteExport(context.Background(), domain.ExportConfig{SelectorConcurrency: maxSelectorConcurrency + 1}); err == nil || !strings.Contains(err.Error(), "selector_concurrency") {
+		t.Fatalf("expected an out-of-range selector_concurrency to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+// validatePerComponentSeriesCap rejects negative caps and caps combined with series pagination
+func validatePerComponentSeriesCap(config domain.ExportConfig) error {
+	if config.PerComponentSeriesCap < 0 {
+		return fmt.Errorf("per_component_series_cap must not be negative, got %d", config.PerComponentSeriesCap)
+	}
+	if config.PerComponentSeriesCap > 0 && config.SeriesLimit > 0 {
+		return fmt.Errorf("per_component_series_cap cannot be combined with series_limit")
+	}
+	return nil
+}
+
+// resolveComponentSeriesCap returns exact-match selectors for at most limit series of every
+// component matched by selector, so one high-cardinality component cannot crowd out the others,
+// with the set of picked series. Each component is listed by its own /api/v1/series request
+// narrowed with extra_filters[] and limited on the server to limit+1 series, the extra one
+// telling whether the component was capped; the full series set is never listed. Which series
+// are returned is up to VictoriaMetrics; they are picked in label order from there.
+func (s *exportServiceImpl) resolveComponentSeriesCap(ctx context.Context, client *vm.Client, selector string, tr domain.TimeRange, limit int) ([]string, seriesSet, *domain.ComponentSeriesCap, error) {
+	filters, err := componentSeriesFilters(ctx, client, selector, tr)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	components := make([]string, 0, len(filters))
+	for component := range filters {
+		components = append(components, component)
+	}
+	sort.Strings(components)
+
+	summary := &domain.ComponentSeriesCap{Cap: limit}
+	kept := make(seriesSet)
+	var picked []map[string]string
+	for _, component := range components {
+		series, err := client.SeriesWithOptions(ctx, selector, tr.Start, tr.End, vm.SeriesOptions{Limit: limit + 1, ExtraFilters: filters[component]})
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("series listing for component %s failed: %w", component, err)
+		}
+		byKey := make(map[string]map[string]string, len(series))
+		keys := make([]string, 0, len(series))
+		for _, labels := range series {
+			key := seriesKey(labels)
+			if _, exists := byKey[key]; exists || s.guessComponent(labels) != component {
+				continue
+			}
+			byKey[key] = labels
+			keys = append(keys, key)
+		}
+		if len(keys) == 0 {
+			continue
+		}
+		sort.Strings(keys)
+
+		count := domain.ComponentSeriesCount{Component: component, ExportedSeries: min(len(keys), limit)}
+		if len(keys) > limit {
+			count.Capped = true
+		} else {
+			count.TotalSeries = len(keys)
+		}
+		for _, key := range keys[:count.ExportedSeries] {
+			kept[key] = struct{}{}
+			picked = append(picked, byKey[key])
+		}
+		summary.Components = append(summary.Components, count)
+	}
+
+	names := labelNames(picked)
+	selectors := make([]string, 0, len(picked))
+	for _, labels := range picked {
+		selectors = append(selectors, exactSeriesSelector(labels, names))
+	}
+	return selectors, kept, summary, nil
+}
+
+// componentSeriesFilters returns, for every component guessComponent can assign to the series
+// matched by selector, extra filters selecting exactly that component's series. They follow
+// guessComponent's order: component label, vm_component label, metric name prefix, job, and
+// "unknown" for the rest. Components without series are dropped once they are listed.
+func componentSeriesFilters(ctx context.Context, client *vm.Client, selector string, tr domain.TimeRange) (map[string][]string, error) {
+	values := func(name string) ([]string, error) {
+		found, err := client.LabelValues(ctx, name, selector, tr.Start, tr.End)
+		if err != nil {
+			return nil, fmt.Errorf("listing %s values failed: %w", name, err)
+		}
+		return found, nil
+	}
+	filters := make(map[string][]string)
+	add := func(component string, matchers ...string) {
+		filters[component] = append(filters[component], "{"+strings.Join(matchers, ",")+"}")
+	}
+
+	components, err := values("component")
+	if err != nil {
+		return nil, err
+	}
+	for _, value := range components {
+		add(value, "component="+quotePromQL(value))
+	}
+	vmComponents, err := values("vm_component")
+	if err != nil {
+		return nil, err
+	}
+	for _, value := range vmComponents {
+		add(value, `component=""`, "vm_component="+quotePromQL(value))
+	}
+
+	const unlabeled = `component="",vm_component=""`
+	for _, prefix := range componentMetricPrefixes {
+		add(prefix, unlabeled, `__name__=~"`+prefix+`_.*"`)
+	}
+	notPrefixed := `__name__!~"(` + strings.Join(componentMetricPrefixes, "|") + `)_.*"`
+	jobs, err := values("job")
+	if err != nil {
+		return nil, err
+	}
+	for _, job := range jobs {
+		add(job, unlabeled, `__name__!=""`, notPrefixed, "job="+quotePromQL(job))
+	}
+	add("unknown", unlabeled, `__name__=""`)
+	add("unknown", unlabeled, `__name__!=""`, notPrefixed, `job=""`)
+	return filters, nil
+}
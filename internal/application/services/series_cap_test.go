@@ -0,0 +1,161 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/archive"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+func TestExecuteExport_PerComponentSeriesCap(t *testing.T) {
+	// vmstorage has 6 series, vmselect and the app job only 2: a cap of 3 trims vmstorage and
+	// keeps the others. The app series nest, so an equality-only selector for the first would
+	// also match the second.
+	var series []map[string]string
+	addSeries := func(name, job string, count int) {
+		for i := 0; i < count; i++ {
+			series = append(series, map[string]string{"__name__": name, "job": job, "instance": fmt.Sprintf("host-%d:8482", i)})
+		}
+	}
+	addSeries("vmstorage_rows", "storage", 6)
+	addSeries("vmselect_requests_total", "select", 2)
+	series = append(series,
+		map[string]string{"__name__": "go_goroutines", "job": "app"},
+		map[string]string{"__name__": "go_goroutines", "job": "app", "shard": "1"},
+	)
+
+	srv := newIPv4Server(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("failed to parse form: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/api/v1/label/"):
+			name := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/v1/label/"), "/values")
+			values := []string{}
+			seen := make(map[string]bool)
+			for _, labels := range series {
+				if value := labels[name]; value != "" && !seen[value] {
+					seen[value] = true
+					values = append(values, value)
+				}
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"status": "success", "data": values})
+		case r.URL.Path == "/api/v1/series":
+			filters := r.Form["extra_filters[]"]
+			limit, _ := strconv.Atoi(r.Form.Get("limit"))
+			if len(filters) == 0 || limit == 0 {
+				t.Errorf("expected a filtered and limited series listing, got %v", r.Form)
+			}
+			matched := []map[string]string{}
+			for _, labels := range series {
+				for _, filter := range filters {
+					if matchesSelector(t, filter, labels) {
+						matched = append(matched, labels)
+						break
+					}
+				}
+			}
+			if len(matched) > limit {
+				matched = matched[:limit]
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"status": "success", "data": matched})
+		case r.URL.Path == "/api/v1/export":
+			for _, labels := range series {
+				for _, match := range r.Form["match[]"] {
+					if matchesSelector(t, match, labels) {
+						line, _ := json.Marshal(vm.ExportedMetric{Metric: labels, Values: vm.SampleValues{1}, Timestamps: []int64{1000}})
+						_, _ = fmt.Fprintln(w, string(line))
+						break
+					}
+				}
+			}
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+
+	service := &exportServiceImpl{
+		clientFactory:   vm.NewClient,
+		archiveWriter:   archive.NewWriter(t.TempDir()),
+		vmGatherVersion: "test",
+	}
+	config := domain.ExportConfig{
+		Connection:            domain.VMConnection{URL: srv.URL},
+		TimeRange:             domain.TimeRange{Start: time.Now().Add(-time.Minute), End: time.Now()},
+		StagingDir:            t.TempDir(),
+		PerComponentSeriesCap: 3,
+	}
+	result, err := service.ExecuteExport(context.Background(), config)
+	if err != nil {
+		t.Fatalf("ExecuteExport failed: %v", err)
+	}
+	if result.MetricsExported != 7 {
+		t.Fatalf("expected 3 vmstorage + 2 vmselect + 2 app series, got %d", result.MetricsExported)
+	}
+
+	zr, err := zip.OpenReader(result.ArchivePath)
+	if err != nil {
+		t.Fatalf("failed to open archive: %v", err)
+	}
+	defer func() { _ = zr.Close() }()
+	var metadata struct {
+		SeriesCap *domain.ComponentSeriesCap `json:"component_series_cap"`
+	}
+	perComponent := make(map[string]int)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("failed to open %s: %v", f.Name, err)
+		}
+		switch f.Name {
+		case "metadata.json":
+			err = json.NewDecoder(rc).Decode(&metadata)
+		case "metrics.jsonl":
+			decoder := json.NewDecoder(rc)
+			for decoder.More() {
+				var metric vm.ExportedMetric
+				if err = decoder.Decode(&metric); err != nil {
+					break
+				}
+				perComponent[metric.Metric["__name__"]]++
+			}
+		}
+		_ = rc.Close()
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", f.Name, err)
+		}
+	}
+
+	if perComponent["vmstorage_rows"] != 3 || perComponent["vmselect_requests_total"] != 2 || perComponent["go_goroutines"] != 2 {
+		t.Fatalf("expected each component capped at 3 series, got %v", perComponent)
+	}
+	want := []domain.ComponentSeriesCount{
+		{Component: "app", TotalSeries: 2, ExportedSeries: 2},
+		{Component: "vmselect", TotalSeries: 2, ExportedSeries: 2},
+		{Component: "vmstorage", ExportedSeries: 3, Capped: true},
+	}
+	if metadata.SeriesCap == nil || metadata.SeriesCap.Cap != 3 || len(metadata.SeriesCap.Components) != len(want) {
+		t.Fatalf("expected cap 3 with %d components in metadata, got %+v", len(want), metadata.SeriesCap)
+	}
+	for i, component := range metadata.SeriesCap.Components {
+		if component != want[i] {
+			t.Fatalf("component %d: expected %+v, got %+v", i, want[i], component)
+		}
+	}
+
+	config.SeriesLimit = 10
+	if _, err := service.ExecuteExport(context.Background(), config); err == nil {
+		t.Fatal("expected per_component_series_cap with series_limit to be rejected")
+	}
+}
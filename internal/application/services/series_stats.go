@@ -0,0 +1,107 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+// seriesStatsAccumulator collects the running figures of one series; a series can span several
+// lines when it was fetched in more than one batch window
+type seriesStatsAccumulator struct {
+	stat     domain.SeriesStat
+	finite   int
+	sum      float64
+	min      float64
+	max      float64
+	last     float64
+	lastSeen int64
+}
+
+func (a *seriesStatsAccumulator) add(metric vm.ExportedMetric) {
+	for i, value := range metric.Values {
+		if i >= len(metric.Timestamps) {
+			break
+		}
+		ts := metric.Timestamps[i]
+		if a.stat.Samples == 0 || ts < a.stat.FirstTimestamp {
+			a.stat.FirstTimestamp = ts
+		}
+		if a.stat.Samples == 0 || ts > a.stat.LastTimestamp {
+			a.stat.LastTimestamp = ts
+		}
+		a.stat.Samples++
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			continue
+		}
+		if a.finite == 0 || value < a.min {
+			a.min = value
+		}
+		if a.finite == 0 || value > a.max {
+			a.max = value
+		}
+		if a.finite == 0 || ts >= a.lastSeen {
+			a.last, a.lastSeen = value, ts
+		}
+		a.sum += value
+		a.finite++
+	}
+}
+
+func (a *seriesStatsAccumulator) summary() domain.SeriesStat {
+	stat := a.stat
+	if a.finite > 0 {
+		avg := a.sum / float64(a.finite)
+		stat.Min, stat.Max, stat.Avg, stat.Last = &a.min, &a.max, &avg, &a.last
+	}
+	return stat
+}
+
+// measureSeriesStats reads what the archive is about to contain and renders series_stats.json:
+// sample count, time span and min/max/avg/last of the finite values for every series. Series
+// are keyed by their full label set, so lines of one series from several windows are merged.
+// Delta-encoded counters are measured on their absolute values, under the labels they import with.
+func measureSeriesStats(reader io.Reader, lineLimit int) ([]byte, error) {
+	bySeries := make(map[string]*seriesStatsAccumulator)
+	decoder := vm.NewExportDecoderSize(reader, lineLimit)
+	for {
+		metric, err := decoder.Decode()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute series stats: %w", err)
+		}
+		decodeCounterDeltas(metric)
+		key := seriesKey(metric.Metric)
+		acc := bySeries[key]
+		if acc == nil {
+			acc = &seriesStatsAccumulator{stat: domain.SeriesStat{Metric: metric.Metric}}
+			bySeries[key] = acc
+		}
+		acc.add(*metric)
+	}
+
+	keys := make([]string, 0, len(bySeries))
+	for key := range bySeries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	series := make([]domain.SeriesStat, 0, len(keys))
+	for _, key := range keys {
+		series = append(series, bySeries[key].summary())
+	}
+	data, err := json.MarshalIndent(struct {
+		Series []domain.SeriesStat `json:"series"`
+	}{series}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode series stats: %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/archive"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+func TestExecuteExport_SeriesStatsSummarizesArchivedSeries(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The series of job a spans two batch windows; job b only has a staleness marker
+		switch requests.Add(1) {
+		case 1:
+			_, _ = w.Write([]byte(`{"metric":{"__name__":"up","job":"a"},"values":[3,1],"timestamps":[1000,2000]}` + "\n" +
+				`{"metric":{"__name__":"up","job":"b"},"values":[null],"timestamps":[1000]}` + "\n"))
+		case 2:
+			_, _ = w.Write([]byte(`{"metric":{"__name__":"up","job":"a"},"values":[4,"NaN"],"timestamps":[3000,4000]}` + "\n"))
+		}
+	}))
+	defer srv.Close()
+
+	service := &exportServiceImpl{
+		clientFactory:   vm.NewClient,
+		archiveWriter:   archive.NewWriter(t.TempDir()),
+		vmGatherVersion: "test",
+	}
+	end := time.Now().Truncate(time.Minute)
+	result, err := service.ExecuteExport(context.Background(), domain.ExportConfig{
+		Connection:  domain.VMConnection{URL: srv.URL},
+		TimeRange:   domain.TimeRange{Start: end.Add(-2 * time.Hour), End: end},
+		Batching:    domain.BatchSettings{Enabled: true, Strategy: "custom", CustomIntervalSecs: 3600},
+		StagingDir:  t.TempDir(),
+		SeriesStats: true,
+	})
+	if err != nil {
+		t.Fatalf("ExecuteExport failed: %v", err)
+	}
+
+	files := readZipFiles(t, result.ArchivePath)
+	var stats struct {
+		Series []domain.SeriesStat `json:"series"`
+	}
+	if err := json.Unmarshal([]byte(files["series_stats.json"]), &stats); err != nil {
+		t.Fatalf("failed to parse series_stats.json: %v\n%s", err, files["series_stats.json"])
+	}
+	if len(stats.Series) != 2 {
+		t.Fatalf("expected 2 series, got %+v", stats.Series)
+	}
+
+	a := stats.Series[0]
+	if a.Metric["job"] != "a" || a.Samples != 4 || a.FirstTimestamp != 1000 || a.LastTimestamp != 4000 {
+		t.Fatalf("unexpected counts for job a: %+v", a)
+	}
+	if a.Min == nil || *a.Min != 1 || *a.Max != 4 || *a.Avg != 8.0/3 || *a.Last != 4 {
+		t.Fatalf("expected min 1, max 4, avg 8/3 and last 4 for job a, got %+v", a)
+	}
+	b := stats.Series[1]
+	if b.Metric["job"] != "b" || b.Samples != 1 || b.Min != nil || b.Avg != nil || b.Last != nil {
+		t.Fatalf("expected job b to have one sample and no finite figures, got %+v", b)
+	}
+}
+
+func TestMeasureSeriesStats_DecodesCounterDeltas(t *testing.T) {
+	// Two windows of one counter, each delta-encoded on its own: 100,130,170 and 175,200
+	input := `{"metric":{"__name__":"requests_total","job":"a","vmgather_counter_encoding":"delta"},"values":[100,30,40],"timestamps":[1000,2000,3000]}` + "\n" +
+		`{"metric":{"__name__":"requests_total","job":"a","vmgather_counter_encoding":"delta"},"values":[175,25],"timestamps":[4000,5000]}` + "\n"
+	data, err := measureSeriesStats(strings.NewReader(input), 0)
+	if err != nil {
+		t.Fatalf("measureSeriesStats failed: %v", err)
+	}
+	var stats struct {
+		Series []domain.SeriesStat `json:"series"`
+	}
+	if err := json.Unmarshal(data, &stats); err != nil {
+		t.Fatalf("failed to parse stats: %v", err)
+	}
+	if len(stats.Series) != 1 {
+		t.Fatalf("expected one series, got %+v", stats.Series)
+	}
+	got := stats.Series[0]
+	if _, marked := got.Metric[domain.CounterEncodingLabel]; marked || got.Metric["job"] != "a" {
+		t.Fatalf("expected the imported label set, got %v", got.Metric)
+	}
+	if got.Samples != 5 || *got.Min != 100 || *got.Max != 200 || *got.Last != 200 || *got.Avg != 155 {
+		t.Fatalf("expected stats over absolute values, got %+v", got)
+	}
+}
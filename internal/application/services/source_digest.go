@@ -0,0 +1,49 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+// sourceDigest is an order-independent SHA256 digest of the series as VictoriaMetrics returned
+// them, before any label policy, obfuscation or sampling: the SHA256 of every series line,
+// re-encoded with sorted labels, is added up modulo 2^256. Exporting the same range with the same
+// batch settings from unchanged data gives the same digest in whatever order the series arrive.
+type sourceDigest struct {
+	sum [sha256.Size]byte
+}
+
+func newSourceDigest() *sourceDigest {
+	return &sourceDigest{}
+}
+
+// observe adds a freshly decoded series to the digest
+func (d *sourceDigest) observe(metric *vm.ExportedMetric) error {
+	if d == nil {
+		return nil
+	}
+	// encoding/json writes map keys sorted, so label order in the response does not matter
+	data, err := json.Marshal(metric)
+	if err != nil {
+		return err
+	}
+	line := sha256.Sum256(data)
+	carry := 0
+	for i := len(d.sum) - 1; i >= 0; i-- {
+		v := int(d.sum[i]) + int(line[i]) + carry
+		d.sum[i] = byte(v)
+		carry = v >> 8
+	}
+	return nil
+}
+
+// hex returns the digest, or "" when the source was not hashed
+func (d *sourceDigest) hex() string {
+	if d == nil {
+		return ""
+	}
+	return hex.EncodeToString(d.sum[:])
+}
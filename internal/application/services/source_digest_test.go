@@ -0,0 +1,71 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/archive"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+func TestExecuteExport_SourceSHA256IsStableAcrossExports(t *testing.T) {
+	lines := []string{
+		`{"metric":{"__name__":"vm_rows","job":"vmstorage","instance":"10.0.0.1:8482"},"values":[1,2],"timestamps":[1000,2000]}`,
+		`{"metric":{"instance":"10.0.0.2:8482","job":"vmstorage","__name__":"vm_rows"},"values":[3,4],"timestamps":[1000,2000]}`,
+		`{"metric":{"__name__":"up","job":"vmstorage","instance":"10.0.0.1:8482"},"values":[1],"timestamps":[1000]}`,
+	}
+	response := lines
+	srv := newIPv4Server(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(strings.Join(response, "\n") + "\n"))
+	}))
+	defer srv.Close()
+
+	service := &exportServiceImpl{
+		clientFactory:   vm.NewClient,
+		archiveWriter:   archive.NewWriter(t.TempDir()),
+		vmGatherVersion: "test",
+	}
+	end := time.Now().Truncate(time.Minute)
+	export := func(obfuscate bool) *domain.ExportResult {
+		t.Helper()
+		result, err := service.ExecuteExport(context.Background(), domain.ExportConfig{
+			Connection:  domain.VMConnection{URL: srv.URL},
+			TimeRange:   domain.TimeRange{Start: end.Add(-5 * time.Minute), End: end},
+			StagingDir:  t.TempDir(),
+			Obfuscation: domain.ObfuscationConfig{Enabled: obfuscate, ObfuscateInstance: true},
+		})
+		if err != nil {
+			t.Fatalf("ExecuteExport failed: %v", err)
+		}
+		return result
+	}
+
+	first := export(false)
+	if len(first.SourceSHA256) != 64 {
+		t.Fatalf("expected a hex SHA256 source hash, got %q", first.SourceSHA256)
+	}
+	// Same data in another order and obfuscated: the archive differs, the source does not
+	response = []string{lines[2], lines[0], lines[1]}
+	second := export(true)
+	if second.SourceSHA256 != first.SourceSHA256 {
+		t.Fatalf("expected identical source hashes, got %s and %s", first.SourceSHA256, second.SourceSHA256)
+	}
+	if second.SHA256 == first.SHA256 {
+		t.Fatal("expected the obfuscated archive to differ from the plain one")
+	}
+	for name, content := range readZipFiles(t, second.ArchivePath) {
+		if strings.Contains(content, second.SourceSHA256) {
+			t.Fatalf("source hash must stay out of the archive, found in %s", name)
+		}
+	}
+
+	// A changed sample changes the hash
+	response = []string{lines[0], strings.Replace(lines[1], "[3,4]", "[3,5]", 1), lines[2]}
+	if changed := export(false); changed.SourceSHA256 == first.SourceSHA256 {
+		t.Fatal("expected changed source data to change the source hash")
+	}
+}
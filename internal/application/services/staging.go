@@ -0,0 +1,216 @@
+package services
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// StagingFileName is the staging file of an export or job ID: <id>.partial.jsonl, plus .gz with staging_gzip
+func StagingFileName(id string, gzipped bool) string {
+	if gzipped {
+		return id + ".partial.jsonl.gz"
+	}
+	return id + ".partial.jsonl"
+}
+
+// createStagingFile creates the staging file of a new export. It fails when the file already
+// exists: export IDs name staging files directly, so an existing file belongs to another export
+// with the same ID that is still running or was left behind for resume.
+func createStagingFile(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o640)
+	if errors.Is(err, os.ErrExist) {
+		return nil, fmt.Errorf("staging file %s already exists: another export with this export_id is running or left it for resume; use a different export_id or remove the file", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging file: %w", err)
+	}
+	return file, nil
+}
+
+// stagingSink writes processed series to the staging file. With staging_gzip every batch is
+// closed as its own gzip member, so the file is always a valid multi-member gzip stream up to
+// the last finished batch and resume simply appends new members.
+type stagingSink struct {
+	file    *os.File
+	buffer  *bufio.Writer
+	gz      *gzip.Writer // nil for plain JSONL staging
+	pending bool         // gz has data for an unfinished member
+}
+
+// openStagingSink opens the staging file for a new export or, on resume, for appending. It returns
+// the uncompressed size of the data already staged, which counts against max_bytes.
+func openStagingSink(path string, resume, gzipped bool, bufferSize int) (*stagingSink, int64, error) {
+	var staged int64
+	if resume && gzipped {
+		// A crash can leave a torn member behind; drop it so appended members stay readable
+		valid, size, err := scanGzipMembers(path)
+		if err != nil {
+			return nil, 0, err
+		}
+		if err := os.Truncate(path, valid); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, 0, fmt.Errorf("failed to truncate staging file: %w", err)
+		}
+		staged = size
+	}
+	var file *os.File
+	if resume {
+		appended, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to open staging file: %w", err)
+		}
+		file = appended
+	} else {
+		created, err := createStagingFile(path)
+		if err != nil {
+			return nil, 0, err
+		}
+		file = created
+	}
+	if resume && !gzipped {
+		if info, err := file.Stat(); err == nil {
+			staged = info.Size()
+		}
+	}
+	if bufferSize <= 0 {
+		bufferSize = defaultStagingBufferSize
+	}
+	sink := &stagingSink{file: file, buffer: bufio.NewWriterSize(file, bufferSize)}
+	if gzipped {
+		sink.gz = gzip.NewWriter(sink.buffer)
+	}
+	return sink, staged, nil
+}
+
+func (s *stagingSink) Write(p []byte) (int, error) {
+	if s.gz == nil {
+		return s.buffer.Write(p)
+	}
+	s.pending = true
+	return s.gz.Write(p)
+}
+
+// endBatch finishes the current gzip member and hands everything staged so far to the OS
+func (s *stagingSink) endBatch() error {
+	if s.gz != nil && s.pending {
+		if err := s.gz.Close(); err != nil {
+			return err
+		}
+		s.gz.Reset(s.buffer)
+		s.pending = false
+	}
+	return s.buffer.Flush()
+}
+
+// reset empties the staging file once archive_per_batch has sealed its contents
+func (s *stagingSink) reset() error {
+	if err := s.endBatch(); err != nil {
+		return err
+	}
+	if err := s.file.Truncate(0); err != nil {
+		return err
+	}
+	_, err := s.file.Seek(0, io.SeekStart)
+	return err
+}
+
+func (s *stagingSink) close() error {
+	flushErr := s.endBatch()
+	closeErr := s.file.Close()
+	if flushErr != nil {
+		return flushErr
+	}
+	return closeErr
+}
+
+// openStagingReader opens the finished staging file for archive creation
+func openStagingReader(path string, gzipped bool) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if !gzipped {
+		return file, nil
+	}
+	info, err := file.Stat()
+	if err == nil && info.Size() == 0 {
+		// Nothing was staged, e.g. the selector matched no series
+		return file, nil
+	}
+	gz, err := gzip.NewReader(bufio.NewReader(file))
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("invalid gzip staging file %s: %w", filepath.Base(path), err)
+	}
+	return &stagingReader{Reader: gz, file: file}, nil
+}
+
+type stagingReader struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (r *stagingReader) Close() error {
+	_ = r.Reader.Close()
+	return r.file.Close()
+}
+
+// scanGzipMembers returns the length of the readable prefix of complete gzip members in path
+// and their uncompressed size. A missing file is an empty prefix.
+func scanGzipMembers(path string) (int64, int64, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to open staging file: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	in := &offsetReader{r: bufio.NewReader(file)}
+	var valid, size int64
+	var gz *gzip.Reader
+	for {
+		if gz == nil {
+			gz, err = gzip.NewReader(in)
+		} else {
+			err = gz.Reset(in)
+		}
+		if err != nil {
+			// io.EOF: no further member; anything else is a torn header
+			return valid, size, nil
+		}
+		gz.Multistream(false)
+		n, err := io.Copy(io.Discard, gz)
+		if err != nil {
+			return valid, size, nil
+		}
+		valid = in.n
+		size += n
+	}
+}
+
+// offsetReader counts consumed bytes; as an io.ByteReader it keeps gzip from reading ahead,
+// so n is exactly the end of the last member read
+type offsetReader struct {
+	r *bufio.Reader
+	n int64
+}
+
+func (o *offsetReader) Read(p []byte) (int, error) {
+	n, err := o.r.Read(p)
+	o.n += int64(n)
+	return n, err
+}
+
+func (o *offsetReader) ReadByte() (byte, error) {
+	b, err := o.r.ReadByte()
+	if err == nil {
+		o.n++
+	}
+	return b, err
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/archive"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+func TestExecuteExport_GzipStagingMatchesPlainStaging(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		_, _ = fmt.Fprintf(w, `{"metric":{"__name__":"up","job":"api","batch":"%d"},"values":[1,0],"timestamps":[1000,2000]}`+"\n", calls)
+	}))
+	defer srv.Close()
+
+	end := time.Now().Truncate(time.Hour)
+	export := func(gzipped bool) (string, string) {
+		calls = 0
+		service := &exportServiceImpl{
+			clientFactory:   vm.NewClient,
+			archiveWriter:   archive.NewWriter(t.TempDir()),
+			vmGatherVersion: "test",
+		}
+		stagingDir := t.TempDir()
+		result, err := service.ExecuteExport(context.Background(), domain.ExportConfig{
+			ExportID:          "gzip-staging",
+			Connection:        domain.VMConnection{URL: srv.URL},
+			TimeRange:         domain.TimeRange{Start: end.Add(-3 * time.Hour), End: end},
+			Batching:          domain.BatchSettings{Enabled: true, Strategy: "custom", CustomIntervalSecs: 3600},
+			StagingDir:        stagingDir,
+			StagingGzip:       gzipped,
+			KeepStaging:       true,
+			MetricStepSeconds: 30,
+		})
+		if err != nil {
+			t.Fatalf("gzip=%t: ExecuteExport failed: %v", gzipped, err)
+		}
+		zr, err := zip.OpenReader(result.ArchivePath)
+		if err != nil {
+			t.Fatalf("failed to open archive: %v", err)
+		}
+		defer func() { _ = zr.Close() }()
+		for _, f := range zr.File {
+			if f.Name != "metrics.jsonl" {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				t.Fatalf("failed to open metrics.jsonl: %v", err)
+			}
+			data, _ := io.ReadAll(rc)
+			_ = rc.Close()
+			return string(data), filepath.Join(stagingDir, StagingFileName("gzip-staging", gzipped))
+		}
+		t.Fatalf("gzip=%t: archive has no metrics.jsonl", gzipped)
+		return "", ""
+	}
+
+	plain, _ := export(false)
+	compressed, stagingPath := export(true)
+	if strings.Count(plain, "\n") != 3 {
+		t.Fatalf("expected one series per batch, got %q", plain)
+	}
+	if compressed != plain {
+		t.Fatalf("gzip staging changed the archive contents:\nplain: %q\ngzip:  %q", plain, compressed)
+	}
+	if _, err := os.Stat(stagingPath); err != nil {
+		t.Fatalf("expected gzip staging file %s to be kept: %v", stagingPath, err)
+	}
+}
+
+func TestOpenStagingSink_ResumeDropsTornGzipMember(t *testing.T) {
+	path := filepath.Join(t.TempDir(), StagingFileName("resume", true))
+	sink, _, err := openStagingSink(path, false, true, 0)
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	for _, line := range []string{"first\n", "second\n"} {
+		_, _ = sink.Write([]byte(line))
+		if err := sink.endBatch(); err != nil {
+			t.Fatalf("endBatch failed: %v", err)
+		}
+	}
+	_ = sink.close()
+	// Simulate a crash in the middle of the third batch
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o640)
+	_, _ = f.Write([]byte{0x1f, 0x8b, 8, 0, 0, 0})
+	_ = f.Close()
+
+	sink, staged, err := openStagingSink(path, true, true, 0)
+	if err != nil {
+		t.Fatalf("resume failed: %v", err)
+	}
+	if staged != int64(len("first\nsecond\n")) {
+		t.Fatalf("expected uncompressed staged size %d, got %d", len("first\nsecond\n"), staged)
+	}
+	_, _ = sink.Write([]byte("third\n"))
+	if err := sink.close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+
+	reader, err := openStagingReader(path, true)
+	if err != nil {
+		t.Fatalf("openStagingReader failed: %v", err)
+	}
+	defer func() { _ = reader.Close() }()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if string(data) != "first\nsecond\nthird\n" {
+		t.Fatalf("unexpected staged data %q", data)
+	}
+}
+
+func TestOpenStagingSink_RejectsExistingStagingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), StagingFileName("incident-42", false))
+	if err := os.WriteFile(path, []byte("batches of a concurrent export\n"), 0o640); err != nil {
+		t.Fatalf("failed to seed staging file: %v", err)
+	}
+
+	if _, _, err := openStagingSink(path, false, false, 0); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("expected an existing staging file to be rejected, got %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "batches of a concurrent export\n" {
+		t.Fatalf("expected the existing staging file to be left intact, got %q (%v)", data, err)
+	}
+}
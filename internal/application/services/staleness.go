@@ -0,0 +1,51 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+// validateStalenessMarkers rejects unknown staleness_markers values
+func validateStalenessMarkers(policy domain.StalenessPolicy) error {
+	switch policy {
+	case "", domain.StalenessMarkersPreserve, domain.StalenessMarkersStrip:
+		return nil
+	default:
+		return fmt.Errorf("unsupported staleness_markers %q (use %q or %q)", policy, domain.StalenessMarkersPreserve, domain.StalenessMarkersStrip)
+	}
+}
+
+// hasRealSamples reports whether a series is real data rather than a meta line: it needs a
+// metric name and at least one value that is not a staleness marker
+func hasRealSamples(metric *vm.ExportedMetric) bool {
+	if metric.Metric["__name__"] == "" {
+		return false
+	}
+	for _, value := range metric.Values {
+		if !vm.IsStaleNaN(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// stripStalenessMarkers removes samples whose value is a staleness marker, which
+// /api/v1/export encodes as null, together with their timestamps
+func stripStalenessMarkers(metric *vm.ExportedMetric) {
+	if len(metric.Values) != len(metric.Timestamps) {
+		return
+	}
+	kept := 0
+	for i, value := range metric.Values {
+		if vm.IsStaleNaN(value) {
+			continue
+		}
+		metric.Values[kept] = value
+		metric.Timestamps[kept] = metric.Timestamps[i]
+		kept++
+	}
+	metric.Values = metric.Values[:kept]
+	metric.Timestamps = metric.Timestamps[:kept]
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+)
+
+func TestProcessMetricsStalenessMarkers(t *testing.T) {
+	input := `{"metric":{"__name__":"up","job":"a"},"values":[1,null,1],"timestamps":[1000,2000,3000]}` + "\n" +
+		`{"metric":{"__name__":"up","job":"b"},"values":[null],"timestamps":[1000]}` + "\n"
+	tests := []struct {
+		policy    domain.StalenessPolicy
+		wantCount int
+		want      string
+	}{
+		{policy: domain.StalenessMarkersPreserve, wantCount: 2, want: `"values":[1,null,1],"timestamps":[1000,2000,3000]`},
+		{policy: domain.StalenessMarkersStrip, wantCount: 1, want: `"values":[1,1],"timestamps":[1000,3000]`},
+	}
+	service := &exportServiceImpl{}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		opts := processOptions{stripStale: tt.policy == domain.StalenessMarkersStrip}
+		count, err := service.processMetricsIntoWriter(strings.NewReader(input), domain.ObfuscationConfig{}, nil, opts, &out)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.policy, err)
+		}
+		if count != tt.wantCount {
+			t.Fatalf("%s: expected %d series, got %d", tt.policy, tt.wantCount, count)
+		}
+		if !strings.Contains(out.String(), tt.want) {
+			t.Fatalf("%s: expected output to contain %s, got %s", tt.policy, tt.want, out.String())
+		}
+	}
+	if err := validateStalenessMarkers("keep"); err == nil {
+		t.Fatal("expected unknown staleness_markers value to be rejected")
+	}
+}
+
+func TestProcessMetricsCountsSeriesWithSamples(t *testing.T) {
+	// Only the first line is real data: the others are nameless or staleness-only
+	input := `{"metric":{"__name__":"up","job":"a"},"values":[1,null],"timestamps":[1000,2000]}` + "\n" +
+		`{"metric":{"job":"a"},"values":[1],"timestamps":[1000]}` + "\n" +
+		`{"metric":{"__name__":"up","job":"b"},"values":[null,null],"timestamps":[1000,2000]}` + "\n"
+	sampled := 0
+	service := &exportServiceImpl{}
+	var out bytes.Buffer
+	count, err := service.processMetricsIntoWriter(strings.NewReader(input), domain.ObfuscationConfig{}, nil, processOptions{sampled: &sampled}, &out)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count != 3 {
+		t.Fatalf("expected every line to be counted as processed, got %d", count)
+	}
+	if sampled != 1 {
+		t.Fatalf("expected 1 series with samples, got %d", sampled)
+	}
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+)
+
+// stepCoarsener raises the query_range step for later batches when a batch returned more points
+// than max_points_per_batch. The step grows by the factor the batch was over the limit, so the
+// next window of the same density fits; it never shrinks again and is capped at one day. Raw
+// /api/v1/export batches have no step and are left alone. A nil coarsener keeps the configured step.
+type stepCoarsener struct {
+	maxPoints   int64
+	stepSeconds int
+	out         io.Writer // receives the warning printed when the step is raised
+}
+
+func newStepCoarsener(config domain.ExportConfig, out io.Writer) *stepCoarsener {
+	if config.MaxPointsPerBatch <= 0 {
+		return nil
+	}
+	return &stepCoarsener{maxPoints: int64(config.MaxPointsPerBatch), stepSeconds: config.MetricStepSeconds, out: out}
+}
+
+// validateMaxPointsPerBatch rejects negative max_points_per_batch values
+func validateMaxPointsPerBatch(limit int) error {
+	if limit < 0 {
+		return fmt.Errorf("max_points_per_batch must not be negative, got %d", limit)
+	}
+	return nil
+}
+
+// apply returns config with the step the next batch should use
+func (c *stepCoarsener) apply(config domain.ExportConfig) domain.ExportConfig {
+	if c != nil {
+		config.MetricStepSeconds = c.stepSeconds
+	}
+	return config
+}
+
+// observe raises the step after a query_range batch that wrote more than maxPoints points
+func (c *stepCoarsener) observe(points int64, source domain.DataSource, window domain.TimeRange) {
+	if c == nil || source != domain.DataSourceQueryRange || points <= c.maxPoints {
+		return
+	}
+	step := int(determineQueryRangeStep(window, c.stepSeconds).Seconds())
+	factor := (points + c.maxPoints - 1) / c.maxPoints
+	next := int(min(int64(step)*factor, MaxBatchIntervalSeconds))
+	if next <= step {
+		return
+	}
+	_, _ = fmt.Fprintf(c.out, "[WARN] Batch returned %d points (max_points_per_batch %d); raising the query_range step from %ds to %ds\n",
+		points, c.maxPoints, step, next)
+	c.stepSeconds = next
+}
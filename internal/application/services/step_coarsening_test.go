@@ -0,0 +1,106 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/archive"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+func TestExecuteExport_CoarsensStepAfterDenseBatch(t *testing.T) {
+	end := time.Now().UTC().Truncate(time.Hour)
+	start := end.Add(-30 * time.Minute)
+
+	var mu sync.Mutex
+	var steps []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		if r.URL.Path != "/api/v1/query_range" {
+			http.NotFound(w, r)
+			return
+		}
+		mu.Lock()
+		steps = append(steps, r.FormValue("step"))
+		mu.Unlock()
+		// One point per step, like a densely scraped series
+		from, _ := strconv.ParseFloat(r.FormValue("start"), 64)
+		to, _ := strconv.ParseFloat(r.FormValue("end"), 64)
+		step, _ := time.ParseDuration(r.FormValue("step"))
+		var values []string
+		for ts := from; ts <= to; ts += step.Seconds() {
+			values = append(values, fmt.Sprintf(`[%.0f,"1"]`, ts))
+		}
+		_, _ = fmt.Fprintf(w, `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"__name__":"up","job":"test"},"values":[%s]}]}}`, strings.Join(values, ","))
+	}))
+	defer srv.Close()
+
+	service := &exportServiceImpl{
+		clientFactory:   vm.NewClient,
+		archiveWriter:   archive.NewWriter(t.TempDir()),
+		vmGatherVersion: "test",
+	}
+	config := domain.ExportConfig{
+		Connection:        domain.VMConnection{URL: srv.URL},
+		TimeRange:         domain.TimeRange{Start: start, End: end},
+		Jobs:              []string{"test"},
+		Batching:          domain.BatchSettings{Enabled: true, Strategy: "custom", CustomIntervalSecs: 600},
+		StagingDir:        t.TempDir(),
+		MetricStepSeconds: 30,
+		MaxPointsPerBatch: 5,
+	}
+
+	result, err := service.ExecuteExport(context.Background(), config)
+	if err != nil {
+		t.Fatalf("ExecuteExport failed: %v", err)
+	}
+	// 20 points against a limit of 5 raise the step fourfold; 120s batches fit and keep it
+	if want := []string{"30s", "120s", "120s"}; strings.Join(steps, " ") != strings.Join(want, " ") {
+		t.Fatalf("expected query_range steps %v, got %v", want, steps)
+	}
+
+	zr, err := zip.OpenReader(result.ArchivePath)
+	if err != nil {
+		t.Fatalf("failed to open archive: %v", err)
+	}
+	defer func() { _ = zr.Close() }()
+	var metadata struct {
+		Fidelity []domain.BatchFidelity `json:"fidelity"`
+	}
+	for _, f := range zr.File {
+		if f.Name != "metadata.json" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("failed to open metadata.json: %v", err)
+		}
+		err = json.NewDecoder(rc).Decode(&metadata)
+		_ = rc.Close()
+		if err != nil {
+			t.Fatalf("failed to decode metadata.json: %v", err)
+		}
+	}
+	if len(metadata.Fidelity) != 2 {
+		t.Fatalf("expected two fidelity runs, got %+v", metadata.Fidelity)
+	}
+	first, second := metadata.Fidelity[0], metadata.Fidelity[1]
+	if first.Batches != 1 || first.StepSeconds != 30 || second.Batches != 2 || second.StepSeconds != 120 {
+		t.Fatalf("expected one batch at 30s then two at 120s, got %+v", metadata.Fidelity)
+	}
+
+	config.MaxPointsPerBatch = -1
+	if _, err := service.ExecuteExport(context.Background(), config); err == nil || !strings.Contains(err.Error(), "max_points_per_batch") {
+		t.Fatalf("expected negative max_points_per_batch to be rejected, got %v", err)
+	}
+}
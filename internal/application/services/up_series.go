@@ -0,0 +1,33 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+)
+
+// upSelector matches the up series of the exported targets: the selected jobs and instances,
+// or every target when the export is not narrowed to any
+func upSelector(config domain.ExportConfig) string {
+	if len(config.Jobs) == 0 && len(config.Instances) == 0 {
+		return "up"
+	}
+	return "up" + buildTargetFilterSelector(config.Jobs, config.Instances)
+}
+
+// validateAlwaysIncludeUp rejects always_include_up where up cannot be added as another selector
+func validateAlwaysIncludeUp(config domain.ExportConfig) error {
+	if config.AlwaysIncludeUp && config.QuerySet != nil {
+		return fmt.Errorf("always_include_up is not supported with query_set; add an up query to the set instead")
+	}
+	return nil
+}
+
+// withUpSelector appends the up selector for always_include_up, so target liveness is exported
+// whatever the other selectors match. An empty series page stays empty.
+func withUpSelector(config domain.ExportConfig, selectors []string) []string {
+	if !config.AlwaysIncludeUp || len(selectors) == 0 {
+		return selectors
+	}
+	return append(selectors[:len(selectors):len(selectors)], upSelector(config))
+}
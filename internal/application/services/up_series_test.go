@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/archive"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+func TestExecuteExport_AlwaysIncludeUp(t *testing.T) {
+	var matches []string
+	vmSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		matches = r.Form["match[]"]
+		for _, match := range matches {
+			switch match {
+			case `{__name__=~"vm_.*"}`:
+				_, _ = io.WriteString(w, `{"metric":{"__name__":"vm_rows","job":"api"},"values":[5],"timestamps":[1000]}`+"\n")
+			case "up":
+				_, _ = io.WriteString(w, `{"metric":{"__name__":"up","job":"api"},"values":[0],"timestamps":[1000]}`+"\n")
+			}
+		}
+	}))
+	defer vmSrv.Close()
+
+	service := &exportServiceImpl{
+		clientFactory:   vm.NewClient,
+		archiveWriter:   archive.NewWriter(t.TempDir()),
+		vmGatherVersion: "test",
+	}
+	result, err := service.ExecuteExport(context.Background(), domain.ExportConfig{
+		Connection:        domain.VMConnection{URL: vmSrv.URL},
+		TimeRange:         domain.TimeRange{Start: time.Now().Add(-5 * time.Minute), End: time.Now()},
+		Mode:              domain.ExportModeCustom,
+		QueryType:         domain.QueryModeSelector,
+		Query:             `{__name__=~"vm_.*"}`,
+		StagingDir:        t.TempDir(),
+		MetricStepSeconds: 30,
+		AlwaysIncludeUp:   true,
+	})
+	if err != nil {
+		t.Fatalf("ExecuteExport failed: %v", err)
+	}
+	if len(matches) != 2 || matches[1] != "up" {
+		t.Fatalf("expected up to be requested next to the selector, got %v", matches)
+	}
+	files := readZipFiles(t, result.ArchivePath)
+	if !strings.Contains(files["metrics.jsonl"], `"__name__":"up"`) || !strings.Contains(files["metrics.jsonl"], `"__name__":"vm_rows"`) {
+		t.Fatalf("expected both the selected series and up, got %s", files["metrics.jsonl"])
+	}
+	var metadata struct {
+		AlwaysIncludeUp bool `json:"always_include_up"`
+	}
+	if err := json.Unmarshal([]byte(files["metadata.json"]), &metadata); err != nil {
+		t.Fatalf("invalid metadata: %v", err)
+	}
+	if !metadata.AlwaysIncludeUp || !strings.Contains(files["README.txt"], "always_include_up") {
+		t.Fatal("expected always_include_up to be recorded in metadata.json and README.txt")
+	}
+
+	if got := upSelector(domain.ExportConfig{Jobs: []string{"api", "db"}}); got != `up{job=~"api|db"}` {
+		t.Fatalf("unexpected up selector for jobs: %s", got)
+	}
+	if got := withUpSelector(domain.ExportConfig{AlwaysIncludeUp: true}, nil); len(got) != 0 {
+		t.Fatalf("expected an empty series page to stay empty, got %v", got)
+	}
+	if err := validateAlwaysIncludeUp(domain.ExportConfig{AlwaysIncludeUp: true, QuerySet: &domain.QuerySet{Name: "set"}}); err == nil {
+		t.Fatal("expected always_include_up with a query set to be rejected")
+	}
+}
@@ -0,0 +1,41 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/upload"
+)
+
+// uploadArchives streams every archive of the export (one, or one per batch with archive_per_batch)
+// to the upload target. A failed upload is recorded in result.Uploads and as a warning; the local
+// archive is never removed, so it can still be delivered by hand. Outcomes are also printed to out.
+func uploadArchives(ctx context.Context, target *domain.UploadTarget, result *domain.ExportResult, out io.Writer) {
+	if target == nil {
+		return
+	}
+	paths := make([]string, 0, len(result.BatchArchives)+1)
+	for _, sealed := range result.BatchArchives {
+		paths = append(paths, sealed.ArchivePath)
+	}
+	if len(paths) == 0 && result.ArchivePath != "" {
+		paths = append(paths, result.ArchivePath)
+	}
+	for _, path := range paths {
+		outcome := domain.ArchiveUpload{ArchiveName: filepath.Base(path)}
+		url, err := upload.PutArchive(ctx, *target, path)
+		outcome.URL = url
+		if err != nil {
+			outcome.Error = err.Error()
+			warning := fmt.Sprintf("upload of %s to %s failed: %v; the archive is kept at %s", outcome.ArchiveName, url, err, path)
+			_, _ = fmt.Fprintf(out, "[WARN] %s\n", warning)
+			result.Warnings = append(result.Warnings, warning)
+		} else {
+			_, _ = fmt.Fprintf(out, "[OK] Archive uploaded: %s\n", url)
+		}
+		result.Uploads = append(result.Uploads, outcome)
+	}
+}
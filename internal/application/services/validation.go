@@ -0,0 +1,116 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/archive"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/obfuscation"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/upload"
+)
+
+// validateExportConfig runs the checks shared by archive exports and streaming exports and
+// returns the normalized output formats
+func (s *exportServiceImpl) validateExportConfig(config domain.ExportConfig) ([]string, error) {
+	checks := []error{
+		ValidateInstances(config.Instances),
+		validateCounterEncoding(config.CounterEncoding),
+		validateMaxPointsPerSeries(config.MaxPointsPerSeries),
+		validateMaxPointsPerBatch(config.MaxPointsPerBatch),
+		validateSampleEveryN(config.SampleEveryN),
+		validateMaxLineBytes(config.MaxLineBytes),
+		validateStalenessMarkers(config.StalenessMarkers),
+		validateHistogramMode(config.HistogramMode),
+		validateRangeEnd(config.RangeEnd),
+		validateLookbehind(config.LookbehindSeconds),
+		validateQuerySet(config.QuerySet),
+		validateAlwaysIncludeUp(config),
+		validateFutureSamples(config),
+		validateLabelValueLimit(config),
+		validateCarryIn(config.CarryInSeconds),
+		validateStallTimeout(config.StallTimeoutSeconds),
+		validateDeadline(config.DeadlineSeconds),
+		validateVMAlertURL(config.VMAlertURL),
+		upload.ValidateTarget(config.Upload),
+		validateMaxOutputFiles(config.MaxOutputFiles),
+		validateSelectorConcurrency(config.SelectorConcurrency),
+	}
+	for _, err := range checks {
+		if err != nil {
+			return nil, err
+		}
+	}
+	if _, err := archive.ParseCollisionStrategy(config.ArchiveCollision); err != nil {
+		return nil, err
+	}
+	if _, err := obfuscation.NewCategorizer(config.Obfuscation); err != nil {
+		return nil, err
+	}
+	formats, err := normalizeFormats(config.Formats)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateCardinalityBudget(config, formats); err != nil {
+		return nil, err
+	}
+	if err := s.validateExportFormat(config, formats); err != nil {
+		return nil, err
+	}
+	return formats, nil
+}
+
+// validateStreamingConfig rejects the options a streaming export cannot honor: they shape,
+// describe or deliver an archive, and streaming writes plain JSONL without one
+func validateStreamingConfig(config domain.ExportConfig) error {
+	if format := exportFormat(config); format != domain.ExportFormatJSONL {
+		return fmt.Errorf("format %q is only supported for archive exports; streaming writes JSONL", config.Format)
+	}
+	var options []string
+	add := func(set bool, name string) {
+		if set {
+			options = append(options, name)
+		}
+	}
+	for _, format := range config.Formats {
+		add(strings.ToLower(strings.TrimSpace(format)) != domain.OutputFormatJSONL, "formats "+format)
+	}
+	add(config.ArchiveCollision != "", "archive_collision")
+	add(config.ArchivePerBatch, "archive_per_batch")
+	add(config.MaxOutputFiles != 0, "max_output_files")
+	add(config.SeriesStats, "series_stats")
+	add(config.IncludeReproduce, "include_reproduce")
+	add(config.InferScrapeInterval, "infer_scrape_interval")
+	add(config.VMAlertURL != "", "vmalert_url")
+	add(config.Upload != nil, "upload")
+	add(config.SkipFailedBatches, "skip_failed_batches")
+	add(config.ResumeFromBatch > 0, "resume_from_batch")
+	add(config.CardinalityBudget != 0, "label_cardinality_budget")
+	if len(options) > 0 {
+		return fmt.Errorf("streaming the export writes no archive and cannot be combined with: %s", strings.Join(options, ", "))
+	}
+	return nil
+}
+
+// newProcessOptions builds the per-series processing shared by archive and streaming exports.
+// Callers add what only they track, such as the byte budget, CSV output or metadata summaries.
+func newProcessOptions(config domain.ExportConfig, categories *obfuscation.Categorizer, baseline, selected seriesSet) processOptions {
+	return processOptions{
+		baseline:       baseline,
+		selected:       selected,
+		keepUp:         config.AlwaysIncludeUp,
+		histogramMode:  config.HistogramMode,
+		namelessSeries: config.NamelessSeries,
+		categories:     categories,
+		lineLimit:      config.MaxLineBytes,
+		counterDeltas:  config.CounterEncoding == domain.CounterEncodingDelta,
+		decimation:     newDecimator(config.MaxPointsPerSeries, config.TimeRange),
+		sampling:       newSampler(config.SampleEveryN),
+		stripStale:     config.StalenessMarkers == domain.StalenessMarkersStrip,
+		future:         newFutureGuard(config, time.Now()),
+		labels:         newLabelValueGuard(config),
+		carryIn:        newCarryInGuard(config),
+		normalize:      newLabelNormalizer(config),
+	}
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/archive"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+func newStreamingTestService(t *testing.T, exportBody string) (*exportServiceImpl, domain.ExportConfig) {
+	t.Helper()
+	srv := newIPv4Server(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/export" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = io.WriteString(w, exportBody)
+	}))
+	t.Cleanup(srv.Close)
+
+	service := &exportServiceImpl{
+		clientFactory:   vm.NewClient,
+		archiveWriter:   archive.NewWriter(t.TempDir()),
+		vmGatherVersion: "test",
+	}
+	config := domain.ExportConfig{
+		Connection:        domain.VMConnection{URL: srv.URL},
+		TimeRange:         domain.TimeRange{Start: time.Now().Add(-time.Minute), End: time.Now()},
+		MetricStepSeconds: 30,
+	}
+	return service, config
+}
+
+func TestExportToWriter_AppliesCounterDeltaEncoding(t *testing.T) {
+	body := `{"metric":{"__name__":"http_requests_total","job":"api"},"values":[100,130,170],"timestamps":[1000,2000,3000]}` + "\n"
+	service, config := newStreamingTestService(t, body)
+	config.CounterEncoding = domain.CounterEncodingDelta
+
+	var buf bytes.Buffer
+	if _, err := service.exportToWriter(context.Background(), config, &buf); err != nil {
+		t.Fatalf("exportToWriter failed: %v", err)
+	}
+	output := buf.String()
+	if !strings.Contains(output, domain.CounterEncodingLabel) || !strings.Contains(output, `"values":[100,30,40]`) {
+		t.Fatalf("expected delta-encoded counter in stream, got %s", output)
+	}
+}
+
+func TestExportToWriter_SharesArchiveValidation(t *testing.T) {
+	service, config := newStreamingTestService(t, "")
+	config.CounterEncoding = "gorilla"
+
+	_, err := service.exportToWriter(context.Background(), config, io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "counter_encoding") {
+		t.Fatalf("expected counter_encoding to be rejected, got %v", err)
+	}
+}
+
+func TestExportToWriter_RejectsArchiveOnlyOptions(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(*domain.ExportConfig)
+		want   string
+	}{
+		{name: "native format", mutate: func(c *domain.ExportConfig) { c.Format = domain.ExportFormatNative }, want: "streaming writes JSONL"},
+		{name: "csv format", mutate: func(c *domain.ExportConfig) { c.Formats = []string{"jsonl", "csv"} }, want: "formats csv"},
+		{name: "archive collision", mutate: func(c *domain.ExportConfig) { c.ArchiveCollision = "overwrite" }, want: "archive_collision"},
+		{name: "vmalert", mutate: func(c *domain.ExportConfig) { c.VMAlertURL = "http://vmalert:8880" }, want: "vmalert_url"},
+		{name: "max output files", mutate: func(c *domain.ExportConfig) { c.MaxOutputFiles = 3 }, want: "max_output_files"},
+		{name: "upload", mutate: func(c *domain.ExportConfig) { c.Upload = &domain.UploadTarget{} }, want: "upload"},
+		{name: "skip failed batches", mutate: func(c *domain.ExportConfig) { c.SkipFailedBatches = true }, want: "skip_failed_batches"},
+		{name: "series stats", mutate: func(c *domain.ExportConfig) { c.SeriesStats = true }, want: "series_stats"},
+		{name: "cardinality budget", mutate: func(c *domain.ExportConfig) { c.CardinalityBudget = 10 }, want: "label_cardinality_budget"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, config := newStreamingTestService(t, "")
+			tt.mutate(&config)
+			var buf bytes.Buffer
+			_, err := service.exportToWriter(context.Background(), config, &buf)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error mentioning %q, got %v", tt.want, err)
+			}
+			if buf.Len() != 0 {
+				t.Fatalf("expected nothing streamed, got %q", buf.String())
+			}
+		})
+	}
+}
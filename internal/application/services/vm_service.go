@@ -2,6 +2,7 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"regexp"
 	"sort"
@@ -20,9 +21,15 @@ type VMService interface {
 	// DiscoverComponents discovers VM components in the cluster
 	DiscoverComponents(ctx context.Context, conn domain.VMConnection, tr domain.TimeRange) ([]domain.VMComponent, error)
 
+	// DiscoverComponentsWithOptions discovers VM components, optionally estimating from an instance sample
+	DiscoverComponentsWithOptions(ctx context.Context, conn domain.VMConnection, tr domain.TimeRange, opts DiscoveryOptions) ([]domain.VMComponent, error)
+
 	// DiscoverSelectorJobs discovers jobs/instances for a selector
 	DiscoverSelectorJobs(ctx context.Context, conn domain.VMConnection, selector string, tr domain.TimeRange) ([]domain.SelectorJob, error)
 
+	// DiscoverLabels lists the label names present in the export set described by config
+	DiscoverLabels(ctx context.Context, config domain.ExportConfig) ([]string, error)
+
 	// GetSample retrieves sample metrics for preview
 	GetSample(ctx context.Context, config domain.ExportConfig, limit int) ([]domain.MetricSample, error)
 
@@ -33,6 +40,13 @@ type VMService interface {
 	CheckExportAPI(ctx context.Context, conn domain.VMConnection) bool
 }
 
+// DiscoveryOptions tunes component discovery
+type DiscoveryOptions struct {
+	// SampleInstances limits series counting to this many instances per component and
+	// extrapolates the totals. Zero counts every instance.
+	SampleInstances int
+}
+
 // vmServiceImpl implements VMService
 type vmServiceImpl struct {
 	clientFactory func(domain.VMConnection) *vm.Client
@@ -76,6 +90,12 @@ func (s *vmServiceImpl) ValidateConnection(ctx context.Context, conn domain.VMCo
 
 // DiscoverComponents discovers VictoriaMetrics components using vm_app_version metric
 func (s *vmServiceImpl) DiscoverComponents(ctx context.Context, conn domain.VMConnection, tr domain.TimeRange) ([]domain.VMComponent, error) {
+	return s.DiscoverComponentsWithOptions(ctx, conn, tr, DiscoveryOptions{})
+}
+
+// DiscoverComponentsWithOptions discovers components; with SampleInstances set, series estimates
+// for large components are computed from a subset of instances and marked as sampled
+func (s *vmServiceImpl) DiscoverComponentsWithOptions(ctx context.Context, conn domain.VMConnection, tr domain.TimeRange, opts DiscoveryOptions) ([]domain.VMComponent, error) {
 	client := s.clientFactory(conn)
 	queryTime := effectiveQueryTime(tr.End)
 
@@ -117,6 +137,13 @@ func (s *vmServiceImpl) DiscoverComponents(ctx context.Context, conn domain.VMCo
 	components := make([]domain.VMComponent, 0, len(componentMap))
 
 	for _, comp := range componentMap {
+		if opts.SampleInstances > 0 {
+			if s.estimateComponentFromSample(ctx, client, comp, opts.SampleInstances, tr) {
+				components = append(components, *comp)
+				continue
+			}
+		}
+
 		// Estimate metrics count for this component
 		count, err := s.estimateComponentMetrics(ctx, client, comp.Jobs, tr)
 		if err != nil {
@@ -218,6 +245,52 @@ func (s *vmServiceImpl) DiscoverSelectorJobs(ctx context.Context, conn domain.VM
 	return jobs, nil
 }
 
+// DiscoverLabels queries /api/v1/labels scoped to the export selector and returns the sorted label
+// names, without __name__, so obfuscation can be configured from the real label inventory
+func (s *vmServiceImpl) DiscoverLabels(ctx context.Context, config domain.ExportConfig) ([]string, error) {
+	selector, err := labelInventorySelector(config)
+	if err != nil {
+		return nil, err
+	}
+	client := s.clientFactory(config.Connection)
+	start := config.TimeRange.Start
+	end := effectiveQueryTime(config.TimeRange.End)
+	if start.IsZero() {
+		start = end.Add(-time.Hour)
+	}
+
+	names, err := client.Labels(ctx, selector, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("label discovery failed: %w", err)
+	}
+	labels := make([]string, 0, len(names))
+	for _, name := range names {
+		if name == "" || name == "__name__" {
+			continue
+		}
+		labels = append(labels, name)
+	}
+	sort.Strings(labels)
+	return labels, nil
+}
+
+// labelInventorySelector returns the series selector for label discovery. /api/v1/labels only
+// accepts series selectors, so MetricsQL exports are rejected; a custom selector is used as-is,
+// which may list labels of series that the job filter later excludes.
+func labelInventorySelector(config domain.ExportConfig) (string, error) {
+	if config.Mode == domain.ExportModeCustom && strings.TrimSpace(config.Query) != "" {
+		query := strings.TrimSpace(config.Query)
+		if config.QueryType == domain.QueryModeMetricsQL || !isSelectorQuery(query) {
+			return "", fmt.Errorf("label discovery requires a series selector, not a MetricsQL query")
+		}
+		return query, nil
+	}
+	if len(config.Jobs) == 0 && len(config.Instances) == 0 {
+		return `{__name__!=""}`, nil
+	}
+	return buildTargetFilterSelector(config.Jobs, config.Instances), nil
+}
+
 // estimateComponentMetrics estimates the number of metrics for given jobs
 func (s *vmServiceImpl) estimateComponentMetrics(ctx context.Context, client *vm.Client, jobs []string, tr domain.TimeRange) (int, error) {
 	if len(jobs) == 0 {
@@ -250,6 +323,119 @@ func (s *vmServiceImpl) estimateComponentMetrics(ctx context.Context, client *vm
 	return 0, nil
 }
 
+// estimateComponentFromSample counts series on a subset of the component's instances and scales
+// each job's count by its total/sampled instance ratio. It reports false when sampling does not
+// apply (instance listing failed or the component has no more instances than the sample size),
+// in which case the caller falls back to exact counting.
+func (s *vmServiceImpl) estimateComponentFromSample(ctx context.Context, client *vm.Client, comp *domain.VMComponent, sampleSize int, tr domain.TimeRange) bool {
+	queryTime := effectiveQueryTime(tr.End)
+
+	// vm_app_version has one series per instance, so listing instances is cheap
+	listQuery := fmt.Sprintf("group by (job, instance) (vm_app_version%s)", buildJobFilterSelector(comp.Jobs))
+	result, err := client.Query(ctx, listQuery, queryTime)
+	if err != nil {
+		return false
+	}
+
+	jobInstances := make(map[string][]string)
+	allInstances := make(map[string]struct{})
+	for _, r := range result.Data.Result {
+		job, instance := r.Metric["job"], r.Metric["instance"]
+		if job == "" || instance == "" {
+			continue
+		}
+		jobInstances[job] = append(jobInstances[job], instance)
+		allInstances[instance] = struct{}{}
+	}
+	if len(allInstances) <= sampleSize {
+		return false
+	}
+
+	sample := sampleInstances(jobInstances, sampleSize)
+	selector := buildTargetFilterSelector(comp.Jobs, sample)
+	countResult, err := client.Query(ctx, fmt.Sprintf("count by (job) (%s)", selector), queryTime)
+	if err != nil {
+		return false
+	}
+
+	sampled := make(map[string]struct{}, len(sample))
+	for _, instance := range sample {
+		sampled[instance] = struct{}{}
+	}
+
+	jobMetrics := make(map[string]int)
+	total := 0
+	for _, series := range countResult.Data.Result {
+		job := series.Metric["job"]
+		if job == "" || len(series.Value) < 2 {
+			continue
+		}
+		count, ok := parseCountValue(series.Value[1])
+		if !ok {
+			continue
+		}
+		sampledInJob := 0
+		for _, instance := range jobInstances[job] {
+			if _, ok := sampled[instance]; ok {
+				sampledInJob++
+			}
+		}
+		if sampledInJob == 0 {
+			continue
+		}
+		estimate := count * len(jobInstances[job]) / sampledInJob
+		jobMetrics[job] = estimate
+		total += estimate
+	}
+
+	comp.InstanceCount = len(allInstances)
+	comp.MetricsCountEstimate = total
+	if len(jobMetrics) > 0 {
+		comp.JobMetrics = jobMetrics
+	}
+	comp.EstimatedFromSample = true
+	comp.SampledInstances = len(sample)
+	return true
+}
+
+// sampleInstances picks about size instances spread evenly over the sorted instance list.
+// At least one instance of every job is always included so each job can be extrapolated.
+func sampleInstances(jobInstances map[string][]string, size int) []string {
+	jobs := make([]string, 0, len(jobInstances))
+	unique := make(map[string]struct{})
+	for job, instances := range jobInstances {
+		jobs = append(jobs, job)
+		sort.Strings(instances)
+		for _, instance := range instances {
+			unique[instance] = struct{}{}
+		}
+	}
+	sort.Strings(jobs)
+	all := make([]string, 0, len(unique))
+	for instance := range unique {
+		all = append(all, instance)
+	}
+	sort.Strings(all)
+
+	picked := make(map[string]struct{}, size)
+	sample := make([]string, 0, size)
+	add := func(instance string) {
+		if _, ok := picked[instance]; ok {
+			return
+		}
+		picked[instance] = struct{}{}
+		sample = append(sample, instance)
+	}
+	for _, job := range jobs {
+		add(jobInstances[job][0])
+	}
+	for i := 0; len(sample) < size && i < size; i++ {
+		add(all[i*len(all)/size])
+	}
+	sort.Strings(sample)
+	return sample
+}
+
 // countInstances counts unique instances for given jobs
 func (s *vmServiceImpl) countInstances(ctx context.Context, client *vm.Client, jobs []string, tr domain.TimeRange) (int, error) {
 	if len(jobs) == 0 {
@@ -349,16 +535,20 @@ func (s *vmServiceImpl) GetSample(ctx context.Context, config domain.ExportConfi
 		samples := make([]domain.MetricSample, 0, len(result.Data.Result))
 
 		for _, r := range result.Data.Result {
+			labels, keep := applyNamelessSeriesPolicy(r.Metric, config.NamelessSeries)
+			if !keep {
+				continue
+			}
 			sample := domain.MetricSample{
-				MetricName: r.Metric["__name__"],
-				Labels:     r.Metric,
+				MetricName: labels["__name__"],
+				Labels:     labels,
 			}
 
 			// Extract value from result
 			if len(r.Value) >= 2 {
 				// Value is [timestamp, value_string]
 				if valStr, ok := r.Value[1].(string); ok {
-					_, _ = fmt.Sscanf(valStr, "%f", &sample.Value)
+					sample.Value, _ = vm.ParseSampleValue(valStr)
 				} else if val, ok := r.Value[1].(float64); ok {
 					sample.Value = val
 				}
@@ -508,6 +698,15 @@ func (s *vmServiceImpl) CheckExportAPI(ctx context.Context, conn domain.VMConnec
 	reader, err := client.Export(ctx, selector, start, end)
 
 	if err != nil {
+		// The endpoint answered, the selector just matched nothing
+		if errors.Is(err, vm.ErrNoData) {
+			return true
+		}
+		// A non-JSON body (e.g. an HTML page) means the export path is routed elsewhere
+		if errors.Is(err, vm.ErrUnexpectedExportResponse) {
+			return false
+		}
+
 		errMsg := strings.ToLower(err.Error())
 
 		// Check for "missing route" error - this means export API is not configured
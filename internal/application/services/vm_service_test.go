@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -121,6 +122,111 @@ func TestVMService_DiscoverComponents_ClampsFutureEnd(t *testing.T) {
 	}
 }
 
+func TestVMService_DiscoverComponents_SampledEstimate(t *testing.T) {
+	var sampledQuery string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("query")
+		result := []map[string]interface{}{}
+		switch {
+		case strings.Contains(query, "label_replace(vm_app_version"):
+			result = []map[string]interface{}{
+				{"metric": map[string]string{"job": "vmstorage", "vm_component": "vmstorage"}},
+			}
+		case strings.HasPrefix(query, "group by (job, instance) (vm_app_version"):
+			for i := 0; i < 10; i++ {
+				result = append(result, map[string]interface{}{
+					"metric": map[string]string{"job": "vmstorage", "instance": fmt.Sprintf("10.0.0.%d:8482", i)},
+				})
+			}
+		case strings.HasPrefix(query, "count by (job)") && strings.Contains(query, "instance=~"):
+			sampledQuery = query
+			result = []map[string]interface{}{
+				{"metric": map[string]string{"job": "vmstorage"}, "value": []interface{}{float64(time.Now().Unix()), "200"}},
+			}
+		default:
+			t.Errorf("unexpected full-scan query during sampled discovery: %s", query)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "success",
+			"data":   map[string]interface{}{"resultType": "vector", "result": result},
+		})
+	}))
+	defer srv.Close()
+
+	service := &vmServiceImpl{clientFactory: vm.NewClient}
+	tr := domain.TimeRange{Start: time.Now().Add(-time.Hour), End: time.Now()}
+	components, err := service.DiscoverComponentsWithOptions(context.Background(), domain.VMConnection{URL: srv.URL}, tr, DiscoveryOptions{SampleInstances: 2})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(components) != 1 {
+		t.Fatalf("expected 1 component, got %d", len(components))
+	}
+	comp := components[0]
+	if !comp.EstimatedFromSample || comp.SampledInstances != 2 {
+		t.Fatalf("expected estimate marked as sampled from 2 instances, got %+v", comp)
+	}
+	if comp.InstanceCount != 10 {
+		t.Fatalf("expected total instance count 10, got %d", comp.InstanceCount)
+	}
+	// 200 series on 2 of 10 instances extrapolates to 1000
+	if comp.MetricsCountEstimate != 1000 || comp.JobMetrics["vmstorage"] != 1000 {
+		t.Fatalf("expected extrapolated estimate 1000, got %d (%v)", comp.MetricsCountEstimate, comp.JobMetrics)
+	}
+	if strings.Count(sampledQuery, ":8482") != 2 {
+		t.Fatalf("expected series count restricted to the 2 sampled instances, got %s", sampledQuery)
+	}
+}
+
+func TestVMService_DiscoverLabels(t *testing.T) {
+	var gotMatch, gotAuth, gotPath string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotMatch = r.URL.Query().Get("match[]")
+		gotAuth = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"status":"success","data":["pod","__name__","instance","job","namespace"]}`)
+	}))
+	defer srv.Close()
+
+	service := &vmServiceImpl{clientFactory: vm.NewClient}
+	config := domain.ExportConfig{
+		Connection: domain.VMConnection{
+			URL:  srv.URL,
+			Auth: domain.AuthConfig{Type: domain.AuthTypeBearer, Token: "label-token"},
+		},
+		TimeRange: domain.TimeRange{Start: time.Now().Add(-time.Hour), End: time.Now()},
+		Jobs:      []string{"vmagent"},
+		Instances: []string{"10.0.0.1:8429"},
+	}
+
+	labels, err := service.DiscoverLabels(context.Background(), config)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	want := []string{"instance", "job", "namespace", "pod"}
+	if strings.Join(labels, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected labels %v, got %v", want, labels)
+	}
+	if gotPath != "/api/v1/labels" {
+		t.Fatalf("expected /api/v1/labels, got %s", gotPath)
+	}
+	if gotMatch != `{job=~"vmagent", instance=~"10\\.0\\.0\\.1:8429"}` {
+		t.Fatalf("expected labels query scoped to the export selector, got match[]=%s", gotMatch)
+	}
+	if gotAuth != "Bearer label-token" {
+		t.Fatalf("expected bearer auth header, got %q", gotAuth)
+	}
+
+	config.Mode = domain.ExportModeCustom
+	config.QueryType = domain.QueryModeMetricsQL
+	config.Query = "rate(vm_rows_total[5m])"
+	if _, err := service.DiscoverLabels(context.Background(), config); err == nil {
+		t.Fatal("expected MetricsQL query to be rejected for label discovery")
+	}
+}
+
 // NOTE: Full integration tests with ValidateConnection would require either:
 // 1. Refactoring to use interfaces (more complex, SOLID but heavier)
 // 2. Running actual VM instance (integration tests with testcontainers)
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+// vmalertTimeout bounds the vmalert snapshot; it is optional context and must not hold up the export
+const vmalertTimeout = 30 * time.Second
+
+// vmalertSnapshot is vmalert's state at export time, stored as alerts.json and rules.json
+type vmalertSnapshot struct {
+	alerts   []byte
+	rules    []byte
+	summary  *domain.VMAlertSummary
+	warnings []string
+}
+
+// validateVMAlertURL accepts an empty value (no snapshot) or an absolute http(s) URL
+func validateVMAlertURL(raw string) error {
+	if raw == "" {
+		return nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("vmalert_url must be an absolute http(s) URL, got %q", raw)
+	}
+	return nil
+}
+
+// captureVMAlert fetches vmalert's alerts and rules when vmalert_url is set. vmalert may be absent or
+// unreachable, so failures only produce warnings. The request reuses the connection's TLS settings;
+// its auth and headers only go along when vmalert is on the same origin as VictoriaMetrics. Alerts
+// and rules carry raw label values and expressions, so nothing is captured from obfuscated exports.
+func (s *exportServiceImpl) captureVMAlert(ctx context.Context, config domain.ExportConfig) vmalertSnapshot {
+	var snapshot vmalertSnapshot
+	if config.VMAlertURL == "" {
+		return snapshot
+	}
+	snapshot.summary = &domain.VMAlertSummary{CapturedAt: time.Now().UTC()}
+	if config.Obfuscation.Enabled {
+		snapshot.summary.Skipped = "obfuscation"
+		snapshot.warnings = append(snapshot.warnings, "vmalert alerts and rules were not captured because obfuscation is enabled")
+		return snapshot
+	}
+
+	conn := vmalertConnection(config.Connection, config.VMAlertURL)
+	client := s.clientFactory(conn)
+	ctx, cancel := context.WithTimeout(ctx, vmalertTimeout)
+	defer cancel()
+
+	var err error
+	if snapshot.alerts, err = client.FetchVMAlert(ctx, vm.VMAlertAlertsPath); err != nil {
+		snapshot.warnings = append(snapshot.warnings, fmt.Sprintf("vmalert alerts were not captured: %v", err))
+	}
+	if snapshot.rules, err = client.FetchVMAlert(ctx, vm.VMAlertRulesPath); err != nil {
+		snapshot.warnings = append(snapshot.warnings, fmt.Sprintf("vmalert rules were not captured: %v", err))
+	}
+	snapshot.summary.Alerts = snapshot.alerts != nil
+	snapshot.summary.Rules = snapshot.rules != nil
+	for _, warning := range snapshot.warnings {
+		s.printf("[WARN] %s\n", warning)
+	}
+	return snapshot
+}
+
+// vmalertConnection points conn at vmalertURL. Credentials and custom headers are meant for
+// VictoriaMetrics, so they are dropped unless vmalert shares its origin.
+func vmalertConnection(conn domain.VMConnection, vmalertURL string) domain.VMConnection {
+	vmURL, vmErr := url.Parse(conn.URL)
+	alertURL, alertErr := url.Parse(vmalertURL)
+	if vmErr != nil || alertErr != nil || !vm.SameOrigin(vmURL, alertURL) {
+		conn.Auth = domain.AuthConfig{Type: domain.AuthTypeNone}
+		conn.Headers = nil
+	}
+	conn.URL = vmalertURL
+	conn.ApiBasePath = ""
+	conn.FullApiUrl = ""
+	return conn
+}
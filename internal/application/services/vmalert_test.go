@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/archive"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+func TestExecuteExport_CapturesVMAlertState(t *testing.T) {
+	const alerts = `{"status":"success","data":{"alerts":[{"name":"HighLatency","state":"firing","labels":{"job":"api"}}]}}`
+	const rules = `{"status":"success","data":{"groups":[{"name":"api","rules":[{"name":"HighLatency","type":"alerting","state":"firing"}]}]}}`
+	var authorized bool
+	// vmalert is proxied behind the same origin as VictoriaMetrics, so it gets the same credentials
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/vmalert/api/v1/alerts":
+			authorized = r.Header.Get("Authorization") == "Bearer token"
+			_, _ = io.WriteString(w, alerts)
+		case "/vmalert/api/v1/rules":
+			_, _ = io.WriteString(w, rules)
+		case "/api/v1/export":
+			_, _ = io.WriteString(w, `{"metric":{"__name__":"up","job":"api"},"values":[1],"timestamps":[1000]}`+"\n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	export := func(vmalertURL string, obfuscate bool) (*domain.ExportResult, map[string]string) {
+		service := &exportServiceImpl{
+			clientFactory:   vm.NewClient,
+			archiveWriter:   archive.NewWriter(t.TempDir()),
+			vmGatherVersion: "test",
+		}
+		result, err := service.ExecuteExport(context.Background(), domain.ExportConfig{
+			Connection: domain.VMConnection{
+				URL:  srv.URL,
+				Auth: domain.AuthConfig{Type: domain.AuthTypeBearer, Token: "token"},
+			},
+			TimeRange:         domain.TimeRange{Start: time.Now().Add(-5 * time.Minute), End: time.Now()},
+			StagingDir:        t.TempDir(),
+			MetricStepSeconds: 30,
+			VMAlertURL:        vmalertURL,
+			Obfuscation:       domain.ObfuscationConfig{Enabled: obfuscate, ObfuscateJob: obfuscate},
+		})
+		if err != nil {
+			t.Fatalf("ExecuteExport failed: %v", err)
+		}
+		return result, readZipFiles(t, result.ArchivePath)
+	}
+
+	result, files := export(srv.URL+"/vmalert", false)
+	if files["alerts.json"] != alerts || files["rules.json"] != rules {
+		t.Fatalf("expected the vmalert responses in the archive, got alerts=%q rules=%q", files["alerts.json"], files["rules.json"])
+	}
+	if !authorized {
+		t.Fatal("expected same-origin vmalert requests to reuse the connection's auth")
+	}
+	if len(result.Warnings) != 0 {
+		t.Fatalf("unexpected warnings: %v", result.Warnings)
+	}
+	var metadata struct {
+		VMAlert *domain.VMAlertSummary `json:"vmalert"`
+	}
+	if err := json.Unmarshal([]byte(files["metadata.json"]), &metadata); err != nil {
+		t.Fatalf("invalid metadata: %v", err)
+	}
+	if metadata.VMAlert == nil || !metadata.VMAlert.Alerts || !metadata.VMAlert.Rules || metadata.VMAlert.CapturedAt.IsZero() {
+		t.Fatalf("unexpected vmalert metadata %+v", metadata.VMAlert)
+	}
+	if !strings.Contains(files["README.txt"], "alerts.json") {
+		t.Fatal("expected README.txt to list alerts.json")
+	}
+
+	// vmalert is optional: a missing endpoint only warns
+	result, files = export(srv.URL+"/missing", false)
+	if _, ok := files["alerts.json"]; ok {
+		t.Fatal("expected no alerts.json without vmalert")
+	}
+	if len(result.Warnings) != 2 || !strings.Contains(result.Warnings[0], "alerts were not captured") {
+		t.Fatalf("expected warnings about the missing vmalert, got %v", result.Warnings)
+	}
+
+	// Raw alert labels would undo obfuscation
+	result, files = export(srv.URL+"/vmalert", true)
+	if _, ok := files["rules.json"]; ok {
+		t.Fatal("expected no vmalert snapshot in an obfuscated archive")
+	}
+	if len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], "obfuscation") {
+		t.Fatalf("expected an obfuscation warning, got %v", result.Warnings)
+	}
+
+	if err := validateVMAlertURL("vmalert:8880"); err == nil {
+		t.Fatal("expected a vmalert_url without scheme to be rejected")
+	}
+}
+
+func TestVMAlertConnectionSendsCredentialsOnlyToTheSameOrigin(t *testing.T) {
+	conn := domain.VMConnection{
+		URL:           "https://vm.example.com/select/0/prometheus",
+		ApiBasePath:   "/select/0/prometheus",
+		Auth:          domain.AuthConfig{Type: domain.AuthTypeBasic, Username: "user", Password: "secret"},
+		Headers:       map[string]string{"X-Gateway-Token": "t"},
+		SkipTLSVerify: true,
+	}
+
+	same := vmalertConnection(conn, "https://vm.example.com/vmalert")
+	if same.Auth.Password != "secret" || same.Headers["X-Gateway-Token"] != "t" || same.URL != "https://vm.example.com/vmalert" || same.ApiBasePath != "" {
+		t.Fatalf("expected a same-origin vmalert to keep the credentials, got %+v", same)
+	}
+
+	other := vmalertConnection(conn, "http://vmalert.example.com:8880")
+	if other.Auth.Type != domain.AuthTypeNone || other.Auth.Password != "" || other.Headers != nil {
+		t.Fatalf("expected no credentials for another host, got %+v", other)
+	}
+	if !other.SkipTLSVerify || other.URL != "http://vmalert.example.com:8880" {
+		t.Fatalf("expected TLS settings and the vmalert URL to be kept, got %+v", other)
+	}
+}
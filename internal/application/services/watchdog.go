@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// errExportStalled fails an export whose batch received no data for stall_timeout_seconds
+var errExportStalled = errors.New("export stalled")
+
+// maxStallTimeoutSeconds keeps the watchdog below the batch timeout, which it is meant to undercut
+const maxStallTimeoutSeconds = int(defaultBatchTimeout / time.Second)
+
+// validateStallTimeout rejects negative values and values the batch timeout would always hit first
+func validateStallTimeout(seconds int) error {
+	if seconds < 0 || seconds > maxStallTimeoutSeconds {
+		return fmt.Errorf("stall_timeout_seconds must be between 0 and %d, got %d", maxStallTimeoutSeconds, seconds)
+	}
+	return nil
+}
+
+// stallWatchdog cancels a batch when no data arrives for the idle interval, either while waiting
+// for the response or between reads of its body. A server that stops sending without closing the
+// connection then fails the export quickly instead of holding it until the batch timeout.
+type stallWatchdog struct {
+	idle   time.Duration
+	timer  *time.Timer
+	fired  atomic.Bool
+	cancel context.CancelFunc
+}
+
+// newStallWatchdog starts watching a batch; it returns nil when seconds is not positive, which
+// disables the watchdog. cancel must abort the batch's request.
+func newStallWatchdog(seconds int, cancel context.CancelFunc) *stallWatchdog {
+	if seconds <= 0 {
+		return nil
+	}
+	w := &stallWatchdog{idle: time.Duration(seconds) * time.Second, cancel: cancel}
+	w.timer = time.AfterFunc(w.idle, func() {
+		w.fired.Store(true)
+		w.cancel()
+	})
+	return w
+}
+
+// watch returns r with every read that yields data pushing the deadline back
+func (w *stallWatchdog) watch(r io.ReadCloser) io.ReadCloser {
+	if w == nil {
+		return r
+	}
+	return &watchedReader{r: r, w: w}
+}
+
+// stop disarms the watchdog once the batch is done
+func (w *stallWatchdog) stop() {
+	if w != nil {
+		w.timer.Stop()
+	}
+}
+
+// explain turns the cancellation caused by the watchdog into a clear stall error
+func (w *stallWatchdog) explain(err error) error {
+	if err == nil || w == nil || !w.fired.Load() {
+		return err
+	}
+	return fmt.Errorf("%w, no data for %s", errExportStalled, w.idle)
+}
+
+type watchedReader struct {
+	r io.ReadCloser
+	w *stallWatchdog
+}
+
+func (r *watchedReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 && !r.w.fired.Load() {
+		r.w.timer.Reset(r.w.idle)
+	}
+	return n, err
+}
+
+func (r *watchedReader) Close() error {
+	r.w.stop()
+	return r.r.Close()
+}
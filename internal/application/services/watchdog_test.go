@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/archive"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+)
+
+func TestExecuteExport_StallWatchdogFailsSilentServer(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `{"metric":{"__name__":"up","job":"api"},"values":[1],"timestamps":[1000]}`+"\n")
+		w.(http.Flusher).Flush()
+		// Go silent without closing the connection
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	service := &exportServiceImpl{
+		clientFactory:   vm.NewClient,
+		archiveWriter:   archive.NewWriter(t.TempDir()),
+		vmGatherVersion: "test",
+	}
+	stagingDir := t.TempDir()
+	config := domain.ExportConfig{
+		ExportID:            "stalled",
+		Connection:          domain.VMConnection{URL: srv.URL},
+		TimeRange:           domain.TimeRange{Start: time.Now().Add(-5 * time.Minute), End: time.Now()},
+		StagingDir:          stagingDir,
+		MetricStepSeconds:   30,
+		StallTimeoutSeconds: 1,
+	}
+
+	started := time.Now()
+	_, err := service.ExecuteExport(context.Background(), config)
+	if !errors.Is(err, errExportStalled) {
+		t.Fatalf("expected a stall error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "export stalled, no data for 1s") {
+		t.Fatalf("expected the idle interval in the error, got %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > 10*time.Second {
+		t.Fatalf("expected the watchdog to fire after about 1s, took %v", elapsed)
+	}
+
+	if err := validateStallTimeout(-1); err == nil {
+		t.Fatal("expected a negative stall_timeout_seconds to be rejected")
+	}
+	if err := validateStallTimeout(maxStallTimeoutSeconds + 1); err == nil {
+		t.Fatal("expected a stall_timeout_seconds beyond the batch timeout to be rejected")
+	}
+}
+
+func TestStallWatchdogDataKeepsItAlive(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watchdog := newStallWatchdog(1, cancel)
+	pr, pw := io.Pipe()
+	reader := watchdog.watch(pr)
+	go func() {
+		// Slow but steady: every chunk arrives well within the idle interval
+		for i := 0; i < 6; i++ {
+			time.Sleep(300 * time.Millisecond)
+			_, _ = pw.Write([]byte("x"))
+		}
+		_ = pw.Close()
+	}()
+	data, err := io.ReadAll(reader)
+	_ = reader.Close()
+	if err != nil || len(data) != 6 {
+		t.Fatalf("expected all data, got %q, %v", data, err)
+	}
+	if errors.Is(watchdog.explain(io.ErrUnexpectedEOF), errExportStalled) || ctx.Err() != nil {
+		t.Fatal("expected the watchdog not to fire while data keeps arriving")
+	}
+	if newStallWatchdog(0, cancel) != nil {
+		t.Fatal("expected the watchdog to be disabled by default")
+	}
+}
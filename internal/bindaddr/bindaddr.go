@@ -0,0 +1,24 @@
+// Package bindaddr checks the -addr flag shared by vmgather and VMImporter.
+package bindaddr
+
+import (
+	"fmt"
+	"net"
+)
+
+// Check reports whether addr listens on all network interfaces (0.0.0.0, :: or an empty host),
+// which is refused unless allowAll is set
+func Check(addr string, allowAll bool) (bool, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false, fmt.Errorf("invalid -addr %q: %w", addr, err)
+	}
+	ip := net.ParseIP(host)
+	if host != "" && (ip == nil || !ip.IsUnspecified()) {
+		return false, nil
+	}
+	if !allowAll {
+		return true, fmt.Errorf("-addr %s listens on all network interfaces; pass -allow-all-interfaces to confirm or bind to localhost", addr)
+	}
+	return true, nil
+}
@@ -0,0 +1,29 @@
+package bindaddr
+
+import "testing"
+
+func TestCheckAcceptsLoopbackDefaults(t *testing.T) {
+	// vmgather and VMImporter default to these addresses
+	for _, addr := range []string{"localhost:8080", "localhost:8081", "127.0.0.1:8080", "[::1]:8081"} {
+		if all, err := Check(addr, false); all || err != nil {
+			t.Errorf("%s: loopback must be accepted without -allow-all-interfaces, got %v, %v", addr, all, err)
+		}
+	}
+}
+
+func TestCheckRequiresFlagForAllInterfaces(t *testing.T) {
+	for _, addr := range []string{"0.0.0.0:8080", ":8080", "[::]:8080"} {
+		if _, err := Check(addr, false); err == nil {
+			t.Errorf("%s: expected an error without -allow-all-interfaces", addr)
+		}
+		if all, err := Check(addr, true); !all || err != nil {
+			t.Errorf("%s: expected all-interfaces binding to be allowed with the flag, got %v, %v", addr, all, err)
+		}
+	}
+	if all, err := Check("192.168.1.10:8080", false); all || err != nil {
+		t.Fatalf("a specific interface must not need the flag, got %v, %v", all, err)
+	}
+	if _, err := Check("localhost", false); err == nil {
+		t.Fatal("expected an address without a port to be rejected")
+	}
+}
@@ -0,0 +1,34 @@
+package domain
+
+import (
+	"sort"
+	"strings"
+)
+
+// SynthesizeMetricName derives a stable metric name from the label names of a nameless series,
+// e.g. {job="a",instance="b"} becomes "unnamed_instance_job". vmgather's synthesize policy and
+// VMImporter's use it, so an exported and an imported series end up with the same name.
+func SynthesizeMetricName(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		if strings.HasPrefix(name, "__") {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("unnamed")
+	for _, name := range names {
+		b.WriteByte('_')
+		for _, r := range name {
+			if r == '_' || r == ':' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+				b.WriteRune(r)
+			} else {
+				b.WriteByte('_')
+			}
+		}
+	}
+	return b.String()
+}
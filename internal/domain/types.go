@@ -34,6 +34,15 @@ const (
 	QueryModeMetricsQL QueryMode = "metricsql"
 )
 
+// RedirectPolicy defines how the VM client treats HTTP redirects
+type RedirectPolicy string
+
+const (
+	RedirectPolicySameHost RedirectPolicy = "same_host" // follow redirects to the same scheme/host only (default)
+	RedirectPolicyFollow   RedirectPolicy = "follow"    // follow any redirect, stripping credentials on cross-host hops
+	RedirectPolicyNone     RedirectPolicy = "none"      // never follow redirects
+)
+
 // AuthConfig contains authentication settings
 type AuthConfig struct {
 	Type        AuthType `json:"type"`
@@ -46,14 +55,21 @@ type AuthConfig struct {
 
 // VMConnection represents connection settings to VictoriaMetrics
 type VMConnection struct {
-	URL           string     `json:"url"`
-	ApiBasePath   string     `json:"api_base_path,omitempty"`  // e.g., "/select/0/prometheus" or "/1011/prometheus"
-	TenantId      string     `json:"tenant_id,omitempty"`      // e.g., "0" or "1011"
-	IsMultitenant bool       `json:"is_multitenant,omitempty"` // true for /select/multitenant endpoints
-	FullApiUrl    string     `json:"full_api_url,omitempty"`   // Complete URL with base path
-	Auth          AuthConfig `json:"auth"`
-	SkipTLSVerify bool       `json:"skip_tls_verify"`
-	Debug         bool       `json:"debug,omitempty"`
+	URL               string            `json:"url"`
+	ApiBasePath       string            `json:"api_base_path,omitempty"`  // e.g., "/select/0/prometheus" or "/1011/prometheus"
+	TenantId          string            `json:"tenant_id,omitempty"`      // e.g., "0" or "1011"
+	IsMultitenant     bool              `json:"is_multitenant,omitempty"` // true for /select/multitenant endpoints
+	FullApiUrl        string            `json:"full_api_url,omitempty"`   // Complete URL with base path
+	Auth              AuthConfig        `json:"auth"`
+	SkipTLSVerify     bool              `json:"skip_tls_verify"`
+	RedirectPolicy    RedirectPolicy    `json:"redirect_policy,omitempty"`
+	DialTimeoutSec    int               `json:"dial_timeout_seconds,omitempty"`    // TCP connect timeout; 0 uses the default (30s)
+	RequestTimeoutSec int               `json:"request_timeout_seconds,omitempty"` // Per-request limit for query and query_range; 0 uses the defaults (30s, 2m)
+	MaxRetries        int               `json:"max_retries,omitempty"`             // Retries of 5xx responses and network timeouts; 0 uses the default (3), negative disables
+	KeepAliveSec      int               `json:"keepalive_seconds,omitempty"`       // TCP keepalive period; 0 uses the default (30s), negative disables
+	ForceHTTP1        bool              `json:"force_http1,omitempty"`             // Never negotiate HTTP/2, for proxies that break HTTP/2 streaming
+	Headers           map[string]string `json:"headers,omitempty"`                 // Extra headers sent with every request; auth headers take precedence
+	Debug             bool              `json:"debug,omitempty"`
 }
 
 // VMComponent represents a discovered VictoriaMetrics component
@@ -63,6 +79,9 @@ type VMComponent struct {
 	InstanceCount        int            `json:"instance_count"`
 	MetricsCountEstimate int            `json:"metrics_count_estimate"`
 	JobMetrics           map[string]int `json:"job_metrics,omitempty"`
+	// EstimatedFromSample marks estimates extrapolated from SampledInstances instances
+	EstimatedFromSample bool `json:"estimated_from_sample,omitempty"`
+	SampledInstances    int  `json:"sampled_instances,omitempty"`
 }
 
 // SelectorJob represents a job discovered by selector-based discovery
@@ -75,10 +94,25 @@ type SelectorJob struct {
 // BatchSettings controls batching for long-running exports
 type BatchSettings struct {
 	Enabled            bool   `json:"enabled"`
-	Strategy           string `json:"strategy,omitempty"` // e.g. "auto"
+	Strategy           string `json:"strategy,omitempty"` // "auto", "custom" or BatchStrategyAdaptive
 	CustomIntervalSecs int    `json:"custom_interval_seconds,omitempty"`
 }
 
+// Batch strategies for BatchSettings.Strategy
+const (
+	BatchStrategyAuto     = "auto"     // fixed windows sized from the time range (default)
+	BatchStrategyAdaptive = "adaptive" // merge adjacent windows while they return little data
+)
+
+// SeriesPagination describes which slice of the matched series an export covered
+type SeriesPagination struct {
+	Offset      int  `json:"series_offset"`
+	Limit       int  `json:"series_limit"`
+	TotalSeries int  `json:"total_series"`
+	NextOffset  int  `json:"next_offset"`
+	HasMore     bool `json:"has_more"`
+}
+
 // MetricSample represents a sample metric for preview
 type MetricSample struct {
 	MetricName string            `json:"metric_name"`
@@ -95,8 +129,39 @@ type ObfuscationConfig struct {
 	PreserveStructure bool     `json:"preserve_structure"`
 	CustomLabels      []string `json:"custom_labels,omitempty"` // Additional labels to obfuscate (pod, namespace, etc.)
 	DropLabels        []string `json:"drop_labels,omitempty"`   // Labels removed from export
+	Allowlist         []string `json:"allowlist,omitempty"`     // Exact label values that are never obfuscated, e.g. a public demo node
+	// Coarse categories of original values (e.g. region from the instance subnet), kept for grouping
+	CategoryLabels []CategoryRule `json:"category_labels,omitempty"`
+}
+
+// CategoryRule adds a category label derived from the original value of a label before it is
+// obfuscated, so obfuscated archives can still be grouped. The first matching entry wins.
+type CategoryRule struct {
+	Source  string          `json:"source"` // Label whose original value is categorized, e.g. "instance"
+	Target  string          `json:"target"` // Label receiving the category, e.g. "region"
+	Match   []CategoryMatch `json:"match"`
+	Default string          `json:"default,omitempty"` // Category when no entry matches; empty adds no label
+}
+
+// CategoryMatch maps values to a category by network or by regular expression
+type CategoryMatch struct {
+	CIDR     string `json:"cidr,omitempty"`  // Matches IPs, or the host of host:port values, in this network
+	Regex    string `json:"regex,omitempty"` // Anchored: matches the whole value
+	Category string `json:"category"`
 }
 
+// Output formats for ExportConfig.Formats
+const (
+	OutputFormatJSONL = "jsonl" // metrics.jsonl, always written
+	OutputFormatCSV   = "csv"   // metrics.csv with one row per sample
+)
+
+// Data formats for ExportConfig.Format
+const (
+	ExportFormatJSONL  = "jsonl"  // /api/v1/export, processed into metrics.jsonl (default)
+	ExportFormatNative = "native" // /api/v1/export/native, stored unmodified as metrics.native
+)
+
 // OutputSettings defines export output configuration
 type OutputSettings struct {
 	Format      string `json:"format"`      // "jsonl"
@@ -106,30 +171,372 @@ type OutputSettings struct {
 
 // ExportConfig contains full export configuration
 type ExportConfig struct {
-	Connection        VMConnection      `json:"connection"`
-	TimeRange         TimeRange         `json:"time_range"`
-	Components        []string          `json:"components"`
-	Jobs              []string          `json:"jobs"`
-	Mode              ExportMode        `json:"mode,omitempty"`
-	QueryType         QueryMode         `json:"query_type,omitempty"`
-	Query             string            `json:"query,omitempty"`
-	Obfuscation       ObfuscationConfig `json:"obfuscation"`
-	Batching          BatchSettings     `json:"batching"`
-	StagingDir        string            `json:"staging_dir,omitempty"`
-	StagingFile       string            `json:"staging_file,omitempty"`
-	ResumeFromBatch   int               `json:"resume_from_batch,omitempty"`
-	MetricStepSeconds int               `json:"metric_step_seconds,omitempty"`
-	OutputSettings    OutputSettings    `json:"output_settings"`
+	ExportID              string               `json:"export_id,omitempty"` // Caller-supplied correlation ID; generated when empty
+	Connection            VMConnection         `json:"connection"`
+	TimeRange             TimeRange            `json:"time_range"`
+	Components            []string             `json:"components"`
+	HeavyConfirmed        []string             `json:"confirmed_heavy_components,omitempty"` // Components exported despite a discovery estimate above the heavy threshold
+	Jobs                  []string             `json:"jobs"`
+	Instances             []string             `json:"instances,omitempty"` // Exact instance values; combined with jobs
+	Mode                  ExportMode           `json:"mode,omitempty"`
+	QueryType             QueryMode            `json:"query_type,omitempty"`
+	Query                 string               `json:"query,omitempty"`
+	QuerySet              *QuerySet            `json:"query_set,omitempty"` // Named expressions exported together via query_range
+	Obfuscation           ObfuscationConfig    `json:"obfuscation"`
+	Batching              BatchSettings        `json:"batching"`
+	StagingDir            string               `json:"staging_dir,omitempty"`
+	StagingFile           string               `json:"staging_file,omitempty"`
+	StagingBufferSize     int                  `json:"staging_buffer_size,omitempty"`   // Staging writer buffer in bytes; 0 uses the bufio default
+	StagingFsync          bool                 `json:"staging_fsync,omitempty"`         // Fsync the staging file after every batch
+	StagingGzip           bool                 `json:"staging_gzip,omitempty"`          // Gzip the staging file; each batch is a separate gzip member
+	KeepStaging           bool                 `json:"keep_staging,omitempty"`          // Keep the staging JSONL after a successful export
+	ArchivePerBatch       bool                 `json:"archive_per_batch,omitempty"`     // Seal every batch window into its own archive
+	IncludeReproduce      bool                 `json:"include_reproduce,omitempty"`     // Add reproduce.sh with the commands that regenerate the export
+	InferScrapeInterval   bool                 `json:"infer_scrape_interval,omitempty"` // Record the median scrape interval per component in metadata
+	SeriesStats           bool                 `json:"series_stats,omitempty"`          // Add series_stats.json with min/max/avg/last per series
+	AlwaysIncludeUp       bool                 `json:"always_include_up,omitempty"`     // Also export up for the selected jobs/instances, whatever the selector
+	ClampToData           bool                 `json:"clamp_to_data,omitempty"`         // Narrow the range to the selector's first and last sample
+	ResumeFromBatch       int                  `json:"resume_from_batch,omitempty"`
+	MetricStepSeconds     int                  `json:"metric_step_seconds,omitempty"`
+	LookbehindSeconds     int                  `json:"lookbehind_seconds,omitempty"`
+	CarryInSeconds        int                  `json:"carry_in_seconds,omitempty"`         // Reach back this far for each series' latest sample before the range
+	StallTimeoutSeconds   int                  `json:"stall_timeout_seconds,omitempty"`    // Fail the export when a batch receives no data this long; 0 disables
+	DeadlineSeconds       int                  `json:"deadline_seconds,omitempty"`         // Overall limit for fetching; a partial archive is sealed when it passes
+	SkipFailedBatches     bool                 `json:"skip_failed_batches,omitempty"`      // Record failed batch windows in errors.json and go on
+	VMAlertURL            string               `json:"vmalert_url,omitempty"`              // vmalert to snapshot alerts and rules from; gets the connection's auth on the same origin only
+	Upload                *UploadTarget        `json:"upload,omitempty"`                   // HTTP PUT / WebDAV drop point the finished archive is streamed to
+	Webhook               *WebhookTarget       `json:"webhook,omitempty"`                  // Receives the final status of an export job
+	SeriesLimit           int                  `json:"series_limit,omitempty"`             // Page size in series; 0 exports all matched series
+	SeriesOffset          int                  `json:"series_offset,omitempty"`            // Number of ordered series to skip before the page
+	PerComponentSeriesCap int                  `json:"per_component_series_cap,omitempty"` // At most N series per component; 0 exports all
+	CardinalityBudget     int                  `json:"label_cardinality_budget,omitempty"` // Drop labels with more distinct values than this; 0 keeps all
+	MaxOutputFiles        int                  `json:"max_output_files,omitempty"`         // Most archive entries an export may write across its archives; 0 uses 10000
+	SelectorConcurrency   int                  `json:"selector_concurrency,omitempty"`     // Fetch a window's selectors in up to N parallel requests; 0 or 1 sends one
+	Priority              int                  `json:"priority,omitempty"`                 // Jobs waiting for a slot start highest priority first; default 0
+	MaxPointsPerBatch     int                  `json:"max_points_per_batch,omitempty"`     // Coarsen the query_range step after a batch with more points; 0 disables
+	BaselineArchive       string               `json:"baseline_archive,omitempty"`         // Prior archive; only series absent from it are exported
+	ArchiveCollision      string               `json:"archive_collision,omitempty"`        // unique (default), overwrite, skip or version when vmexport_<id>.zip exists
+	HistogramMode         HistogramMode        `json:"histogram_mode,omitempty"`
+	CounterEncoding       CounterEncoding      `json:"counter_encoding,omitempty"`
+	NamelessSeries        NamelessSeriesPolicy `json:"nameless_series,omitempty"`
+	StalenessMarkers      StalenessPolicy      `json:"staleness_markers,omitempty"`
+	RangeEnd              RangeEnd             `json:"range_end,omitempty"`
+	MaxFutureSkewSeconds  int                  `json:"max_future_skew_seconds,omitempty"`
+	FutureSamples         FutureSamplePolicy   `json:"future_samples,omitempty"`
+	MaxLabelValueLength   int                  `json:"max_label_value_length,omitempty"`
+	LongLabelValues       LabelValuePolicy     `json:"long_label_values,omitempty"`
+	LowercaseLabelNames   bool                 `json:"lowercase_label_names,omitempty"` // Lowercase label names; never the metric name
+	TrimLabelValues       bool                 `json:"trim_label_values,omitempty"`     // Trim surrounding whitespace from label values
+	MaxBytes              int64                `json:"max_bytes,omitempty"`             // Budget for uncompressed exported data; 0 means unlimited
+	MaxLineBytes          int                  `json:"max_line_bytes,omitempty"`        // Longest series line accepted from VictoriaMetrics; 0 uses 16 MiB
+	MaxPointsPerSeries    int                  `json:"max_points_per_series,omitempty"` // Keep at most N evenly spaced points per series; 0 keeps all
+	SampleEveryN          int                  `json:"sample_every_n,omitempty"`        // Keep every Nth raw point per series; 0 or 1 keeps all
+	Format                string               `json:"format,omitempty"`                // ExportFormatJSONL (default) or ExportFormatNative
+	Formats               []string             `json:"formats,omitempty"`               // Extra archive representations besides jsonl, e.g. "csv"
+	FormatQueueSize       int                  `json:"format_queue_size,omitempty"`     // Series an extra format's encoder may lag behind; 0 uses 256
+	OutputSettings        OutputSettings       `json:"output_settings"`
+}
+
+// QuerySet is a named bundle of expressions (e.g. a team's standard health queries) exported into one archive.
+// Every exported series carries the name of the query that produced it in the vmgather_query label.
+type QuerySet struct {
+	Name    string       `json:"name"`
+	Queries []NamedQuery `json:"queries"`
+}
+
+// NamedQuery is one expression of a QuerySet
+type NamedQuery struct {
+	Name  string `json:"name"`
+	Query string `json:"query,omitempty"` // Omitted from metadata of obfuscated exports
+}
+
+// HistogramMode defines how histogram bucket series are exported
+type HistogramMode string
+
+const (
+	HistogramModePreserve HistogramMode = "preserve" // export every bucket series as-is (default)
+	HistogramModeCompact  HistogramMode = "compact"  // drop VictoriaMetrics vmrange buckets without observations
+)
+
+// CounterEncoding defines how `_total` counter samples are stored in the archive
+type CounterEncoding string
+
+const (
+	CounterEncodingAbsolute CounterEncoding = "absolute" // raw counter values (default)
+	CounterEncodingDelta    CounterEncoding = "delta"    // first value, then the difference to the previous sample
+)
+
+// CounterEncodingLabel marks series stored with a non-absolute counter encoding.
+// VMImporter decodes such series and removes the label before importing.
+const CounterEncodingLabel = "vmgather_counter_encoding"
+
+// NamelessSeriesPolicy defines how series without a __name__ label are handled
+type NamelessSeriesPolicy string
+
+const (
+	NamelessSeriesKeep       NamelessSeriesPolicy = "keep"       // keep as-is, shown as "unknown" (default)
+	NamelessSeriesDrop       NamelessSeriesPolicy = "drop"       // skip such series
+	NamelessSeriesSynthesize NamelessSeriesPolicy = "synthesize" // set __name__ derived from the label names
+)
+
+// StalenessPolicy defines what happens to staleness markers (exported as null values)
+type StalenessPolicy string
+
+const (
+	StalenessMarkersPreserve StalenessPolicy = "preserve" // keep markers so gaps replay faithfully (default)
+	StalenessMarkersStrip    StalenessPolicy = "strip"    // drop marker samples and their timestamps
+)
+
+// RangeEnd defines whether a sample timestamped exactly at the end of the export range is exported.
+// The start is always inclusive, and batch windows inside the range are always [start, end), so a
+// sample on a window boundary is exported once, by the window that starts there.
+type RangeEnd string
+
+const (
+	RangeEndExclusive RangeEnd = "exclusive" // [start, end)
+	RangeEndInclusive RangeEnd = "inclusive" // [start, end] (default)
+)
+
+// FutureSamplePolicy defines what happens to samples timestamped beyond now+max_future_skew_seconds
+type FutureSamplePolicy string
+
+const (
+	FutureSamplesDrop  FutureSamplePolicy = "drop"  // remove future samples (default)
+	FutureSamplesClamp FutureSamplePolicy = "clamp" // keep the latest future sample per series at now+skew
+)
+
+// FutureSampleSummary records samples the future-skew guard dropped or clamped
+type FutureSampleSummary struct {
+	MaxFutureSkewSeconds int                `json:"max_future_skew_seconds"`
+	Policy               FutureSamplePolicy `json:"policy"`
+	Limit                time.Time          `json:"limit"` // now+skew at export start
+	Points               int64              `json:"points"`
+	Series               int64              `json:"series"`
+}
+
+// LabelValuePolicy defines what happens to series with a label value longer than max_label_value_length
+type LabelValuePolicy string
+
+const (
+	LabelValuesTruncate   LabelValuePolicy = "truncate"    // cut the value to the limit (default)
+	LabelValuesDropSeries LabelValuePolicy = "drop-series" // skip the series
+	LabelValuesError      LabelValuePolicy = "error"       // fail the export
+)
+
+// LabelValueSummary records series affected by the label value length limit
+type LabelValueSummary struct {
+	MaxLabelValueLength int              `json:"max_label_value_length"`
+	Policy              LabelValuePolicy `json:"policy"`
+	Labels              []string         `json:"labels"` // Names of the labels that exceeded the limit
+	ValuesTruncated     int64            `json:"values_truncated"`
+	Series              int64            `json:"series"` // Series truncated or dropped
+}
+
+// LabelBudgetSummary records the labels label_cardinality_budget removed from the archive
+type LabelBudgetSummary struct {
+	Budget        int      `json:"budget"`
+	DroppedLabels []string `json:"dropped_labels"` // Labels with more distinct values than the budget
+}
+
+// NormalizationSummary records lowercase_label_names / trim_label_values; present whenever either is set
+type NormalizationSummary struct {
+	LowercaseNames bool  `json:"lowercase_names"`
+	TrimValues     bool  `json:"trim_values"`
+	NamesRenamed   int64 `json:"names_renamed"`
+	ValuesTrimmed  int64 `json:"values_trimmed"`
+	Collisions     int64 `json:"collisions"` // Labels dropped because another label had the same lowercased name
+	Series         int64 `json:"series"`     // Series with at least one normalized label
+}
+
+// VMAlertSummary records the vmalert snapshot stored as alerts.json and rules.json
+type VMAlertSummary struct {
+	CapturedAt time.Time `json:"captured_at"`
+	Alerts     bool      `json:"alerts"`            // alerts.json is in the archive
+	Rules      bool      `json:"rules"`             // rules.json is in the archive
+	Skipped    string    `json:"skipped,omitempty"` // Why nothing was fetched, e.g. "obfuscation"
+}
+
+// CarryInSummary records carry_in_seconds: series whose latest sample before the range was carried in.
+// Carried-in points keep their original timestamps, so they are the points before time_range.start.
+type CarryInSummary struct {
+	CarryInSeconds int       `json:"carry_in_seconds"`
+	Since          time.Time `json:"since"` // time_range.start - carry_in_seconds
+	Series         int64     `json:"series"`
+}
+
+// BatchWindow identifies the batch window held by an archive_per_batch archive
+type BatchWindow struct {
+	Index           int       `json:"index"`   // 0-based first batch window in the archive
+	Batches         int       `json:"batches"` // Windows merged into it by adaptive batching
+	TotalBatches    int       `json:"total_batches"`
+	ExportTimeRange TimeRange `json:"export_time_range"` // Range of the whole export; time_range is the window itself
+}
+
+// UploadTarget is an HTTP PUT (e.g. WebDAV) drop point for finished archives. A URL ending in "/"
+// is a collection and gets the archive name appended; any other URL is the archive's location.
+type UploadTarget struct {
+	URL           string     `json:"url"`
+	Auth          AuthConfig `json:"auth"`
+	SkipTLSVerify bool       `json:"skip_tls_verify,omitempty"`
+}
+
+// WebhookTarget receives a POST with the final status of an export job when it completes, fails or is canceled
+type WebhookTarget struct {
+	URL           string     `json:"url"`
+	Auth          AuthConfig `json:"auth"`
+	SkipTLSVerify bool       `json:"skip_tls_verify,omitempty"`
+}
+
+// ArchiveUpload is the outcome of uploading one archive; a failed upload keeps the local archive
+type ArchiveUpload struct {
+	ArchiveName string `json:"archive_name"`
+	URL         string `json:"url"` // Without query string, which may carry a presigned token
+	Error       string `json:"error,omitempty"`
+}
+
+// BatchArchive is one archive sealed by archive_per_batch
+type BatchArchive struct {
+	Index            int       `json:"index"`
+	TimeRange        TimeRange `json:"time_range"`
+	ArchivePath      string    `json:"archive_path"`
+	ArchiveName      string    `json:"archive_name"`
+	ArchiveSizeBytes int64     `json:"archive_size_bytes"`
+	SHA256           string    `json:"sha256"`
+	MetricsExported  int       `json:"metrics_exported"`
+}
+
+// PartialExport describes an export that stopped before covering the requested range
+type PartialExport struct {
+	Reason           string    `json:"reason"`
+	MaxBytes         int64     `json:"max_bytes,omitempty"`
+	DeadlineSeconds  int       `json:"deadline_seconds,omitempty"`
+	BytesWritten     int64     `json:"bytes_written"`
+	CoveredRange     TimeRange `json:"covered_range"` // Span whose batches were exported completely
+	CompletedBatches int       `json:"completed_batches"`
+	TotalBatches     int       `json:"total_batches"`
+	FailedBatches    int       `json:"failed_batches,omitempty"` // Windows skipped by skip_failed_batches, listed in errors.json
+}
+
+// RangeAdjustment records how clamp_to_data narrowed the requested range to the available data
+type RangeAdjustment struct {
+	RequestedRange TimeRange `json:"requested_range"`
+	ClampedRange   TimeRange `json:"clamped_range"`
+	FirstSample    time.Time `json:"first_sample"`
+	LastSample     time.Time `json:"last_sample"`
+}
+
+// FailedBatch is a batch window that could not be read and was skipped with skip_failed_batches
+type FailedBatch struct {
+	Index      int       `json:"index"`
+	Batches    int       `json:"batches"`
+	TimeRange  TimeRange `json:"time_range"`
+	Error      string    `json:"error"`       // As returned by VictoriaMetrics, e.g. the query error response
+	SeriesKept int       `json:"series_kept"` // Whole series received before the error, kept in the archive
+}
+
+// SeriesStat summarizes one archived series for series_stats.json. Min, Max, Avg and Last cover
+// finite samples only and are omitted when a series has none.
+type SeriesStat struct {
+	Metric         map[string]string `json:"metric"`
+	Samples        int               `json:"samples"`
+	Min            *float64          `json:"min,omitempty"`
+	Max            *float64          `json:"max,omitempty"`
+	Avg            *float64          `json:"avg,omitempty"`
+	Last           *float64          `json:"last,omitempty"`
+	FirstTimestamp int64             `json:"first_timestamp"` // Unix milliseconds
+	LastTimestamp  int64             `json:"last_timestamp"`  // Unix milliseconds
+}
+
+// ScrapeIntervalSummary is the scrape interval inferred from sample spacing for one component
+type ScrapeIntervalSummary struct {
+	Component             string  `json:"component"`
+	MedianIntervalSeconds float64 `json:"median_interval_seconds"`
+	Series                int     `json:"series"` // Series with at least two samples that contributed
+}
+
+// MetricTypeHint is the type guessed from a metric name suffix for recipients without metadata endpoints
+type MetricTypeHint struct {
+	Metric string `json:"metric"`
+	Type   string `json:"type"`   // counter, histogram or gauge
+	Series int    `json:"series"` // Exported series lines with this name
+}
+
+// DecimationSummary records how max_points_per_series thinned the exported series
+type DecimationSummary struct {
+	MaxPointsPerSeries int   `json:"max_points_per_series"`
+	PointsKept         int64 `json:"points_kept"`
+	PointsDropped      int64 `json:"points_dropped"`
+}
+
+// SamplingSummary records sample_every_n: only every Nth raw point of each series was kept
+type SamplingSummary struct {
+	SampleEveryN  int   `json:"sample_every_n"`
+	PointsKept    int64 `json:"points_kept"`
+	PointsDropped int64 `json:"points_dropped"`
+}
+
+// DataSource tells how exported samples were obtained from VictoriaMetrics
+type DataSource string
+
+const (
+	DataSourceExport     DataSource = "export"      // raw samples from /api/v1/export
+	DataSourceQueryRange DataSource = "query_range" // points evaluated at every step; a sample may repeat until the lookbehind expires
+)
+
+// BatchFidelity records where the samples of consecutive batch windows came from
+type BatchFidelity struct {
+	Start             time.Time  `json:"start"`
+	End               time.Time  `json:"end"`
+	Batches           int        `json:"batches"`
+	Source            DataSource `json:"source"`
+	Raw               bool       `json:"raw"`                          // false when points may be carried over or interpolated
+	StepSeconds       int        `json:"step_seconds,omitempty"`       // query_range step
+	LookbehindSeconds int        `json:"lookbehind_seconds,omitempty"` // query_range lookbehind; 0 is the server default
+}
+
+// ComponentSeriesCap records how per_component_series_cap limited each component
+type ComponentSeriesCap struct {
+	Cap        int                    `json:"cap"`
+	Components []ComponentSeriesCount `json:"components"`
+}
+
+// ComponentSeriesCount is the number of matched and exported series of one component. Series
+// are listed only up to the cap, so the matched total is known only for uncapped components.
+type ComponentSeriesCount struct {
+	Component      string `json:"component"`
+	TotalSeries    int    `json:"total_series,omitempty"` // Set when the component has no more series than the cap
+	ExportedSeries int    `json:"exported_series"`
+	Capped         bool   `json:"capped,omitempty"` // More series matched than were exported
+}
+
+// BaselineReference identifies the prior archive a diff export was compared against
+type BaselineReference struct {
+	ArchiveName string `json:"archive_name"`
+	ExportID    string `json:"export_id,omitempty"`
+	SeriesCount int    `json:"series_count"`
 }
 
 // ExportResult represents the result of an export operation
 type ExportResult struct {
-	ExportID           string    `json:"export_id"`
-	ArchivePath        string    `json:"archive_path"`
-	ArchiveName        string    `json:"archive_name"`
-	ArchiveSizeBytes   int64     `json:"archive_size_bytes"`
-	MetricsExported    int       `json:"metrics_exported"`
-	TimeRange          TimeRange `json:"time_range"`
-	ObfuscationApplied bool      `json:"obfuscation_applied"`
-	SHA256             string    `json:"sha256"`
+	ExportID           string            `json:"export_id"`
+	ArchivePath        string            `json:"archive_path"`
+	ArchiveName        string            `json:"archive_name"`
+	ArchiveSizeBytes   int64             `json:"archive_size_bytes"`
+	MetricsExported    int               `json:"metrics_exported"`
+	SeriesWithSamples  int               `json:"series_with_samples"` // Excludes meta lines: nameless or staleness-only series
+	PointsExported     int64             `json:"points_exported"`
+	TimeRange          TimeRange         `json:"time_range"`
+	ObfuscationApplied bool              `json:"obfuscation_applied"`
+	SHA256             string            `json:"sha256"`
+	Pagination         *SeriesPagination `json:"pagination,omitempty"`
+	Partial            *PartialExport    `json:"partial,omitempty"`
+	RangeClamp         *RangeAdjustment  `json:"range_clamp,omitempty"`  // Set when clamp_to_data narrowed the range
+	StagingPath        string            `json:"staging_path,omitempty"` // Set when keep_staging preserved the staging file
+	Warnings           []string          `json:"warnings,omitempty"`
+	// archive_per_batch: one archive per batch window; the Archive* fields above describe the last one
+	BatchArchives []BatchArchive  `json:"batch_archives,omitempty"`
+	Uploads       []ArchiveUpload `json:"uploads,omitempty"` // One entry per archive when upload is configured
+	// Order-independent SHA256 of the series as fetched, before any processing; kept out of the
+	// archive. Set for complete exports that were not resumed.
+	SourceSHA256 string `json:"source_sha256,omitempty"`
 }
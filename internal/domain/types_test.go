@@ -81,3 +81,20 @@ func TestTimeRange_Duration(t *testing.T) {
 		t.Errorf("Duration = %v, want %v", duration, expectedDuration)
 	}
 }
+
+func TestSynthesizeMetricName(t *testing.T) {
+	cases := []struct {
+		labels map[string]string
+		want   string
+	}{
+		{map[string]string{"job": "a", "instance": "b"}, "unnamed_instance_job"},
+		{map[string]string{"job": "a", "__tenant__": "1"}, "unnamed_job"},
+		{map[string]string{"k8s.pod-name": "x"}, "unnamed_k8s_pod_name"},
+		{nil, "unnamed"},
+	}
+	for _, tc := range cases {
+		if got := SynthesizeMetricName(tc.labels); got != tc.want {
+			t.Errorf("SynthesizeMetricName(%v) = %q, want %q", tc.labels, got, tc.want)
+		}
+	}
+}
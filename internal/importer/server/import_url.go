@@ -0,0 +1,217 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Limits for /api/import-from-url
+const (
+	importURLDownloadTimeout = 30 * time.Minute
+	importURLMaxRedirects    = 5
+	importURLProgressStep    = 4 << 20
+)
+
+// errImportURLNotAllowed marks source URLs whose host is not in the -import-url-allow-hosts list
+var errImportURLNotAllowed = errors.New("source host is not in the import URL allowlist")
+
+// importFromURLRequest is the body of /api/import-from-url
+type importFromURLRequest struct {
+	URL string `json:"url"`
+	// Authorization is sent as-is to the source host only (e.g. "Bearer <token>"); it is never stored
+	Authorization string       `json:"authorization,omitempty"`
+	Config        uploadConfig `json:"config"`
+}
+
+// SetImportURLAllowlist enables /api/import-from-url for bundles hosted on the given hosts.
+// Entries are "host" (any port) or "host:port"; with an empty list the endpoint is disabled.
+func (s *Server) SetImportURLAllowlist(hosts []string) {
+	allowed := make(map[string]struct{}, len(hosts))
+	for _, host := range hosts {
+		host = strings.ToLower(strings.TrimSpace(host))
+		if host != "" {
+			allowed[host] = struct{}{}
+		}
+	}
+	s.importURLHosts = allowed
+}
+
+// importURLAllowed reports whether u points to an allowlisted host over http(s)
+func (s *Server) importURLAllowed(u *url.URL) bool {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+	if _, ok := s.importURLHosts[strings.ToLower(u.Host)]; ok {
+		return true
+	}
+	_, ok := s.importURLHosts[strings.ToLower(u.Hostname())]
+	return ok
+}
+
+func (s *Server) handleImportFromURL(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if len(s.importURLHosts) == 0 {
+		respondWithError(w, http.StatusForbidden, "import from URL is disabled; start vmimporter with -import-url-allow-hosts")
+		return
+	}
+	var req importFromURLRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxUploadFieldBytes)).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+		return
+	}
+	sourceURL, err := url.Parse(strings.TrimSpace(req.URL))
+	if err != nil || sourceURL.Host == "" || (sourceURL.Scheme != "http" && sourceURL.Scheme != "https") {
+		respondWithError(w, http.StatusBadRequest, "url must be an absolute http(s) URL")
+		return
+	}
+	if sourceURL.User != nil {
+		respondWithError(w, http.StatusBadRequest, "url must not contain credentials; use the authorization field")
+		return
+	}
+	if !s.importURLAllowed(sourceURL) {
+		respondWithError(w, http.StatusForbidden, fmt.Sprintf("%v: %s", errImportURLNotAllowed, sourceURL.Host))
+		return
+	}
+	cfg := req.Config
+	if err := normalizeUploadConfig(&cfg); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	importURL, queryURL, err := resolveEndpoints(cfg)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.saveRecentProfile(cfg)
+
+	job := s.newJob(0)
+	s.storeJob(job)
+	jobSnapshot := snapshotJob(job)
+	go s.runImportFromURL(context.Background(), job, cfg, sourceURL, req.Authorization, importURL, queryURL)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(struct {
+		JobID string    `json:"job_id"`
+		Job   importJob `json:"job"`
+	}{
+		JobID: job.ID,
+		Job:   jobSnapshot,
+	})
+}
+
+// runImportFromURL downloads the bundle into a temp file and hands it to the regular import pipeline
+func (s *Server) runImportFromURL(ctx context.Context, job *importJob, cfg uploadConfig, sourceURL *url.URL, authorization, importURL, queryURL string) {
+	s.updateJob(job, func(j *importJob) {
+		j.State = jobStateRunning
+		j.Stage = "downloading"
+		j.Message = fmt.Sprintf("Downloading bundle from %s…", sourceURL.Host)
+		j.Percent = 1
+	})
+	bundlePath, bundleName, size, err := s.downloadBundle(ctx, job, sourceURL, authorization)
+	if err != nil {
+		s.failJob(job, err)
+		return
+	}
+	s.updateJob(job, func(j *importJob) {
+		j.SourceBytes = size
+	})
+	s.runImportJob(ctx, job, cfg, bundlePath, bundleName, importURL, queryURL, 0)
+}
+
+// downloadBundle streams the source into a temp file under the upload size limit.
+// Redirects are followed only to allowlisted hosts.
+func (s *Server) downloadBundle(ctx context.Context, job *importJob, sourceURL *url.URL, authorization string) (string, string, int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, importURLDownloadTimeout)
+	defer cancel()
+
+	client := &http.Client{
+		Transport: newTransport(s.dialer, false),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= importURLMaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", importURLMaxRedirects)
+			}
+			if !s.importURLAllowed(req.URL) {
+				return fmt.Errorf("redirect refused: %w: %s", errImportURLNotAllowed, req.URL.Host)
+			}
+			return nil
+		},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, sourceURL.String(), nil)
+	if err != nil {
+		return "", "", 0, err
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", "", 0, fmt.Errorf("failed to download bundle: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return "", "", 0, fmt.Errorf("failed to download bundle: source returned %s", resp.Status)
+	}
+	if resp.ContentLength > s.maxUploadBytes {
+		return "", "", 0, &uploadTooLargeError{limit: s.maxUploadBytes}
+	}
+
+	body := &downloadProgressReader{r: resp.Body, total: resp.ContentLength, report: func(read, total int64) {
+		s.updateJob(job, func(j *importJob) {
+			if total > 0 {
+				j.Message = fmt.Sprintf("Downloaded %s of %s…", formatUploadSize(read), formatUploadSize(total))
+			} else {
+				j.Message = fmt.Sprintf("Downloaded %s…", formatUploadSize(read))
+			}
+		})
+	}}
+	bundlePath, size, err := persistUploadedFile(body, s.maxUploadBytes)
+	if err != nil {
+		return "", "", 0, err
+	}
+	return bundlePath, downloadBundleName(resp.Request.URL, resp.Header.Get("Content-Type")), size, nil
+}
+
+// downloadBundleName picks the name prepareBundle uses to detect the format: the URL's file
+// name, or bundle.zip/bundle.jsonl from the content type when the URL has no known extension
+func downloadBundleName(u *url.URL, contentType string) string {
+	name := path.Base(u.Path)
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".zip", ".jsonl", ".json":
+		return name
+	}
+	if strings.Contains(strings.ToLower(contentType), "zip") {
+		return "bundle.zip"
+	}
+	return "bundle.jsonl"
+}
+
+// downloadProgressReader reports progress every importURLProgressStep bytes
+type downloadProgressReader struct {
+	r        io.Reader
+	total    int64
+	read     int64
+	reported int64
+	report   func(read, total int64)
+}
+
+func (p *downloadProgressReader) Read(buf []byte) (int, error) {
+	n, err := p.r.Read(buf)
+	p.read += int64(n)
+	if p.read-p.reported >= importURLProgressStep {
+		p.reported = p.read
+		p.report(p.read, p.total)
+	}
+	return n, err
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// A metric object may repeat a label key; that is valid JSON, but decoding silently keeps the
+// last value. Such series keep the last ("last", default) or the first ("first") value, are
+// skipped ("drop-series"), or stop the import ("error"). A "name" label that differs from
+// __name__ is reported too; it is left as is except by drop-series and error.
+const (
+	labelConflictsLast  = "last"
+	labelConflictsFirst = "first"
+	labelConflictsDrop  = "drop-series"
+	labelConflictsError = "error"
+)
+
+// labelAnomalies are the label problems of one series that its decoded labels do not show
+type labelAnomalies struct {
+	firstValues  map[string]string // first value of every repeated label
+	nameConflict bool
+}
+
+func (a labelAnomalies) found() bool {
+	return len(a.firstValues) > 0 || a.nameConflict
+}
+
+func (a labelAnomalies) describe(labels map[string]string) string {
+	var parts []string
+	if len(a.firstValues) > 0 {
+		names := make([]string, 0, len(a.firstValues))
+		for name := range a.firstValues {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		parts = append(parts, "repeated labels "+strings.Join(names, ", "))
+	}
+	if a.nameConflict {
+		parts = append(parts, fmt.Sprintf("label name=%q differs from __name__=%q", labels["name"], labels["__name__"]))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// findLabelAnomalies re-reads the metric object of an already decoded JSONL line for repeated
+// keys, and compares the decoded "name" label with __name__
+func findLabelAnomalies(line []byte, labels map[string]string) labelAnomalies {
+	var anomalies labelAnomalies
+	if name, ok := labels["name"]; ok && labels["__name__"] != "" && name != labels["__name__"] {
+		anomalies.nameConflict = true
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(line))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return anomalies
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return anomalies
+		}
+		if key != "metric" {
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return anomalies
+			}
+			continue
+		}
+		if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+			return anomalies
+		}
+		seen := make(map[string]string, len(labels))
+		for dec.More() {
+			nameTok, err := dec.Token()
+			if err != nil {
+				return anomalies
+			}
+			valueTok, err := dec.Token()
+			if err != nil {
+				return anomalies
+			}
+			name, _ := nameTok.(string)
+			value, _ := valueTok.(string)
+			first, repeated := seen[name]
+			if !repeated {
+				seen[name] = value
+				continue
+			}
+			if anomalies.firstValues == nil {
+				anomalies.firstValues = make(map[string]string)
+			}
+			if _, ok := anomalies.firstValues[name]; !ok {
+				anomalies.firstValues[name] = first
+			}
+		}
+		return anomalies
+	}
+	return anomalies
+}
+
+// applyLabelConflictPolicy counts the label anomalies of a series and resolves them according to
+// policy. Returns false when the series must be skipped, and an error for policy "error".
+func (s *importSummary) applyLabelConflictPolicy(parsed *metricLine, line []byte, lineNumber int, policy string) (bool, error) {
+	if parsed.Metric == nil {
+		return true, nil
+	}
+	anomalies := findLabelAnomalies(line, parsed.Metric)
+	if !anomalies.found() {
+		return true, nil
+	}
+	if len(anomalies.firstValues) > 0 {
+		s.DuplicateLabels++
+	}
+	if anomalies.nameConflict {
+		s.NameConflicts++
+	}
+	switch policy {
+	case labelConflictsError:
+		return false, fmt.Errorf("line %d: %s (label_conflicts: %s)", lineNumber, anomalies.describe(parsed.Metric), policy)
+	case labelConflictsDrop:
+		s.DroppedConflict++
+		return false, nil
+	case labelConflictsFirst:
+		for name, value := range anomalies.firstValues {
+			parsed.Metric[name] = value
+		}
+	}
+	return true, nil
+}
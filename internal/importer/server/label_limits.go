@@ -0,0 +1,131 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"unicode/utf8"
+)
+
+// VictoriaMetrics rejects or cuts labels longer than -maxLabelNameLen and -maxLabelValueLen, and
+// the import response does not say which series were affected. Series over these limits are
+// therefore resolved before posting: labels are cut to the limit ("truncate", default), or the
+// series is skipped ("skip"). A truncated name that collides with another label skips the series.
+const (
+	overLengthLabelsTruncate = "truncate"
+	overLengthLabelsSkip     = "skip"
+
+	// VictoriaMetrics defaults of -maxLabelNameLen and -maxLabelValueLen
+	defaultMaxLabelNameBytes  = 256
+	defaultMaxLabelValueBytes = 4096
+
+	// maxOverLengthExamples bounds how many offending series the summary names
+	maxOverLengthExamples = 5
+)
+
+// labelLengthLimits are the byte limits checked per label; 0 disables a check
+type labelLengthLimits struct {
+	name  int
+	value int
+}
+
+// resolveLabelLengthLimits returns the configured limits: 0 uses the VictoriaMetrics default,
+// negative disables the check
+func resolveLabelLengthLimits(cfg uploadConfig) labelLengthLimits {
+	resolve := func(configured, fallback int) int {
+		switch {
+		case configured < 0:
+			return 0
+		case configured == 0:
+			return fallback
+		default:
+			return configured
+		}
+	}
+	return labelLengthLimits{
+		name:  resolve(cfg.MaxLabelNameBytes, defaultMaxLabelNameBytes),
+		value: resolve(cfg.MaxLabelValueBytes, defaultMaxLabelValueBytes),
+	}
+}
+
+// overLengthLabels lists, sorted, the labels whose name or value exceeds limits
+func (l labelLengthLimits) overLengthLabels(labels map[string]string) []string {
+	var names []string
+	for name, value := range labels {
+		if (l.name > 0 && len(name) > l.name) || (l.value > 0 && len(value) > l.value) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// describe names the first offending label of a series and by how much it is over; values are
+// not quoted, they may be large
+func (l labelLengthLimits) describe(lineNumber int, labels map[string]string, names []string) string {
+	name := names[0]
+	detail := fmt.Sprintf("value of %d bytes exceeds %d", len(labels[name]), l.value)
+	if l.name > 0 && len(name) > l.name {
+		detail = fmt.Sprintf("name of %d bytes exceeds %d", len(name), l.name)
+		name = truncateUTF8(name, 32) + "..."
+	}
+	desc := fmt.Sprintf("line %d: series %s label %q %s", lineNumber, labels["__name__"], name, detail)
+	if len(names) > 1 {
+		desc += fmt.Sprintf(" (+%d more)", len(names)-1)
+	}
+	return desc
+}
+
+// applyLabelLengthPolicy counts a series with over-length labels and resolves it according to
+// policy; reportOnly counts it without changes. Returns false when the series must be skipped.
+func (s *importSummary) applyLabelLengthPolicy(parsed *metricLine, lineNumber int, limits labelLengthLimits, policy string, reportOnly bool) bool {
+	if parsed.Metric == nil || (limits.name <= 0 && limits.value <= 0) {
+		return true
+	}
+	names := limits.overLengthLabels(parsed.Metric)
+	if len(names) == 0 {
+		return true
+	}
+	s.LongLabels++
+	if len(s.LongLabelLines) < maxOverLengthExamples {
+		s.LongLabelLines = append(s.LongLabelLines, limits.describe(lineNumber, parsed.Metric, names))
+	}
+	if reportOnly {
+		return true
+	}
+	if policy == overLengthLabelsSkip {
+		s.DroppedLong++
+		return false
+	}
+	for _, name := range names {
+		value := parsed.Metric[name]
+		if limits.value > 0 {
+			value = truncateUTF8(value, limits.value)
+		}
+		cut := name
+		if limits.name > 0 {
+			cut = truncateUTF8(name, limits.name)
+		}
+		if cut != name {
+			if _, taken := parsed.Metric[cut]; taken {
+				s.DroppedLong++
+				return false
+			}
+			delete(parsed.Metric, name)
+		}
+		parsed.Metric[cut] = value
+	}
+	s.TruncatedLabels++
+	return true
+}
+
+// truncateUTF8 cuts s to at most limit bytes without splitting a UTF-8 sequence
+func truncateUTF8(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut]
+}
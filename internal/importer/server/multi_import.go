@@ -0,0 +1,255 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// Limits for /api/upload-multi
+const (
+	maxMultiImportBundles     = 64
+	maxMultiImportConcurrency = 8
+)
+
+// bundleImport is the outcome of one bundle of a multi-bundle import
+type bundleImport struct {
+	Name         string              `json:"name"`
+	State        string              `json:"state"`
+	Error        string              `json:"error,omitempty"`
+	Summary      *importSummary      `json:"summary,omitempty"`
+	Verification *verificationResult `json:"verification,omitempty"`
+}
+
+// handleUploadMulti imports several bundles, e.g. the per-window archives of one export, as a
+// single job. Every "bundle" part is imported with the same config; "concurrency" (1 by default,
+// at most maxMultiImportConcurrency) bounds how many bundles stream at once.
+func (s *Server) handleUploadMulti(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	form, err := readUploadForm(r, s.maxUploadBytes, s.maxMultiUploadBytes, maxMultiImportBundles)
+	if err != nil {
+		respondWithUploadError(w, err)
+		return
+	}
+	jobStarted := false
+	defer func() {
+		if !jobStarted {
+			form.cleanup()
+		}
+	}()
+
+	var cfg uploadConfig
+	if err := json.Unmarshal([]byte(form.fields["config"]), &cfg); err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("invalid config: %v", err))
+		return
+	}
+	if err := normalizeUploadConfig(&cfg); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	concurrency := 1
+	if raw := form.fields["concurrency"]; raw != "" {
+		concurrency, err = strconv.Atoi(raw)
+		if err != nil || concurrency < 1 || concurrency > maxMultiImportConcurrency {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("concurrency must be between 1 and %d", maxMultiImportConcurrency))
+			return
+		}
+	}
+	bundles := form.bundles()
+	if len(bundles) == 0 {
+		respondWithError(w, http.StatusBadRequest, "at least one bundle file is required")
+		return
+	}
+	s.saveRecentProfile(cfg)
+
+	importURL, queryURL, err := resolveEndpoints(cfg)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	job := s.newJob(form.totalBytes())
+	job.Config = cfg
+	for _, bundle := range bundles {
+		job.Bundles = append(job.Bundles, bundleImport{Name: bundle.name, State: jobStateQueued})
+	}
+	s.storeJob(job)
+
+	jobSnapshot := snapshotJob(job)
+	jobStarted = true
+	go s.runMultiImportJob(context.Background(), job, cfg, bundles, importURL, queryURL, concurrency)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(struct {
+		JobID string    `json:"job_id"`
+		Job   importJob `json:"job"`
+	}{
+		JobID: job.ID,
+		Job:   jobSnapshot,
+	})
+}
+
+// runMultiImportJob streams every bundle through the regular import pipeline, at most
+// concurrency at a time, and verifies each one on its own. The job summary adds up the
+// bundles that were imported; the job fails if any bundle failed. Multi-bundle jobs are not
+// resumable: re-upload the failed bundles instead.
+func (s *Server) runMultiImportJob(ctx context.Context, job *importJob, cfg uploadConfig, bundles []uploadedBundle, importURL, queryURL string, concurrency int) {
+	s.updateJob(job, func(j *importJob) {
+		j.State = jobStateRunning
+		j.Stage = "importing"
+		j.Message = fmt.Sprintf("Importing %d bundles…", len(bundles))
+		j.Percent = 2
+		j.ImportURL = importURL
+		j.QueryURL = queryURL
+		j.RemotePath = importURL
+	})
+
+	retentionCutoff := s.retentionCutoff(ctx, cfg)
+	_, maxLabelsLimit, _ := s.resolveMaxLabelsLimit(ctx, cfg)
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+	finished := 0
+	for i := range bundles {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			s.updateJob(job, func(j *importJob) {
+				j.Bundles[i].State = jobStateRunning
+			})
+			result := s.importOneBundle(ctx, cfg, bundles[i], importURL, queryURL, retentionCutoff, maxLabelsLimit)
+			s.updateJob(job, func(j *importJob) {
+				j.Bundles[i] = result
+				j.InflatedBytes += result.inflatedBytes()
+				finished++
+				j.Percent = 2 + float64(finished)/float64(len(bundles))*96
+				j.Message = fmt.Sprintf("Imported %d/%d bundles…", finished, len(bundles))
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	s.updateJob(job, func(j *importJob) {
+		var combined importSummary
+		failed := 0
+		for _, bundle := range j.Bundles {
+			if bundle.Summary != nil {
+				combined.merge(*bundle.Summary)
+			}
+			if bundle.State == jobStateFailed {
+				failed++
+			}
+		}
+		j.Summary = &combined
+		j.Percent = 100
+		if failed > 0 {
+			j.State = jobStateFailed
+			j.Stage = "failed"
+			j.Error = fmt.Sprintf("%d of %d bundles failed to import", failed, len(j.Bundles))
+			j.Message = j.Error
+			return
+		}
+		j.State = jobStateCompleted
+		j.Stage = "completed"
+		j.Message = fmt.Sprintf("%s (%d bundles)", combined.completionMessage(), len(j.Bundles))
+	})
+}
+
+// importOneBundle extracts, imports and verifies one uploaded bundle and removes its files
+func (s *Server) importOneBundle(ctx context.Context, cfg uploadConfig, uploaded uploadedBundle, importURL, queryURL string, retentionCutoff int64, maxLabelsLimit int) bundleImport {
+	defer func() { _ = os.Remove(uploaded.path) }()
+	result := bundleImport{Name: uploaded.name, State: jobStateFailed}
+
+	bundle, err := prepareBundle(uploaded.path, uploaded.name, uploaded.bytes)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if bundle.Cleanup != nil {
+		defer bundle.Cleanup()
+	}
+	_, summary, err := s.streamImport(ctx, cfg, bundle, importURL, 0, retentionCutoff, cfg.TimeShiftMs, maxLabelsLimit, nil)
+	summary.SourceBytes = bundle.OriginalBytes
+	summary.InflatedBytes = bundle.ExtractedBytes
+	result.Summary = &summary
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.State = jobStateCompleted
+	result.Verification = s.verifyImport(ctx, cfg, summary, queryURL)
+	return result
+}
+
+func (b bundleImport) inflatedBytes() int64 {
+	if b.Summary == nil {
+		return 0
+	}
+	return b.Summary.InflatedBytes
+}
+
+// merge adds the counters of another bundle's summary; the time range grows to cover both and
+// the metric name, labels and examples come from the first bundles
+func (s *importSummary) merge(other importSummary) {
+	if s.MetricName == "" {
+		s.MetricName = other.MetricName
+		s.Labels = other.Labels
+	}
+	if !other.Start.IsZero() && (s.Start.IsZero() || other.Start.Before(s.Start)) {
+		s.Start = other.Start
+	}
+	if other.End.After(s.End) {
+		s.End = other.End
+	}
+	for _, example := range other.Examples {
+		if len(s.Examples) < 5 {
+			s.Examples = append(s.Examples, example)
+		}
+	}
+	s.TotalPoints += other.TotalPoints
+	s.Points += other.Points
+	s.Bytes += other.Bytes
+	s.SourceBytes += other.SourceBytes
+	s.InflatedBytes += other.InflatedBytes
+	s.Chunks += other.Chunks
+	s.ChunkBytes = other.ChunkBytes
+	s.SkippedLines += other.SkippedLines
+	s.DroppedOld += other.DroppedOld
+	s.ProcessedBytes += other.ProcessedBytes
+	s.NormalizedTs = s.NormalizedTs || other.NormalizedTs
+	s.MaxLabelsSeen = max(s.MaxLabelsSeen, other.MaxLabelsSeen)
+	s.OverLabelLimit += other.OverLabelLimit
+	s.OverLimitPts += other.OverLimitPts
+	s.NamelessSeries += other.NamelessSeries
+	s.DroppedNameless += other.DroppedNameless
+	s.DuplicateLabels += other.DuplicateLabels
+	s.NameConflicts += other.NameConflicts
+	s.DroppedConflict += other.DroppedConflict
+	s.LongLabels += other.LongLabels
+	s.TruncatedLabels += other.TruncatedLabels
+	s.DroppedLong += other.DroppedLong
+	for _, example := range other.LongLabelLines {
+		if len(s.LongLabelLines) >= maxOverLengthExamples {
+			break
+		}
+		s.LongLabelLines = append(s.LongLabelLines, example)
+	}
+	s.StaleMarkers += other.StaleMarkers
+	s.DroppedStale += other.DroppedStale
+	s.FutureSamples += other.FutureSamples
+	s.MaxLabelsLimit = other.MaxLabelsLimit
+	s.RemoteWarnings = append(s.RemoteWarnings, other.RemoteWarnings...)
+	s.RejectedRows += other.RejectedRows
+	s.PartialChunks += other.PartialChunks
+}
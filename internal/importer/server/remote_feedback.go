@@ -0,0 +1,147 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A target can answer 2xx yet accept only part of a chunk. Such replies are recorded in the
+// summary and the import goes on ("warn", default), or the import stops at that chunk ("fail").
+const (
+	partialSuccessWarn = "warn"
+	partialSuccessFail = "fail"
+)
+
+// maxRemoteWarnings caps the distinct target warnings kept in the import summary
+const maxRemoteWarnings = 10
+
+var (
+	remoteWarningKeywords = []string{"warn", "reject", "skip", "partial", "drop", "ignor", "error"}
+	remoteRejectedPattern = regexp.MustCompile(`(?i)(\d+)\s+(?:rows?|samples?|lines?|series|points?)\s+(?:were\s+|was\s+|have\s+been\s+)?(?:rejected|skipped|dropped|ignored)`)
+)
+
+// remoteFeedback is what a successful import response says about rows the target did not accept
+type remoteFeedback struct {
+	Warnings []string
+	Rejected int
+}
+
+func (f remoteFeedback) partial() bool {
+	return f.Rejected > 0 || len(f.Warnings) > 0
+}
+
+// parseRemoteFeedback looks for warning or partial-success indicators in a 2xx import response.
+// JSON bodies are read for warning(s)/error(s) and rejected row counters; plain text lines are
+// kept when they mention warnings, rejected or skipped rows. An empty body means a clean import.
+func parseRemoteFeedback(body string) remoteFeedback {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return remoteFeedback{}
+	}
+	var obj map[string]interface{}
+	if strings.HasPrefix(body, "{") && json.Unmarshal([]byte(body), &obj) == nil {
+		return feedbackFromJSON(obj)
+	}
+	var feedback remoteFeedback
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || !mentionsRemoteWarning(line) {
+			continue
+		}
+		feedback.Warnings = append(feedback.Warnings, line)
+		if m := remoteRejectedPattern.FindStringSubmatch(line); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			feedback.Rejected += n
+		}
+	}
+	return feedback
+}
+
+func feedbackFromJSON(obj map[string]interface{}) remoteFeedback {
+	var feedback remoteFeedback
+	for _, key := range []string{"warning", "warnings", "error", "errors"} {
+		feedback.Warnings = append(feedback.Warnings, jsonStrings(obj[key])...)
+	}
+	for _, key := range []string{"rejected", "rows_rejected", "rejected_rows", "skipped", "rows_skipped", "skipped_rows"} {
+		if n, ok := obj[key].(float64); ok && n > 0 {
+			feedback.Rejected += int(n)
+		}
+	}
+	if feedback.Rejected > 0 && len(feedback.Warnings) == 0 {
+		feedback.Warnings = []string{fmt.Sprintf("target rejected %d rows", feedback.Rejected)}
+	}
+	return feedback
+}
+
+// jsonStrings flattens a string or a list of strings/objects into warning texts
+func jsonStrings(v interface{}) []string {
+	switch val := v.(type) {
+	case string:
+		if s := strings.TrimSpace(val); s != "" {
+			return []string{s}
+		}
+	case []interface{}:
+		var out []string
+		for _, item := range val {
+			switch it := item.(type) {
+			case string:
+				out = append(out, jsonStrings(it)...)
+			case nil:
+			default:
+				if raw, err := json.Marshal(it); err == nil {
+					out = append(out, string(raw))
+				}
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func mentionsRemoteWarning(line string) bool {
+	lower := strings.ToLower(line)
+	for _, keyword := range remoteWarningKeywords {
+		if strings.Contains(lower, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordRemoteFeedback adds a chunk's feedback to the summary, keeping up to maxRemoteWarnings distinct warnings
+func (s *importSummary) recordRemoteFeedback(feedback remoteFeedback) {
+	s.RejectedRows += feedback.Rejected
+	for _, warning := range feedback.Warnings {
+		if len(s.RemoteWarnings) >= maxRemoteWarnings {
+			break
+		}
+		duplicate := false
+		for _, seen := range s.RemoteWarnings {
+			if seen == warning {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			s.RemoteWarnings = append(s.RemoteWarnings, warning)
+		}
+	}
+	if feedback.partial() {
+		s.PartialChunks++
+	}
+}
+
+// completionMessage is the final job message; partial successes are called out instead of a plain "Import completed"
+func (s *importSummary) completionMessage() string {
+	if s.PartialChunks == 0 {
+		return "Import completed"
+	}
+	msg := fmt.Sprintf("Import completed with target warnings on %d of %d chunks", s.PartialChunks, s.Chunks)
+	if s.RejectedRows > 0 {
+		msg += fmt.Sprintf(" (%d rows rejected)", s.RejectedRows)
+	}
+	return msg
+}
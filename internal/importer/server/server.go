@@ -16,7 +16,7 @@ import (
 	"io/fs"
 	"log"
 	"math"
-	"mime/multipart"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
@@ -26,12 +26,39 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
 )
 
 const importerHTTPTimeout = 5 * time.Minute
 
+// defaultVerifyTimeout bounds the post-import verification query; overridable via SetVerifyTimeout
+const defaultVerifyTimeout = time.Minute
+
+// defaultMaxUploadBytes caps uploaded bundles; overridable via SetMaxUploadSize
+const defaultMaxUploadBytes int64 = 512 << 20
+
+// defaultMaxMultiUploadBytes caps the bundles of one /api/upload-multi request combined;
+// overridable via SetMaxMultiUploadSize
+const defaultMaxMultiUploadBytes int64 = 4 << 30
+
+// maxUploadFieldBytes caps the non-file form fields of an upload, such as the JSON config,
+// together; a request with more field data is rejected
+const maxUploadFieldBytes = 1 << 20
+
+// Dialer defaults for requests to VictoriaMetrics; overridable via SetDialSettings
+const (
+	defaultDialTimeout = 30 * time.Second
+	defaultKeepAlive   = 30 * time.Second
+)
+
 var maxImportChunkBytes = 512 * 1024
 
+// maxImportLineBytes is the default hard limit for a single JSONL line (one series); see SetMaxLineSize.
+// Lines longer than maxImportChunkBytes are still imported, each in its own chunk, since chunks never split a line.
+var maxImportLineBytes = 16 * 1024 * 1024
+
 const (
 	defaultAnalyzeSampleLines = 2000
 	maxSimulationSeries       = 5000
@@ -41,6 +68,39 @@ const (
 	defaultAuthTypeNone       = "none"
 )
 
+// supportedMetadataSchemaVersion is the newest metadata.json schema this importer understands.
+// Bundles written before schema versioning was introduced carry no version and are treated as version 0.
+// Version 2 adds counter_encoding.
+const supportedMetadataSchemaVersion = 2
+
+// Counter encoding written by vmgather (`counter_encoding` in metadata.json). Delta-encoded
+// series carry counterEncodingLabel and are restored to absolute values before import.
+const (
+	counterEncodingLabel    = "vmgather_counter_encoding"
+	counterEncodingAbsolute = "absolute"
+	counterEncodingDelta    = "delta"
+)
+
+const (
+	namelessSeriesKeep       = "keep"
+	namelessSeriesDrop       = "drop"
+	namelessSeriesSynthesize = "synthesize"
+)
+
+// Staleness markers are exported by VictoriaMetrics as `null` values. They are imported as-is
+// ("preserve", default) so gaps replay faithfully, or removed together with their timestamps ("strip").
+const (
+	stalenessMarkersPreserve = "preserve"
+	stalenessMarkersStrip    = "strip"
+)
+
+// Samples timestamped after now+max_future_skew_seconds come from clock-skewed sources. They are
+// dropped ("drop", default) or the latest one per series is kept at the limit ("clamp").
+const (
+	futureSamplesDrop  = "drop"
+	futureSamplesClamp = "clamp"
+)
+
 var protectedDropLabels = []string{"__name__", "job", "instance"}
 
 //go:embed static/*
@@ -61,6 +121,19 @@ type uploadConfig struct {
 	TimeShiftMs       int64    `json:"time_shift_ms"`
 	MaxLabelsOverride int      `json:"max_labels_override,omitempty"`
 	DropLabels        []string `json:"drop_labels,omitempty"`
+	NamelessSeries    string   `json:"nameless_series,omitempty"`
+	StalenessMarkers  string   `json:"staleness_markers,omitempty"`
+	MetricNamePrefix  string   `json:"metric_name_prefix,omitempty"`
+	MaxFutureSkewSecs int      `json:"max_future_skew_seconds,omitempty"`
+	FutureSamples     string   `json:"future_samples,omitempty"`
+	PartialSuccess    string   `json:"partial_success,omitempty"`
+	LabelConflicts    string   `json:"label_conflicts,omitempty"`
+	// Label byte limits checked before posting; 0 uses the VictoriaMetrics defaults, negative disables
+	MaxLabelNameBytes  int    `json:"max_label_name_bytes,omitempty"`
+	MaxLabelValueBytes int    `json:"max_label_value_bytes,omitempty"`
+	OverLengthLabels   string `json:"over_length_labels,omitempty"`
+	// Headers are sent with every request to the target; tenant, auth and content headers take precedence
+	Headers map[string]string `json:"headers,omitempty"`
 }
 
 type recentProfile struct {
@@ -95,6 +168,7 @@ type verificationResult struct {
 	Start      string `json:"start"`
 	End        string `json:"end"`
 	Message    string `json:"message"`
+	Skipped    bool   `json:"skipped,omitempty"`
 }
 
 type bundleInfo struct {
@@ -107,13 +181,15 @@ type bundleInfo struct {
 }
 
 type bundleMetadata struct {
-	ExportID  string `json:"export_id"`
-	TimeRange struct {
+	SchemaVersion int    `json:"schema_version"`
+	ExportID      string `json:"export_id"`
+	TimeRange     struct {
 		Start string `json:"start"`
 		End   string `json:"end"`
 	} `json:"time_range"`
-	MetricsCount int      `json:"metrics_count"`
-	Jobs         []string `json:"jobs"`
+	MetricsCount    int      `json:"metrics_count"`
+	Jobs            []string `json:"jobs"`
+	CounterEncoding string   `json:"counter_encoding,omitempty"`
 }
 
 func (s *Server) newJob(uploadedBytes int64) *importJob {
@@ -157,6 +233,7 @@ func snapshotJob(job *importJob) importJob {
 		ver := *job.Verification
 		cp.Verification = &ver
 	}
+	cp.Bundles = append([]bundleImport(nil), job.Bundles...)
 	return cp
 }
 
@@ -199,6 +276,7 @@ type importJob struct {
 	BundlePath      string              `json:"bundle_path,omitempty"`
 	ResumeOffset    int64               `json:"resume_offset,omitempty"`
 	ResumeReady     bool                `json:"resume_ready,omitempty"`
+	Bundles         []bundleImport      `json:"bundles,omitempty"` // Per-bundle results of /api/upload-multi
 	Config          uploadConfig        `json:"-"`
 }
 
@@ -210,38 +288,53 @@ const (
 )
 
 type importSummary struct {
-	MetricName     string              `json:"metric_name"`
-	Labels         map[string]string   `json:"labels"`
-	Start          time.Time           `json:"start"`
-	End            time.Time           `json:"end"`
-	TotalPoints    int                 `json:"total_points,omitempty"`
-	Points         int                 `json:"points"`
-	Bytes          int64               `json:"bytes"`
-	SourceBytes    int64               `json:"source_bytes"`
-	InflatedBytes  int64               `json:"inflated_bytes"`
-	Chunks         int                 `json:"chunks"`
-	ChunkBytes     int                 `json:"chunk_bytes"`
-	Examples       []map[string]string `json:"examples,omitempty"`
-	SkippedLines   int                 `json:"skipped_lines,omitempty"`
-	DroppedOld     int                 `json:"dropped_old,omitempty"`
-	ProcessedBytes int64               `json:"processed_bytes,omitempty"`
-	NormalizedTs   bool                `json:"normalized_ts,omitempty"`
-	AnalyzedLines  int                 `json:"analyzed_lines,omitempty"`
-	ScannedLines   int                 `json:"scanned_lines,omitempty"`
-	SampleLimit    int                 `json:"sample_limit,omitempty"`
-	SampleCut      bool                `json:"sample_cut,omitempty"`
-	MaxLabelsSeen  int                 `json:"max_labels_seen,omitempty"`
-	OverLabelLimit int                 `json:"over_label_limit,omitempty"`
-	OverLimitPts   int                 `json:"over_limit_points,omitempty"`
-	MaxLabelsLimit int                 `json:"max_labels_limit,omitempty"`
-	TotalLabels    int                 `json:"total_labels,omitempty"`
-	LabelStats     []labelStat         `json:"label_stats,omitempty"`
-	LabelUniverse  []string            `json:"label_universe,omitempty"`
-	LabelBitsets   []string            `json:"series_label_bitsets,omitempty"`
-	LabelCounts    []int               `json:"series_label_counts,omitempty"`
-	PointCounts    []int               `json:"series_point_counts,omitempty"`
-	SimSeries      int                 `json:"simulation_series,omitempty"`
-	SimSeriesCut   bool                `json:"simulation_series_capped,omitempty"`
+	MetricName      string              `json:"metric_name"`
+	Labels          map[string]string   `json:"labels"`
+	Start           time.Time           `json:"start"`
+	End             time.Time           `json:"end"`
+	TotalPoints     int                 `json:"total_points,omitempty"`
+	Points          int                 `json:"points"`
+	Bytes           int64               `json:"bytes"`
+	SourceBytes     int64               `json:"source_bytes"`
+	InflatedBytes   int64               `json:"inflated_bytes"`
+	Chunks          int                 `json:"chunks"`
+	ChunkBytes      int                 `json:"chunk_bytes"`
+	Examples        []map[string]string `json:"examples,omitempty"`
+	SkippedLines    int                 `json:"skipped_lines,omitempty"`
+	DroppedOld      int                 `json:"dropped_old,omitempty"`
+	ProcessedBytes  int64               `json:"processed_bytes,omitempty"`
+	NormalizedTs    bool                `json:"normalized_ts,omitempty"`
+	AnalyzedLines   int                 `json:"analyzed_lines,omitempty"`
+	ScannedLines    int                 `json:"scanned_lines,omitempty"`
+	SampleLimit     int                 `json:"sample_limit,omitempty"`
+	SampleCut       bool                `json:"sample_cut,omitempty"`
+	MaxLabelsSeen   int                 `json:"max_labels_seen,omitempty"`
+	OverLabelLimit  int                 `json:"over_label_limit,omitempty"`
+	OverLimitPts    int                 `json:"over_limit_points,omitempty"`
+	NamelessSeries  int                 `json:"nameless_series,omitempty"`
+	DroppedNameless int                 `json:"dropped_nameless,omitempty"`
+	DuplicateLabels int                 `json:"duplicate_label_series,omitempty"` // Series repeating a label key
+	NameConflicts   int                 `json:"name_label_conflicts,omitempty"`   // Series whose name label differs from __name__
+	DroppedConflict int                 `json:"dropped_label_conflicts,omitempty"`
+	LongLabels      int                 `json:"over_length_label_series,omitempty"` // Series over max_label_name_bytes / max_label_value_bytes
+	LongLabelLines  []string            `json:"over_length_examples,omitempty"`
+	TruncatedLabels int                 `json:"truncated_label_series,omitempty"`
+	DroppedLong     int                 `json:"dropped_over_length_series,omitempty"`
+	StaleMarkers    int                 `json:"staleness_markers,omitempty"`
+	DroppedStale    int                 `json:"dropped_staleness_markers,omitempty"`
+	FutureSamples   int                 `json:"future_samples,omitempty"` // Dropped or clamped by max_future_skew_seconds
+	MaxLabelsLimit  int                 `json:"max_labels_limit,omitempty"`
+	TotalLabels     int                 `json:"total_labels,omitempty"`
+	LabelStats      []labelStat         `json:"label_stats,omitempty"`
+	LabelUniverse   []string            `json:"label_universe,omitempty"`
+	LabelBitsets    []string            `json:"series_label_bitsets,omitempty"`
+	LabelCounts     []int               `json:"series_label_counts,omitempty"`
+	PointCounts     []int               `json:"series_point_counts,omitempty"`
+	SimSeries       int                 `json:"simulation_series,omitempty"`
+	SimSeriesCut    bool                `json:"simulation_series_capped,omitempty"`
+	RemoteWarnings  []string            `json:"remote_warnings,omitempty"` // Reported by the target on 2xx responses
+	RejectedRows    int                 `json:"rejected_rows,omitempty"`
+	PartialChunks   int                 `json:"partial_chunks,omitempty"`
 
 	rangePinned bool
 }
@@ -251,9 +344,11 @@ type labelStat struct {
 	Count int    `json:"count"`
 }
 
+// metricLine is one series of a JSONL bundle. Values are decoded by vm.SampleValues, exactly as
+// the exporter reads /api/v1/export: numbers, numeric strings and null staleness markers.
 type metricLine struct {
 	Metric     map[string]string `json:"metric"`
-	Values     []json.RawMessage `json:"values"`
+	Values     vm.SampleValues   `json:"values"`
 	Timestamps []int64           `json:"timestamps"`
 }
 
@@ -261,29 +356,45 @@ type metricLine struct {
 type Server struct {
 	version             string
 	httpClient          *http.Client
+	dialer              *net.Dialer
+	verifyTimeout       time.Duration
 	jobs                map[string]*importJob
 	jobsMu              sync.RWMutex
 	insecureTLSWarnOnce sync.Once
 	profilesPath        string
 	profiles            []recentProfile
 	profilesMu          sync.RWMutex
+	maxUploadBytes      int64
+	maxMultiUploadBytes int64
+	importURLHosts      map[string]struct{}
+	maxLineBytes        int // 0 uses maxImportLineBytes
 }
 
 func NewServer(version string) *Server {
 	return newServer(version, defaultProfilesPath())
 }
 
+// NewServerWithProfilesPath is NewServer with recent profiles stored at profilesPath;
+// an empty path keeps them in memory only
+func NewServerWithProfilesPath(version, profilesPath string) *Server {
+	return newServer(version, profilesPath)
+}
+
 func newServer(version, profilesPath string) *Server {
-	transport := &http.Transport{TLSClientConfig: &tls.Config{MinVersion: tls.VersionTLS12}}
+	dialer := &net.Dialer{Timeout: defaultDialTimeout, KeepAlive: defaultKeepAlive}
 	server := &Server{
 		version: version,
 		httpClient: &http.Client{
 			Timeout:   importerHTTPTimeout,
-			Transport: transport,
+			Transport: newTransport(dialer, false),
 		},
-		jobs:         make(map[string]*importJob),
-		profilesPath: profilesPath,
-		profiles:     make([]recentProfile, 0, maxRecentProfiles),
+		dialer:              dialer,
+		verifyTimeout:       defaultVerifyTimeout,
+		jobs:                make(map[string]*importJob),
+		profilesPath:        profilesPath,
+		profiles:            make([]recentProfile, 0, maxRecentProfiles),
+		maxUploadBytes:      defaultMaxUploadBytes,
+		maxMultiUploadBytes: defaultMaxMultiUploadBytes,
 	}
 	server.loadRecentProfiles()
 	return server
@@ -559,8 +670,72 @@ func (s *Server) withInsecure(insecure bool, endpoint string) *http.Client {
 	s.insecureTLSWarnOnce.Do(func() {
 		log.Printf("[WARN] vmimporter is using skip_tls_verify for endpoint %s. Use only in trusted lab/dev environments.", redactURLForLog(endpoint))
 	})
-	transport := &http.Transport{TLSClientConfig: &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: true}} // #nosec G402 - intentional for air-gapped envs
-	return &http.Client{Timeout: importerHTTPTimeout, Transport: transport}
+	return &http.Client{Timeout: importerHTTPTimeout, Transport: newTransport(s.dialer, true)}
+}
+
+// SetDialSettings configures the TCP dial timeout and keepalive for requests to VictoriaMetrics.
+// Zero keeps the default; a negative keepalive disables TCP keepalives.
+func (s *Server) SetDialSettings(dialTimeout, keepAlive time.Duration) {
+	dialer := &net.Dialer{Timeout: defaultDialTimeout, KeepAlive: defaultKeepAlive}
+	if dialTimeout > 0 {
+		dialer.Timeout = dialTimeout
+	}
+	if keepAlive != 0 {
+		dialer.KeepAlive = keepAlive
+	}
+	s.dialer = dialer
+	s.httpClient.Transport = newTransport(dialer, false)
+}
+
+// SetVerifyTimeout bounds how long post-import verification may take before it is skipped.
+// Zero keeps the default.
+func (s *Server) SetVerifyTimeout(timeout time.Duration) {
+	if timeout > 0 {
+		s.verifyTimeout = timeout
+	}
+}
+
+// SetMaxLineSize sets the longest JSONL line (one series) analyze and import accept.
+// Non-positive values keep the default of 16 MiB.
+func (s *Server) SetMaxLineSize(maxBytes int) {
+	if maxBytes > 0 {
+		s.maxLineBytes = maxBytes
+	}
+}
+
+// lineLimit is the effective single-line limit
+func (s *Server) lineLimit() int {
+	if s.maxLineBytes > 0 {
+		return s.maxLineBytes
+	}
+	return maxImportLineBytes
+}
+
+// SetMaxUploadSize sets the largest bundle accepted by /api/upload and /api/analyze.
+// Non-positive values keep the default of 512 MiB.
+func (s *Server) SetMaxUploadSize(maxBytes int64) {
+	if maxBytes > 0 {
+		s.maxUploadBytes = maxBytes
+	}
+}
+
+// SetMaxMultiUploadSize sets the largest combined size of the bundles of one /api/upload-multi
+// request. Non-positive values keep the default of 4 GiB.
+func (s *Server) SetMaxMultiUploadSize(maxBytes int64) {
+	if maxBytes > 0 {
+		s.maxMultiUploadBytes = maxBytes
+	}
+}
+
+func newTransport(dialer *net.Dialer, insecure bool) *http.Transport {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if insecure {
+		tlsConfig.InsecureSkipVerify = true // #nosec G402 - intentional for air-gapped envs
+	}
+	return &http.Transport{
+		DialContext:     dialer.DialContext,
+		TLSClientConfig: tlsConfig,
+	}
 }
 
 func redactURLForLog(raw string) string {
@@ -587,6 +762,8 @@ func (s *Server) Router() http.Handler {
 	mux.HandleFunc("/api/profiles/recent", s.handleRecentProfiles)
 	mux.HandleFunc("/api/analyze", s.handleAnalyze)
 	mux.HandleFunc("/api/upload", s.handleUpload)
+	mux.HandleFunc("/api/upload-multi", s.handleUploadMulti)
+	mux.HandleFunc("/api/import-from-url", s.handleImportFromURL)
 	mux.HandleFunc("/api/check-endpoint", s.handleCheckEndpoint)
 	mux.HandleFunc("/api/import/status", s.handleJobStatus)
 	mux.HandleFunc("/api/import/resume", s.handleResume)
@@ -686,12 +863,20 @@ func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusMethodNotAllowed, "method not allowed")
 		return
 	}
-	if err := r.ParseMultipartForm(512 << 20); err != nil {
-		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("failed to parse form: %v", err))
+	form, err := readUploadForm(r, s.maxUploadBytes, s.maxUploadBytes, 1)
+	if err != nil {
+		respondWithUploadError(w, err)
 		return
 	}
+	// The job takes over the bundle once it starts; until then every early return removes it.
+	jobStarted := false
+	defer func() {
+		if !jobStarted {
+			form.cleanup()
+		}
+	}()
 
-	cfgRaw := r.FormValue("config")
+	cfgRaw := form.fields["config"]
 	if cfgRaw == "" {
 		respondWithError(w, http.StatusBadRequest, "missing config payload")
 		return
@@ -701,21 +886,15 @@ func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("invalid config: %v", err))
 		return
 	}
-	cfg.DropLabels = sanitizeDropLabels(cfg.DropLabels)
-	cfg.MaxLabelsOverride = sanitizeMaxLabelsOverride(cfg.MaxLabelsOverride)
-	file, header, err := r.FormFile("bundle")
-	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "bundle file is required")
+	if err := normalizeUploadConfig(&cfg); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	defer func() { _ = file.Close() }()
-	s.saveRecentProfile(cfg)
-
-	tempPath, uploadedBytes, err := persistUploadedFile(file)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("failed to persist bundle: %v", err))
+	if form.bundlePath == "" {
+		respondWithError(w, http.StatusBadRequest, "bundle file is required")
 		return
 	}
+	s.saveRecentProfile(cfg)
 
 	importURL, queryURL, err := resolveEndpoints(cfg)
 	if err != nil {
@@ -723,12 +902,13 @@ func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	job := s.newJob(uploadedBytes)
+	job := s.newJob(form.bundleBytes)
 	s.storeJob(job)
 
 	// Snapshot the queued job before starting async execution to avoid races under -race.
 	jobSnapshot := snapshotJob(job)
-	go s.runImportJob(context.Background(), job, cfg, tempPath, header.Filename, importURL, queryURL, 0)
+	jobStarted = true
+	go s.runImportJob(context.Background(), job, cfg, form.bundlePath, form.bundleName, importURL, queryURL, 0)
 
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(struct {
@@ -740,16 +920,61 @@ func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// normalizeUploadConfig sanitizes an import config and rejects unsupported option values
+func normalizeUploadConfig(cfg *uploadConfig) error {
+	cfg.DropLabels = sanitizeDropLabels(cfg.DropLabels)
+	cfg.MaxLabelsOverride = sanitizeMaxLabelsOverride(cfg.MaxLabelsOverride)
+	switch cfg.StalenessMarkers {
+	case "", stalenessMarkersPreserve, stalenessMarkersStrip:
+	default:
+		return fmt.Errorf("unsupported staleness_markers %q (use %q or %q)", cfg.StalenessMarkers, stalenessMarkersPreserve, stalenessMarkersStrip)
+	}
+	if cfg.MaxFutureSkewSecs < 0 {
+		return errors.New("max_future_skew_seconds must not be negative")
+	}
+	switch cfg.FutureSamples {
+	case "", futureSamplesDrop, futureSamplesClamp:
+	default:
+		return fmt.Errorf("unsupported future_samples %q (use %q or %q)", cfg.FutureSamples, futureSamplesDrop, futureSamplesClamp)
+	}
+	switch cfg.PartialSuccess {
+	case "", partialSuccessWarn, partialSuccessFail:
+	default:
+		return fmt.Errorf("unsupported partial_success %q (use %q or %q)", cfg.PartialSuccess, partialSuccessWarn, partialSuccessFail)
+	}
+	switch cfg.LabelConflicts {
+	case "", labelConflictsLast, labelConflictsFirst, labelConflictsDrop, labelConflictsError:
+	default:
+		return fmt.Errorf("unsupported label_conflicts %q (use %q, %q, %q or %q)", cfg.LabelConflicts, labelConflictsLast, labelConflictsFirst, labelConflictsDrop, labelConflictsError)
+	}
+	switch cfg.OverLengthLabels {
+	case "", overLengthLabelsTruncate, overLengthLabelsSkip:
+	default:
+		return fmt.Errorf("unsupported over_length_labels %q (use %q or %q)", cfg.OverLengthLabels, overLengthLabelsTruncate, overLengthLabelsSkip)
+	}
+	switch cfg.NamelessSeries {
+	case "", namelessSeriesKeep, namelessSeriesDrop, namelessSeriesSynthesize:
+	default:
+		return fmt.Errorf("unsupported nameless_series %q (use %q, %q or %q)", cfg.NamelessSeries, namelessSeriesKeep, namelessSeriesDrop, namelessSeriesSynthesize)
+	}
+	if !validMetricNamePrefix(cfg.MetricNamePrefix) {
+		return fmt.Errorf("invalid metric_name_prefix %q: use letters, digits, '_' or ':' and do not start with a digit", cfg.MetricNamePrefix)
+	}
+	return nil
+}
+
 func (s *Server) handleAnalyze(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		respondWithError(w, http.StatusMethodNotAllowed, "method not allowed")
 		return
 	}
-	if err := r.ParseMultipartForm(512 << 20); err != nil {
-		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("failed to parse form: %v", err))
+	form, err := readUploadForm(r, s.maxUploadBytes, s.maxUploadBytes, 1)
+	if err != nil {
+		respondWithUploadError(w, err)
 		return
 	}
-	cfgRaw := r.FormValue("config")
+	defer form.cleanup()
+	cfgRaw := form.fields["config"]
 	if cfgRaw == "" {
 		respondWithError(w, http.StatusBadRequest, "missing config payload")
 		return
@@ -763,28 +988,19 @@ func (s *Server) handleAnalyze(w http.ResponseWriter, r *http.Request) {
 	cfg.MaxLabelsOverride = sanitizeMaxLabelsOverride(cfg.MaxLabelsOverride)
 	s.saveRecentProfile(cfg)
 
-	fullCollection := parseBoolFormValue(r.FormValue("full_collection"))
+	fullCollection := parseBoolFormValue(form.fields["full_collection"])
 	sampleLimit := defaultAnalyzeSampleLines
 	analysisMode := "sample"
 	if fullCollection {
 		sampleLimit = 0
 		analysisMode = "full"
 	}
-	file, header, err := r.FormFile("bundle")
-	if err != nil {
+	if form.bundlePath == "" {
 		respondWithError(w, http.StatusBadRequest, "bundle file is required")
 		return
 	}
-	defer func() { _ = file.Close() }()
 
-	tempPath, uploadedBytes, err := persistUploadedFile(file)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("failed to persist bundle: %v", err))
-		return
-	}
-	defer func() { _ = os.Remove(tempPath) }()
-
-	bundle, err := prepareBundle(tempPath, header.Filename, uploadedBytes)
+	bundle, err := prepareBundle(form.bundlePath, form.bundleName, form.bundleBytes)
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("failed to prepare bundle: %v", err))
 		return
@@ -802,7 +1018,7 @@ func (s *Server) handleAnalyze(w http.ResponseWriter, r *http.Request) {
 		retentionCutoff = 0
 	}
 
-	summary, err := s.analyzeBundle(ctx, bundle, retentionCutoff, cfg.TimeShiftMs, maxLabelsLimit, cfg.DropLabels, sampleLimit)
+	summary, err := s.analyzeBundle(ctx, bundle, retentionCutoff, cfg.TimeShiftMs, maxLabelsLimit, cfg.DropLabels, cfg.NamelessSeries, resolveLabelLengthLimits(cfg), sampleLimit)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("failed to analyze bundle: %v", err))
 		return
@@ -828,14 +1044,174 @@ func (s *Server) handleAnalyze(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(payload)
 }
 
-func persistUploadedFile(src multipart.File) (string, int64, error) {
+// errPersistBundle marks local I/O failures while saving an uploaded bundle
+var errPersistBundle = errors.New("failed to persist bundle")
+
+// uploadTooLargeError reports a bundle, or with combined the bundles of one request together,
+// larger than the configured upload limit
+type uploadTooLargeError struct {
+	limit    int64
+	combined bool
+}
+
+func (e *uploadTooLargeError) Error() string {
+	if e.combined {
+		return fmt.Sprintf("bundles exceed combined max size of %s", formatUploadSize(e.limit))
+	}
+	return fmt.Sprintf("bundle exceeds max size of %s", formatUploadSize(e.limit))
+}
+
+// formatUploadSize renders a byte limit in the largest binary unit that divides it evenly
+func formatUploadSize(n int64) string {
+	switch {
+	case n >= 1<<30 && n%(1<<30) == 0:
+		return fmt.Sprintf("%d GiB", n>>30)
+	case n >= 1<<20 && n%(1<<20) == 0:
+		return fmt.Sprintf("%d MiB", n>>20)
+	case n >= 1<<10 && n%(1<<10) == 0:
+		return fmt.Sprintf("%d KiB", n>>10)
+	default:
+		return fmt.Sprintf("%d bytes", n)
+	}
+}
+
+// uploadForm is a parsed multipart upload whose bundle has already been written to a temp file
+type uploadForm struct {
+	fields      map[string]string
+	bundlePath  string
+	bundleName  string
+	bundleBytes int64
+	more        []uploadedBundle // further bundles when more than one is accepted
+}
+
+// uploadedBundle is one bundle file of an upload, persisted to a temp file
+type uploadedBundle struct {
+	path  string
+	name  string
+	bytes int64
+}
+
+// bundles lists every uploaded bundle in upload order
+func (f *uploadForm) bundles() []uploadedBundle {
+	if f.bundlePath == "" {
+		return nil
+	}
+	return append([]uploadedBundle{{path: f.bundlePath, name: f.bundleName, bytes: f.bundleBytes}}, f.more...)
+}
+
+// totalBytes is the combined size of the uploaded bundles
+func (f *uploadForm) totalBytes() int64 {
+	var total int64
+	for _, bundle := range f.bundles() {
+		total += bundle.bytes
+	}
+	return total
+}
+
+func (f *uploadForm) cleanup() {
+	for _, bundle := range f.bundles() {
+		_ = os.Remove(bundle.path)
+	}
+}
+
+// readUploadForm reads the multipart body part by part. Each bundle is streamed straight to a
+// temp file and rejected once it grows past maxBytes, or all bundles together past maxTotalBytes,
+// so uploads are never buffered in memory. Up to maxBundles bundle parts are kept; with
+// maxBundles 1 further ones are ignored.
+func readUploadForm(r *http.Request, maxBytes, maxTotalBytes int64, maxBundles int) (*uploadForm, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse form: %w", err)
+	}
+	form := &uploadForm{fields: make(map[string]string)}
+	fieldBytes := 0
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return form, nil
+		}
+		if err != nil {
+			form.cleanup()
+			return nil, fmt.Errorf("failed to parse form: %w", err)
+		}
+		name := part.FormName()
+		switch {
+		case name == "bundle" && form.bundlePath == "":
+			form.bundleName = part.FileName()
+			form.bundlePath, form.bundleBytes, err = persistBundlePart(part, maxBytes, maxTotalBytes, 0)
+		case name == "bundle" && len(form.more)+1 < maxBundles:
+			bundle := uploadedBundle{name: part.FileName()}
+			bundle.path, bundle.bytes, err = persistBundlePart(part, maxBytes, maxTotalBytes, form.totalBytes())
+			if err == nil {
+				form.more = append(form.more, bundle)
+			}
+		case name == "bundle" && maxBundles > 1:
+			err = fmt.Errorf("at most %d bundles can be uploaded at once", maxBundles)
+		case part.FileName() == "":
+			var value []byte
+			value, err = io.ReadAll(io.LimitReader(part, int64(maxUploadFieldBytes-fieldBytes)+1))
+			fieldBytes += len(value)
+			if err == nil && fieldBytes > maxUploadFieldBytes {
+				err = fmt.Errorf("form fields exceed %s (at %q)", formatUploadSize(maxUploadFieldBytes), name)
+			}
+			form.fields[name] = string(value)
+		}
+		_ = part.Close()
+		if err != nil {
+			form.cleanup()
+			return nil, err
+		}
+	}
+}
+
+// respondWithUploadError answers 413 for oversized bundles, 500 for local I/O failures and 400 for malformed forms
+func respondWithUploadError(w http.ResponseWriter, err error) {
+	var tooLarge *uploadTooLargeError
+	switch {
+	case errors.As(err, &tooLarge):
+		respondWithError(w, http.StatusRequestEntityTooLarge, err.Error())
+	case errors.Is(err, errPersistBundle):
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+	default:
+		respondWithError(w, http.StatusBadRequest, err.Error())
+	}
+}
+
+// persistBundlePart persists one bundle of an upload whose earlier bundles took stagedBytes,
+// reporting which of the per-bundle and the combined limit it exceeds
+func persistBundlePart(src io.Reader, maxBytes, maxTotalBytes, stagedBytes int64) (string, int64, error) {
+	limit := maxBytes
+	if remaining := maxTotalBytes - stagedBytes; remaining < limit {
+		limit = remaining
+	}
+	path, n, err := persistUploadedFile(src, limit)
+	var tooLarge *uploadTooLargeError
+	if errors.As(err, &tooLarge) && limit < maxBytes {
+		err = &uploadTooLargeError{limit: maxTotalBytes, combined: true}
+	}
+	return path, n, err
+}
+
+// persistUploadedFile copies src into a temp file, failing with uploadTooLargeError past maxBytes.
+// Only failures to write the temp file are reported as errPersistBundle; a body the client
+// stopped sending is a bad request.
+func persistUploadedFile(src io.Reader, maxBytes int64) (string, int64, error) {
 	tmp, err := os.CreateTemp("", "vmimport-upload-*")
 	if err != nil {
-		return "", 0, err
+		return "", 0, fmt.Errorf("%w: %v", errPersistBundle, err)
 	}
 	defer func() { _ = tmp.Close() }()
 
-	n, err := io.Copy(tmp, src)
+	body := &bodyReader{r: io.LimitReader(src, maxBytes+1)}
+	n, err := io.Copy(tmp, body)
+	switch {
+	case err == nil && n > maxBytes:
+		err = &uploadTooLargeError{limit: maxBytes}
+	case body.err != nil:
+		err = fmt.Errorf("failed to read bundle: %w", body.err)
+	case err != nil:
+		err = fmt.Errorf("%w: %v", errPersistBundle, err)
+	}
 	if err != nil {
 		_ = os.Remove(tmp.Name())
 		return "", 0, err
@@ -843,6 +1219,21 @@ func persistUploadedFile(src multipart.File) (string, int64, error) {
 	return tmp.Name(), n, nil
 }
 
+// bodyReader remembers the first error reading the request body, telling it apart from
+// errors writing the copy
+type bodyReader struct {
+	r   io.Reader
+	err error
+}
+
+func (b *bodyReader) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if err != nil && err != io.EOF && b.err == nil {
+		b.err = err
+	}
+	return n, err
+}
+
 func prepareBundle(path, originalName string, uploadedBytes int64) (*bundleInfo, error) {
 	ext := strings.ToLower(filepath.Ext(originalName))
 	switch ext {
@@ -877,7 +1268,7 @@ func prepareBundle(path, originalName string, uploadedBytes int64) (*bundleInfo,
 	}
 }
 
-func (s *Server) analyzeBundle(ctx context.Context, bundle *bundleInfo, retentionCutoffMs int64, shiftMs int64, maxLabelsLimit int, dropLabels []string, sampleLimit int) (importSummary, error) {
+func (s *Server) analyzeBundle(ctx context.Context, bundle *bundleInfo, retentionCutoffMs int64, shiftMs int64, maxLabelsLimit int, dropLabels []string, namelessPolicy string, labelLimits labelLengthLimits, sampleLimit int) (importSummary, error) {
 	summary := importSummary{
 		Labels:         make(map[string]string),
 		SourceBytes:    bundle.OriginalBytes,
@@ -902,9 +1293,7 @@ func (s *Server) analyzeBundle(ctx context.Context, bundle *bundleInfo, retentio
 	}
 	defer func() { _ = file.Close() }()
 
-	scanner := bufio.NewScanner(file)
-	buf := make([]byte, 0, 1024*1024)
-	scanner.Buffer(buf, 16*1024*1024)
+	scanner := newMetricsLineScanner(file, s.lineLimit())
 	linesScanned := 0
 
 	for scanner.Scan() {
@@ -921,12 +1310,20 @@ func (s *Server) analyzeBundle(ctx context.Context, bundle *bundleInfo, retentio
 			summary.SkippedLines++
 			continue
 		}
+		// Preflight only reports label anomalies; label_conflicts is applied on import
+		_, _ = summary.applyLabelConflictPolicy(&parsed, line, linesScanned, labelConflictsLast)
+		takeCounterDeltaMarker(parsed.Metric)
 		rawLabels := make([]string, 0, len(parsed.Metric))
 		for label := range parsed.Metric {
 			labelCounts[label]++
 			rawLabels = append(rawLabels, label)
 		}
 		parsed.Metric = filterMetricLabels(parsed.Metric, dropSet)
+		if !summary.applyNamelessPolicy(&parsed, namelessPolicy) {
+			continue
+		}
+		// Preflight only reports over-length labels; over_length_labels is applied on import
+		summary.applyLabelLengthPolicy(&parsed, linesScanned, labelLimits, "", true)
 		summary.AnalyzedLines++
 		labelCount := len(parsed.Metric)
 		if labelCount > summary.MaxLabelsSeen {
@@ -936,11 +1333,8 @@ func (s *Server) analyzeBundle(ctx context.Context, bundle *bundleInfo, retentio
 			summary.OverLabelLimit++
 			summary.OverLimitPts += len(parsed.Timestamps)
 		}
-		values, err := normalizeValues(parsed.Values)
-		if err != nil {
-			summary.SkippedLines++
-			continue
-		}
+		values := []float64(parsed.Values)
+		summary.StaleMarkers += countStaleMarkers(values)
 		parsedTotal := len(parsed.Timestamps)
 		summary.TotalPoints += parsedTotal
 		filteredTs, filteredVals, dropped := filterTimestampsAndValues(parsed.Timestamps, values, retentionCutoffMs)
@@ -979,7 +1373,7 @@ func (s *Server) analyzeBundle(ctx context.Context, bundle *bundleInfo, retentio
 		}
 	}
 	if err := scanner.Err(); err != nil {
-		return summary, err
+		return summary, metricsLineError(err, linesScanned+1, s.lineLimit())
 	}
 	summary.ScannedLines = linesScanned
 	if summary.InflatedBytes == 0 {
@@ -1062,6 +1456,12 @@ func buildAnalysisWarnings(summary importSummary, cutoffMs int64, maxLabelsLimit
 	if summary.DroppedOld > 0 {
 		warnings = append(warnings, fmt.Sprintf("Dropped %d samples outside retention window.", summary.DroppedOld))
 	}
+	if summary.DuplicateLabels > 0 || summary.NameConflicts > 0 {
+		warnings = append(warnings, fmt.Sprintf("Label anomalies: %d series repeat a label key and %d series have a name label that differs from __name__. Choose label_conflicts (last, first, drop-series or error) to resolve them on import.", summary.DuplicateLabels, summary.NameConflicts))
+	}
+	if summary.LongLabels > 0 {
+		warnings = append(warnings, fmt.Sprintf("Label length: %d series have a label name or value over the VictoriaMetrics limits (e.g. %s). Choose over_length_labels (truncate or skip) to resolve them on import, or raise max_label_name_bytes / max_label_value_bytes to match the target.", summary.LongLabels, summary.LongLabelLines[0]))
+	}
 	if summary.NormalizedTs {
 		warnings = append(warnings, "Timestamps were auto-scaled to milliseconds (detected non-ms input).")
 	}
@@ -1097,22 +1497,32 @@ func buildLabelStats(labelCounts map[string]int, limit int) []labelStat {
 	return stats
 }
 
+// maxBundleEntries bounds the entries of a zip bundle. vmgather archives hold fewer than ten,
+// so a bundle with more is not one and is refused before any entry is inspected.
+const maxBundleEntries = 1000
+
 func prepareZipBundle(path string, uploadedBytes int64) (*bundleInfo, error) {
 	reader, err := zip.OpenReader(path)
 	if err != nil {
 		return nil, fmt.Errorf("cannot open zip bundle: %w", err)
 	}
 	defer func() { _ = reader.Close() }()
+	if len(reader.File) > maxBundleEntries {
+		return nil, fmt.Errorf("zip bundle has %d entries, more than the %d allowed", len(reader.File), maxBundleEntries)
+	}
 
 	var metricsFile *zip.File
 	var jsonlCandidates []*zip.File
 	var metadata *bundleMetadata
+	hasNative := false
 
 	for _, f := range reader.File {
 		nameLower := strings.ToLower(f.Name)
 		switch nameLower {
 		case "metrics.jsonl":
 			metricsFile = f
+		case "metrics.native":
+			hasNative = true
 		case "metadata.json":
 			meta, err := parseMetadataFile(f)
 			if err != nil {
@@ -1143,6 +1553,9 @@ func prepareZipBundle(path string, uploadedBytes int64) (*bundleInfo, error) {
 			if validationErr != nil {
 				return nil, validationErr
 			}
+			if hasNative {
+				return nil, errors.New("bundle holds VictoriaMetrics native data (metrics.native); import it with /api/v1/import/native or vmctl")
+			}
 			return nil, errors.New("bundle is missing metrics data (.jsonl)")
 		}
 	}
@@ -1292,7 +1705,7 @@ func (s *Server) runImportJob(ctx context.Context, job *importJob, cfg uploadCon
 	s.updateJob(job, func(j *importJob) {
 		j.State = jobStateCompleted
 		j.Stage = "completed"
-		j.Message = "Import completed"
+		j.Message = summary.completionMessage()
 		j.Percent = 100
 		j.Summary = &summary
 		j.Verification = verification
@@ -1313,9 +1726,32 @@ func parseMetadataFile(f *zip.File) (*bundleMetadata, error) {
 	if err := json.NewDecoder(rc).Decode(&meta); err != nil {
 		return nil, fmt.Errorf("failed to parse metadata.json: %w", err)
 	}
+	if err := migrateBundleMetadata(&meta); err != nil {
+		return nil, err
+	}
 	return &meta, nil
 }
 
+// migrateBundleMetadata validates the metadata schema version and upgrades older layouts in place.
+func migrateBundleMetadata(meta *bundleMetadata) error {
+	switch {
+	case meta.SchemaVersion < 0:
+		return fmt.Errorf("metadata.json has invalid schema_version %d", meta.SchemaVersion)
+	case meta.SchemaVersion > supportedMetadataSchemaVersion:
+		return fmt.Errorf("bundle metadata schema_version %d is newer than supported version %d; upgrade vmimporter to import this bundle", meta.SchemaVersion, supportedMetadataSchemaVersion)
+	case meta.SchemaVersion < supportedMetadataSchemaVersion:
+		// Pre-versioned and v1 bundles share the current field layout without counter_encoding,
+		// so only the version needs to be bumped.
+		meta.SchemaVersion = supportedMetadataSchemaVersion
+	}
+	switch meta.CounterEncoding {
+	case "", counterEncodingAbsolute, counterEncodingDelta:
+	default:
+		return fmt.Errorf("bundle uses unsupported counter_encoding %q; upgrade vmimporter to import this bundle", meta.CounterEncoding)
+	}
+	return nil
+}
+
 func isLikelyMetricsFile(f *zip.File) (bool, error) {
 	rc, err := f.Open()
 	if err != nil {
@@ -1363,6 +1799,8 @@ func (s *Server) streamImport(ctx context.Context, cfg uploadConfig, bundle *bun
 		MaxLabelsLimit: maxLabelsLimit,
 	}
 	dropSet := dropLabelsSet(cfg.DropLabels)
+	labelLimits := resolveLabelLengthLimits(cfg)
+	futureLimitMs := futureSkewLimitMs(cfg.MaxFutureSkewSecs, time.Now())
 	if summary.InflatedBytes == 0 && bundle.ExtractedBytes > 0 {
 		summary.InflatedBytes = bundle.ExtractedBytes
 	}
@@ -1405,9 +1843,8 @@ func (s *Server) streamImport(ctx context.Context, cfg uploadConfig, bundle *bun
 		chunkEndOffset int64
 	)
 
-	scanner := bufio.NewScanner(file)
-	buf := make([]byte, 0, 1024*1024)
-	scanner.Buffer(buf, 16*1024*1024)
+	scanner := newMetricsLineScanner(file, s.lineLimit())
+	lineNumber := 0
 
 	commitChunk := func() error {
 		if chunk.Len() == 0 {
@@ -1420,6 +1857,12 @@ func (s *Server) streamImport(ctx context.Context, cfg uploadConfig, bundle *bun
 			summary.ProcessedBytes = committedOffset
 			return err
 		}
+		feedback := parseRemoteFeedback(message)
+		summary.recordRemoteFeedback(feedback)
+		if feedback.partial() && cfg.PartialSuccess == partialSuccessFail {
+			summary.ProcessedBytes = committedOffset
+			return fmt.Errorf("remote accepted chunk %d only partially: %s", summary.Chunks+1, strings.Join(feedback.Warnings, "; "))
+		}
 		summary.Bytes += int64(len(body))
 		summary.Points += chunkPoints
 		summary.Chunks++
@@ -1470,6 +1913,7 @@ func (s *Server) streamImport(ctx context.Context, cfg uploadConfig, bundle *bun
 
 	for scanner.Scan() {
 		line := scanner.Bytes()
+		lineNumber++
 		currentOffset += int64(len(line)) + 1 // account for newline
 
 		var parsed metricLine
@@ -1477,7 +1921,22 @@ func (s *Server) streamImport(ctx context.Context, cfg uploadConfig, bundle *bun
 			summary.SkippedLines++
 			continue
 		}
+		keep, err := summary.applyLabelConflictPolicy(&parsed, line, lineNumber, cfg.LabelConflicts)
+		if err != nil {
+			return nil, summary, err
+		}
+		if !keep {
+			continue
+		}
+		deltaEncoded := takeCounterDeltaMarker(parsed.Metric)
 		parsed.Metric = filterMetricLabels(parsed.Metric, dropSet)
+		if !summary.applyNamelessPolicy(&parsed, cfg.NamelessSeries) {
+			continue
+		}
+		applyMetricNamePrefix(parsed.Metric, cfg.MetricNamePrefix)
+		if !summary.applyLabelLengthPolicy(&parsed, lineNumber, labelLimits, cfg.OverLengthLabels, false) {
+			continue
+		}
 		summary.AnalyzedLines++
 		labelCount := len(parsed.Metric)
 		if labelCount > summary.MaxLabelsSeen {
@@ -1489,10 +1948,16 @@ func (s *Server) streamImport(ctx context.Context, cfg uploadConfig, bundle *bun
 		}
 
 		parsed.Timestamps, _ = normalizeTimestamps(parsed.Timestamps)
-		values, err := normalizeValues(parsed.Values)
-		if err != nil {
-			summary.SkippedLines++
-			continue
+		values := []float64(parsed.Values)
+		if deltaEncoded {
+			restoreCounterDeltas(values)
+		}
+		if markers := countStaleMarkers(values); markers > 0 {
+			summary.StaleMarkers += markers
+			if cfg.StalenessMarkers == stalenessMarkersStrip {
+				parsed.Timestamps, values = stripStaleMarkers(parsed.Timestamps, values)
+				summary.DroppedStale += markers
+			}
 		}
 		filteredTs, filteredVals, dropped := filterTimestampsAndValues(parsed.Timestamps, values, retentionCutoffMs)
 		if dropped > 0 {
@@ -1512,6 +1977,15 @@ func (s *Server) streamImport(ctx context.Context, cfg uploadConfig, bundle *bun
 				filteredTs[i] += shiftMs
 			}
 		}
+		// Checked after the time shift, which can itself move samples into the future
+		if futureLimitMs > 0 {
+			var guarded int
+			filteredTs, filteredVals, guarded = guardFutureSamples(filteredTs, filteredVals, futureLimitMs, cfg.FutureSamples == futureSamplesClamp)
+			summary.FutureSamples += guarded
+			if len(filteredTs) == 0 {
+				continue
+			}
+		}
 
 		normalized, err := buildNormalizedLine(parsed.Metric, filteredVals, filteredTs)
 		if err != nil {
@@ -1519,6 +1993,12 @@ func (s *Server) streamImport(ctx context.Context, cfg uploadConfig, bundle *bun
 			continue
 		}
 
+		// Flush before the line that would overflow the chunk, so every chunk is whole JSONL lines
+		if chunk.Len() > 0 && chunk.Len()+len(normalized)+1 > maxImportChunkBytes {
+			if err := commitChunk(); err != nil {
+				return nil, summary, err
+			}
+		}
 		chunk.Write(normalized)
 		chunk.WriteByte('\n')
 		chunkPoints += len(filteredTs)
@@ -1546,7 +2026,7 @@ func (s *Server) streamImport(ctx context.Context, cfg uploadConfig, bundle *bun
 		}
 	}
 	if err := scanner.Err(); err != nil {
-		return nil, summary, err
+		return nil, summary, metricsLineError(err, lineNumber+1, s.lineLimit())
 	}
 	if err := commitChunk(); err != nil {
 		return nil, summary, err
@@ -1565,45 +2045,106 @@ func (s *Server) streamImport(ctx context.Context, cfg uploadConfig, bundle *bun
 	}, summary, nil
 }
 
-func normalizeValues(raw []json.RawMessage) ([]float64, error) {
-	values := make([]float64, 0, len(raw))
-	for _, v := range raw {
-		// Try to decode as number first
-		var num json.Number
-		if err := json.Unmarshal(v, &num); err == nil {
-			f, err := num.Float64()
-			if err != nil {
-				return nil, err
-			}
-			values = append(values, f)
-			continue
+// newMetricsLineScanner reads JSONL lines, growing its buffer for long series up to maxLineBytes
+func newMetricsLineScanner(r io.Reader, maxLineBytes int) *bufio.Scanner {
+	initial := 1024 * 1024
+	if initial > maxLineBytes {
+		initial = maxLineBytes
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, initial), maxLineBytes)
+	return scanner
+}
+
+// metricsLineError explains scanner failures; an over-long line is reported with its number and the limit
+func metricsLineError(err error, lineNumber, maxLineBytes int) error {
+	if errors.Is(err, bufio.ErrTooLong) {
+		return fmt.Errorf("line %d is longer than the %s limit for a single series; restart vmimporter with a higher -max-line-mb or re-export it with fewer points per line (e.g. a shorter batch window)",
+			lineNumber, formatUploadSize(int64(maxLineBytes)))
+	}
+	return err
+}
+
+// takeCounterDeltaMarker removes the counter encoding label and reports whether the series is delta-encoded
+func takeCounterDeltaMarker(labels map[string]string) bool {
+	encoding, ok := labels[counterEncodingLabel]
+	if !ok {
+		return false
+	}
+	delete(labels, counterEncodingLabel)
+	return encoding == counterEncodingDelta
+}
+
+// restoreCounterDeltas turns delta-encoded samples back into absolute counter values in place.
+// Decoding must happen before retention filtering so dropped samples still contribute to the sum.
+func restoreCounterDeltas(values []float64) {
+	for i := 1; i < len(values); i++ {
+		values[i] += values[i-1]
+	}
+}
+
+func countStaleMarkers(values []float64) int {
+	count := 0
+	for _, v := range values {
+		if vm.IsStaleNaN(v) {
+			count++
 		}
+	}
+	return count
+}
 
-		// Strings that contain numbers ("1", "0.5", "NaN")
-		var s string
-		if err := json.Unmarshal(v, &s); err == nil {
-			f, err := strconv.ParseFloat(s, 64)
-			if err != nil {
-				return nil, err
-			}
-			values = append(values, f)
+// stripStaleMarkers drops staleness markers together with their timestamps
+func stripStaleMarkers(timestamps []int64, values []float64) ([]int64, []float64) {
+	if len(timestamps) != len(values) {
+		return timestamps, values
+	}
+	keptTs := make([]int64, 0, len(timestamps))
+	keptVals := make([]float64, 0, len(values))
+	for i, v := range values {
+		if vm.IsStaleNaN(v) {
 			continue
 		}
+		keptTs = append(keptTs, timestamps[i])
+		keptVals = append(keptVals, v)
+	}
+	return keptTs, keptVals
+}
 
-		// Booleans as 0/1
-		var b bool
-		if err := json.Unmarshal(v, &b); err == nil {
-			if b {
-				values = append(values, 1)
-			} else {
-				values = append(values, 0)
+// futureSkewLimitMs returns now+skew in milliseconds, or 0 when the guard is disabled
+func futureSkewLimitMs(skewSeconds int, now time.Time) int64 {
+	if skewSeconds <= 0 {
+		return 0
+	}
+	return now.Add(time.Duration(skewSeconds) * time.Second).UnixMilli()
+}
+
+// guardFutureSamples removes samples after limitMs and returns how many there were.
+// With clamp the latest of them is kept at limitMs unless a sample already sits there.
+func guardFutureSamples(timestamps []int64, values []float64, limitMs int64, clamp bool) ([]int64, []float64, int) {
+	if len(timestamps) != len(values) {
+		return timestamps, values, 0
+	}
+	keptTs := timestamps[:0]
+	keptVals := values[:0]
+	future := 0
+	var latest float64
+	var latestTs int64
+	for i, ts := range timestamps {
+		if ts > limitMs {
+			future++
+			if ts >= latestTs {
+				latest, latestTs = values[i], ts
 			}
 			continue
 		}
-
-		return nil, fmt.Errorf("unsupported value %s", string(v))
+		keptTs = append(keptTs, ts)
+		keptVals = append(keptVals, values[i])
 	}
-	return values, nil
+	if future > 0 && clamp && (len(keptTs) == 0 || keptTs[len(keptTs)-1] < limitMs) {
+		keptTs = append(keptTs, limitMs)
+		keptVals = append(keptVals, latest)
+	}
+	return keptTs, keptVals, future
 }
 
 func normalizeTimestamps(ts []int64) ([]int64, bool) {
@@ -1679,7 +2220,7 @@ func filterTimestampsAndValues(timestamps []int64, values []float64, cutoffMs in
 func buildNormalizedLine(labels map[string]string, values []float64, timestamps []int64) ([]byte, error) {
 	payload := struct {
 		Metric     map[string]string `json:"metric"`
-		Values     []float64         `json:"values"`
+		Values     vm.SampleValues   `json:"values"`
 		Timestamps []int64           `json:"timestamps"`
 	}{
 		Metric:     labels,
@@ -1697,6 +2238,7 @@ func (s *Server) postImportChunk(ctx context.Context, cfg uploadConfig, importUR
 	req.Header.Set("Content-Type", "application/jsonl")
 	applyTenantHeaders(req, cfg)
 	applyAuthHeaders(req, cfg)
+	applyCustomHeaders(req, cfg)
 
 	client := s.withInsecure(cfg.SkipTLSVerify, importURL)
 	resp, err := client.Do(req)
@@ -1712,6 +2254,45 @@ func (s *Server) postImportChunk(ctx context.Context, cfg uploadConfig, importUR
 	return resp.StatusCode, strings.TrimSpace(string(bodyBytes)), nil
 }
 
+// applyNamelessPolicy handles series without __name__ according to policy
+// ("keep" default, "drop", "synthesize"). Returns false when the series must be skipped.
+func (s *importSummary) applyNamelessPolicy(parsed *metricLine, policy string) bool {
+	if parsed.Metric == nil || parsed.Metric["__name__"] != "" {
+		return true
+	}
+	s.NamelessSeries++
+	switch policy {
+	case namelessSeriesDrop:
+		s.DroppedNameless++
+		return false
+	case namelessSeriesSynthesize:
+		parsed.Metric["__name__"] = domain.SynthesizeMetricName(parsed.Metric)
+	}
+	return true
+}
+
+// applyMetricNamePrefix namespaces imported series, e.g. cust1_ + vm_rows turns into cust1_vm_rows.
+// Nameless series are left alone. The summary and verification see the prefixed name.
+func applyMetricNamePrefix(labels map[string]string, prefix string) {
+	if prefix == "" || labels["__name__"] == "" {
+		return
+	}
+	labels["__name__"] = prefix + labels["__name__"]
+}
+
+// validMetricNamePrefix reports whether prefix keeps metric names valid: [a-zA-Z_:][a-zA-Z0-9_:]*
+func validMetricNamePrefix(prefix string) bool {
+	for i, r := range prefix {
+		switch {
+		case r == '_' || r == ':' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z'):
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
 func (s *importSummary) consumeMetric(parsed metricLine) error {
 	if parsed.Metric == nil {
 		return errors.New("metrics line missing labels")
@@ -1821,6 +2402,21 @@ func (s *Server) verifyImport(ctx context.Context, cfg uploadConfig, summary imp
 	params.Set("start", fmt.Sprintf("%d", start))
 	params.Set("end", fmt.Sprintf("%d", end))
 
+	// A slow or hung query endpoint must not keep the job in "verifying": the data is already imported
+	ctx, cancel := context.WithTimeout(ctx, s.verifyTimeout)
+	defer cancel()
+	timedOut := func() *verificationResult {
+		if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil
+		}
+		return &verificationResult{
+			Verified: false,
+			Skipped:  true,
+			Query:    match,
+			Message:  fmt.Sprintf("verification skipped due to timeout: query endpoint did not answer within %s", s.verifyTimeout),
+		}
+	}
+
 	var lastErr string
 	for attempt := 0; attempt < 3; attempt++ {
 		req, err := http.NewRequestWithContext(ctx, http.MethodGet, seriesURL+"?"+params.Encode(), nil)
@@ -1829,10 +2425,14 @@ func (s *Server) verifyImport(ctx context.Context, cfg uploadConfig, summary imp
 		}
 		applyTenantHeaders(req, cfg)
 		applyAuthHeaders(req, cfg)
+		applyCustomHeaders(req, cfg)
 
 		client := s.withInsecure(cfg.SkipTLSVerify, seriesURL)
 		resp, err := client.Do(req)
 		if err != nil {
+			if result := timedOut(); result != nil {
+				return result
+			}
 			lastErr = err.Error()
 		} else {
 			body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
@@ -1870,7 +2470,14 @@ func (s *Server) verifyImport(ctx context.Context, cfg uploadConfig, summary imp
 				}
 			}
 		}
-		time.Sleep(700 * time.Millisecond)
+		select {
+		case <-ctx.Done():
+			if result := timedOut(); result != nil {
+				return result
+			}
+			return &verificationResult{Verified: false, Query: match, Message: ctx.Err().Error()}
+		case <-time.After(700 * time.Millisecond):
+		}
 	}
 	return &verificationResult{Verified: false, Query: match, Message: lastErr}
 }
@@ -1926,30 +2533,78 @@ func parseBoolFormValue(value string) bool {
 	}
 }
 
+// pingEndpoint checks that the import URL is reachable and looks like a metrics ingestion endpoint.
+// Proxies or UIs that answer every path with 200 would otherwise accept the upload and silently drop it.
 func (s *Server) pingEndpoint(ctx context.Context, cfg uploadConfig) error {
 	importURL, _, err := resolveEndpoints(cfg)
 	if err != nil {
 		return err
 	}
-	req, err := http.NewRequestWithContext(ctx, http.MethodHead, importURL, nil)
+	resp, err := s.probeImportEndpoint(ctx, cfg, http.MethodHead, importURL)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	// Some gateways only route POST to the import handler; OPTIONS tells whether POST is allowed there.
+	if resp.StatusCode == http.StatusMethodNotAllowed {
+		optionsResp, err := s.probeImportEndpoint(ctx, cfg, http.MethodOptions, importURL)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = optionsResp.Body.Close() }()
+		if optionsResp.StatusCode < http.StatusBadRequest && allowsMethod(optionsResp.Header.Get("Allow"), http.MethodPost) {
+			return nil
+		}
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		msg := fmt.Sprintf("remote responded %s: %s", resp.Status, strings.TrimSpace(string(body)))
+		if resp.StatusCode == http.StatusNotFound {
+			msg += " (the endpoint does not serve /api/v1/import; point it at vminsert, vmsingle or vmagent)"
+		}
+		return errors.New(msg)
+	}
+	return detectImportEndpoint(resp)
+}
+
+func (s *Server) probeImportEndpoint(ctx context.Context, cfg uploadConfig, method, importURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, importURL, nil)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	applyTenantHeaders(req, cfg)
 	applyAuthHeaders(req, cfg)
+	applyCustomHeaders(req, cfg)
 	client := s.withInsecure(cfg.SkipTLSVerify, importURL)
 	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("dial failed: %w", err)
+		return nil, fmt.Errorf("dial failed: %w", err)
 	}
-	defer func() { _ = resp.Body.Close() }()
-	if resp.StatusCode >= http.StatusBadRequest {
-		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
-		return fmt.Errorf("remote responded %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	return resp, nil
+}
+
+// detectImportEndpoint rejects successful probe responses that come from something other than
+// a metrics import handler: redirects away from /api/v1/import (e.g. a login page) or HTML pages
+func detectImportEndpoint(resp *http.Response) error {
+	if resp.Request != nil && resp.Request.URL != nil && !strings.HasSuffix(strings.TrimRight(resp.Request.URL.Path, "/"), "/api/v1/import") {
+		return fmt.Errorf("endpoint redirected to %s, which is not a metrics import endpoint; check the URL and auth settings", resp.Request.URL.Redacted())
+	}
+	if mediaType := strings.ToLower(strings.TrimSpace(strings.Split(resp.Header.Get("Content-Type"), ";")[0])); mediaType == "text/html" {
+		return fmt.Errorf("endpoint answered /api/v1/import with an HTML page; it looks like a web UI or proxy rather than a VictoriaMetrics ingestion endpoint (vminsert, vmsingle or vmagent)")
 	}
 	return nil
 }
 
+// allowsMethod reports whether an Allow header value lists method
+func allowsMethod(allow, method string) bool {
+	for _, candidate := range strings.Split(allow, ",") {
+		if strings.EqualFold(strings.TrimSpace(candidate), method) {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *Server) retentionCutoff(ctx context.Context, cfg uploadConfig) int64 {
 	importURL, _, err := resolveEndpoints(cfg)
 	if err != nil {
@@ -1968,6 +2623,7 @@ func (s *Server) retentionCutoff(ctx context.Context, cfg uploadConfig) int64 {
 	if err == nil {
 		applyTenantHeaders(req, cfg)
 		applyAuthHeaders(req, cfg)
+		applyCustomHeaders(req, cfg)
 
 		client := s.withInsecure(cfg.SkipTLSVerify, parsed.String())
 		resp, err := client.Do(req)
@@ -2002,6 +2658,7 @@ func (s *Server) retentionCutoff(ctx context.Context, cfg uploadConfig) int64 {
 	}
 	applyTenantHeaders(req, cfg)
 	applyAuthHeaders(req, cfg)
+	applyCustomHeaders(req, cfg)
 
 	client := s.withInsecure(cfg.SkipTLSVerify, parsed.String())
 	resp, err := client.Do(req)
@@ -2056,6 +2713,7 @@ func (s *Server) maxLabelsPerTimeseries(ctx context.Context, cfg uploadConfig) i
 	}
 	applyTenantHeaders(req, cfg)
 	applyAuthHeaders(req, cfg)
+	applyCustomHeaders(req, cfg)
 
 	client := s.withInsecure(cfg.SkipTLSVerify, parsed.String())
 	resp, err := client.Do(req)
@@ -2235,6 +2893,16 @@ func applyTenantHeaders(req *http.Request, cfg uploadConfig) {
 	}
 }
 
+// applyCustomHeaders adds the configured passthrough headers without replacing headers already set on req
+func applyCustomHeaders(req *http.Request, cfg uploadConfig) {
+	for name, value := range cfg.Headers {
+		if req.Header.Get(name) != "" {
+			continue
+		}
+		req.Header.Set(name, value)
+	}
+}
+
 func applyAuthHeaders(req *http.Request, cfg uploadConfig) {
 	switch strings.ToLower(cfg.AuthType) {
 	case "bearer":
@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
@@ -12,9 +13,15 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"testing/iotest"
 	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
 )
 
 func recentTimestampMs() int64 {
@@ -540,13 +547,10 @@ func TestPrepareZipBundleRejectsNonMetricsJsonl(t *testing.T) {
 	}
 }
 
+// testTempDir returns a per-test directory that is removed when the test ends
 func testTempDir(t *testing.T) string {
 	t.Helper()
-	base := filepath.Join(".", "tmp", "tests")
-	if err := os.MkdirAll(base, 0o755); err != nil {
-		t.Fatalf("failed to create base temp dir: %v", err)
-	}
-	return base
+	return t.TempDir()
 }
 
 func ensureTestFile(t *testing.T, name string, write func(io.Writer) error) string {
@@ -985,7 +989,7 @@ func TestAnalyzeBundleRetentionAndWarnings(t *testing.T) {
 
 	srv := NewServer("test")
 	bundle := &bundleInfo{MetricsPath: tmpPath, OriginalBytes: int64(len(payload)), ExtractedBytes: int64(len(payload))}
-	summary, err := srv.analyzeBundle(context.Background(), bundle, 5000, 0, 0, nil, 0)
+	summary, err := srv.analyzeBundle(context.Background(), bundle, 5000, 0, 0, nil, "", labelLengthLimits{}, 0)
 	if err != nil {
 		t.Fatalf("analyze failed: %v", err)
 	}
@@ -1007,7 +1011,7 @@ func TestAnalyzeBundleWarnsOnTargetLabelLimit(t *testing.T) {
 
 	srv := NewServer("test")
 	bundle := &bundleInfo{MetricsPath: tmpPath, OriginalBytes: int64(len(payload)), ExtractedBytes: int64(len(payload))}
-	summary, err := srv.analyzeBundle(context.Background(), bundle, 0, 0, 2, nil, 0)
+	summary, err := srv.analyzeBundle(context.Background(), bundle, 0, 0, 2, nil, "", labelLengthLimits{}, 0)
 	if err != nil {
 		t.Fatalf("analyze failed: %v", err)
 	}
@@ -1036,7 +1040,7 @@ func TestAnalyzeBundleDropLabelsCanReduceLimitRisk(t *testing.T) {
 
 	srv := NewServer("test")
 	bundle := &bundleInfo{MetricsPath: tmpPath, OriginalBytes: int64(len(payload)), ExtractedBytes: int64(len(payload))}
-	summary, err := srv.analyzeBundle(context.Background(), bundle, 0, 0, 4, []string{"cluster", "pod"}, 0)
+	summary, err := srv.analyzeBundle(context.Background(), bundle, 0, 0, 4, []string{"cluster", "pod"}, "", labelLengthLimits{}, 0)
 	if err != nil {
 		t.Fatalf("analyze failed: %v", err)
 	}
@@ -1143,7 +1147,7 @@ func TestAnalyzeBundleReportsAllDetectedLabels(t *testing.T) {
 	}
 	lineBytes, err := json.Marshal(metricLine{
 		Metric:     metric,
-		Values:     []json.RawMessage{json.RawMessage("1")},
+		Values:     vm.SampleValues{1},
 		Timestamps: []int64{recentTimestampMs()},
 	})
 	if err != nil {
@@ -1155,7 +1159,7 @@ func TestAnalyzeBundleReportsAllDetectedLabels(t *testing.T) {
 	})
 	srv := NewServer("test")
 	bundle := &bundleInfo{MetricsPath: tmpPath, OriginalBytes: int64(len(lineBytes)), ExtractedBytes: int64(len(lineBytes))}
-	summary, err := srv.analyzeBundle(context.Background(), bundle, 0, 0, 0, nil, 0)
+	summary, err := srv.analyzeBundle(context.Background(), bundle, 0, 0, 0, nil, "", labelLengthLimits{}, 0)
 	if err != nil {
 		t.Fatalf("analyze failed: %v", err)
 	}
@@ -1200,7 +1204,7 @@ func TestAnalyzeBundleSampleLimitAndFullCollection(t *testing.T) {
 		ExtractedBytes: 1,
 	}
 
-	sampleSummary, err := srv.analyzeBundle(context.Background(), bundle, 0, 0, 0, nil, defaultAnalyzeSampleLines)
+	sampleSummary, err := srv.analyzeBundle(context.Background(), bundle, 0, 0, 0, nil, "", labelLengthLimits{}, defaultAnalyzeSampleLines)
 	if err != nil {
 		t.Fatalf("sample analyze failed: %v", err)
 	}
@@ -1211,7 +1215,7 @@ func TestAnalyzeBundleSampleLimitAndFullCollection(t *testing.T) {
 		t.Fatalf("expected sample_cut=true for sample-limited analysis")
 	}
 
-	fullSummary, err := srv.analyzeBundle(context.Background(), bundle, 0, 0, 0, nil, 0)
+	fullSummary, err := srv.analyzeBundle(context.Background(), bundle, 0, 0, 0, nil, "", labelLengthLimits{}, 0)
 	if err != nil {
 		t.Fatalf("full analyze failed: %v", err)
 	}
@@ -1395,7 +1399,7 @@ func TestHandleAnalyzeWarnsWhenMaxLabelsUnknownAndHighSeen(t *testing.T) {
 	}
 	line, _ := json.Marshal(metricLine{
 		Metric:     metric,
-		Values:     []json.RawMessage{json.RawMessage("1")},
+		Values:     vm.SampleValues{1},
 		Timestamps: []int64{recentTimestampMs()},
 	})
 	_, _ = io.WriteString(fw, string(line))
@@ -1595,7 +1599,7 @@ func TestHandleAnalyzeUsesManualMaxLabelsOverride(t *testing.T) {
 	}
 	line, _ := json.Marshal(metricLine{
 		Metric:     metric,
-		Values:     []json.RawMessage{json.RawMessage("1")},
+		Values:     vm.SampleValues{1},
 		Timestamps: []int64{recentTimestampMs()},
 	})
 	_, _ = io.WriteString(fw, string(line))
@@ -1823,7 +1827,7 @@ func TestAnalyzeBundleSuggestedShiftAndWarnings(t *testing.T) {
 	srv := NewServer("test")
 	bundle := &bundleInfo{MetricsPath: tmpPath, OriginalBytes: int64(len(payload)), ExtractedBytes: int64(len(payload))}
 	retentionCutoff := now - int64(1*time.Hour/time.Millisecond)
-	summary, err := srv.analyzeBundle(context.Background(), bundle, retentionCutoff, 0, 0, nil, 0)
+	summary, err := srv.analyzeBundle(context.Background(), bundle, retentionCutoff, 0, 0, nil, "", labelLengthLimits{}, 0)
 	if err != nil {
 		t.Fatalf("analyzeBundle failed: %v", err)
 	}
@@ -1850,7 +1854,7 @@ func TestAnalyzeBundleSkipsInvalidTimestamps(t *testing.T) {
 	})
 	srv := NewServer("test")
 	bundle := &bundleInfo{MetricsPath: tmpPath, OriginalBytes: int64(len(payload)), ExtractedBytes: int64(len(payload))}
-	summary, err := srv.analyzeBundle(context.Background(), bundle, 0, 0, 0, nil, 0)
+	summary, err := srv.analyzeBundle(context.Background(), bundle, 0, 0, 0, nil, "", labelLengthLimits{}, 0)
 	if err != nil {
 		t.Fatalf("analyzeBundle failed: %v", err)
 	}
@@ -1900,3 +1904,1115 @@ func TestNormalizeTimestampsAutoScale(t *testing.T) {
 		t.Fatalf("expected micros -> ms scaling")
 	}
 }
+
+func TestPrepareZipBundleMetadataSchemaVersions(t *testing.T) {
+	tests := []struct {
+		name        string
+		metadata    string
+		wantVersion int
+		wantErr     string
+	}{
+		{
+			name:        "current version",
+			metadata:    fmt.Sprintf(`{"schema_version":%d,"metrics_count":1}`, supportedMetadataSchemaVersion),
+			wantVersion: supportedMetadataSchemaVersion,
+		},
+		{
+			name:        "pre-versioned bundle",
+			metadata:    `{"metrics_count":1}`,
+			wantVersion: supportedMetadataSchemaVersion,
+		},
+		{
+			name:     "unsupported future version",
+			metadata: fmt.Sprintf(`{"schema_version":%d,"metrics_count":1}`, supportedMetadataSchemaVersion+1),
+			wantErr:  "upgrade vmimporter",
+		},
+		{
+			name:     "negative version",
+			metadata: `{"schema_version":-1}`,
+			wantErr:  "invalid schema_version",
+		},
+		{
+			name:        "v1 bundle",
+			metadata:    `{"schema_version":1,"metrics_count":1}`,
+			wantVersion: supportedMetadataSchemaVersion,
+		},
+		{
+			name:     "unknown counter encoding",
+			metadata: fmt.Sprintf(`{"schema_version":%d,"counter_encoding":"gorilla"}`, supportedMetadataSchemaVersion),
+			wantErr:  "unsupported counter_encoding",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var zipBuffer bytes.Buffer
+			zw := zip.NewWriter(&zipBuffer)
+			mw, _ := zw.Create("metrics.jsonl")
+			fmt.Fprintf(mw, `{"metric":{"__name__":"demo","job":"schema"},"values":[1],"timestamps":[%d]}`+"\n", recentTimestampMs())
+			meta, _ := zw.Create("metadata.json")
+			_, _ = meta.Write([]byte(tt.metadata))
+			_ = zw.Close()
+
+			tmpPath := ensureTestFile(t, "bundle-schema.zip", func(w io.Writer) error {
+				_, err := w.Write(zipBuffer.Bytes())
+				return err
+			})
+
+			bundle, err := prepareZipBundle(tmpPath, int64(zipBuffer.Len()))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer bundle.Cleanup()
+			if bundle.Metadata == nil || bundle.Metadata.SchemaVersion != tt.wantVersion {
+				t.Fatalf("unexpected metadata: %+v", bundle.Metadata)
+			}
+		})
+	}
+}
+
+func TestAnalyzeBundleNamelessSeriesPolicies(t *testing.T) {
+	ts := recentTimestampMs()
+	tmpPath := ensureTestFile(t, "bundle-nameless.jsonl", func(w io.Writer) error {
+		_, err := fmt.Fprintf(w, "{\"metric\":{\"__name__\":\"up\",\"job\":\"a\"},\"values\":[1],\"timestamps\":[%d]}\n{\"metric\":{\"job\":\"a\",\"instance\":\"b\"},\"values\":[1],\"timestamps\":[%d]}\n", ts, ts)
+		return err
+	})
+	srv := NewServer("test")
+	bundle := &bundleInfo{MetricsPath: tmpPath}
+
+	tests := []struct {
+		policy       string
+		analyzed     int
+		dropped      int
+		wantExamples string
+	}{
+		{policy: "", analyzed: 2},
+		{policy: "keep", analyzed: 2},
+		{policy: "drop", analyzed: 1, dropped: 1},
+		{policy: "synthesize", analyzed: 2, wantExamples: "unnamed_instance_job"},
+	}
+	for _, tt := range tests {
+		summary, err := srv.analyzeBundle(context.Background(), bundle, 0, 0, 0, nil, tt.policy, labelLengthLimits{}, 0)
+		if err != nil {
+			t.Fatalf("policy %q: analyze failed: %v", tt.policy, err)
+		}
+		if summary.NamelessSeries != 1 {
+			t.Fatalf("policy %q: expected 1 nameless series, got %d", tt.policy, summary.NamelessSeries)
+		}
+		if summary.AnalyzedLines != tt.analyzed || summary.DroppedNameless != tt.dropped {
+			t.Fatalf("policy %q: analyzed=%d dropped=%d", tt.policy, summary.AnalyzedLines, summary.DroppedNameless)
+		}
+		if tt.wantExamples != "" {
+			found := false
+			for _, example := range summary.Examples {
+				if example["__name__"] == tt.wantExamples {
+					found = true
+				}
+			}
+			if !found {
+				t.Fatalf("policy %q: expected example named %s, got %v", tt.policy, tt.wantExamples, summary.Examples)
+			}
+		}
+		if err := normalizeUploadConfig(&uploadConfig{NamelessSeries: tt.policy}); err != nil {
+			t.Fatalf("policy %q: expected the upload config to be accepted, got %v", tt.policy, err)
+		}
+	}
+	if err := normalizeUploadConfig(&uploadConfig{NamelessSeries: "synthesise"}); err == nil || !strings.Contains(err.Error(), "nameless_series") {
+		t.Fatalf("expected an unknown nameless_series policy to be rejected, got %v", err)
+	}
+}
+
+func TestSetDialSettingsFailsFastOnUnreachableEndpoint(t *testing.T) {
+	srv := newServer("test", filepath.Join(t.TempDir(), "profiles.json"))
+	srv.SetDialSettings(time.Second, 10*time.Second)
+	if srv.dialer.Timeout != time.Second || srv.dialer.KeepAlive != 10*time.Second {
+		t.Fatalf("unexpected dialer settings: timeout=%v keepalive=%v", srv.dialer.Timeout, srv.dialer.KeepAlive)
+	}
+
+	for _, insecure := range []bool{false, true} {
+		client := srv.withInsecure(insecure, "http://10.255.255.1:8428")
+		start := time.Now()
+		resp, err := client.Get("http://10.255.255.1:8428/health")
+		if err == nil {
+			_ = resp.Body.Close()
+			t.Fatal("expected request to unreachable endpoint to fail")
+		}
+		if elapsed := time.Since(start); elapsed > 3*time.Second {
+			t.Fatalf("insecure=%v: dial took %v, expected to fail within the 1s dial timeout", insecure, elapsed)
+		}
+	}
+}
+
+func TestHandleUploadVerificationTimeoutCompletesJob(t *testing.T) {
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/api/v1/import"):
+			w.WriteHeader(http.StatusNoContent)
+		case strings.HasSuffix(r.URL.Path, "/api/v1/series"):
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+		case strings.HasSuffix(r.URL.Path, "/api/v1/status/tsdb"):
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"status":"success","data":{"retentionTime":"1y"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer downstream.Close()
+
+	srvImpl := NewServer("test")
+	srvImpl.SetVerifyTimeout(200 * time.Millisecond)
+	srv := httptest.NewServer(srvImpl.Router())
+	defer srv.Close()
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	configBytes, _ := json.Marshal(uploadConfig{Endpoint: downstream.URL})
+	_ = writer.WriteField("config", string(configBytes))
+	fileWriter, _ := writer.CreateFormFile("bundle", "test.jsonl")
+	fmt.Fprintf(fileWriter, `{"metric":{"__name__":"test_metric","job":"demo"},"values":[1],"timestamps":[%d]}`, recentTimestampMs())
+	writer.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/api/upload", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var created struct {
+		JobID string `json:"job_id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		t.Fatalf("failed to decode body: %v", err)
+	}
+
+	job := waitForJobCompletion(t, srvImpl, created.JobID, 3*time.Second)
+	if job.State != jobStateCompleted {
+		t.Fatalf("job did not complete: %+v", job)
+	}
+	if job.Verification == nil || !job.Verification.Skipped || job.Verification.Verified {
+		t.Fatalf("expected verification skipped, got %+v", job.Verification)
+	}
+	if !strings.Contains(job.Verification.Message, "timeout") {
+		t.Fatalf("expected timeout note in verification message, got %q", job.Verification.Message)
+	}
+}
+
+func TestPostImportChunkSendsPassthroughHeaders(t *testing.T) {
+	var seen http.Header
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = r.Header.Clone()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer remote.Close()
+
+	srv := NewServer("test")
+	cfg := uploadConfig{
+		Endpoint: remote.URL,
+		TenantID: "42",
+		AuthType: "bearer",
+		Password: "real-token",
+		Headers: map[string]string{
+			"X-Trace-Id":    "trace-1",
+			"X-Vm-TenantID": "7",
+			"Authorization": "Bearer spoofed",
+		},
+	}
+	if _, _, err := srv.postImportChunk(context.Background(), cfg, remote.URL+"/api/v1/import", []byte("{}\n")); err != nil {
+		t.Fatalf("postImportChunk failed: %v", err)
+	}
+	if seen.Get("X-Trace-Id") != "trace-1" {
+		t.Fatalf("expected passthrough header on import request, got %v", seen)
+	}
+	if seen.Get("X-Vm-TenantID") != "42" || seen.Get("Authorization") != "Bearer real-token" {
+		t.Fatalf("passthrough headers must not override tenant/auth headers, got %v", seen)
+	}
+}
+
+func TestStreamImportStalenessMarkers(t *testing.T) {
+	line := `{"metric":{"__name__":"demo"},"values":[1,null,3],"timestamps":[1000,2000,3000]}` + "\n"
+	tmpPath := ensureTestFile(t, "bundle-stale.jsonl", func(w io.Writer) error {
+		_, err := io.WriteString(w, line)
+		return err
+	})
+	bundle := &bundleInfo{MetricsPath: tmpPath, OriginalBytes: int64(len(line)), ExtractedBytes: int64(len(line))}
+
+	tests := []struct {
+		policy      string
+		wantBody    string
+		wantDropped int
+	}{
+		{policy: "preserve", wantBody: `"values":[1,null,3],"timestamps":[1000,2000,3000]`},
+		{policy: "strip", wantBody: `"values":[1,3],"timestamps":[1000,3000]`, wantDropped: 1},
+	}
+	for _, tt := range tests {
+		var body string
+		downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			data, _ := io.ReadAll(r.Body)
+			body += string(data)
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		srv := NewServer("test")
+		_, summary, err := srv.streamImport(context.Background(), uploadConfig{StalenessMarkers: tt.policy}, bundle, downstream.URL+"/api/v1/import", 0, 0, 0, 0, nil)
+		downstream.Close()
+		if err != nil {
+			t.Fatalf("%s: streamImport failed: %v", tt.policy, err)
+		}
+		if summary.SkippedLines != 0 || summary.StaleMarkers != 1 || summary.DroppedStale != tt.wantDropped {
+			t.Fatalf("%s: unexpected summary %+v", tt.policy, summary)
+		}
+		if !strings.Contains(body, tt.wantBody) {
+			t.Fatalf("%s: expected import body to contain %s, got %s", tt.policy, tt.wantBody, body)
+		}
+	}
+}
+
+func TestUploadRejectsBundleOverMaxSize(t *testing.T) {
+	var imports int32
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&imports, 1)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer downstream.Close()
+
+	srvImpl := newServer("test", filepath.Join(t.TempDir(), "profiles.json"))
+	srvImpl.SetMaxUploadSize(1 << 10)
+	srv := httptest.NewServer(srvImpl.Router())
+	defer srv.Close()
+
+	line := fmt.Sprintf(`{"metric":{"__name__":"test_metric","job":"demo"},"values":[1],"timestamps":[%d]}`+"\n", recentTimestampMs())
+	for _, path := range []string{"/api/upload", "/api/analyze"} {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		fileWriter, _ := writer.CreateFormFile("bundle", "big.jsonl")
+		for written := 0; written <= 4<<10; written += len(line) {
+			_, _ = io.WriteString(fileWriter, line)
+		}
+		configBytes, _ := json.Marshal(uploadConfig{Endpoint: downstream.URL})
+		_ = writer.WriteField("config", string(configBytes))
+		_ = writer.Close()
+
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+path, body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s: request failed: %v", path, err)
+		}
+		var payload map[string]string
+		decodeErr := json.NewDecoder(resp.Body).Decode(&payload)
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusRequestEntityTooLarge {
+			t.Fatalf("%s: expected 413, got %d", path, resp.StatusCode)
+		}
+		if decodeErr != nil || payload["error"] != "bundle exceeds max size of 1 KiB" {
+			t.Fatalf("%s: expected JSON size error, got %v (decode error %v)", path, payload, decodeErr)
+		}
+	}
+	if n := atomic.LoadInt32(&imports); n != 0 {
+		t.Fatalf("expected no requests to VictoriaMetrics, got %d", n)
+	}
+}
+
+func TestUploadFormLimitsFieldsAndRejectsBrokenBodies(t *testing.T) {
+	srv := NewServer("test")
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	field := strings.Repeat("x", maxUploadFieldBytes/2)
+	for i := 0; i < 3; i++ {
+		_ = writer.WriteField(fmt.Sprintf("note%d", i), field)
+	}
+	_ = writer.Close()
+	req := httptest.NewRequest(http.MethodPost, "/api/upload", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+	srv.Router().ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "form fields exceed") {
+		t.Fatalf("expected 400 for oversized form fields, got %d: %s", w.Code, w.Body.String())
+	}
+
+	body = &bytes.Buffer{}
+	writer = multipart.NewWriter(body)
+	fileWriter, _ := writer.CreateFormFile("bundle", "metrics.jsonl")
+	_, _ = io.WriteString(fileWriter, `{"metric":{"__name__":"up"},"values":[1],"timestamps":[1]}`+"\n")
+	broken := io.MultiReader(bytes.NewReader(body.Bytes()), iotest.ErrReader(errors.New("connection reset by peer")))
+	req = httptest.NewRequest(http.MethodPost, "/api/upload", broken)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w = httptest.NewRecorder()
+	srv.Router().ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "connection reset by peer") {
+		t.Fatalf("expected 400 when the client body fails, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestPingEndpointDetectsNonImportEndpoints(t *testing.T) {
+	mux := http.NewServeMux()
+	// A web UI answering every path with its index page
+	mux.HandleFunc("/ui/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+	})
+	// An auth proxy redirecting unauthenticated requests to its login page
+	mux.HandleFunc("/sso/", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/login", http.StatusFound)
+	})
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	// A gateway that only routes POST to the import handler
+	mux.HandleFunc("/gateway/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", "OPTIONS, POST")
+		if r.Method != http.MethodOptions {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/vm/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	downstream := httptest.NewServer(mux)
+	defer downstream.Close()
+
+	srv := newServer("test", filepath.Join(t.TempDir(), "profiles.json"))
+	tests := []struct {
+		path    string
+		wantErr string
+	}{
+		{path: "/ui", wantErr: "HTML page"},
+		{path: "/sso", wantErr: "not a metrics import endpoint"},
+		{path: "/gateway"},
+		{path: "/vm"},
+	}
+	for _, tt := range tests {
+		err := srv.pingEndpoint(context.Background(), uploadConfig{Endpoint: downstream.URL + tt.path})
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Fatalf("%s: expected import endpoint to be accepted, got %v", tt.path, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Fatalf("%s: expected error containing %q, got %v", tt.path, tt.wantErr, err)
+		}
+	}
+}
+
+func TestHandleUploadMetricNamePrefix(t *testing.T) {
+	var imported atomic.Value
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/api/v1/import"):
+			body, _ := io.ReadAll(r.Body)
+			imported.Store(string(body))
+			w.WriteHeader(http.StatusNoContent)
+		case strings.HasSuffix(r.URL.Path, "/api/v1/series"):
+			w.Header().Set("Content-Type", "application/json")
+			if !strings.Contains(r.URL.Query().Get("match[]"), `__name__="cust1_test_metric"`) {
+				_, _ = w.Write([]byte(`{"status":"success","data":[]}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"status":"success","data":[{"__name__":"cust1_test_metric","job":"demo"}]}`))
+		case strings.HasSuffix(r.URL.Path, "/api/v1/status/tsdb"):
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"status":"success","data":{"retentionTime":"1y"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer downstream.Close()
+
+	srvImpl := NewServer("test")
+	srv := httptest.NewServer(srvImpl.Router())
+	defer srv.Close()
+
+	upload := func(prefix string) *http.Response {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		configBytes, _ := json.Marshal(uploadConfig{Endpoint: downstream.URL, MetricNamePrefix: prefix})
+		_ = writer.WriteField("config", string(configBytes))
+		fileWriter, _ := writer.CreateFormFile("bundle", "test.jsonl")
+		fmt.Fprintf(fileWriter, `{"metric":{"__name__":"test_metric","job":"demo"},"values":[1],"timestamps":[%d]}`, recentTimestampMs())
+		writer.Close()
+		resp, err := http.Post(srv.URL+"/api/upload", writer.FormDataContentType(), body)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		return resp
+	}
+
+	if resp := upload("9bad"); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid prefix, got %d", resp.StatusCode)
+	}
+
+	resp := upload("cust1_")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var created struct {
+		JobID string `json:"job_id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		t.Fatalf("failed to decode body: %v", err)
+	}
+	job := waitForJobCompletion(t, srvImpl, created.JobID, 5*time.Second)
+	if job.State != jobStateCompleted {
+		t.Fatalf("job did not complete: %+v", job)
+	}
+	body, _ := imported.Load().(string)
+	if !strings.Contains(body, `"__name__":"cust1_test_metric"`) {
+		t.Fatalf("expected prefixed metric name in import body, got %s", body)
+	}
+	if job.Summary == nil || job.Summary.MetricName != "cust1_test_metric" {
+		t.Fatalf("unexpected summary %+v", job.Summary)
+	}
+	if job.Verification == nil || !job.Verification.Verified {
+		t.Fatalf("expected verification to find the prefixed series, got %+v", job.Verification)
+	}
+}
+
+func TestGuardFutureSamples(t *testing.T) {
+	limit := futureSkewLimitMs(60, time.UnixMilli(10_000))
+	if limit != 70_000 {
+		t.Fatalf("unexpected limit %d", limit)
+	}
+	if futureSkewLimitMs(0, time.Now()) != 0 {
+		t.Fatal("expected the guard to be disabled without max_future_skew_seconds")
+	}
+
+	ts, vals, guarded := guardFutureSamples([]int64{5000, 80000, 90000}, []float64{1, 2, 3}, limit, false)
+	if guarded != 2 || len(ts) != 1 || ts[0] != 5000 || vals[0] != 1 {
+		t.Fatalf("drop: got ts=%v vals=%v guarded=%d", ts, vals, guarded)
+	}
+
+	ts, vals, guarded = guardFutureSamples([]int64{5000, 80000, 90000}, []float64{1, 2, 3}, limit, true)
+	if guarded != 2 || len(ts) != 2 || ts[1] != limit || vals[1] != 3 {
+		t.Fatalf("clamp: got ts=%v vals=%v guarded=%d", ts, vals, guarded)
+	}
+}
+
+func TestStreamImportFutureSamples(t *testing.T) {
+	var imported atomic.Value
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		imported.Store(string(body))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer downstream.Close()
+
+	now := time.Now()
+	past := now.Add(-time.Minute).UnixMilli()
+	future := now.Add(time.Hour).UnixMilli()
+	tmpPath := ensureTestFile(t, "bundle-future.jsonl", func(w io.Writer) error {
+		_, err := fmt.Fprintf(w, `{"metric":{"__name__":"skewed","job":"demo"},"values":[1,2],"timestamps":[%d,%d]}`+"\n", past, future)
+		return err
+	})
+
+	for _, policy := range []string{futureSamplesDrop, futureSamplesClamp} {
+		srv := NewServer("test")
+		cfg := uploadConfig{MaxFutureSkewSecs: 60, FutureSamples: policy}
+		bundle := &bundleInfo{MetricsPath: tmpPath}
+		_, summary, err := srv.streamImport(context.Background(), cfg, bundle, downstream.URL+"/api/v1/import", 0, 0, 0, 0, nil)
+		if err != nil {
+			t.Fatalf("%s: streamImport failed: %v", policy, err)
+		}
+		if summary.FutureSamples != 1 {
+			t.Fatalf("%s: expected 1 future sample, got %d", policy, summary.FutureSamples)
+		}
+		body, _ := imported.Load().(string)
+		if strings.Contains(body, fmt.Sprint(future)) {
+			t.Fatalf("%s: future timestamp reached the target: %s", policy, body)
+		}
+		if policy == futureSamplesClamp && summary.Points != 2 {
+			t.Fatalf("clamp: expected the future sample kept at the limit, got %d points", summary.Points)
+		}
+		if policy == futureSamplesDrop && summary.Points != 1 {
+			t.Fatalf("drop: expected 1 point, got %d", summary.Points)
+		}
+	}
+}
+
+func TestHandleImportFromURL(t *testing.T) {
+	var imported atomic.Value
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/api/v1/import"):
+			body, _ := io.ReadAll(r.Body)
+			imported.Store(string(body))
+			w.WriteHeader(http.StatusNoContent)
+		case strings.HasSuffix(r.URL.Path, "/api/v1/series"):
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"status":"success","data":[{"__name__":"hosted_metric"}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer downstream.Close()
+
+	bundle := fmt.Sprintf(`{"metric":{"__name__":"hosted_metric","job":"demo"},"values":[1],"timestamps":[%d]}`+"\n", recentTimestampMs())
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer bucket-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, _ = io.WriteString(w, bundle)
+	}))
+	defer source.Close()
+
+	srvImpl := NewServer("test")
+	srv := httptest.NewServer(srvImpl.Router())
+	defer srv.Close()
+
+	post := func(sourceURL string) *http.Response {
+		payload, _ := json.Marshal(importFromURLRequest{
+			URL:           sourceURL,
+			Authorization: "Bearer bucket-token",
+			Config:        uploadConfig{Endpoint: downstream.URL},
+		})
+		resp, err := http.Post(srv.URL+"/api/import-from-url", "application/json", bytes.NewReader(payload))
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		return resp
+	}
+
+	if resp := post(source.URL + "/bundles/test.jsonl"); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 while the endpoint is disabled, got %d", resp.StatusCode)
+	}
+	srvImpl.SetImportURLAllowlist([]string{strings.TrimPrefix(source.URL, "http://")})
+	if resp := post("http://not-allowed.example.com/test.jsonl"); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 for a host outside the allowlist, got %d", resp.StatusCode)
+	}
+
+	resp := post(source.URL + "/bundles/test.jsonl")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var created struct {
+		JobID string `json:"job_id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		t.Fatalf("failed to decode body: %v", err)
+	}
+	job := waitForJobCompletion(t, srvImpl, created.JobID, 5*time.Second)
+	if job.State != jobStateCompleted {
+		t.Fatalf("job did not complete: %+v", job)
+	}
+	if body, _ := imported.Load().(string); !strings.Contains(body, "hosted_metric") {
+		t.Fatalf("expected hosted bundle to be imported, got %q", body)
+	}
+	if job.SourceBytes != int64(len(bundle)) {
+		t.Fatalf("expected source bytes %d, got %d", len(bundle), job.SourceBytes)
+	}
+
+	srvImpl.SetMaxUploadSize(8)
+	resp = post(source.URL + "/bundles/test.jsonl")
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		t.Fatalf("failed to decode body: %v", err)
+	}
+	job = waitForJobCompletion(t, srvImpl, created.JobID, 5*time.Second)
+	if job.State != jobStateFailed || !strings.Contains(job.Error, "bundle exceeds max size") {
+		t.Fatalf("expected size limit failure, got %+v", job)
+	}
+}
+
+func TestStreamImportNeverSplitsLines(t *testing.T) {
+	var mu sync.Mutex
+	var chunks []string
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		chunks = append(chunks, string(body))
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer downstream.Close()
+
+	// The middle series alone is larger than the default chunk size
+	base := recentTimestampMs()
+	points := 40000
+	var values, timestamps strings.Builder
+	for i := 0; i < points; i++ {
+		if i > 0 {
+			values.WriteByte(',')
+			timestamps.WriteByte(',')
+		}
+		values.WriteString("1")
+		timestamps.WriteString(strconv.FormatInt(base+int64(i), 10))
+	}
+	tmpPath := filepath.Join(t.TempDir(), "bundle-long-line.jsonl")
+	content := fmt.Sprintf(`{"metric":{"__name__":"small","job":"a"},"values":[1],"timestamps":[%d]}`+"\n"+
+		`{"metric":{"__name__":"huge","job":"b"},"values":[%s],"timestamps":[%s]}`+"\n"+
+		`{"metric":{"__name__":"small","job":"c"},"values":[1],"timestamps":[%d]}`+"\n",
+		base, values.String(), timestamps.String(), base)
+	if err := os.WriteFile(tmpPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write bundle: %v", err)
+	}
+
+	srv := NewServer("test")
+	bundle := &bundleInfo{MetricsPath: tmpPath}
+	_, summary, err := srv.streamImport(context.Background(), uploadConfig{}, bundle, downstream.URL+"/api/v1/import", 0, 0, 0, 0, nil)
+	if err != nil {
+		t.Fatalf("streamImport failed: %v", err)
+	}
+	if summary.Chunks != 3 || summary.Points != points+2 {
+		t.Fatalf("expected the long series in its own chunk, got %d chunks and %d points", summary.Chunks, summary.Points)
+	}
+	mu.Lock()
+	for i, chunk := range chunks {
+		if !strings.HasSuffix(chunk, "\n") {
+			t.Fatalf("chunk %d does not end on a line boundary", i)
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(chunk, "\n"), "\n") {
+			if !json.Valid([]byte(line)) {
+				t.Fatalf("chunk %d contains a partial line", i)
+			}
+		}
+	}
+	mu.Unlock()
+
+	prev := maxImportLineBytes
+	maxImportLineBytes = 256 * 1024
+	defer func() { maxImportLineBytes = prev }()
+	_, _, err = srv.streamImport(context.Background(), uploadConfig{}, bundle, downstream.URL+"/api/v1/import", 0, 0, 0, 0, nil)
+	if err == nil || !strings.Contains(err.Error(), "line 2 is longer than the 256 KiB limit") {
+		t.Fatalf("expected a clear error for a line above the hard limit, got %v", err)
+	}
+}
+
+func TestStreamImportPartialSuccess(t *testing.T) {
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+		_, _ = io.WriteString(w, `{"warnings":["label value too long"],"rows_rejected":2}`)
+	}))
+	defer downstream.Close()
+
+	tmpPath := ensureTestFile(t, "bundle-partial.jsonl", func(w io.Writer) error {
+		_, err := io.WriteString(w, `{"metric":{"__name__":"up","job":"demo"},"values":[1,2],"timestamps":[1000,2000]}`+"\n")
+		return err
+	})
+	bundle := &bundleInfo{MetricsPath: tmpPath}
+
+	srv := NewServer("test")
+	_, summary, err := srv.streamImport(context.Background(), uploadConfig{}, bundle, downstream.URL+"/api/v1/import", 0, 0, 0, 0, nil)
+	if err != nil {
+		t.Fatalf("streamImport failed: %v", err)
+	}
+	if summary.RejectedRows != 2 || summary.PartialChunks != 1 {
+		t.Fatalf("expected 2 rejected rows in 1 partial chunk, got %d in %d", summary.RejectedRows, summary.PartialChunks)
+	}
+	if len(summary.RemoteWarnings) != 1 || summary.RemoteWarnings[0] != "label value too long" {
+		t.Fatalf("unexpected remote warnings: %v", summary.RemoteWarnings)
+	}
+	if msg := summary.completionMessage(); !strings.Contains(msg, "2 rows rejected") {
+		t.Fatalf("expected completion message to report rejected rows, got %q", msg)
+	}
+
+	_, summary, err = srv.streamImport(context.Background(), uploadConfig{PartialSuccess: partialSuccessFail}, bundle, downstream.URL+"/api/v1/import", 0, 0, 0, 0, nil)
+	if err == nil || !strings.Contains(err.Error(), "label value too long") {
+		t.Fatalf("expected partial_success=fail to stop the import, got %v", err)
+	}
+	if summary.ProcessedBytes != 0 {
+		t.Fatalf("expected the partial chunk to stay uncommitted, got offset %d", summary.ProcessedBytes)
+	}
+}
+
+func TestParseRemoteFeedback(t *testing.T) {
+	if parseRemoteFeedback("").partial() {
+		t.Fatal("expected an empty body to be a clean import")
+	}
+	text := parseRemoteFeedback("ok\nwarn: 3 rows were skipped because of invalid labels")
+	if text.Rejected != 3 || len(text.Warnings) != 1 {
+		t.Fatalf("unexpected text feedback: %+v", text)
+	}
+	if parseRemoteFeedback(`{"status":"success"}`).partial() {
+		t.Fatal("expected a plain success JSON to be a clean import")
+	}
+	if err := normalizeUploadConfig(&uploadConfig{PartialSuccess: "ignore"}); err == nil {
+		t.Fatal("expected unknown partial_success value to be rejected")
+	}
+}
+
+func TestStreamImportMaxLineSize(t *testing.T) {
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer downstream.Close()
+
+	tmpPath := ensureTestFile(t, "bundle-max-line.jsonl", func(w io.Writer) error {
+		values := strings.TrimSuffix(strings.Repeat("1,", 8_000), ",")
+		timestamps := make([]string, 8_000)
+		for i := range timestamps {
+			timestamps[i] = fmt.Sprint(1_700_000_000_000 + i)
+		}
+		_, err := fmt.Fprintf(w, `{"metric":{"__name__":"long","job":"demo"},"values":[%s],"timestamps":[%s]}`+"\n", values, strings.Join(timestamps, ","))
+		return err
+	})
+	bundle := &bundleInfo{MetricsPath: tmpPath}
+
+	srv := NewServer("test")
+	srv.SetMaxLineSize(64 * 1024)
+	_, _, err := srv.streamImport(context.Background(), uploadConfig{}, bundle, downstream.URL+"/api/v1/import", 0, 0, 0, 0, nil)
+	if err == nil || !strings.Contains(err.Error(), "line 1 is longer than the 64 KiB limit") || !strings.Contains(err.Error(), "-max-line-mb") {
+		t.Fatalf("expected a clear error naming the oversized line, got %v", err)
+	}
+
+	srv.SetMaxLineSize(4 << 20)
+	_, summary, err := srv.streamImport(context.Background(), uploadConfig{}, bundle, downstream.URL+"/api/v1/import", 0, 0, 0, 0, nil)
+	if err != nil || summary.Points != 8_000 {
+		t.Fatalf("expected the line to import with a raised limit, got %d points, err %v", summary.Points, err)
+	}
+}
+
+func TestStreamImportLabelConflicts(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		bodies []string
+	)
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(body))
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer downstream.Close()
+
+	tmpPath := ensureTestFile(t, "bundle-label-conflicts.jsonl", func(w io.Writer) error {
+		_, err := io.WriteString(w, `{"metric":{"__name__":"up","name":"down","job":"a","job":"b"},"values":[1],"timestamps":[1000]}`+"\n"+
+			`{"metric":{"__name__":"up","job":"c"},"values":[1],"timestamps":[1000]}`+"\n")
+		return err
+	})
+	bundle := &bundleInfo{MetricsPath: tmpPath}
+	srv := NewServer("test")
+
+	imported := func(policy string) (string, importSummary, error) {
+		t.Helper()
+		mu.Lock()
+		bodies = nil
+		mu.Unlock()
+		_, summary, err := srv.streamImport(context.Background(), uploadConfig{LabelConflicts: policy}, bundle, downstream.URL+"/api/v1/import", 0, 0, 0, 0, nil)
+		mu.Lock()
+		defer mu.Unlock()
+		return strings.Join(bodies, ""), summary, err
+	}
+
+	body, summary, err := imported("")
+	if err != nil {
+		t.Fatalf("streamImport failed: %v", err)
+	}
+	if summary.DuplicateLabels != 1 || summary.NameConflicts != 1 {
+		t.Fatalf("expected 1 repeated label series and 1 name conflict, got %d and %d", summary.DuplicateLabels, summary.NameConflicts)
+	}
+	if !strings.Contains(body, `"job":"b"`) || !strings.Contains(body, `"name":"down"`) || strings.Contains(body, `"job":"a"`) {
+		t.Fatalf("expected the last repeated value to win by default, got %s", body)
+	}
+
+	body, _, err = imported(labelConflictsFirst)
+	if err != nil {
+		t.Fatalf("streamImport failed: %v", err)
+	}
+	if !strings.Contains(body, `"job":"a"`) || strings.Contains(body, `"job":"b"`) {
+		t.Fatalf("expected the first repeated value with label_conflicts=first, got %s", body)
+	}
+
+	body, summary, err = imported(labelConflictsDrop)
+	if err != nil {
+		t.Fatalf("streamImport failed: %v", err)
+	}
+	if summary.DroppedConflict != 1 || strings.Contains(body, `"name":"down"`) || !strings.Contains(body, `"job":"c"`) {
+		t.Fatalf("expected only the conflicting series to be dropped, got %d dropped: %s", summary.DroppedConflict, body)
+	}
+
+	body, _, err = imported(labelConflictsError)
+	if err == nil || !strings.Contains(err.Error(), "line 1: repeated labels job") || !strings.Contains(err.Error(), `name="down" differs from __name__="up"`) {
+		t.Fatalf("expected label_conflicts=error to name the line and the anomalies, got %v", err)
+	}
+	if body != "" {
+		t.Fatalf("expected nothing to be imported, got %s", body)
+	}
+
+	analyzed, err := srv.analyzeBundle(context.Background(), bundle, 0, 0, 0, nil, "", labelLengthLimits{}, 0)
+	if err != nil {
+		t.Fatalf("analyzeBundle failed: %v", err)
+	}
+	warnings := strings.Join(buildAnalysisWarnings(analyzed, 0, 0), "\n")
+	if analyzed.DuplicateLabels != 1 || analyzed.NameConflicts != 1 || !strings.Contains(warnings, "label_conflicts") {
+		t.Fatalf("expected preflight to report the anomalies, got %+v: %s", analyzed, warnings)
+	}
+	if err := normalizeUploadConfig(&uploadConfig{LabelConflicts: "merge"}); err == nil {
+		t.Fatal("expected an unknown label_conflicts policy to be rejected")
+	}
+}
+
+func TestStreamImportOverLengthLabels(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		bodies []string
+	)
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(body))
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer downstream.Close()
+
+	longValue := strings.Repeat("x", 30) + "é"
+	tmpPath := ensureTestFile(t, "bundle-long-labels.jsonl", func(w io.Writer) error {
+		_, err := io.WriteString(w, `{"metric":{"__name__":"up","job":"`+longValue+`"},"values":[1],"timestamps":[1000]}`+"\n"+
+			`{"metric":{"__name__":"up","job":"short"},"values":[1],"timestamps":[1000]}`+"\n")
+		return err
+	})
+	bundle := &bundleInfo{MetricsPath: tmpPath}
+	srv := NewServer("test")
+
+	imported := func(cfg uploadConfig) (string, importSummary, error) {
+		t.Helper()
+		mu.Lock()
+		bodies = nil
+		mu.Unlock()
+		_, summary, err := srv.streamImport(context.Background(), cfg, bundle, downstream.URL+"/api/v1/import", 0, 0, 0, 0, nil)
+		mu.Lock()
+		defer mu.Unlock()
+		return strings.Join(bodies, ""), summary, err
+	}
+
+	// Defaults are the VictoriaMetrics limits, which this series stays within
+	body, summary, err := imported(uploadConfig{})
+	if err != nil || summary.LongLabels != 0 || !strings.Contains(body, longValue) {
+		t.Fatalf("expected the series to pass the default limits, got %d over-length, err %v: %s", summary.LongLabels, err, body)
+	}
+
+	// The value is 32 bytes; a 31-byte limit must not split the two-byte rune at its end
+	body, summary, err = imported(uploadConfig{MaxLabelValueBytes: 31})
+	if err != nil {
+		t.Fatalf("streamImport failed: %v", err)
+	}
+	if summary.LongLabels != 1 || summary.TruncatedLabels != 1 || !strings.Contains(body, `"job":"`+strings.Repeat("x", 30)+`"`) {
+		t.Fatalf("expected the value to be truncated at a rune boundary, got %+v: %s", summary, body)
+	}
+	if len(summary.LongLabelLines) != 1 || !strings.Contains(summary.LongLabelLines[0], `line 1: series up label "job" value of 32 bytes exceeds 31`) {
+		t.Fatalf("expected the offending series to be reported, got %v", summary.LongLabelLines)
+	}
+
+	body, summary, err = imported(uploadConfig{MaxLabelValueBytes: 31, OverLengthLabels: overLengthLabelsSkip})
+	if err != nil {
+		t.Fatalf("streamImport failed: %v", err)
+	}
+	if summary.DroppedLong != 1 || strings.Contains(body, "xxx") || !strings.Contains(body, `"job":"short"`) {
+		t.Fatalf("expected only the over-length series to be skipped, got %d dropped: %s", summary.DroppedLong, body)
+	}
+
+	body, summary, err = imported(uploadConfig{MaxLabelNameBytes: 2, MaxLabelValueBytes: -1})
+	if err != nil {
+		t.Fatalf("streamImport failed: %v", err)
+	}
+	if summary.LongLabels != 2 || !strings.Contains(body, `"jo":"short"`) || !strings.Contains(body, `"__":"up"`) {
+		t.Fatalf("expected label names to be truncated, got %+v: %s", summary, body)
+	}
+
+	analyzed, err := srv.analyzeBundle(context.Background(), bundle, 0, 0, 0, nil, "", labelLengthLimits{value: 31}, 0)
+	if err != nil {
+		t.Fatalf("analyzeBundle failed: %v", err)
+	}
+	warnings := strings.Join(buildAnalysisWarnings(analyzed, 0, 0), "\n")
+	if analyzed.LongLabels != 1 || analyzed.TruncatedLabels != 0 || !strings.Contains(warnings, "over_length_labels") {
+		t.Fatalf("expected preflight to report the series without changing it, got %+v: %s", analyzed, warnings)
+	}
+	if err := normalizeUploadConfig(&uploadConfig{OverLengthLabels: "drop"}); err == nil {
+		t.Fatal("expected an unknown over_length_labels policy to be rejected")
+	}
+}
+
+func TestHandleUploadMultiCombinesBundles(t *testing.T) {
+	var mu sync.Mutex
+	var imported []string
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/api/v1/import"):
+			body, _ := io.ReadAll(r.Body)
+			mu.Lock()
+			imported = append(imported, string(body))
+			mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+		case strings.HasSuffix(r.URL.Path, "/api/v1/series"):
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"status":"success","data":[{"__name__":"window_metric"}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer downstream.Close()
+
+	srvImpl := NewServer("test")
+	srv := httptest.NewServer(srvImpl.Router())
+	defer srv.Close()
+
+	ts := recentTimestampMs()
+	zipBuf := &bytes.Buffer{}
+	zw := zip.NewWriter(zipBuf)
+	entry, _ := zw.Create("metrics.jsonl")
+	fmt.Fprintf(entry, `{"metric":{"__name__":"window_metric","job":"a"},"values":[1,2],"timestamps":[%d,%d]}`+"\n", ts-1000, ts)
+	_ = zw.Close()
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	configBytes, _ := json.Marshal(uploadConfig{Endpoint: downstream.URL})
+	_ = writer.WriteField("config", string(configBytes))
+	_ = writer.WriteField("concurrency", "2")
+	fileWriter, _ := writer.CreateFormFile("bundle", "window-1.zip")
+	_, _ = fileWriter.Write(zipBuf.Bytes())
+	fileWriter, _ = writer.CreateFormFile("bundle", "window-2.jsonl")
+	fmt.Fprintf(fileWriter, `{"metric":{"__name__":"window_metric","job":"b"},"values":[3,4,5],"timestamps":[%d,%d,%d]}`+"\n", ts+1000, ts+2000, ts+3000)
+	writer.Close()
+
+	resp, err := http.Post(srv.URL+"/api/upload-multi", writer.FormDataContentType(), body)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var created struct {
+		JobID string `json:"job_id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		t.Fatalf("failed to decode body: %v", err)
+	}
+
+	job := waitForJobCompletion(t, srvImpl, created.JobID, 5*time.Second)
+	if job.State != jobStateCompleted {
+		t.Fatalf("job did not complete: %+v", job)
+	}
+	if len(job.Bundles) != 2 {
+		t.Fatalf("expected 2 bundle results, got %+v", job.Bundles)
+	}
+	for _, bundle := range job.Bundles {
+		if bundle.State != jobStateCompleted || bundle.Verification == nil || !bundle.Verification.Verified {
+			t.Fatalf("expected bundle %s to be imported and verified, got %+v", bundle.Name, bundle)
+		}
+	}
+	if job.Bundles[0].Name != "window-1.zip" || job.Bundles[0].Summary.Points != 2 || job.Bundles[1].Summary.Points != 3 {
+		t.Fatalf("unexpected per-bundle results: %+v", job.Bundles)
+	}
+	summary := job.Summary
+	if summary == nil || summary.Points != 5 || summary.MetricName != "window_metric" {
+		t.Fatalf("expected a combined summary of 5 points, got %+v", summary)
+	}
+	if summary.Start.UnixMilli() != ts-1000 || summary.End.UnixMilli() != ts+3000 {
+		t.Fatalf("expected the combined range to cover both bundles, got %s - %s", summary.Start, summary.End)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if all := strings.Join(imported, ""); !strings.Contains(all, `"job":"a"`) || !strings.Contains(all, `"job":"b"`) {
+		t.Fatalf("expected both bundles to be imported, got %q", all)
+	}
+}
+
+func TestHandleUploadMultiRejectsBundlesOverCombinedSize(t *testing.T) {
+	srvImpl := NewServer("test")
+	srvImpl.SetMaxUploadSize(1 << 10)
+	srvImpl.SetMaxMultiUploadSize(2 << 10)
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	configBytes, _ := json.Marshal(uploadConfig{Endpoint: "http://127.0.0.1:8428"})
+	_ = writer.WriteField("config", string(configBytes))
+	chunk := strings.Repeat("x", 900)
+	for i := 0; i < 3; i++ {
+		fileWriter, _ := writer.CreateFormFile("bundle", fmt.Sprintf("window-%d.jsonl", i))
+		_, _ = io.WriteString(fileWriter, chunk)
+	}
+	_ = writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/upload-multi", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+	srvImpl.Router().ServeHTTP(w, req)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413, got %d: %s", w.Code, w.Body.String())
+	}
+	var payload map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &payload); err != nil || payload["error"] != "bundles exceed combined max size of 2 KiB" {
+		t.Fatalf("expected a combined size error, got %s", w.Body.String())
+	}
+	if len(srvImpl.jobs) != 0 {
+		t.Fatalf("expected no job to be started, got %d", len(srvImpl.jobs))
+	}
+}
+
+func TestImportValuesEncodeSpecialFloats(t *testing.T) {
+	var parsed metricLine
+	if err := json.Unmarshal([]byte(`{"metric":{"__name__":"up"},"values":[1,"2.5",null,"NaN","+Inf","-Inf"],"timestamps":[1,2,3,4,5,6]}`), &parsed); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	data, err := buildNormalizedLine(parsed.Metric, parsed.Values, parsed.Timestamps)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	if want := `"values":[1,2.5,null,"NaN","+Inf","-Inf"]`; !strings.Contains(string(data), want) {
+		t.Fatalf("got %s, want %s", data, want)
+	}
+}
+
+func TestMetricLineRejectsValuesTheExporterRejects(t *testing.T) {
+	for _, values := range []string{`[true]`, `["abc"]`, `[{}]`} {
+		var parsed metricLine
+		if err := json.Unmarshal([]byte(`{"metric":{"__name__":"up"},"values":`+values+`,"timestamps":[1]}`), &parsed); err == nil {
+			t.Fatalf("expected values %s to be rejected, got %v", values, parsed.Values)
+		}
+	}
+}
+
+func TestPrepareZipBundleRejectsTooManyEntries(t *testing.T) {
+	var zipBuffer bytes.Buffer
+	zw := zip.NewWriter(&zipBuffer)
+	mw, _ := zw.Create("metrics.jsonl")
+	fmt.Fprintf(mw, `{"metric":{"__name__":"up"},"values":[1],"timestamps":[1000]}`+"\n")
+	for i := 0; i < maxBundleEntries; i++ {
+		zw.Create(fmt.Sprintf("filler/%d.txt", i))
+	}
+	zw.Close()
+
+	tmpPath := ensureTestFile(t, "bundle-many-entries.zip", func(w io.Writer) error {
+		_, err := w.Write(zipBuffer.Bytes())
+		return err
+	})
+
+	_, err := prepareZipBundle(tmpPath, int64(zipBuffer.Len()))
+	if err == nil || !strings.Contains(err.Error(), "1001 entries") {
+		t.Fatalf("expected bundle with too many entries to be rejected, got %v", err)
+	}
+}
+
+func TestPrepareZipBundleRejectsNativeBundle(t *testing.T) {
+	var zipBuffer bytes.Buffer
+	zw := zip.NewWriter(&zipBuffer)
+	nw, _ := zw.Create("metrics.native")
+	nw.Write([]byte{0x00, 0x01, 0xff})
+	zw.Close()
+
+	tmpPath := ensureTestFile(t, "bundle-native.zip", func(w io.Writer) error {
+		_, err := w.Write(zipBuffer.Bytes())
+		return err
+	})
+
+	_, err := prepareZipBundle(tmpPath, int64(zipBuffer.Len()))
+	if err == nil || !strings.Contains(err.Error(), "/api/v1/import/native") {
+		t.Fatalf("expected native bundles to point at /api/v1/import/native, got %v", err)
+	}
+}
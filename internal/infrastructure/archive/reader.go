@@ -0,0 +1,151 @@
+package archive
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+)
+
+// ArchiveSummary is what metadata.json tells about an archive without reading its metrics
+type ArchiveSummary struct {
+	ExportID        string           `json:"export_id"`
+	ExportDate      time.Time        `json:"export_date"`
+	TimeRange       domain.TimeRange `json:"time_range"`
+	Components      []string         `json:"components"`
+	MetricsCount    int              `json:"metrics_count"`
+	Obfuscated      bool             `json:"obfuscated"`
+	Format          string           `json:"format,omitempty"`
+	VMGatherVersion string           `json:"vmgather_version,omitempty"`
+	Fingerprint     string           `json:"connection_fingerprint,omitempty"`
+}
+
+// ReadArchiveSummary opens a vmgather archive and decodes only its metadata.json
+func ReadArchiveSummary(archivePath string) (*ArchiveSummary, error) {
+	reader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer func() { _ = reader.Close() }()
+
+	for _, file := range reader.File {
+		if file.Name != "metadata.json" {
+			continue
+		}
+		meta, err := decodeArchiveMetadata(file)
+		if err != nil {
+			return nil, err
+		}
+		return &ArchiveSummary{
+			ExportID:        meta.ExportID,
+			ExportDate:      meta.ExportDate,
+			TimeRange:       meta.TimeRange,
+			Components:      meta.Components,
+			MetricsCount:    meta.MetricsCount,
+			Obfuscated:      meta.Obfuscated,
+			Format:          meta.Format,
+			VMGatherVersion: meta.VMGatherVersion,
+			Fingerprint:     meta.Fingerprint,
+		}, nil
+	}
+	return nil, fmt.Errorf("archive %s does not contain metadata.json", filepath.Base(archivePath))
+}
+
+// ArchiveSeries holds the series set of a previously written archive
+type ArchiveSeries struct {
+	ArchiveName string
+	ExportID    string
+	Obfuscated  bool
+	Series      []map[string]string
+}
+
+// ReadArchiveSeries opens a vmgather archive and collects the label sets of all series in metrics.jsonl.
+// Samples are not kept in memory, only distinct label sets in the order they first appear in the file;
+// a series split over several lines (one per batch) is returned once.
+func ReadArchiveSeries(archivePath string) (*ArchiveSeries, error) {
+	reader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer func() { _ = reader.Close() }()
+
+	result := &ArchiveSeries{ArchiveName: filepath.Base(archivePath)}
+	var metricsFile *zip.File
+	for _, file := range reader.File {
+		switch file.Name {
+		case "metrics.jsonl":
+			metricsFile = file
+		case "metadata.json":
+			if err := readArchiveMetadata(file, result); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if metricsFile == nil {
+		return nil, fmt.Errorf("archive %s does not contain metrics.jsonl", result.ArchiveName)
+	}
+
+	rc, err := metricsFile.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open metrics.jsonl: %w", err)
+	}
+	defer func() { _ = rc.Close() }()
+
+	seen := make(map[string]struct{})
+	decoder := json.NewDecoder(rc)
+	for {
+		var line struct {
+			Metric map[string]string `json:"metric"`
+		}
+		if err := decoder.Decode(&line); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to decode metrics.jsonl: %w", err)
+		}
+		// The encoding marker is not part of the original label set
+		delete(line.Metric, domain.CounterEncodingLabel)
+		if len(line.Metric) == 0 {
+			continue
+		}
+		// encoding/json sorts map keys, so the encoded labels are a canonical key of the set
+		key, err := json.Marshal(line.Metric)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode series labels: %w", err)
+		}
+		if _, dup := seen[string(key)]; dup {
+			continue
+		}
+		seen[string(key)] = struct{}{}
+		result.Series = append(result.Series, line.Metric)
+	}
+
+	return result, nil
+}
+
+func readArchiveMetadata(file *zip.File, result *ArchiveSeries) error {
+	meta, err := decodeArchiveMetadata(file)
+	if err != nil {
+		return err
+	}
+	result.ExportID = meta.ExportID
+	result.Obfuscated = meta.Obfuscated
+	return nil
+}
+
+func decodeArchiveMetadata(file *zip.File) (*archiveMetadataPublic, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open metadata.json: %w", err)
+	}
+	defer func() { _ = rc.Close() }()
+
+	var meta archiveMetadataPublic
+	if err := json.NewDecoder(rc).Decode(&meta); err != nil {
+		return nil, fmt.Errorf("failed to decode metadata.json: %w", err)
+	}
+	return &meta, nil
+}
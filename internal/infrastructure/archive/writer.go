@@ -15,9 +15,29 @@ import (
 	"github.com/VictoriaMetrics/vmgather/internal/domain"
 )
 
+// MetadataSchemaVersion is the version of the metadata.json layout written into archives.
+// Bump it whenever the metadata format changes in a way older importers cannot handle.
+// Version 2 adds counter_encoding: delta-encoded counters must be decoded before import.
+const MetadataSchemaVersion = 2
+
+// CollisionStrategy controls what CreateArchive does when the archive name is already taken
+type CollisionStrategy string
+
+const (
+	// CollisionUnique appends a timestamp to every archive name (default)
+	CollisionUnique CollisionStrategy = "unique"
+	// CollisionOverwrite writes vmexport_<id>.zip, replacing an existing archive
+	CollisionOverwrite CollisionStrategy = "overwrite"
+	// CollisionSkip keeps an existing vmexport_<id>.zip and returns it untouched
+	CollisionSkip CollisionStrategy = "skip"
+	// CollisionVersion writes vmexport_<id>.zip, or the first free vmexport_<id>-vN.zip
+	CollisionVersion CollisionStrategy = "version"
+)
+
 // Writer handles archive creation for export data
 type Writer struct {
-	outputDir string
+	outputDir         string
+	collisionStrategy CollisionStrategy
 }
 
 func (w *Writer) OutputDir() string {
@@ -27,8 +47,34 @@ func (w *Writer) OutputDir() string {
 // NewWriter creates a new archive writer
 func NewWriter(outputDir string) *Writer {
 	return &Writer{
-		outputDir: outputDir,
+		outputDir:         outputDir,
+		collisionStrategy: CollisionUnique,
+	}
+}
+
+// ParseCollisionStrategy validates a strategy name; an empty name is CollisionUnique
+func ParseCollisionStrategy(name string) (CollisionStrategy, error) {
+	switch strategy := CollisionStrategy(name); strategy {
+	case "":
+		return CollisionUnique, nil
+	case CollisionUnique, CollisionOverwrite, CollisionSkip, CollisionVersion:
+		return strategy, nil
+	default:
+		return "", fmt.Errorf("unknown archive collision strategy %q (use unique, overwrite, skip or version)", name)
+	}
+}
+
+// NewWriterWithCollisionStrategy creates an archive writer with explicit collision handling.
+// Non-unique strategies use the stable name vmexport_<id>.zip so repeated runs target the same file.
+func NewWriterWithCollisionStrategy(outputDir string, strategy CollisionStrategy) (*Writer, error) {
+	strategy, err := ParseCollisionStrategy(string(strategy))
+	if err != nil {
+		return nil, err
 	}
+	return &Writer{
+		outputDir:         outputDir,
+		collisionStrategy: strategy,
+	}, nil
 }
 
 func validateExportID(exportID string) error {
@@ -56,29 +102,83 @@ func validateExportID(exportID string) error {
 // Note: InstanceMap and JobMap are intentionally excluded from archive metadata
 // per issue #10 - mapping should not be included in the archive sent to customers
 type ArchiveMetadata struct {
-	ExportID        string            `json:"export_id"`
-	ExportDate      time.Time         `json:"export_date"`
-	TimeRange       domain.TimeRange  `json:"time_range"`
-	Components      []string          `json:"components"`
-	Jobs            []string          `json:"jobs"`
-	MetricsCount    int               `json:"metrics_count"`
-	Obfuscated      bool              `json:"obfuscated"`
-	InstanceMap     map[string]string `json:"instance_map,omitempty"` // Internal use only, not included in archive
-	JobMap          map[string]string `json:"job_map,omitempty"`      // Internal use only, not included in archive
-	VMGatherVersion string            `json:"vmgather_version"`
+	ExportID        string                         `json:"export_id"`
+	ExportDate      time.Time                      `json:"export_date"`
+	TimeRange       domain.TimeRange               `json:"time_range"`
+	Components      []string                       `json:"components"`
+	Jobs            []string                       `json:"jobs"`
+	MetricsCount    int                            `json:"metrics_count"`
+	Obfuscated      bool                           `json:"obfuscated"`
+	InstanceMap     map[string]string              `json:"instance_map,omitempty"` // Internal use only, not included in archive
+	JobMap          map[string]string              `json:"job_map,omitempty"`      // Internal use only, not included in archive
+	VMGatherVersion string                         `json:"vmgather_version"`
+	Fingerprint     string                         `json:"connection_fingerprint,omitempty"` // Hash of host, tenant and base path; never the URL
+	Pagination      *domain.SeriesPagination       `json:"pagination,omitempty"`
+	SeriesCap       *domain.ComponentSeriesCap     `json:"component_series_cap,omitempty"`
+	Baseline        *domain.BaselineReference      `json:"baseline,omitempty"`
+	Partial         *domain.PartialExport          `json:"partial,omitempty"`
+	ScrapeIntervals []domain.ScrapeIntervalSummary `json:"scrape_intervals,omitempty"`
+	CounterEncoding domain.CounterEncoding         `json:"counter_encoding,omitempty"`
+	Decimation      *domain.DecimationSummary      `json:"decimation,omitempty"`
+	Sampling        *domain.SamplingSummary        `json:"sampling,omitempty"`
+	Formats         []string                       `json:"formats,omitempty"`
+	Format          string                         `json:"format,omitempty"`
+	Fidelity        []domain.BatchFidelity         `json:"fidelity,omitempty"`
+	QuerySet        *domain.QuerySet               `json:"query_set,omitempty"`
+	FutureSamples   *domain.FutureSampleSummary    `json:"future_samples,omitempty"`
+	LabelValues     *domain.LabelValueSummary      `json:"label_values,omitempty"`
+	Normalization   *domain.NormalizationSummary   `json:"label_normalization,omitempty"`
+	VMAlert         *domain.VMAlertSummary         `json:"vmalert,omitempty"`
+	CarryIn         *domain.CarryInSummary         `json:"carry_in,omitempty"`
+	Batch           *domain.BatchWindow            `json:"batch,omitempty"`
+	AlwaysIncludeUp bool                           `json:"always_include_up,omitempty"`
+	RangeClamp      *domain.RangeAdjustment        `json:"range_clamp,omitempty"`
+	CategoryLabels  []string                       `json:"category_labels,omitempty"`
+	LabelBudget     *domain.LabelBudgetSummary     `json:"label_cardinality_budget,omitempty"`
+	ReproduceScript string                         `json:"-"` // Written as reproduce.sh when set
+	CSVPath         string                         `json:"-"` // Copied into the archive as metrics.csv when set
+	AlertsJSON      []byte                         `json:"-"` // vmalert /api/v1/alerts, written as alerts.json when set
+	RulesJSON       []byte                         `json:"-"` // vmalert /api/v1/rules, written as rules.json when set
+	FailedBatches   []domain.FailedBatch           `json:"-"` // Batch windows skipped after errors, written as errors.json when set
+	SeriesStatsJSON []byte                         `json:"-"` // Per-series min/max/avg/last, written as series_stats.json when set
+	MetricTypes     []domain.MetricTypeHint        `json:"-"` // Most frequent metrics with their inferred type, listed in README.txt
 }
 
 // archiveMetadataPublic is the public version of metadata without obfuscation maps
 // This is what gets included in the archive sent to customers
 type archiveMetadataPublic struct {
-	ExportID        string           `json:"export_id"`
-	ExportDate      time.Time        `json:"export_date"`
-	TimeRange       domain.TimeRange `json:"time_range"`
-	Components      []string         `json:"components"`
-	Jobs            []string         `json:"jobs"`
-	MetricsCount    int              `json:"metrics_count"`
-	Obfuscated      bool             `json:"obfuscated"`
-	VMGatherVersion string           `json:"vmgather_version"`
+	SchemaVersion   int                            `json:"schema_version"`
+	ExportID        string                         `json:"export_id"`
+	ExportDate      time.Time                      `json:"export_date"`
+	TimeRange       domain.TimeRange               `json:"time_range"`
+	Components      []string                       `json:"components"`
+	Jobs            []string                       `json:"jobs"`
+	MetricsCount    int                            `json:"metrics_count"`
+	Obfuscated      bool                           `json:"obfuscated"`
+	VMGatherVersion string                         `json:"vmgather_version"`
+	Fingerprint     string                         `json:"connection_fingerprint,omitempty"`
+	Pagination      *domain.SeriesPagination       `json:"pagination,omitempty"`
+	SeriesCap       *domain.ComponentSeriesCap     `json:"component_series_cap,omitempty"`
+	Baseline        *domain.BaselineReference      `json:"baseline,omitempty"`
+	Partial         *domain.PartialExport          `json:"partial,omitempty"`
+	ScrapeIntervals []domain.ScrapeIntervalSummary `json:"scrape_intervals,omitempty"`
+	CounterEncoding domain.CounterEncoding         `json:"counter_encoding,omitempty"`
+	Decimation      *domain.DecimationSummary      `json:"decimation,omitempty"`
+	Sampling        *domain.SamplingSummary        `json:"sampling,omitempty"`
+	Formats         []string                       `json:"formats,omitempty"`
+	Format          string                         `json:"format,omitempty"`
+	Fidelity        []domain.BatchFidelity         `json:"fidelity,omitempty"`
+	QuerySet        *domain.QuerySet               `json:"query_set,omitempty"`
+	FutureSamples   *domain.FutureSampleSummary    `json:"future_samples,omitempty"`
+	LabelValues     *domain.LabelValueSummary      `json:"label_values,omitempty"`
+	Normalization   *domain.NormalizationSummary   `json:"label_normalization,omitempty"`
+	VMAlert         *domain.VMAlertSummary         `json:"vmalert,omitempty"`
+	CarryIn         *domain.CarryInSummary         `json:"carry_in,omitempty"`
+	Batch           *domain.BatchWindow            `json:"batch,omitempty"`
+	AlwaysIncludeUp bool                           `json:"always_include_up,omitempty"`
+	RangeClamp      *domain.RangeAdjustment        `json:"range_clamp,omitempty"`
+	CategoryLabels  []string                       `json:"category_labels,omitempty"`
+	LabelBudget     *domain.LabelBudgetSummary     `json:"label_cardinality_budget,omitempty"`
 }
 
 // CreateArchive creates a ZIP archive with metrics data
@@ -92,32 +192,58 @@ func (w *Writer) CreateArchive(
 		return "", "", err
 	}
 
-	// Generate archive filename
-	timestamp := time.Now().Format("20060102_150405")
-	archiveName := fmt.Sprintf("vmexport_%s_%s.zip", exportID, timestamp)
-	archivePath = filepath.Join(w.outputDir, archiveName)
-
 	// Create output directory if not exists
 	if err := os.MkdirAll(w.outputDir, 0755); err != nil {
 		return "", "", fmt.Errorf("failed to create output directory: %w", err)
 	}
 
-	// Create archive file
-	archiveFile, err := os.Create(archivePath)
+	// Generate archive filename
+	archivePath, skip, err := w.resolveArchivePath(exportID)
+	if err != nil {
+		return "", "", err
+	}
+	if skip {
+		sha256sum, err = w.calculateSHA256(archivePath)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to calculate SHA256: %w", err)
+		}
+		return archivePath, sha256sum, nil
+	}
+
+	// Write to a temporary file next to the target and rename it into place once complete,
+	// so a failed export never leaves a truncated archive or destroys the one it overwrites
+	archiveFile, err := os.CreateTemp(w.outputDir, ".vmexport_*.zip.tmp")
 	if err != nil {
 		return "", "", fmt.Errorf("failed to create archive file: %w", err)
 	}
-	defer func() { _ = archiveFile.Close() }()
+	tmpPath := archiveFile.Name()
+	defer func() {
+		_ = archiveFile.Close()
+		if err != nil {
+			_ = os.Remove(tmpPath)
+		}
+	}()
+	if err := archiveFile.Chmod(0644); err != nil {
+		return "", "", fmt.Errorf("failed to create archive file: %w", err)
+	}
 
-	// Create ZIP writer
-	zipWriter := zip.NewWriter(archiveFile)
+	// Create ZIP writer. The SHA256 is computed from the bytes as they are written,
+	// which avoids re-reading the whole archive from disk afterwards.
+	hasher := sha256.New()
+	zipWriter := zip.NewWriter(io.MultiWriter(archiveFile, hasher))
 	defer func() { _ = zipWriter.Close() }()
 
 	// Add metrics data
-	if err := w.addMetricsToArchive(zipWriter, metricsReader); err != nil {
+	if err := w.addMetricsToArchive(zipWriter, metricsEntryName(metadata.Format), metricsReader); err != nil {
 		return "", "", fmt.Errorf("failed to add metrics: %w", err)
 	}
 
+	if metadata.CSVPath != "" {
+		if err := w.addFileToArchive(zipWriter, "metrics.csv", metadata.CSVPath); err != nil {
+			return "", "", fmt.Errorf("failed to add metrics.csv: %w", err)
+		}
+	}
+
 	// Add metadata
 	if err := w.addMetadataToArchive(zipWriter, metadata); err != nil {
 		return "", "", fmt.Errorf("failed to add metadata: %w", err)
@@ -128,23 +254,99 @@ func (w *Writer) CreateArchive(
 		return "", "", fmt.Errorf("failed to add README: %w", err)
 	}
 
+	// Add the vmalert snapshot
+	if metadata.AlertsJSON != nil {
+		if err := w.addBytesToArchive(zipWriter, "alerts.json", metadata.AlertsJSON); err != nil {
+			return "", "", fmt.Errorf("failed to add alerts.json: %w", err)
+		}
+	}
+	if metadata.RulesJSON != nil {
+		if err := w.addBytesToArchive(zipWriter, "rules.json", metadata.RulesJSON); err != nil {
+			return "", "", fmt.Errorf("failed to add rules.json: %w", err)
+		}
+	}
+
+	if len(metadata.FailedBatches) > 0 {
+		if err := w.addBytesToArchive(zipWriter, "errors.json", encodeFailedBatches(metadata.FailedBatches)); err != nil {
+			return "", "", fmt.Errorf("failed to add errors.json: %w", err)
+		}
+	}
+
+	if metadata.SeriesStatsJSON != nil {
+		if err := w.addBytesToArchive(zipWriter, "series_stats.json", metadata.SeriesStatsJSON); err != nil {
+			return "", "", fmt.Errorf("failed to add series_stats.json: %w", err)
+		}
+	}
+
+	// Add reproduce script
+	if metadata.ReproduceScript != "" {
+		if err := w.addReproduceToArchive(zipWriter, metadata.ReproduceScript); err != nil {
+			return "", "", fmt.Errorf("failed to add reproduce script: %w", err)
+		}
+	}
+
 	// Close ZIP writer to flush all data
 	if err := zipWriter.Close(); err != nil {
 		return "", "", fmt.Errorf("failed to close zip writer: %w", err)
 	}
 
-	// Calculate SHA256
-	sha256sum, err = w.calculateSHA256(archivePath)
-	if err != nil {
-		return "", "", fmt.Errorf("failed to calculate SHA256: %w", err)
+	if err := archiveFile.Close(); err != nil {
+		return "", "", fmt.Errorf("failed to close archive file: %w", err)
+	}
+	if err := os.Rename(tmpPath, archivePath); err != nil {
+		return "", "", fmt.Errorf("failed to move archive into place: %w", err)
+	}
+
+	return archivePath, hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// resolveArchivePath picks the archive path according to the collision strategy.
+// skip is true when an existing archive must be returned as-is.
+func (w *Writer) resolveArchivePath(exportID string) (archivePath string, skip bool, err error) {
+	if w.collisionStrategy == "" || w.collisionStrategy == CollisionUnique {
+		timestamp := time.Now().Format("20060102_150405")
+		return filepath.Join(w.outputDir, fmt.Sprintf("vmexport_%s_%s.zip", exportID, timestamp)), false, nil
 	}
 
-	return archivePath, sha256sum, nil
+	archivePath = filepath.Join(w.outputDir, fmt.Sprintf("vmexport_%s.zip", exportID))
+	exists, err := fileExists(archivePath)
+	if err != nil || !exists {
+		return archivePath, false, err
+	}
+
+	switch w.collisionStrategy {
+	case CollisionSkip:
+		return archivePath, true, nil
+	case CollisionVersion:
+		for version := 2; ; version++ {
+			candidate := filepath.Join(w.outputDir, fmt.Sprintf("vmexport_%s-v%d.zip", exportID, version))
+			exists, err := fileExists(candidate)
+			if err != nil {
+				return "", false, err
+			}
+			if !exists {
+				return candidate, false, nil
+			}
+		}
+	default:
+		return archivePath, false, nil
+	}
+}
+
+func fileExists(path string) (bool, error) {
+	_, err := os.Stat(path)
+	if err == nil {
+		return true, nil
+	}
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return false, fmt.Errorf("failed to check archive path: %w", err)
 }
 
 // addMetricsToArchive adds metrics JSONL data to archive
-func (w *Writer) addMetricsToArchive(zipWriter *zip.Writer, metricsReader io.Reader) error {
-	writer, err := zipWriter.Create("metrics.jsonl")
+func (w *Writer) addMetricsToArchive(zipWriter *zip.Writer, name string, metricsReader io.Reader) error {
+	writer, err := zipWriter.Create(name)
 	if err != nil {
 		return err
 	}
@@ -153,6 +355,40 @@ func (w *Writer) addMetricsToArchive(zipWriter *zip.Writer, metricsReader io.Rea
 	return err
 }
 
+// metricsEntryName is the archive entry holding the exported data in the given format
+func metricsEntryName(format string) string {
+	if format == domain.ExportFormatNative {
+		return "metrics.native"
+	}
+	return "metrics.jsonl"
+}
+
+// addBytesToArchive writes data into the archive under name
+func (w *Writer) addBytesToArchive(zipWriter *zip.Writer, name string, data []byte) error {
+	writer, err := zipWriter.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(data)
+	return err
+}
+
+// addFileToArchive copies a local file into the archive under name
+func (w *Writer) addFileToArchive(zipWriter *zip.Writer, name, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	writer, err := zipWriter.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(writer, file)
+	return err
+}
+
 // addMetadataToArchive adds metadata JSON to archive
 // Note: Obfuscation maps (InstanceMap, JobMap) are excluded from archive per issue #10
 func (w *Writer) addMetadataToArchive(zipWriter *zip.Writer, metadata ArchiveMetadata) error {
@@ -163,6 +399,7 @@ func (w *Writer) addMetadataToArchive(zipWriter *zip.Writer, metadata ArchiveMet
 
 	// Create public metadata without obfuscation maps
 	publicMetadata := archiveMetadataPublic{
+		SchemaVersion:   MetadataSchemaVersion,
 		ExportID:        metadata.ExportID,
 		ExportDate:      metadata.ExportDate,
 		TimeRange:       metadata.TimeRange,
@@ -171,6 +408,29 @@ func (w *Writer) addMetadataToArchive(zipWriter *zip.Writer, metadata ArchiveMet
 		MetricsCount:    metadata.MetricsCount,
 		Obfuscated:      metadata.Obfuscated,
 		VMGatherVersion: metadata.VMGatherVersion,
+		Fingerprint:     metadata.Fingerprint,
+		Pagination:      metadata.Pagination,
+		SeriesCap:       metadata.SeriesCap,
+		Baseline:        metadata.Baseline,
+		Partial:         metadata.Partial,
+		ScrapeIntervals: metadata.ScrapeIntervals,
+		CounterEncoding: metadata.CounterEncoding,
+		Decimation:      metadata.Decimation,
+		Sampling:        metadata.Sampling,
+		Formats:         metadata.Formats,
+		Format:          metadata.Format,
+		Fidelity:        metadata.Fidelity,
+		QuerySet:        metadata.QuerySet,
+		FutureSamples:   metadata.FutureSamples,
+		LabelValues:     metadata.LabelValues,
+		CarryIn:         metadata.CarryIn,
+		Normalization:   metadata.Normalization,
+		VMAlert:         metadata.VMAlert,
+		Batch:           metadata.Batch,
+		AlwaysIncludeUp: metadata.AlwaysIncludeUp,
+		RangeClamp:      metadata.RangeClamp,
+		CategoryLabels:  metadata.CategoryLabels,
+		LabelBudget:     metadata.LabelBudget,
 	}
 
 	encoder := json.NewEncoder(writer)
@@ -178,6 +438,15 @@ func (w *Writer) addMetadataToArchive(zipWriter *zip.Writer, metadata ArchiveMet
 	return encoder.Encode(publicMetadata)
 }
 
+// batchLine describes the window of an archive_per_batch archive for README.txt
+func batchLine(batch *domain.BatchWindow) string {
+	if batch == nil {
+		return ""
+	}
+	return fmt.Sprintf("Batch: %d of %d (export range %s to %s)\n", batch.Index+1, batch.TotalBatches,
+		batch.ExportTimeRange.Start.Format(time.RFC3339), batch.ExportTimeRange.End.Format(time.RFC3339))
+}
+
 // addReadmeToArchive adds human-readable README to archive
 func (w *Writer) addReadmeToArchive(zipWriter *zip.Writer, metadata ArchiveMetadata) error {
 	writer, err := zipWriter.Create("README.txt")
@@ -190,6 +459,18 @@ func (w *Writer) addReadmeToArchive(zipWriter *zip.Writer, metadata ArchiveMetad
 	return err
 }
 
+// addReproduceToArchive adds reproduce.sh as an executable file
+func (w *Writer) addReproduceToArchive(zipWriter *zip.Writer, script string) error {
+	header := &zip.FileHeader{Name: "reproduce.sh", Method: zip.Deflate}
+	header.SetMode(0o755)
+	writer, err := zipWriter.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write([]byte(script))
+	return err
+}
+
 // generateReadme generates human-readable README content
 func (w *Writer) generateReadme(metadata ArchiveMetadata) string {
 	readme := fmt.Sprintf(`VictoriaMetrics Metrics Export
@@ -198,11 +479,12 @@ func (w *Writer) generateReadme(metadata ArchiveMetadata) string {
 Export ID: %s
 Export Date: %s
 Time Range: %s to %s
-
+%s
 Components Exported:
 `, metadata.ExportID, metadata.ExportDate.Format(time.RFC3339),
 		metadata.TimeRange.Start.Format(time.RFC3339),
-		metadata.TimeRange.End.Format(time.RFC3339))
+		metadata.TimeRange.End.Format(time.RFC3339),
+		batchLine(metadata.Batch))
 
 	for _, comp := range metadata.Components {
 		readme += fmt.Sprintf("  - %s\n", comp)
@@ -213,12 +495,158 @@ Components Exported:
 	if metadata.Obfuscated {
 		readme += "\n[WARN] OBFUSCATION APPLIED\n"
 		readme += "Instance IPs and job names have been obfuscated for privacy.\n"
+		if len(metadata.CategoryLabels) > 0 {
+			readme += fmt.Sprintf("Category labels derived from the original values keep coarse grouping: %s\n", strings.Join(metadata.CategoryLabels, ", "))
+		}
+	}
+
+	if clamp := metadata.RangeClamp; clamp != nil {
+		readme += fmt.Sprintf("\nTime range clamped to the available data (clamp_to_data); requested %s to %s.\n",
+			clamp.RequestedRange.Start.Format(time.RFC3339), clamp.RequestedRange.End.Format(time.RFC3339))
+	}
+
+	if metadata.Partial != nil {
+		readme += "\n[WARN] PARTIAL EXPORT\n"
+		// failed_batches exports ran to the end; only the skipped windows are missing
+		if metadata.Partial.Reason != "failed_batches" {
+			readme += fmt.Sprintf("Export stopped early (%s); fully covered range: %s to %s.\n", metadata.Partial.Reason,
+				metadata.Partial.CoveredRange.Start.Format(time.RFC3339), metadata.Partial.CoveredRange.End.Format(time.RFC3339))
+		}
+		if len(metadata.FailedBatches) > 0 {
+			readme += fmt.Sprintf("%d batch window(s) failed and were skipped; see errors.json for the errors:\n", metadata.Partial.FailedBatches)
+			for _, failed := range metadata.FailedBatches {
+				readme += fmt.Sprintf("  - %s to %s\n", failed.TimeRange.Start.Format(time.RFC3339), failed.TimeRange.End.Format(time.RFC3339))
+			}
+		}
+	}
+
+	if len(metadata.ScrapeIntervals) > 0 {
+		readme += "\nInferred scrape intervals (median sample spacing):\n"
+		for _, interval := range metadata.ScrapeIntervals {
+			readme += fmt.Sprintf("  - %s: %gs (%d series)\n", interval.Component, interval.MedianIntervalSeconds, interval.Series)
+		}
+	}
+
+	if len(metadata.MetricTypes) > 0 {
+		readme += "\nTop metrics with types inferred from their names (_bucket: histogram, _total/_count: counter, otherwise gauge):\n"
+		readme += fmt.Sprintf("  %-50s %-10s %s\n", "Metric", "Type", "Series")
+		for _, hint := range metadata.MetricTypes {
+			readme += fmt.Sprintf("  %-50s %-10s %d\n", hint.Metric, hint.Type, hint.Series)
+		}
+		readme += "These are guesses for quick reading; metadata from the source is authoritative.\n"
+	}
+
+	if metadata.QuerySet != nil {
+		readme += fmt.Sprintf("\nQuery set %q (series are tagged with the vmgather_query label):\n", metadata.QuerySet.Name)
+		for _, query := range metadata.QuerySet.Queries {
+			readme += fmt.Sprintf("  - %s\n", query.Name)
+		}
+	}
+
+	if metadata.AlwaysIncludeUp {
+		readme += "\nTarget liveness: the up series of the exported targets were added regardless of the selector (always_include_up).\n"
+	}
+
+	if capped := cappedComponents(metadata.SeriesCap); len(capped) > 0 {
+		readme += "\n[WARN] SERIES CAPPED PER COMPONENT\n"
+		readme += fmt.Sprintf("At most %d series were exported per component; capped: %s.\n",
+			metadata.SeriesCap.Cap, strings.Join(capped, ", "))
+	}
+
+	if metadata.Sampling != nil {
+		readme += "\n[WARN] SAMPLED SERIES\n"
+		readme += fmt.Sprintf("Only 1 of every %d raw points of each series was kept (sample_every_n), counted per batch window; %d of %d points were dropped.\n",
+			metadata.Sampling.SampleEveryN, metadata.Sampling.PointsDropped, metadata.Sampling.PointsKept+metadata.Sampling.PointsDropped)
+	}
+
+	if metadata.Decimation != nil && metadata.Decimation.PointsDropped > 0 {
+		readme += "\n[WARN] DECIMATED SERIES\n"
+		readme += fmt.Sprintf("Series were thinned to at most %d evenly spaced points over the export range; %d of %d points were dropped.\n",
+			metadata.Decimation.MaxPointsPerSeries, metadata.Decimation.PointsDropped, metadata.Decimation.PointsKept+metadata.Decimation.PointsDropped)
+	}
+
+	if derived := queryRangeBatches(metadata.Fidelity); derived > 0 {
+		readme += "\n[WARN] QUERY_RANGE-DERIVED DATA\n"
+		readme += fmt.Sprintf("%d batch window(s) were fetched via query_range (MetricsQL query or /api/v1/export unavailable).\n", derived)
+		readme += "Their points are evaluated at every step, not raw samples: a value may repeat until the lookbehind expires.\n"
+		readme += "See \"fidelity\" in metadata.json for the affected time ranges.\n"
+	}
+
+	if future := metadata.FutureSamples; future != nil {
+		readme += "\n[WARN] FUTURE TIMESTAMPS\n"
+		readme += fmt.Sprintf("%d sample(s) in %d series were timestamped after %s (export time + %ds) and were handled with policy %q.\n",
+			future.Points, future.Series, future.Limit.Format(time.RFC3339), future.MaxFutureSkewSeconds, future.Policy)
+	}
+
+	if norm := metadata.Normalization; norm != nil {
+		readme += "\n[WARN] LABELS NORMALIZED\n"
+		var steps []string
+		if norm.LowercaseNames {
+			steps = append(steps, "label names were lowercased")
+		}
+		if norm.TrimValues {
+			steps = append(steps, "surrounding whitespace was trimmed from label values")
+		}
+		readme += fmt.Sprintf("Labels differ from the source: %s (%d series changed).\n", strings.Join(steps, " and "), norm.Series)
+		if norm.Collisions > 0 {
+			readme += fmt.Sprintf("%d label(s) were dropped because another label had the same lowercased name.\n", norm.Collisions)
+		}
+	}
+
+	if budget := metadata.LabelBudget; budget != nil && len(budget.DroppedLabels) > 0 {
+		readme += "\n[WARN] HIGH-CARDINALITY LABELS DROPPED\n"
+		readme += fmt.Sprintf("Labels with more than %d distinct values were removed (label_cardinality_budget): %s.\n", budget.Budget, strings.Join(budget.DroppedLabels, ", "))
+		readme += "Series that differed only in those labels now share a label set.\n"
+	}
+
+	if carry := metadata.CarryIn; carry != nil && carry.Series > 0 {
+		readme += "\n[INFO] CARRIED-IN SAMPLES\n"
+		readme += fmt.Sprintf("%d series start with their latest sample from up to %ds before the range (carry_in_seconds); those points are timestamped before %s.\n",
+			carry.Series, carry.CarryInSeconds, metadata.TimeRange.Start.UTC().Format(time.RFC3339))
+	}
+
+	if labels := metadata.LabelValues; labels != nil {
+		readme += "\n[WARN] LONG LABEL VALUES\n"
+		if labels.Policy == domain.LabelValuesDropSeries {
+			readme += fmt.Sprintf("%d series with a label value longer than %d bytes were dropped (labels: %s).\n",
+				labels.Series, labels.MaxLabelValueLength, strings.Join(labels.Labels, ", "))
+		} else {
+			readme += fmt.Sprintf("%d label value(s) in %d series were truncated to %d bytes (labels: %s); truncated series may have merged.\n",
+				labels.ValuesTruncated, labels.Series, labels.MaxLabelValueLength, strings.Join(labels.Labels, ", "))
+		}
+	}
+
+	if metadata.CounterEncoding == domain.CounterEncodingDelta {
+		readme += "\n[WARN] DELTA-ENCODED COUNTERS\n"
+		readme += fmt.Sprintf("Series labelled %s=\"delta\" store per-sample deltas; import with vmimporter to restore absolute values.\n", domain.CounterEncodingLabel)
 	}
 
 	readme += "\nFiles in this archive:\n"
-	readme += "  - metrics.jsonl: Exported metrics in JSONL format\n"
+	if metadata.Format == domain.ExportFormatNative {
+		readme += "  - metrics.native: Exported metrics in VictoriaMetrics native format; import with /api/v1/import/native or vmctl\n"
+	} else {
+		readme += "  - metrics.jsonl: Exported metrics in JSONL format\n"
+	}
+	if metadata.CSVPath != "" {
+		readme += "  - metrics.csv: The same samples as CSV (name, labels, timestamp_ms, value), one row per sample\n"
+	}
+	if metadata.AlertsJSON != nil {
+		readme += "  - alerts.json: vmalert alerts at export time (/api/v1/alerts)\n"
+	}
+	if metadata.RulesJSON != nil {
+		readme += "  - rules.json: vmalert rules and their state at export time (/api/v1/rules)\n"
+	}
+	if len(metadata.FailedBatches) > 0 {
+		readme += "  - errors.json: Batch windows that failed, with the error VictoriaMetrics returned\n"
+	}
+	if metadata.SeriesStatsJSON != nil {
+		readme += "  - series_stats.json: Sample count, time span and min/max/avg/last per series\n"
+	}
 	readme += "  - metadata.json: Export metadata\n"
 	readme += "  - README.txt: This file\n"
+	if metadata.ReproduceScript != "" {
+		readme += "  - reproduce.sh: Commands to regenerate this export (set VM_URL/VM_AUTH; no credentials included)\n"
+	}
 
 	readme += "\nFor support inquiries, send this archive to VictoriaMetrics Support Team.\n"
 	readme += fmt.Sprintf("Generated by vmgather v%s\n", metadata.VMGatherVersion)
@@ -226,6 +654,31 @@ Components Exported:
 	return readme
 }
 
+// cappedComponents describes the components that had more series than per_component_series_cap
+func cappedComponents(seriesCap *domain.ComponentSeriesCap) []string {
+	if seriesCap == nil {
+		return nil
+	}
+	var capped []string
+	for _, component := range seriesCap.Components {
+		if component.Capped {
+			capped = append(capped, fmt.Sprintf("%s (%d of more)", component.Component, component.ExportedSeries))
+		}
+	}
+	return capped
+}
+
+// queryRangeBatches counts the batch windows whose points were evaluated via query_range
+func queryRangeBatches(fidelity []domain.BatchFidelity) int {
+	count := 0
+	for _, run := range fidelity {
+		if !run.Raw {
+			count += run.Batches
+		}
+	}
+	return count
+}
+
 // calculateSHA256 calculates SHA256 checksum of a file
 func (w *Writer) calculateSHA256(filePath string) (string, error) {
 	file, err := os.Open(filePath)
@@ -250,3 +703,11 @@ func (w *Writer) GetArchiveSize(archivePath string) (int64, error) {
 	}
 	return info.Size(), nil
 }
+
+// encodeFailedBatches renders errors.json
+func encodeFailedBatches(failed []domain.FailedBatch) []byte {
+	data, _ := json.MarshalIndent(struct {
+		FailedBatches []domain.FailedBatch `json:"failed_batches"`
+	}{failed}, "", "  ")
+	return data
+}
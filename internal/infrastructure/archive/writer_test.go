@@ -3,12 +3,17 @@ package archive
 import (
 	"archive/zip"
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"testing/iotest"
 	"time"
 
 	"github.com/VictoriaMetrics/vmgather/internal/domain"
@@ -610,3 +615,222 @@ func TestWriter_CreateArchive_EmptyMetrics(t *testing.T) {
 		t.Error("archive not created for empty metrics")
 	}
 }
+
+func TestWriter_CreateArchive_WritesSchemaVersion(t *testing.T) {
+	writer := NewWriter(t.TempDir())
+
+	metadata := ArchiveMetadata{
+		ExportID:        "schema",
+		ExportDate:      time.Now(),
+		VMGatherVersion: "1.0.0",
+	}
+	archivePath, _, err := writer.CreateArchive("schema", strings.NewReader(""), metadata)
+	if err != nil {
+		t.Fatalf("CreateArchive failed: %v", err)
+	}
+
+	zipReader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		t.Fatalf("failed to open archive: %v", err)
+	}
+	defer zipReader.Close()
+
+	for _, file := range zipReader.File {
+		if file.Name != "metadata.json" {
+			continue
+		}
+		reader, err := file.Open()
+		if err != nil {
+			t.Fatalf("failed to open metadata file: %v", err)
+		}
+		defer reader.Close()
+
+		var parsed map[string]interface{}
+		if err := json.NewDecoder(reader).Decode(&parsed); err != nil {
+			t.Fatalf("failed to parse metadata: %v", err)
+		}
+		if got, ok := parsed["schema_version"].(float64); !ok || int(got) != MetadataSchemaVersion {
+			t.Fatalf("schema_version = %v, want %d", parsed["schema_version"], MetadataSchemaVersion)
+		}
+		return
+	}
+	t.Fatal("metadata.json not found in archive")
+}
+
+// TestWriter_CreateArchive_CollisionStrategies tests archive naming against a pre-existing file
+func TestWriter_CreateArchive_CollisionStrategies(t *testing.T) {
+	readMetrics := func(t *testing.T, path string) string {
+		t.Helper()
+		zr, err := zip.OpenReader(path)
+		if err != nil {
+			t.Fatalf("failed to open archive: %v", err)
+		}
+		defer zr.Close()
+		for _, f := range zr.File {
+			if f.Name == "metrics.jsonl" {
+				rc, _ := f.Open()
+				data, _ := io.ReadAll(rc)
+				_ = rc.Close()
+				return string(data)
+			}
+		}
+		t.Fatal("metrics.jsonl not found")
+		return ""
+	}
+
+	tests := []struct {
+		strategy    CollisionStrategy
+		wantName    string
+		wantMetrics string
+	}{
+		{strategy: CollisionOverwrite, wantName: "vmexport_stable.zip", wantMetrics: "second"},
+		{strategy: CollisionSkip, wantName: "vmexport_stable.zip", wantMetrics: "first"},
+		{strategy: CollisionVersion, wantName: "vmexport_stable-v2.zip", wantMetrics: "second"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.strategy), func(t *testing.T) {
+			writer, err := NewWriterWithCollisionStrategy(t.TempDir(), tt.strategy)
+			if err != nil {
+				t.Fatalf("NewWriterWithCollisionStrategy failed: %v", err)
+			}
+			existing, _, err := writer.CreateArchive("stable", strings.NewReader("first"), ArchiveMetadata{ExportID: "stable"})
+			if err != nil {
+				t.Fatalf("failed to create existing archive: %v", err)
+			}
+			if filepath.Base(existing) != "vmexport_stable.zip" {
+				t.Fatalf("expected stable archive name, got %s", filepath.Base(existing))
+			}
+
+			path, sum, err := writer.CreateArchive("stable", strings.NewReader("second"), ArchiveMetadata{ExportID: "stable"})
+			if err != nil {
+				t.Fatalf("CreateArchive failed: %v", err)
+			}
+			if filepath.Base(path) != tt.wantName {
+				t.Fatalf("archive name = %s, want %s", filepath.Base(path), tt.wantName)
+			}
+			if sum == "" {
+				t.Fatal("expected SHA256 for returned archive")
+			}
+			if got := readMetrics(t, path); got != tt.wantMetrics {
+				t.Fatalf("metrics = %q, want %q", got, tt.wantMetrics)
+			}
+			if tt.strategy == CollisionVersion && readMetrics(t, existing) != "first" {
+				t.Fatal("version strategy must keep the existing archive intact")
+			}
+		})
+	}
+}
+
+// TestWriter_CreateArchive_FailedOverwriteKeepsExisting tests a failed export leaves the archive it would overwrite intact
+func TestWriter_CreateArchive_FailedOverwriteKeepsExisting(t *testing.T) {
+	dir := t.TempDir()
+	writer, err := NewWriterWithCollisionStrategy(dir, CollisionOverwrite)
+	if err != nil {
+		t.Fatalf("NewWriterWithCollisionStrategy failed: %v", err)
+	}
+	existing, sum, err := writer.CreateArchive("stable", strings.NewReader("first"), ArchiveMetadata{ExportID: "stable"})
+	if err != nil {
+		t.Fatalf("failed to create existing archive: %v", err)
+	}
+
+	failing := io.MultiReader(strings.NewReader("partial"), iotest.ErrReader(errors.New("source went away")))
+	if _, _, err := writer.CreateArchive("stable", failing, ArchiveMetadata{ExportID: "stable"}); err == nil {
+		t.Fatal("expected CreateArchive to fail")
+	}
+	if got, err := writer.calculateSHA256(existing); err != nil || got != sum {
+		t.Fatalf("expected the existing archive to be unchanged, got sha256 %q, %v", got, err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected only the existing archive to remain, got %d entries", len(entries))
+	}
+}
+
+// TestWriter_CreateArchive_DefaultStaysUnique tests the default strategy keeps timestamped names
+func TestWriter_CreateArchive_DefaultStaysUnique(t *testing.T) {
+	writer, err := NewWriterWithCollisionStrategy(t.TempDir(), "")
+	if err != nil {
+		t.Fatalf("NewWriterWithCollisionStrategy failed: %v", err)
+	}
+	path, _, err := writer.CreateArchive("stable", strings.NewReader("data"), ArchiveMetadata{ExportID: "stable"})
+	if err != nil {
+		t.Fatalf("CreateArchive failed: %v", err)
+	}
+	if !strings.HasPrefix(filepath.Base(path), "vmexport_stable_") {
+		t.Fatalf("expected timestamped archive name, got %s", filepath.Base(path))
+	}
+	if _, err := NewWriterWithCollisionStrategy(t.TempDir(), "bogus"); err == nil {
+		t.Fatal("expected error for unknown strategy")
+	}
+}
+
+// TestWriter_CreateArchive_StreamedSHA256MatchesFile tests the SHA256 computed while writing equals a re-read of the file
+func TestWriter_CreateArchive_StreamedSHA256MatchesFile(t *testing.T) {
+	writer := NewWriter(t.TempDir())
+	var metrics strings.Builder
+	for i := 0; i < 5000; i++ {
+		fmt.Fprintf(&metrics, `{"metric":{"__name__":"vm_rows","instance":"host-%d:8482"},"values":[%d],"timestamps":[1699728000000]}`+"\n", i, i)
+	}
+
+	path, streamed, err := writer.CreateArchive("sha-check", strings.NewReader(metrics.String()), ArchiveMetadata{ExportID: "sha-check"})
+	if err != nil {
+		t.Fatalf("CreateArchive failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read archive: %v", err)
+	}
+	sum := sha256.Sum256(data)
+	if expected := hex.EncodeToString(sum[:]); streamed != expected {
+		t.Fatalf("streamed SHA256 = %s, want %s", streamed, expected)
+	}
+}
+
+func BenchmarkWriter_CreateArchive(b *testing.B) {
+	var metrics strings.Builder
+	for i := 0; i < 50000; i++ {
+		fmt.Fprintf(&metrics, `{"metric":{"__name__":"vm_rows","instance":"host-%d:8482"},"values":[%d],"timestamps":[1699728000000]}`+"\n", i, i)
+	}
+	payload := metrics.String()
+	writer := NewWriter(b.TempDir())
+
+	b.SetBytes(int64(len(payload)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		path, _, err := writer.CreateArchive(fmt.Sprintf("bench-%d", i), strings.NewReader(payload), ArchiveMetadata{ExportID: "bench"})
+		if err != nil {
+			b.Fatalf("CreateArchive failed: %v", err)
+		}
+		_ = os.Remove(path)
+	}
+}
+
+func TestReadArchiveSeries_ReturnsDistinctLabelSets(t *testing.T) {
+	writer := NewWriter(t.TempDir())
+	// The same series appears once per batch; the encoding marker does not make it a new one
+	metricsData := `{"metric":{"__name__":"up","job":"vm"},"values":[1],"timestamps":[1]}
+{"metric":{"__name__":"go_goroutines","job":"vm"},"values":[42],"timestamps":[1]}
+{"metric":{"job":"vm","__name__":"up"},"values":[1],"timestamps":[2]}
+{"metric":{"__name__":"up","job":"vm","` + domain.CounterEncodingLabel + `":"delta"},"values":[1],"timestamps":[3]}
+`
+	archivePath, _, err := writer.CreateArchive("distinct", strings.NewReader(metricsData), ArchiveMetadata{ExportID: "distinct"})
+	if err != nil {
+		t.Fatalf("CreateArchive failed: %v", err)
+	}
+
+	contents, err := ReadArchiveSeries(archivePath)
+	if err != nil {
+		t.Fatalf("ReadArchiveSeries failed: %v", err)
+	}
+	if len(contents.Series) != 2 {
+		t.Fatalf("expected 2 distinct series, got %d: %v", len(contents.Series), contents.Series)
+	}
+	if contents.Series[0]["__name__"] != "up" || contents.Series[1]["__name__"] != "go_goroutines" {
+		t.Fatalf("expected series in first-seen order, got %v", contents.Series)
+	}
+}
@@ -0,0 +1,85 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Record is one completed export in the audit trail.
+// It must never carry credentials: only the connection host and tenant are recorded.
+type Record struct {
+	Timestamp        time.Time   `json:"timestamp"`
+	ExportID         string      `json:"export_id"`
+	ConnectionHost   string      `json:"connection_host"`
+	Tenant           string      `json:"tenant,omitempty"`
+	Selectors        []string    `json:"selectors"`
+	TimeRangeStart   time.Time   `json:"time_range_start"`
+	TimeRangeEnd     time.Time   `json:"time_range_end"`
+	Obfuscation      Obfuscation `json:"obfuscation"`
+	MetricsExported  int         `json:"metrics_exported"`
+	ArchiveName      string      `json:"archive_name"`
+	ArchiveSizeBytes int64       `json:"archive_size_bytes"`
+	SHA256           string      `json:"sha256"`
+	SourceSHA256     string      `json:"source_sha256,omitempty"`
+	Partial          bool        `json:"partial,omitempty"`
+}
+
+// Obfuscation summarises the obfuscation settings applied to an export
+type Obfuscation struct {
+	Enabled      bool     `json:"enabled"`
+	Instance     bool     `json:"instance"`
+	Job          bool     `json:"job"`
+	CustomLabels []string `json:"custom_labels,omitempty"`
+	DropLabels   []string `json:"drop_labels,omitempty"`
+}
+
+// Logger appends audit records as JSON lines to a file, separate from operational logs
+type Logger struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewLogger creates an audit logger writing to path, creating the parent directory if needed
+func NewLogger(path string) (*Logger, error) {
+	if path == "" {
+		return nil, fmt.Errorf("audit log path is empty")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+	return &Logger{path: path}, nil
+}
+
+// Path returns the audit file location
+func (l *Logger) Path() string {
+	return l.path
+}
+
+// Append writes record as a single JSON line and fsyncs it so the trail survives crashes
+func (l *Logger) Append(record Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit record: %w", err)
+	}
+	data = append(data, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to write audit record: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to sync audit log: %w", err)
+	}
+	return file.Close()
+}
@@ -0,0 +1,14 @@
+package obfuscation
+
+import "github.com/VictoriaMetrics/vmgather/internal/domain"
+
+// Allowlisted reports whether value is listed in the obfuscation allowlist and must be passed
+// through unchanged. Values match exactly, whatever label they appear in.
+func Allowlisted(config domain.ObfuscationConfig, value string) bool {
+	for _, allowed := range config.Allowlist {
+		if allowed == value {
+			return true
+		}
+	}
+	return false
+}
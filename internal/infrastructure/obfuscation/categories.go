@@ -0,0 +1,141 @@
+package obfuscation
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"regexp"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+)
+
+// Categorizer adds the category labels of ObfuscationConfig.CategoryLabels. It must see the
+// original values, so it runs before the labels are obfuscated. A nil Categorizer does nothing.
+type Categorizer struct {
+	rules []categoryRule
+}
+
+type categoryRule struct {
+	source, target string
+	matchers       []categoryMatcher
+	fallback       string
+}
+
+type categoryMatcher struct {
+	prefix   netip.Prefix
+	re       *regexp.Regexp
+	category string
+}
+
+// NewCategorizer validates and compiles the category rules; it returns nil when obfuscation is
+// disabled or no rules are configured. Targets must not be labels that are themselves
+// obfuscated or dropped.
+func NewCategorizer(config domain.ObfuscationConfig) (*Categorizer, error) {
+	if !config.Enabled || len(config.CategoryLabels) == 0 {
+		return nil, nil
+	}
+	reserved := map[string]bool{"__name__": true, "instance": config.ObfuscateInstance, "job": config.ObfuscateJob}
+	for _, label := range append(append([]string{}, config.CustomLabels...), config.DropLabels...) {
+		reserved[label] = true
+	}
+
+	c := &Categorizer{}
+	targets := make(map[string]bool)
+	for i, rule := range config.CategoryLabels {
+		if rule.Source == "" || rule.Target == "" {
+			return nil, fmt.Errorf("category_labels[%d]: source and target are required", i)
+		}
+		if rule.Target == rule.Source || reserved[rule.Target] {
+			return nil, fmt.Errorf("category_labels[%d]: target %q must not be the source or an obfuscated or dropped label", i, rule.Target)
+		}
+		if targets[rule.Target] {
+			return nil, fmt.Errorf("category_labels[%d]: target %q is used by another rule", i, rule.Target)
+		}
+		targets[rule.Target] = true
+
+		compiled := categoryRule{source: rule.Source, target: rule.Target, fallback: rule.Default}
+		for j, match := range rule.Match {
+			if match.Category == "" || (match.CIDR == "") == (match.Regex == "") {
+				return nil, fmt.Errorf("category_labels[%d].match[%d]: set a category and exactly one of cidr or regex", i, j)
+			}
+			matcher := categoryMatcher{category: match.Category}
+			if match.CIDR != "" {
+				prefix, err := netip.ParsePrefix(match.CIDR)
+				if err != nil {
+					return nil, fmt.Errorf("category_labels[%d].match[%d]: invalid cidr: %w", i, j, err)
+				}
+				matcher.prefix = prefix.Masked()
+			} else {
+				re, err := regexp.Compile("^(?:" + match.Regex + ")$")
+				if err != nil {
+					return nil, fmt.Errorf("category_labels[%d].match[%d]: invalid regex: %w", i, j, err)
+				}
+				matcher.re = re
+			}
+			compiled.matchers = append(compiled.matchers, matcher)
+		}
+		c.rules = append(c.rules, compiled)
+	}
+	return c, nil
+}
+
+// Targets lists the category labels in rule order
+func (c *Categorizer) Targets() []string {
+	if c == nil {
+		return nil
+	}
+	targets := make([]string, 0, len(c.rules))
+	for _, rule := range c.rules {
+		targets = append(targets, rule.target)
+	}
+	return targets
+}
+
+// Apply adds the category labels of one series from its original label values. A label the
+// series already has is left untouched.
+func (c *Categorizer) Apply(labels map[string]string) {
+	if c == nil || labels == nil {
+		return
+	}
+	for _, rule := range c.rules {
+		value, ok := labels[rule.source]
+		if !ok {
+			continue
+		}
+		if _, exists := labels[rule.target]; exists {
+			continue
+		}
+		if category := rule.categorize(value); category != "" {
+			labels[rule.target] = category
+		}
+	}
+}
+
+func (r categoryRule) categorize(value string) string {
+	addr, hasAddr := parseHostAddr(value)
+	for _, m := range r.matchers {
+		if m.re != nil {
+			if m.re.MatchString(value) {
+				return m.category
+			}
+			continue
+		}
+		if hasAddr && m.prefix.Contains(addr) {
+			return m.category
+		}
+	}
+	return r.fallback
+}
+
+// parseHostAddr reads the IP of "ip" or "ip:port" values
+func parseHostAddr(value string) (netip.Addr, bool) {
+	host := value
+	if h, _, err := net.SplitHostPort(value); err == nil {
+		host = h
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
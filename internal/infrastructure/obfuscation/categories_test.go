@@ -0,0 +1,71 @@
+package obfuscation
+
+import (
+	"testing"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+)
+
+func TestCategorizer(t *testing.T) {
+	config := domain.ObfuscationConfig{
+		Enabled:           true,
+		ObfuscateInstance: true,
+		CustomLabels:      []string{"pod"},
+		CategoryLabels: []domain.CategoryRule{
+			{
+				Source: "instance",
+				Target: "region",
+				Match: []domain.CategoryMatch{
+					{CIDR: "10.1.0.0/16", Category: "eu-west"},
+					{CIDR: "10.2.0.0/16", Category: "us-east"},
+				},
+				Default: "other",
+			},
+			{
+				Source: "pod",
+				Target: "pod_group",
+				Match:  []domain.CategoryMatch{{Regex: `vmstorage-.*`, Category: "storage"}},
+			},
+		},
+	}
+	c, err := NewCategorizer(config)
+	if err != nil {
+		t.Fatalf("NewCategorizer failed: %v", err)
+	}
+
+	cases := []struct {
+		labels map[string]string
+		want   map[string]string
+	}{
+		{map[string]string{"instance": "10.2.3.4:8482"}, map[string]string{"region": "us-east"}},
+		{map[string]string{"instance": "10.1.0.7"}, map[string]string{"region": "eu-west"}},
+		{map[string]string{"instance": "db.internal:9100"}, map[string]string{"region": "other"}},
+		{map[string]string{"instance": "10.1.0.7:80", "region": "kept"}, map[string]string{"region": "kept"}},
+		{map[string]string{"pod": "vmstorage-0"}, map[string]string{"pod_group": "storage"}},
+		{map[string]string{"pod": "vminsert-0"}, map[string]string{"pod_group": ""}},
+	}
+	for _, tc := range cases {
+		c.Apply(tc.labels)
+		for label, want := range tc.want {
+			if got := tc.labels[label]; got != want {
+				t.Fatalf("%v: %s = %q, want %q", tc.labels, label, got, want)
+			}
+		}
+	}
+	if got := c.Targets(); len(got) != 2 || got[0] != "region" || got[1] != "pod_group" {
+		t.Fatalf("unexpected targets: %v", got)
+	}
+
+	invalid := []domain.CategoryRule{
+		{Source: "instance", Target: "pod", Match: []domain.CategoryMatch{{CIDR: "10.0.0.0/8", Category: "a"}}},
+		{Source: "instance", Target: "region", Match: []domain.CategoryMatch{{CIDR: "10.0.0.0/33", Category: "a"}}},
+		{Source: "instance", Target: "region", Match: []domain.CategoryMatch{{CIDR: "10.0.0.0/8", Regex: "x", Category: "a"}}},
+		{Source: "instance", Target: "region", Match: []domain.CategoryMatch{{Regex: "(", Category: "a"}}},
+	}
+	for _, rule := range invalid {
+		config.CategoryLabels = []domain.CategoryRule{rule}
+		if _, err := NewCategorizer(config); err == nil {
+			t.Fatalf("expected rule %+v to be rejected", rule)
+		}
+	}
+}
@@ -8,8 +8,28 @@ import (
 	"sync"
 )
 
-// Obfuscator handles data obfuscation for sensitive labels
-type Obfuscator struct {
+// Obfuscator pseudonymizes sensitive label values consistently within one export.
+// Implementations must return the same output for the same input and be safe for concurrent use.
+type Obfuscator interface {
+	ObfuscateInstance(instance string) string
+	ObfuscateJob(job string, component string) string
+	ObfuscateCustomLabel(labelName, value string) string
+	// GetMappings returns copies of the instance and job mappings (original -> obfuscated)
+	GetMappings() (instanceMap, jobMap map[string]string)
+}
+
+// Factory creates a fresh Obfuscator for each export
+type Factory func() Obfuscator
+
+// DefaultFactory creates the built-in obfuscator
+func DefaultFactory() Obfuscator {
+	return NewObfuscator()
+}
+
+var _ Obfuscator = (*DefaultObfuscator)(nil)
+
+// DefaultObfuscator handles data obfuscation for sensitive labels
+type DefaultObfuscator struct {
 	instanceMap  map[string]string            // original -> obfuscated
 	jobMap       map[string]string            // original -> obfuscated
 	customLabels map[string]map[string]string // label -> (original -> obfuscated)
@@ -21,8 +41,8 @@ type Obfuscator struct {
 }
 
 // NewObfuscator creates a new obfuscator
-func NewObfuscator() *Obfuscator {
-	return &Obfuscator{
+func NewObfuscator() *DefaultObfuscator {
+	return &DefaultObfuscator{
 		instanceMap:    make(map[string]string),
 		jobMap:         make(map[string]string),
 		customLabels:   make(map[string]map[string]string),
@@ -32,8 +52,9 @@ func NewObfuscator() *Obfuscator {
 }
 
 // ObfuscateInstance obfuscates instance label (IP:PORT)
-// Uses obviously fake IP pool (777.777.x.x) to make obfuscation clear
-func (o *Obfuscator) ObfuscateInstance(instance string) string {
+// Uses obviously fake IP pool (777.777.x.x) to make obfuscation clear.
+// A bare host or IP without a port gets a pool IP without a port.
+func (o *DefaultObfuscator) ObfuscateInstance(instance string) string {
 	o.mu.Lock()
 	defer o.mu.Unlock()
 
@@ -42,10 +63,10 @@ func (o *Obfuscator) ObfuscateInstance(instance string) string {
 		return obf
 	}
 
-	// Parse host and port
-	_, port, err := net.SplitHostPort(instance)
-	if err != nil {
-		// If cannot parse, use simple hash
+	// Parse host and port; bare hosts, IPs and IPv6 literals fail to split and have no port
+	_, port, _ := net.SplitHostPort(instance)
+	if instance == "" {
+		// Nothing to pseudonymize, use simple hash
 		obfuscated := o.hashString(instance)
 		o.instanceMap[instance] = obfuscated
 		return obfuscated
@@ -58,8 +79,11 @@ func (o *Obfuscator) ObfuscateInstance(instance string) string {
 	fourthOctet := ((o.instanceCounter - 1) % 255) + 1
 	newIP := fmt.Sprintf("777.777.%d.%d", thirdOctet, fourthOctet)
 
-	// Reconstruct with original port
-	obfuscated := net.JoinHostPort(newIP, port)
+	// Reconstruct with original port, if there was one
+	obfuscated := newIP
+	if port != "" {
+		obfuscated = net.JoinHostPort(newIP, port)
+	}
 	o.instanceMap[instance] = obfuscated
 
 	return obfuscated
@@ -67,7 +91,7 @@ func (o *Obfuscator) ObfuscateInstance(instance string) string {
 
 // ObfuscateJob obfuscates job name
 // Format: original job -> vm_component_<component>_<N>
-func (o *Obfuscator) ObfuscateJob(job string, component string) string {
+func (o *DefaultObfuscator) ObfuscateJob(job string, component string) string {
 	o.mu.Lock()
 	defer o.mu.Unlock()
 
@@ -86,7 +110,7 @@ func (o *Obfuscator) ObfuscateJob(job string, component string) string {
 
 // ObfuscateCustomLabel obfuscates custom labels (pod, namespace, etc.)
 // Format: <label-type>-<N> (e.g., "pod-1", "namespace-1")
-func (o *Obfuscator) ObfuscateCustomLabel(labelName, value string) string {
+func (o *DefaultObfuscator) ObfuscateCustomLabel(labelName, value string) string {
 	o.mu.Lock()
 	defer o.mu.Unlock()
 
@@ -110,7 +134,7 @@ func (o *Obfuscator) ObfuscateCustomLabel(labelName, value string) string {
 
 // GetMappings returns copies of obfuscation mappings
 // Returns instanceMap (original->obfuscated) and jobMap (original->obfuscated)
-func (o *Obfuscator) GetMappings() (instanceMap, jobMap map[string]string) {
+func (o *DefaultObfuscator) GetMappings() (instanceMap, jobMap map[string]string) {
 	o.mu.RLock()
 	defer o.mu.RUnlock()
 
@@ -130,7 +154,7 @@ func (o *Obfuscator) GetMappings() (instanceMap, jobMap map[string]string) {
 
 // hashString creates a deterministic hash of a string
 // Used as fallback when standard obfuscation cannot be applied
-func (o *Obfuscator) hashString(s string) string {
+func (o *DefaultObfuscator) hashString(s string) string {
 	h := sha256.Sum256([]byte(s))
 	return hex.EncodeToString(h[:8]) // Use first 8 bytes (16 hex chars)
 }
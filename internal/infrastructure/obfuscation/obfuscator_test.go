@@ -81,6 +81,32 @@ func TestObfuscator_ObfuscateInstance_PreservesPort(t *testing.T) {
 	}
 }
 
+// TestObfuscator_ObfuscateInstance_WithoutPort tests bare hosts and IPs
+func TestObfuscator_ObfuscateInstance_WithoutPort(t *testing.T) {
+	obf := NewObfuscator()
+
+	inputs := []string{"vmstorage-1", "10.0.1.5", "2001:db8::1", "[::1]", "host.example.com:"}
+	seen := make(map[string]string)
+	for _, input := range inputs {
+		result := obf.ObfuscateInstance(input)
+		if !strings.HasPrefix(result, "777.777.") || strings.Contains(result, ":") {
+			t.Errorf("%q: expected a pool IP without port, got %q", input, result)
+		}
+		if other, dup := seen[result]; dup {
+			t.Errorf("%q and %q map to the same value %q", input, other, result)
+		}
+		seen[result] = input
+		if again := obf.ObfuscateInstance(input); again != result {
+			t.Errorf("%q: not deterministic: %q then %q", input, result, again)
+		}
+	}
+
+	// The same host with a port is a different instance and keeps its port
+	if result := obf.ObfuscateInstance("10.0.1.5:8482"); !strings.HasSuffix(result, ":8482") || seen[strings.TrimSuffix(result, ":8482")] != "" {
+		t.Errorf("expected a new pool IP with port 8482, got %q", result)
+	}
+}
+
 // TestObfuscator_ObfuscateInstance_UsesObviousFakeIP tests obviously fake IP range (777.777.x.x)
 func TestObfuscator_ObfuscateInstance_UsesObviousFakeIP(t *testing.T) {
 	obf := NewObfuscator()
@@ -0,0 +1,126 @@
+package upload
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+)
+
+// putTimeout bounds a single archive upload
+const putTimeout = 30 * time.Minute
+
+// ValidateTarget rejects upload URLs that are not absolute http(s) URLs. Credentials belong in
+// auth, so URLs with user info are refused as well.
+func ValidateTarget(target *domain.UploadTarget) error {
+	if target == nil {
+		return nil
+	}
+	u, err := url.Parse(target.URL)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("upload.url must be an absolute http(s) URL, got %q", target.URL)
+	}
+	if u.User != nil {
+		return fmt.Errorf("upload.url must not contain credentials; use upload.auth")
+	}
+	return nil
+}
+
+// ArchiveURL is where archiveName is stored: the collection URL plus the name, or the URL itself
+func ArchiveURL(target domain.UploadTarget, archiveName string) (*url.URL, error) {
+	u, err := url.Parse(target.URL)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(u.Path, "/") {
+		u.Path = path.Join(u.Path, archiveName)
+		u.RawPath = ""
+	}
+	return u, nil
+}
+
+// PublicURL drops the query string, which may carry a presigned token, and any
+// user:password@ for results and logs
+func PublicURL(u *url.URL) string {
+	public := *u
+	public.User = nil
+	public.RawQuery = ""
+	public.Fragment = ""
+	return public.String()
+}
+
+// PutArchive streams the archive at archivePath to target with HTTP PUT and returns the URL it
+// was stored at. Redirects are not followed, since the body could not be replayed safely.
+func PutArchive(ctx context.Context, target domain.UploadTarget, archivePath string) (string, error) {
+	u, err := ArchiveURL(target, filepath.Base(archivePath))
+	if err != nil {
+		return "", err
+	}
+	public := PublicURL(u)
+
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return public, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+	info, err := file.Stat()
+	if err != nil {
+		return public, fmt.Errorf("failed to stat archive: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, putTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.String(), file)
+	if err != nil {
+		return public, fmt.Errorf("failed to build upload request: %w", err)
+	}
+	req.ContentLength = info.Size()
+	req.Header.Set("Content-Type", "application/zip")
+	applyAuth(req, target.Auth)
+
+	resp, err := newClient(target.SkipTLSVerify, "archive upload").Do(req)
+	if err != nil {
+		return public, fmt.Errorf("upload request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return public, fmt.Errorf("upload target responded %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return public, nil
+}
+
+func newClient(skipTLSVerify bool, purpose string) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if skipTLSVerify {
+		log.Printf("[WARN] TLS certificate verification is disabled for the %s. Use only in trusted lab/dev environments.", purpose)
+		transport.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: true} // #nosec G402 -- explicit user opt-in via skip_tls_verify
+	}
+	return &http.Client{
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func applyAuth(req *http.Request, auth domain.AuthConfig) {
+	switch auth.Type {
+	case domain.AuthTypeBasic:
+		req.SetBasicAuth(auth.Username, auth.Password)
+	case domain.AuthTypeBearer:
+		req.Header.Set("Authorization", "Bearer "+auth.Token)
+	case domain.AuthTypeHeader:
+		req.Header.Set(auth.HeaderName, auth.HeaderValue)
+	}
+}
@@ -0,0 +1,89 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+)
+
+const (
+	// webhookTimeout bounds a single webhook delivery attempt
+	webhookTimeout = 10 * time.Second
+	// webhookAttempts is how often a webhook is tried before giving up
+	webhookAttempts = 3
+)
+
+// webhookRetryDelay is the pause before the second attempt; it doubles for every further attempt
+var webhookRetryDelay = time.Second
+
+// ValidateWebhook rejects webhook URLs that are not absolute http(s) URLs or that carry credentials
+func ValidateWebhook(target *domain.WebhookTarget) error {
+	if target == nil {
+		return nil
+	}
+	u, err := url.Parse(target.URL)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("webhook.url must be an absolute http(s) URL, got %q", target.URL)
+	}
+	if u.User != nil {
+		return fmt.Errorf("webhook.url must not contain credentials; use webhook.auth")
+	}
+	return nil
+}
+
+// PostWebhook POSTs the JSON payload to target. Network errors, 429 and 5xx responses are
+// retried with backoff; any other non-2xx response fails at once. Redirects are not followed.
+func PostWebhook(ctx context.Context, target domain.WebhookTarget, payload []byte) error {
+	client := newClient(target.SkipTLSVerify, "webhook")
+	delay := webhookRetryDelay
+	var lastErr error
+	for attempt := 1; attempt <= webhookAttempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("%w (last error: %v)", ctx.Err(), lastErr)
+			case <-time.After(delay):
+			}
+			delay *= 2
+		}
+		retry, err := postWebhookOnce(ctx, client, target, payload)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if !retry {
+			break
+		}
+	}
+	return lastErr
+}
+
+func postWebhookOnce(ctx context.Context, client *http.Client, target domain.WebhookTarget, payload []byte) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.URL, bytes.NewReader(payload))
+	if err != nil {
+		return false, fmt.Errorf("failed to build webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	applyAuth(req, target.Auth)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return retry, fmt.Errorf("webhook responded %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return false, nil
+}
@@ -1,6 +1,8 @@
 package vm
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"crypto/tls"
 	"encoding/json"
@@ -8,9 +10,11 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"mime"
 	"net"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -47,6 +51,45 @@ type Result struct {
 // ErrMissingTenantPath indicates vmselect URL is missing /select/<tenant>/prometheus
 var ErrMissingTenantPath = errors.New("vmselect requires /select/<tenant>/prometheus")
 
+// ErrRedirectNotAllowed indicates a redirect was blocked by the connection redirect policy
+var ErrRedirectNotAllowed = errors.New("redirect not allowed by redirect policy")
+
+// ErrNoData indicates an export succeeded but the selector matched no series (204 or an empty 200 body)
+var ErrNoData = errors.New("export returned no data")
+
+// ErrUnexpectedExportResponse indicates a 200 export response that is not JSON lines,
+// typically an HTML page from a misrouted proxy or UI path
+var ErrUnexpectedExportResponse = errors.New("unexpected export response")
+
+// exportSniffBytes is how much of an export body is peeked to tell data from no-data or a misroute
+const exportSniffBytes = 512
+
+// ErrResponseTooLarge indicates a query response exceeded QueryOptions.MaxResponseBytes
+var ErrResponseTooLarge = errors.New("response exceeds size limit")
+
+const maxRedirects = 10
+
+// Dialer defaults: fail fast on unreachable hosts and keep idle connections alive through NAT
+const (
+	defaultDialTimeout = 30 * time.Second
+	defaultKeepAlive   = 30 * time.Second
+)
+
+// Request deadlines used when request_timeout_seconds is not set. Exports stream for as long as
+// the caller's context allows, so only requests whose whole response is read at once are bounded.
+const (
+	defaultQueryTimeout      = 30 * time.Second // instant queries
+	defaultQueryRangeTimeout = 2 * time.Minute  // query_range, which evaluates a whole chunk at once
+)
+
+// QueryOptions bounds an instant query so callers can keep cheap probes cheap on large clusters
+type QueryOptions struct {
+	// Limit is sent as the `limit` query arg and also enforced client-side for backends that ignore it
+	Limit int
+	// MaxResponseBytes stops decoding once the response body grows past this size; 0 means unlimited
+	MaxResponseBytes int64
+}
+
 var insecureTLSWarnOnce sync.Once
 
 // HintForError returns a human-friendly hint for common VM connection errors
@@ -60,7 +103,7 @@ func HintForError(err error) string {
 // ExportedMetric represents a metric in export format (JSONL)
 type ExportedMetric struct {
 	Metric     map[string]string `json:"metric"`
-	Values     []interface{}     `json:"values"`
+	Values     SampleValues      `json:"values"`
 	Timestamps []int64           `json:"timestamps"`
 }
 
@@ -69,15 +112,14 @@ func NewClient(conn domain.VMConnection) *Client {
 	// Prefer IPv4 for localhost, since Docker/OrbStack port-forwards are often bound
 	// only to 127.0.0.1 and not to ::1. This prevents flaky "dial tcp [::1]:PORT:
 	// connect: connection refused" errors when users provide http://localhost:PORT.
-	dialer := &net.Dialer{
-		Timeout:   30 * time.Second,
-		KeepAlive: 30 * time.Second,
-	}
+	dialer := newDialer(conn)
 
 	transport := &http.Transport{
 		MaxIdleConns:        100,
 		MaxIdleConnsPerHost: 100,
 		IdleConnTimeout:     90 * time.Second,
+		// Like http.DefaultTransport: negotiate HTTP/2 over TLS, which a custom dialer would otherwise turn off
+		ForceAttemptHTTP2: !conn.ForceHTTP1,
 		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
 			host, _, err := net.SplitHostPort(addr)
 			if err == nil && host == "localhost" {
@@ -97,15 +139,102 @@ func NewClient(conn domain.VMConnection) *Client {
 		})
 		transport.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: true} // #nosec G402 -- explicit user opt-in via skip_tls_verify
 	}
+	if conn.ForceHTTP1 {
+		// A non-nil empty map keeps the transport from ever upgrading to HTTP/2
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
 
 	return &Client{
 		httpClient: &http.Client{
-			Transport: transport,
+			Transport:     transport,
+			CheckRedirect: redirectPolicyFunc(conn),
 		},
 		conn: conn,
 	}
 }
 
+// newDialer builds the TCP dialer from the connection's dial timeout and keepalive settings
+func newDialer(conn domain.VMConnection) *net.Dialer {
+	dialer := &net.Dialer{
+		Timeout:   defaultDialTimeout,
+		KeepAlive: defaultKeepAlive,
+	}
+	if conn.DialTimeoutSec > 0 {
+		dialer.Timeout = time.Duration(conn.DialTimeoutSec) * time.Second
+	}
+	if conn.KeepAliveSec > 0 {
+		dialer.KeepAlive = time.Duration(conn.KeepAliveSec) * time.Second
+	} else if conn.KeepAliveSec < 0 {
+		dialer.KeepAlive = -1
+	}
+	return dialer
+}
+
+// redirectPolicyFunc enforces the connection redirect policy so credentials never follow
+// a redirect to a host the user did not configure.
+func redirectPolicyFunc(conn domain.VMConnection) func(req *http.Request, via []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxRedirects)
+		}
+		if conn.RedirectPolicy == domain.RedirectPolicyNone {
+			return fmt.Errorf("%w: redirect to %s", ErrRedirectNotAllowed, redactURL(req.URL.String()))
+		}
+		if SameOrigin(via[0].URL, req.URL) {
+			return nil
+		}
+		if conn.RedirectPolicy == domain.RedirectPolicyFollow {
+			stripAuthHeaders(req, conn)
+			return nil
+		}
+		return fmt.Errorf("%w: cross-host redirect to %s", ErrRedirectNotAllowed, redactURL(req.URL.String()))
+	}
+}
+
+// SameOrigin reports whether both URLs share scheme, hostname and port, i.e. whether credentials
+// meant for one may be sent to the other. A missing port counts as the scheme's default one.
+func SameOrigin(a, b *url.URL) bool {
+	return strings.EqualFold(a.Scheme, b.Scheme) &&
+		strings.EqualFold(a.Hostname(), b.Hostname()) &&
+		originPort(a) == originPort(b)
+}
+
+// originPort returns the port of u, defaulting to 80 for http and 443 for https
+func originPort(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return port
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http":
+		return "80"
+	case "https":
+		return "443"
+	}
+	return ""
+}
+
+// stripAuthHeaders removes credentials and passthrough headers, which may carry gateway tokens
+func stripAuthHeaders(req *http.Request, conn domain.VMConnection) {
+	req.Header.Del("Authorization")
+	req.Header.Del("Cookie")
+	if conn.Auth.Type == domain.AuthTypeHeader && conn.Auth.HeaderName != "" {
+		req.Header.Del(conn.Auth.HeaderName)
+	}
+	for name := range conn.Headers {
+		req.Header.Del(name)
+	}
+}
+
+// withRequestTimeout bounds one non-streaming request by the connection's request timeout,
+// or by fallback when none is set
+func (c *Client) withRequestTimeout(ctx context.Context, fallback time.Duration) (context.Context, context.CancelFunc) {
+	timeout := fallback
+	if c.conn.RequestTimeoutSec > 0 {
+		timeout = time.Duration(c.conn.RequestTimeoutSec) * time.Second
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
 // NewClientWithTransport creates a new client with a custom transport.
 //
 // This is primarily used for deterministic unit tests, where callers want to
@@ -120,10 +249,21 @@ func NewClientWithTransport(conn domain.VMConnection, transport http.RoundTrippe
 
 // Query executes an instant PromQL query
 func (c *Client) Query(ctx context.Context, query string, ts time.Time) (*QueryResult, error) {
+	return c.QueryWithOptions(ctx, query, ts, QueryOptions{})
+}
+
+// QueryWithOptions executes an instant PromQL query with a series limit and a response size cap
+func (c *Client) QueryWithOptions(ctx context.Context, query string, ts time.Time, opts QueryOptions) (*QueryResult, error) {
+	ctx, cancel := c.withRequestTimeout(ctx, defaultQueryTimeout)
+	defer cancel()
+
 	// Build query parameters
 	params := url.Values{}
 	params.Set("query", query)
 	params.Set("time", fmt.Sprintf("%d", ts.Unix()))
+	if opts.Limit > 0 {
+		params.Set("limit", fmt.Sprintf("%d", opts.Limit))
+	}
 
 	// Build request
 	req, err := c.buildRequest(ctx, http.MethodGet, "/api/v1/query", params)
@@ -131,8 +271,8 @@ func (c *Client) Query(ctx context.Context, query string, ts time.Time) (*QueryR
 		return nil, fmt.Errorf("failed to build request: %w", err)
 	}
 
-	// Execute request
-	resp, err := c.httpClient.Do(req)
+	// Execute request, retrying transient failures
+	resp, err := c.doWithRetry(req)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
@@ -144,28 +284,54 @@ func (c *Client) Query(ctx context.Context, query string, ts time.Time) (*QueryR
 		return nil, classifyResponseError(resp.StatusCode, string(body))
 	}
 
-	// Parse response
+	// Parse response, reading at most one byte past the cap so oversized bodies are detected
+	var body io.Reader = resp.Body
+	var limited *io.LimitedReader
+	if opts.MaxResponseBytes > 0 {
+		limited = &io.LimitedReader{R: resp.Body, N: opts.MaxResponseBytes + 1}
+		body = limited
+	}
 	var result QueryResult
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	decodeErr := json.NewDecoder(body).Decode(&result)
+	if limited != nil && limited.N <= 0 {
+		return nil, fmt.Errorf("%w: more than %d bytes", ErrResponseTooLarge, opts.MaxResponseBytes)
+	}
+	if decodeErr != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", decodeErr)
 	}
 
 	// Check API status
 	if result.Status != "success" {
 		return nil, fmt.Errorf("API error: %s", result.Error)
 	}
+	if opts.Limit > 0 && len(result.Data.Result) > opts.Limit {
+		result.Data.Result = result.Data.Result[:opts.Limit]
+	}
 
 	return &result, nil
 }
 
 // QueryRange executes a range PromQL query
 func (c *Client) QueryRange(ctx context.Context, query string, start, end time.Time, step time.Duration) (*QueryResult, error) {
+	return c.QueryRangeWithLookbehind(ctx, query, start, end, step, 0)
+}
+
+// QueryRangeWithLookbehind executes a range query whose points only look back lookbehind for a raw
+// sample (sent as `max_lookback`), so a sample is not repeated across steps further than that.
+// Zero keeps the server default.
+func (c *Client) QueryRangeWithLookbehind(ctx context.Context, query string, start, end time.Time, step, lookbehind time.Duration) (*QueryResult, error) {
+	ctx, cancel := c.withRequestTimeout(ctx, defaultQueryRangeTimeout)
+	defer cancel()
+
 	// Build query parameters
 	params := url.Values{}
 	params.Set("query", query)
-	params.Set("start", fmt.Sprintf("%d", start.Unix()))
-	params.Set("end", fmt.Sprintf("%d", end.Unix()))
+	params.Set("start", rangeParamUnix(start))
+	params.Set("end", rangeParamUnix(end))
 	params.Set("step", fmt.Sprintf("%ds", int(step.Seconds())))
+	if lookbehind > 0 {
+		params.Set("max_lookback", fmt.Sprintf("%ds", int(lookbehind.Seconds())))
+	}
 
 	// Build request
 	req, err := c.buildRequest(ctx, http.MethodGet, "/api/v1/query_range", params)
@@ -174,7 +340,7 @@ func (c *Client) QueryRange(ctx context.Context, query string, start, end time.T
 	}
 
 	// Execute request
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.doExportRequest(req)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
@@ -186,6 +352,12 @@ func (c *Client) QueryRange(ctx context.Context, query string, start, end time.T
 		return nil, classifyResponseError(resp.StatusCode, string(body))
 	}
 
+	// query_range is the fallback export path, so a gateway's HTML page gets the same clear error
+	if contentType := resp.Header.Get("Content-Type"); isHTMLContentType(contentType) {
+		peeked, _ := io.ReadAll(io.LimitReader(resp.Body, exportSniffBytes))
+		return nil, unexpectedResponseError(contentType, bytes.TrimLeft(peeked, " \t\r\n"))
+	}
+
 	// Parse response
 	var result QueryResult
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
@@ -200,14 +372,168 @@ func (c *Client) QueryRange(ctx context.Context, query string, start, end time.T
 	return &result, nil
 }
 
+// SeriesResult represents the /api/v1/series response
+type SeriesResult struct {
+	Status string              `json:"status"`
+	Data   []map[string]string `json:"data"`
+	Error  string              `json:"error,omitempty"`
+}
+
+// SeriesOptions narrows a series listing on the server
+type SeriesOptions struct {
+	Limit        int      // Return at most this many series; 0 returns all
+	ExtraFilters []string // Series must also match one of these selectors (VictoriaMetrics extra_filters[])
+}
+
+// Series returns label sets of all series matching the selector within the time range
+func (c *Client) Series(ctx context.Context, selector string, start, end time.Time) ([]map[string]string, error) {
+	return c.SeriesWithOptions(ctx, selector, start, end, SeriesOptions{})
+}
+
+// SeriesWithOptions returns label sets of series matching the selector within the time range,
+// narrowed by opts. Requests with extra filters are sent as POST, since the filters can make
+// the query string too long for proxies.
+func (c *Client) SeriesWithOptions(ctx context.Context, selector string, start, end time.Time, opts SeriesOptions) ([]map[string]string, error) {
+	params := url.Values{}
+	params.Set("match[]", selector)
+	params.Set("start", fmt.Sprintf("%d", start.Unix()))
+	params.Set("end", fmt.Sprintf("%d", end.Unix()))
+	if opts.Limit > 0 {
+		params.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	method := http.MethodGet
+	if len(opts.ExtraFilters) > 0 {
+		params["extra_filters[]"] = opts.ExtraFilters
+		method = http.MethodPost
+	}
+
+	req, err := c.buildRequest(ctx, method, "/api/v1/series", params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	if method == http.MethodPost {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	resp, err := c.doWithRetry(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, classifyResponseError(resp.StatusCode, string(body))
+	}
+
+	var result SeriesResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if result.Status != "success" {
+		return nil, fmt.Errorf("API error: %s", result.Error)
+	}
+
+	return result.Data, nil
+}
+
+// LabelsResult represents the /api/v1/labels response
+type LabelsResult struct {
+	Status string   `json:"status"`
+	Data   []string `json:"data"`
+	Error  string   `json:"error,omitempty"`
+}
+
+// Labels returns the label names of series matching the selector within the time range
+func (c *Client) Labels(ctx context.Context, selector string, start, end time.Time) ([]string, error) {
+	params := url.Values{}
+	params.Set("match[]", selector)
+	params.Set("start", fmt.Sprintf("%d", start.Unix()))
+	params.Set("end", fmt.Sprintf("%d", end.Unix()))
+
+	req, err := c.buildRequest(ctx, http.MethodGet, "/api/v1/labels", params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+
+	resp, err := c.doWithRetry(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, classifyResponseError(resp.StatusCode, string(body))
+	}
+
+	var result LabelsResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if result.Status != "success" {
+		return nil, fmt.Errorf("API error: %s", result.Error)
+	}
+
+	return result.Data, nil
+}
+
+// LabelValues returns the values of label name on series matching the selector within the time range
+func (c *Client) LabelValues(ctx context.Context, name, selector string, start, end time.Time) ([]string, error) {
+	params := url.Values{}
+	params.Set("match[]", selector)
+	params.Set("start", fmt.Sprintf("%d", start.Unix()))
+	params.Set("end", fmt.Sprintf("%d", end.Unix()))
+
+	req, err := c.buildRequest(ctx, http.MethodGet, "/api/v1/label/"+url.PathEscape(name)+"/values", params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+
+	resp, err := c.doWithRetry(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, classifyResponseError(resp.StatusCode, string(body))
+	}
+
+	var result LabelsResult // /api/v1/label/<name>/values has the same shape
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if result.Status != "success" {
+		return nil, fmt.Errorf("API error: %s", result.Error)
+	}
+
+	return result.Data, nil
+}
+
+// rangeParamUnix formats a range bound as Unix seconds with millisecond precision. Export and
+// query_range both include samples at start and end, so the bounds must not be rounded to seconds
+// for the two paths to return the same points; whole seconds are formatted without a fraction.
+func rangeParamUnix(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixMilli())/1000, 'f', -1, 64)
+}
+
 // Export executes metrics export via /api/v1/export endpoint
 // Returns a reader for streaming JSONL data
 func (c *Client) Export(ctx context.Context, selector string, start, end time.Time) (io.ReadCloser, error) {
+	return c.ExportMatches(ctx, []string{selector}, start, end)
+}
+
+// ExportMatches executes metrics export for several series selectors in a single request
+func (c *Client) ExportMatches(ctx context.Context, selectors []string, start, end time.Time) (io.ReadCloser, error) {
 	// Build query parameters
 	params := url.Values{}
-	params.Set("match[]", selector)
-	params.Set("start", start.Format(time.RFC3339))
-	params.Set("end", end.Format(time.RFC3339))
+	for _, selector := range selectors {
+		params.Add("match[]", selector)
+	}
+	params.Set("start", start.Format(time.RFC3339Nano))
+	params.Set("end", end.Format(time.RFC3339Nano))
 
 	// Build request
 	req, err := c.buildRequest(ctx, http.MethodPost, "/api/v1/export", params)
@@ -219,22 +545,106 @@ func (c *Client) Export(ctx context.Context, selector string, start, end time.Ti
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
 	// Execute request
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.doExportRequest(req)
 	if err != nil {
 		return nil, fmt.Errorf("export request failed: %w", err)
 	}
 
 	// Check status code
+	if resp.StatusCode == http.StatusNoContent {
+		_ = resp.Body.Close()
+		return nil, ErrNoData
+	}
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		_ = resp.Body.Close()
 		return nil, classifyResponseError(resp.StatusCode, string(body))
 	}
 
-	// Return response body for streaming
+	// Return response body for streaming, after checking it actually carries JSON lines
+	return checkExportBody(resp)
+}
+
+// ExportNative executes an export via /api/v1/export/native. The body is VictoriaMetrics' binary
+// native format and is returned as is, for /api/v1/import/native; it cannot be decoded here.
+func (c *Client) ExportNative(ctx context.Context, selectors []string, start, end time.Time) (io.ReadCloser, error) {
+	params := url.Values{}
+	for _, selector := range selectors {
+		params.Add("match[]", selector)
+	}
+	params.Set("start", start.Format(time.RFC3339Nano))
+	params.Set("end", end.Format(time.RFC3339Nano))
+
+	req, err := c.buildRequest(ctx, http.MethodPost, "/api/v1/export/native", params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.doExportRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("native export request failed: %w", err)
+	}
+	if resp.StatusCode == http.StatusNoContent {
+		_ = resp.Body.Close()
+		return nil, ErrNoData
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		return nil, classifyResponseError(resp.StatusCode, string(body))
+	}
 	return resp.Body, nil
 }
 
+// checkExportBody peeks at the start of an export body: empty means no data, an HTML content
+// type or anything that does not start with a JSON object means the request hit the wrong endpoint.
+func checkExportBody(resp *http.Response) (io.ReadCloser, error) {
+	buffered := bufio.NewReaderSize(resp.Body, exportSniffBytes)
+	peeked, err := buffered.Peek(exportSniffBytes)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("failed to read export response: %w", err)
+	}
+	trimmed := bytes.TrimLeft(peeked, " \t\r\n")
+	if len(trimmed) == 0 && len(peeked) < exportSniffBytes {
+		_ = resp.Body.Close()
+		return nil, ErrNoData
+	}
+	if len(trimmed) > 0 && (trimmed[0] != '{' || isHTMLContentType(resp.Header.Get("Content-Type"))) {
+		_ = resp.Body.Close()
+		return nil, unexpectedResponseError(resp.Header.Get("Content-Type"), trimmed)
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{buffered, resp.Body}, nil
+}
+
+// isHTMLContentType reports whether a response declares itself an HTML page
+func isHTMLContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
+
+// unexpectedResponseError describes a 200 response that is not metrics data, typically a login
+// or error page from a misconfigured gateway, with the start of the body to help find it
+func unexpectedResponseError(contentType string, body []byte) error {
+	kind := "text"
+	if isHTMLContentType(contentType) || bytes.HasPrefix(body, []byte("<")) {
+		kind = "HTML"
+	}
+	snippet := strings.TrimSpace(string(body))
+	if len(snippet) > 120 {
+		snippet = snippet[:120] + "..."
+	}
+	return fmt.Errorf("%w: expected metrics stream but got %s (content-type %q); check the URL and any proxy or gateway in front of VictoriaMetrics: %s",
+		ErrUnexpectedExportResponse, kind, contentType, snippet)
+}
+
 // buildRequest builds an HTTP request with authentication
 func (c *Client) buildRequest(ctx context.Context, method, path string, params url.Values) (*http.Request, error) {
 	// Build URL logic
@@ -259,7 +669,7 @@ func (c *Client) buildRequest(ctx context.Context, method, path string, params u
 	}
 
 	// Append the API endpoint path
-	reqURL := baseURL + path
+	reqURL := joinRequestURL(baseURL, path)
 	if len(params) > 0 {
 		if method == http.MethodGet {
 			reqURL += "?" + params.Encode()
@@ -295,9 +705,38 @@ func (c *Client) buildRequest(ctx context.Context, method, path string, params u
 		// No authentication
 	}
 
+	// Passthrough headers never replace the computed auth headers
+	for name, value := range c.conn.Headers {
+		if req.Header.Get(name) != "" {
+			continue
+		}
+		req.Header.Set(name, value)
+	}
+
 	return req, nil
 }
 
+// joinRequestURL appends path to base and collapses accidental double slashes in the URL path,
+// e.g. from a trailing slash in FullApiUrl. The "//" after the scheme and any query are kept.
+func joinRequestURL(base, path string) string {
+	joined := base
+	if path != "" {
+		joined = strings.TrimRight(base, "/") + "/" + strings.TrimLeft(path, "/")
+	}
+	head, rest := "", joined
+	if i := strings.Index(joined, "://"); i >= 0 {
+		head, rest = joined[:i+3], joined[i+3:]
+	}
+	tail := ""
+	if i := strings.IndexAny(rest, "?#"); i >= 0 {
+		rest, tail = rest[:i], rest[i:]
+	}
+	for strings.Contains(rest, "//") {
+		rest = strings.ReplaceAll(rest, "//", "/")
+	}
+	return head + rest + tail
+}
+
 func classifyResponseError(statusCode int, body string) error {
 	trimmed := strings.TrimSpace(body)
 	lowered := strings.ToLower(trimmed)
@@ -4,8 +4,11 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
 	"time"
@@ -204,9 +207,11 @@ func TestClient_Query_WithRedirect(t *testing.T) {
 	}))
 	defer redirectServer.Close()
 
+	// The target listens on another port, so it is a different origin
 	conn := domain.VMConnection{
-		URL:  redirectServer.URL,
-		Auth: domain.AuthConfig{Type: domain.AuthTypeNone},
+		URL:            redirectServer.URL,
+		Auth:           domain.AuthConfig{Type: domain.AuthTypeNone},
+		RedirectPolicy: domain.RedirectPolicyFollow,
 	}
 
 	client := NewClient(conn)
@@ -218,6 +223,178 @@ func TestClient_Query_WithRedirect(t *testing.T) {
 	}
 }
 
+func newRedirectTargetServer(t *testing.T, seen *http.Header) *httptest.Server {
+	t.Helper()
+	return newIPv4TestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*seen = r.Header.Clone()
+		resp := QueryResult{
+			Status: "success",
+			Data:   QueryData{ResultType: "vector", Result: []Result{}},
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+}
+
+// TestClient_Query_RedirectSameHostKeepsAuth tests that same-origin redirects are followed with credentials
+func TestClient_Query_RedirectSameHostKeepsAuth(t *testing.T) {
+	var seen http.Header
+	targetServer := newRedirectTargetServer(t, &seen)
+	defer targetServer.Close()
+
+	redirectServer := newIPv4TestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/old/") {
+			http.Redirect(w, r, strings.TrimPrefix(r.URL.Path, "/old"), http.StatusFound)
+			return
+		}
+		targetServer.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer redirectServer.Close()
+
+	client := NewClient(domain.VMConnection{
+		URL:  redirectServer.URL + "/old",
+		Auth: domain.AuthConfig{Type: domain.AuthTypeHeader, HeaderName: "X-Api-Key", HeaderValue: "secret"},
+	})
+
+	if _, err := client.Query(context.Background(), "test", time.Now()); err != nil {
+		t.Fatalf("query failed after same-host redirect: %v", err)
+	}
+	if got := seen.Get("X-Api-Key"); got != "secret" {
+		t.Fatalf("expected auth header on same-host redirect, got %q", got)
+	}
+}
+
+// TestClient_Query_RedirectOtherPortRejectedByDefault tests that a redirect to another port of the same host is cross-origin
+func TestClient_Query_RedirectOtherPortRejectedByDefault(t *testing.T) {
+	var seen http.Header
+	targetServer := newRedirectTargetServer(t, &seen)
+	defer targetServer.Close()
+
+	redirectServer := newIPv4TestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, targetServer.URL+r.URL.Path, http.StatusFound)
+	}))
+	defer redirectServer.Close()
+
+	client := NewClient(domain.VMConnection{
+		URL:  redirectServer.URL,
+		Auth: domain.AuthConfig{Type: domain.AuthTypeHeader, HeaderName: "X-Api-Key", HeaderValue: "secret"},
+	})
+
+	_, err := client.Query(context.Background(), "test", time.Now())
+	if !errors.Is(err, ErrRedirectNotAllowed) {
+		t.Fatalf("expected ErrRedirectNotAllowed, got %v", err)
+	}
+	if seen != nil {
+		t.Fatal("a different port must not be contacted")
+	}
+}
+
+// TestSameOrigin tests that ports are compared with the scheme's default filled in
+func TestSameOrigin(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{a: "https://vm.example.com/select", b: "https://VM.example.com:443/other", want: true},
+		{a: "http://vm.example.com", b: "http://vm.example.com:80", want: true},
+		{a: "http://vm.example.com:8481", b: "http://vm.example.com:8481/vmalert", want: true},
+		{a: "http://vm.example.com:8481", b: "http://vm.example.com:8880", want: false},
+		{a: "http://vm.example.com", b: "http://vm.example.com:8080", want: false},
+		{a: "http://vm.example.com", b: "https://vm.example.com", want: false},
+		{a: "https://vm.example.com", b: "https://other.example.com", want: false},
+	}
+	for _, tt := range tests {
+		a, _ := url.Parse(tt.a)
+		b, _ := url.Parse(tt.b)
+		if got := SameOrigin(a, b); got != tt.want {
+			t.Errorf("SameOrigin(%s, %s) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+// TestClient_Query_RedirectCrossHostRejectedByDefault tests that the default policy refuses cross-host redirects
+func TestClient_Query_RedirectCrossHostRejectedByDefault(t *testing.T) {
+	var seen http.Header
+	targetServer := newRedirectTargetServer(t, &seen)
+	defer targetServer.Close()
+	crossHostURL := strings.Replace(targetServer.URL, "127.0.0.1", "localhost", 1)
+
+	redirectServer := newIPv4TestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, crossHostURL+r.URL.Path, http.StatusFound)
+	}))
+	defer redirectServer.Close()
+
+	client := NewClient(domain.VMConnection{
+		URL:  redirectServer.URL,
+		Auth: domain.AuthConfig{Type: domain.AuthTypeBearer, Token: "secret"},
+	})
+
+	_, err := client.Query(context.Background(), "test", time.Now())
+	if !errors.Is(err, ErrRedirectNotAllowed) {
+		t.Fatalf("expected ErrRedirectNotAllowed, got %v", err)
+	}
+	if seen != nil {
+		t.Fatal("cross-host target must not be contacted")
+	}
+}
+
+// TestClient_Query_RedirectCrossHostStripsAuth tests that the follow policy drops credentials on cross-host hops
+func TestClient_Query_RedirectCrossHostStripsAuth(t *testing.T) {
+	var seen http.Header
+	targetServer := newRedirectTargetServer(t, &seen)
+	defer targetServer.Close()
+	crossHostURL := strings.Replace(targetServer.URL, "127.0.0.1", "localhost", 1)
+
+	redirectServer := newIPv4TestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, crossHostURL+r.URL.Path, http.StatusFound)
+	}))
+	defer redirectServer.Close()
+
+	for _, auth := range []domain.AuthConfig{
+		{Type: domain.AuthTypeBasic, Username: "user", Password: "pass"},
+		{Type: domain.AuthTypeBearer, Token: "secret"},
+		{Type: domain.AuthTypeHeader, HeaderName: "X-Api-Key", HeaderValue: "secret"},
+	} {
+		seen = nil
+		client := NewClient(domain.VMConnection{
+			URL:            redirectServer.URL,
+			Auth:           auth,
+			RedirectPolicy: domain.RedirectPolicyFollow,
+		})
+		if _, err := client.Query(context.Background(), "test", time.Now()); err != nil {
+			t.Fatalf("%s: query failed after cross-host redirect: %v", auth.Type, err)
+		}
+		if seen.Get("Authorization") != "" || seen.Get("X-Api-Key") != "" {
+			t.Fatalf("%s: credentials leaked to cross-host target: %v", auth.Type, seen)
+		}
+	}
+}
+
+// TestClient_Query_RedirectDisabled tests that the none policy never follows redirects
+func TestClient_Query_RedirectDisabled(t *testing.T) {
+	var seen http.Header
+	targetServer := newRedirectTargetServer(t, &seen)
+	defer targetServer.Close()
+
+	redirectServer := newIPv4TestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, targetServer.URL+r.URL.Path, http.StatusFound)
+	}))
+	defer redirectServer.Close()
+
+	client := NewClient(domain.VMConnection{
+		URL:            redirectServer.URL,
+		Auth:           domain.AuthConfig{Type: domain.AuthTypeNone},
+		RedirectPolicy: domain.RedirectPolicyNone,
+	})
+
+	_, err := client.Query(context.Background(), "test", time.Now())
+	if !errors.Is(err, ErrRedirectNotAllowed) {
+		t.Fatalf("expected ErrRedirectNotAllowed, got %v", err)
+	}
+	if seen != nil {
+		t.Fatal("redirect target must not be contacted")
+	}
+}
+
 // TestClient_Query_WithRateLimiting tests 429 rate limit response
 func TestClient_Query_WithRateLimiting(t *testing.T) {
 	callCount := 0
@@ -249,14 +426,17 @@ func TestClient_Query_WithRateLimiting(t *testing.T) {
 
 	client := NewClient(conn)
 
-	// First call should fail with 429
+	// The 429 is retried once after the Retry-After wait
+	start := time.Now()
 	_, err := client.Query(context.Background(), "test", time.Now())
-	if err == nil {
-		t.Fatal("expected error on rate limit")
+	if err != nil {
+		t.Fatalf("expected the query to succeed after Retry-After, got %v", err)
 	}
-
-	if !strings.Contains(err.Error(), "429") {
-		t.Errorf("error should mention 429: %v", err)
+	if callCount != 2 {
+		t.Errorf("expected 2 calls, got %d", callCount)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("expected to wait for Retry-After (1s), waited %v", elapsed)
 	}
 }
 
@@ -401,3 +581,34 @@ func TestClient_Export_GzipCompression(t *testing.T) {
 		t.Error("no data received")
 	}
 }
+
+// TestClient_Export_SendsPassthroughHeaders tests that custom headers reach VM without replacing auth
+func TestClient_Export_SendsPassthroughHeaders(t *testing.T) {
+	server := newIPv4TestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("X-Route-To"); got != "cluster-b" {
+			t.Errorf("expected passthrough header, got %q", got)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer real-token" {
+			t.Errorf("passthrough header must not override auth, got %q", got)
+		}
+		if got := r.Header.Get("Content-Type"); got != "application/x-www-form-urlencoded" {
+			t.Errorf("passthrough header must not override content type, got %q", got)
+		}
+		_, _ = io.WriteString(w, `{"metric":{"__name__":"up"},"values":[1],"timestamps":[1]}`+"\n")
+	}))
+	defer server.Close()
+
+	client := NewClient(domain.VMConnection{
+		URL:  server.URL,
+		Auth: domain.AuthConfig{Type: domain.AuthTypeBearer, Token: "real-token"},
+		Headers: map[string]string{
+			"X-Route-To":    "cluster-b",
+			"Authorization": "Bearer spoofed",
+		},
+	})
+	body, err := client.Export(context.Background(), `{__name__="up"}`, time.Now().Add(-time.Minute), time.Now())
+	if err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	_ = body.Close()
+}
@@ -33,6 +33,20 @@ func TestBuildRequest_PathNormalization(t *testing.T) {
 			shouldContain:    "/prometheus/api/v1/export",
 			shouldNotContain: "/rw/prometheus",
 		},
+		{
+			name: "Native export with /rw/prometheus in ApiBasePath - should normalize",
+			connection: domain.VMConnection{
+				URL:         "https://vm.example.com",
+				ApiBasePath: "/select/0/rw/prometheus",
+				Auth: domain.AuthConfig{
+					Type: domain.AuthTypeNone,
+				},
+			},
+			requestPath:      "/api/v1/export/native",
+			expectedURL:      "https://vm.example.com/select/0/prometheus/api/v1/export/native",
+			shouldContain:    "/prometheus/api/v1/export/native",
+			shouldNotContain: "/rw/prometheus",
+		},
 		{
 			name: "Query with /rw/prometheus in FullApiUrl - should NOT normalize",
 			connection: domain.VMConnection{
@@ -250,7 +264,43 @@ func TestBuildRequest_PathNormalization_EdgeCases(t *testing.T) {
 				},
 			},
 			requestPath: "/api/v1/export",
-			expectedURL: "https://example.com/1011/prometheus//api/v1/export",
+			expectedURL: "https://example.com/1011/prometheus/api/v1/export",
+		},
+		{
+			name: "Query with trailing slash in FullApiUrl",
+			connection: domain.VMConnection{
+				URL:        "https://example.com",
+				FullApiUrl: "https://example.com/select/0/prometheus/",
+				Auth: domain.AuthConfig{
+					Type: domain.AuthTypeNone,
+				},
+			},
+			requestPath: "/api/v1/query",
+			expectedURL: "https://example.com/select/0/prometheus/api/v1/query",
+		},
+		{
+			name: "Trailing slash in URL joined with ApiBasePath",
+			connection: domain.VMConnection{
+				URL:         "http://vmselect:8481/",
+				ApiBasePath: "/select/0/prometheus/",
+				Auth: domain.AuthConfig{
+					Type: domain.AuthTypeNone,
+				},
+			},
+			requestPath: "/api/v1/export",
+			expectedURL: "http://vmselect:8481/select/0/prometheus/api/v1/export",
+		},
+		{
+			name: "Embedded double slashes collapsed, scheme kept",
+			connection: domain.VMConnection{
+				URL:        "https://example.com",
+				FullApiUrl: "https://example.com//1011//rw/prometheus",
+				Auth: domain.AuthConfig{
+					Type: domain.AuthTypeNone,
+				},
+			},
+			requestPath: "/api/v1/export",
+			expectedURL: "https://example.com/1011/prometheus/api/v1/export",
 		},
 		{
 			name: "Case sensitivity - should match /rw/prometheus exactly",
@@ -3,8 +3,11 @@ package vm
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
@@ -247,6 +250,206 @@ func TestClient_Query_APIError(t *testing.T) {
 	}
 }
 
+func TestClient_QueryWithOptions_LimitsSeries(t *testing.T) {
+	var gotLimit string
+	server := newIPv4TestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotLimit = r.URL.Query().Get("limit")
+		result := QueryResult{Status: "success", Data: QueryData{ResultType: "vector"}}
+		for i := 0; i < 5; i++ {
+			result.Data.Result = append(result.Data.Result, Result{Metric: map[string]string{"job": "job"}})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(result)
+	}))
+	defer server.Close()
+
+	client := NewClient(domain.VMConnection{URL: server.URL, Auth: domain.AuthConfig{Type: domain.AuthTypeNone}})
+	result, err := client.QueryWithOptions(context.Background(), "up", time.Now(), QueryOptions{Limit: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotLimit != "2" {
+		t.Fatalf("expected limit=2 query arg, got %q", gotLimit)
+	}
+	if len(result.Data.Result) != 2 {
+		t.Fatalf("expected result truncated to 2 series, got %d", len(result.Data.Result))
+	}
+}
+
+func TestClient_QueryWithOptions_RejectsOversizedResponse(t *testing.T) {
+	server := newIPv4TestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"status":"success","data":{"resultType":"vector","result":[`)
+		for i := 0; i < 1000; i++ {
+			if i > 0 {
+				_, _ = io.WriteString(w, ",")
+			}
+			_, _ = io.WriteString(w, `{"metric":{"__name__":"vm_rows"},"value":[1,"1"]}`)
+		}
+		_, _ = io.WriteString(w, `]}}`)
+	}))
+	defer server.Close()
+
+	client := NewClient(domain.VMConnection{URL: server.URL, Auth: domain.AuthConfig{Type: domain.AuthTypeNone}})
+	_, err := client.QueryWithOptions(context.Background(), "vm_rows", time.Now(), QueryOptions{MaxResponseBytes: 1024})
+	if !errors.Is(err, ErrResponseTooLarge) {
+		t.Fatalf("expected ErrResponseTooLarge, got %v", err)
+	}
+}
+
+func TestClient_DialTimeoutFailsFast(t *testing.T) {
+	// 10.255.255.1 is a non-routable address: SYNs are dropped, so without a dial timeout the request hangs.
+	client := NewClient(domain.VMConnection{
+		URL:            "http://10.255.255.1:8428",
+		Auth:           domain.AuthConfig{Type: domain.AuthTypeNone},
+		DialTimeoutSec: 1,
+	})
+
+	start := time.Now()
+	_, err := client.Query(context.Background(), "up", time.Now())
+	elapsed := time.Since(start)
+	if err == nil {
+		t.Fatal("expected dial to unreachable address to fail")
+	}
+	if elapsed > 3*time.Second {
+		t.Fatalf("dial took %v, expected to fail within the 1s dial timeout", elapsed)
+	}
+}
+
+func TestNewDialer_AppliesConnectionSettings(t *testing.T) {
+	dialer := newDialer(domain.VMConnection{})
+	if dialer.Timeout != defaultDialTimeout || dialer.KeepAlive != defaultKeepAlive {
+		t.Fatalf("expected defaults, got timeout=%v keepalive=%v", dialer.Timeout, dialer.KeepAlive)
+	}
+	dialer = newDialer(domain.VMConnection{DialTimeoutSec: 5, KeepAliveSec: 15})
+	if dialer.Timeout != 5*time.Second || dialer.KeepAlive != 15*time.Second {
+		t.Fatalf("expected 5s/15s, got timeout=%v keepalive=%v", dialer.Timeout, dialer.KeepAlive)
+	}
+	dialer = newDialer(domain.VMConnection{KeepAliveSec: -1})
+	if dialer.KeepAlive >= 0 {
+		t.Fatalf("expected keepalive disabled, got %v", dialer.KeepAlive)
+	}
+}
+
+func TestNewClient_ForceHTTP1(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.Proto)
+	}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+
+	protoOf := func(conn domain.VMConnection) string {
+		t.Helper()
+		resp, err := NewClient(conn).httpClient.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	if got := protoOf(domain.VMConnection{URL: srv.URL, SkipTLSVerify: true}); got != "HTTP/2.0" {
+		t.Fatalf("expected HTTP/2 to be negotiated by default, got %s", got)
+	}
+
+	conn := domain.VMConnection{URL: srv.URL, SkipTLSVerify: true, ForceHTTP1: true}
+	transport := NewClient(conn).httpClient.Transport.(*http.Transport)
+	if transport.ForceAttemptHTTP2 || transport.TLSNextProto == nil || len(transport.TLSNextProto) != 0 {
+		t.Fatalf("expected HTTP/2 to be disabled, got ForceAttemptHTTP2=%v TLSNextProto=%v", transport.ForceAttemptHTTP2, transport.TLSNextProto)
+	}
+	if got := protoOf(conn); got != "HTTP/1.1" {
+		t.Fatalf("expected HTTP/1.1 with force_http1, got %s", got)
+	}
+}
+
+func TestClient_Export_NoData(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{name: "empty_200", status: http.StatusOK, body: ""},
+		{name: "whitespace_200", status: http.StatusOK, body: "\n\n"},
+		{name: "no_content_204", status: http.StatusNoContent, body: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newIPv4TestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/stream+json")
+				w.WriteHeader(tt.status)
+				_, _ = io.WriteString(w, tt.body)
+			}))
+			defer server.Close()
+
+			client := NewClient(domain.VMConnection{URL: server.URL, Auth: domain.AuthConfig{Type: domain.AuthTypeNone}})
+			reader, err := client.Export(context.Background(), `{job="missing"}`, time.Now().Add(-time.Hour), time.Now())
+			if reader != nil {
+				_ = reader.Close()
+			}
+			if !errors.Is(err, ErrNoData) {
+				t.Fatalf("expected ErrNoData, got %v", err)
+			}
+		})
+	}
+}
+
+func TestClient_Export_MisroutedHTMLIsError(t *testing.T) {
+	server := newIPv4TestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = io.WriteString(w, "<!DOCTYPE html><html><body>Login</body></html>")
+	}))
+	defer server.Close()
+
+	client := NewClient(domain.VMConnection{URL: server.URL, Auth: domain.AuthConfig{Type: domain.AuthTypeNone}})
+	_, err := client.Export(context.Background(), "up", time.Now().Add(-time.Hour), time.Now())
+	if !errors.Is(err, ErrUnexpectedExportResponse) {
+		t.Fatalf("expected ErrUnexpectedExportResponse, got %v", err)
+	}
+	if errors.Is(err, ErrNoData) {
+		t.Fatal("misrouted response must not be reported as no data")
+	}
+	if !strings.Contains(err.Error(), "text/html") {
+		t.Fatalf("expected content type in error, got %v", err)
+	}
+}
+
+func TestClient_Export_GatewayPageHasClearError(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        string
+	}{
+		{name: "html page", contentType: "text/html; charset=utf-8", body: "<html><body>502 Bad Gateway</body></html>", want: "expected metrics stream but got HTML"},
+		// A JSON-looking body does not make an HTML response metrics data
+		{name: "html content type", contentType: "text/html", body: "{{ template error }}", want: "expected metrics stream but got HTML"},
+		{name: "plain text", contentType: "text/plain", body: "Service temporarily unavailable", want: "expected metrics stream but got text"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newIPv4TestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				_, _ = io.WriteString(w, tt.body)
+			}))
+			defer server.Close()
+
+			client := NewClient(domain.VMConnection{URL: server.URL})
+			_, err := client.Export(context.Background(), "up", time.Now().Add(-time.Hour), time.Now())
+			if !errors.Is(err, ErrUnexpectedExportResponse) || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected %q, got %v", tt.want, err)
+			}
+			if tt.contentType == "text/html" {
+				_, err = client.QueryRange(context.Background(), "up", time.Now().Add(-time.Hour), time.Now(), time.Minute)
+				if !errors.Is(err, ErrUnexpectedExportResponse) || !strings.Contains(err.Error(), tt.want) {
+					t.Fatalf("expected query_range to report %q too, got %v", tt.want, err)
+				}
+			}
+		})
+	}
+}
+
 // TestClient_Export_Success tests successful export
 func TestClient_Export_Success(t *testing.T) {
 	// Mock JSONL response
@@ -336,3 +539,99 @@ func TestClient_Export_HTTPError(t *testing.T) {
 		t.Errorf("error doesn't mention status code: %v", err)
 	}
 }
+
+func TestClient_ExportNative_StreamsRawBody(t *testing.T) {
+	// Not JSON: the native body must be passed through without sniffing or decoding
+	payload := []byte{0x00, 0x01, 0x02, 'n', 'a', 't', 'i', 'v', 'e', 0xff}
+	var gotPath string
+	var gotMatches []string
+	server := newIPv4TestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		_ = r.ParseForm()
+		gotMatches = r.PostForm["match[]"]
+		w.Write(payload)
+	}))
+	defer server.Close()
+
+	client := NewClient(domain.VMConnection{URL: server.URL, ApiBasePath: "/select/0/rw/prometheus"})
+	body, err := client.ExportNative(context.Background(), []string{`{job="a"}`, `up{job="a"}`}, time.Unix(0, 0), time.Unix(60, 0))
+	if err != nil {
+		t.Fatalf("ExportNative failed: %v", err)
+	}
+	defer body.Close()
+	got, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatalf("failed to read body: %v", err)
+	}
+
+	if gotPath != "/select/0/prometheus/api/v1/export/native" {
+		t.Fatalf("expected the normalized native export path, got %s", gotPath)
+	}
+	if len(gotMatches) != 2 {
+		t.Fatalf("expected both selectors as match[], got %v", gotMatches)
+	}
+	if string(got) != string(payload) {
+		t.Fatalf("expected the raw payload, got %v", got)
+	}
+}
+
+func TestClient_RequestTimeout(t *testing.T) {
+	const delay = 1200 * time.Millisecond
+	// wait answers slowly, but stops once the client gave up
+	wait := func(r *http.Request) {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+		}
+	}
+	server := newIPv4TestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/export":
+			// Streams for longer than the request timeout, one line at a time
+			for i := 0; i < 3; i++ {
+				if i > 0 {
+					time.Sleep(delay / 2)
+				}
+				fmt.Fprintf(w, `{"metric":{"__name__":"up"},"values":[%d],"timestamps":[%d]}`+"\n", i, i)
+				w.(http.Flusher).Flush()
+			}
+		case "/api/v1/query":
+			wait(r)
+			w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+		case "/api/v1/query_range":
+			wait(r)
+			w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[]}}`))
+		}
+	}))
+	defer server.Close()
+
+	queryAll := func(client *Client) (queryErr, rangeErr error) {
+		_, queryErr = client.Query(context.Background(), "up", time.Now())
+		_, rangeErr = client.QueryRange(context.Background(), "up", time.Now().Add(-time.Hour), time.Now(), time.Minute)
+		return queryErr, rangeErr
+	}
+
+	short := NewClient(domain.VMConnection{URL: server.URL, RequestTimeoutSec: 1})
+	queryErr, rangeErr := queryAll(short)
+	if !errors.Is(queryErr, context.DeadlineExceeded) || !errors.Is(rangeErr, context.DeadlineExceeded) {
+		t.Fatalf("expected the 1s request timeout to cut off both queries, got %v and %v", queryErr, rangeErr)
+	}
+
+	long := NewClient(domain.VMConnection{URL: server.URL, RequestTimeoutSec: 5})
+	if queryErr, rangeErr := queryAll(long); queryErr != nil || rangeErr != nil {
+		t.Fatalf("expected both queries to finish within 5s, got %v and %v", queryErr, rangeErr)
+	}
+
+	body, err := short.Export(context.Background(), `{__name__="up"}`, time.Unix(0, 0), time.Unix(60, 0))
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatalf("expected the export stream not to be bound by the request timeout, got %v", err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 3 {
+		t.Fatalf("expected 3 exported lines, got %d", lines)
+	}
+}
@@ -3,22 +3,46 @@ package vm
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 )
 
+// DefaultMaxLineBytes is the longest JSONL line (one series) ExportDecoder accepts by default
+const DefaultMaxLineBytes = 16 << 20
+
+// ErrLineTooLong is wrapped by Decode when a series line exceeds the decoder's limit
+var ErrLineTooLong = errors.New("series line too long")
+
 // ExportDecoder decodes JSONL export stream
 type ExportDecoder struct {
-	scanner *bufio.Scanner
+	scanner  *bufio.Scanner
+	maxBytes int
+	line     int
 }
 
 // NewExportDecoder creates a new export decoder
 func NewExportDecoder(r io.Reader) *ExportDecoder {
+	return NewExportDecoderSize(r, DefaultMaxLineBytes)
+}
+
+// NewExportDecoderSize creates an export decoder accepting series lines up to maxLineBytes;
+// 0 or less uses DefaultMaxLineBytes
+func NewExportDecoderSize(r io.Reader, maxLineBytes int) *ExportDecoder {
+	if maxLineBytes <= 0 {
+		maxLineBytes = DefaultMaxLineBytes
+	}
+	initial := 64 * 1024
+	if initial > maxLineBytes {
+		initial = maxLineBytes
+	}
 	scanner := bufio.NewScanner(r)
-	// Set larger buffer for metrics with many labels
-	scanner.Buffer(make([]byte, 64*1024), 1024*1024) // 64KB initial, 1MB max
+	// Start small and grow for series with many labels or points
+	scanner.Buffer(make([]byte, 0, initial), maxLineBytes)
 
 	return &ExportDecoder{
-		scanner: scanner,
+		scanner:  scanner,
+		maxBytes: maxLineBytes,
 	}
 }
 
@@ -27,10 +51,14 @@ func NewExportDecoder(r io.Reader) *ExportDecoder {
 func (d *ExportDecoder) Decode() (*ExportedMetric, error) {
 	if !d.scanner.Scan() {
 		if err := d.scanner.Err(); err != nil {
+			if errors.Is(err, bufio.ErrTooLong) {
+				return nil, fmt.Errorf("%w: line %d is longer than %d bytes", ErrLineTooLong, d.line+1, d.maxBytes)
+			}
 			return nil, err
 		}
 		return nil, io.EOF
 	}
+	d.line++
 
 	line := d.scanner.Bytes()
 
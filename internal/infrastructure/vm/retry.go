@@ -0,0 +1,143 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// defaultMaxRetries is how often a transient failure is retried when max_retries is not set
+const defaultMaxRetries = 3
+
+// retryDelay is the pause before the first retry; it doubles for every further one (250ms, 500ms, 1s)
+var retryDelay = 250 * time.Millisecond
+
+// maxRetryAfter caps the wait a 429 response may ask for with Retry-After
+const maxRetryAfter = 30 * time.Second
+
+// maxRetries returns the connection's retry budget: 0 uses the default, negative disables retries
+func (c *Client) maxRetries() int {
+	switch {
+	case c.conn.MaxRetries < 0:
+		return 0
+	case c.conn.MaxRetries == 0:
+		return defaultMaxRetries
+	default:
+		return c.conn.MaxRetries
+	}
+}
+
+// doWithRetry sends req and retries 500/502/503/504 responses, network and dial timeouts and reset
+// connections with exponential backoff. A 429 with Retry-After is retried once after the
+// requested wait (at most 30s); without the header it is backed off like a 5xx. Only the
+// response status is waited for, so a returned body is never replayed: a stream that breaks
+// later fails as before. A retry is skipped when its wait would outlive the request context,
+// and the last response or error is returned.
+func (c *Client) doWithRetry(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	retries := c.maxRetries()
+	delay := retryDelay
+	retryAfterHonored := false
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+		resp, err := c.httpClient.Do(req)
+		transient, wait, backoff := isTransientError(err), delay, true
+		if err == nil {
+			transient = isTransientStatus(resp.StatusCode)
+			if resp.StatusCode == http.StatusTooManyRequests {
+				transient = true
+				if after, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+					transient, wait, backoff = !retryAfterHonored, after, false
+					retryAfterHonored = true
+				}
+			}
+		}
+		if !transient || attempt >= retries || ctx.Err() != nil || !fitsDeadline(ctx, wait) {
+			return resp, err
+		}
+
+		lastErr := err
+		if resp != nil {
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, exportSniffBytes))
+			_ = resp.Body.Close()
+			lastErr = classifyResponseError(resp.StatusCode, string(body))
+		}
+		log.Printf("[WARN] %s %s failed (%v), retrying in %v (%d/%d)", req.Method, req.URL.Path, lastErr, wait, attempt+1, retries)
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w (last error: %v)", ctx.Err(), lastErr)
+		case <-time.After(wait):
+		}
+		if backoff {
+			delay *= 2
+		}
+	}
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date, capped at
+// maxRetryAfter; a date in the past means no wait
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	var wait time.Duration
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		wait = time.Duration(min(seconds, int(maxRetryAfter/time.Second))) * time.Second
+	} else if at, err := http.ParseTime(value); err == nil {
+		wait = at.Sub(now)
+	} else {
+		return 0, false
+	}
+	return min(max(wait, 0), maxRetryAfter), true
+}
+
+// isTransientStatus reports the gateway and overload statuses worth another attempt
+func isTransientStatus(status int) bool {
+	switch status {
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// isTransientError reports network timeouts, dial timeouts included, and connections reset by a
+// peer or proxy. Dials that fail outright (connection refused, no route, DNS errors) are not
+// retried: the host is unreachable and another attempt would only delay the error.
+func isTransientError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" && !opErr.Timeout() {
+		return false
+	}
+	if errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// fitsDeadline reports whether waiting delay still leaves the context time for another attempt
+func fitsDeadline(ctx context.Context, delay time.Duration) bool {
+	deadline, ok := ctx.Deadline()
+	return !ok || time.Until(deadline) > delay
+}
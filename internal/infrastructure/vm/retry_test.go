@@ -0,0 +1,311 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+)
+
+// shortRetryDelay keeps retry tests fast
+func shortRetryDelay(t *testing.T) {
+	t.Helper()
+	prev := retryDelay
+	retryDelay = time.Millisecond
+	t.Cleanup(func() { retryDelay = prev })
+}
+
+// countingHandler answers the first len(statuses) requests with those statuses and then with ok
+func countingHandler(calls *int32, statuses []int, ok func(w http.ResponseWriter, r *http.Request)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(calls, 1))
+		if n <= len(statuses) {
+			http.Error(w, "upstream unavailable", statuses[n-1])
+			return
+		}
+		ok(w, r)
+	}
+}
+
+func writeEmptyVector(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+}
+
+func TestClient_RetriesTransientStatuses(t *testing.T) {
+	shortRetryDelay(t)
+	tests := []struct {
+		name       string
+		statuses   []int
+		maxRetries int
+		wantCalls  int32
+		wantErr    string
+	}{
+		{name: "recovers after 502 and 503", statuses: []int{http.StatusBadGateway, http.StatusServiceUnavailable}, wantCalls: 3},
+		{name: "default budget is three retries", statuses: []int{500, 502, 503, 504, 502}, wantCalls: 4, wantErr: "unexpected status code 504"},
+		{name: "custom budget", statuses: []int{502, 502, 502}, maxRetries: 1, wantCalls: 2, wantErr: "unexpected status code 502"},
+		{name: "negative disables retries", statuses: []int{http.StatusBadGateway}, maxRetries: -1, wantCalls: 1, wantErr: "unexpected status code 502"},
+		{name: "client errors are not retried", statuses: []int{http.StatusBadRequest}, wantCalls: 1, wantErr: "unexpected status code 400"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int32
+			server := newIPv4TestServer(countingHandler(&calls, tt.statuses, writeEmptyVector))
+			defer server.Close()
+
+			client := NewClient(domain.VMConnection{URL: server.URL, MaxRetries: tt.maxRetries})
+			_, err := client.Query(context.Background(), "up", time.Now())
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("expected query to succeed after retries, got %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+			if got := atomic.LoadInt32(&calls); got != tt.wantCalls {
+				t.Fatalf("expected %d requests, got %d", tt.wantCalls, got)
+			}
+		})
+	}
+}
+
+func TestClient_QueryRangeAndExportRetryBeforeFirstByte(t *testing.T) {
+	shortRetryDelay(t)
+
+	var rangeCalls int32
+	rangeServer := newIPv4TestServer(countingHandler(&rangeCalls, []int{http.StatusGatewayTimeout}, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[]}}`))
+	}))
+	defer rangeServer.Close()
+	end := time.Now()
+	client := NewClient(domain.VMConnection{URL: rangeServer.URL})
+	if _, err := client.QueryRange(context.Background(), "up", end.Add(-time.Hour), end, time.Minute); err != nil {
+		t.Fatalf("expected query_range to succeed after a retry, got %v", err)
+	}
+	if rangeCalls != 2 {
+		t.Fatalf("expected 2 query_range requests, got %d", rangeCalls)
+	}
+
+	// The POST form must be sent again in full on the retry
+	var exportCalls int32
+	exportServer := newIPv4TestServer(countingHandler(&exportCalls, []int{http.StatusBadGateway}, func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.Form.Get("match[]") != `{job="vm"}` {
+			http.Error(w, "missing match[]", http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(`{"metric":{"__name__":"up"},"values":[1],"timestamps":[1]}` + "\n"))
+	}))
+	defer exportServer.Close()
+	client = NewClient(domain.VMConnection{URL: exportServer.URL})
+	body, err := client.Export(context.Background(), `{job="vm"}`, end.Add(-time.Hour), end)
+	if err != nil {
+		t.Fatalf("expected export to succeed after a retry, got %v", err)
+	}
+	data, _ := io.ReadAll(body)
+	_ = body.Close()
+	if !strings.Contains(string(data), `"up"`) {
+		t.Fatalf("unexpected export body: %s", data)
+	}
+	if exportCalls != 2 {
+		t.Fatalf("expected 2 export requests, got %d", exportCalls)
+	}
+}
+
+// timeoutError is a net.Error that reports a timeout
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+type flakyTransport struct {
+	calls    int32
+	failures int32
+	err      error // returned for each failure; defaults to timeoutError
+}
+
+func (f *flakyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if atomic.AddInt32(&f.calls, 1) <= f.failures {
+		if f.err != nil {
+			return nil, f.err
+		}
+		return nil, timeoutError{}
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"status":"success","data":{"resultType":"vector","result":[]}}`)),
+		Request:    req,
+	}, nil
+}
+
+func TestClient_RetriesNetworkTimeouts(t *testing.T) {
+	shortRetryDelay(t)
+	transport := &flakyTransport{failures: 2}
+	client := NewClientWithTransport(domain.VMConnection{URL: "http://vm.invalid:8428"}, transport)
+	if _, err := client.Query(context.Background(), "up", time.Now()); err != nil {
+		t.Fatalf("expected query to succeed after timeouts, got %v", err)
+	}
+	if transport.calls != 3 {
+		t.Fatalf("expected 3 attempts, got %d", transport.calls)
+	}
+}
+
+func TestClient_RetriesDialTimeoutsOnly(t *testing.T) {
+	shortRetryDelay(t)
+	dialTimeout := &net.OpError{Op: "dial", Net: "tcp", Err: timeoutError{}}
+	transport := &flakyTransport{failures: 1, err: dialTimeout}
+	client := NewClientWithTransport(domain.VMConnection{URL: "http://vm.invalid:8428"}, transport)
+	if _, err := client.Query(context.Background(), "up", time.Now()); err != nil {
+		t.Fatalf("expected query to succeed after a dial timeout, got %v", err)
+	}
+	if transport.calls != 2 {
+		t.Fatalf("expected 2 attempts after a dial timeout, got %d", transport.calls)
+	}
+
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	transport = &flakyTransport{failures: 1, err: refused}
+	client = NewClientWithTransport(domain.VMConnection{URL: "http://vm.invalid:8428"}, transport)
+	if _, err := client.Query(context.Background(), "up", time.Now()); err == nil {
+		t.Fatal("expected a refused dial to fail")
+	}
+	if transport.calls != 1 {
+		t.Fatalf("expected a refused dial not to be retried, got %d attempts", transport.calls)
+	}
+}
+
+func TestClient_SeriesLabelsAndVMAlertRetry(t *testing.T) {
+	shortRetryDelay(t)
+	end := time.Now()
+
+	var seriesCalls int32
+	seriesServer := newIPv4TestServer(countingHandler(&seriesCalls, []int{http.StatusServiceUnavailable}, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"success","data":[{"__name__":"up"}]}`))
+	}))
+	defer seriesServer.Close()
+	client := NewClient(domain.VMConnection{URL: seriesServer.URL})
+	if _, err := client.SeriesWithOptions(context.Background(), "up", end.Add(-time.Hour), end, SeriesOptions{ExtraFilters: []string{`{job="vm"}`}}); err != nil {
+		t.Fatalf("expected series to succeed after a retry, got %v", err)
+	}
+	if seriesCalls != 2 {
+		t.Fatalf("expected 2 series requests, got %d", seriesCalls)
+	}
+
+	var labelCalls int32
+	labelServer := newIPv4TestServer(countingHandler(&labelCalls, []int{http.StatusBadGateway, http.StatusBadGateway}, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"success","data":["job"]}`))
+	}))
+	defer labelServer.Close()
+	client = NewClient(domain.VMConnection{URL: labelServer.URL})
+	if _, err := client.Labels(context.Background(), "up", end.Add(-time.Hour), end); err != nil {
+		t.Fatalf("expected labels to succeed after a retry, got %v", err)
+	}
+	if _, err := client.LabelValues(context.Background(), "job", "up", end.Add(-time.Hour), end); err != nil {
+		t.Fatalf("expected label values to succeed, got %v", err)
+	}
+	if labelCalls != 4 {
+		t.Fatalf("expected 4 label requests, got %d", labelCalls)
+	}
+
+	var alertCalls int32
+	alertServer := newIPv4TestServer(countingHandler(&alertCalls, []int{http.StatusGatewayTimeout}, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"success","data":{"alerts":[]}}`))
+	}))
+	defer alertServer.Close()
+	client = NewClient(domain.VMConnection{URL: alertServer.URL})
+	if _, err := client.FetchVMAlert(context.Background(), VMAlertAlertsPath); err != nil {
+		t.Fatalf("expected vmalert fetch to succeed after a retry, got %v", err)
+	}
+	if alertCalls != 2 {
+		t.Fatalf("expected 2 vmalert requests, got %d", alertCalls)
+	}
+}
+
+func TestClient_RetryBackoffRespectsDeadline(t *testing.T) {
+	var calls int32
+	server := newIPv4TestServer(countingHandler(&calls, []int{502, 502, 502, 502}, writeEmptyVector))
+	defer server.Close()
+
+	// The first backoff (250ms) does not fit into the remaining deadline, so no retry is made
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	client := NewClient(domain.VMConnection{URL: server.URL})
+	start := time.Now()
+	_, err := client.Query(ctx, "up", time.Now())
+	if err == nil || errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the 502 to be reported, got %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected a single request, got %d", calls)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatalf("expected to give up without waiting, took %v", elapsed)
+	}
+}
+
+func TestClient_RetryAfterIsHonoredOnce(t *testing.T) {
+	var calls int32
+	server := newIPv4TestServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Retry-After", "0")
+		http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	client := NewClient(domain.VMConnection{URL: server.URL})
+	_, err := client.Query(context.Background(), "up", time.Now())
+	if err == nil || !strings.Contains(err.Error(), "429") {
+		t.Fatalf("expected the second 429 to be reported, got %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected a single retry, got %d requests", calls)
+	}
+
+	// A wait beyond the context deadline is not attempted
+	calls = 0
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Retry-After", "5")
+		http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+	})
+	if _, err := client.Query(ctx, "up", time.Now()); err == nil || calls != 1 {
+		t.Fatalf("expected to give up at once, got %d requests, err %v", calls, err)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{value: "2", want: 2 * time.Second, ok: true},
+		{value: " 0 ", want: 0, ok: true},
+		{value: "3600", want: maxRetryAfter, ok: true},
+		{value: now.Add(10 * time.Second).Format(http.TimeFormat), want: 10 * time.Second, ok: true},
+		{value: now.Add(-time.Minute).Format(http.TimeFormat), want: 0, ok: true},
+		{value: "", ok: false},
+		{value: "-1", ok: false},
+		{value: "soon", ok: false},
+	}
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.value, now)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseRetryAfter(%q) = %v, %v; want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
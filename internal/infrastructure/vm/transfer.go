@@ -0,0 +1,119 @@
+package vm
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// transferStats describes how an export response travelled over the wire
+type transferStats struct {
+	Path         string
+	Encoding     string // Negotiated Content-Encoding; "identity" for an uncompressed response
+	WireBytes    int64
+	DecodedBytes int64
+	Decompressed bool
+}
+
+func (s transferStats) String() string {
+	ratio := ""
+	if s.Decompressed && s.WireBytes > 0 {
+		ratio = fmt.Sprintf(" ratio=%.1fx", float64(s.DecodedBytes)/float64(s.WireBytes))
+	}
+	return fmt.Sprintf("%s content-encoding=%s wire_bytes=%d decoded_bytes=%d decompressed=%t%s",
+		s.Path, s.Encoding, s.WireBytes, s.DecodedBytes, s.Decompressed, ratio)
+}
+
+// doExportRequest executes an export or query_range request. It asks for gzip itself instead of
+// relying on the transport's transparent decompression, which a passthrough Accept-Encoding
+// header would silently turn off, and decodes the body here, so callers always read plain
+// JSONL. With -debug the log shows whether the response was compressed and how many bytes
+// crossed the wire.
+func (c *Client) doExportRequest(req *http.Request) (*http.Response, error) {
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := c.doWithRetry(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = newTransferBody(resp, req.URL.Path, c.conn.Debug)
+	return resp, nil
+}
+
+// transferBody decodes the response body and, with debug set, logs its transferStats once closed
+type transferBody struct {
+	wire    *countingReader
+	decoded io.Reader
+	gz      *gzip.Reader
+	raw     io.Closer
+	stats   transferStats
+	debug   bool
+	logged  bool
+}
+
+func newTransferBody(resp *http.Response, path string, debug bool) *transferBody {
+	wire := &countingReader{r: resp.Body}
+	d := &transferBody{
+		wire:    wire,
+		decoded: wire,
+		raw:     resp.Body,
+		stats:   transferStats{Path: path, Encoding: "identity"},
+		debug:   debug,
+	}
+	if encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))); encoding != "" {
+		d.stats.Encoding = encoding
+	}
+	if d.stats.Encoding == "gzip" {
+		// The gzip header is read on the first Read, so empty bodies (e.g. 204) do not fail
+		d.decoded = nil
+		d.stats.Decompressed = true
+		// The body is decoded here, so downstream readers must not see the encoding anymore
+		resp.Header.Del("Content-Encoding")
+		resp.ContentLength = -1
+	}
+	return d
+}
+
+func (d *transferBody) Read(p []byte) (int, error) {
+	if d.decoded == nil {
+		gz, err := gzip.NewReader(d.wire)
+		if err != nil {
+			return 0, fmt.Errorf("invalid gzip response: %w", err)
+		}
+		d.gz, d.decoded = gz, gz
+	}
+	n, err := d.decoded.Read(p)
+	d.stats.DecodedBytes += int64(n)
+	return n, err
+}
+
+// Close closes the gzip stream, if any, and the underlying response body
+func (d *transferBody) Close() error {
+	if d.debug && !d.logged {
+		d.logged = true
+		d.stats.WireBytes = d.wire.n
+		log.Printf("[DEBUG] Transfer: %s", d.stats)
+	}
+	var gzErr error
+	if d.gz != nil {
+		gzErr = d.gz.Close()
+	}
+	if err := d.raw.Close(); err != nil {
+		return err
+	}
+	return gzErr
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
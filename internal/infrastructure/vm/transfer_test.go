@@ -0,0 +1,146 @@
+package vm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+)
+
+func TestExportMatches_TransferDiagnostics(t *testing.T) {
+	payload := strings.Repeat(`{"metric":{"__name__":"up"},"values":[1],"timestamps":[1]}`+"\n", 50)
+	tests := []struct {
+		name     string
+		gzipped  bool
+		wantLogs []string
+	}{
+		{name: "gzip", gzipped: true, wantLogs: []string{"content-encoding=gzip", "decompressed=true", "decoded_bytes=" + strconv.Itoa(len(payload))}},
+		{name: "plain", gzipped: false, wantLogs: []string{"content-encoding=identity", "decompressed=false", "wire_bytes=" + strconv.Itoa(len(payload))}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !tt.gzipped || r.Header.Get("Accept-Encoding") != "gzip" {
+					_, _ = w.Write([]byte(payload))
+					return
+				}
+				w.Header().Set("Content-Encoding", "gzip")
+				gz := gzip.NewWriter(w)
+				_, _ = gz.Write([]byte(payload))
+				_ = gz.Close()
+			}))
+			defer srv.Close()
+
+			var logs bytes.Buffer
+			prev := log.Writer()
+			log.SetOutput(&logs)
+			defer log.SetOutput(prev)
+
+			client := NewClient(domain.VMConnection{URL: srv.URL, Debug: true})
+			body, err := client.ExportMatches(context.Background(), []string{`{__name__="up"}`}, time.Unix(0, 0), time.Unix(60, 0))
+			if err != nil {
+				t.Fatalf("ExportMatches failed: %v", err)
+			}
+			var got bytes.Buffer
+			_, _ = got.ReadFrom(body)
+			_ = body.Close()
+
+			if got.String() != payload {
+				t.Fatalf("expected decoded payload, got %d bytes", got.Len())
+			}
+			for _, want := range tt.wantLogs {
+				if !strings.Contains(logs.String(), want) {
+					t.Fatalf("expected %q in diagnostics, got %s", want, logs.String())
+				}
+			}
+		})
+	}
+}
+
+// closeTrackingTransport records whether response bodies were closed
+type closeTrackingTransport struct {
+	base   http.RoundTripper
+	closed bool
+}
+
+func (t *closeTrackingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = &trackedBody{ReadCloser: resp.Body, closed: &t.closed}
+	return resp, nil
+}
+
+type trackedBody struct {
+	io.ReadCloser
+	closed *bool
+}
+
+func (b *trackedBody) Close() error {
+	*b.closed = true
+	return b.ReadCloser.Close()
+}
+
+func TestExport_DecodesGzipResponse(t *testing.T) {
+	payload := `{"metric":{"__name__":"up","job":"a"},"values":[1,2],"timestamps":[1,2]}` + "\n" +
+		`{"metric":{"__name__":"up","job":"b"},"values":[3],"timestamps":[3]}` + "\n"
+	var gotAcceptEncoding string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAcceptEncoding = r.Header.Get("Accept-Encoding")
+		if gotAcceptEncoding != "gzip" {
+			_, _ = w.Write([]byte(payload))
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		_, _ = gz.Write([]byte(payload))
+		_ = gz.Close()
+	}))
+	defer srv.Close()
+
+	// A passthrough Accept-Encoding header turns off the transport's transparent decompression
+	client := NewClient(domain.VMConnection{URL: srv.URL, Headers: map[string]string{"Accept-Encoding": "identity"}})
+	transport := &closeTrackingTransport{base: client.httpClient.Transport}
+	client.httpClient.Transport = transport
+
+	body, err := client.Export(context.Background(), `{__name__="up"}`, time.Unix(0, 0), time.Unix(60, 0))
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	decoder := NewExportDecoder(body)
+	var jobs []string
+	for {
+		metric, err := decoder.Decode()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("decode failed: %v", err)
+		}
+		jobs = append(jobs, metric.Metric["job"])
+	}
+	if err := body.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if gotAcceptEncoding != "gzip" {
+		t.Fatalf("expected Accept-Encoding gzip, got %q", gotAcceptEncoding)
+	}
+	if strings.Join(jobs, ",") != "a,b" {
+		t.Fatalf("expected decoded series a,b, got %v", jobs)
+	}
+	if !transport.closed {
+		t.Fatal("expected Close to close the underlying response body")
+	}
+}
@@ -0,0 +1,112 @@
+package vm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// StaleNaN is the NaN bit pattern VictoriaMetrics and Prometheus use for staleness markers.
+// /api/v1/export writes these samples as null.
+var StaleNaN = math.Float64frombits(0x7ff0000000000002)
+
+// IsStaleNaN reports whether v is a staleness marker rather than an ordinary NaN
+func IsStaleNaN(v float64) bool {
+	return math.Float64bits(v) == math.Float64bits(StaleNaN)
+}
+
+// ParseSampleValue is the single point where textual sample values become floats. It accepts
+// what VictoriaMetrics returns as numbers or numeric strings: decimals, scientific notation,
+// "NaN", "+Inf", "-Inf" and "Inf".
+func ParseSampleValue(raw string) (float64, error) {
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid sample value %q", raw)
+	}
+	return v, nil
+}
+
+// SampleValues are the values of an exported series. JSON numbers, numeric strings and null
+// (read as StaleNaN) are all decoded to float64. Encoding writes staleness markers as null and
+// other NaN and infinite values as the strings "NaN", "+Inf" and "-Inf", which JSON numbers
+// cannot express; finite values are written exactly like encoding/json writes a float64.
+type SampleValues []float64
+
+// UnmarshalJSON decodes a JSON array of sample values
+func (v *SampleValues) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		*v = nil
+		return nil
+	}
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	values := make(SampleValues, len(raw))
+	for i, item := range raw {
+		item = bytes.TrimSpace(item)
+		switch {
+		case bytes.Equal(item, []byte("null")):
+			values[i] = StaleNaN
+			continue
+		case len(item) > 0 && item[0] == '"':
+			var s string
+			if err := json.Unmarshal(item, &s); err != nil {
+				return err
+			}
+			item = []byte(s)
+		}
+		f, err := ParseSampleValue(string(item))
+		if err != nil {
+			return err
+		}
+		values[i] = f
+	}
+	*v = values
+	return nil
+}
+
+// MarshalJSON encodes the values as a JSON array
+func (v SampleValues) MarshalJSON() ([]byte, error) {
+	if v == nil {
+		return []byte("null"), nil
+	}
+	buf := make([]byte, 0, 2+len(v)*8)
+	buf = append(buf, '[')
+	for i, f := range v {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = appendSampleValue(buf, f)
+	}
+	return append(buf, ']'), nil
+}
+
+func appendSampleValue(buf []byte, f float64) []byte {
+	switch {
+	case IsStaleNaN(f):
+		return append(buf, "null"...)
+	case math.IsNaN(f):
+		return append(buf, `"NaN"`...)
+	case math.IsInf(f, 1):
+		return append(buf, `"+Inf"`...)
+	case math.IsInf(f, -1):
+		return append(buf, `"-Inf"`...)
+	}
+	// Same formatting as encoding/json, so archives do not change byte for byte
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	buf = strconv.AppendFloat(buf, f, format, -1, 64)
+	if format == 'e' {
+		// clean up e-09 to e-9
+		if n := len(buf); n >= 4 && buf[n-4] == 'e' && buf[n-3] == '-' && buf[n-2] == '0' {
+			buf[n-2] = buf[n-1]
+			buf = buf[:n-1]
+		}
+	}
+	return buf
+}
@@ -0,0 +1,62 @@
+package vm
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestSampleValuesDecodeStringsAndNumbers(t *testing.T) {
+	var numeric, textual ExportedMetric
+	if err := json.Unmarshal([]byte(`{"metric":{"__name__":"up"},"values":[1,0.5,1.5e3,-2,null],"timestamps":[1,2,3,4,5]}`), &numeric); err != nil {
+		t.Fatalf("numeric values: %v", err)
+	}
+	if err := json.Unmarshal([]byte(`{"metric":{"__name__":"up"},"values":["1","0.5","1.5e3","-2",null],"timestamps":[1,2,3,4,5]}`), &textual); err != nil {
+		t.Fatalf("string values: %v", err)
+	}
+	want := []float64{1, 0.5, 1500, -2}
+	for i, w := range want {
+		if numeric.Values[i] != w || textual.Values[i] != w {
+			t.Fatalf("value %d: numeric %v, string %v, want %v", i, numeric.Values[i], textual.Values[i], w)
+		}
+	}
+	if !IsStaleNaN(numeric.Values[4]) || !IsStaleNaN(textual.Values[4]) {
+		t.Fatal("expected null to decode to the staleness marker")
+	}
+
+	var special SampleValues
+	if err := json.Unmarshal([]byte(`["NaN","+Inf","-Inf","Inf"]`), &special); err != nil {
+		t.Fatalf("special values: %v", err)
+	}
+	if !math.IsNaN(special[0]) || IsStaleNaN(special[0]) || !math.IsInf(special[1], 1) || !math.IsInf(special[2], -1) || !math.IsInf(special[3], 1) {
+		t.Fatalf("unexpected special values %v", special)
+	}
+	if err := json.Unmarshal([]byte(`["abc"]`), &special); err == nil {
+		t.Fatal("expected a non-numeric string to be rejected")
+	}
+}
+
+func TestSampleValuesEncode(t *testing.T) {
+	values := SampleValues{1, 0.5, 1e21, 1e-7, 123456789, StaleNaN, math.NaN(), math.Inf(1), math.Inf(-1)}
+	data, err := json.Marshal(values)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	const want = `[1,0.5,1e+21,1e-7,123456789,null,"NaN","+Inf","-Inf"]`
+	if string(data) != want {
+		t.Fatalf("got %s, want %s", data, want)
+	}
+	// Finite values match encoding/json, so existing archives keep their bytes
+	plain, _ := json.Marshal([]float64(values[:5]))
+	if string(data[:len(plain)-1]) != string(plain[:len(plain)-1]) {
+		t.Fatalf("finite values %s differ from encoding/json %s", data, plain)
+	}
+
+	var decoded SampleValues
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("round trip failed: %v", err)
+	}
+	if decoded[4] != 123456789 || !IsStaleNaN(decoded[5]) || !math.IsNaN(decoded[6]) || IsStaleNaN(decoded[6]) || !math.IsInf(decoded[8], -1) {
+		t.Fatalf("unexpected round trip %v", decoded)
+	}
+}
@@ -0,0 +1,57 @@
+package vm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// vmalert API endpoints captured into incident archives
+const (
+	VMAlertAlertsPath = "/api/v1/alerts"
+	VMAlertRulesPath  = "/api/v1/rules"
+)
+
+// maxVMAlertResponseBytes bounds a vmalert response kept in memory for the archive
+const maxVMAlertResponseBytes = 32 << 20
+
+// vmalertResponse is the envelope shared by vmalert's alerts and rules APIs
+type vmalertResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// FetchVMAlert returns the raw JSON of a vmalert API endpoint, e.g. VMAlertAlertsPath. The client's
+// connection URL must point at vmalert (or at a proxy path in front of it, such as .../vmalert).
+func (c *Client) FetchVMAlert(ctx context.Context, path string) ([]byte, error) {
+	req, err := c.buildRequest(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	resp, err := c.doWithRetry(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxVMAlertResponseBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	if len(body) > maxVMAlertResponseBytes {
+		return nil, fmt.Errorf("response is larger than %d bytes", maxVMAlertResponseBytes)
+	}
+	var envelope vmalertResponse
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, fmt.Errorf("response is not vmalert JSON: %w", err)
+	}
+	if envelope.Status != "success" {
+		return nil, fmt.Errorf("API error: %s", envelope.Error)
+	}
+	return body, nil
+}
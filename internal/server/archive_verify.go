@@ -0,0 +1,219 @@
+package server
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limits for /api/archive/verify
+const (
+	archiveVerifyDefaultConcurrency = 4
+	archiveVerifyMaxConcurrency     = 16
+	archiveVerifyMaxArchives        = 500
+)
+
+// archiveVerifyTarget is one archive to verify. Without sha256 only the zip structure and the
+// CRC of every entry are checked.
+type archiveVerifyTarget struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+type archiveVerifyResult struct {
+	Index    int    `json:"index"`
+	Path     string `json:"path"`
+	OK       bool   `json:"ok"`
+	SHA256   string `json:"sha256,omitempty"`
+	Expected string `json:"expected_sha256,omitempty"`
+	Entries  int    `json:"entries,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// handleArchiveVerify recomputes SHA256 and checks the zip entries of one archive
+// ({"path","sha256"}), a list ({"archives":[...]}) or every .zip in a directory ({"dir"}) with a
+// bounded worker pool. A failing archive only fails its own result entry.
+func (s *Server) handleArchiveVerify(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var request struct {
+		archiveVerifyTarget
+		Archives    []archiveVerifyTarget `json:"archives,omitempty"`
+		Dir         string                `json:"dir,omitempty"`
+		Concurrency int                   `json:"concurrency,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+	targets := request.Archives
+	if request.Path != "" {
+		targets = append([]archiveVerifyTarget{request.archiveVerifyTarget}, targets...)
+	}
+	if request.Dir != "" {
+		listed, status, err := s.listArchives(request.Dir)
+		if err != nil {
+			respondWithError(w, status, err.Error())
+			return
+		}
+		targets = append(targets, listed...)
+	}
+	if len(targets) == 0 {
+		respondWithError(w, http.StatusBadRequest, "path, archives or dir is required")
+		return
+	}
+	if len(targets) > archiveVerifyMaxArchives {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("at most %d archives per request", archiveVerifyMaxArchives))
+		return
+	}
+	if request.Concurrency < 0 {
+		respondWithError(w, http.StatusBadRequest, "concurrency must be non-negative")
+		return
+	}
+	concurrency := request.Concurrency
+	if concurrency == 0 {
+		concurrency = archiveVerifyDefaultConcurrency
+	}
+	if concurrency > archiveVerifyMaxConcurrency {
+		concurrency = archiveVerifyMaxConcurrency
+	}
+
+	started := time.Now()
+	results := make([]archiveVerifyResult, len(targets))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target archiveVerifyTarget) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-r.Context().Done():
+				results[i] = archiveVerifyResult{Index: i, Path: target.Path, Error: r.Context().Err().Error()}
+				return
+			}
+			results[i] = s.verifyArchive(i, target)
+		}(i, target)
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, result := range results {
+		if !result.OK {
+			failed++
+		}
+	}
+	log.Printf("[OK] Archive verification complete: %d archives, %d failed in %s", len(results), failed, time.Since(started).Round(time.Millisecond))
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"results": results,
+		"failed":  failed,
+	})
+}
+
+// verifyArchive checks one archive: it must be a file inside the output directory, every zip
+// entry must pass its CRC check, and the file's SHA256 must match the expected one if given
+func (s *Server) verifyArchive(index int, target archiveVerifyTarget) archiveVerifyResult {
+	result := archiveVerifyResult{Index: index, Path: target.Path, Expected: strings.ToLower(strings.TrimSpace(target.SHA256))}
+	path, info, _, err := s.resolveOutputFile(target.Path)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if info.IsDir() {
+		result.Error = "path is a directory"
+		return result
+	}
+
+	sum, err := fileSHA256(path)
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to read archive: %v", err)
+		return result
+	}
+	result.SHA256 = sum
+	entries, err := checkZipEntries(path)
+	result.Entries = entries
+	switch {
+	case err != nil:
+		result.Error = fmt.Sprintf("corrupted archive: %v", err)
+	case result.Expected != "" && result.Expected != sum:
+		result.Error = "sha256 mismatch"
+	default:
+		result.OK = true
+	}
+	return result
+}
+
+// listArchives returns every .zip directly inside dir, which must be within the output directory
+func (s *Server) listArchives(dir string) ([]archiveVerifyTarget, int, error) {
+	absDir, info, status, err := s.resolveOutputFile(dir)
+	if err != nil {
+		return nil, status, err
+	}
+	if !info.IsDir() {
+		return nil, http.StatusBadRequest, errors.New("dir is not a directory")
+	}
+	entries, err := os.ReadDir(absDir)
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("failed to list %s: %v", dir, err)
+	}
+	var targets []archiveVerifyTarget
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && strings.EqualFold(filepath.Ext(entry.Name()), ".zip") {
+			targets = append(targets, archiveVerifyTarget{Path: filepath.Join(absDir, entry.Name())})
+		}
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].Path < targets[j].Path })
+	return targets, 0, nil
+}
+
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = file.Close() }()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// checkZipEntries reads every entry so archive/zip validates its CRC32, and returns the entry count
+func checkZipEntries(path string) (int, error) {
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = reader.Close() }()
+	for _, f := range reader.File {
+		rc, err := f.Open()
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		_, err = io.Copy(io.Discard, rc)
+		_ = rc.Close()
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", f.Name, err)
+		}
+	}
+	return len(reader.File), nil
+}
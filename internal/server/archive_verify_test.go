@@ -0,0 +1,99 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestArchive(t *testing.T, path string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	f, err := zw.Create("metrics.jsonl")
+	if err != nil {
+		t.Fatalf("failed to create entry: %v", err)
+	}
+	_, _ = f.Write([]byte(strings.Repeat(`{"metric":{"__name__":"up"},"values":[1],"timestamps":[1000]}`+"\n", 50)))
+	if err := zw.Close(); err != nil {
+		t.Fatalf("failed to close zip: %v", err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatalf("failed to write archive: %v", err)
+	}
+	sum, err := fileSHA256(path)
+	if err != nil {
+		t.Fatalf("failed to hash archive: %v", err)
+	}
+	return sum
+}
+
+func TestHandleArchiveVerify_Concurrent(t *testing.T) {
+	outputDir := t.TempDir()
+	var targets []archiveVerifyTarget
+	for i := 0; i < 6; i++ {
+		path := filepath.Join(outputDir, fmt.Sprintf("export_%d.zip", i))
+		targets = append(targets, archiveVerifyTarget{Path: path, SHA256: writeTestArchive(t, path)})
+	}
+	// Flip a byte inside the compressed data of archive 2; its recorded checksum predates the damage
+	data, err := os.ReadFile(targets[2].Path)
+	if err != nil {
+		t.Fatalf("failed to read archive: %v", err)
+	}
+	data[len("PK\x03\x04")+40] ^= 0xff
+	if err := os.WriteFile(targets[2].Path, data, 0o644); err != nil {
+		t.Fatalf("failed to corrupt archive: %v", err)
+	}
+	targets[4].SHA256 = strings.Repeat("0", 64)
+	targets = append(targets, archiveVerifyTarget{Path: filepath.Join(t.TempDir(), "outside.zip")})
+
+	srv := NewServer(outputDir, "test", false)
+	body, _ := json.Marshal(map[string]interface{}{"archives": targets, "concurrency": 3})
+	rr := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/archive/verify", bytes.NewReader(body)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp struct {
+		Results []archiveVerifyResult `json:"results"`
+		Failed  int                   `json:"failed"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid json: %v", err)
+	}
+	if len(resp.Results) != len(targets) || resp.Failed != 3 {
+		t.Fatalf("expected %d results with 3 failures, got %d with %d: %+v", len(targets), len(resp.Results), resp.Failed, resp.Results)
+	}
+	for i, result := range resp.Results {
+		wantOK := i != 2 && i != 4 && i != 6
+		if result.Index != i || result.Path != targets[i].Path || result.OK != wantOK {
+			t.Fatalf("archive %d: unexpected result %+v", i, result)
+		}
+	}
+	if !strings.Contains(resp.Results[2].Error, "corrupted") {
+		t.Fatalf("expected archive 2 to be reported corrupted, got %q", resp.Results[2].Error)
+	}
+	if resp.Results[4].Error != "sha256 mismatch" {
+		t.Fatalf("expected archive 4 to fail the checksum, got %q", resp.Results[4].Error)
+	}
+	if !strings.Contains(resp.Results[6].Error, "Access denied") {
+		t.Fatalf("expected the archive outside the output dir to be refused, got %q", resp.Results[6].Error)
+	}
+
+	body, _ = json.Marshal(map[string]string{"dir": outputDir})
+	rr = httptest.NewRecorder()
+	srv.Router().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/archive/verify", bytes.NewReader(body)))
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid json: %v", err)
+	}
+	if len(resp.Results) != 6 || resp.Failed != 1 || resp.Results[2].OK {
+		t.Fatalf("expected only the corrupted archive to fail a directory check, got %+v", resp.Results)
+	}
+}
@@ -2,13 +2,19 @@ package server
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
 	"sync"
 	"time"
 
 	"github.com/VictoriaMetrics/vmgather/internal/application/services"
 	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/upload"
 )
 
 type ExportJobState string
@@ -23,7 +29,11 @@ const (
 
 const (
 	defaultMaxConcurrentJobs = 3
-	defaultJobRetention      = 30 * time.Minute
+	// defaultMaxQueuedJobs is how many jobs may wait for a free slot before new ones are rejected
+	defaultMaxQueuedJobs = 50
+	defaultJobRetention  = 30 * time.Minute
+	// defaultProgressInterval is the shortest time between two published progress updates of a job
+	defaultProgressInterval = 500 * time.Millisecond
 )
 
 type ExportJobStatus struct {
@@ -36,6 +46,7 @@ type ExportJobStatus struct {
 	CompletedBatches         int                  `json:"completed_batches"`
 	Progress                 float64              `json:"progress"`
 	MetricsProcessed         int                  `json:"metrics_processed"`
+	SeriesWithSamples        int                  `json:"series_with_samples"`
 	BatchWindowSeconds       int                  `json:"batch_window_seconds"`
 	AverageBatchSeconds      float64              `json:"average_batch_seconds"`
 	LastBatchDurationSeconds float64              `json:"last_batch_duration_seconds"`
@@ -45,6 +56,10 @@ type ExportJobStatus struct {
 	Result                   *domain.ExportResult `json:"result,omitempty"`
 	Error                    string               `json:"error,omitempty"`
 	CurrentRange             *domain.TimeRange    `json:"current_range,omitempty"`
+	Priority                 int                  `json:"priority,omitempty"`
+	QueuePosition            int                  `json:"queue_position,omitempty"` // 1-based place among jobs waiting for a slot
+	// archive_per_batch: archives sealed so far, available for download while the job runs
+	BatchArchives []domain.BatchArchive `json:"batch_archives,omitempty"`
 }
 
 func (s *ExportJobStatus) clone() *ExportJobStatus {
@@ -52,25 +67,50 @@ func (s *ExportJobStatus) clone() *ExportJobStatus {
 		return nil
 	}
 	clone := *s
+	clone.BatchArchives = append([]domain.BatchArchive(nil), s.BatchArchives...)
 	return &clone
 }
 
 type exportJob struct {
 	status        *ExportJobStatus
 	durationTotal time.Duration
+	// aheadOfPrefix holds 0-based windows completed after a gap; CompletedBatches counts them too,
+	// but only the gap-free prefix (CompletedBatches - len(aheadOfPrefix)) is safe to resume from
+	aheadOfPrefix map[int]struct{}
 	cancel        context.CancelFunc
+	done          chan struct{}
 	config        domain.ExportConfig
 	resumeFrom    int
 	baseMetrics   int
+	// ctx is kept while the job waits in the queue and handed to runJob when it starts
+	ctx context.Context
 }
 
+// persistedExportJob is the on-disk form of a resumable job written on shutdown
+type persistedExportJob struct {
+	Status *ExportJobStatus    `json:"status"`
+	Config domain.ExportConfig `json:"config"`
+}
+
+// errShutdownInterrupted marks jobs canceled because the process is shutting down
+var errShutdownInterrupted = errors.New("interrupted by shutdown; resume to continue")
+
+// errExportIDInUse rejects a job whose export_id belongs to another pending or running job,
+// since both would write the same archive
+var errExportIDInUse = errors.New("export_id is already used by an active job")
+
 type ExportJobManager struct {
 	exportService     services.ExportService
 	mu                sync.RWMutex
 	jobs              map[string]*exportJob
 	maxConcurrentJobs int
+	maxQueuedJobs     int
 	retention         time.Duration
 	activeJobs        int
+	queue             []string // IDs of jobs waiting for a slot, highest priority first
+	statePath         string
+	shuttingDown      bool
+	progressInterval  time.Duration
 }
 
 func NewExportJobManager(service services.ExportService) *ExportJobManager {
@@ -78,7 +118,9 @@ func NewExportJobManager(service services.ExportService) *ExportJobManager {
 		exportService:     service,
 		jobs:              make(map[string]*exportJob),
 		maxConcurrentJobs: defaultMaxConcurrentJobs,
+		maxQueuedJobs:     defaultMaxQueuedJobs,
 		retention:         defaultJobRetention,
+		progressInterval:  defaultProgressInterval,
 	}
 }
 
@@ -104,29 +146,127 @@ func (m *ExportJobManager) StartJob(ctx context.Context, jobID string, config do
 		BatchWindowSeconds: batchWindowSeconds,
 		StagingPath:        config.StagingFile,
 		ObfuscationEnabled: config.Obfuscation.Enabled,
+		Priority:           config.Priority,
 	}
 
 	// Export execution is already governed by per-request/per-batch timeouts inside the export service.
 	// Do not apply a fixed hard deadline here, since large exports can legitimately take hours.
 	jobCtx, cancel := context.WithCancel(context.Background())
-	job := &exportJob{status: status, cancel: cancel, config: config}
+	job := &exportJob{status: status, cancel: cancel, done: make(chan struct{}), config: config}
 
 	m.mu.Lock()
 	m.cleanupLocked(time.Now())
-	if m.activeJobs >= m.maxConcurrentJobs {
+	if m.shuttingDown {
+		m.mu.Unlock()
+		cancel()
+		return nil, fmt.Errorf("server is shutting down")
+	}
+	if err := m.checkExportIDLocked(jobID, config.ExportID); err != nil {
 		m.mu.Unlock()
-		return nil, fmt.Errorf("maximum concurrent exports reached (%d)", m.maxConcurrentJobs)
+		cancel()
+		return nil, err
+	}
+	if err := m.checkCapacityLocked(); err != nil {
+		m.mu.Unlock()
+		cancel()
+		return nil, err
 	}
 	m.jobs[jobID] = job
-	m.activeJobs++
-	statusSnapshot := status.clone()
+	m.scheduleLocked(jobID, job, jobCtx)
+	statusSnapshot := m.statusLocked(jobID, job)
 	m.mu.Unlock()
 
-	go m.runJob(jobCtx, jobID, config)
-
 	return statusSnapshot, nil
 }
 
+// checkExportIDLocked fails when another pending or running job uses the same export_id
+func (m *ExportJobManager) checkExportIDLocked(jobID, exportID string) error {
+	if exportID == "" {
+		return nil
+	}
+	for id, other := range m.jobs {
+		if id == jobID || other.config.ExportID != exportID {
+			continue
+		}
+		if other.status.State == JobPending || other.status.State == JobRunning {
+			return fmt.Errorf("%w: %s (%s)", errExportIDInUse, exportID, id)
+		}
+	}
+	return nil
+}
+
+// checkCapacityLocked fails when every slot is busy and the queue is full
+func (m *ExportJobManager) checkCapacityLocked() error {
+	if m.activeJobs < m.maxConcurrentJobs || len(m.queue) < m.maxQueuedJobs {
+		return nil
+	}
+	if m.maxQueuedJobs <= 0 {
+		return fmt.Errorf("maximum concurrent exports reached (%d)", m.maxConcurrentJobs)
+	}
+	return fmt.Errorf("maximum concurrent exports reached (%d) and %d exports are already queued", m.maxConcurrentJobs, len(m.queue))
+}
+
+// scheduleLocked starts the job when a slot is free and queues it otherwise. The queue is kept
+// ordered by priority, and jobs of equal priority keep their arrival order.
+func (m *ExportJobManager) scheduleLocked(jobID string, job *exportJob, ctx context.Context) {
+	if m.activeJobs < m.maxConcurrentJobs {
+		m.activeJobs++
+		go m.runJob(ctx, jobID, job.config)
+		return
+	}
+	job.ctx = ctx
+	at := len(m.queue)
+	for i, id := range m.queue {
+		if queued, ok := m.jobs[id]; ok && queued.config.Priority < job.config.Priority {
+			at = i
+			break
+		}
+	}
+	m.queue = append(m.queue, "")
+	copy(m.queue[at+1:], m.queue[at:])
+	m.queue[at] = jobID
+}
+
+// startQueuedLocked fills free slots from the head of the queue
+func (m *ExportJobManager) startQueuedLocked() {
+	for !m.shuttingDown && m.activeJobs < m.maxConcurrentJobs && len(m.queue) > 0 {
+		jobID := m.queue[0]
+		m.queue = m.queue[1:]
+		job, ok := m.jobs[jobID]
+		if !ok || job.ctx == nil {
+			continue
+		}
+		ctx := job.ctx
+		job.ctx = nil
+		m.activeJobs++
+		go m.runJob(ctx, jobID, job.config)
+	}
+}
+
+// dequeueLocked removes a waiting job from the queue and reports whether it was queued
+func (m *ExportJobManager) dequeueLocked(jobID string) bool {
+	for i, id := range m.queue {
+		if id == jobID {
+			m.queue = append(m.queue[:i], m.queue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// statusLocked returns a copy of the job status with its current queue position
+func (m *ExportJobManager) statusLocked(jobID string, job *exportJob) *ExportJobStatus {
+	status := job.status.clone()
+	status.QueuePosition = 0
+	for i, id := range m.queue {
+		if id == jobID {
+			status.QueuePosition = i + 1
+			break
+		}
+	}
+	return status
+}
+
 func (m *ExportJobManager) GetStatus(jobID string) (*ExportJobStatus, bool) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -134,10 +274,16 @@ func (m *ExportJobManager) GetStatus(jobID string) (*ExportJobStatus, bool) {
 	if !exists {
 		return nil, false
 	}
-	return job.status.clone(), true
+	return m.statusLocked(jobID, job), true
 }
 
 func (m *ExportJobManager) ResumeJob(ctx context.Context, jobID string) (*ExportJobStatus, error) {
+	return m.ResumeJobWithConnection(ctx, jobID, nil)
+}
+
+// ResumeJobWithConnection resumes a job, optionally replacing its connection.
+// Jobs restored after a restart have their credentials stripped and need the connection resupplied.
+func (m *ExportJobManager) ResumeJobWithConnection(ctx context.Context, jobID string, conn *domain.VMConnection) (*ExportJobStatus, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	job, exists := m.jobs[jobID]
@@ -148,22 +294,34 @@ func (m *ExportJobManager) ResumeJob(ctx context.Context, jobID string) (*Export
 		return nil, fmt.Errorf("job %s is not resumable", jobID)
 	}
 
-	resumeFrom := job.status.CompletedBatches
+	resumeFrom := job.resumePoint()
 	baseMetrics := job.status.MetricsProcessed
 	cfg := job.config
 	cfg.ResumeFromBatch = resumeFrom
 	if job.status.StagingPath != "" {
 		cfg.StagingFile = job.status.StagingPath
 	}
-	if m.activeJobs >= m.maxConcurrentJobs {
-		return nil, fmt.Errorf("maximum concurrent exports reached (%d)", m.maxConcurrentJobs)
+	if m.shuttingDown {
+		return nil, fmt.Errorf("server is shutting down")
+	}
+	if err := m.checkExportIDLocked(jobID, cfg.ExportID); err != nil {
+		return nil, err
+	}
+	if err := m.checkCapacityLocked(); err != nil {
+		return nil, err
+	}
+	if conn != nil {
+		cfg.Connection = *conn
 	}
 	jobCtx, cancel := context.WithCancel(context.Background())
 	job.cancel = cancel
+	job.done = make(chan struct{})
 	job.config = cfg
 	job.resumeFrom = resumeFrom
 	job.baseMetrics = baseMetrics
 	job.durationTotal = 0
+	job.aheadOfPrefix = nil
+	job.status.CompletedBatches = resumeFrom
 	job.status.StagingPath = cfg.StagingFile
 
 	job.status.State = JobPending
@@ -171,11 +329,10 @@ func (m *ExportJobManager) ResumeJob(ctx context.Context, jobID string) (*Export
 	job.status.Result = nil
 	job.status.CompletedAt = nil
 	job.status.ETA = nil
+	job.status.Priority = cfg.Priority
 
-	m.activeJobs++
-
-	go m.runJob(jobCtx, jobID, cfg)
-	return job.status.clone(), nil
+	m.scheduleLocked(jobID, job, jobCtx)
+	return m.statusLocked(jobID, job), nil
 }
 
 func (m *ExportJobManager) runJob(ctx context.Context, jobID string, config domain.ExportConfig) {
@@ -183,30 +340,51 @@ func (m *ExportJobManager) runJob(ctx context.Context, jobID string, config doma
 	baseMetrics := 0
 	m.mu.RLock()
 	job, ok := m.jobs[jobID]
-	m.mu.RUnlock()
+	var done chan struct{}
 	if ok {
 		baseBatches = job.resumeFrom
 		baseMetrics = job.baseMetrics
+		done = job.done
+	}
+	m.mu.RUnlock()
+	if done != nil {
+		defer close(done)
 	}
 	if baseBatches > 0 {
 		config.ResumeFromBatch = baseBatches
 	}
-	reporter := &jobProgressReporter{manager: m, jobID: jobID, baseBatches: baseBatches, baseMetrics: baseMetrics}
+	m.mu.RLock()
+	interval := m.progressInterval
+	m.mu.RUnlock()
+	reporter := &jobProgressReporter{manager: m, jobID: jobID, baseBatches: baseBatches, baseMetrics: baseMetrics, interval: interval}
 	ctx = services.WithProgressReporter(ctx, reporter)
 
 	m.markRunning(jobID)
 
 	result, err := m.exportService.ExecuteExport(ctx, config)
+	// Publish progress held back by the throttle before the terminal state
+	reporter.flush()
 	if err != nil {
 		if errors.Is(err, context.Canceled) {
+			m.mu.RLock()
+			shuttingDown := m.shuttingDown
+			m.mu.RUnlock()
+			if shuttingDown {
+				// The job resumes after restart; notify once it really finishes
+				m.markCanceled(jobID, errShutdownInterrupted)
+				return
+			}
 			m.markCanceled(jobID, err)
+			m.notifyWebhook(jobID, config)
 		} else {
 			m.markFailed(jobID, err)
+			m.notifyWebhook(jobID, config)
 		}
 		return
 	}
 
 	m.markCompleted(jobID, result)
+	m.notifyWebhook(jobID, config)
 }
 
 func (m *ExportJobManager) markRunning(jobID string) {
@@ -255,47 +433,71 @@ func (m *ExportJobManager) markCompleted(jobID string, result *domain.ExportResu
 	}
 }
 
+// updateBatch aggregates a batch completion into the job status. Completions may arrive
+// out of order from concurrent workers: windows are counted once, so completed batches,
+// progress and metrics only ever grow, and the ETA uses wall-clock throughput since the
+// run started, which stays accurate when batch durations overlap.
 func (m *ExportJobManager) updateBatch(jobID string, progress services.BatchProgress, baseBatches int, baseMetrics int) {
+	m.updateBatches(jobID, []services.BatchProgress{progress}, baseBatches, baseMetrics)
+}
+
+// updateBatches aggregates several batch completions under a single lock acquisition
+func (m *ExportJobManager) updateBatches(jobID string, batches []services.BatchProgress, baseBatches int, baseMetrics int) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	job, exists := m.jobs[jobID]
 	if !exists {
 		return
 	}
+	for _, progress := range batches {
+		job.applyBatch(progress, baseBatches, baseMetrics)
+	}
+}
 
+func (job *exportJob) applyBatch(progress services.BatchProgress, baseBatches int, baseMetrics int) {
 	if progress.TotalBatches > 0 {
 		job.status.TotalBatches = progress.TotalBatches
 	}
-
-	if progress.BatchIndex > job.status.CompletedBatches {
-		job.status.CompletedBatches = progress.BatchIndex
+	if job.markWindows(progress) == 0 {
+		// Every window was already counted, e.g. a retried batch
+		return
 	}
+
 	if job.status.TotalBatches > 0 {
 		p := float64(job.status.CompletedBatches) / float64(job.status.TotalBatches)
 		if p > 1.0 {
 			p = 1.0
 		}
-		job.status.Progress = p
+		if p > job.status.Progress {
+			job.status.Progress = p
+		}
 	}
 
 	if baseMetrics > 0 && job.status.MetricsProcessed < baseMetrics {
 		job.status.MetricsProcessed = baseMetrics
 	}
 	job.status.MetricsProcessed += progress.Metrics
+	job.status.SeriesWithSamples += progress.SeriesWithSamples
+	if progress.Archive != nil {
+		job.status.BatchArchives = append(job.status.BatchArchives, *progress.Archive)
+	}
 	job.status.LastBatchDurationSeconds = progress.Duration.Seconds()
 	job.durationTotal += progress.Duration
 
-	if job.status.CompletedBatches > 0 {
-		observedBatches := job.status.CompletedBatches - baseBatches
-		if observedBatches <= 0 {
-			observedBatches = job.status.CompletedBatches
-		}
-		avg := job.durationTotal.Seconds() / float64(observedBatches)
-		job.status.AverageBatchSeconds = avg
+	observedBatches := job.status.CompletedBatches - baseBatches
+	if observedBatches <= 0 {
+		observedBatches = job.status.CompletedBatches
+	}
+	if observedBatches > 0 {
+		job.status.AverageBatchSeconds = job.durationTotal.Seconds() / float64(observedBatches)
 
 		remaining := job.status.TotalBatches - job.status.CompletedBatches
-		if remaining > 0 && avg > 0 {
-			eta := time.Now().Add(time.Duration(avg*float64(remaining)) * time.Second)
+		perBatch := job.durationTotal / time.Duration(observedBatches)
+		if job.status.StartedAt != nil {
+			perBatch = time.Since(*job.status.StartedAt) / time.Duration(observedBatches)
+		}
+		if remaining > 0 && perBatch > 0 {
+			eta := time.Now().Add(perBatch * time.Duration(remaining))
 			job.status.ETA = &eta
 		} else {
 			job.status.ETA = nil
@@ -308,11 +510,64 @@ func (m *ExportJobManager) updateBatch(jobID string, progress services.BatchProg
 	}
 }
 
+// markWindows records the base windows covered by a completion and returns how many were new
+func (job *exportJob) markWindows(progress services.BatchProgress) int {
+	span := progress.Batches
+	if span <= 0 {
+		span = 1
+	}
+	added := 0
+	for window := progress.BatchIndex - span; window < progress.BatchIndex; window++ {
+		prefix := job.resumePoint()
+		if window < prefix {
+			continue
+		}
+		if _, seen := job.aheadOfPrefix[window]; seen {
+			continue
+		}
+		if job.aheadOfPrefix == nil {
+			job.aheadOfPrefix = make(map[int]struct{})
+		}
+		job.aheadOfPrefix[window] = struct{}{}
+		job.status.CompletedBatches++
+		added++
+		// Fold windows that now continue the gap-free prefix back into it
+		for {
+			if _, ok := job.aheadOfPrefix[prefix]; !ok {
+				break
+			}
+			delete(job.aheadOfPrefix, prefix)
+			prefix++
+		}
+	}
+	return added
+}
+
+// resumePoint is the number of leading windows completed without gaps
+func (job *exportJob) resumePoint() int {
+	return job.status.CompletedBatches - len(job.aheadOfPrefix)
+}
+
 func (m *ExportJobManager) jobFinishedLocked() {
 	if m.activeJobs > 0 {
 		m.activeJobs--
 	}
 	m.cleanupLocked(time.Now())
+	m.startQueuedLocked()
+}
+
+// cancelQueuedLocked finishes a job that never left the queue; it holds no slot
+func (m *ExportJobManager) cancelQueuedLocked(job *exportJob, err error) {
+	job.cancel()
+	job.cancel = nil
+	job.ctx = nil
+	now := time.Now()
+	job.status.State = JobCanceled
+	job.status.CompletedAt = &now
+	job.status.Error = err.Error()
+	if job.done != nil {
+		close(job.done)
+	}
 }
 
 func (m *ExportJobManager) markCanceled(jobID string, err error) {
@@ -347,6 +602,11 @@ func (m *ExportJobManager) CancelJob(jobID string) error {
 	if job.status.State == JobCompleted || job.status.State == JobFailed || job.status.State == JobCanceled {
 		return fmt.Errorf("job %s already finished", jobID)
 	}
+	if m.dequeueLocked(jobID) {
+		m.cancelQueuedLocked(job, context.Canceled)
+		go m.notifyWebhook(jobID, job.config)
+		return nil
+	}
 	if job.cancel != nil {
 		job.cancel()
 	}
@@ -363,13 +623,267 @@ func (m *ExportJobManager) cleanupLocked(now time.Time) {
 	}
 }
 
+// jobProgressReporter publishes batch completions to the job status at most once per interval.
+// Completions in between are held back and published together when the interval ends, so fast
+// exports neither contend on the manager lock nor lose any window; runJob flushes what is left
+// before the terminal state.
 type jobProgressReporter struct {
 	manager     *ExportJobManager
 	jobID       string
 	baseBatches int
 	baseMetrics int
+	interval    time.Duration
+
+	mu          sync.Mutex
+	pending     []services.BatchProgress
+	lastPublish time.Time
+	timer       *time.Timer
 }
 
 func (r *jobProgressReporter) OnBatchComplete(progress services.BatchProgress) {
-	r.manager.updateBatch(r.jobID, progress, r.baseBatches, r.baseMetrics)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pending = append(r.pending, progress)
+	if r.interval > 0 && !r.lastPublish.IsZero() {
+		if wait := r.interval - time.Since(r.lastPublish); wait > 0 {
+			if r.timer == nil {
+				r.timer = time.AfterFunc(wait, r.flush)
+			}
+			return
+		}
+	}
+	r.publishLocked()
+}
+
+// flush publishes every held back completion
+func (r *jobProgressReporter) flush() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.publishLocked()
+}
+
+func (r *jobProgressReporter) publishLocked() {
+	if r.timer != nil {
+		r.timer.Stop()
+		r.timer = nil
+	}
+	if len(r.pending) == 0 {
+		return
+	}
+	r.manager.updateBatches(r.jobID, r.pending, r.baseBatches, r.baseMetrics)
+	r.pending = nil
+	r.lastPublish = time.Now()
+}
+
+// SetProgressInterval sets the shortest time between two published progress updates of a job;
+// 0 publishes every batch completion. The final progress is always published.
+func (m *ExportJobManager) SetProgressInterval(interval time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.progressInterval = interval
+}
+
+// SetStatePath sets the file used to persist resumable jobs across restarts
+func (m *ExportJobManager) SetStatePath(path string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.statePath = path
+}
+
+// Shutdown cancels running jobs, waits for them to stop, fsyncs their staging files
+// and persists resumable jobs so they can be resumed after a restart.
+func (m *ExportJobManager) Shutdown(ctx context.Context) error {
+	m.mu.Lock()
+	m.shuttingDown = true
+	// Queued jobs never started; they are kept as interrupted so they can be resumed
+	for _, jobID := range m.queue {
+		if job, ok := m.jobs[jobID]; ok {
+			m.cancelQueuedLocked(job, errShutdownInterrupted)
+		}
+	}
+	m.queue = nil
+	var waiting []chan struct{}
+	for _, job := range m.jobs {
+		if job.status.State != JobPending && job.status.State != JobRunning {
+			continue
+		}
+		if job.cancel != nil {
+			job.cancel()
+		}
+		if job.done != nil {
+			waiting = append(waiting, job.done)
+		}
+	}
+	m.mu.Unlock()
+
+	var waitErr error
+	for _, done := range waiting {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			waitErr = fmt.Errorf("timed out waiting for export jobs to stop: %w", ctx.Err())
+		}
+		if waitErr != nil {
+			break
+		}
+	}
+
+	if err := m.persistState(); err != nil {
+		return err
+	}
+	return waitErr
+}
+
+func (m *ExportJobManager) persistState() error {
+	m.mu.RLock()
+	statePath := m.statePath
+	var persisted []persistedExportJob
+	for _, job := range m.jobs {
+		if job.status.State != JobCanceled && job.status.State != JobFailed {
+			continue
+		}
+		if job.status.StagingPath == "" {
+			continue
+		}
+		status := job.status.clone()
+		// Windows completed after a gap are refetched on resume, so only the prefix is kept
+		status.CompletedBatches = job.resumePoint()
+		persisted = append(persisted, persistedExportJob{
+			Status: status,
+			Config: redactExportConfig(job.config),
+		})
+	}
+	m.mu.RUnlock()
+
+	for _, job := range persisted {
+		if err := syncFile(job.Status.StagingPath); err != nil && !os.IsNotExist(err) {
+			log.Printf("[WARN] Failed to fsync staging file %s: %v", job.Status.StagingPath, err)
+		}
+	}
+
+	if statePath == "" {
+		return nil
+	}
+	if len(persisted) == 0 {
+		if err := os.Remove(statePath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove job state: %w", err)
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(persisted, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode job state: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(statePath), 0o755); err != nil {
+		return fmt.Errorf("failed to prepare job state directory: %w", err)
+	}
+	tmpPath := statePath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write job state: %w", err)
+	}
+	if err := syncFile(tmpPath); err != nil {
+		return fmt.Errorf("failed to fsync job state: %w", err)
+	}
+	if err := os.Rename(tmpPath, statePath); err != nil {
+		return fmt.Errorf("failed to write job state: %w", err)
+	}
+	return nil
+}
+
+// RestoreState loads jobs persisted by a previous Shutdown as canceled, resumable jobs.
+func (m *ExportJobManager) RestoreState() (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.statePath == "" {
+		return 0, nil
+	}
+	data, err := os.ReadFile(m.statePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to read job state: %w", err)
+	}
+	var persisted []persistedExportJob
+	if err := json.Unmarshal(data, &persisted); err != nil {
+		return 0, fmt.Errorf("failed to decode job state: %w", err)
+	}
+
+	now := time.Now()
+	restored := 0
+	for _, entry := range persisted {
+		if entry.Status == nil || entry.Status.ID == "" {
+			continue
+		}
+		if _, exists := m.jobs[entry.Status.ID]; exists {
+			continue
+		}
+		status := entry.Status
+		status.State = JobCanceled
+		status.CompletedAt = &now
+		status.ETA = nil
+		status.CurrentRange = nil
+		m.jobs[status.ID] = &exportJob{status: status, config: entry.Config}
+		restored++
+	}
+	if err := os.Remove(m.statePath); err != nil && !os.IsNotExist(err) {
+		log.Printf("[WARN] Failed to remove job state %s: %v", m.statePath, err)
+	}
+	return restored, nil
+}
+
+// redactExportConfig strips credentials so they are never written to disk, including
+// user:password@ in URLs, which the VM client would send as basic auth
+func redactExportConfig(config domain.ExportConfig) domain.ExportConfig {
+	config.Connection.Auth.Password = ""
+	config.Connection.Auth.Token = ""
+	config.Connection.Auth.HeaderValue = ""
+	config.Connection.Headers = nil
+	config.Connection.URL = withoutUserinfo(config.Connection.URL)
+	config.Connection.FullApiUrl = withoutUserinfo(config.Connection.FullApiUrl)
+	config.VMAlertURL = withoutUserinfo(config.VMAlertURL)
+	if config.Upload != nil {
+		target := *config.Upload
+		target.Auth.Password = ""
+		target.Auth.Token = ""
+		target.Auth.HeaderValue = ""
+		if u, err := url.Parse(target.URL); err == nil {
+			target.URL = upload.PublicURL(u)
+		}
+		config.Upload = &target
+	}
+	if config.Webhook != nil {
+		target := *config.Webhook
+		target.Auth.Password = ""
+		target.Auth.Token = ""
+		target.Auth.HeaderValue = ""
+		if u, err := url.Parse(target.URL); err == nil {
+			target.URL = upload.PublicURL(u)
+		}
+		config.Webhook = &target
+	}
+	return config
+}
+
+// withoutUserinfo removes user:password@ from rawURL; other parts are kept as they are
+func withoutUserinfo(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.User == nil {
+		return rawURL
+	}
+	u.User = nil
+	return u.String()
+}
+
+func syncFile(path string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	syncErr := file.Sync()
+	closeErr := file.Close()
+	if syncErr != nil {
+		return syncErr
+	}
+	return closeErr
 }
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/archive"
+)
+
+// exportListEntry describes one archive in the output directory. Fields from metadata.json are
+// empty when the archive cannot be read; Error says why.
+type exportListEntry struct {
+	Name       string    `json:"name"`
+	Path       string    `json:"path"`
+	SizeBytes  int64     `json:"size_bytes"`
+	ModifiedAt time.Time `json:"modified_at"`
+	SHA256     string    `json:"sha256,omitempty"`
+	*archive.ArchiveSummary
+	Error string `json:"error,omitempty"`
+}
+
+// handleExports serves GET (list archives) and DELETE (remove one archive) on /api/exports
+func (s *Server) handleExports(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.handleListExports(w, r)
+	case http.MethodDelete:
+		s.handleDeleteExport(w, r)
+	default:
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// handleListExports lists the vmexport_*.zip archives directly inside the output directory,
+// newest first, with their metadata. Staging files and partial archives are not listed; an
+// archive that cannot be read is listed with an error instead of failing the request.
+func (s *Server) handleListExports(w http.ResponseWriter, _ *http.Request) {
+	entries, err := os.ReadDir(s.outputDir)
+	if err != nil && !os.IsNotExist(err) {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("failed to list %s: %v", s.outputDir, err))
+		return
+	}
+	exports := make([]exportListEntry, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !isExportArchiveName(entry.Name()) {
+			continue
+		}
+		exports = append(exports, describeExportArchive(filepath.Join(s.outputDir, entry.Name())))
+	}
+	sort.SliceStable(exports, func(i, j int) bool {
+		if !exports[i].ModifiedAt.Equal(exports[j].ModifiedAt) {
+			return exports[i].ModifiedAt.After(exports[j].ModifiedAt)
+		}
+		return exports[i].Name < exports[j].Name
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(exports)
+}
+
+// handleDeleteExport removes the archive named by the path parameter. The path is checked like a
+// download (403 outside the output directory, 404 when missing), and only finished
+// vmexport_*.zip archives may be removed, so staging files of running exports stay untouched.
+func (s *Server) handleDeleteExport(w http.ResponseWriter, r *http.Request) {
+	filePath := r.URL.Query().Get("path")
+	if filePath == "" {
+		respondWithError(w, http.StatusBadRequest, "Missing path parameter")
+		return
+	}
+	absFilePath, info, status, err := s.resolveOutputFile(filePath)
+	if err != nil {
+		respondWithError(w, status, err.Error())
+		return
+	}
+	if !info.Mode().IsRegular() || !isExportArchiveName(filepath.Base(absFilePath)) {
+		respondWithError(w, http.StatusBadRequest, "Only export archives (vmexport_*.zip) can be deleted")
+		return
+	}
+	if err := os.Remove(absFilePath); err != nil {
+		if os.IsNotExist(err) {
+			respondWithError(w, http.StatusNotFound, fmt.Sprintf("File not found: %s", filePath))
+			return
+		}
+		log.Printf("[ERROR] Failed to delete %s: %v", absFilePath, err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to delete archive")
+		return
+	}
+	log.Printf("[OK] Deleted export archive: %s", absFilePath)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"deleted": true,
+		"path":    absFilePath,
+	})
+}
+
+// isExportArchiveName matches archives written by the export writer, skipping partial files
+func isExportArchiveName(name string) bool {
+	matched, _ := filepath.Match("vmexport_*.zip", name)
+	return matched && !strings.Contains(name, ".partial")
+}
+
+func describeExportArchive(path string) exportListEntry {
+	entry := exportListEntry{Name: filepath.Base(path), Path: path}
+	info, err := os.Stat(path)
+	if err != nil {
+		entry.Error = err.Error()
+		return entry
+	}
+	entry.SizeBytes = info.Size()
+	entry.ModifiedAt = info.ModTime().UTC()
+	if entry.SHA256, err = fileSHA256(path); err != nil {
+		entry.Error = fmt.Sprintf("failed to read archive: %v", err)
+		return entry
+	}
+	if entry.ArchiveSummary, err = archive.ReadArchiveSummary(path); err != nil {
+		entry.Error = err.Error()
+	}
+	return entry
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/archive"
+)
+
+func TestHandleListExports(t *testing.T) {
+	outputDir := t.TempDir()
+	writer := archive.NewWriter(outputDir)
+	end := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+	sums := map[string]string{}
+	for i, id := range []string{"export-a", "export-b"} {
+		path, sum, err := writer.CreateArchive(id, strings.NewReader(`{"metric":{"__name__":"up"},"values":[1],"timestamps":[1000]}`+"\n"), archive.ArchiveMetadata{
+			ExportID:     id,
+			TimeRange:    domain.TimeRange{Start: end.Add(-time.Hour), End: end},
+			Components:   []string{"vmsingle"},
+			MetricsCount: 1 + i,
+			Obfuscated:   i == 1,
+		})
+		if err != nil {
+			t.Fatalf("failed to create archive: %v", err)
+		}
+		sums[id] = sum
+		// Distinct modification times make the newest-first order deterministic
+		mtime := end.Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatalf("failed to set mtime: %v", err)
+		}
+	}
+	for name, content := range map[string]string{
+		"vmexport_corrupt.zip":         "not a zip",
+		"vmexport_x.partial.zip":       "partial",
+		"other.zip":                    "not an export",
+		"staging/vmexport_staged.zip":  "staged",
+		"vmexport_x.jsonl.partial.zip": "partial",
+	} {
+		path := filepath.Join(outputDir, name)
+		_ = os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	srv := NewServer(outputDir, "test", false)
+	rr := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/exports", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var listed []struct {
+		Name         string           `json:"name"`
+		SizeBytes    int64            `json:"size_bytes"`
+		SHA256       string           `json:"sha256"`
+		ExportID     string           `json:"export_id"`
+		TimeRange    domain.TimeRange `json:"time_range"`
+		Components   []string         `json:"components"`
+		MetricsCount int              `json:"metrics_count"`
+		Obfuscated   bool             `json:"obfuscated"`
+		Error        string           `json:"error"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &listed); err != nil {
+		t.Fatalf("failed to decode listing: %v", err)
+	}
+	if len(listed) != 3 {
+		t.Fatalf("expected two archives and the corrupt one, got %+v", listed)
+	}
+
+	byID := map[string]int{}
+	for i, entry := range listed {
+		if entry.Name == "vmexport_corrupt.zip" {
+			if entry.Error == "" || entry.ExportID != "" || entry.SizeBytes != int64(len("not a zip")) {
+				t.Fatalf("expected the corrupt archive to carry an error, got %+v", entry)
+			}
+			continue
+		}
+		if entry.Error != "" || entry.SHA256 != sums[entry.ExportID] || entry.SizeBytes == 0 {
+			t.Fatalf("unexpected entry %+v", entry)
+		}
+		if !entry.TimeRange.End.Equal(end) || len(entry.Components) != 1 || entry.Components[0] != "vmsingle" {
+			t.Fatalf("expected metadata in the entry, got %+v", entry)
+		}
+		byID[entry.ExportID] = i
+	}
+	if byID["export-b"] >= byID["export-a"] {
+		t.Fatalf("expected the newest archive first, got %+v", listed)
+	}
+	if b := listed[byID["export-b"]]; !b.Obfuscated || b.MetricsCount != 2 {
+		t.Fatalf("expected export-b metadata, got %+v", b)
+	}
+
+	rr = httptest.NewRecorder()
+	srv.Router().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/exports", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405 for POST, got %d", rr.Code)
+	}
+}
+
+func TestHandleDeleteExport(t *testing.T) {
+	tempDir := t.TempDir()
+	outputDir := filepath.Join(tempDir, "exports")
+	if err := os.Mkdir(outputDir, 0o755); err != nil {
+		t.Fatalf("failed to create output dir: %v", err)
+	}
+	archivePath := filepath.Join(outputDir, "vmexport_a.zip")
+	stagingPath := filepath.Join(outputDir, "staging.jsonl")
+	outsidePath := filepath.Join(tempDir, "vmexport_outside.zip")
+	for _, path := range []string{archivePath, stagingPath, outsidePath} {
+		if err := os.WriteFile(path, []byte("data"), 0o644); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+	}
+
+	srv := NewServer(outputDir, "test", false)
+	remove := func(path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		srv.Router().ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/api/exports?path="+url.QueryEscape(path), nil))
+		return rr
+	}
+
+	tests := []struct {
+		name string
+		path string
+		want int
+	}{
+		{name: "relative traversal", path: "../../etc/passwd", want: http.StatusForbidden},
+		{name: "traversal from the output directory", path: outputDir + "/../vmexport_outside.zip", want: http.StatusForbidden},
+		{name: "missing archive", path: filepath.Join(outputDir, "vmexport_missing.zip"), want: http.StatusNotFound},
+		{name: "not an archive", path: stagingPath, want: http.StatusBadRequest},
+		{name: "output directory itself", path: outputDir, want: http.StatusBadRequest},
+		{name: "missing path", path: "", want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rr := remove(tt.path); rr.Code != tt.want {
+				t.Fatalf("expected %d, got %d: %s", tt.want, rr.Code, rr.Body.String())
+			}
+		})
+	}
+	for _, path := range []string{stagingPath, outsidePath} {
+		if _, err := os.Stat(path); err != nil {
+			t.Fatalf("expected %s to be kept, got %v", path, err)
+		}
+	}
+
+	rr := remove(archivePath)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if _, err := os.Stat(archivePath); !os.IsNotExist(err) {
+		t.Fatalf("expected the archive to be removed, got %v", err)
+	}
+	if rr = remove(archivePath); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a second delete, got %d", rr.Code)
+	}
+}
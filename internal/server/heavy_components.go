@@ -0,0 +1,154 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/application/services"
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+)
+
+// DefaultHeavyComponentSeries is the discovery estimate above which a component has to be listed
+// in confirmed_heavy_components before /api/export/start includes it
+const DefaultHeavyComponentSeries = 1_000_000
+
+// maxDiscoveryEstimates bounds how many connections' discovery results are remembered
+const maxDiscoveryEstimates = 64
+
+// heavyEstimateTimeout bounds the discovery run by /api/export/start for an undiscovered connection
+const heavyEstimateTimeout = 30 * time.Second
+
+// heavyComponent is a component whose discovery estimate needs confirmation
+type heavyComponent struct {
+	Component            string `json:"component"`
+	MetricsCountEstimate int    `json:"metrics_count_estimate"`
+}
+
+// discoveryEstimates remembers the latest component discovery per connection, so an export
+// started afterwards can be checked against it. Past maxDiscoveryEstimates connections the
+// least recently discovered one is forgotten.
+type discoveryEstimates struct {
+	mu     sync.Mutex
+	byConn map[string][]domain.VMComponent
+	order  []string // keys of byConn, least recently recorded first
+}
+
+func discoveryKey(conn domain.VMConnection) string {
+	return strings.Join([]string{strings.TrimRight(conn.URL, "/"), conn.ApiBasePath, conn.TenantId}, "|")
+}
+
+func (d *discoveryEstimates) record(conn domain.VMConnection, components []domain.VMComponent) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.byConn == nil {
+		d.byConn = make(map[string][]domain.VMComponent)
+	}
+	key := discoveryKey(conn)
+	if _, ok := d.byConn[key]; ok {
+		for i, existing := range d.order {
+			if existing == key {
+				d.order = append(d.order[:i], d.order[i+1:]...)
+				break
+			}
+		}
+	} else if len(d.order) >= maxDiscoveryEstimates {
+		delete(d.byConn, d.order[0])
+		d.order = d.order[1:]
+	}
+	d.byConn[key] = components
+	d.order = append(d.order, key)
+}
+
+func (d *discoveryEstimates) lookup(conn domain.VMConnection) ([]domain.VMComponent, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	components, ok := d.byConn[discoveryKey(conn)]
+	return components, ok
+}
+
+// SetHeavyComponentThreshold sets the discovery estimate in series above which a component must
+// be confirmed before export; 0 disables the check
+func (s *Server) SetHeavyComponentThreshold(series int) {
+	s.heavyLimit = series
+}
+
+// unconfirmedHeavyComponents lists the components of config whose latest discovery estimate
+// exceeds the threshold and which config does not confirm. With jobs selected only their
+// estimates count. A connection this server has not discovered yet, e.g. after a restart or
+// when the API is called directly, is discovered now; if that fails the export is refused.
+func (s *Server) unconfirmedHeavyComponents(ctx context.Context, config domain.ExportConfig) ([]heavyComponent, error) {
+	if s.heavyLimit <= 0 || len(config.Components) == 0 {
+		return nil, nil
+	}
+	confirmed := make(map[string]bool, len(config.HeavyConfirmed))
+	for _, name := range config.HeavyConfirmed {
+		confirmed[name] = true
+	}
+	unconfirmed := false
+	for _, name := range config.Components {
+		unconfirmed = unconfirmed || !confirmed[name]
+	}
+	if !unconfirmed {
+		return nil, nil
+	}
+	discovered, ok := s.estimates.lookup(config.Connection)
+	if !ok {
+		ctx, cancel := context.WithTimeout(ctx, heavyEstimateTimeout)
+		defer cancel()
+		components, err := s.discoverComponents(ctx, config.Connection, config.TimeRange, services.DiscoveryOptions{})
+		if err != nil {
+			errMsg, _ := formatVMError(err)
+			return nil, fmt.Errorf("cannot estimate component sizes (run discovery first or list the components in confirmed_heavy_components): %s", errMsg)
+		}
+		s.estimates.record(config.Connection, components)
+		discovered = components
+	}
+	selectedJobs := make(map[string]bool, len(config.Jobs))
+	for _, job := range config.Jobs {
+		selectedJobs[job] = true
+	}
+
+	estimates := make(map[string]int)
+	for _, component := range discovered {
+		if len(selectedJobs) == 0 || len(component.JobMetrics) == 0 {
+			estimates[component.Component] += component.MetricsCountEstimate
+			continue
+		}
+		for job, count := range component.JobMetrics {
+			if selectedJobs[job] {
+				estimates[component.Component] += count
+			}
+		}
+	}
+
+	var heavy []heavyComponent
+	for _, name := range config.Components {
+		if estimate := estimates[name]; estimate > s.heavyLimit && !confirmed[name] {
+			heavy = append(heavy, heavyComponent{Component: name, MetricsCountEstimate: estimate})
+		}
+	}
+	sort.Slice(heavy, func(i, j int) bool { return heavy[i].Component < heavy[j].Component })
+	return heavy, nil
+}
+
+// respondWithHeavyComponents answers 409 with the components the caller has to confirm
+func (s *Server) respondWithHeavyComponents(w http.ResponseWriter, heavy []heavyComponent) {
+	names := make([]string, 0, len(heavy))
+	for _, component := range heavy {
+		names = append(names, fmt.Sprintf("%s (~%d series)", component.Component, component.MetricsCountEstimate))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":            fmt.Sprintf("components estimated above %d series need confirmation: %s; list them in confirmed_heavy_components to export them", s.heavyLimit, strings.Join(names, ", ")),
+		"status":           http.StatusConflict,
+		"heavy_components": heavy,
+		"threshold":        s.heavyLimit,
+	})
+}
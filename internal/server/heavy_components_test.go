@@ -0,0 +1,160 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/application/services"
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+)
+
+// estimatesVMService discovers one heavy and one light component
+type estimatesVMService struct {
+	mockVMService
+}
+
+func (m *estimatesVMService) DiscoverComponentsWithOptions(ctx context.Context, conn domain.VMConnection, tr domain.TimeRange, opts services.DiscoveryOptions) ([]domain.VMComponent, error) {
+	return []domain.VMComponent{
+		{Component: "vmstorage", Jobs: []string{"vmstorage-big", "vmstorage-small"}, MetricsCountEstimate: 5_000_000, JobMetrics: map[string]int{"vmstorage-big": 4_990_000, "vmstorage-small": 10_000}},
+		{Component: "vminsert", Jobs: []string{"vminsert"}, MetricsCountEstimate: 20_000},
+	}, nil
+}
+
+func TestHandleExportStart_RejectsUnconfirmedHeavyComponent(t *testing.T) {
+	server := NewServer(t.TempDir(), "test-version", false)
+	server.vmService = &estimatesVMService{}
+	conn := domain.VMConnection{URL: "http://vmselect:8481", ApiBasePath: "/select/0/prometheus", Auth: domain.AuthConfig{Type: domain.AuthTypeNone}}
+	timeRange := domain.TimeRange{Start: time.Now().Add(-time.Hour), End: time.Now()}
+
+	config := domain.ExportConfig{
+		Connection: conn,
+		TimeRange:  timeRange,
+		Components: []string{"vmstorage", "vminsert"},
+		Batching:   domain.BatchSettings{Enabled: true},
+		StagingDir: t.TempDir(),
+	}
+	discoverBody, _ := json.Marshal(map[string]interface{}{"connection": conn, "time_range": timeRange})
+	w := httptest.NewRecorder()
+	server.Router().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/discover", bytes.NewReader(discoverBody)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("discovery failed: %d %s", w.Code, w.Body.String())
+	}
+
+	body, _ := json.Marshal(config)
+	w = httptest.NewRecorder()
+	server.Router().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/export/start", bytes.NewReader(body)))
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for an unconfirmed heavy component, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Error           string           `json:"error"`
+		HeavyComponents []heavyComponent `json:"heavy_components"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if len(resp.HeavyComponents) != 1 || resp.HeavyComponents[0].Component != "vmstorage" || resp.HeavyComponents[0].MetricsCountEstimate != 5_000_000 {
+		t.Fatalf("expected only vmstorage to need confirmation, got %+v", resp.HeavyComponents)
+	}
+	if !strings.Contains(resp.Error, "confirmed_heavy_components") {
+		t.Fatalf("expected the error to explain how to confirm, got %q", resp.Error)
+	}
+	if len(server.jobManager.jobs) != 0 {
+		t.Fatalf("expected no job to be started, got %d", len(server.jobManager.jobs))
+	}
+
+	confirmed := config
+	confirmed.HeavyConfirmed = []string{"vmstorage"}
+	if heavy, _ := server.unconfirmedHeavyComponents(context.Background(), confirmed); heavy != nil {
+		t.Fatalf("expected a confirmed component to pass, got %+v", heavy)
+	}
+	lightJobs := config
+	lightJobs.Jobs = []string{"vmstorage-small", "vminsert"}
+	if heavy, _ := server.unconfirmedHeavyComponents(context.Background(), lightJobs); heavy != nil {
+		t.Fatalf("expected only the selected jobs' estimates to count, got %+v", heavy)
+	}
+	server.SetHeavyComponentThreshold(0)
+	if heavy, _ := server.unconfirmedHeavyComponents(context.Background(), config); heavy != nil {
+		t.Fatalf("expected a zero threshold to disable the check, got %+v", heavy)
+	}
+}
+
+// failingDiscoveryVMService cannot reach the source
+type failingDiscoveryVMService struct {
+	mockVMService
+}
+
+func (m *failingDiscoveryVMService) DiscoverComponentsWithOptions(ctx context.Context, conn domain.VMConnection, tr domain.TimeRange, opts services.DiscoveryOptions) ([]domain.VMComponent, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestHandleExportStart_EstimatesUndiscoveredConnection(t *testing.T) {
+	config := domain.ExportConfig{
+		Connection: domain.VMConnection{URL: "http://vmselect:8481", Auth: domain.AuthConfig{Type: domain.AuthTypeNone}},
+		TimeRange:  domain.TimeRange{Start: time.Now().Add(-time.Hour), End: time.Now()},
+		Components: []string{"vmstorage"},
+		Batching:   domain.BatchSettings{Enabled: true},
+		StagingDir: t.TempDir(),
+	}
+	body, _ := json.Marshal(config)
+
+	server := NewServer(t.TempDir(), "test-version", false)
+	server.vmService = &estimatesVMService{}
+	w := httptest.NewRecorder()
+	server.Router().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/export/start", bytes.NewReader(body)))
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 without a prior discovery, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, ok := server.estimates.lookup(config.Connection); !ok {
+		t.Fatalf("expected the export-time estimate to be recorded")
+	}
+
+	server = NewServer(t.TempDir(), "test-version", false)
+	server.vmService = &failingDiscoveryVMService{}
+	w = httptest.NewRecorder()
+	server.Router().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/export/start", bytes.NewReader(body)))
+	if w.Code != http.StatusBadGateway {
+		t.Fatalf("expected 502 when the estimate fails, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(server.jobManager.jobs) != 0 {
+		t.Fatalf("expected no job to be started, got %d", len(server.jobManager.jobs))
+	}
+
+	confirmed := config
+	confirmed.HeavyConfirmed = []string{"vmstorage"}
+	if heavy, err := server.unconfirmedHeavyComponents(context.Background(), confirmed); err != nil || heavy != nil {
+		t.Fatalf("expected confirmed components to skip the estimate, got %+v, %v", heavy, err)
+	}
+}
+
+func TestDiscoveryEstimatesEvictOldest(t *testing.T) {
+	var estimates discoveryEstimates
+	conn := func(i int) domain.VMConnection {
+		return domain.VMConnection{URL: fmt.Sprintf("http://vmselect-%d:8481", i)}
+	}
+	for i := 0; i < maxDiscoveryEstimates; i++ {
+		estimates.record(conn(i), nil)
+	}
+	estimates.record(conn(0), nil)
+	estimates.record(conn(maxDiscoveryEstimates), nil)
+
+	if _, ok := estimates.lookup(conn(1)); ok {
+		t.Fatalf("expected the least recently recorded connection to be evicted")
+	}
+	for _, i := range []int{0, 2, maxDiscoveryEstimates} {
+		if _, ok := estimates.lookup(conn(i)); !ok {
+			t.Fatalf("expected connection %d to be kept", i)
+		}
+	}
+	if len(estimates.byConn) != maxDiscoveryEstimates || len(estimates.order) != maxDiscoveryEstimates {
+		t.Fatalf("expected %d estimates, got %d (%d ordered)", maxDiscoveryEstimates, len(estimates.byConn), len(estimates.order))
+	}
+}
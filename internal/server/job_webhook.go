@@ -0,0 +1,58 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/upload"
+)
+
+// webhookDeliveryTimeout bounds a webhook delivery including its retries
+const webhookDeliveryTimeout = time.Minute
+
+// webhookPayload is the body POSTed to an export's webhook when the job finishes
+type webhookPayload struct {
+	Event  string              `json:"event"`
+	Job    *ExportJobStatus    `json:"job"`
+	Config domain.ExportConfig `json:"config"`
+}
+
+// webhookEvents maps terminal job states to the event names webhooks receive
+var webhookEvents = map[ExportJobState]string{
+	JobCompleted: "export.completed",
+	JobFailed:    "export.failed",
+	JobCanceled:  "export.canceled",
+}
+
+// notifyWebhook posts the final status of the job to its webhook in the background. The config
+// in the payload is redacted like persisted job state, so no credentials leave the process.
+// Delivery failures are logged and never change the job outcome.
+func (m *ExportJobManager) notifyWebhook(jobID string, config domain.ExportConfig) {
+	if config.Webhook == nil {
+		return
+	}
+	status, ok := m.GetStatus(jobID)
+	if !ok {
+		return
+	}
+	event, ok := webhookEvents[status.State]
+	if !ok {
+		return
+	}
+	body, err := json.Marshal(webhookPayload{Event: event, Job: status, Config: redactExportConfig(config)})
+	if err != nil {
+		log.Printf("[WARN] Export job %s: failed to encode webhook payload: %v", jobID, err)
+		return
+	}
+	target := *config.Webhook
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), webhookDeliveryTimeout)
+		defer cancel()
+		if err := upload.PostWebhook(ctx, target, body); err != nil {
+			log.Printf("[WARN] Export job %s: webhook delivery failed: %v", jobID, err)
+		}
+	}()
+}
@@ -0,0 +1,178 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/application/services"
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+)
+
+// Preview bounds: a preview is a confidence check, not an export, so it stays small and quick.
+// previewTimeout covers every slice and stays below the HTTP server's 30s WriteTimeout, so a
+// slow preview still gets its error response instead of a dropped connection.
+const (
+	defaultPreviewWindowSeconds = 60
+	maxPreviewWindowSeconds     = 900
+	maxPreviewBytes             = 16 << 20
+	previewTimeout              = 25 * time.Second
+)
+
+// previewSlices resolves ?at= to the edges of the range to sample: both (default) previews the
+// first and the last window, start and end only one of them. A range no longer than the slices
+// together is previewed whole, as one slice.
+func previewSlices(at string, tr domain.TimeRange, windowSeconds int) ([]string, error) {
+	switch at {
+	case "", "both":
+		if tr.End.Sub(tr.Start) <= 2*time.Duration(windowSeconds)*time.Second {
+			return []string{"start"}, nil
+		}
+		return []string{"start", "end"}, nil
+	case "start", "end":
+		return []string{at}, nil
+	default:
+		return nil, fmt.Errorf("unsupported at %q (use \"both\", \"start\" or \"end\")", at)
+	}
+}
+
+// previewConfig narrows config to a window_seconds slice at the start or end of its range.
+// Everything else (selectors, obfuscation, filters) is kept so the preview shows what the real export will contain.
+func previewConfig(config domain.ExportConfig, windowSeconds int, at string, now time.Time) (domain.ExportConfig, error) {
+	if windowSeconds <= 0 || windowSeconds > maxPreviewWindowSeconds {
+		return config, fmt.Errorf("window_seconds must be between 1 and %d", maxPreviewWindowSeconds)
+	}
+	window := time.Duration(windowSeconds) * time.Second
+	tr := config.TimeRange
+	if tr.End.Sub(tr.Start) > window {
+		switch at {
+		case "", "start":
+			tr.End = tr.Start.Add(window)
+		case "end":
+			tr.Start = tr.End.Add(-window)
+		default:
+			return config, fmt.Errorf("unsupported at %q (use \"start\" or \"end\")", at)
+		}
+	}
+	config.TimeRange = tr
+
+	exportID := fmt.Sprintf("preview-%d", now.UnixNano())
+	if config.ExportID != "" {
+		exportID = "preview-" + config.ExportID
+	}
+	config.ExportID = exportID
+	config.Batching.Enabled = false
+	config.ResumeFromBatch = 0
+	config.StagingFile = ""
+	config.KeepStaging = false
+	config.ArchivePerBatch = false
+	config.Upload = nil
+	if config.MaxBytes <= 0 || config.MaxBytes > maxPreviewBytes {
+		config.MaxBytes = maxPreviewBytes
+	}
+	return config, nil
+}
+
+// handleExportPreview runs a small synchronous export over a slice of the requested range
+// (?window_seconds=60&at=start|end) so obfuscation and filters can be checked before the real export
+func (s *Server) handleExportPreview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var config domain.ExportConfig
+	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+	windowSeconds := defaultPreviewWindowSeconds
+	if raw := r.URL.Query().Get("window_seconds"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid window_seconds: %v", err))
+			return
+		}
+		windowSeconds = parsed
+	}
+	// Defaults come from the full range, so the preview uses the same step as the real export
+	ensureBatchDefaults(&config)
+	if !config.TimeRange.End.After(config.TimeRange.Start) {
+		respondWithError(w, http.StatusBadRequest, "time_range end must be after start")
+		return
+	}
+	slices, err := previewSlices(r.URL.Query().Get("at"), config.TimeRange, windowSeconds)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	now := time.Now()
+	configs := make([]domain.ExportConfig, 0, len(slices))
+	for _, at := range slices {
+		sliceConfig, err := previewConfig(config, windowSeconds, at, now)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if len(slices) > 1 {
+			sliceConfig.ExportID += "-" + at
+		}
+		if err := services.ValidateExportID(sliceConfig.ExportID); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		configs = append(configs, sliceConfig)
+	}
+	if err := services.ValidateInstances(config.Instances); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	for i := range configs {
+		if err := s.enforceSafeModePaths(&configs[i]); err != nil {
+			respondWithError(w, http.StatusForbidden, err.Error())
+			return
+		}
+	}
+
+	// One deadline for every slice, so the response is written before the server's WriteTimeout
+	ctx, cancel := context.WithTimeout(r.Context(), previewTimeout)
+	defer cancel()
+	previews := make([]map[string]interface{}, 0, len(configs))
+	for i, sliceConfig := range configs {
+		if s.debug {
+			sliceConfig.Connection.Debug = true
+			log.Printf("[SEND] Export preview: %s to %s", sliceConfig.TimeRange.Start.Format(time.RFC3339), sliceConfig.TimeRange.End.Format(time.RFC3339))
+		}
+		result, err := s.exportService.ExecuteExport(ctx, sliceConfig)
+		if err != nil {
+			log.Printf("[ERROR] Export preview failed: %v", err)
+			respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Export preview failed: %v", err))
+			return
+		}
+		log.Printf("[OK] Export preview complete: %s (%d metrics, %.2f KB)", result.ExportID, result.MetricsExported, float64(result.ArchiveSizeBytes)/1024)
+		previews = append(previews, map[string]interface{}{
+			"at":                  slices[i],
+			"export_id":           result.ExportID,
+			"archive_path":        result.ArchivePath,
+			"archive_name":        result.ArchiveName,
+			"archive_size":        result.ArchiveSizeBytes,
+			"metrics_count":       result.MetricsExported,
+			"series_with_samples": result.SeriesWithSamples,
+			"sha256":              result.SHA256,
+			"time_range":          result.TimeRange,
+			"obfuscation_applied": result.ObfuscationApplied,
+			"partial":             result.Partial,
+			"warnings":            result.Warnings,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"preview": true,
+		"slices":  previews,
+	})
+}
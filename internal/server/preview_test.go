@@ -0,0 +1,166 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+)
+
+func TestHandleExportPreviewProducesSmallArchive(t *testing.T) {
+	var mu sync.Mutex
+	var starts []string
+	vmServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/export" {
+			http.NotFound(w, r)
+			return
+		}
+		_ = r.ParseForm()
+		mu.Lock()
+		starts = append(starts, r.FormValue("start"))
+		mu.Unlock()
+		_, _ = io.WriteString(w, `{"metric":{"__name__":"up","job":"vmstorage","instance":"10.0.0.5:8482"},"values":[1],"timestamps":[1]}`+"\n")
+	}))
+	defer vmServer.Close()
+
+	end := time.Date(2026, 1, 23, 14, 0, 0, 0, time.UTC)
+	config := domain.ExportConfig{
+		Connection: domain.VMConnection{URL: vmServer.URL},
+		TimeRange:  domain.TimeRange{Start: end.Add(-2 * time.Hour), End: end},
+		Jobs:       []string{"vmstorage"},
+		Obfuscation: domain.ObfuscationConfig{
+			Enabled:           true,
+			ObfuscateInstance: true,
+		},
+	}
+	body, _ := json.Marshal(config)
+
+	srv := NewServer(t.TempDir(), "test", false)
+	req := httptest.NewRequest(http.MethodPost, "/api/export/preview?window_seconds=60&at=end", bytes.NewReader(body))
+	rr := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var previews previewResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &previews); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if !previews.Preview || len(previews.Slices) != 1 {
+		t.Fatalf("expected a single preview slice, got %+v", previews)
+	}
+	resp := previews.Slices[0]
+	if resp.At != "end" || !strings.HasPrefix(resp.ExportID, "preview-") {
+		t.Fatalf("expected a preview export, got %+v", resp)
+	}
+	if !resp.TimeRange.Start.Equal(end.Add(-time.Minute)) || !resp.TimeRange.End.Equal(end) {
+		t.Fatalf("expected the last minute of the range, got %+v", resp.TimeRange)
+	}
+	mu.Lock()
+	if len(starts) != 1 {
+		t.Fatalf("expected a single export request, got %d", len(starts))
+	}
+	mu.Unlock()
+
+	zr, err := zip.OpenReader(resp.ArchivePath)
+	if err != nil {
+		t.Fatalf("preview archive is not a valid zip: %v", err)
+	}
+	defer func() { _ = zr.Close() }()
+	var metrics string
+	for _, f := range zr.File {
+		if f.Name != "metrics.jsonl" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("failed to open metrics.jsonl: %v", err)
+		}
+		data, _ := io.ReadAll(rc)
+		_ = rc.Close()
+		metrics = string(data)
+	}
+	if !strings.Contains(metrics, `"__name__":"up"`) || strings.Contains(metrics, "10.0.0.5") {
+		t.Fatalf("expected obfuscated preview data, got %q", metrics)
+	}
+}
+
+type previewResponse struct {
+	Preview bool `json:"preview"`
+	Slices  []struct {
+		At          string           `json:"at"`
+		ExportID    string           `json:"export_id"`
+		ArchivePath string           `json:"archive_path"`
+		TimeRange   domain.TimeRange `json:"time_range"`
+	} `json:"slices"`
+}
+
+func TestHandleExportPreviewSamplesBothEdgesByDefault(t *testing.T) {
+	vmServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `{"metric":{"__name__":"up","job":"vmstorage"},"values":[1],"timestamps":[1]}`+"\n")
+	}))
+	defer vmServer.Close()
+
+	end := time.Date(2026, 1, 23, 14, 0, 0, 0, time.UTC)
+	body, _ := json.Marshal(domain.ExportConfig{
+		Connection: domain.VMConnection{URL: vmServer.URL},
+		TimeRange:  domain.TimeRange{Start: end.Add(-2 * time.Hour), End: end},
+		Jobs:       []string{"vmstorage"},
+	})
+	srv := NewServer(t.TempDir(), "test", false)
+	rr := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/export/preview", bytes.NewReader(body)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var previews previewResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &previews); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if len(previews.Slices) != 2 {
+		t.Fatalf("expected the first and the last slice, got %+v", previews.Slices)
+	}
+	first, last := previews.Slices[0], previews.Slices[1]
+	if first.At != "start" || !first.TimeRange.Start.Equal(end.Add(-2*time.Hour)) || !first.TimeRange.End.Equal(end.Add(-2*time.Hour+time.Minute)) {
+		t.Fatalf("unexpected first slice %+v", first)
+	}
+	if last.At != "end" || !last.TimeRange.Start.Equal(end.Add(-time.Minute)) || !last.TimeRange.End.Equal(end) {
+		t.Fatalf("unexpected last slice %+v", last)
+	}
+	if first.ArchivePath == last.ArchivePath || !strings.HasSuffix(first.ExportID, "-start") || !strings.HasSuffix(last.ExportID, "-end") {
+		t.Fatalf("expected one archive per slice, got %+v", previews.Slices)
+	}
+}
+
+func TestPreviewConfigClampsRange(t *testing.T) {
+	start := time.Date(2026, 1, 23, 12, 0, 0, 0, time.UTC)
+	config := domain.ExportConfig{
+		TimeRange: domain.TimeRange{Start: start, End: start.Add(time.Hour)},
+		ExportID:  "TICKET-1",
+		MaxBytes:  1 << 30,
+		Batching:  domain.BatchSettings{Enabled: true},
+	}
+	got, err := previewConfig(config, 60, "", time.Now())
+	if err != nil {
+		t.Fatalf("previewConfig: %v", err)
+	}
+	if !got.TimeRange.End.Equal(start.Add(time.Minute)) || got.ExportID != "preview-TICKET-1" || got.MaxBytes != maxPreviewBytes || got.Batching.Enabled {
+		t.Fatalf("unexpected preview config: %+v", got)
+	}
+	if _, err := previewConfig(config, maxPreviewWindowSeconds+1, "", time.Now()); err == nil {
+		t.Fatal("expected oversized window to be rejected")
+	}
+	if _, err := previewConfig(config, 60, "middle", time.Now()); err == nil {
+		t.Fatal("expected unknown position to be rejected")
+	}
+}
@@ -0,0 +1,322 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/application/services"
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+)
+
+// Schedule defaults and bounds
+const (
+	defaultScheduleExportIDPrefix = "scheduled"
+	defaultScheduleKeepArchives   = 24
+	minScheduleIntervalSeconds    = 60
+	scheduleRunHistory            = 20
+)
+
+// scheduleExportIDTimeFormat is the UTC run timestamp in <export_id_prefix>-<timestamp> export IDs
+const scheduleExportIDTimeFormat = "20060102T150405.000Z"
+
+// scheduledArchiveSuffix matches what follows vmexport_<prefix> in a scheduled archive name: the run
+// timestamp, an optional archive_per_batch window, then the writer's timestamp or -vN version suffix
+const scheduledArchiveSuffix = `-\d{8}T\d{6}\.\d{3}Z(_\d{8}T\d{6}Z-\d{8}T\d{6}Z)?(_\d{8}_\d{6}|-v\d+)?\.zip$`
+
+// ScheduleSpec is the on-disk schedule file: an export config run periodically.
+// The export's time_range and export_id are replaced on every run.
+type ScheduleSpec struct {
+	IntervalSeconds int                 `json:"interval_seconds"`
+	RangeSeconds    int                 `json:"range_seconds,omitempty"` // Defaults to interval_seconds
+	KeepArchives    int                 `json:"keep_archives,omitempty"` // Defaults to 24; older scheduled archives are deleted
+	ExportIDPrefix  string              `json:"export_id_prefix,omitempty"`
+	Export          domain.ExportConfig `json:"export"`
+}
+
+// LoadScheduleSpec reads and validates a schedule file
+func LoadScheduleSpec(path string) (ScheduleSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ScheduleSpec{}, err
+	}
+	var spec ScheduleSpec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return ScheduleSpec{}, fmt.Errorf("malformed schedule JSON: %w", err)
+	}
+	if err := spec.normalize(); err != nil {
+		return ScheduleSpec{}, fmt.Errorf("invalid schedule: %w", err)
+	}
+	return spec, nil
+}
+
+// normalize applies defaults and rejects specs that cannot run unattended
+func (spec *ScheduleSpec) normalize() error {
+	if spec.IntervalSeconds < minScheduleIntervalSeconds {
+		return fmt.Errorf("interval_seconds must be at least %d", minScheduleIntervalSeconds)
+	}
+	if spec.RangeSeconds < 0 {
+		return fmt.Errorf("range_seconds cannot be negative")
+	}
+	if spec.RangeSeconds == 0 {
+		spec.RangeSeconds = spec.IntervalSeconds
+	}
+	if spec.KeepArchives < 0 {
+		return fmt.Errorf("keep_archives cannot be negative")
+	}
+	if spec.KeepArchives == 0 {
+		spec.KeepArchives = defaultScheduleKeepArchives
+	}
+	spec.ExportIDPrefix = strings.TrimSpace(spec.ExportIDPrefix)
+	if spec.ExportIDPrefix == "" {
+		spec.ExportIDPrefix = defaultScheduleExportIDPrefix
+	}
+	if err := services.ValidateExportID(spec.ExportIDPrefix); err != nil {
+		return fmt.Errorf("export_id_prefix: %w", err)
+	}
+	if spec.Export.Connection.URL == "" {
+		return fmt.Errorf("export.connection.url is required")
+	}
+	if spec.Export.Mode == domain.ExportModeCustom && spec.Export.Query == "" {
+		return fmt.Errorf("export.query is required in custom mode")
+	}
+	return services.ValidateInstances(spec.Export.Instances)
+}
+
+// ScheduledRun is one scheduled export as reported by /api/schedule/status
+type ScheduledRun struct {
+	ExportID        string           `json:"export_id"`
+	State           ExportJobState   `json:"state"`
+	StartedAt       time.Time        `json:"started_at"`
+	CompletedAt     *time.Time       `json:"completed_at,omitempty"`
+	TimeRange       domain.TimeRange `json:"time_range"`
+	ArchivePath     string           `json:"archive_path,omitempty"`
+	MetricsExported int              `json:"metrics_exported,omitempty"`
+	Error           string           `json:"error,omitempty"`
+}
+
+// ScheduleStatus is the response of /api/schedule/status; it never includes the export config
+type ScheduleStatus struct {
+	Enabled         bool           `json:"enabled"`
+	IntervalSeconds int            `json:"interval_seconds,omitempty"`
+	RangeSeconds    int            `json:"range_seconds,omitempty"`
+	KeepArchives    int            `json:"keep_archives,omitempty"`
+	NextRun         *time.Time     `json:"next_run,omitempty"`
+	Runs            []ScheduledRun `json:"runs"`
+}
+
+// exportScheduler runs a ScheduleSpec on a fixed interval until stopped
+type exportScheduler struct {
+	exportService services.ExportService
+	spec          ScheduleSpec
+	interval      time.Duration
+	now           func() time.Time
+
+	mu      sync.Mutex
+	runs    []ScheduledRun
+	nextRun time.Time
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+func newExportScheduler(service services.ExportService, spec ScheduleSpec, interval time.Duration) *exportScheduler {
+	return &exportScheduler{
+		exportService: service,
+		spec:          spec,
+		interval:      interval,
+		now:           time.Now,
+	}
+}
+
+// start launches the run loop; the first export runs one interval after start
+func (s *exportScheduler) start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.mu.Lock()
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	s.nextRun = s.now().Add(s.interval)
+	s.mu.Unlock()
+
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.runOnce(ctx)
+				s.mu.Lock()
+				s.nextRun = s.now().Add(s.interval)
+				s.mu.Unlock()
+			}
+		}
+	}()
+}
+
+// stop cancels the in-flight run, if any, and waits for the loop to exit
+func (s *exportScheduler) stop(ctx context.Context) error {
+	s.mu.Lock()
+	cancel, done := s.cancel, s.done
+	s.mu.Unlock()
+	if cancel == nil {
+		return nil
+	}
+	cancel()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("timed out waiting for scheduled export to stop: %w", ctx.Err())
+	}
+}
+
+// runOnce exports the last range_seconds and rotates old scheduled archives
+func (s *exportScheduler) runOnce(ctx context.Context) {
+	end := s.now().UTC()
+	config := s.spec.Export
+	config.TimeRange = domain.TimeRange{
+		Start: end.Add(-time.Duration(s.spec.RangeSeconds) * time.Second),
+		End:   end,
+	}
+	config.ExportID = fmt.Sprintf("%s-%s", s.spec.ExportIDPrefix, end.Format(scheduleExportIDTimeFormat))
+	services.ApplyExportDefaults(&config)
+
+	run := ScheduledRun{
+		ExportID:  config.ExportID,
+		State:     JobRunning,
+		StartedAt: end,
+		TimeRange: config.TimeRange,
+	}
+	s.recordRun(run)
+
+	result, err := s.exportService.ExecuteExport(ctx, config)
+	completed := s.now().UTC()
+	run.CompletedAt = &completed
+	switch {
+	case err != nil && ctx.Err() != nil:
+		run.State = JobCanceled
+		run.Error = err.Error()
+	case err != nil:
+		run.State = JobFailed
+		run.Error = err.Error()
+		log.Printf("[WARN] Scheduled export %s failed: %v", config.ExportID, err)
+	default:
+		run.State = JobCompleted
+		if result != nil {
+			run.ArchivePath = result.ArchivePath
+			run.MetricsExported = result.MetricsExported
+		}
+		log.Printf("Scheduled export %s completed: %s", config.ExportID, run.ArchivePath)
+	}
+	s.updateLastRun(run)
+
+	if run.ArchivePath != "" {
+		if err := rotateScheduledArchives(filepath.Dir(run.ArchivePath), s.spec.ExportIDPrefix, s.spec.KeepArchives); err != nil {
+			log.Printf("[WARN] Failed to rotate scheduled archives: %v", err)
+		}
+	}
+}
+
+// recordRun appends a run to the bounded history
+func (s *exportScheduler) recordRun(run ScheduledRun) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.runs = append(s.runs, run)
+	if len(s.runs) > scheduleRunHistory {
+		s.runs = s.runs[len(s.runs)-scheduleRunHistory:]
+	}
+}
+
+// updateLastRun replaces the in-flight run; runs never overlap, so it is always the last entry
+func (s *exportScheduler) updateLastRun(run ScheduledRun) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.runs) > 0 {
+		s.runs[len(s.runs)-1] = run
+	}
+}
+
+func (s *exportScheduler) status() ScheduleStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := ScheduleStatus{
+		Enabled:         true,
+		IntervalSeconds: int(s.interval / time.Second),
+		RangeSeconds:    s.spec.RangeSeconds,
+		KeepArchives:    s.spec.KeepArchives,
+		Runs:            append([]ScheduledRun{}, s.runs...),
+	}
+	if !s.nextRun.IsZero() {
+		next := s.nextRun
+		status.NextRun = &next
+	}
+	return status
+}
+
+// rotateScheduledArchives keeps the newest keep scheduled archives in dir. Only names the scheduler
+// produces (vmexport_<prefix>-<run timestamp>...zip) are considered, so manual exports whose ID
+// merely starts with the prefix are never removed.
+func rotateScheduledArchives(dir, prefix string, keep int) error {
+	pattern, err := regexp.Compile("^vmexport_" + regexp.QuoteMeta(prefix) + scheduledArchiveSuffix)
+	if err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var matches []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && pattern.MatchString(entry.Name()) {
+			matches = append(matches, filepath.Join(dir, entry.Name()))
+		}
+	}
+	if len(matches) <= keep {
+		return nil
+	}
+	// Names embed a sortable UTC timestamp, so lexical order is chronological
+	sort.Strings(matches)
+	for _, path := range matches[:len(matches)-keep] {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		log.Printf("Removed scheduled archive %s (keep_archives=%d)", path, keep)
+	}
+	return nil
+}
+
+// StartSchedule runs spec every interval_seconds until Shutdown
+func (s *Server) StartSchedule(spec ScheduleSpec) error {
+	if err := spec.normalize(); err != nil {
+		return err
+	}
+	if err := s.enforceSafeModePaths(&spec.Export); err != nil {
+		return err
+	}
+	s.scheduler = newExportScheduler(s.exportService, spec, time.Duration(spec.IntervalSeconds)*time.Second)
+	s.scheduler.start()
+	return nil
+}
+
+func (s *Server) handleScheduleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithNegotiatedError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if s.scheduler == nil {
+		respondNegotiated(w, r, http.StatusOK, ScheduleStatus{Runs: []ScheduledRun{}})
+		return
+	}
+	respondNegotiated(w, r, http.StatusOK, s.scheduler.status())
+}
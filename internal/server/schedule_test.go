@@ -0,0 +1,174 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+)
+
+// archivingExportService writes an empty archive per export, like the real writer
+type archivingExportService struct {
+	dir string
+
+	mu      sync.Mutex
+	configs []domain.ExportConfig
+}
+
+func (a *archivingExportService) ExecuteExport(ctx context.Context, config domain.ExportConfig) (*domain.ExportResult, error) {
+	a.mu.Lock()
+	a.configs = append(a.configs, config)
+	a.mu.Unlock()
+	path := filepath.Join(a.dir, "vmexport_"+config.ExportID+".zip")
+	if err := os.WriteFile(path, []byte("zip"), 0o644); err != nil {
+		return nil, err
+	}
+	return &domain.ExportResult{ExportID: config.ExportID, ArchivePath: path, MetricsExported: 3}, nil
+}
+
+func (a *archivingExportService) calls() []domain.ExportConfig {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]domain.ExportConfig{}, a.configs...)
+}
+
+func TestExportSchedulerRunsAndRotatesArchives(t *testing.T) {
+	dir := t.TempDir()
+	service := &archivingExportService{dir: dir}
+	spec := ScheduleSpec{
+		IntervalSeconds: 300,
+		KeepArchives:    2,
+		Export:          domain.ExportConfig{Connection: domain.VMConnection{URL: "http://vm:8428"}},
+	}
+	if err := spec.normalize(); err != nil {
+		t.Fatalf("normalize: %v", err)
+	}
+	scheduler := newExportScheduler(service, spec, 20*time.Millisecond)
+	scheduler.start()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(service.calls()) < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := scheduler.stop(context.Background()); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+
+	calls := service.calls()
+	if len(calls) < 3 {
+		t.Fatalf("expected at least 3 scheduled exports, got %d", len(calls))
+	}
+	first := calls[0]
+	if !strings.HasPrefix(first.ExportID, "scheduled-") {
+		t.Fatalf("unexpected export id %q", first.ExportID)
+	}
+	if got := first.TimeRange.End.Sub(first.TimeRange.Start); got != 300*time.Second {
+		t.Fatalf("expected range to default to the interval, got %s", got)
+	}
+
+	archives, _ := filepath.Glob(filepath.Join(dir, "vmexport_scheduled-*.zip"))
+	if len(archives) != 2 {
+		t.Fatalf("expected keep_archives=2 to leave 2 archives, got %d", len(archives))
+	}
+	last := calls[len(calls)-1]
+	if _, err := os.Stat(filepath.Join(dir, "vmexport_"+last.ExportID+".zip")); err != nil {
+		t.Fatalf("newest archive was rotated away: %v", err)
+	}
+
+	status := scheduler.status()
+	if len(status.Runs) != len(calls) || status.Runs[0].State != JobCompleted {
+		t.Fatalf("unexpected run history: %+v", status.Runs)
+	}
+}
+
+func TestRotateScheduledArchivesMatchesOnlySchedulerNames(t *testing.T) {
+	dir := t.TempDir()
+	scheduled := []string{
+		"vmexport_[ops]-20260101T000000.000Z_20260101_000001.zip",
+		"vmexport_[ops]-20260101T010000.000Z_20260101_010001.zip",
+		"vmexport_[ops]-20260101T020000.000Z_20260101T013000Z-20260101T020000Z_20260101_020001.zip",
+	}
+	// Manual exports whose ID starts with the prefix, and a glob-style match of the bracket
+	kept := []string{
+		"vmexport_[ops]-incident_20250101_000000.zip",
+		"vmexport_[ops]-20250101T000000.000Z-manual_20250101_000000.zip",
+		"vmexport_o-20250101T000000.000Z_20250101_000000.zip",
+	}
+	for _, name := range append(append([]string{}, scheduled...), kept...) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("zip"), 0o644); err != nil {
+			t.Fatalf("failed to seed %s: %v", name, err)
+		}
+	}
+
+	if err := rotateScheduledArchives(dir, "[ops]", 1); err != nil {
+		t.Fatalf("rotateScheduledArchives: %v", err)
+	}
+	for i, name := range scheduled {
+		_, err := os.Stat(filepath.Join(dir, name))
+		if newest := i == len(scheduled)-1; newest != (err == nil) {
+			t.Fatalf("%s: expected only the newest scheduled archive to remain (stat error %v)", name, err)
+		}
+	}
+	for _, name := range kept {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Fatalf("rotation removed a non-scheduled archive %s: %v", name, err)
+		}
+	}
+}
+
+func TestScheduleSpecValidation(t *testing.T) {
+	base := domain.ExportConfig{Connection: domain.VMConnection{URL: "http://vm:8428"}}
+	cases := []struct {
+		name string
+		spec ScheduleSpec
+	}{
+		{"interval too short", ScheduleSpec{IntervalSeconds: 5, Export: base}},
+		{"negative keep", ScheduleSpec{IntervalSeconds: 60, KeepArchives: -1, Export: base}},
+		{"bad prefix", ScheduleSpec{IntervalSeconds: 60, ExportIDPrefix: "a/b", Export: base}},
+		{"missing url", ScheduleSpec{IntervalSeconds: 60}},
+	}
+	for _, tc := range cases {
+		if err := tc.spec.normalize(); err == nil {
+			t.Errorf("%s: expected error", tc.name)
+		}
+	}
+}
+
+func TestHandleScheduleStatusOmitsConfig(t *testing.T) {
+	srv := NewServer(t.TempDir(), "test", false)
+	spec := ScheduleSpec{
+		IntervalSeconds: 3600,
+		Export: domain.ExportConfig{Connection: domain.VMConnection{
+			URL:  "http://vm:8428",
+			Auth: domain.AuthConfig{Type: domain.AuthTypeBasic, Username: "user", Password: "secret"},
+		}},
+	}
+	if err := srv.StartSchedule(spec); err != nil {
+		t.Fatalf("StartSchedule: %v", err)
+	}
+	defer func() { _ = srv.Shutdown(context.Background()) }()
+
+	rec := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/schedule/status", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), "secret") {
+		t.Fatalf("schedule status leaked credentials: %s", rec.Body.String())
+	}
+	var status ScheduleStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !status.Enabled || status.IntervalSeconds != 3600 || status.NextRun == nil {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+}
@@ -1,11 +1,16 @@
 package server
 
 import (
+	"bytes"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
 )
 
 func TestHandleDownload_PathTraversal(t *testing.T) {
@@ -94,3 +99,56 @@ func TestHandleDownload_PathTraversal(t *testing.T) {
 		})
 	}
 }
+
+func TestSafeMode_DisablesFilesystemEndpoints(t *testing.T) {
+	outputDir := t.TempDir()
+	archivePath := filepath.Join(outputDir, "export.zip")
+	if err := os.WriteFile(archivePath, []byte("zip"), 0o644); err != nil {
+		t.Fatalf("failed to create archive: %v", err)
+	}
+
+	srv := NewServer(outputDir, "test", false)
+	srv.SetSafeMode(true)
+	srv.exportService = &fakeExportService{result: &domain.ExportResult{ExportID: "ok"}}
+	srv.jobManager = NewExportJobManager(srv.exportService)
+	handler := srv.Router()
+
+	serve := func(method, target string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, bytes.NewReader(body))
+		req.RemoteAddr = "127.0.0.1:1234"
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := serve(http.MethodGet, "/api/fs/list?path="+outputDir, nil); rr.Code != http.StatusForbidden {
+		t.Fatalf("fs/list: expected 403 in safe mode, got %d", rr.Code)
+	}
+	checkBody, _ := json.Marshal(map[string]string{"path": outputDir})
+	if rr := serve(http.MethodPost, "/api/fs/check", checkBody); rr.Code != http.StatusForbidden {
+		t.Fatalf("fs/check: expected 403 in safe mode, got %d", rr.Code)
+	}
+
+	outsideBody, _ := json.Marshal(domain.ExportConfig{StagingDir: t.TempDir()})
+	if rr := serve(http.MethodPost, "/api/export/start", outsideBody); rr.Code != http.StatusForbidden {
+		t.Fatalf("export/start with foreign staging dir: expected 403, got %d", rr.Code)
+	}
+
+	startBody, _ := json.Marshal(domain.ExportConfig{})
+	rr := serve(http.MethodPost, "/api/export/start", startBody)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("export/start: expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var started map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &started); err != nil {
+		t.Fatalf("invalid json: %v", err)
+	}
+	stagingPath, _ := started["staging_path"].(string)
+	if !strings.HasPrefix(stagingPath, filepath.Join(outputDir, "staging")+string(os.PathSeparator)) {
+		t.Fatalf("expected staging under output dir, got %q", stagingPath)
+	}
+
+	if rr := serve(http.MethodGet, "/api/download?path="+archivePath, nil); rr.Code != http.StatusOK {
+		t.Fatalf("download: expected 200 in safe mode, got %d", rr.Code)
+	}
+}
@@ -1,26 +1,60 @@
 package server
 
 import (
+	"bytes"
 	"context"
 	"embed"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"log"
 	"net"
 	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
 	"runtime"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/VictoriaMetrics/vmgather/internal/application/services"
 	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/audit"
 	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/obfuscation"
+	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/upload"
 	"github.com/VictoriaMetrics/vmgather/internal/infrastructure/vm"
+	"gopkg.in/yaml.v3"
+)
+
+// Validation probes: aggregated so the response stays small even on large clusters
+const (
+	validateVersionQuery     = `group by (job, version, vm_component) (vm_app_version)`
+	validateFallbackQuery    = `count by (job) ({__name__=~"vm_.*"})`
+	validateQueryMaxSeries   = 100
+	validateMaxResponseBytes = 1 << 20
+)
+
+// validateQueryOptions bounds every validation probe
+var validateQueryOptions = vm.QueryOptions{Limit: validateQueryMaxSeries, MaxResponseBytes: validateMaxResponseBytes}
+
+// Limits for the /api/query scratchpad
+const (
+	queryProxyTimeout   = 10 * time.Second
+	queryProxyMaxSeries = 100
+	queryProxyMaxLength = 4096
+)
+
+// Limits for /api/discover/batch fan-out
+const (
+	batchDiscoveryDefaultConcurrency = 4
+	batchDiscoveryMaxConcurrency     = 16
+	batchDiscoveryMaxTargets         = 200
+	batchDiscoveryTargetTimeout      = 30 * time.Second
 )
 
 //go:embed static/*
@@ -34,6 +68,10 @@ type Server struct {
 	outputDir     string
 	version       string
 	debug         bool
+	safeMode      bool
+	scheduler     *exportScheduler
+	heavyLimit    int // Discovery estimate in series above which components need confirmation
+	estimates     discoveryEstimates
 }
 
 // NewServer creates a new HTTP server
@@ -48,11 +86,83 @@ func NewServer(outputDir, version string, debug bool) *Server {
 		outputDir:     outputDir,
 		version:       version,
 		debug:         debug,
+		heavyLimit:    DefaultHeavyComponentSeries,
 	}
 	server.jobManager = NewExportJobManager(server.exportService)
+	server.jobManager.SetStatePath(filepath.Join(outputDir, "staging", exportJobStateFile))
 	return server
 }
 
+// exportJobStateFile holds resumable jobs persisted on shutdown
+const exportJobStateFile = "export-jobs.json"
+
+// RestoreExportJobs reloads jobs interrupted by a previous shutdown so they can be resumed.
+func (s *Server) RestoreExportJobs() (int, error) {
+	return s.jobManager.RestoreState()
+}
+
+// Shutdown stops the export schedule, cancels in-flight export jobs and persists their staging state for resume.
+func (s *Server) Shutdown(ctx context.Context) error {
+	if s.scheduler != nil {
+		if err := s.scheduler.stop(ctx); err != nil {
+			log.Printf("[WARN] %v", err)
+		}
+	}
+	return s.jobManager.Shutdown(ctx)
+}
+
+// SetSafeMode toggles the hardened posture for server-side deployments:
+// filesystem browsing endpoints are disabled and all export paths are confined to the output directory.
+func (s *Server) SetSafeMode(enabled bool) {
+	s.safeMode = enabled
+}
+
+// SetAuditLogger records every completed export (sync and job-based) in the given audit log
+func (s *Server) SetAuditLogger(logger *audit.Logger) {
+	s.exportService = services.NewAuditedExportService(s.exportService, logger)
+	s.jobManager.exportService = s.exportService
+}
+
+// SetProgressInterval sets the shortest time between two progress updates of an export job
+func (s *Server) SetProgressInterval(interval time.Duration) {
+	s.jobManager.SetProgressInterval(interval)
+}
+
+// safeModeStagingDir is the only staging directory allowed in safe mode
+func (s *Server) safeModeStagingDir() string {
+	return filepath.Join(s.outputDir, "staging")
+}
+
+// enforceSafeModePaths confines user-supplied export paths to the output directory in safe mode
+func (s *Server) enforceSafeModePaths(config *domain.ExportConfig) error {
+	if !s.safeMode {
+		return nil
+	}
+	if config.StagingDir == "" {
+		config.StagingDir = s.safeModeStagingDir()
+	}
+	for _, path := range []string{config.StagingDir, config.StagingFile, config.BaselineArchive} {
+		if path != "" && !isWithinDir(s.outputDir, path) {
+			return fmt.Errorf("path %s is outside the output directory (safe mode)", path)
+		}
+	}
+	return nil
+}
+
+// isWithinDir reports whether path resolves to dir or a location inside it
+func isWithinDir(dir, path string) bool {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(absDir, absPath)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator))
+}
+
 // respondWithError sends JSON error response
 // CRITICAL: Always return JSON, never text/plain, even on errors!
 func respondWithError(w http.ResponseWriter, statusCode int, message string) {
@@ -64,6 +174,115 @@ func respondWithError(w http.ResponseWriter, statusCode int, message string) {
 	})
 }
 
+// yamlMediaTypes are the Accept values that select YAML responses
+var yamlMediaTypes = map[string]bool{
+	"application/yaml":   true,
+	"application/x-yaml": true,
+	"text/yaml":          true,
+	"text/x-yaml":        true,
+}
+
+// wantsYAML reports whether the Accept header prefers YAML over JSON.
+// The YAML or JSON media type with the highest q-value wins, the first listed on a tie;
+// q=0 rules a type out and anything else falls back to JSON.
+func wantsYAML(r *http.Request) bool {
+	bestQuality, preferYAML := 0.0, false
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		params := strings.Split(part, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+		isYAML := yamlMediaTypes[mediaType]
+		if !isYAML && mediaType != "application/json" {
+			continue
+		}
+		if quality := acceptQuality(params[1:]); quality > bestQuality {
+			bestQuality, preferYAML = quality, isYAML
+		}
+	}
+	return preferYAML
+}
+
+// acceptQuality returns the q parameter of an Accept entry, 1 when absent and 0 when malformed
+func acceptQuality(params []string) float64 {
+	for _, param := range params {
+		key, value, _ := strings.Cut(param, "=")
+		if strings.ToLower(strings.TrimSpace(key)) != "q" {
+			continue
+		}
+		quality, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || quality < 0 || quality > 1 {
+			return 0
+		}
+		return quality
+	}
+	return 1
+}
+
+// respondNegotiated writes payload as YAML when the client asked for it and as JSON otherwise
+func respondNegotiated(w http.ResponseWriter, r *http.Request, statusCode int, payload interface{}) {
+	if wantsYAML(r) {
+		data, err := marshalYAML(payload)
+		if err == nil {
+			w.Header().Set("Content-Type", "application/yaml")
+			w.WriteHeader(statusCode)
+			_, _ = w.Write(data)
+			return
+		}
+		log.Printf("[WARN] Failed to encode YAML response, falling back to JSON: %v", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(payload)
+}
+
+// respondWithNegotiatedError is respondWithError for endpoints that support YAML
+func respondWithNegotiatedError(w http.ResponseWriter, r *http.Request, statusCode int, message string) {
+	respondNegotiated(w, r, statusCode, map[string]interface{}{
+		"error":  message,
+		"status": statusCode,
+	})
+}
+
+// marshalYAML round-trips payload through JSON so YAML keys match the JSON field names
+func marshalYAML(payload interface{}) ([]byte, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var generic interface{}
+	if err := decoder.Decode(&generic); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(normalizeJSONNumbers(generic))
+}
+
+// normalizeJSONNumbers converts json.Number values so YAML emits them unquoted
+func normalizeJSONNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = normalizeJSONNumbers(item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalizeJSONNumbers(item)
+		}
+		return v
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	default:
+		return v
+	}
+}
+
 type validateAttempt struct {
 	Endpoint    string `json:"endpoint"`
 	ApiBasePath string `json:"api_base_path,omitempty"`
@@ -127,18 +346,25 @@ func (s *Server) Router() http.Handler {
 	// API endpoints
 	mux.HandleFunc("/api/validate", s.handleValidateConnection)
 	mux.HandleFunc("/api/validate-query", s.handleValidateQuery)
+	mux.HandleFunc("/api/query", s.handleQuery)
 	mux.HandleFunc("/api/discover", s.handleDiscoverComponents)
+	mux.HandleFunc("/api/discover/batch", s.handleDiscoverBatch)
 	mux.HandleFunc("/api/discover-selector", s.handleDiscoverSelectorJobs)
+	mux.HandleFunc("/api/labels", s.handleDiscoverLabels)
 	mux.HandleFunc("/api/sample", s.handleGetSample)
 	mux.HandleFunc("/api/export", s.handleExport)
 	mux.HandleFunc("/api/export/start", s.handleExportStart)
+	mux.HandleFunc("/api/export/preview", s.handleExportPreview)
 	mux.HandleFunc("/api/export/resume", s.handleExportResume)
 	mux.HandleFunc("/api/export/status", s.handleExportStatus)
 	mux.HandleFunc("/api/fs/list", s.handleListDirectory)
 	mux.HandleFunc("/api/fs/check", s.handleCheckDirectory)
 	mux.HandleFunc("/api/export/cancel", s.handleExportCancel)
+	mux.HandleFunc("/api/schedule/status", s.handleScheduleStatus)
 	mux.HandleFunc("/api/config", s.handleConfig)
 	mux.HandleFunc("/api/download", s.handleDownload)
+	mux.HandleFunc("/api/exports", s.handleExports)
+	mux.HandleFunc("/api/archive/verify", s.handleArchiveVerify)
 	mux.HandleFunc("/api/health", s.handleHealth)
 
 	// Serve static files with proper MIME types
@@ -152,7 +378,7 @@ func (s *Server) Router() http.Handler {
 
 // handleHealth returns server health status
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
-	_ = json.NewEncoder(w).Encode(map[string]string{
+	respondNegotiated(w, r, http.StatusOK, map[string]string{
 		"status":  "ok",
 		"version": s.version,
 	})
@@ -160,20 +386,23 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		respondWithNegotiatedError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
 		return
 	}
 	defaultDir := recommendedStagingDir()
+	if s.safeMode {
+		defaultDir = s.safeModeStagingDir()
+	}
 	response := map[string]interface{}{
 		"version":              s.version,
 		"default_staging_dir":  defaultDir,
 		"os":                   runtime.GOOS,
 		"output_dir":           s.outputDir,
-		"supports_dir_picker":  true,
-		"supports_dir_prepare": true,
+		"safe_mode":            s.safeMode,
+		"supports_dir_picker":  !s.safeMode,
+		"supports_dir_prepare": !s.safeMode,
 	}
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(response)
+	respondNegotiated(w, r, http.StatusOK, response)
 }
 
 // handleValidateConnection validates VM connection
@@ -212,7 +441,7 @@ func (s *Server) handleValidateConnection(w http.ResponseWriter, r *http.Request
 	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
 
-	query := "vm_app_version"
+	query := validateVersionQuery
 	if s.debug {
 		log.Printf("Executing query: %s", query)
 	}
@@ -225,7 +454,7 @@ func (s *Server) handleValidateConnection(w http.ResponseWriter, r *http.Request
 
 	for _, candidate := range candidates {
 		client := vm.NewClient(candidate)
-		res, err := client.Query(ctx, query, time.Now())
+		res, err := client.QueryWithOptions(ctx, query, time.Now(), validateQueryOptions)
 		attempt := validateAttempt{
 			Endpoint:    buildFullEndpoint(candidate),
 			ApiBasePath: candidate.ApiBasePath,
@@ -269,16 +498,16 @@ func (s *Server) handleValidateConnection(w http.ResponseWriter, r *http.Request
 	if result != nil && result.Status == "success" && len(result.Data.Result) == 0 {
 		log.Printf("[WARN] vm_app_version returned no results, trying alternative queries...")
 
-		// Try to query any vm_* metric
-		result, err = client.Query(ctx, `{__name__=~"vm_.*"}`, time.Now())
+		// Count vm_* series per job instead of fetching them all
+		result, err = client.QueryWithOptions(ctx, validateFallbackQuery, time.Now(), validateQueryOptions)
 		if err == nil && len(result.Data.Result) > 0 {
-			log.Printf("[OK] Found %d vm_* metrics", len(result.Data.Result))
+			log.Printf("[OK] Found vm_* metrics for %d jobs", len(result.Data.Result))
 		}
 
 		// If still no results, try a simple constant query to verify API works
 		if err == nil && len(result.Data.Result) == 0 {
 			log.Printf("[WARN] No vm_* metrics found, trying constant query...")
-			result, err = client.Query(ctx, `1`, time.Now())
+			result, err = client.QueryWithOptions(ctx, `1`, time.Now(), validateQueryOptions)
 			if err == nil {
 				log.Printf("[OK] API responds correctly (Prometheus-compatible)")
 			}
@@ -360,19 +589,25 @@ func (s *Server) handleValidateConnection(w http.ResponseWriter, r *http.Request
 // handleDiscoverComponents discovers VM components
 func (s *Server) handleDiscoverComponents(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		respondWithNegotiatedError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	// Parse request body
 	var request struct {
-		Connection domain.VMConnection `json:"connection"`
-		TimeRange  domain.TimeRange    `json:"time_range"`
+		Connection      domain.VMConnection `json:"connection"`
+		TimeRange       domain.TimeRange    `json:"time_range"`
+		SampleInstances int                 `json:"sample_instances,omitempty"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		respondWithNegotiatedError(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
 		return
 	}
+	if request.SampleInstances < 0 {
+		respondWithNegotiatedError(w, r, http.StatusBadRequest, "sample_instances must be non-negative")
+		return
+	}
+	discoveryOpts := services.DiscoveryOptions{SampleInstances: request.SampleInstances}
 
 	// Propagate debug flag
 	if s.debug {
@@ -392,39 +627,15 @@ func (s *Server) handleDiscoverComponents(w http.ResponseWriter, r *http.Request
 	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
 	defer cancel()
 
-	components, err := s.vmService.DiscoverComponents(ctx, request.Connection, request.TimeRange)
+	components, err := s.discoverComponents(ctx, request.Connection, request.TimeRange, discoveryOpts)
 	if err != nil {
-		// If discovery fails and the client provided an ApiBasePath (common when users paste full /prometheus URLs),
-		// retry without the path so we still find VM components on single-node endpoints.
-		if request.Connection.ApiBasePath != "" && !request.Connection.IsMultitenant {
-			log.Printf("[WARN] Discovery failed with api_base_path=%q, retrying without base path...", request.Connection.ApiBasePath)
-			fallbackConn := request.Connection
-			fallbackConn.ApiBasePath = ""
-			fallbackConn.FullApiUrl = ""
-
-			components, err = s.vmService.DiscoverComponents(ctx, fallbackConn, request.TimeRange)
-			if err != nil {
-				errMsg, hint := formatVMError(err)
-				log.Printf("[ERROR] Discovery retry without base path failed: %s", errMsg)
-				if hint != "" {
-					log.Printf("[HINT] %s", hint)
-				}
-				respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("No VictoriaMetrics component metrics found at the provided URL: %s", errMsg))
-				return
-			}
-			// Success on fallback
-			request.Connection = fallbackConn
-		} else {
-			errMsg, hint := formatVMError(err)
-			log.Printf("[ERROR] Discovery failed: %s", errMsg)
-			if hint != "" {
-				log.Printf("[HINT] %s", hint)
-			}
-			respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("No VictoriaMetrics component metrics found at the provided URL: %s", errMsg))
-			return
-		}
+		errMsg, _ := formatVMError(err)
+		respondWithNegotiatedError(w, r, http.StatusInternalServerError, fmt.Sprintf("No VictoriaMetrics component metrics found at the provided URL: %s", errMsg))
+		return
 	}
 
+	s.estimates.record(request.Connection, components)
+
 	// Log discovery results
 	componentTypes := make(map[string]int)
 	for _, comp := range components {
@@ -436,12 +647,142 @@ func (s *Server) handleDiscoverComponents(w http.ResponseWriter, r *http.Request
 	}
 
 	// Return discovered components
+	respondNegotiated(w, r, http.StatusOK, map[string]interface{}{
+		"components": components,
+	})
+}
+
+// batchDiscoveryResult is the discovery outcome for one connection of a batch request
+type batchDiscoveryResult struct {
+	Index      int                  `json:"index"`
+	TenantID   string               `json:"tenant_id,omitempty"`
+	Components []domain.VMComponent `json:"components,omitempty"`
+	Error      string               `json:"error,omitempty"`
+}
+
+// handleDiscoverBatch runs discovery for many connections/tenants with a bounded worker pool.
+// A failing tenant only fails its own result entry.
+func (s *Server) handleDiscoverBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var request struct {
+		Connections     []domain.VMConnection `json:"connections"`
+		TimeRange       domain.TimeRange      `json:"time_range"`
+		Concurrency     int                   `json:"concurrency,omitempty"`
+		SampleInstances int                   `json:"sample_instances,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+	if len(request.Connections) == 0 {
+		respondWithError(w, http.StatusBadRequest, "connections must not be empty")
+		return
+	}
+	if len(request.Connections) > batchDiscoveryMaxTargets {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("at most %d connections per batch", batchDiscoveryMaxTargets))
+		return
+	}
+	if request.SampleInstances < 0 || request.Concurrency < 0 {
+		respondWithError(w, http.StatusBadRequest, "concurrency and sample_instances must be non-negative")
+		return
+	}
+	concurrency := request.Concurrency
+	if concurrency == 0 {
+		concurrency = batchDiscoveryDefaultConcurrency
+	}
+	if concurrency > batchDiscoveryMaxConcurrency {
+		concurrency = batchDiscoveryMaxConcurrency
+	}
+	opts := services.DiscoveryOptions{SampleInstances: request.SampleInstances}
+
+	results := make([]batchDiscoveryResult, len(request.Connections))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, conn := range request.Connections {
+		if s.debug {
+			conn.Debug = true
+		}
+		wg.Add(1)
+		go func(i int, conn domain.VMConnection) {
+			defer wg.Done()
+			result := batchDiscoveryResult{Index: i, TenantID: conn.TenantId}
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-r.Context().Done():
+				result.Error = r.Context().Err().Error()
+				results[i] = result
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), batchDiscoveryTargetTimeout)
+			defer cancel()
+			components, err := s.discoverComponents(ctx, conn, request.TimeRange, opts)
+			if err != nil {
+				errMsg, _ := formatVMError(err)
+				result.Error = errMsg
+			} else {
+				result.Components = components
+			}
+			results[i] = result
+		}(i, conn)
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, result := range results {
+		if result.Error != "" {
+			failed++
+		}
+	}
+	if s.debug {
+		log.Printf("[OK] Batch discovery complete: %d connections, %d failed", len(results), failed)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(map[string]interface{}{
-		"components": components,
+		"results": results,
+		"failed":  failed,
 	})
 }
 
+// discoverComponents runs discovery for one connection. If it fails and the client provided an
+// ApiBasePath (common when users paste full /prometheus URLs), discovery is retried without the
+// path so VM components are still found on single-node endpoints.
+func (s *Server) discoverComponents(ctx context.Context, conn domain.VMConnection, tr domain.TimeRange, opts services.DiscoveryOptions) ([]domain.VMComponent, error) {
+	components, err := s.vmService.DiscoverComponentsWithOptions(ctx, conn, tr, opts)
+	if err == nil {
+		return components, nil
+	}
+	if conn.ApiBasePath == "" || conn.IsMultitenant {
+		errMsg, hint := formatVMError(err)
+		log.Printf("[ERROR] Discovery failed: %s", errMsg)
+		if hint != "" {
+			log.Printf("[HINT] %s", hint)
+		}
+		return nil, err
+	}
+
+	log.Printf("[WARN] Discovery failed with api_base_path=%q, retrying without base path...", conn.ApiBasePath)
+	fallbackConn := conn
+	fallbackConn.ApiBasePath = ""
+	fallbackConn.FullApiUrl = ""
+	components, err = s.vmService.DiscoverComponentsWithOptions(ctx, fallbackConn, tr, opts)
+	if err != nil {
+		errMsg, hint := formatVMError(err)
+		log.Printf("[ERROR] Discovery retry without base path failed: %s", errMsg)
+		if hint != "" {
+			log.Printf("[HINT] %s", hint)
+		}
+		return nil, err
+	}
+	return components, nil
+}
+
 func (s *Server) handleValidateQuery(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
@@ -504,6 +845,112 @@ func (s *Server) handleValidateQuery(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(response)
 }
 
+// handleQuery runs a bounded instant query against the user's connection for ad-hoc inspection
+func (s *Server) handleQuery(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req struct {
+		Connection domain.VMConnection `json:"connection"`
+		Query      string              `json:"query"`
+		Time       *time.Time          `json:"time,omitempty"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+
+	query := strings.TrimSpace(req.Query)
+	if query == "" {
+		respondWithError(w, http.StatusBadRequest, "Query is required")
+		return
+	}
+	if len(query) > queryProxyMaxLength {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Query is too long (max %d characters)", queryProxyMaxLength))
+		return
+	}
+	if isUnboundedQuery(query) {
+		respondWithError(w, http.StatusBadRequest, "Query selects every series; add a metric name or a label matcher")
+		return
+	}
+
+	ts := time.Now()
+	if req.Time != nil {
+		ts = *req.Time
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), queryProxyTimeout)
+	defer cancel()
+
+	client := vm.NewClient(req.Connection)
+	result, err := client.Query(ctx, query, ts)
+	if err != nil {
+		errMsg, hint := formatVMError(err)
+		if hint != "" {
+			errMsg = fmt.Sprintf("%s. Hint: %s", errMsg, hint)
+		}
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Query failed: %s", errMsg))
+		return
+	}
+
+	series := result.Data.Result
+	truncated := false
+	if len(series) > queryProxyMaxSeries {
+		series = series[:queryProxyMaxSeries]
+		truncated = true
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":      true,
+		"result_type":  result.Data.ResultType,
+		"result":       series,
+		"series_count": len(result.Data.Result),
+		"truncated":    truncated,
+		"max_series":   queryProxyMaxSeries,
+	})
+}
+
+// bareSelectorRe matches selectors without a metric name, e.g. {job="x"} but not up{job="x"}
+var bareSelectorRe = regexp.MustCompile(`(^|[^a-zA-Z0-9_:])\{([^}]*)\}`)
+
+// isUnboundedQuery reports whether query contains a selector that matches every series,
+// such as {__name__!=""} or {__name__=~".*"}
+func isUnboundedQuery(query string) bool {
+	for _, match := range bareSelectorRe.FindAllStringSubmatch(query, -1) {
+		bounded := false
+		for _, matcher := range strings.Split(match[2], ",") {
+			matcher = strings.Join(strings.Fields(matcher), "")
+			if matcher == "" {
+				continue
+			}
+			if !isMatchAllMatcher(matcher) {
+				bounded = true
+				break
+			}
+		}
+		if !bounded {
+			return true
+		}
+	}
+	return false
+}
+
+func isMatchAllMatcher(matcher string) bool {
+	for _, suffix := range []string{`=~".*"`, `=~".+"`, "=~'.*'", "=~'.+'"} {
+		if strings.HasSuffix(matcher, suffix) {
+			return true
+		}
+	}
+	switch matcher {
+	case `__name__!=""`, `__name__!~""`, "__name__!=''":
+		return true
+	}
+	return false
+}
+
 func (s *Server) handleDiscoverSelectorJobs(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
@@ -548,6 +995,53 @@ func (s *Server) handleDiscoverSelectorJobs(w http.ResponseWriter, r *http.Reque
 	})
 }
 
+// handleDiscoverLabels lists the label names of the export set so obfuscation can be configured
+func (s *Server) handleDiscoverLabels(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var request struct {
+		Config domain.ExportConfig `json:"config"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+	if request.Config.Mode == domain.ExportModeCustom && request.Config.QueryType == domain.QueryModeMetricsQL {
+		respondWithError(w, http.StatusBadRequest, "Label discovery requires a series selector, not a MetricsQL query")
+		return
+	}
+	if err := services.ValidateInstances(request.Config.Instances); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if s.debug {
+		request.Config.Connection.Debug = true
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	labels, err := s.vmService.DiscoverLabels(ctx, request.Config)
+	if err != nil {
+		errMsg, hint := formatVMError(err)
+		log.Printf("[ERROR] Label discovery failed: %s", errMsg)
+		if hint != "" {
+			log.Printf("[HINT] %s", hint)
+		}
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Label discovery failed: %s", errMsg))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"labels": labels,
+	})
+}
+
 // handleGetSample returns sample metrics
 func (s *Server) handleGetSample(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -682,6 +1176,20 @@ func (s *Server) handleExport(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ensureBatchDefaults(&config)
+	if config.ExportID != "" {
+		if err := services.ValidateExportID(config.ExportID); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if err := services.ValidateInstances(config.Instances); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := s.enforceSafeModePaths(&config); err != nil {
+		respondWithError(w, http.StatusForbidden, err.Error())
+		return
+	}
 
 	// Propagate debug flag
 	if s.debug {
@@ -746,6 +1254,9 @@ func (s *Server) handleExport(w http.ResponseWriter, r *http.Request) {
 	if sampleErrorMsg != "" {
 		response["sample_error"] = sampleErrorMsg
 	}
+	if len(result.Uploads) > 0 {
+		response["uploads"] = result.Uploads
+	}
 
 	// Return export result
 	w.Header().Set("Content-Type", "application/json")
@@ -764,6 +1275,24 @@ func (s *Server) handleExportStart(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	ensureBatchDefaults(&config)
+	if config.ExportID != "" {
+		if err := services.ValidateExportID(config.ExportID); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if err := services.ValidateInstances(config.Instances); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := upload.ValidateWebhook(config.Webhook); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := s.enforceSafeModePaths(&config); err != nil {
+		respondWithError(w, http.StatusForbidden, err.Error())
+		return
+	}
 	jobID := fmt.Sprintf("job-%d", time.Now().UnixNano())
 	stagingDir := config.StagingDir
 	if stagingDir == "" {
@@ -775,24 +1304,29 @@ func (s *Server) handleExportStart(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	stagingDir = absDir
-	if err := os.MkdirAll(stagingDir, 0o755); err != nil {
-		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to prepare staging directory: %v", err))
+	var dirErr *stagingDirError
+	if err := prepareStagingDir(stagingDir); errors.As(err, &dirErr) {
+		respondWithError(w, dirErr.status, dirErr.message)
 		return
 	}
-	// Check write permission by creating temp file
-	testFile := filepath.Join(stagingDir, ".vmgather-write-test")
-	testHandle, err := os.Create(testFile)
+	heavy, err := s.unconfirmedHeavyComponents(r.Context(), config)
 	if err != nil {
-		respondWithError(w, http.StatusForbidden, fmt.Sprintf("Cannot write to staging directory %s: %v", stagingDir, err))
+		respondWithError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	if len(heavy) > 0 {
+		s.respondWithHeavyComponents(w, heavy)
 		return
 	}
-	_ = testHandle.Close()
-	_ = os.Remove(testFile)
 
 	config.StagingDir = stagingDir
-	config.StagingFile = filepath.Join(stagingDir, fmt.Sprintf("%s.partial.jsonl", jobID))
+	config.StagingFile = filepath.Join(stagingDir, services.StagingFileName(jobID, config.StagingGzip))
 
 	status, err := s.jobManager.StartJob(r.Context(), jobID, config)
+	if errors.Is(err, errExportIDInUse) {
+		respondWithError(w, http.StatusConflict, err.Error())
+		return
+	}
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to start export: %v", err))
 		return
@@ -805,6 +1339,9 @@ func (s *Server) handleExportStart(w http.ResponseWriter, r *http.Request) {
 		"staging_path":         config.StagingFile,
 		"obfuscation_enabled":  status.ObfuscationEnabled,
 	}
+	if status.QueuePosition > 0 {
+		response["queue_position"] = status.QueuePosition
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(response)
@@ -816,7 +1353,8 @@ func (s *Server) handleExportResume(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var req struct {
-		JobID string `json:"job_id"`
+		JobID      string               `json:"job_id"`
+		Connection *domain.VMConnection `json:"connection,omitempty"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
@@ -827,7 +1365,11 @@ func (s *Server) handleExportResume(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	status, err := s.jobManager.ResumeJob(r.Context(), req.JobID)
+	status, err := s.jobManager.ResumeJobWithConnection(r.Context(), req.JobID, req.Connection)
+	if errors.Is(err, errExportIDInUse) {
+		respondWithError(w, http.StatusConflict, err.Error())
+		return
+	}
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Failed to resume export: %v", err))
 		return
@@ -848,19 +1390,19 @@ func (s *Server) handleExportResume(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleExportStatus(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		respondWithNegotiatedError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	jobID := r.URL.Query().Get("id")
 	if jobID == "" {
-		respondWithError(w, http.StatusBadRequest, "Missing id parameter")
+		respondWithNegotiatedError(w, r, http.StatusBadRequest, "Missing id parameter")
 		return
 	}
 
 	status, ok := s.jobManager.GetStatus(jobID)
 	if !ok {
-		respondWithError(w, http.StatusNotFound, fmt.Sprintf("Job %s not found", jobID))
+		respondWithNegotiatedError(w, r, http.StatusNotFound, fmt.Sprintf("Job %s not found", jobID))
 		return
 	}
 	log.Printf("[EXPORT][HTTP] status job_id=%s state=%s completed=%d total=%d staging=%s",
@@ -907,8 +1449,7 @@ func (s *Server) handleExportStatus(w http.ResponseWriter, r *http.Request) {
 	}
 	response["obfuscation_enabled"] = status.ObfuscationEnabled
 
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(response)
+	respondNegotiated(w, r, http.StatusOK, response)
 }
 
 func (s *Server) handleExportCancel(w http.ResponseWriter, r *http.Request) {
@@ -966,16 +1507,6 @@ func recommendedStagingDir() string {
 	return filepath.Join(os.TempDir(), "vmgather")
 }
 
-func ensureWritableDirectory(path string) error {
-	testFile := filepath.Join(path, fmt.Sprintf(".vmgather-check-%d", time.Now().UnixNano()))
-	file, err := os.Create(testFile)
-	if err != nil {
-		return err
-	}
-	_ = file.Close()
-	return os.Remove(testFile)
-}
-
 func canCreateDirectory(path string) bool {
 	dir := filepath.Clean(path)
 	for {
@@ -1015,6 +1546,10 @@ func isLoopbackRemoteAddr(remoteAddr string) bool {
 }
 
 func (s *Server) handleListDirectory(w http.ResponseWriter, r *http.Request) {
+	if s.safeMode {
+		respondWithError(w, http.StatusForbidden, "Filesystem browsing is disabled in safe mode")
+		return
+	}
 	if r.Method != http.MethodGet {
 		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
 		return
@@ -1102,6 +1637,10 @@ func (s *Server) handleListDirectory(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleCheckDirectory(w http.ResponseWriter, r *http.Request) {
+	if s.safeMode {
+		respondWithError(w, http.StatusForbidden, "Filesystem browsing is disabled in safe mode")
+		return
+	}
 	if r.Method != http.MethodPost {
 		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
 		return
@@ -1175,7 +1714,7 @@ func (s *Server) handleCheckDirectory(w http.ResponseWriter, r *http.Request) {
 			"abs_path":   absPath,
 			"exists":     true,
 			"can_create": false,
-			"message":    fmt.Sprintf("Cannot write to directory: %v", err),
+			"message":    describeWriteCheckError(absPath, err),
 		})
 		return
 	}
@@ -1230,33 +1769,14 @@ func (s *Server) getSampleDataFromResult(ctx context.Context, config domain.Expo
 	return sampleData, nil
 }
 
-// handleDownload serves archive file for download
-func (s *Server) handleDownload(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
-		return
-	}
-
-	// Get file path from query parameter
-	filePath := r.URL.Query().Get("path")
-	if filePath == "" {
-		respondWithError(w, http.StatusBadRequest, "Missing path parameter")
-		return
-	}
-
-	// DEBUG: Log download request
-	if s.debug {
-		log.Printf("Archive Download:")
-		log.Printf("  File Path: %s", filePath)
-		log.Printf("  Client IP: %s", r.RemoteAddr)
-	}
-
+// resolveOutputFile resolves a client-supplied path to an existing file inside the output directory,
+// blocking traversal and symlink escapes. On failure it also returns the HTTP status to answer with.
+func (s *Server) resolveOutputFile(filePath string) (string, os.FileInfo, int, error) {
 	// Security: ensure file is within output directory
 	absOutputDir, err := filepath.Abs(s.outputDir)
 	if err != nil {
 		log.Printf("[ERROR] Failed to resolve output directory: %v", err)
-		respondWithError(w, http.StatusInternalServerError, "Internal server error")
-		return
+		return "", nil, http.StatusInternalServerError, errors.New("Internal server error")
 	}
 
 	// Resolve requested path to absolute path
@@ -1264,8 +1784,7 @@ func (s *Server) handleDownload(w http.ResponseWriter, r *http.Request) {
 	absFilePath, err := filepath.Abs(filePath)
 	if err != nil {
 		log.Printf("[ERROR] Failed to resolve file path: %v", err)
-		respondWithError(w, http.StatusBadRequest, "Invalid path")
-		return
+		return "", nil, http.StatusBadRequest, errors.New("Invalid path")
 	}
 
 	// Ensure the path is clean
@@ -1277,8 +1796,7 @@ func (s *Server) handleDownload(w http.ResponseWriter, r *http.Request) {
 	prefix := absOutputDir + string(os.PathSeparator)
 	if !strings.HasPrefix(absFilePath, prefix) && absFilePath != absOutputDir {
 		log.Printf("[WARN] Blocked path traversal attempt: %s (resolved: %s, allowed: %s)", filePath, absFilePath, absOutputDir)
-		respondWithError(w, http.StatusForbidden, "Access denied: file must be in export directory")
-		return
+		return "", nil, http.StatusForbidden, errors.New("Access denied: file must be in export directory")
 	}
 
 	// Check if file exists
@@ -1286,17 +1804,10 @@ func (s *Server) handleDownload(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		if os.IsNotExist(err) {
 			log.Printf("[ERROR] File not found: %s", absFilePath)
-			respondWithError(w, http.StatusNotFound, fmt.Sprintf("File not found: %s", filePath))
-			return
+			return "", nil, http.StatusNotFound, fmt.Errorf("File not found: %s", filePath)
 		}
 		log.Printf("[ERROR] File access error: %v", err)
-		respondWithError(w, http.StatusInternalServerError, "File access error")
-		return
-	}
-
-	if info.IsDir() {
-		respondWithError(w, http.StatusBadRequest, "Cannot download a directory")
-		return
+		return "", nil, http.StatusInternalServerError, errors.New("File access error")
 	}
 
 	// Block symlink escapes out of outputDir. This matters if outputDir contains symlinks or the requested file
@@ -1310,15 +1821,46 @@ func (s *Server) handleDownload(w http.ResponseWriter, r *http.Request) {
 	realFilePath, err := filepath.EvalSymlinks(absFilePath)
 	if err != nil {
 		log.Printf("[ERROR] Failed to resolve file path symlinks: %v", err)
-		respondWithError(w, http.StatusBadRequest, "Invalid path")
-		return
+		return "", nil, http.StatusBadRequest, errors.New("Invalid path")
 	}
 	realFilePath = filepath.Clean(realFilePath)
 
 	rel, err := filepath.Rel(realOutputDir, realFilePath)
 	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
 		log.Printf("[WARN] Blocked symlink escape attempt: %s (resolved: %s, allowed: %s)", filePath, realFilePath, realOutputDir)
-		respondWithError(w, http.StatusForbidden, "Access denied: file must be in export directory")
+		return "", nil, http.StatusForbidden, errors.New("Access denied: file must be in export directory")
+	}
+	return absFilePath, info, 0, nil
+}
+
+// handleDownload serves archive file for download
+func (s *Server) handleDownload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	// Get file path from query parameter
+	filePath := r.URL.Query().Get("path")
+	if filePath == "" {
+		respondWithError(w, http.StatusBadRequest, "Missing path parameter")
+		return
+	}
+
+	// DEBUG: Log download request
+	if s.debug {
+		log.Printf("Archive Download:")
+		log.Printf("  File Path: %s", filePath)
+		log.Printf("  Client IP: %s", r.RemoteAddr)
+	}
+
+	absFilePath, info, status, err := s.resolveOutputFile(filePath)
+	if err != nil {
+		respondWithError(w, status, err.Error())
+		return
+	}
+	if info.IsDir() {
+		respondWithError(w, http.StatusBadRequest, "Cannot download a directory")
 		return
 	}
 
@@ -1336,6 +1878,8 @@ func (s *Server) handleDownload(w http.ResponseWriter, r *http.Request) {
 func (s *Server) obfuscateSamples(samples []domain.MetricSample, config domain.ObfuscationConfig) []domain.MetricSample {
 	// Create obfuscator
 	obfuscator := obfuscation.NewObfuscator()
+	// Invalid rules are rejected when the export starts; the preview just leaves them out
+	categories, _ := obfuscation.NewCategorizer(config)
 
 	// Apply obfuscation to each sample
 	for i := range samples {
@@ -1353,17 +1897,18 @@ func (s *Server) obfuscateSamples(samples []domain.MetricSample, config domain.O
 		if !config.Enabled {
 			continue
 		}
+		categories.Apply(samples[i].Labels)
 
 		// Obfuscate instance
 		if config.ObfuscateInstance {
-			if instance, exists := samples[i].Labels["instance"]; exists {
+			if instance, exists := samples[i].Labels["instance"]; exists && !obfuscation.Allowlisted(config, instance) {
 				samples[i].Labels["instance"] = obfuscator.ObfuscateInstance(instance)
 			}
 		}
 
 		// Obfuscate job
 		if config.ObfuscateJob {
-			if job, exists := samples[i].Labels["job"]; exists {
+			if job, exists := samples[i].Labels["job"]; exists && !obfuscation.Allowlisted(config, job) {
 				// Try to determine component from metric name
 				component := "unknown"
 				if metricName, ok := samples[i].Labels["__name__"]; ok {
@@ -1375,7 +1920,7 @@ func (s *Server) obfuscateSamples(samples []domain.MetricSample, config domain.O
 
 		// Obfuscate custom labels (pod, namespace, etc.)
 		for _, label := range config.CustomLabels {
-			if value, exists := samples[i].Labels[label]; exists {
+			if value, exists := samples[i].Labels[label]; exists && !obfuscation.Allowlisted(config, value) {
 				// Use simple hash-based obfuscation for custom labels
 				samples[i].Labels[label] = obfuscator.ObfuscateCustomLabel(label, value)
 			}
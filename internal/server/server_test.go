@@ -12,11 +12,13 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/VictoriaMetrics/vmgather/internal/application/services"
 	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	"gopkg.in/yaml.v3"
 )
 
 // TestServer_GetSampleDataFromResult tests getSampleDataFromResult function
@@ -722,6 +724,25 @@ func TestServer_GetSampleDataFromResult_ObfuscatesWhenEnabled(t *testing.T) {
 	}
 }
 
+func TestServer_ObfuscateSamples_InstanceWithoutPort(t *testing.T) {
+	server := NewServer(t.TempDir(), "test-version", false)
+	samples := []domain.MetricSample{
+		{MetricName: "up", Labels: map[string]string{"instance": "vmselect-0"}},
+		{MetricName: "up", Labels: map[string]string{"instance": "10.0.0.7"}},
+		{MetricName: "up", Labels: map[string]string{"instance": "vmselect-0"}},
+		{MetricName: "up", Labels: map[string]string{"instance": "10.0.0.7:8481"}},
+	}
+	config := domain.ObfuscationConfig{Enabled: true, ObfuscateInstance: true}
+
+	got := server.obfuscateSamples(samples, config)
+	want := []string{"777.777.1.1", "777.777.1.2", "777.777.1.1", "777.777.1.3:8481"}
+	for i, sample := range got {
+		if sample.Labels["instance"] != want[i] {
+			t.Errorf("sample %d: instance = %q, want %q", i, sample.Labels["instance"], want[i])
+		}
+	}
+}
+
 func TestServer_GetSampleDataFromResult_DropsLabelsWithoutObfuscation(t *testing.T) {
 	tmpDir := t.TempDir()
 	server := NewServer(tmpDir, "test-version", false)
@@ -811,6 +832,14 @@ func (m *mockVMService) DiscoverComponents(ctx context.Context, conn domain.VMCo
 	return nil, nil
 }
 
+func (m *mockVMService) DiscoverComponentsWithOptions(ctx context.Context, conn domain.VMConnection, tr domain.TimeRange, opts services.DiscoveryOptions) ([]domain.VMComponent, error) {
+	return nil, nil
+}
+
+func (m *mockVMService) DiscoverLabels(ctx context.Context, config domain.ExportConfig) ([]string, error) {
+	return nil, nil
+}
+
 func (m *mockVMService) DiscoverSelectorJobs(ctx context.Context, conn domain.VMConnection, selector string, tr domain.TimeRange) ([]domain.SelectorJob, error) {
 	return nil, nil
 }
@@ -829,3 +858,352 @@ func (m *mockVMService) EstimateExportSize(ctx context.Context, conn domain.VMCo
 func (m *mockVMService) CheckExportAPI(ctx context.Context, conn domain.VMConnection) bool {
 	return true
 }
+
+func TestHandleQuery_ReturnsBoundedResult(t *testing.T) {
+	var gotQuery string
+	vmServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/query" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		_ = r.ParseForm()
+		gotQuery = r.Form.Get("query")
+		result := make([]map[string]interface{}, 0, queryProxyMaxSeries+5)
+		for i := 0; i < queryProxyMaxSeries+5; i++ {
+			result = append(result, map[string]interface{}{
+				"metric": map[string]string{"__name__": "up", "instance": fmt.Sprintf("host-%d", i)},
+				"value":  []interface{}{1700000000, "1"},
+			})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "success",
+			"data":   map[string]interface{}{"resultType": "vector", "result": result},
+		})
+	}))
+	defer vmServer.Close()
+
+	server := NewServer(t.TempDir(), "test-version", false)
+	body, _ := json.Marshal(map[string]interface{}{
+		"connection": map[string]interface{}{"url": vmServer.URL, "auth": map[string]string{"type": "none"}},
+		"query":      `up{job="vmstorage"}`,
+	})
+	req := httptest.NewRequest(http.MethodPost, "/api/query", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	server.Router().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if gotQuery != `up{job="vmstorage"}` {
+		t.Fatalf("unexpected proxied query %q", gotQuery)
+	}
+	var resp struct {
+		ResultType  string        `json:"result_type"`
+		Result      []interface{} `json:"result"`
+		SeriesCount int           `json:"series_count"`
+		Truncated   bool          `json:"truncated"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid json: %v", err)
+	}
+	if resp.ResultType != "vector" || len(resp.Result) != queryProxyMaxSeries || resp.SeriesCount != queryProxyMaxSeries+5 || !resp.Truncated {
+		t.Fatalf("unexpected response: type=%s len=%d count=%d truncated=%v", resp.ResultType, len(resp.Result), resp.SeriesCount, resp.Truncated)
+	}
+}
+
+func TestHandleQuery_RejectsUnboundedSelector(t *testing.T) {
+	vmCalled := false
+	vmServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vmCalled = true
+	}))
+	defer vmServer.Close()
+
+	server := NewServer(t.TempDir(), "test-version", false)
+	for _, query := range []string{`{__name__!=""}`, `count({ __name__ =~ ".*" })`, `up or {__name__=~".+"}`} {
+		body, _ := json.Marshal(map[string]interface{}{
+			"connection": map[string]interface{}{"url": vmServer.URL},
+			"query":      query,
+		})
+		req := httptest.NewRequest(http.MethodPost, "/api/query", bytes.NewReader(body))
+		w := httptest.NewRecorder()
+		server.Router().ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", query, w.Code)
+		}
+	}
+	if vmCalled {
+		t.Fatal("unbounded queries must not reach VictoriaMetrics")
+	}
+
+	for _, query := range []string{`{job="vmstorage"}`, `up`, `sum(rate(vm_rows{__name__!=""}[5m]))`} {
+		if isUnboundedQuery(query) {
+			t.Fatalf("%s: expected query to be treated as bounded", query)
+		}
+	}
+}
+
+func TestHandleConfig_YAMLNegotiation(t *testing.T) {
+	server := NewServer(t.TempDir(), "test-version", false)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/config", nil)
+	req.Header.Set("Accept", "application/yaml")
+	rec := httptest.NewRecorder()
+	server.Router().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/yaml" {
+		t.Fatalf("expected application/yaml content type, got %q", ct)
+	}
+	var payload map[string]interface{}
+	if err := yaml.Unmarshal(rec.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("response is not valid YAML: %v\n%s", err, rec.Body.String())
+	}
+	if payload["version"] != "test-version" {
+		t.Fatalf("expected version test-version, got %v", payload["version"])
+	}
+	if payload["safe_mode"] != false {
+		t.Fatalf("expected safe_mode false, got %v", payload["safe_mode"])
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/config", nil)
+	rec = httptest.NewRecorder()
+	server.Router().ServeHTTP(rec, req)
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("expected JSON by default, got %q", ct)
+	}
+}
+
+func TestHandleExportStatus_YAMLErrors(t *testing.T) {
+	server := NewServer(t.TempDir(), "test", false)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/export/status?id=missing", nil)
+	req.Header.Set("Accept", "text/yaml, application/json;q=0.5")
+	rec := httptest.NewRecorder()
+	server.Router().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rec.Code)
+	}
+	var payload struct {
+		Error  string `yaml:"error"`
+		Status int    `yaml:"status"`
+	}
+	if err := yaml.Unmarshal(rec.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("error response is not valid YAML: %v", err)
+	}
+	if payload.Status != http.StatusNotFound || !strings.Contains(payload.Error, "missing") {
+		t.Fatalf("unexpected error payload: %+v", payload)
+	}
+}
+
+func TestHandleDiscoverComponents_YAMLNegotiation(t *testing.T) {
+	server := NewServer(t.TempDir(), "test", false)
+	server.vmService = &mockVMService{}
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"connection": map[string]interface{}{"url": "http://127.0.0.1:8428"},
+		"time_range": map[string]string{
+			"start": time.Now().Add(-time.Hour).Format(time.RFC3339),
+			"end":   time.Now().Format(time.RFC3339),
+		},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/api/discover", bytes.NewReader(body))
+	req.Header.Set("Accept", "application/yaml")
+	rec := httptest.NewRecorder()
+	server.Router().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/yaml" {
+		t.Fatalf("expected application/yaml content type, got %q", ct)
+	}
+	var payload map[string]interface{}
+	if err := yaml.Unmarshal(rec.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("response is not valid YAML: %v\n%s", err, rec.Body.String())
+	}
+	if _, ok := payload["components"]; !ok {
+		t.Fatalf("expected components in the YAML response, got %v", payload)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/discover", strings.NewReader("{"))
+	req.Header.Set("Accept", "text/yaml")
+	rec = httptest.NewRecorder()
+	server.Router().ServeHTTP(rec, req)
+	var errPayload struct {
+		Error  string `yaml:"error"`
+		Status int    `yaml:"status"`
+	}
+	if rec.Code != http.StatusBadRequest || rec.Header().Get("Content-Type") != "application/yaml" {
+		t.Fatalf("expected a 400 YAML error, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if err := yaml.Unmarshal(rec.Body.Bytes(), &errPayload); err != nil || errPayload.Status != http.StatusBadRequest {
+		t.Fatalf("unexpected YAML error payload %+v (%v)", errPayload, err)
+	}
+}
+
+func TestWantsYAML_HonorsQualityValues(t *testing.T) {
+	cases := []struct {
+		accept string
+		want   bool
+	}{
+		{"", false},
+		{"application/yaml", true},
+		{"application/yaml;q=0", false},
+		{"application/yaml; q=0, */*", false},
+		{"application/json;q=0.5, text/yaml", true},
+		{"application/yaml;q=0.4, application/json;q=0.9", false},
+		{"application/json, application/yaml", false},
+		{"application/yaml, application/json", true},
+		{"application/yaml;q=bogus", false},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/api/config", nil)
+		req.Header.Set("Accept", tc.accept)
+		if got := wantsYAML(req); got != tc.want {
+			t.Errorf("Accept %q: expected wantsYAML %v, got %v", tc.accept, tc.want, got)
+		}
+	}
+}
+
+func TestHandleValidateConnection_BoundsValidationQueries(t *testing.T) {
+	var mu sync.Mutex
+	var queries []string
+	var limits []string
+	vmServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("query")
+		mu.Lock()
+		queries = append(queries, query)
+		limits = append(limits, r.URL.Query().Get("limit"))
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		if query == validateFallbackQuery {
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{"job":"vmsingle"},"value":[1,"1234"]}]}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+	}))
+	defer vmServer.Close()
+
+	server := NewServer(t.TempDir(), "test-version", false)
+	body, _ := json.Marshal(map[string]interface{}{
+		"connection": map[string]interface{}{
+			"url":  vmServer.URL,
+			"auth": map[string]interface{}{"type": "none"},
+		},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/api/validate", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+	server.Router().ServeHTTP(rec, req)
+
+	var resp map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp["success"] != true {
+		t.Fatalf("expected successful validation, got %v", resp)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(queries) != 2 || queries[0] != validateVersionQuery || queries[1] != validateFallbackQuery {
+		t.Fatalf("unexpected validation queries: %v", queries)
+	}
+	for i, query := range queries {
+		if query == `{__name__=~"vm_.*"}` {
+			t.Fatalf("validation must not fetch all vm_* series")
+		}
+		if limits[i] != fmt.Sprintf("%d", validateQueryMaxSeries) {
+			t.Fatalf("expected limit=%d for %q, got %q", validateQueryMaxSeries, query, limits[i])
+		}
+	}
+}
+
+// fanoutVMService records how many discoveries run at once and fails one tenant
+type fanoutVMService struct {
+	mockVMService
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+}
+
+func (m *fanoutVMService) DiscoverComponentsWithOptions(ctx context.Context, conn domain.VMConnection, tr domain.TimeRange, opts services.DiscoveryOptions) ([]domain.VMComponent, error) {
+	m.mu.Lock()
+	m.inFlight++
+	if m.inFlight > m.maxInFlight {
+		m.maxInFlight = m.inFlight
+	}
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		m.inFlight--
+		m.mu.Unlock()
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	if conn.TenantId == "3" {
+		return nil, fmt.Errorf("tenant 3 unavailable")
+	}
+	return []domain.VMComponent{{Component: "vmstorage", Jobs: []string{"storage-" + conn.TenantId}}}, nil
+}
+
+func TestHandleDiscoverBatch_ConcurrentWithErrorIsolation(t *testing.T) {
+	server := NewServer(t.TempDir(), "test-version", false)
+	vmService := &fanoutVMService{}
+	server.vmService = vmService
+
+	connections := make([]map[string]interface{}, 0, 6)
+	for i := 0; i < 6; i++ {
+		connections = append(connections, map[string]interface{}{
+			"url":            "http://vmselect:8481",
+			"tenant_id":      fmt.Sprintf("%d", i),
+			"is_multitenant": true,
+			"auth":           map[string]interface{}{"type": "none"},
+		})
+	}
+	body, _ := json.Marshal(map[string]interface{}{
+		"connections": connections,
+		"concurrency": 3,
+		"time_range": map[string]string{
+			"start": time.Now().Add(-time.Hour).Format(time.RFC3339),
+			"end":   time.Now().Format(time.RFC3339),
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/discover/batch", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+	server.Router().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Results []batchDiscoveryResult `json:"results"`
+		Failed  int                    `json:"failed"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Results) != 6 || resp.Failed != 1 {
+		t.Fatalf("expected 6 results with 1 failure, got %d results, %d failed", len(resp.Results), resp.Failed)
+	}
+	for i, result := range resp.Results {
+		if result.Index != i || result.TenantID != fmt.Sprintf("%d", i) {
+			t.Fatalf("result %d out of order: %+v", i, result)
+		}
+		if i == 3 {
+			if !strings.Contains(result.Error, "tenant 3 unavailable") || len(result.Components) != 0 {
+				t.Fatalf("expected isolated error for tenant 3, got %+v", result)
+			}
+			continue
+		}
+		if result.Error != "" || len(result.Components) != 1 || result.Components[0].Jobs[0] != "storage-"+result.TenantID {
+			t.Fatalf("unexpected result for tenant %s: %+v", result.TenantID, result)
+		}
+	}
+	if vmService.maxInFlight < 2 || vmService.maxInFlight > 3 {
+		t.Fatalf("expected concurrent discovery bounded by 3, max in flight was %d", vmService.maxInFlight)
+	}
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// writeCheckRetryDelay spaces the single retry of a failed write check; network mounts often fail
+// transiently right after a directory is created
+var writeCheckRetryDelay = 250 * time.Millisecond
+
+// createProbeFile creates the write-check file; replaced in tests to simulate filesystems
+var createProbeFile = func(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+}
+
+// stagingDirError is an actionable reason why a staging directory cannot be used
+type stagingDirError struct {
+	status  int
+	message string
+}
+
+func (e *stagingDirError) Error() string {
+	return e.message
+}
+
+// prepareStagingDir creates dir if needed and checks that files can be written to it. The result
+// tells "cannot create the directory" apart from "exists but not writable" and suggests a fix.
+func prepareStagingDir(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		if errors.Is(err, syscall.ENOTDIR) || errors.Is(err, fs.ErrExist) {
+			return &stagingDirError{http.StatusBadRequest, fmt.Sprintf("Staging path %s exists but is not a directory. Choose a directory.", dir)}
+		}
+		if errors.Is(err, fs.ErrPermission) || errors.Is(err, syscall.EROFS) {
+			return &stagingDirError{http.StatusForbidden, fmt.Sprintf("Cannot create staging directory %s: %v. Create it yourself or choose a directory inside one you can write to.", dir, err)}
+		}
+		return &stagingDirError{http.StatusInternalServerError, fmt.Sprintf("Failed to prepare staging directory %s: %v", dir, err)}
+	}
+	if err := ensureWritableDirectory(dir); err != nil {
+		return &stagingDirError{http.StatusForbidden, describeWriteCheckError(dir, err)}
+	}
+	return nil
+}
+
+// describeWriteCheckError explains a failed write check on an existing directory
+func describeWriteCheckError(dir string, err error) string {
+	switch {
+	case errors.Is(err, syscall.EROFS):
+		return fmt.Sprintf("Staging directory %s is on a read-only filesystem. Choose a writable directory.", dir)
+	case errors.Is(err, fs.ErrPermission):
+		return fmt.Sprintf("Staging directory %s exists but is not writable (%v). Fix its permissions or choose another directory.", dir, err)
+	default:
+		return fmt.Sprintf("Cannot create files in staging directory %s (%v). If it is on a network mount (NFS, SMB), choose a local directory instead.", dir, err)
+	}
+}
+
+// ensureWritableDirectory creates and removes a uniquely named file in path, retrying once
+func ensureWritableDirectory(path string) error {
+	err := probeWrite(path)
+	if err == nil {
+		return nil
+	}
+	time.Sleep(writeCheckRetryDelay)
+	return probeWrite(path)
+}
+
+func probeWrite(path string) error {
+	testFile := filepath.Join(path, fmt.Sprintf(".vmgather-check-%d", time.Now().UnixNano()))
+	file, err := createProbeFile(testFile)
+	if err != nil {
+		return err
+	}
+	_, writeErr := file.Write([]byte{'\n'})
+	closeErr := file.Close()
+	removeErr := os.Remove(testFile)
+	if writeErr != nil {
+		return writeErr
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+	return removeErr
+}
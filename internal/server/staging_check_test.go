@@ -0,0 +1,102 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// stubProbeFile makes the write check fail with errs in order, then behave normally
+func stubProbeFile(t *testing.T, errs ...error) *int {
+	t.Helper()
+	calls := 0
+	prevCreate, prevDelay := createProbeFile, writeCheckRetryDelay
+	createProbeFile = func(path string) (*os.File, error) {
+		calls++
+		if calls <= len(errs) {
+			return nil, &fs.PathError{Op: "open", Path: path, Err: errs[calls-1]}
+		}
+		return prevCreate(path)
+	}
+	writeCheckRetryDelay = time.Millisecond
+	t.Cleanup(func() { createProbeFile, writeCheckRetryDelay = prevCreate, prevDelay })
+	return &calls
+}
+
+func TestPrepareStagingDirMessages(t *testing.T) {
+	dir := t.TempDir()
+	if err := prepareStagingDir(filepath.Join(dir, "new", "staging")); err != nil {
+		t.Fatalf("expected a creatable directory to pass, got %v", err)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(dir, "new", "staging")); len(entries) != 0 {
+		t.Fatalf("expected the write check to clean up, found %v", entries)
+	}
+
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	assertStagingDirError(t, prepareStagingDir(file), http.StatusBadRequest, "exists but is not a directory")
+
+	calls := stubProbeFile(t, syscall.EACCES, syscall.EACCES)
+	assertStagingDirError(t, prepareStagingDir(dir), http.StatusForbidden, "exists but is not writable")
+	if *calls != 2 {
+		t.Fatalf("expected the write check to be retried once, got %d attempts", *calls)
+	}
+
+	stubProbeFile(t, syscall.EROFS, syscall.EROFS)
+	assertStagingDirError(t, prepareStagingDir(dir), http.StatusForbidden, "read-only filesystem")
+
+	stubProbeFile(t, syscall.EIO, syscall.EIO)
+	assertStagingDirError(t, prepareStagingDir(dir), http.StatusForbidden, "choose a local directory")
+
+	// A transient failure, as seen on network mounts, passes on the retry
+	calls = stubProbeFile(t, syscall.ESTALE)
+	if err := prepareStagingDir(dir); err != nil || *calls != 2 {
+		t.Fatalf("expected the retry to succeed, got %v after %d attempts", err, *calls)
+	}
+}
+
+func assertStagingDirError(t *testing.T, err error, status int, message string) {
+	t.Helper()
+	dirErr, ok := err.(*stagingDirError)
+	if !ok {
+		t.Fatalf("expected a stagingDirError, got %v", err)
+	}
+	if dirErr.status != status || !strings.Contains(dirErr.message, message) {
+		t.Fatalf("expected %d %q, got %d %q", status, message, dirErr.status, dirErr.message)
+	}
+}
+
+func TestHandleExportStart_ReadOnlyStagingDirMessage(t *testing.T) {
+	stubProbeFile(t, syscall.EACCES, syscall.EACCES)
+	stagingDir := t.TempDir()
+	server := NewServer(t.TempDir(), "test-version", false)
+	body, _ := json.Marshal(map[string]interface{}{
+		"connection": map[string]interface{}{"url": "http://localhost:8428", "auth": map[string]interface{}{"type": "none"}},
+		"time_range": map[string]string{
+			"start": time.Now().Add(-time.Hour).Format(time.RFC3339),
+			"end":   time.Now().Format(time.RFC3339),
+		},
+		"batching":    map[string]interface{}{"enabled": true},
+		"staging_dir": stagingDir,
+	})
+	req := httptest.NewRequest(http.MethodPost, "/api/export/start", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	server.Router().ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), "exists but is not writable") || !strings.Contains(w.Body.String(), "Fix its permissions") {
+		t.Fatalf("expected an actionable message, got %s", w.Body.String())
+	}
+}
@@ -2315,6 +2315,17 @@ async function loadSampleMetrics() {
         renderDropLabels(sampleMetrics);
         updateSelectionSummary();
         window.__vm_samples_version = (window.__vm_samples_version || 0) + 1;
+        if (!(currentMode === 'custom' && customQueryType === 'metricsql')) {
+            loadLabelInventory({
+                connection: config,
+                time_range: { start: from, end: to },
+                components: uniqueComponents,
+                jobs: selectedJobs,
+                mode: currentMode,
+                query_type: currentMode === 'custom' ? customQueryType : '',
+                query: currentMode === 'custom' ? getActiveCustomQuery() : ''
+            });
+        }
         document.querySelectorAll('#advancedLoader,#previewLoader').forEach(el => el.remove());
         const globalSpinnerDone = document.getElementById('sampleGlobalSpinner');
         if (globalSpinnerDone) {
@@ -2433,11 +2444,11 @@ function renderSamplePreview(samples) {
     preview.innerHTML = html;
 }
 
-function renderAdvancedLabels(samples) {
+function renderAdvancedLabels(samples, inventory = []) {
     const container = document.getElementById('advancedLabels');
 
-    // Extract all unique labels
-    const labelSet = new Set();
+    // Extract all unique labels; the inventory adds labels missing from the preview samples
+    const labelSet = new Set(inventory);
     const labelSamples = {};
 
     samples.forEach(sample => {
@@ -2490,6 +2501,31 @@ function renderAdvancedLabels(samples) {
     });
 }
 
+// loadLabelInventory fetches every label name of the export set (not just the preview samples)
+// and re-renders the obfuscation checkboxes with it. Failures keep the sample-based list.
+async function loadLabelInventory(exportConfig) {
+    const samplesVersion = window.__vm_samples_version;
+    try {
+        const response = await fetch('/api/labels', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ config: exportConfig })
+        });
+        const data = await response.json();
+        if (!response.ok) {
+            console.warn('[LABELS] Label inventory unavailable:', data?.error || response.status);
+            return;
+        }
+        // Ignore stale responses if samples were reloaded meanwhile
+        if (window.__vm_samples_version !== samplesVersion) {
+            return;
+        }
+        renderAdvancedLabels(sampleMetrics, data.labels || []);
+    } catch (err) {
+        console.warn('[LABELS] Label inventory request failed:', err?.message || err);
+    }
+}
+
 function renderDropLabels(samples) {
     const container = document.getElementById('dropLabels');
     if (!container) {
@@ -3094,15 +3130,33 @@ async function exportMetrics(buttonElement) {
         };
         window.__lastExportStartPayload = exportPayload;
 
-        const response = await fetch('/api/export/start', {
+        const startExport = () => fetch('/api/export/start', {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
             body: JSON.stringify(exportPayload)
         });
+        let response = await startExport();
 
         console.log('[QUERY] Response Status:', response.status, response.statusText);
 
-        const data = await response.json();
+        let data = await response.json();
+
+        // Components with very large discovery estimates must be confirmed explicitly
+        if (response.status === 409 && Array.isArray(data.heavy_components) && data.heavy_components.length > 0) {
+            const list = data.heavy_components
+                .map(c => `  • ${c.component}: ~${Number(c.metrics_count_estimate).toLocaleString()} series`)
+                .join('\n');
+            if (!confirm(`These components are very large:\n${list}\n\nExporting them may take long and produce a big archive. Include them anyway?`)) {
+                console.groupEnd();
+                btn.disabled = false;
+                btn.textContent = originalText;
+                currentExportButton = null;
+                return;
+            }
+            exportPayload.confirmed_heavy_components = data.heavy_components.map(c => c.component);
+            response = await startExport();
+            data = await response.json();
+        }
 
         if (!response.ok) {
             throw new Error(data.error || 'Export failed');
@@ -0,0 +1,158 @@
+package integration
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/vmgather/internal/application/services"
+	"github.com/VictoriaMetrics/vmgather/internal/domain"
+	importer "github.com/VictoriaMetrics/vmgather/internal/importer/server"
+)
+
+// TestCounterDeltaRoundTrip exports counters with delta encoding and imports the archive with
+// vmimporter, asserting the imported samples equal the original absolute values.
+func TestCounterDeltaRoundTrip(t *testing.T) {
+	now := time.Now().Add(-10 * time.Minute).UnixMilli()
+	timestamps := []int64{now, now + 15000, now + 30000, now + 45000, now + 60000}
+	original := map[string][]float64{
+		"http_requests_total":       {1000, 1250, 1600, 3, 40}, // includes a counter reset
+		"process_cpu_seconds_total": {1.5, 1.75, 2, 2.5, 3},
+		"go_goroutines":             {12, 14, 13, 12, 15},
+	}
+
+	var exportBody strings.Builder
+	for name, values := range original {
+		line, _ := json.Marshal(map[string]interface{}{
+			"metric":     map[string]string{"__name__": name, "job": "app"},
+			"values":     values,
+			"timestamps": timestamps,
+		})
+		exportBody.Write(line)
+		exportBody.WriteByte('\n')
+	}
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, exportBody.String())
+	}))
+	defer source.Close()
+
+	exportService := services.NewExportService(t.TempDir(), "test")
+	result, err := exportService.ExecuteExport(context.Background(), domain.ExportConfig{
+		Connection:        domain.VMConnection{URL: source.URL},
+		TimeRange:         domain.TimeRange{Start: time.UnixMilli(now).Add(-time.Minute), End: time.UnixMilli(now).Add(5 * time.Minute)},
+		Jobs:              []string{"app"},
+		StagingDir:        t.TempDir(),
+		MetricStepSeconds: 15,
+		CounterEncoding:   domain.CounterEncodingDelta,
+	})
+	if err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+
+	var mu sync.Mutex
+	imported := make(map[string][]float64)
+	var importedLabels []map[string]string
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/api/v1/import"):
+			scanner := bufio.NewScanner(r.Body)
+			scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+			mu.Lock()
+			defer mu.Unlock()
+			for scanner.Scan() {
+				var line struct {
+					Metric map[string]string `json:"metric"`
+					Values []float64         `json:"values"`
+				}
+				if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+					t.Errorf("invalid import line %q: %v", scanner.Text(), err)
+					continue
+				}
+				imported[line.Metric["__name__"]] = append(imported[line.Metric["__name__"]], line.Values...)
+				importedLabels = append(importedLabels, line.Metric)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case strings.HasSuffix(r.URL.Path, "/api/v1/series"):
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = io.WriteString(w, `{"status":"success","data":[{"__name__":"http_requests_total"}]}`)
+		case strings.HasSuffix(r.URL.Path, "/api/v1/status/tsdb"):
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = io.WriteString(w, `{"status":"success","data":{"retentionTime":"1y"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer target.Close()
+
+	importServer := importer.NewServer("test")
+	ui := httptest.NewServer(importServer.Router())
+	defer ui.Close()
+
+	archive, err := os.ReadFile(result.ArchivePath)
+	if err != nil {
+		t.Fatalf("failed to read archive: %v", err)
+	}
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	_ = writer.WriteField("config", fmt.Sprintf(`{"endpoint":%q}`, target.URL))
+	part, _ := writer.CreateFormFile("bundle", result.ArchiveName)
+	_, _ = part.Write(archive)
+	_ = writer.Close()
+
+	resp, err := http.Post(ui.URL+"/api/upload", writer.FormDataContentType(), body)
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+	var created struct {
+		JobID string `json:"job_id"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&created)
+	_ = resp.Body.Close()
+	if created.JobID == "" {
+		t.Fatalf("upload returned no job id (HTTP %d)", resp.StatusCode)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		statusResp, err := http.Get(ui.URL + "/api/import/status?id=" + created.JobID)
+		if err != nil {
+			t.Fatalf("status request failed: %v", err)
+		}
+		var job struct {
+			State   string `json:"state"`
+			Message string `json:"message"`
+		}
+		_ = json.NewDecoder(statusResp.Body).Decode(&job)
+		_ = statusResp.Body.Close()
+		if job.State == "completed" {
+			break
+		}
+		if job.State == "failed" || time.Now().After(deadline) {
+			t.Fatalf("import did not complete: state=%s message=%s", job.State, job.Message)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(imported, original) {
+		t.Fatalf("round trip changed values:\n got  %v\n want %v", imported, original)
+	}
+	for _, labels := range importedLabels {
+		if _, ok := labels[domain.CounterEncodingLabel]; ok {
+			t.Fatalf("importer must strip the counter encoding marker, got labels %v", labels)
+		}
+	}
+}